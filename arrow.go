@@ -0,0 +1,370 @@
+package seafan
+
+// arrow.go converts Pipelines to and from Apache Arrow records and Feather (Arrow IPC) files
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+// keys of the Arrow field metadata that record the FType of a field
+const (
+	arrowRole     = "seafan.role"
+	arrowNorm     = "seafan.normalized"
+	arrowLocation = "seafan.location"
+	arrowScale    = "seafan.scale"
+)
+
+// ToArrow converts the FRCts and FRCat fields of pipe to an Arrow record.  FROneHot and FREmbed fields are not
+// included since they are built from an FRCat field.
+//
+// FRCts fields are float64 columns of the un-normalized values.  If a field isn't normalized, its column shares
+// memory with the pipeline. FRCat fields are columns of their raw values: strings, int32, int64, float64 or
+// timestamps (UTC, milliseconds).
+//
+// The role of each field and, for normalized fields, its location and scale are stored in the field metadata so
+// that FromArrow can restore them.  The caller should Release the record when done.
+func ToArrow(pipe Pipeline) (array.Record, error) {
+	gd := pipe.GData()
+	mem := memory.NewGoAllocator()
+
+	var (
+		fields []arrow.Field
+		cols   []array.Interface
+	)
+
+	defer func() {
+		for _, col := range cols {
+			col.Release()
+		}
+	}()
+
+	for _, fld := range gd.FieldList() {
+		d := gd.Get(fld)
+		if d.FT.Role != FRCts && d.FT.Role != FRCat {
+			continue
+		}
+
+		col, e := arrowColumn(mem, gd, d)
+		if e != nil {
+			return nil, Wrapper(e, "ToArrow")
+		}
+
+		keys, vals := []string{arrowRole, arrowNorm}, []string{d.FT.Role.String(), strconv.FormatBool(d.FT.Normalized)}
+		if d.FT.Normalized {
+			keys = append(keys, arrowLocation, arrowScale)
+			vals = append(vals, strconv.FormatFloat(d.FT.FP.Location, 'g', -1, 64),
+				strconv.FormatFloat(d.FT.FP.Scale, 'g', -1, 64))
+		}
+
+		md := arrow.NewMetadata(keys, vals)
+		fields = append(fields, arrow.Field{Name: fld, Type: col.DataType(), Nullable: false, Metadata: md})
+		cols = append(cols, col)
+	}
+
+	return array.NewRecord(arrow.NewSchema(fields, nil), cols, int64(gd.Rows())), nil
+}
+
+// arrowColumn returns the Arrow array of d
+func arrowColumn(mem memory.Allocator, gd *GData, d *GDatum) (array.Interface, error) {
+	if d.FT.Role == FRCts {
+		x := d.Floats()
+		if d.FT.Normalized {
+			x = UnNormalize(append([]float64{}, x...), d.FT)
+		}
+
+		data := array.NewData(arrow.PrimitiveTypes.Float64, len(x),
+			[]*memory.Buffer{nil, memory.NewBufferBytes(arrow.Float64Traits.CastToBytes(x))}, nil, 0, 0)
+		defer data.Release()
+
+		return array.NewFloat64Data(data), nil
+	}
+
+	raw, e := gd.GetRaw(d.FT.Name)
+	if e != nil {
+		return nil, e
+	}
+
+	switch raw.Kind {
+	case reflect.String:
+		bld := array.NewStringBuilder(mem)
+		defer bld.Release()
+
+		for _, v := range raw.Data {
+			bld.Append(v.(string))
+		}
+
+		return bld.NewArray(), nil
+	case reflect.Struct:
+		bld := array.NewTimestampBuilder(mem, arrow.FixedWidthTypes.Timestamp_ms.(*arrow.TimestampType))
+		defer bld.Release()
+
+		for _, v := range raw.Data {
+			dt, ok := v.(time.Time)
+			if !ok {
+				return nil, Wrapper(ErrPipe, fmt.Sprintf("field %s: unsupported type %T", d.FT.Name, v))
+			}
+
+			bld.Append(arrow.Timestamp(dt.UnixMilli()))
+		}
+
+		return bld.NewArray(), nil
+	case reflect.Int32:
+		bld := array.NewInt32Builder(mem)
+		defer bld.Release()
+
+		for _, v := range raw.Data {
+			bld.Append(v.(int32))
+		}
+
+		return bld.NewArray(), nil
+	case reflect.Int64, reflect.Int:
+		bld := array.NewInt64Builder(mem)
+		defer bld.Release()
+
+		for _, v := range raw.Data {
+			bld.Append(reflect.ValueOf(v).Int())
+		}
+
+		return bld.NewArray(), nil
+	case reflect.Float64, reflect.Float32:
+		bld := array.NewFloat64Builder(mem)
+		defer bld.Release()
+
+		for _, v := range raw.Data {
+			bld.Append(reflect.ValueOf(v).Float())
+		}
+
+		return bld.NewArray(), nil
+	}
+
+	return nil, Wrapper(ErrPipe, fmt.Sprintf("field %s: unsupported type %v", d.FT.Name, raw.Kind))
+}
+
+// FromArrow creates a *VecData from an Arrow record.  Fields written by ToArrow have their roles and normalization
+// restored.  Otherwise, string, timestamp, date and boolean columns are FRCat and numeric columns are FRCts.
+// Null floats are NaN and null strings are "". Nulls in other types are an error.
+// opts are applied to the returned pipeline.
+func FromArrow(rec array.Record, opts ...Opts) (Pipeline, error) {
+	cols, e := arrowValues(rec)
+	if e != nil {
+		return nil, Wrapper(e, "FromArrow")
+	}
+
+	gd, e := arrowGData(rec.Schema(), cols)
+	if e != nil {
+		return nil, Wrapper(e, "FromArrow")
+	}
+
+	return NewVecData("arrow", gd, opts...), nil
+}
+
+// arrowValues returns the values of the columns of rec
+func arrowValues(rec array.Record) ([][]any, error) {
+	cols := make([][]any, rec.NumCols())
+
+	for c, col := range rec.Columns() {
+		name := rec.ColumnName(c)
+		vals := make([]any, col.Len())
+
+		for row := 0; row < col.Len(); row++ {
+			if col.IsNull(row) {
+				switch col.(type) {
+				case *array.Float64, *array.Float32:
+					vals[row] = math.NaN()
+				case *array.String:
+					vals[row] = ""
+				default:
+					return nil, Wrapper(ErrPipe, fmt.Sprintf("field %s has nulls", name))
+				}
+
+				continue
+			}
+
+			switch a := col.(type) {
+			case *array.Float64:
+				vals[row] = a.Value(row)
+			case *array.Float32:
+				vals[row] = float64(a.Value(row))
+			case *array.Int64:
+				vals[row] = a.Value(row)
+			case *array.Int32:
+				vals[row] = a.Value(row)
+			case *array.Int16:
+				vals[row] = int32(a.Value(row))
+			case *array.Int8:
+				vals[row] = int32(a.Value(row))
+			case *array.Uint32:
+				vals[row] = int64(a.Value(row))
+			case *array.Uint16:
+				vals[row] = int32(a.Value(row))
+			case *array.Uint8:
+				vals[row] = int32(a.Value(row))
+			case *array.Boolean:
+				vals[row] = int32(0)
+				if a.Value(row) {
+					vals[row] = int32(1)
+				}
+			case *array.String:
+				vals[row] = a.Value(row)
+			case *array.Timestamp:
+				unit := a.DataType().(*arrow.TimestampType).Unit
+				vals[row] = time.Unix(0, 0).UTC().Add(time.Duration(a.Value(row)) * unit.Multiplier())
+			case *array.Date32:
+				vals[row] = time.Unix(0, 0).UTC().AddDate(0, 0, int(a.Value(row)))
+			case *array.Date64:
+				vals[row] = time.UnixMilli(int64(a.Value(row))).UTC()
+			default:
+				return nil, Wrapper(ErrPipe, fmt.Sprintf("field %s: unsupported type %v", name, col.DataType()))
+			}
+		}
+
+		cols[c] = vals
+	}
+
+	return cols, nil
+}
+
+// arrowGData builds a GData with the columns cols described by schema
+func arrowGData(schema *arrow.Schema, cols [][]any) (*GData, error) {
+	gd := NewGData()
+
+	for c, fld := range schema.Fields() {
+		raw := NewRaw(cols[c], nil)
+
+		role := FRCts
+		switch fld.Type.ID() {
+		case arrow.STRING, arrow.TIMESTAMP, arrow.DATE32, arrow.DATE64, arrow.BOOL:
+			role = FRCat
+		}
+
+		if ind := fld.Metadata.FindKey(arrowRole); ind >= 0 {
+			switch fld.Metadata.Values()[ind] {
+			case FRCat.String():
+				role = FRCat
+			case FRCts.String():
+				role = FRCts
+			}
+		}
+
+		if role == FRCat {
+			if e := gd.AppendD(raw, fld.Name, nil, false); e != nil {
+				return nil, e
+			}
+
+			continue
+		}
+
+		normalize, fp := arrowNormalized(fld.Metadata)
+		if e := gd.AppendC(raw, fld.Name, normalize, fp, false); e != nil {
+			return nil, e
+		}
+	}
+
+	return gd, nil
+}
+
+// arrowNormalized returns whether the field with metadata md was normalized and, if known, its FParam
+func arrowNormalized(md arrow.Metadata) (normalize bool, fp *FParam) {
+	find := func(key string) string {
+		if ind := md.FindKey(key); ind >= 0 {
+			return md.Values()[ind]
+		}
+
+		return ""
+	}
+
+	if normalize, _ = strconv.ParseBool(find(arrowNorm)); !normalize {
+		return false, nil
+	}
+
+	loc, e1 := strconv.ParseFloat(find(arrowLocation), 64)
+	scale, e2 := strconv.ParseFloat(find(arrowScale), 64)
+
+	if e1 != nil || e2 != nil {
+		return true, nil
+	}
+
+	return true, &FParam{Location: loc, Scale: scale}
+}
+
+// PipeToFeather saves the pipe as a Feather (Arrow IPC) file.  See ToArrow for the fields saved.
+func PipeToFeather(pipe Pipeline, outFile string) (err error) {
+	if outFile == "" {
+		return Wrapper(ErrPipe, "PipeToFeather: outFile cannot be empty")
+	}
+
+	rec, err := ToArrow(pipe)
+	if err != nil {
+		return err
+	}
+	defer rec.Release()
+
+	handle, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = handle.Close() }()
+
+	wtr, err := ipc.NewFileWriter(handle, ipc.WithSchema(rec.Schema()))
+	if err != nil {
+		return err
+	}
+
+	if err = wtr.Write(rec); err != nil {
+		_ = wtr.Close()
+		return err
+	}
+
+	return wtr.Close()
+}
+
+// FeatherToPipe creates a *VecData from a Feather (Arrow IPC) file.  The records of the file are stacked.
+// See FromArrow for the conversion of the columns.
+func FeatherToPipe(inFile string, opts ...Opts) (Pipeline, error) {
+	handle, e := os.Open(inFile)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = handle.Close() }()
+
+	rdr, e := ipc.NewFileReader(handle)
+	if e != nil {
+		return nil, Wrapper(ErrPipe, fmt.Sprintf("FeatherToPipe: %v", e))
+	}
+	defer func() { _ = rdr.Close() }()
+
+	cols := make([][]any, len(rdr.Schema().Fields()))
+
+	for r := 0; r < rdr.NumRecords(); r++ {
+		rec, e := rdr.Record(r)
+		if e != nil {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("FeatherToPipe: %v", e))
+		}
+
+		vals, e := arrowValues(rec)
+		if e != nil {
+			return nil, Wrapper(e, "FeatherToPipe")
+		}
+
+		for c := range cols {
+			cols[c] = append(cols[c], vals[c]...)
+		}
+	}
+
+	gd, e := arrowGData(rdr.Schema(), cols)
+	if e != nil {
+		return nil, Wrapper(e, "FeatherToPipe")
+	}
+
+	return NewVecData("feather", gd, opts...), nil
+}
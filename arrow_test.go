@@ -0,0 +1,95 @@
+package seafan
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToArrow(t *testing.T) {
+	dts := []any{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRaw([]any{1.0, 2.0, -1.0, 4.0}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{1.0, 2.0, 3.0, 4.0}, nil), "z", true, &FParam{Location: 2, Scale: 4}, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "c"}, nil), "s", nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{int32(3), int32(1), int32(3), int32(2)}, nil), "i", nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw(dts, nil), "dt", nil, false))
+	assert.Nil(t, gd.MakeOneHot("s", "sOH"))
+	pipe := NewVecData("arrow", gd)
+
+	rec, e := ToArrow(pipe)
+	assert.Nil(t, e)
+	defer rec.Release()
+
+	// the one-hot field is not included
+	assert.Equal(t, int64(5), rec.NumCols())
+	assert.Equal(t, int64(4), rec.NumRows())
+	assert.Equal(t, 4.0, rec.Column(1).(*array.Float64).Value(3))
+	assert.Equal(t, "c", rec.Column(2).(*array.String).Value(3))
+	assert.Equal(t, arrow.INT32, rec.Column(3).DataType().ID())
+	assert.Equal(t, arrow.TIMESTAMP, rec.Column(4).DataType().ID())
+
+	back, e := FromArrow(rec)
+	assert.Nil(t, e)
+	assert.Equal(t, pipe.Rows(), back.Rows())
+
+	for _, fld := range []string{"x", "z", "s", "i", "dt"} {
+		ft, ftBack := pipe.GetFType(fld), back.GetFType(fld)
+		assert.Equal(t, ft.Role, ftBack.Role, fld)
+		assert.Equal(t, ft.Normalized, ftBack.Normalized, fld)
+		assert.Equal(t, pipe.Get(fld).Data, back.Get(fld).Data, fld)
+	}
+
+	assert.Equal(t, 2.0, back.GetFType("z").FP.Location)
+
+	raw, e := back.GData().GetRaw("dt")
+	assert.Nil(t, e)
+	assert.Equal(t, dts, raw.Data)
+
+	// a record built elsewhere with nulls
+	mem := memory.NewGoAllocator()
+	fb, sb := array.NewFloat64Builder(mem), array.NewStringBuilder(mem)
+	fb.AppendValues([]float64{1, 2}, []bool{true, false})
+	sb.AppendValues([]string{"u", ""}, []bool{true, false})
+
+	fa, sa := fb.NewArray(), sb.NewArray()
+	schema := arrow.NewSchema([]arrow.Field{{Name: "f", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "s", Type: arrow.BinaryTypes.String, Nullable: true}}, nil)
+	ext := array.NewRecord(schema, []array.Interface{fa, sa}, 2)
+	defer ext.Release()
+
+	extPipe, e := FromArrow(ext)
+	assert.Nil(t, e)
+	assert.True(t, math.IsNaN(extPipe.Get("f").Data.([]float64)[1]))
+	assert.Equal(t, FRCat, extPipe.GetFType("s").Role)
+}
+
+func TestPipeToFeather(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRaw([]any{1.0, 2.0, 3.0}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a"}, nil), "s", nil, false))
+	pipe := NewVecData("feather", gd)
+
+	file := os.TempDir() + "/seafanTest.feather"
+	defer func() { _ = os.Remove(file) }()
+
+	assert.Nil(t, PipeToFeather(pipe, file))
+
+	back, e := FeatherToPipe(file, WithBatchSize(3))
+	assert.Nil(t, e)
+	assert.Equal(t, 3, back.BatchSize())
+	assert.Equal(t, []float64{1, 2, 3}, back.Get("x").Data)
+	assert.Equal(t, pipe.Get("s").Data, back.Get("s").Data)
+
+	_, e = FeatherToPipe(os.TempDir() + "/nope.feather")
+	assert.NotNil(t, e)
+	assert.NotNil(t, PipeToFeather(pipe, ""))
+}
@@ -0,0 +1,183 @@
+package seafan
+
+// backtest.go implements rolling-origin backtests of NNModels
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// BacktestWindow is a single train/validation split of a backtest.  The train data has dates in
+// [TrainStart, TrainEnd), the validation data in [ValStart, ValEnd).
+type BacktestWindow struct {
+	TrainStart time.Time
+	TrainEnd   time.Time
+	ValStart   time.Time
+	ValEnd     time.Time
+	Train      Pipeline
+	Val        Pipeline
+}
+
+// BacktestSplits splits pipe into rolling-origin train/validation windows based on the date field dateField.
+// The first window trains on the first trainMonths months of data and validates on the next valMonths months.
+// Each subsequent window moves the origin (the end of the train data) stepMonths months later.  If sliding is
+// true, the train data of each window is the trainMonths months before the origin.  Otherwise, the train window
+// expands to include all the data before the origin.  Windows with no train or validation data are skipped.
+func BacktestSplits(pipe Pipeline, dateField string, trainMonths, valMonths, stepMonths int, sliding bool) ([]*BacktestWindow, error) {
+	if trainMonths < 1 || valMonths < 1 || stepMonths < 1 {
+		return nil, Wrapper(ErrPipe, "BacktestSplits: months must be positive")
+	}
+
+	dates, e := panelDates(pipe, dateField)
+	if e != nil {
+		return nil, Wrapper(e, "BacktestSplits")
+	}
+
+	if len(dates) == 0 {
+		return nil, Wrapper(ErrPipe, "BacktestSplits: no data")
+	}
+
+	minDt, maxDt := dates[0], dates[0]
+	for _, dt := range dates {
+		if dt.Before(minDt) {
+			minDt = dt
+		}
+
+		if dt.After(maxDt) {
+			maxDt = dt
+		}
+	}
+
+	windows := make([]*BacktestWindow, 0)
+
+	for origin := minDt.AddDate(0, trainMonths, 0); !origin.After(maxDt); origin = origin.AddDate(0, stepMonths, 0) {
+		w := &BacktestWindow{TrainStart: minDt, TrainEnd: origin, ValStart: origin, ValEnd: origin.AddDate(0, valMonths, 0)}
+		if sliding {
+			w.TrainStart = origin.AddDate(0, -trainMonths, 0)
+		}
+
+		var trainRows, valRows []int
+
+		for row, dt := range dates {
+			switch {
+			case !dt.Before(w.TrainStart) && dt.Before(w.TrainEnd):
+				trainRows = append(trainRows, row)
+			case !dt.Before(w.ValStart) && dt.Before(w.ValEnd):
+				valRows = append(valRows, row)
+			}
+		}
+
+		if len(trainRows) == 0 || len(valRows) == 0 {
+			continue
+		}
+
+		if w.Train, e = subsetPipe(pipe, trainRows); e != nil {
+			return nil, Wrapper(e, "BacktestSplits")
+		}
+
+		if w.Val, e = subsetPipe(pipe, valRows); e != nil {
+			return nil, Wrapper(e, "BacktestSplits")
+		}
+
+		windows = append(windows, w)
+	}
+
+	return windows, nil
+}
+
+// subsetPipe returns a *VecData with rows of pipe.  The batch size is that of pipe, up to the number of rows.
+func subsetPipe(pipe Pipeline, rows []int) (Pipeline, error) {
+	gd, e := pipe.GData().Subset(rows)
+	if e != nil {
+		return nil, e
+	}
+
+	bSize := pipe.BatchSize()
+	if bSize > len(rows) || bSize == 0 {
+		bSize = len(rows)
+	}
+
+	out := NewVecData("backtest", gd, WithBatchSize(bSize))
+	WithKeepRaw(pipe.GetKeepRaw())(out)
+
+	return out, nil
+}
+
+// Backtest fits the model modSpec on the train data of each window and evaluates it on the validation data.
+// nnOpts are applied to each NNModel (these must include a cost function), fitOpts to each Fit.  The model output
+// is the sum of the target columns (as in AddFitted).
+//
+// The result has one row per window with the fields window, trainStart, trainEnd, valStart, valEnd, trainRows,
+// valRows, cost (the cost on the validation data), meanObs and meanFit (the means of the target and the model
+// output on the validation data).
+func Backtest(windows []*BacktestWindow, modSpec ModSpec, epochs int, target []int, nnOpts []NNOpts, fitOpts []FitOpts) (Pipeline, error) {
+	if len(windows) == 0 {
+		return nil, Wrapper(ErrNNModel, "Backtest: no windows")
+	}
+
+	fields := []string{"window", "trainStart", "trainEnd", "valStart", "valEnd", "trainRows", "valRows", "cost",
+		"meanObs", "meanFit"}
+	cols := make([][]any, len(fields))
+
+	for ind, w := range windows {
+		cost, meanObs, meanFit, e := backtestWindow(w, modSpec, epochs, target, nnOpts, fitOpts)
+		if e != nil {
+			return nil, Wrapper(e, fmt.Sprintf("Backtest: window %d", ind))
+		}
+
+		vals := []any{int32(ind), w.TrainStart, w.TrainEnd, w.ValStart, w.ValEnd, int32(w.Train.Rows()),
+			int32(w.Val.Rows()), cost, meanObs, meanFit}
+
+		for col, v := range vals {
+			cols[col] = append(cols[col], v)
+		}
+	}
+
+	return VecFromAny(cols, fields, nil)
+}
+
+// backtestWindow fits and evaluates the model on a single window
+func backtestWindow(w *BacktestWindow, modSpec ModSpec, epochs int, target []int, nnOpts []NNOpts,
+	fitOpts []FitOpts) (cost, meanObs, meanFit float64, err error) {
+	nn, e := NewNNModel(modSpec, w.Train, true, nnOpts...)
+	if e != nil {
+		return 0, 0, 0, e
+	}
+
+	if nn.CostFn() == nil {
+		return 0, 0, 0, Wrapper(ErrNNModel, "no cost function")
+	}
+
+	ft := NewFit(nn, epochs, w.Train, fitOpts...)
+	if e := ft.Do(); e != nil {
+		return 0, 0, 0, e
+	}
+
+	defer func() {
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+	}()
+
+	bSize := w.Val.BatchSize()
+	WithBatchSize(w.Val.Rows())(w.Val)
+	defer WithBatchSize(bSize)(w.Val)
+
+	pred, e := PredictNN(ft.OutFile(), w.Val, false, WithCostFn(nn.CostFn()))
+	if e != nil {
+		return 0, 0, 0, e
+	}
+
+	fit, obs := pred.FitSlice(), pred.ObsSlice()
+	outCols := pred.OutputCols()
+	rows := w.Val.Rows()
+
+	for row := 0; row < rows; row++ {
+		for _, col := range target {
+			meanFit += fit[row*outCols+col]
+			meanObs += obs[row*outCols+col]
+		}
+	}
+
+	return pred.CostFlt(), meanObs / float64(rows), meanFit / float64(rows), nil
+}
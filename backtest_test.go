@@ -0,0 +1,71 @@
+package seafan
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBacktest(t *testing.T) {
+	Verbose = false
+	SetSeed(3)
+
+	const perMonth = 50
+
+	var (
+		dts  []any
+		x, y []float64
+	)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for month := 0; month < 12; month++ {
+		for ind := 0; ind < perMonth; ind++ {
+			xv := float64(ind) / perMonth
+			dts = append(dts, start.AddDate(0, month, 0))
+			x = append(x, xv)
+			y = append(y, 2*xv+0.1*math.Sin(float64(ind)))
+		}
+	}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw(dts, nil), "month", nil, true))
+	assert.Nil(t, gd.AppendC(NewRawCast(x, nil), "x", false, nil, true))
+	assert.Nil(t, gd.AppendC(NewRawCast(y, nil), "y", false, nil, true))
+	pipe := NewVecData("backtest", gd, WithBatchSize(perMonth))
+
+	// expanding: origins at months 6, 8, 10
+	windows, e := BacktestSplits(pipe, "month", 6, 2, 2, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, len(windows))
+	assert.Equal(t, 6*perMonth, windows[0].Train.Rows())
+	assert.Equal(t, 10*perMonth, windows[2].Train.Rows())
+	assert.Equal(t, 2*perMonth, windows[2].Val.Rows())
+
+	// sliding: the train window stays 6 months
+	sliding, e := BacktestSplits(pipe, "month", 6, 1, 3, true)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(sliding))
+	assert.Equal(t, 6*perMonth, sliding[1].Train.Rows())
+	assert.Equal(t, start.AddDate(0, 3, 0), sliding[1].TrainStart)
+
+	mod := ModSpec{
+		"Input(x)",
+		"FC(size:1)",
+		"Target(y)",
+	}
+
+	result, e := Backtest(windows, mod, 10, []int{0}, []NNOpts{WithCostFn(RMS)}, nil)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, result.Rows())
+	assert.Equal(t, []float64{300, 400, 500}, result.Get("trainRows").Data)
+
+	for _, c := range result.Get("cost").Data.([]float64) {
+		assert.False(t, math.IsNaN(c))
+	}
+
+	_, e = Backtest(windows, mod, 1, []int{0}, nil, nil)
+	assert.NotNil(t, e)
+}
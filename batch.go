@@ -0,0 +1,136 @@
+package seafan
+
+// batch.go loads batches of a Pipeline into the input nodes of a model
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// batchBuffers are the tensors, by input node name, that Batch copies batches into.  See WithBatchBuffers.
+type batchBuffers map[string]tensor.Tensor
+
+// batchSlice returns the rows startRow to endRow of d and the shape of the tensor that holds them.  FRCts data
+// stored as float32 is converted to float64.
+func batchSlice(d *GDatum, startRow, endRow int) (backing any, shape []int) {
+	bs := endRow - startRow
+
+	switch d.FT.Role {
+	case FRCts:
+		if x, ok := d.Data.([]float32); ok {
+			xOut := make([]float64, bs)
+			for ind, xv := range x[startRow:endRow] {
+				xOut[ind] = float64(xv)
+			}
+
+			return xOut, []int{bs, 1}
+		}
+
+		return d.Data.([]float64)[startRow:endRow], []int{bs, 1}
+	case FRCat:
+		return d.Data.([]int32)[startRow:endRow], []int{bs, 1}
+	case FROneHot, FREmbed:
+		return d.Data.([]float64)[startRow*d.FT.Cats : endRow*d.FT.Cats], []int{bs, d.FT.Cats}
+	case FRSeq:
+		steps, feats := d.FT.SeqShape()
+		return d.Data.([]float64)[startRow*d.FT.Cats : endRow*d.FT.Cats], []int{bs, steps, feats}
+	}
+
+	return nil, nil
+}
+
+// letBatch binds rows startRow to endRow of d to the input node nd.  If bufs is nil, the tensor is backed by the
+// data of d.  Otherwise, the rows are copied into a tensor allocated on the first batch, and nd is bound only if
+// it isn't bound to that tensor already.
+func letBatch(nd *G.Node, d *GDatum, startRow, endRow int, bufs batchBuffers) error {
+	backing, shape := batchSlice(d, startRow, endRow)
+	if backing == nil {
+		return fmt.Errorf("cannot batch field %s with role %v", d.FT.Name, d.FT.Role)
+	}
+
+	return letBacking(nd, backing, shape, bufs)
+}
+
+// letPadded binds the rows of d from startRow to the end to the input node nd, padded to bs rows with copies of
+// the last row.
+func letPadded(nd *G.Node, d *GDatum, startRow, bs int) error {
+	nRow := d.Summary.NRows
+	backing, shape := batchSlice(d, startRow, nRow)
+
+	switch x := backing.(type) {
+	case []float64:
+		backing = padRows(x, nRow-startRow, bs)
+	case []int32:
+		backing = padRows(x, nRow-startRow, bs)
+	default:
+		return fmt.Errorf("cannot batch field %s with role %v", d.FT.Name, d.FT.Role)
+	}
+
+	shape[0] = bs
+
+	return letBacking(nd, backing, shape, nil)
+}
+
+// padRows returns a copy of the n rows of x padded to bs rows with copies of the last row
+func padRows[T any](x []T, n, bs int) []T {
+	width := len(x) / n
+	out := make([]T, 0, bs*width)
+	out = append(out, x...)
+
+	for len(out) < bs*width {
+		out = append(out, x[len(x)-width:]...)
+	}
+
+	return out
+}
+
+// letBacking binds a tensor of backing with shape shape to the input node nd (see letBatch).
+func letBacking(nd *G.Node, backing any, shape []int, bufs batchBuffers) error {
+	// the codes of an embedded FRCat field are a vector of ints (see NewNNModel)
+	if codes, ok := backing.([]int32); ok && nd.Dtype() == tensor.Int {
+		ints := make([]int, len(codes))
+		for ind, code := range codes {
+			ints[ind] = int(code)
+		}
+
+		backing, shape = ints, []int{len(ints)}
+	}
+
+	if bufs == nil {
+		return G.Let(nd, tensor.New(tensor.WithBacking(backing), tensor.WithShape(shape...)))
+	}
+
+	buf := bufs[nd.Name()]
+	if buf == nil || !buf.Shape().Eq(tensor.Shape(shape)) {
+		var data any
+
+		switch x := backing.(type) {
+		case []float64:
+			data = make([]float64, len(x))
+		case []int32:
+			data = make([]int32, len(x))
+		case []int:
+			data = make([]int, len(x))
+		}
+
+		buf = tensor.New(tensor.WithBacking(data), tensor.WithShape(shape...))
+		bufs[nd.Name()] = buf
+	}
+
+	switch x := backing.(type) {
+	case []float64:
+		copy(buf.Data().([]float64), x)
+	case []int32:
+		copy(buf.Data().([]int32), x)
+	case []int:
+		copy(buf.Data().([]int), x)
+	}
+
+	if v, ok := nd.Value().(tensor.Tensor); ok && v == buf {
+		return nil
+	}
+
+	return G.Let(nd, buf)
+}
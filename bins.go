@@ -0,0 +1,197 @@
+package seafan
+
+// bins.go groups continuous fields into bins: histograms, equal-frequency bins and binned FRCat fields
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Bins are the bins of a continuous field.  Bin i is [Edges[i], Edges[i+1]) except the last bin, which includes
+// its upper edge. Edges and counts are in the units of the raw data (i.e. not normalized).
+type Bins struct {
+	Field   string    // field the bins were built from
+	Edges   []float64 // bin edges, len(Counts)+1 values
+	Counts  []int     // # of observations in each bin
+	Missing int       // # of NaN observations, which are not in any bin
+}
+
+// Histogram returns bins equal-width bins spanning the range of the field. The field must be FRCts.
+func (g *GDatum) Histogram(bins int) (*Bins, error) {
+	x, e := g.binData(bins, "Histogram")
+	if e != nil {
+		return nil, e
+	}
+
+	lo, hi := x[0], x[len(x)-1]
+	if lo == hi {
+		bins = 1
+	}
+
+	edges := make([]float64, bins+1)
+	for ind := 0; ind < bins; ind++ {
+		edges[ind] = lo + float64(ind)*(hi-lo)/float64(bins)
+	}
+
+	edges[bins] = hi
+
+	return g.newBins(edges, x), nil
+}
+
+// EqualFreqBins returns k bins whose edges are the quantiles of the field, so each bin has about the same number of
+// observations. Edges that repeat (due to ties) are collapsed, so there may be fewer than k bins.  The field must
+// be FRCts.
+func (g *GDatum) EqualFreqBins(k int) (*Bins, error) {
+	x, e := g.binData(k, "EqualFreqBins")
+	if e != nil {
+		return nil, e
+	}
+
+	edges := []float64{x[0]}
+
+	for b := 1; b < k; b++ {
+		if q := x[int(float64(b)*float64(len(x))/float64(k))]; q > edges[len(edges)-1] {
+			edges = append(edges, q)
+		}
+	}
+
+	// the last edge is the max, which may repeat the previous edge if all values are the same
+	if hi := x[len(x)-1]; hi > edges[len(edges)-1] || len(edges) == 1 {
+		edges = append(edges, hi)
+	}
+
+	return g.newBins(edges, x), nil
+}
+
+// binData returns the sorted, un-normalized data of g without NaNs
+func (g *GDatum) binData(bins int, caller string) ([]float64, error) {
+	if g.FT.Role != FRCts {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("%s: field %s is not FRCts", caller, g.FT.Name))
+	}
+
+	if bins < 1 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("%s: need at least 1 bin, got %d", caller, bins))
+	}
+
+	x := make([]float64, 0, g.Summary.NRows)
+
+	for _, xv := range UnNormalize(append([]float64{}, g.Floats()...), g.FT) {
+		if !math.IsNaN(xv) {
+			x = append(x, xv)
+		}
+	}
+
+	if len(x) == 0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("%s: field %s has no data", caller, g.FT.Name))
+	}
+
+	sort.Float64s(x)
+
+	return x, nil
+}
+
+// newBins counts the values of x in the bins defined by edges
+func (g *GDatum) newBins(edges, x []float64) *Bins {
+	b := &Bins{Field: g.FT.Name, Edges: edges, Counts: make([]int, len(edges)-1)}
+	b.Missing = g.Summary.NRows - len(x)
+
+	for _, xv := range x {
+		b.Counts[b.Bin(xv)]++
+	}
+
+	return b
+}
+
+// Bin returns the index of the bin that x falls in.  Values below (above) the range of the bins are put in the first
+// (last) bin.  NaN returns -1.
+func (b *Bins) Bin(x float64) int {
+	if math.IsNaN(x) {
+		return -1
+	}
+
+	inner := b.Edges[1 : len(b.Edges)-1]
+
+	return sort.Search(len(inner), func(i int) bool { return inner[i] > x })
+}
+
+// Labels returns a label for each bin, such as "[1, 2.5)".
+func (b *Bins) Labels() []string {
+	labels := make([]string, len(b.Counts))
+	for ind := range labels {
+		closer := ")"
+		if ind == len(labels)-1 {
+			closer = "]"
+		}
+
+		labels[ind] = fmt.Sprintf("[%g, %g%s", b.Edges[ind], b.Edges[ind+1], closer)
+	}
+
+	return labels
+}
+
+// Shares returns the share of the (non-missing) observations in each bin.
+func (b *Bins) Shares() []float64 {
+	n := 0
+	for _, c := range b.Counts {
+		n += c
+	}
+
+	shares := make([]float64, len(b.Counts))
+	for ind, c := range b.Counts {
+		shares[ind] = float64(c) / float64(n)
+	}
+
+	return shares
+}
+
+func (b *Bins) String() string {
+	str := fmt.Sprintf("Bins for %s\n", b.Field)
+	for ind, lbl := range b.Labels() {
+		str = fmt.Sprintf("%s%-30s%d\n", str, lbl, b.Counts[ind])
+	}
+
+	if b.Missing > 0 {
+		str = fmt.Sprintf("%s%-30s%d\n", str, "missing", b.Missing)
+	}
+
+	return str
+}
+
+// AppendBinned appends the FRCat field name to gd whose values are the labels (see Labels) of the bins of field.
+// The bins may come from a different GData (e.g. the training data). The levels of the new field are the bins, in
+// order, so every bin is a level whether it has observations or not.  Missing values are labeled "missing".
+func (gd *GData) AppendBinned(field, name string, b *Bins, keepRaw bool) error {
+	d := gd.Get(field)
+	if d == nil {
+		return Wrapper(ErrGData, fmt.Sprintf("AppendBinned: field %s not found", field))
+	}
+
+	if d.FT.Role != FRCts {
+		return Wrapper(ErrGData, fmt.Sprintf("AppendBinned: field %s is not FRCts", field))
+	}
+
+	labels := b.Labels()
+	lvl := make(Levels)
+
+	for ind, lbl := range labels {
+		lvl[lbl] = int32(ind)
+	}
+
+	x := UnNormalize(append([]float64{}, d.Floats()...), d.FT)
+	vals := make([]any, len(x))
+
+	for ind, xv := range x {
+		bin := b.Bin(xv)
+		if bin < 0 {
+			vals[ind] = "missing"
+			lvl["missing"] = int32(len(labels))
+
+			continue
+		}
+
+		vals[ind] = labels[bin]
+	}
+
+	return gd.AppendD(NewRaw(vals, nil), name, &FParam{Lvl: lvl}, keepRaw)
+}
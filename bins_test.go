@@ -0,0 +1,74 @@
+package seafan
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGDatum_Histogram(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, math.NaN()}, nil), "x", true,
+		&FParam{Location: 4, Scale: 2}, false))
+
+	b, e := gd.Get("x").Histogram(4)
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{0, 2, 4, 6, 8}, b.Edges)
+	assert.Equal(t, []int{2, 2, 2, 3}, b.Counts)
+	assert.Equal(t, 1, b.Missing)
+	assert.Equal(t, []string{"[0, 2)", "[2, 4)", "[4, 6)", "[6, 8]"}, b.Labels())
+	assert.InDelta(t, 1.0/3.0, b.Shares()[3], 1e-10)
+
+	assert.Equal(t, 0, b.Bin(-10))
+	assert.Equal(t, 3, b.Bin(10))
+	assert.Equal(t, -1, b.Bin(math.NaN()))
+
+	// a constant field has one bin
+	gdC := NewGData()
+	assert.Nil(t, gdC.AppendC(NewRawCast([]float64{2, 2, 2}, nil), "c", false, nil, false))
+	b, e = gdC.Get("c").Histogram(5)
+	assert.Nil(t, e)
+	assert.Equal(t, []int{3}, b.Counts)
+
+	_, e = gd.Get("x").Histogram(0)
+	assert.NotNil(t, e)
+}
+
+func TestGDatum_EqualFreqBins(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4, 5, 6, 7, 8}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 1, 1, 1, 1, 1, 2, 3}, nil), "ties", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "b", "a", "b", "a", "b"}, nil), "s", nil, false))
+
+	b, e := gd.Get("x").EqualFreqBins(4)
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{1, 3, 5, 7, 8}, b.Edges)
+	assert.Equal(t, []int{2, 2, 2, 2}, b.Counts)
+
+	// ties collapse bins
+	b, e = gd.Get("ties").EqualFreqBins(4)
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{1, 2, 3}, b.Edges)
+	assert.Equal(t, []int{6, 2}, b.Counts)
+
+	_, e = gd.Get("s").EqualFreqBins(4)
+	assert.NotNil(t, e)
+
+	// bins from x applied to new data
+	gdNew := NewGData()
+	assert.Nil(t, gdNew.AppendC(NewRawCast([]float64{0, 4, 100, math.NaN()}, nil), "x", false, nil, false))
+
+	b, e = gd.Get("x").EqualFreqBins(4)
+	assert.Nil(t, e)
+	assert.Nil(t, gdNew.AppendBinned("x", "xBin", b, true))
+
+	raw, e := gdNew.GetRaw("xBin")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"[1, 3)", "[3, 5)", "[7, 8]", "missing"}, raw.Data)
+	assert.Equal(t, 5, gdNew.GetFType("xBin").Cats)
+	assert.Equal(t, int32(2), gdNew.GetFType("xBin").FP.Lvl["[5, 7)"])
+
+	assert.NotNil(t, gd.AppendBinned("s", "sBin", b, false))
+	assert.NotNil(t, gd.AppendBinned("nope", "sBin", b, false))
+}
@@ -0,0 +1,307 @@
+package seafan
+
+// builder.go builds a ModSpec from typed arguments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ModSpecBuilder builds a ModSpec layer by layer. For example:
+//
+//	ms, e := NewModSpec().Input("x1", "x2", Embed("state", 3)).FC(64, Relu).DropOut(0.2).FC(2, SoftMax).Target("yoh").Build()
+//
+// produces
+//
+//	ModSpec{"Input(x1+x2+E(state,3))", "FC(size:64, activation:relu)", "DropOut(0.2)", "FC(size:2, activation:softmax)",
+//	"Target(yoh)"}
+//
+// The arguments of each layer are checked as it is added.  The first problem found is returned by Build.
+type ModSpecBuilder struct {
+	layers []string
+	err    error
+}
+
+// NewModSpec starts a ModSpecBuilder
+func NewModSpec() *ModSpecBuilder {
+	return &ModSpecBuilder{}
+}
+
+// Embed returns the Input feature that embeds the one-hot field in cols dimensions.
+func Embed(field string, cols int) string {
+	return fmt.Sprintf("E(%s,%d)", field, cols)
+}
+
+// add appends the layer unless there's already an error
+func (b *ModSpecBuilder) add(e error, layer string, a ...any) *ModSpecBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	if e != nil {
+		b.err = Wrapper(e, fmt.Sprintf("layer %d", len(b.layers)))
+		return b
+	}
+
+	b.layers = append(b.layers, fmt.Sprintf(layer, a...))
+
+	return b
+}
+
+// Input adds the Input layer.  features are fields, terms (e.g. x1*x2) or embeddings (see Embed).
+func (b *ModSpecBuilder) Input(features ...string) *ModSpecBuilder {
+	var e error
+
+	switch {
+	case len(b.layers) > 0:
+		e = Wrapper(ErrModSpec, "Input must be the first layer")
+	case len(features) == 0:
+		e = Wrapper(ErrModSpec, "Input: no features")
+	}
+
+	for _, feat := range features {
+		if isEmbed(feat) {
+			if _, _, _, err := parseEmbed(feat); err != nil {
+				e = err
+			}
+
+			continue
+		}
+
+		if err := checkName(feat, "*^"); err != nil {
+			e = err
+		}
+	}
+
+	return b.add(e, "Input(%s)", strings.Join(features, "+"))
+}
+
+// FC adds a fully connected layer with size outputs and activation act.  LeakyRelu takes its slope as parm.
+func (b *ModSpecBuilder) FC(size int, act Activation, parm ...float64) *ModSpecBuilder {
+	return b.fc(size, act, true, parm...)
+}
+
+// FCNoBias adds a fully connected layer without a bias term.
+func (b *ModSpecBuilder) FCNoBias(size int, act Activation, parm ...float64) *ModSpecBuilder {
+	return b.fc(size, act, false, parm...)
+}
+
+func (b *ModSpecBuilder) fc(size int, act Activation, bias bool, parm ...float64) *ModSpecBuilder {
+	if size < 1 {
+		return b.add(Wrapper(ErrModSpec, fmt.Sprintf("FC: illegal size %d", size)), "")
+	}
+
+	actStr, e := actString(act, parm...)
+	args := fmt.Sprintf("size:%d%s", size, actStr)
+
+	if !bias {
+		args += ", bias:false"
+	}
+
+	return b.add(e, "FC(%s)", args)
+}
+
+// DropOut adds a dropout layer with dropout probability p
+func (b *ModSpecBuilder) DropOut(p float64) *ModSpecBuilder {
+	var e error
+	if p <= 0.0 || p >= 1.0 {
+		e = Wrapper(ErrModSpec, fmt.Sprintf("DropOut: probability must be in (0, 1), got %v", p))
+	}
+
+	return b.add(e, "DropOut(%s)", strconv.FormatFloat(p, 'g', -1, 64))
+}
+
+// InputDropout adds dropout with probability p to the inputs of the FC layers
+func (b *ModSpecBuilder) InputDropout(p float64) *ModSpecBuilder {
+	var e error
+	if p <= 0.0 || p >= 1.0 {
+		e = Wrapper(ErrModSpec, fmt.Sprintf("InputDropout: probability must be in (0, 1), got %v", p))
+	}
+
+	return b.add(e, "InputDropout(%s)", strconv.FormatFloat(p, 'g', -1, 64))
+}
+
+// GaussianNoise adds normal noise with standard deviation sigma to the inputs of the FC layers
+func (b *ModSpecBuilder) GaussianNoise(sigma float64) *ModSpecBuilder {
+	var e error
+	if sigma <= 0.0 {
+		e = Wrapper(ErrModSpec, fmt.Sprintf("GaussianNoise: standard deviation must be positive, got %v", sigma))
+	}
+
+	return b.add(e, "GaussianNoise(%s)", strconv.FormatFloat(sigma, 'g', -1, 64))
+}
+
+// Target adds the Target layer.  The optional exposure is the exposure field of a hazard model.
+func (b *ModSpecBuilder) Target(target string, exposure ...string) *ModSpecBuilder {
+	e := checkName(target, "")
+
+	if len(exposure) > 1 {
+		e = Wrapper(ErrModSpec, "Target: at most one exposure field")
+	}
+
+	for _, exp := range exposure {
+		if err := checkName(exp, ""); err != nil {
+			e = err
+		}
+	}
+
+	return b.add(e, "Target(%s)", strings.Join(append([]string{target}, exposure...), ","))
+}
+
+// Output adds an output head of a multi-output model. See OutputLayer.  A zero Weight is taken as 1.  Since Bias is
+// false unless set, the head has no bias term unless out.Bias is true.
+func (b *ModSpecBuilder) Output(out OutputLayer) *ModSpecBuilder {
+	e := checkName(out.Target, "")
+
+	args := []string{"target:" + out.Target}
+
+	switch {
+	case out.Quantiles != nil:
+		qs := make([]string, len(out.Quantiles))
+		for ind, q := range out.Quantiles {
+			qs[ind] = strconv.FormatFloat(q, 'g', -1, 64)
+		}
+
+		args = append(args, fmt.Sprintf("quantiles:[%s]", strings.Join(qs, ",")))
+	case out.Size < 1:
+		e = Wrapper(ErrModSpec, fmt.Sprintf("Output: illegal size %d", out.Size))
+	default:
+		args = append(args, fmt.Sprintf("size:%d", out.Size))
+	}
+
+	actStr, err := actString(out.Act, out.ActParm)
+	if err != nil {
+		e = err
+	}
+
+	args[len(args)-1] += actStr
+
+	if !out.Bias {
+		args = append(args, "bias:false")
+	}
+
+	if out.Weight != 0.0 && out.Weight != 1.0 {
+		args = append(args, "weight:"+strconv.FormatFloat(out.Weight, 'g', -1, 64))
+	}
+
+	layer := fmt.Sprintf("Output(%s)", strings.Join(args, ", "))
+
+	if e == nil {
+		_, e = OutputParse(layer)
+	}
+
+	return b.add(e, "%s", layer)
+}
+
+// Offset adds an Offset layer with offset field
+func (b *ModSpecBuilder) Offset(field string) *ModSpecBuilder {
+	return b.add(checkName(field, ""), "Offset(%s)", field)
+}
+
+// GRU adds a GRU layer with hidden state of dimension size
+func (b *ModSpecBuilder) GRU(size int) *ModSpecBuilder {
+	return seqBuild(b, RNNParse, "GRU(%d)", size)
+}
+
+// LSTM adds an LSTM layer with hidden state of dimension size
+func (b *ModSpecBuilder) LSTM(size int) *ModSpecBuilder {
+	return seqBuild(b, RNNParse, "LSTM(%d)", size)
+}
+
+// Conv1D adds a 1-D convolution layer.  LeakyRelu takes its slope as parm.
+func (b *ModSpecBuilder) Conv1D(filters, kernel int, act Activation, parm ...float64) *ModSpecBuilder {
+	actStr, e := actString(act, parm...)
+	if e != nil {
+		return b.add(e, "")
+	}
+
+	return seqBuild(b, ConvParse, "Conv1D(filters:%d, kernel:%d%s)", filters, kernel, actStr)
+}
+
+// MaxPool adds a max pooling layer over windows of size steps
+func (b *ModSpecBuilder) MaxPool(size int) *ModSpecBuilder {
+	return seqBuild(b, PoolParse, "MaxPool(%d)", size)
+}
+
+// AvgPool adds an average pooling layer over windows of size steps
+func (b *ModSpecBuilder) AvgPool(size int) *ModSpecBuilder {
+	return seqBuild(b, PoolParse, "AvgPool(%d)", size)
+}
+
+// Attention adds a self-attention layer
+func (b *ModSpecBuilder) Attention(heads, dim int) *ModSpecBuilder {
+	return seqBuild(b, AttnParse, "Attention(heads:%d, dim:%d)", heads, dim)
+}
+
+// seqBuild adds a sequence layer, checking it with its parser
+func seqBuild[T any](b *ModSpecBuilder, parse func(string) (T, error), format string, a ...any) *ModSpecBuilder {
+	layer := fmt.Sprintf(format, a...)
+	_, e := parse(layer)
+
+	return b.add(e, "%s", layer)
+}
+
+// Build returns the ModSpec.  It checks that Input is the first layer and the last layer is Target, Output or Offset.
+func (b *ModSpecBuilder) Build() (ModSpec, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if len(b.layers) == 0 {
+		return nil, Wrapper(ErrModSpec, "Build: no layers")
+	}
+
+	ms := ModSpec(append([]string{}, b.layers...))
+
+	if !strings.HasPrefix(ms[0], "Input(") {
+		return nil, Wrapper(ErrModSpec, "Build: the first layer must be Input")
+	}
+
+	last := ms[len(ms)-1]
+	if !strings.HasPrefix(last, "Target(") && !strings.HasPrefix(last, "Output(") &&
+		!strings.HasPrefix(last, "Offset(") {
+		return nil, Wrapper(ErrModSpec, "Build: the last layer must be Target, Output or Offset")
+	}
+
+	if e := ms.Check(); e != nil {
+		return nil, e
+	}
+
+	return ms, nil
+}
+
+// actString returns the activation argument of a layer, e.g. ", activation:leakyrelu(0.1)".  It's empty for Linear.
+func actString(act Activation, parm ...float64) (string, error) {
+	if act < Linear || act > SoftMax {
+		return "", Wrapper(ErrModSpec, fmt.Sprintf("unknown activation %d", act))
+	}
+
+	if len(parm) > 1 {
+		return "", Wrapper(ErrModSpec, "at most one activation parameter")
+	}
+
+	switch {
+	case act == Linear:
+		return "", nil
+	case act == LeakyRelu && len(parm) == 1:
+		return fmt.Sprintf(", activation:leakyrelu(%s)", strconv.FormatFloat(parm[0], 'g', -1, 64)), nil
+	}
+
+	return ", activation:" + strings.ToLower(act.String()), nil
+}
+
+// checkName checks that name is a legal field name.  The characters in allow may also appear.
+func checkName(name, allow string) error {
+	if strings.TrimSpace(name) == "" {
+		return Wrapper(ErrModSpec, "empty field name")
+	}
+
+	for _, ch := range name {
+		if strings.ContainsRune(" ,+:()[]*^", ch) && !strings.ContainsRune(allow, ch) {
+			return Wrapper(ErrModSpec, fmt.Sprintf("illegal character %q in field name %s", ch, name))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModSpecBuilder(t *testing.T) {
+	Verbose = false
+
+	ms, e := NewModSpec().Input("x1", "x2", "x1*x2", Embed("y1oh", 2)).FC(4, LeakyRelu, 0.1).DropOut(0.2).
+		FCNoBias(2, SoftMax).Target("yoh").Build()
+	assert.Nil(t, e)
+
+	exp := ModSpec{"Input(x1+x2+x1*x2+E(y1oh,2))", "FC(size:4, activation:leakyrelu(0.1))", "DropOut(0.2)",
+		"FC(size:2, activation:softmax, bias:false)", "Target(yoh)"}
+	assert.Equal(t, exp, ms)
+
+	pipe := chPipe(100, "test1.csv")
+	assert.Nil(t, ms.Validate(pipe))
+
+	_, e = NewNNModel(ms, pipe, true)
+	assert.Nil(t, e)
+
+	fc := ms.FC(3)
+	assert.Equal(t, FCLayer{Size: 2, Act: SoftMax, Bias: false}, *fc)
+
+	ms, e = NewModSpec().Input("x1").FC(3, Relu).
+		Output(OutputLayer{Target: "ycts", FCLayer: FCLayer{Size: 1, Bias: true}, Weight: 2}).
+		Output(OutputLayer{Target: "ycts", Quantiles: []float64{0.1, 0.9}}).Build()
+	assert.Nil(t, e)
+	assert.Equal(t, ModSpec{"Input(x1)", "FC(size:3, activation:relu)", "Output(target:ycts, size:1, weight:2)",
+		"Output(target:ycts, quantiles:[0.1,0.9], bias:false)"}, ms)
+
+	ms, e = NewModSpec().Input("x").GRU(4).Conv1D(2, 3, Relu).MaxPool(2).Attention(1, 2).FC(1, Linear).
+		Target("y").Build()
+	assert.Nil(t, e)
+	assert.Equal(t, ModSpec{"Input(x)", "GRU(4)", "Conv1D(filters:2, kernel:3, activation:relu)", "MaxPool(2)",
+		"Attention(heads:1, dim:2)", "FC(size:1)", "Target(y)"}, ms)
+
+	bad := []*ModSpecBuilder{
+		NewModSpec().Input(),
+		NewModSpec().Input("x1,x2").FC(1, Linear).Target("y"),
+		NewModSpec().Input("x1").FC(0, Linear).Target("y"),
+		NewModSpec().Input("x1").FC(1, Activation(9)).Target("y"),
+		NewModSpec().Input("x1").DropOut(1).FC(1, Linear).Target("y"),
+		NewModSpec().Input("x1").FC(1, Linear),
+		NewModSpec().FC(1, Linear).Target("y"),
+		NewModSpec().Input("x1").FC(1, Linear).Target("y", "e1", "e2"),
+		NewModSpec().Input("x1").Output(OutputLayer{Target: "y"}),
+		NewModSpec().Input("x1").GRU(0).Target("y"),
+		NewModSpec().Input("x1").Input("x2").Target("y"),
+	}
+
+	for ind, b := range bad {
+		_, e := b.Build()
+		assert.ErrorIs(t, e, ErrModSpec, ind)
+	}
+
+	_, e = NewModSpec().Input("x1").FC(1, Linear).Build()
+	assert.Contains(t, e.Error(), "Target, Output or Offset")
+}
@@ -5,11 +5,13 @@ package seafan
 import (
 	"fmt"
 	"io"
+	"math/rand"
 	"reflect"
+	"sort"
 
 	"github.com/invertedv/chutils"
+	"github.com/invertedv/utilities"
 	G "gorgonia.org/gorgonia"
-	"gorgonia.org/tensor"
 )
 
 // ChData provides a Pipeline interface into text files (delimited, fixed length) and ClickHouse.
@@ -24,8 +26,48 @@ type ChData struct {
 	epochCount int           // current epoch
 	ftypes     FTypes        // user input selections
 	keepRaw    bool
-	callback   Opts   // user callbacks executed at the start of Init()
-	name       string // pipeline name
+	callback   Opts          // user callbacks executed at the start of Init()
+	name       string        // pipeline name
+	rnd        *rand.Rand    // source for Shuffle (package source if nil)
+	tolerant   bool          // if true, Init skips fields that fail to load
+	report     LoadReport    // fields that failed to load on the last Init
+	buffers    batchBuffers  // tensors Batch copies into, nil if batches use the data directly
+	derived    DerivedFields // fields calculated on each Init
+}
+
+// LoadReport records the fields that failed to load and the reason. See WithTolerant.
+type LoadReport map[string]error
+
+// String lists the failed fields
+func (lr LoadReport) String() string {
+	if len(lr) == 0 {
+		return "all fields loaded"
+	}
+
+	flds := make([]string, 0, len(lr))
+	for fld := range lr {
+		flds = append(flds, fld)
+	}
+
+	sort.Strings(flds)
+
+	str := fmt.Sprintf("%d fields failed to load\n", len(lr))
+	for _, fld := range flds {
+		str = fmt.Sprintf("%s%s: %v\n", str, fld, lr[fld])
+	}
+
+	return str
+}
+
+// Fatal returns an error if any of fields failed to load.
+func (lr LoadReport) Fatal(fields ...string) error {
+	for _, fld := range fields {
+		if e, ok := lr[fld]; ok {
+			return Wrapper(ErrChData, fmt.Sprintf("field %s failed to load: %v", fld, e))
+		}
+	}
+
+	return nil
 }
 
 func NewChData(name string, opts ...Opts) *ChData {
@@ -92,6 +134,12 @@ func (ch *ChData) IsCat(field string) bool {
 	return false
 }
 
+// LoadReport returns the fields that failed to load on the last call to Init. Fields fail to load only if the
+// pipeline is tolerant (see WithTolerant).
+func (ch *ChData) LoadReport() LoadReport {
+	return ch.report
+}
+
 // GData returns the Pipelines' GData
 func (ch *ChData) GData() *GData {
 	d := ch.data
@@ -100,22 +148,25 @@ func (ch *ChData) GData() *GData {
 }
 
 // Init initializes the Pipeline.
+//
+// ClickHouse Array(T) fields are expanded into one field per element: an array field x of up to 3 elements
+// becomes the fields x_0, x_1 and x_2.  Shorter arrays are padded with the missing value of T.
+// Nulls in Nullable(T) fields are replaced by the missing value of T (chutils.IntMissing, chutils.FloatMissing,
+// chutils.StringMissing, chutils.DateMissing) unless the reader's FieldDef has a Default or Missing value.
 func (ch *ChData) Init() (err error) {
 	if ch.rdr == nil {
 		return Wrapper(ErrChData, "no reader")
 	}
 
 	ch.pull = false
-	fds := ch.rdr.TableSpec().FieldDefs
-	names := make([]string, len(fds))           // field names
-	trans := make([]*Raw, len(fds))             // data
-	chTypes := make([]chutils.ChType, len(fds)) // field types
 
-	for ind := 0; ind < len(fds); ind++ {
-		names[ind] = fds[ind].Name
-		chTypes[ind] = fds[ind].ChSpec.Base
+	td := ch.rdr.TableSpec()
+	if td == nil {
+		return Wrapper(ErrChData, "reader has no TableSpec")
 	}
 
+	fds := td.FieldDefs
+
 	rAll, _, ex := ch.rdr.Read(0, true)
 	if ex != nil && ex != io.EOF {
 		return ex
@@ -130,28 +181,29 @@ func (ch *ChData) Init() (err error) {
 		return Wrapper(ErrChData, fmt.Sprintf("Init: batch size = %d > dataset rows = %d", ch.bs, ch.nRow))
 	}
 
-	// load GData
-	anyData := false
-	for rw := 0; rw < ch.nRow; rw++ {
+	if ch.nRow == 0 {
+		return fmt.Errorf("ch.Init failed...query EOF with no data")
+	}
 
-		// now we have the types, we can allocate the slices
-		if rw == 0 {
-			for c := 0; c < len(rAll[rw]); c++ {
-				trans[c] = AllocRaw(ch.nRow, reflect.TypeOf(rAll[rw][c]).Kind())
-			}
-		}
+	// load columns. Array fields expand to several columns.
+	var (
+		names   []string         // field names
+		trans   []*Raw           // data
+		chTypes []chutils.ChType // field types
+	)
 
-		anyData = true
-		for c := 0; c < len(trans); c++ {
-			trans[c].Data[rw] = rAll[rw][c]
-		}
-	}
+	for ind := 0; ind < len(fds); ind++ {
+		nms, cols := chColumns(rAll, ind, fds[ind])
+		names = append(names, nms...)
+		trans = append(trans, cols...)
 
-	if !anyData {
-		return fmt.Errorf("ch.Init failed...query EOF with no data")
+		for range nms {
+			chTypes = append(chTypes, fds[ind].ChSpec.Base)
+		}
 	}
 
 	gd := NewGData()
+	ch.report = make(LoadReport)
 
 	// work through fields, add to GData
 	for ind, nm := range names {
@@ -170,26 +222,54 @@ func (ch *ChData) Init() (err error) {
 
 		switch ft.Role {
 		case FRCts:
-			if err = gd.AppendC(trans[ind], nm, ft.Normalized, ft.FP, ch.keepRaw); err != nil {
-				return Wrapper(err, "(*ChData).Init")
+			err = gd.AppendC(trans[ind], nm, ft.Normalized, ft.FP, ch.keepRaw)
+			if err == nil && ft.Float32 {
+				err = gd.ToFloat32(nm)
 			}
+		case FRDate:
+			err = gd.AppendDate(trans[ind], nm, ch.keepRaw)
+		case FROrdinal:
+			err = gd.appendOrdinal(trans[ind], nm, ft.FP, ch.keepRaw)
 		default:
-			if err = gd.AppendD(trans[ind], names[ind], ft.FP, ch.keepRaw); err != nil {
+			err = gd.AppendD(trans[ind], names[ind], ft.FP, ch.keepRaw)
+		}
+
+		if err != nil {
+			if !ch.tolerant {
 				return Wrapper(err, "(*ChData).Init")
 			}
+
+			ch.report[nm] = err
+		}
+	}
+
+	if gd.FieldCount() == 0 {
+		return Wrapper(ErrChData, "(*ChData).Init: no fields loaded")
+	}
+
+	if ch.derived != nil {
+		if e := ch.derived.Apply(gd, ch.ftypes, ch.keepRaw); e != nil {
+			return Wrapper(e, "(*ChData).Init")
 		}
 	}
+
 	// Add calculated fields
 	for _, ft := range ch.ftypes {
-		switch ft.Role {
-		case FROneHot:
-			if err = gd.MakeOneHot(ft.From, ft.Name); err != nil {
-				return Wrapper(err, "(*ChData).Init")
-			}
-		case FREmbed:
-			if err = gd.MakeOneHot(ft.From, ft.Name); err != nil {
+		if ft.Role != FROneHot && ft.Role != FREmbed {
+			continue
+		}
+
+		if e, ok := ch.report[ft.From]; ok && ch.tolerant {
+			ch.report[ft.Name] = fmt.Errorf("from field %s failed to load: %v", ft.From, e)
+			continue
+		}
+
+		if err = gd.MakeOneHot(ft.From, ft.Name); err != nil {
+			if !ch.tolerant {
 				return Wrapper(err, "(*ChData).Init")
 			}
+
+			ch.report[ft.Name] = err
 		}
 	}
 
@@ -202,6 +282,95 @@ func (ch *ChData) Init() (err error) {
 	return nil
 }
 
+// chColumns returns the field names and data of column c of rows described by fd. Array columns return
+// one field per element.
+func chColumns(rows []chutils.Row, c int, fd *chutils.FieldDef) (names []string, cols []*Raw) {
+	if !fd.ChSpec.Funcs.Has(chutils.OuterArray) {
+		vals := make([]any, len(rows))
+		for rw := 0; rw < len(rows); rw++ {
+			vals[rw] = rows[rw][c]
+		}
+
+		return []string{fd.Name}, []*Raw{chRaw(vals, fd.ChSpec.Base)}
+	}
+
+	// elements of each row's array
+	elems := make([][]any, len(rows))
+	maxLen := 0
+
+	for rw := 0; rw < len(rows); rw++ {
+		if rows[rw][c] == nil {
+			continue
+		}
+
+		arr := reflect.ValueOf(rows[rw][c])
+		if arr.Kind() != reflect.Slice {
+			elems[rw] = []any{rows[rw][c]}
+		} else {
+			for ind := 0; ind < arr.Len(); ind++ {
+				elems[rw] = append(elems[rw], arr.Index(ind).Interface())
+			}
+		}
+
+		maxLen = utilities.MaxInt(maxLen, len(elems[rw]))
+	}
+
+	for ind := 0; ind < maxLen; ind++ {
+		vals := make([]any, len(rows))
+		for rw := 0; rw < len(rows); rw++ {
+			if ind < len(elems[rw]) {
+				vals[rw] = elems[rw][ind]
+			}
+		}
+
+		names = append(names, fmt.Sprintf("%s_%d", fd.Name, ind))
+		cols = append(cols, chRaw(vals, fd.ChSpec.Base))
+	}
+
+	return names, cols
+}
+
+// chRaw creates a *Raw from vals, replacing nils with the missing value of base.
+func chRaw(vals []any, base chutils.ChType) *Raw {
+	var typ reflect.Type
+	for _, v := range vals {
+		if v != nil {
+			typ = reflect.TypeOf(v)
+			break
+		}
+	}
+
+	var miss any
+	switch base {
+	case chutils.ChString, chutils.ChFixedString:
+		miss = chutils.StringMissing
+	case chutils.ChDate:
+		miss = chutils.DateMissing
+	case chutils.ChInt:
+		miss = int32(chutils.IntMissing)
+	default:
+		miss = chutils.FloatMissing
+	}
+
+	if typ == nil {
+		typ = reflect.TypeOf(miss)
+	}
+
+	if mv := reflect.ValueOf(miss); mv.CanConvert(typ) {
+		miss = mv.Convert(typ).Interface()
+	}
+
+	raw := AllocRaw(len(vals), typ.Kind())
+	for ind, v := range vals {
+		raw.Data[ind] = v
+		if v == nil {
+			raw.Data[ind] = miss
+		}
+	}
+
+	return raw
+}
+
 // Init initializes the Pipeline.
 func (ch *ChData) InitOld() (err error) {
 	if ch.rdr == nil {
@@ -223,7 +392,13 @@ func (ch *ChData) InitOld() (err error) {
 	}
 
 	ch.pull = false
-	fds := ch.rdr.TableSpec().FieldDefs
+
+	td := ch.rdr.TableSpec()
+	if td == nil {
+		return Wrapper(ErrChData, "reader has no TableSpec")
+	}
+
+	fds := td.FieldDefs
 	names := make([]string, len(fds))           // field names
 	trans := make([]*Raw, len(fds))             // data
 	chTypes := make([]chutils.ChType, len(fds)) // field types
@@ -288,6 +463,20 @@ func (ch *ChData) InitOld() (err error) {
 			if err = gd.AppendC(trans[ind], nm, ft.Normalized, ft.FP, ch.keepRaw); err != nil {
 				return Wrapper(err, "(*ChData).Init")
 			}
+
+			if ft.Float32 {
+				if err = gd.ToFloat32(nm); err != nil {
+					return Wrapper(err, "(*ChData).Init")
+				}
+			}
+		case FRDate:
+			if err = gd.AppendDate(trans[ind], nm, ch.keepRaw); err != nil {
+				return Wrapper(err, "(*ChData).Init")
+			}
+		case FROrdinal:
+			if err = gd.appendOrdinal(trans[ind], nm, ft.FP, ch.keepRaw); err != nil {
+				return Wrapper(err, "(*ChData).Init")
+			}
 		default:
 			if err = gd.AppendD(trans[ind], names[ind], ft.FP, ch.keepRaw); err != nil {
 				return Wrapper(err, "(*ChData).Init")
@@ -342,6 +531,13 @@ func (ch *ChData) Batch(inputs G.Nodes) bool {
 		// user callbacks
 		if ch.callback != nil {
 			ch.callback(ch)
+
+			// the callback may have changed the data the derived fields use
+			if ch.derived != nil && !ch.pull {
+				if e := ch.derived.Apply(ch.data, ch.ftypes, ch.keepRaw); e != nil {
+					panic(e)
+				}
+			}
 		}
 
 		return false
@@ -351,26 +547,13 @@ func (ch *ChData) Batch(inputs G.Nodes) bool {
 	endRow := startRow + ch.bs
 
 	for _, nd := range inputs {
-		var t tensor.Tensor
-
 		d := ch.data.Get(nd.Name())
 
 		if d == nil {
 			panic(Wrapper(ErrChData, fmt.Sprintf("feature %s not in dataset", nd.Name())))
 		}
 
-		switch d.FT.Role {
-		case FRCts:
-			t = tensor.New(tensor.WithBacking(d.Data.([]float64)[startRow:endRow]), tensor.WithShape(ch.bs, 1))
-		case FRCat:
-			t = tensor.New(tensor.WithBacking(d.Data.([]int32)[startRow:endRow]), tensor.WithShape(ch.bs, 1))
-		case FROneHot, FREmbed:
-			sr := startRow * d.FT.Cats
-			er := endRow * d.FT.Cats
-			t = tensor.New(tensor.WithBacking(d.Data.([]float64)[sr:er]), tensor.WithShape(ch.bs, d.FT.Cats))
-		}
-
-		if e := G.Let(nd, t); e != nil {
+		if e := letBatch(nd, d, startRow, endRow, ch.buffers); e != nil {
 			panic(e)
 		}
 	}
@@ -396,9 +579,9 @@ func (ch *ChData) Cols(field string) int {
 	switch d.FT.Role {
 	case FRCts:
 		return 1
-	case FRCat:
+	case FRCat, FRDate, FROrdinal:
 		return 1
-	case FROneHot, FREmbed:
+	case FROneHot, FREmbed, FRSeq:
 		return d.FT.Cats
 	}
 
@@ -483,7 +666,7 @@ func (ch *ChData) Slice(sl Slicer) (Pipeline, error) {
 
 // Shuffle shuffles the data
 func (ch *ChData) Shuffle() {
-	ch.data.Shuffle()
+	ch.data.shuffle(ch.rnd)
 }
 
 // Sort sorts the data
@@ -547,11 +730,74 @@ func (ch *ChData) Where(field string, equalTo []any) (newPipe Pipeline, err erro
 	return newPipe, nil
 }
 
+// WhereExpr creates a new pipeline with rows where the expression expr evaluates to a positive value.
+func (ch *ChData) WhereExpr(expr string) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = ch.GData().WhereExpr(expr); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(ch.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
 // FieldCount returns the number of fields in the pipeline
 func (ch *ChData) FieldCount() int {
 	return ch.data.FieldCount()
 }
 
+// SampleN creates a new pipeline with a simple random sample of n rows. See GData.SampleN.
+func (ch *ChData) SampleN(n int, seed int64) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = ch.GData().SampleN(n, seed); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(ch.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// SampleFrac creates a new pipeline with a simple random sample of the fraction f of the rows.
+func (ch *ChData) SampleFrac(f float64, seed int64) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = ch.GData().SampleFrac(f, seed); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(ch.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// StratifiedSample creates a new pipeline with a random sample of perLevel rows for each level of field. The sample is
+// reproducible for a given seed.
+func (ch *ChData) StratifiedSample(field string, perLevel int, seed int64) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = ch.GData().StratifiedSample(field, perLevel, seed); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(ch.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// Compact rebuilds the data of the pipeline to use only the memory it needs. The *Raw data is dropped unless
+// the pipeline keeps it. Returns an estimate of the bytes reclaimed.
+func (ch *ChData) Compact() int {
+	return ch.GData().Compact(ch.keepRaw)
+}
+
 // Keep keeps only the listed fields in the pipeline
 func (ch *ChData) Keep(fields []string) error {
 	return ch.GData().Keep(fields)
@@ -563,8 +809,8 @@ func (ch *ChData) Drop(field string) error {
 }
 
 // AppendRows appends rows to the existing GData and then re-initializes each GDatum, using the fTypes, if provided.
-func (ch *ChData) AppendRows(gd *GData, fTypes FTypes) (pipeOut Pipeline, err error) {
-	gdOut, e := ch.GData().AppendRows(gd, fTypes)
+func (ch *ChData) AppendRows(gd *GData, fTypes FTypes, opts ...AppendOpts) (pipeOut Pipeline, err error) {
+	gdOut, e := ch.GData().AppendRows(gd, fTypes, opts...)
 	if e != nil {
 		return nil, e
 	}
@@ -2,6 +2,7 @@ package seafan
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"testing"
@@ -123,6 +124,84 @@ func TestChData_Init(t *testing.T) {
 	assert.InEpsilon(t, m-42.0, ch.Get("x1").Summary.DistrC.Mean, 0.0001)
 }
 
+// rowReader is a chutils.Input serving fixed rows, as ClickHouse returns them
+type rowReader struct {
+	rows []chutils.Row
+	td   *chutils.TableDef
+}
+
+func (r *rowReader) Read(nTarget int, validate bool) (data []chutils.Row, valid []chutils.Valid, err error) {
+	return r.rows, nil, io.EOF
+}
+
+func (r *rowReader) Reset() error                 { return nil }
+func (r *rowReader) CountLines() (int, error)     { return len(r.rows), nil }
+func (r *rowReader) Seek(lineNo int) error        { return nil }
+func (r *rowReader) Close() error                 { return nil }
+func (r *rowReader) TableSpec() *chutils.TableDef { return r.td }
+
+func TestChData_InitArrays(t *testing.T) {
+	fds := map[int]*chutils.FieldDef{
+		0: {Name: "arr", ChSpec: chutils.ChField{Base: chutils.ChFloat, Length: 64, Funcs: chutils.OuterFuncs{chutils.OuterArray}}},
+		1: {Name: "s", ChSpec: chutils.ChField{Base: chutils.ChString, Funcs: chutils.OuterFuncs{chutils.OuterNullable}}},
+		2: {Name: "i", ChSpec: chutils.ChField{Base: chutils.ChInt, Length: 32, Funcs: chutils.OuterFuncs{chutils.OuterNullable}}},
+	}
+
+	rows := []chutils.Row{
+		{[]float64{1, 2}, "a", nil},
+		{[]float64{3}, nil, int32(4)},
+		{nil, "b", int32(5)},
+	}
+
+	rdr := &rowReader{rows: rows, td: chutils.NewTableDef("arr", chutils.MergeTree, fds)}
+	ch := NewChData("arrays", WithReader(rdr), WithBatchSize(0), WithKeepRaw(true))
+	assert.Nil(t, ch.Init())
+
+	assert.ElementsMatch(t, []string{"arr_0", "arr_1", "s", "i"}, ch.GData().FieldList())
+	assert.Equal(t, []float64{1, 3, chutils.FloatMissing}, ch.Get("arr_0").Data)
+	assert.Equal(t, []float64{2, chutils.FloatMissing, chutils.FloatMissing}, ch.Get("arr_1").Data)
+
+	assert.Equal(t, FRCat, ch.GetFType("s").Role)
+	raw, e := ch.GData().GetRaw("s")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"a", chutils.StringMissing, "b"}, raw.Data)
+
+	assert.Equal(t, FRCts, ch.GetFType("i").Role)
+	assert.Equal(t, []float64{float64(chutils.IntMissing), 4, 5}, ch.Get("i").Data)
+}
+
+func TestWithTolerant(t *testing.T) {
+	fileName := os.TempDir() + "/tolerant.csv"
+	assert.Nil(t, os.WriteFile(fileName, []byte("a,c,s\n1,1,x\n2,1,y\n3,1,x\n"), 0644))
+	defer func() { _ = os.Remove(fileName) }()
+
+	// newPipe returns a pipeline in which c cannot be normalized and s has a level not in its FParam
+	newPipe := func(tolerant bool) *ChData {
+		f, e := os.Open(fileName)
+		assert.Nil(t, e)
+
+		rdr := file.NewReader(fileName, ',', '\n', 0, 0, 1, 0, f, 0)
+		assert.Nil(t, rdr.Init("", chutils.MergeTree))
+		assert.Nil(t, rdr.TableSpec().Impute(rdr, 0, .99))
+
+		fts := FTypes{&FType{Name: "s", Role: FRCat, FP: &FParam{Lvl: Levels{"x": 0}, Default: "zz"}}}
+
+		return NewChData("tolerant", WithBatchSize(3), WithReader(rdr), WithFtypes(fts),
+			WithNormalized("c"), WithOneHot("sOh", "s"), WithTolerant(tolerant))
+	}
+
+	assert.NotNil(t, newPipe(false).Init())
+
+	ch := newPipe(true)
+	assert.Nil(t, ch.Init())
+	assert.Equal(t, []string{"a"}, ch.FieldList())
+
+	report := ch.LoadReport()
+	assert.Equal(t, 3, len(report))
+	assert.Nil(t, report.Fatal("a"))
+	assert.NotNil(t, report.Fatal("a", "sOh"))
+}
+
 func TestChData_Batch(t *testing.T) {
 	dataPath := os.Getenv("data")
 	fileName := dataPath + "/test1.csv"
@@ -0,0 +1,228 @@
+package seafan
+
+// checkpoint.go implements the Adam solver used by Fit and saving/restoring the state of a fit so it can be resumed
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	G "gorgonia.org/gorgonia"
+)
+
+const (
+	// fitStateVersion is the version of the fit state file format written by checkpoints
+	fitStateVersion = 1
+
+	adamBeta1 = 0.9
+	adamBeta2 = 0.999
+	adamEps   = 1e-8
+)
+
+// adam is the Adam solver.  It follows the gorgonia AdamSolver, but its state can be saved.
+type adam struct {
+	Eta  float64              `json:"eta"`  // learning rate
+	Iter int                  `json:"iter"` // number of steps taken
+	M    map[string][]float64 `json:"m"`    // means of the gradients by parameter name
+	V    map[string][]float64 `json:"v"`    // variances of the gradients by parameter name
+}
+
+// newAdam returns an Adam solver with learning rate eta
+func newAdam(eta float64) *adam {
+	return &adam{Eta: eta, M: make(map[string][]float64), V: make(map[string][]float64)}
+}
+
+// step updates params using their gradients and zeros the gradients
+func (a *adam) step(params G.Nodes) error {
+	a.Iter++
+	c1 := 1.0 - math.Pow(adamBeta1, float64(a.Iter))
+	c2 := 1.0 - math.Pow(adamBeta2, float64(a.Iter))
+
+	for _, n := range params {
+		gv, e := n.Grad()
+		if e != nil {
+			return e
+		}
+
+		g, ok1 := gv.Data().([]float64)
+		w, ok2 := n.Value().Data().([]float64)
+
+		if !ok1 || !ok2 {
+			return Wrapper(ErrNNModel, fmt.Sprintf("parameter %s is not float64", n.Name()))
+		}
+
+		m, v := a.M[n.Name()], a.V[n.Name()]
+		if m == nil {
+			m, v = make([]float64, len(w)), make([]float64, len(w))
+			a.M[n.Name()], a.V[n.Name()] = m, v
+		}
+
+		if len(m) != len(w) {
+			return Wrapper(ErrNNModel, fmt.Sprintf("solver state of %s does not match the parameter", n.Name()))
+		}
+
+		for ind, gr := range g {
+			m[ind] = adamBeta1*m[ind] + (1.0-adamBeta1)*gr
+			v[ind] = adamBeta2*v[ind] + (1.0-adamBeta2)*gr*gr
+			w[ind] -= a.Eta * (m[ind] / c1) / (math.Sqrt(v[ind]/c2) + adamEps)
+			g[ind] = 0.0
+		}
+	}
+
+	return nil
+}
+
+// nanFloats is a []float64 that saves NaN values as null in json
+type nanFloats []float64
+
+func (x nanFloats) MarshalJSON() ([]byte, error) {
+	out := make([]*float64, len(x))
+
+	for ind := range x {
+		if !math.IsNaN(x[ind]) {
+			out[ind] = &x[ind]
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+func (x *nanFloats) UnmarshalJSON(js []byte) error {
+	in := make([]*float64, 0)
+	if e := json.Unmarshal(js, &in); e != nil {
+		return e
+	}
+
+	*x = make(nanFloats, len(in))
+
+	for ind, v := range in {
+		(*x)[ind] = math.NaN()
+		if v != nil {
+			(*x)[ind] = *v
+		}
+	}
+
+	return nil
+}
+
+// savedHistory is a FitHistory in a form that can be saved as json
+type savedHistory struct {
+	Epoch     []int     `json:"epoch"`
+	InCost    nanFloats `json:"inCost"`
+	ValCost   nanFloats `json:"valCost"`
+	LearnRate nanFloats `json:"learnRate"`
+	GradNorm  nanFloats `json:"gradNorm"`
+	ParamNorm nanFloats `json:"paramNorm"`
+	Seconds   nanFloats `json:"seconds"`
+	Best      []bool    `json:"best"`
+}
+
+// fitState is the state of a fit at the end of an epoch, saved in <fileRoot>O.nn by WithCheckpoint.
+type fitState struct {
+	Version   int           `json:"version"`
+	Epoch     int           `json:"epoch"`     // last epoch completed
+	BestEpoch int           `json:"bestEpoch"` // epoch of the best cost
+	BestCost  float64       `json:"bestCost"`  // best cost
+	Wait      int           `json:"wait"`      // epochs since the best epoch, for early stopping
+	OutFile   string        `json:"outFile"`   // file root of the best model
+	Solver    *adam         `json:"solver"`
+	History   *savedHistory `json:"history"`
+}
+
+// WithCheckpoint saves the state of the fit at the end of each epoch so that it can be resumed with WithResume.  The
+// model weights at the end of the epoch are saved as by NNModel Save in <fileRoot>S.nn and <fileRoot>P.nn, so they
+// can be loaded by LoadNN.  The solver state (the Adam moments), the epoch, the best epoch, the number of epochs since
+// the best epoch (for early stopping) and the history are saved in <fileRoot>O.nn.
+func WithCheckpoint(fileRoot string) FitOpts {
+	f := func(ft *Fit) {
+		ft.checkpoint = fileRoot
+	}
+
+	return f
+}
+
+// WithResume resumes a fit from the checkpoint saved in fileRoot by WithCheckpoint.  The NNModel passed to NewFit
+// should be loaded from the checkpoint:
+//
+//	nn, e := LoadNN(fileRoot, pipe, true)
+//	ft := NewFit(nn, epochs, pipe, WithResume(fileRoot), ...)
+//
+// The fit continues with the epoch after the checkpoint and the best model so far is kept in the out file of the
+// original fit, unless WithOutFile is given. The resumed fit matches an uninterrupted fit if the options (including
+// epochs) are the same, the Pipeline is at the start of an epoch and there are no DropOut, InputDropout or
+// GaussianNoise layers.
+func WithResume(fileRoot string) FitOpts {
+	f := func(ft *Fit) {
+		ft.resume = fileRoot
+	}
+
+	return f
+}
+
+// saveCheckpoint saves the model and the state of the fit after epoch ep
+func (ft *Fit) saveCheckpoint(ep int, best float64, solv *adam) error {
+	if e := ft.nn.Save(ft.checkpoint); e != nil {
+		return e
+	}
+
+	h := ft.history
+	state := &fitState{
+		Version:   fitStateVersion,
+		Epoch:     ep,
+		BestEpoch: ft.bestEpoch,
+		BestCost:  best,
+		Wait:      ft.sinceBest,
+		OutFile:   ft.outFile,
+		Solver:    solv,
+		History: &savedHistory{Epoch: h.Epoch, InCost: h.InCost, ValCost: h.ValCost, LearnRate: h.LearnRate,
+			GradNorm: h.GradNorm, ParamNorm: h.ParamNorm, Seconds: h.Seconds, Best: h.Best},
+	}
+
+	js, e := json.MarshalIndent(state, "", "  ")
+	if e != nil {
+		return e
+	}
+
+	return os.WriteFile(ft.checkpoint+"O.nn", js, 0644)
+}
+
+// loadFitState loads the state saved by a checkpoint in fileRoot
+func loadFitState(fileRoot string) (*fitState, error) {
+	js, e := os.ReadFile(fileRoot + "O.nn")
+	if e != nil {
+		return nil, e
+	}
+
+	state := &fitState{}
+	if e := json.Unmarshal(js, state); e != nil {
+		return nil, e
+	}
+
+	if state.Version != fitStateVersion {
+		return nil, Wrapper(ErrNNModel, fmt.Sprintf("fit state version %d is not supported", state.Version))
+	}
+
+	if state.Solver == nil || state.History == nil || len(state.History.Epoch) != state.Epoch {
+		return nil, Wrapper(ErrNNModel, "bad fit state file")
+	}
+
+	h := state.History
+	for _, col := range []nanFloats{h.InCost, h.ValCost, h.LearnRate, h.GradNorm, h.ParamNorm, h.Seconds} {
+		if len(col) != state.Epoch {
+			return nil, Wrapper(ErrNNModel, "bad fit state file")
+		}
+	}
+
+	if len(h.Best) != state.Epoch {
+		return nil, Wrapper(ErrNNModel, "bad fit state file")
+	}
+
+	return state, nil
+}
+
+// history returns the saved history as a FitHistory
+func (h *savedHistory) history() *FitHistory {
+	return &FitHistory{Epoch: h.Epoch, InCost: h.InCost, ValCost: h.ValCost, LearnRate: h.LearnRate,
+		GradNorm: h.GradNorm, ParamNorm: h.ParamNorm, Seconds: h.Seconds, Best: h.Best}
+}
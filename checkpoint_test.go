@@ -0,0 +1,108 @@
+package seafan
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithResume(t *testing.T) {
+	Verbose = false
+
+	gd := synthData(t, 23, 1000, []string{"x1", "x2"}, []string{"y"}, func(x []float64, rnd *rand.Rand) []any {
+		return []any{x[0]*x[1] + 0.1*rnd.NormFloat64()}
+	})
+
+	mod := ModSpec{
+		"Input(x1+x2)",
+		"FC(size:4, activation:relu)",
+		"FC(size:1)",
+		"Target(y)",
+	}
+
+	root := os.TempDir() + "/resumeTest"
+	defer func() {
+		for _, suffix := range []string{"P.nn", "S.nn", "O.nn"} {
+			_ = os.Remove(root + suffix)
+		}
+	}()
+
+	// uninterrupted fit
+	SetSeed(23)
+	nn, e := NewNNModel(mod, NewVecData("resume", gd, WithBatchSize(100)), true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	pipe := NewVecData("resume", gd, WithBatchSize(100))
+	full := fitModel(t, nn, 6, pipe, WithFitSeed(23))
+
+	// fit 3 epochs, then resume from the checkpoint for the remaining 3
+	SetSeed(23)
+	nn, e = NewNNModel(mod, NewVecData("resume", gd, WithBatchSize(100)), true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	pipe = NewVecData("resume", gd, WithBatchSize(100))
+	first := fitModel(t, nn, 3, pipe, WithFitSeed(24), WithCheckpoint(root))
+
+	state, e := loadFitState(root)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, state.Epoch)
+	assert.Equal(t, 30, state.Solver.Iter)
+
+	pipe = NewVecData("resume", gd, WithBatchSize(100))
+	nn, e = LoadNN(root, pipe, true)
+	assert.Nil(t, e)
+	WithCostFn(RMS)(nn)
+
+	second := NewFit(nn, 6, pipe, WithFitSeed(25), WithResume(root))
+	assert.Nil(t, second.Do())
+
+	// the resumed fit continues the history and matches the uninterrupted fit
+	assert.Equal(t, first.OutFile(), second.OutFile())
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, second.History().Epoch)
+	assert.InDeltaSlice(t, full.History().InCost, second.History().InCost, 1e-10)
+	assert.Equal(t, full.BestEpoch(), second.BestEpoch())
+	assert.Equal(t, 6, second.InCosts().Len())
+
+	// the checkpoint has no validation costs, which are saved as null
+	js, e := os.ReadFile(root + "O.nn")
+	assert.Nil(t, e)
+	assert.Contains(t, string(js), "null")
+
+	// the early stopping count is saved and restored: a fit that had stopped early does not continue
+	pipe = NewVecData("resume", gd, WithBatchSize(100))
+	nn, e = LoadNN(root, pipe, true)
+	assert.Nil(t, e)
+	WithCostFn(RMS)(nn)
+
+	valFit := NewFit(nn, 8, pipe, WithValidation(NewVecData("val", gd, WithBatchSize(100)), 2), WithResume(root),
+		WithCheckpoint(root))
+	assert.Nil(t, valFit.Do())
+
+	state, e = loadFitState(root)
+	assert.Nil(t, e)
+	assert.Equal(t, state.Epoch-state.BestEpoch, state.Wait)
+
+	state.Wait = 3
+	js, e = json.Marshal(state)
+	assert.Nil(t, e)
+	assert.Nil(t, os.WriteFile(root+"O.nn", js, 0644))
+
+	pipe = NewVecData("resume", gd, WithBatchSize(100))
+	nn, e = LoadNN(root, pipe, true)
+	assert.Nil(t, e)
+	WithCostFn(RMS)(nn)
+
+	stopped := NewFit(nn, 12, pipe, WithValidation(NewVecData("val", gd, WithBatchSize(100)), 2), WithResume(root))
+	assert.Nil(t, stopped.Do())
+	assert.Equal(t, state.History.Epoch, stopped.History().Epoch)
+
+	// unknown versions are rejected
+	assert.Nil(t, os.WriteFile(root+"O.nn", []byte(`{"version": 99}`), 0644))
+	_, e = loadFitState(root)
+	assert.NotNil(t, e)
+
+	assert.NotNil(t, NewFit(nn, 6, pipe, WithResume(root)).Do())
+}
@@ -0,0 +1,269 @@
+package seafan
+
+// compare.go compares two models on the same pipeline (champion/challenger)
+
+import (
+	"fmt"
+	"math"
+
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/invertedv/utilities"
+	"gonum.org/v1/gonum/stat"
+)
+
+// CompareModels compares the models saved in fileA and fileB on pipe.  The models must have the same target field,
+// which must be in pipe.  The fitted value of each model is the sum of the target columns of its output.  If target
+// is nil, it is column 0 for an FRCts target and column 1 for a target with two levels.  The observed value is the
+// target (FRCts) or an indicator that the target is one of the target levels (one-hot).
+//
+// summary has the fields statistic, modelA and modelB and a row for each of:
+//   - rows: the number of rows of pipe;
+//   - mean fit, std fit: the mean and standard deviation of the fitted values;
+//   - mean obs: the mean of the observed values;
+//   - R2: the r-square of the fitted values (see Metric);
+//   - KS: the KS statistic (see Metric).  It is NaN if the target is FRCts;
+//   - corr: the correlation between the fitted values of the models;
+//   - PSI: the population stability index of the fitted values of B against those of A, with bins at the deciles of A.
+//     It is 0 for A.
+//
+// deciles has a row for each decile of the fitted values of each model with the fields decile, fitA and obsA (the
+// average fitted and observed values of A within the deciles of A) and fitB and obsB (the same for B).  The averages
+// are NaN for an empty decile, which happens if there are many ties.
+//
+// If plt is not nil, a plot of the fitted values of B against those of A is produced beside a decile plot of both
+// models.
+func CompareModels(fileA, fileB string, pipe Pipeline, target []int, plt *utilities.PlotDef) (summary, deciles Pipeline, err error) {
+	msA, e := LoadModSpec(fileA + "S.nn")
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	msB, e := LoadModSpec(fileB + "S.nn")
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	if msA.TargetName() != msB.TargetName() {
+		return nil, nil, Wrapper(ErrDiags, fmt.Sprintf("CompareModels: targets %s and %s differ", msA.TargetName(), msB.TargetName()))
+	}
+
+	obsD := pipe.Get(msA.TargetName())
+	if obsD == nil {
+		return nil, nil, Wrapper(ErrDiags, fmt.Sprintf("CompareModels: target %s not in pipeline", msA.TargetName()))
+	}
+
+	trg, e := obsTarget(obsD.FT, target)
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	obs, e := obsValues(obsD, trg)
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	fitA, e := modelFit(fileA, pipe, trg, obsD.FT)
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	fitB, e := modelFit(fileB, pipe, trg, obsD.FT)
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	decA, e := decileEdges(fitA)
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	decB, e := decileEdges(fitB)
+	if e != nil {
+		return nil, nil, Wrapper(e, "CompareModels")
+	}
+
+	// summary statistics
+	stats := []string{"rows", "mean fit", "std fit", "mean obs", "R2", "KS", "corr", "PSI"}
+	var colA, colB []any
+
+	for ind, fit := range [][]float64{fitA, fitB} {
+		ks := math.NaN()
+		if obsD.FT.Role != FRCts {
+			ks = Metric("KS")(obs, fit)
+		}
+
+		psi := 0.0
+		if ind == 1 {
+			psi = fitPSI(decA, fitA, fitB)
+		}
+
+		vals := []any{float64(len(fit)), stat.Mean(fit, nil), stat.StdDev(fit, nil), stat.Mean(obs, nil), Metric("R2")(obs, fit), ks,
+			stat.Correlation(fitA, fitB, nil), psi}
+
+		if ind == 0 {
+			colA = vals
+			continue
+		}
+
+		colB = vals
+	}
+
+	names := make([]any, len(stats))
+	for ind, s := range stats {
+		names[ind] = s
+	}
+
+	if summary, err = VecFromAny([][]any{names, colA, colB}, []string{"statistic", "modelA", "modelB"}, nil); err != nil {
+		return nil, nil, Wrapper(err, "CompareModels")
+	}
+
+	// decile tables
+	fDecA, oDecA := decileMeans(decA, fitA, obs)
+	fDecB, oDecB := decileMeans(decB, fitB, obs)
+	cols := make([][]any, 5)
+
+	for dec := range fDecA {
+		for col, v := range []any{int32(dec + 1), fDecA[dec], oDecA[dec], fDecB[dec], oDecB[dec]} {
+			cols[col] = append(cols[col], v)
+		}
+	}
+
+	if deciles, err = VecFromAny(cols, []string{"decile", "fitA", "obsA", "fitB", "obsB"}, nil); err != nil {
+		return nil, nil, Wrapper(err, "CompareModels")
+	}
+
+	if plt != nil {
+		if err = compareFig(fitA, fitB, fDecA, oDecA, fDecB, oDecB, plt); err != nil {
+			return nil, nil, Wrapper(err, "CompareModels")
+		}
+	}
+
+	return summary, deciles, nil
+}
+
+// modelFit returns the sum of the trg columns of the output of the model saved in nnFile on all the rows of pipe.
+// If obsFt is FRCts, the fit is unnormalized.
+func modelFit(nnFile string, pipe Pipeline, trg []int, obsFt *FType) ([]float64, error) {
+	// score all the rows in one batch without changing the batch size of pipe
+	all := NewVecData("score", pipe.GData(), WithBatchSize(0))
+
+	nn, e := PredictNN(nnFile, all, false)
+	if e != nil {
+		return nil, e
+	}
+
+	if trg == nil {
+		trg = []int{0}
+	}
+
+	fit, e := Coalesce(nn.FitSlice(), nn.OutputCols(), trg, false, false, nil)
+	if e != nil {
+		return nil, e
+	}
+
+	if obsFt.Role == FRCts {
+		return UnNormalize(fit, obsFt), nil
+	}
+
+	return fit, nil
+}
+
+// decileEdges returns the deciles of x
+func decileEdges(x []float64) ([]float64, error) {
+	deciles, e := NewDesc([]float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}, "fitted")
+	if e != nil {
+		return nil, e
+	}
+
+	deciles.Populate(x, true, nil)
+
+	return deciles.Q, nil
+}
+
+// decileGroup returns the decile group, 0 to len(edges), of x
+func decileGroup(edges []float64, x float64) int {
+	for g, edge := range edges {
+		if x < edge {
+			return g
+		}
+	}
+
+	return len(edges)
+}
+
+// decileMeans returns the average of fit and obs within the decile groups of fit
+func decileMeans(edges, fit, obs []float64) (fDec, oDec []float64) {
+	fDec, oDec = make([]float64, len(edges)+1), make([]float64, len(edges)+1)
+	n := make([]int, len(edges)+1)
+
+	for row, f := range fit {
+		g := decileGroup(edges, f)
+		fDec[g] += f
+		oDec[g] += obs[row]
+		n[g]++
+	}
+
+	for g := range fDec {
+		fDec[g] /= float64(n[g])
+		oDec[g] /= float64(n[g])
+	}
+
+	return fDec, oDec
+}
+
+// fitPSI returns the PSI of fitNew against fitBase with bins at edges (see PSI)
+func fitPSI(edges, fitBase, fitNew []float64) float64 {
+	shares := func(x []float64) []float64 {
+		s := make([]float64, len(edges)+1)
+		for _, xv := range x {
+			s[decileGroup(edges, xv)] += 1.0 / float64(len(x))
+		}
+
+		return s
+	}
+
+	baseShare, newShare := shares(fitBase), shares(fitNew)
+	psi := 0.0
+
+	for ind, b := range baseShare {
+		b, n := math.Max(b, psiFloor), math.Max(newShare[ind], psiFloor)
+		psi += (n - b) * math.Log(n/b)
+	}
+
+	return psi
+}
+
+// compareFig plots fitB against fitA beside the decile averages of both models
+func compareFig(fitA, fitB, fDecA, oDecA, fDecB, oDecB []float64, plt *utilities.PlotDef) error {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, x := range [][]float64{fitA, fitB, oDecA, oDecB} {
+		for _, xv := range x {
+			lo, hi = math.Min(lo, xv), math.Max(hi, xv)
+		}
+	}
+
+	fig := &grob.Fig{}
+	fig.AddTraces(
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: fitA, Y: fitB, Name: "fitted", Mode: grob.ScatterModeMarkers,
+			Xaxis: "x", Yaxis: "y", Marker: &grob.ScatterMarker{Color: "black"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: []float64{lo, hi}, Y: []float64{lo, hi}, Name: "ref",
+			Mode: grob.ScatterModeLines, Xaxis: "x", Yaxis: "y", Line: &grob.ScatterLine{Color: "red"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: fDecA, Y: oDecA, Name: "A deciles", Mode: grob.ScatterModeMarkers,
+			Xaxis: "x2", Yaxis: "y2", Marker: &grob.ScatterMarker{Color: "black"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: fDecB, Y: oDecB, Name: "B deciles", Mode: grob.ScatterModeMarkers,
+			Xaxis: "x2", Yaxis: "y2", Marker: &grob.ScatterMarker{Color: "blue"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: []float64{lo, hi}, Y: []float64{lo, hi}, Name: "ref",
+			Mode: grob.ScatterModeLines, Xaxis: "x2", Yaxis: "y2", Line: &grob.ScatterLine{Color: "red"}},
+	)
+
+	lay := &grob.Layout{}
+	lay.Grid = &grob.LayoutGrid{Rows: 1, Columns: 2, Pattern: grob.LayoutGridPatternIndependent}
+
+	if plt.Title == "" {
+		plt.Title = "Model Comparison"
+	}
+
+	plt.Title = fmt.Sprintf("%s<br>Left: fitted B vs A, Right: observed vs fitted by decile", plt.Title)
+
+	return plotter(fig, lay, plt)
+}
@@ -0,0 +1,59 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareModels(t *testing.T) {
+	Verbose = false
+	SetSeed(3)
+	pipe := scorerPipe(t, 100)
+
+	roots := make([]string, 0)
+
+	for _, inputs := range []string{"Input(x1+x2+x3+x4oh)", "Input(x1+x2)"} {
+		mod := ModSpec{inputs, "FC(size:3, activation:relu)", "FC(size:2, activation:softmax)", "Target(yoh)"}
+
+		nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+		assert.Nil(t, e)
+
+		ft := NewFit(nn, 2, pipe, WithOutFile(os.TempDir()+"/compare"+string(rune('A'+len(roots)))))
+		assert.Nil(t, ft.Do())
+
+		roots = append(roots, ft.OutFile())
+	}
+
+	defer func() {
+		for _, root := range roots {
+			_ = os.Remove(root + "P.nn")
+			_ = os.Remove(root + "S.nn")
+		}
+	}()
+
+	bSize := pipe.BatchSize()
+	summary, deciles, e := CompareModels(roots[0], roots[1], pipe, nil, nil)
+	assert.Nil(t, e)
+	assert.Equal(t, bSize, pipe.BatchSize())
+	assert.Equal(t, 8, summary.Rows())
+	assert.Equal(t, 10, deciles.Rows())
+
+	a, b := summary.Get("modelA").Floats(), summary.Get("modelB").Floats()
+	assert.Equal(t, float64(pipe.Rows()), a[0])
+	assert.Equal(t, a[3], b[3])
+	assert.Equal(t, 0.0, a[7])
+	assert.Greater(t, b[7], 0.0)
+
+	// a model compared to itself
+	summary, _, e = CompareModels(roots[0], roots[0], pipe, nil, nil)
+	assert.Nil(t, e)
+
+	b = summary.Get("modelB").Floats()
+	assert.InDelta(t, 1.0, b[6], 1e-10)
+	assert.InDelta(t, 0.0, b[7], 1e-10)
+
+	_, _, e = CompareModels(roots[0], "nope", pipe, nil, nil)
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,275 @@
+package seafan
+
+// compile.go type-checks an expression once against a schema so it can be run repeatedly
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/invertedv/utilities"
+)
+
+// Program is an expression compiled by Compile.  It may be Run against any Pipeline with the fields of the FTypes it
+// was compiled with.  A Program is not safe for concurrent use.
+type Program struct {
+	Expression string
+	root       *OpNode
+	kind       reflect.Kind
+	fields     []string
+	opts       []EvalOpt // options given to Compile, applied before those given to Run
+}
+
+// Compile parses expr (see Expr2Tree) and checks it against the fields fts.  Problems are returned as a *ParseError.
+// It checks that:
+//   - the functions exist and have the right number of arguments;
+//   - the fields exist and are not one-hot, embedded or sequence fields;
+//   - the types of the arguments are compatible with the functions, as far as they can be known before the data is
+//     seen (the types of FRCat fields aren't known until then).
+//
+// Constants are evaluated once, here, rather than each time the Program is run.  The interior nodes of the expression
+// keep the slices that hold their values, so later runs with the same number of rows do not allocate them again.
+// opts are options for Run (see Evaluate): the names bound in an Env given by WithEnv are known to Compile and
+// are used by Run unless Run is given another Env.  Names bound only in an Env passed to Run are not known to Compile.
+func Compile(expr string, fts FTypes, opts ...EvalOpt) (*Program, error) {
+	root := &OpNode{Expression: expr}
+	if e := Expr2Tree(root); e != nil {
+		return nil, e
+	}
+
+	prog := &Program{Expression: expr, root: root, opts: opts}
+
+	kind, e := prog.check(root, fts, newEvalConfig(opts), false)
+	if e != nil {
+		return nil, locate(expr, e)
+	}
+
+	prog.kind = kind
+	reuseBuffers(root)
+
+	return prog, nil
+}
+
+// reuseBuffers marks the nodes below node to keep their result slices between runs
+func reuseBuffers(node *OpNode) {
+	for _, in := range node.Inputs {
+		in.reuse = true
+		reuseBuffers(in)
+	}
+}
+
+// buffered returns true if raw is the result slice kept by node or a node below it
+func buffered(node *OpNode, raw *Raw) bool {
+	if node.buf == raw {
+		return true
+	}
+
+	for _, in := range node.Inputs {
+		if buffered(in, raw) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run evaluates the Program using the fields of pipe.  See Evaluate for opts.  The result belongs to the caller: it
+// is not overwritten by the next Run.
+func (p *Program) Run(pipe Pipeline, opts ...EvalOpt) (*Raw, error) {
+	return p.run(onePipe(pipe), append(append([]EvalOpt{}, p.opts...), opts...))
+}
+
+// RunGD evaluates the Program using the fields of gd.
+func (p *Program) RunGD(gd *GData, opts ...EvalOpt) (*Raw, error) {
+	return p.run(oneGData(gd), append(append([]EvalOpt{}, p.opts...), opts...))
+}
+
+func (p *Program) run(pipes pipeFinder, opts []EvalOpt) (*Raw, error) {
+	if e := evaluate(p.root, pipes, newEvalConfig(opts)); e != nil {
+		return nil, locate(p.Expression, e)
+	}
+
+	// a function such as exist may return the slice of a node, which the next run overwrites
+	if raw := p.root.Raw; raw != nil && buffered(p.root, raw) {
+		p.root.Raw = &Raw{Data: append([]any(nil), raw.Data...), Kind: raw.Kind}
+	}
+
+	return p.root.Raw, nil
+}
+
+// Node returns the root of the expression tree.  After Run, it can be added to a Pipeline with AddToPipe.
+func (p *Program) Node() *OpNode {
+	return p.root
+}
+
+// Kind returns the type of the result.  It is reflect.Interface if the type isn't known until the Program is run.
+// Numeric results are reflect.Float64.
+func (p *Program) Kind() reflect.Kind {
+	return p.kind
+}
+
+// Fields returns the fields the Program uses.
+func (p *Program) Fields() []string {
+	return p.fields
+}
+
+// check checks node and returns the kind of its value.  If inExist, node is an argument of exist, which need not be
+// a field.  Since exist returns its argument, constants in exist are not kept.
+func (p *Program) check(node *OpNode, fts FTypes, cfg *evalConfig, inExist bool) (reflect.Kind, error) {
+	if node.Func == nil && node.Inputs != nil {
+		return reflect.Invalid, newParseError(node.Expression, "unknown function", "")
+	}
+
+	// leaf
+	if node.Func == nil {
+		return p.checkLeaf(node, fts, cfg, inExist)
+	}
+
+	if len(node.Inputs) != len(node.Func.Args) {
+		return reflect.Invalid, newParseError(node.Expression, fmt.Sprintf("%s takes %d arguments, got %d",
+			node.Func.Name, len(node.Func.Args), len(node.Inputs)), "")
+	}
+
+	kinds := make([]reflect.Kind, len(node.Inputs))
+
+	for ind, in := range node.Inputs {
+		var e error
+		if kinds[ind], e = p.check(in, fts, cfg, node.Func.Name == "exist"); e != nil {
+			return reflect.Invalid, e
+		}
+
+		if !argOK(node.Func.Args[ind], kinds[ind]) {
+			return reflect.Invalid, newParseError(in.Expression, fmt.Sprintf("argument %d of %s must be %s", ind+1,
+				node.Func.Name, kindName(node.Func.Args[ind])), "")
+		}
+	}
+
+	// fields named by the FType functions
+	if utilities.Has(node.Func.Name, delim, ftFunctions) {
+		field := strings.ReplaceAll(node.Inputs[0].Expression, "'", "")
+		if fts.Get(field) == nil {
+			return reflect.Invalid, newParseError(node.Inputs[0].Expression,
+				fmt.Sprintf("%s: field %s not found", node.Func.Name, field), suggest(field, fts.names()))
+		}
+	}
+
+	kind, e := resultKind(node.Func, kinds)
+	if e != nil {
+		return reflect.Invalid, newParseError(node.Expression, e.Error(), "")
+	}
+
+	return kind, nil
+}
+
+// checkLeaf checks a constant or field and returns its kind.  Constants are evaluated.
+func (p *Program) checkLeaf(node *OpNode, fts FTypes, cfg *evalConfig, inExist bool) (reflect.Kind, error) {
+	// a bound name hides a field
+	if raw := cfg.lookup(node.Expression); raw != nil {
+		return raw.Kind, nil
+	}
+
+	// a field whose name parses as a number (e.g. Inf) is a field
+	if ft := fts.Get(node.Expression); ft != nil {
+		p.fields = append(p.fields, ft.Name)
+
+		switch ft.Role {
+		case FRCts:
+			return reflect.Float64, nil
+		case FRCat, FREither:
+			return reflect.Interface, nil
+		}
+
+		return reflect.Invalid, newParseError(node.Expression, fmt.Sprintf("cannot operate on %v field %s", ft.Role, ft.Name), "")
+	}
+
+	if inExist {
+		return reflect.Interface, nil
+	}
+
+	if !evalConstant(node) {
+		return reflect.Invalid, newParseError(node.Expression, fmt.Sprintf("field %s not found", node.Expression),
+			suggest(node.Expression, fts.names()))
+	}
+
+	// keep the value
+	node.stet = true
+
+	if _, ok := node.Raw.Data[0].(float64); ok {
+		return reflect.Float64, nil
+	}
+
+	return node.Raw.Kind, nil
+}
+
+// argOK returns true if a value of kind can be the argument arg.  reflect.Interface is unknown and always OK.
+func argOK(arg, kind reflect.Kind) bool {
+	if kind == reflect.Interface || arg == reflect.Interface {
+		return true
+	}
+
+	switch arg {
+	case reflect.String, reflect.Struct:
+		return kind == arg
+	}
+
+	// numeric
+	return kind != reflect.String && kind != reflect.Struct
+}
+
+// resultKind returns the kind of the result of fn with arguments of kinds
+func resultKind(fn *FuncSpec, kinds []reflect.Kind) (reflect.Kind, error) {
+	join := func(k1, k2 reflect.Kind) reflect.Kind {
+		if k1 == k2 {
+			return k1
+		}
+
+		return reflect.Interface
+	}
+
+	switch fn.Name {
+	case "if":
+		if !argOK(reflect.Float64, kinds[0]) {
+			return reflect.Invalid, fmt.Errorf("the condition of if must be numeric")
+		}
+
+		return join(kinds[1], kinds[2]), nil
+	case "exist", "maxE", "minE":
+		return join(kinds[0], kinds[1]), nil
+	case "lag", "index", "max", "min":
+		return kinds[0], nil
+	case ">", ">=", "<", "<=", "==", "!=":
+		if numeric(kinds[0]) != numeric(kinds[1]) && kinds[0] != reflect.Interface && kinds[1] != reflect.Interface {
+			return reflect.Invalid, fmt.Errorf("cannot compare %s and %s", kindName(kinds[0]), kindName(kinds[1]))
+		}
+
+		return reflect.Float64, nil
+	case "cat":
+		return reflect.Float64, nil
+	}
+
+	switch fn.Return {
+	case reflect.String, reflect.Struct, reflect.Interface:
+		return fn.Return, nil
+	}
+
+	return reflect.Float64, nil
+}
+
+// numeric returns true if kind is numeric
+func numeric(kind reflect.Kind) bool {
+	return kind != reflect.String && kind != reflect.Struct
+}
+
+// kindName describes kind for error messages
+func kindName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Struct:
+		return "a date"
+	case reflect.Interface:
+		return "any type"
+	}
+
+	return "numeric"
+}
@@ -0,0 +1,99 @@
+package seafan
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest6.csv", nil, false)
+	assert.Nil(t, e)
+
+	good := []struct {
+		expr string
+		kind reflect.Kind
+	}{
+		{"x+2*y", reflect.Float64},
+		{"-(x-y)^2", reflect.Float64},
+		{"if(x>y, x, -1)", reflect.Float64},
+		{"exist(xx, x)", reflect.Interface},
+		{"substr(s1, 1, 1)", reflect.String},
+		{"s1=='a'", reflect.Float64},
+		{"toDate(d1)", reflect.Struct},
+		{"dateDiff(toDate(d1), toDate(d2), 'year')", reflect.Float64},
+		{"mean(x) + scaleOf('x')", reflect.Float64},
+		{"maxE(x, y)", reflect.Float64},
+	}
+
+	for _, g := range good {
+		prog, e := Compile(g.expr, pipe.GetFTypes())
+		assert.Nil(t, e, g.expr)
+		assert.Equal(t, g.kind, prog.Kind(), g.expr)
+
+		root := &OpNode{Expression: g.expr}
+		assert.Nil(t, Expr2Tree(root))
+		assert.Nil(t, Evaluate(root, pipe))
+
+		// run twice: the Program is reusable
+		for run := 0; run < 2; run++ {
+			raw, e := prog.Run(pipe)
+			assert.Nil(t, e, g.expr)
+			assert.Equal(t, root.Raw.Data, raw.Data, g.expr)
+		}
+	}
+
+	prog, e := Compile("x+y*x", pipe.GetFTypes())
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, []string{"x", "y", "x"}, prog.Fields())
+
+	_, e = prog.RunGD(pipe.GData())
+	assert.Nil(t, e)
+
+	// the interior nodes keep their slices between runs, the result does not
+	first, e := prog.Run(pipe)
+	assert.Nil(t, e)
+
+	inner := prog.Node().Inputs[1].Raw
+	second, e := prog.Run(pipe)
+	assert.Nil(t, e)
+	assert.Same(t, inner, prog.Node().Inputs[1].Raw)
+	assert.NotSame(t, &first.Data[0], &second.Data[0])
+	assert.Equal(t, first.Data, second.Data)
+
+	// exist returns the slice of its argument, which is copied
+	prog, e = Compile("exist(xx, x+y)", pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	first, e = prog.Run(pipe)
+	assert.Nil(t, e)
+
+	second, e = prog.Run(pipe)
+	assert.Nil(t, e)
+	assert.NotSame(t, &first.Data[0], &second.Data[0])
+	assert.Equal(t, first.Data, second.Data)
+
+	bad := []string{
+		"x+z",
+		"foo(x)",
+		"exp(x, y)",
+		"exp('a')",
+		"x+'a'",
+		"x>'2020-01-01'",
+		"dateAdd(x, 1)",
+		"substr(x, 0, 1)",
+		"scaleOf('zz')",
+		"(x+y",
+	}
+
+	for _, expr := range bad {
+		_, e := Compile(expr, pipe.GetFTypes())
+		assert.True(t, errors.Is(e, ErrParser), expr)
+	}
+}
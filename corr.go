@@ -0,0 +1,228 @@
+package seafan
+
+// corr.go calculates correlations and associations between fields
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/invertedv/utilities"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Corr holds the pairwise associations of a set of fields.  The measure depends on the roles of the pair:
+//   - both FRCts: the Pearson and Spearman (rank) correlations.
+//   - both FRCat: Cramér's V.
+//   - one of each: the correlation ratio (eta) of the FRCts field on the FRCat field.
+//
+// For pairs that are not both FRCts, Pearson and Spearman hold the same value.  The measure used is in Kind.
+type Corr struct {
+	Fields   []string    // Fields are the rows and columns of the matrices
+	Kind     [][]string  // Kind is the measure of each pair: "corr", "cramersV" or "eta"
+	Pearson  [][]float64 // Pearson correlations
+	Spearman [][]float64 // Spearman correlations
+}
+
+// CorrMatrix calculates the pairwise associations of fields in pipe.  The fields must be FRCts or FRCat.
+func CorrMatrix(pipe Pipeline, fields []string) (*Corr, error) {
+	if len(fields) < 2 {
+		return nil, Wrapper(ErrDiags, "CorrMatrix: need at least 2 fields")
+	}
+
+	data := make([]*GDatum, len(fields))
+
+	for ind, f := range fields {
+		if data[ind] = pipe.Get(f); data[ind] == nil {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("CorrMatrix: field %s not found", f))
+		}
+
+		if r := data[ind].FT.Role; r != FRCts && r != FRCat {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("CorrMatrix: field %s must be FRCts or FRCat", f))
+		}
+	}
+
+	n := len(fields)
+	c := &Corr{Fields: fields, Kind: make([][]string, n), Pearson: make([][]float64, n), Spearman: make([][]float64, n)}
+
+	// ranks of the continuous fields for Spearman
+	ranks := make([][]float64, n)
+
+	for ind, d := range data {
+		c.Kind[ind], c.Pearson[ind], c.Spearman[ind] = make([]string, n), make([]float64, n), make([]float64, n)
+
+		if d.FT.Role == FRCts {
+			ranks[ind] = rank(d.Floats())
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			var kind string
+
+			var p, s float64
+
+			di, dj := data[i], data[j]
+
+			switch {
+			case di.FT.Role == FRCts && dj.FT.Role == FRCts:
+				kind = "corr"
+				p = stat.Correlation(di.Floats(), dj.Floats(), nil)
+				s = stat.Correlation(ranks[i], ranks[j], nil)
+			case di.FT.Role == FRCat && dj.FT.Role == FRCat:
+				kind = "cramersV"
+				p = cramersV(di.Data.([]int32), dj.Data.([]int32))
+				s = p
+			case di.FT.Role == FRCts:
+				kind = "eta"
+				p = eta(di.Floats(), dj.Data.([]int32))
+				s = p
+			default:
+				kind = "eta"
+				p = eta(dj.Floats(), di.Data.([]int32))
+				s = p
+			}
+
+			c.Kind[i][j], c.Kind[j][i] = kind, kind
+			c.Pearson[i][j], c.Pearson[j][i] = p, p
+			c.Spearman[i][j], c.Spearman[j][i] = s, s
+		}
+	}
+
+	return c, nil
+}
+
+// String produces a table of the Pearson correlations and associations
+func (c *Corr) String() string {
+	const pad = 3
+
+	table := [][]string{append([]string{""}, c.Fields...)}
+
+	for i, f := range c.Fields {
+		row := []string{f}
+		for j := range c.Fields {
+			row = append(row, fmt.Sprintf("%0.3f", c.Pearson[i][j]))
+		}
+
+		table = append(table, row)
+	}
+
+	return utilities.Pad(table, pad)
+}
+
+// Plot produces a heatmap of the Pearson (or, if spearman is true, the Spearman) correlations.
+func (c *Corr) Plot(spearman bool, plt *utilities.PlotDef) error {
+	z := c.Pearson
+	if spearman {
+		z = c.Spearman
+	}
+
+	hm := &grob.Heatmap{
+		Type:       grob.TraceTypeHeatmap,
+		X:          c.Fields,
+		Y:          c.Fields,
+		Z:          z,
+		Zmin:       -1.0,
+		Zmax:       1.0,
+		Colorscale: grob.ColorScale("RdBu"),
+	}
+
+	if plt.Title == "" {
+		plt.Title = "Correlations"
+	}
+
+	fig := &grob.Fig{Data: grob.Traces{hm}}
+
+	return plotter(fig, &grob.Layout{}, plt)
+}
+
+// rank returns the ranks of x (starting at 1).  Ties get the average rank.
+func rank(x []float64) []float64 {
+	ind := make([]int, len(x))
+	for i := range ind {
+		ind[i] = i
+	}
+
+	sort.SliceStable(ind, func(i, j int) bool { return x[ind[i]] < x[ind[j]] })
+
+	r := make([]float64, len(x))
+
+	for start := 0; start < len(ind); {
+		end := start + 1
+		for end < len(ind) && x[ind[end]] == x[ind[start]] {
+			end++
+		}
+
+		avg := float64(start+end+1) / 2.0
+		for k := start; k < end; k++ {
+			r[ind[k]] = avg
+		}
+
+		start = end
+	}
+
+	return r
+}
+
+// cramersV returns Cramér's V of the categorical x and y
+func cramersV(x, y []int32) float64 {
+	type cell struct{ x, y int32 }
+
+	obs := make(map[cell]float64)
+	xCnt, yCnt := make(map[int32]float64), make(map[int32]float64)
+
+	for ind := range x {
+		obs[cell{x[ind], y[ind]}]++
+		xCnt[x[ind]]++
+		yCnt[y[ind]]++
+	}
+
+	k := math.Min(float64(len(xCnt)), float64(len(yCnt)))
+	if k < 2 {
+		return 0.0
+	}
+
+	n := float64(len(x))
+	chi2 := 0.0
+
+	for xv, xc := range xCnt {
+		for yv, yc := range yCnt {
+			exp := xc * yc / n
+			d := obs[cell{xv, yv}] - exp
+			chi2 += d * d / exp
+		}
+	}
+
+	return math.Sqrt(chi2 / (n * (k - 1)))
+}
+
+// eta returns the correlation ratio of the continuous x on the categorical grp
+func eta(x []float64, grp []int32) float64 {
+	sum, cnt := make(map[int32]float64), make(map[int32]float64)
+
+	for ind, g := range grp {
+		sum[g] += x[ind]
+		cnt[g]++
+	}
+
+	m := mean(x)
+	ssTot := 0.0
+
+	for _, xv := range x {
+		ssTot += (xv - m) * (xv - m)
+	}
+
+	if ssTot == 0.0 {
+		return 0.0
+	}
+
+	ssBetween := 0.0
+
+	for g, s := range sum {
+		gm := s / cnt[g]
+		ssBetween += cnt[g] * (gm - m) * (gm - m)
+	}
+
+	return math.Sqrt(ssBetween / ssTot)
+}
@@ -0,0 +1,39 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrMatrix(t *testing.T) {
+	gd := getData(t)
+	// x4 is a monotone but non-linear function of x1
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 4, 9, 16, 64, 81, 100}, nil), "x4", false, nil, false))
+	// x5 is the same grouping as x2 with different labels
+	assert.Nil(t, gd.AppendD(NewRawCast([]string{"p", "q", "r", "p", "p", "p", "p"}, nil), "x5", nil, false))
+	pipe := NewVecData("corr", gd)
+
+	c, e := CorrMatrix(pipe, []string{"x1", "x4", "x2", "x5"})
+	assert.Nil(t, e)
+
+	assert.Equal(t, "corr", c.Kind[0][1])
+	assert.InDelta(t, 1.0, c.Spearman[0][1], 1e-10)
+	assert.Less(t, c.Pearson[0][1], 1.0)
+
+	assert.Equal(t, "cramersV", c.Kind[2][3])
+	assert.InDelta(t, 1.0, c.Pearson[2][3], 1e-10)
+
+	assert.Equal(t, "eta", c.Kind[0][2])
+	assert.Equal(t, c.Pearson[0][2], c.Pearson[2][0])
+	assert.Greater(t, c.Pearson[0][2], 0.0)
+
+	assert.Contains(t, c.String(), "x5")
+
+	_, e = CorrMatrix(pipe, []string{"x1", "x2Oh"})
+	assert.NotNil(t, e)
+}
+
+func TestRank(t *testing.T) {
+	assert.Equal(t, []float64{3, 1.5, 4, 1.5}, rank([]float64{2, 1, 5, 1}))
+}
@@ -134,7 +134,7 @@ func (p *XY) Plot(pd *utilities.PlotDef, scatter bool) error {
 
 	fig := &grob.Fig{Data: grob.Traces{tr}}
 
-	return utilities.Plotter(fig, nil, pd)
+	return plotter(fig, nil, pd)
 }
 
 // Desc contains descriptive information of a float64 slice
@@ -203,6 +203,48 @@ func (d *Desc) Populate(x []float64, noSort bool, sl Slicer) {
 	d.Mean, d.Std = stat.MeanStdDev(xIn, nil)
 }
 
+// PopulateWeighted calculates the descriptive statistics based on x weighted by w. The quantiles are the weighted
+// quantiles--for instance, the median is the value of x that splits the total weight in half.
+func (d *Desc) PopulateWeighted(x, w []float64, sl Slicer) error {
+	if len(x) != len(w) {
+		return Wrapper(ErrData, fmt.Sprintf("PopulateWeighted: x has %d elements, w has %d", len(x), len(w)))
+	}
+
+	xw := make([][2]float64, 0, len(x))
+
+	for row := 0; row < len(x); row++ {
+		if sl != nil && !sl(row) {
+			continue
+		}
+
+		if w[row] < 0.0 {
+			return Wrapper(ErrData, "PopulateWeighted: weights cannot be negative")
+		}
+
+		xw = append(xw, [2]float64{x[row], w[row]})
+	}
+
+	if len(xw) == 0 {
+		return Wrapper(ErrData, "PopulateWeighted: no data")
+	}
+
+	sort.Slice(xw, func(i, j int) bool { return xw[i][0] < xw[j][0] })
+
+	xs, ws := make([]float64, len(xw)), make([]float64, len(xw))
+	for ind, v := range xw {
+		xs[ind], ws[ind] = v[0], v[1]
+	}
+
+	for ind, u := range d.U {
+		d.Q[ind] = stat.Quantile(u, stat.Empirical, xs, ws)
+	}
+
+	d.N = len(xs)
+	d.Mean, d.Std = stat.MeanStdDev(xs, ws)
+
+	return nil
+}
+
 func (d *Desc) String() string {
 	s := fmt.Sprintf("Descriptive Statistics for %s\n", d.Name)
 	s = fmt.Sprintf("%sn               %d\n", s, d.N)
@@ -811,31 +853,174 @@ func (l Levels) Sort(byName, ascend bool) (key []any, val []int32) {
 	return key, val
 }
 
-// TopK returns the top k values either by name or by counts, ascending or descending
+// TopK returns the top k values either by name or by counts, ascending or descending, along with their share
+// of the total count.
 func (l Levels) TopK(topNum int, byName, ascend bool) string {
-	const top = 11
+	const (
+		top   = 11 // "Field Value" length
+		count = 5  // "Count" length
+	)
 
 	keyS, valS := l.Sort(byName, ascend)
+	shares := l.Shares()
 
-	maxLen := 11 // "Field Value" length
+	maxLen, cntLen := top, count
 
-	for kx := range l {
+	for kx, v := range l {
 		maxLen = utilities.MaxInt(maxLen, len(fmt.Sprintf("%v", kx)))
+		cntLen = utilities.MaxInt(cntLen, len(fmt.Sprintf("%d", v)))
 	}
 
 	if topNum <= 0 {
 		topNum = len(keyS)
 	}
 
-	str := fmt.Sprintf("Field Value%sCount\n", pad(maxLen, top))
+	str := fmt.Sprintf("Field Value%sCount%sShare\n", pad(maxLen, top), pad(cntLen, count))
 	for ind := 0; ind < utilities.MinInt(topNum, len(keyS)); ind++ {
-		keyS := fmt.Sprintf("%v", keyS[ind])
-		str = fmt.Sprintf("%s%s%s%v\n", str, keyS, pad(maxLen, len(keyS)), valS[ind])
+		key, cnt := fmt.Sprintf("%v", keyS[ind]), fmt.Sprintf("%d", valS[ind])
+		str = fmt.Sprintf("%s%s%s%s%s%0.2f%%\n", str, key, pad(maxLen, len(key)), cnt, pad(cntLen, len(cnt)),
+			100.0*shares[keyS[ind]])
 	}
 
 	return str
 }
 
+// TopKOrdered returns the counts and shares of the first k levels of an ordinal field in the order given by order,
+// which maps levels to their place in the order (see AppendOrdinal).
+func (l Levels) TopKOrdered(topNum int, order Levels) string {
+	const pad = 5
+
+	keyS, _ := order.Sort(false, true)
+	shares := l.Shares()
+
+	if topNum <= 0 {
+		topNum = len(keyS)
+	}
+
+	table := [][]string{{"Field Value", "Count", "Share"}}
+	for ind := 0; ind < utilities.MinInt(topNum, len(keyS)); ind++ {
+		table = append(table, []string{fmt.Sprintf("%v", keyS[ind]), fmt.Sprintf("%d", l[keyS[ind]]),
+			fmt.Sprintf("%0.2f%%", 100.0*shares[keyS[ind]])})
+	}
+
+	return utilities.Pad(table, pad)
+}
+
+// WLevels is a map from the values of a discrete field to weighted counts (e.g. balances)
+type WLevels map[any]float64
+
+// ByWeights builds a WLevels map with the weighted distribution of data. weights must have the same length as data.
+func ByWeights(data, weights *Raw, sl Slicer) (WLevels, error) {
+	if data.Len() != weights.Len() {
+		return nil, Wrapper(ErrData, fmt.Sprintf("ByWeights: data has %d rows, weights has %d", data.Len(), weights.Len()))
+	}
+
+	w, e := utilities.AnySlice2Float64(weights.Data)
+	if e != nil {
+		return nil, Wrapper(ErrData, "ByWeights: weights must be numeric")
+	}
+
+	l := make(WLevels)
+	for row := 0; row < data.Len(); row++ {
+		if sl == nil || sl(row) {
+			l[data.Data[row]] += w[row]
+		}
+	}
+
+	return l, nil
+}
+
+// Shares returns the share of the total count of each level
+func (l Levels) Shares() WLevels {
+	w := make(WLevels)
+	for k, v := range l {
+		w[k] = float64(v)
+	}
+
+	return w.Shares()
+}
+
+// Total returns the total weight
+func (w WLevels) Total() float64 {
+	tot := 0.0
+	for _, v := range w {
+		tot += v
+	}
+
+	return tot
+}
+
+// Shares returns the share of the total weight of each level
+func (w WLevels) Shares() WLevels {
+	tot := w.Total()
+	shares := make(WLevels)
+
+	for k, v := range w {
+		shares[k] = 0.0
+		if tot != 0.0 {
+			shares[k] = v / tot
+		}
+	}
+
+	return shares
+}
+
+// Sort sorts WLevels, returns sorted map as key, val slices
+func (w WLevels) Sort(byName, ascend bool) (key []any, val []float64) {
+	inKey := make([]any, 0, len(w))
+	inVal := make([]any, 0, len(w))
+	ord := make([]int, 0, len(w))
+
+	for kx, v := range w {
+		inKey = append(inKey, kx)
+		inVal = append(inVal, v)
+		ord = append(ord, len(ord))
+	}
+
+	// kv sorts sortOn in place, so copy it
+	sortOn := make([]any, len(inVal))
+	copy(sortOn, inVal)
+
+	if byName {
+		copy(sortOn, inKey)
+	}
+
+	sort.Sort(&kv{ord: ord, kv: sortOn, ascend: ascend})
+
+	for _, o := range ord {
+		key = append(key, inKey[o])
+		val = append(val, w[inKey[o]])
+	}
+
+	return key, val
+}
+
+// TopK returns the top k values either by name or by weight, ascending or descending, along with their
+// share of the total weight.
+func (w WLevels) TopK(topNum int, byName, ascend bool) string {
+	const pad = 5
+
+	keyS, valS := w.Sort(byName, ascend)
+	tot := w.Total()
+
+	if topNum <= 0 {
+		topNum = len(keyS)
+	}
+
+	table := [][]string{{"Field Value", "Weight", "Share"}}
+	for ind := 0; ind < utilities.MinInt(topNum, len(keyS)); ind++ {
+		share := 0.0
+		if tot != 0.0 {
+			share = valS[ind] / tot
+		}
+
+		table = append(table, []string{fmt.Sprintf("%v", keyS[ind]), fmt.Sprintf("%0.2f", valS[ind]),
+			fmt.Sprintf("%0.2f%%", 100.0*share)})
+	}
+
+	return utilities.Pad(table, pad)
+}
+
 // Unique returns a slice of the unique values of xs
 func Unique(xs []any) []any {
 	u := make([]any, 0)
@@ -64,6 +64,32 @@ func TestDesc_Populate(t *testing.T) {
 	assert.Equal(t, true, sort.Float64sAreSorted(x))
 }
 
+func TestDesc_PopulateWeighted(t *testing.T) {
+	x := []float64{4, 1, 3, 2}
+	w := []float64{1, 1, 1, 7}
+	d, e := NewDesc([]float64{0.5}, "test")
+	assert.Nil(t, e)
+
+	e = d.PopulateWeighted(x, w, nil)
+	assert.Nil(t, e)
+	// 2 has 70% of the weight
+	assert.Equal(t, []float64{2}, d.Q)
+	assert.Equal(t, 4, d.N)
+	assert.InEpsilon(t, 2.2, d.Mean, .0001)
+
+	// equal weights match Populate
+	d1, _ := NewDesc(nil, "test")
+	d1.Populate(x, true, nil)
+	d2, _ := NewDesc(nil, "test")
+	e = d2.PopulateWeighted(x, []float64{1, 1, 1, 1}, nil)
+	assert.Nil(t, e)
+	assert.Equal(t, d1.Q, d2.Q)
+	assert.Equal(t, d1.Mean, d2.Mean)
+
+	assert.NotNil(t, d.PopulateWeighted(x, w[1:], nil))
+	assert.NotNil(t, d.PopulateWeighted(x, []float64{1, -1, 1, 1}, nil))
+}
+
 func TestAllocRaw(t *testing.T) {
 	n := 100
 	x := AllocRaw(n, reflect.Float64)
@@ -105,15 +131,60 @@ func TestLevels_TopK(t *testing.T) {
 	r := NewRaw(x, nil)
 	m := ByCounts(r, nil)
 	s := m.TopK(2, true, true)
-	exp := `Field Value   Count
-a             1
-b             2
+	exp := `Field Value   Count   Share
+a             1       14.29%
+b             2       28.57%
 `
 	assert.Equal(t, s, exp)
 	s = m.TopK(2, false, false)
-	exp = `Field Value   Count
-c             3
-b             2
+	exp = `Field Value   Count   Share
+c             3       42.86%
+b             2       28.57%
 `
 	assert.Equal(t, s, exp)
+
+	// Describe reports the shares of a discrete field
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(r, "x", nil, false))
+	assert.Contains(t, gd.Get("x").Describe(2), "42.86%")
+}
+
+func TestByWeights(t *testing.T) {
+	x := []any{"z", "b", "a", "b", "c", "c", "c"}
+	w := []any{1.0, 2.0, 3.0, 4.0, 0.5, 0.5, 1.0}
+	m, e := ByWeights(NewRaw(x, nil), NewRaw(w, nil), nil)
+	assert.Nil(t, e)
+
+	exp := WLevels{"z": 1, "b": 6, "a": 3, "c": 2}
+	assert.Equal(t, exp, m)
+	assert.Equal(t, 12.0, m.Total())
+	assert.Equal(t, 0.5, m.Shares()["b"])
+
+	assert.Equal(t, 3.0/7.0, ByCounts(NewRaw(x, nil), nil).Shares()["c"])
+
+	s := m.TopK(2, false, false)
+	exp1 := "Field Value     Weight     Share      \n" +
+		"b               6.00       50.00%     \n" +
+		"a               3.00       25.00%     \n"
+	assert.Equal(t, exp1, s)
+
+	keys, vals := m.Sort(true, true)
+	assert.Equal(t, []any{"a", "b", "c", "z"}, keys)
+	assert.Equal(t, []float64{3, 6, 2, 1}, vals)
+
+	_, e = ByWeights(NewRaw(x, nil), NewRaw(w[1:], nil), nil)
+	assert.NotNil(t, e)
+}
+
+func TestGData_DescribeWeighted(t *testing.T) {
+	gd := getData(t)
+
+	s, e := gd.DescribeWeighted("x2", "x1", 0)
+	assert.Nil(t, e)
+	assert.Contains(t, s, "weighted by x1")
+	// a has weight 1+4+8+9+10 = 32 out of 37
+	assert.Contains(t, s, "86.49%")
+
+	_, e = gd.DescribeWeighted("x1", "x1", 0)
+	assert.NotNil(t, e)
 }
@@ -0,0 +1,197 @@
+package seafan
+
+// dbexport.go writes pipelines to databases other than ClickHouse using database/sql
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/invertedv/chutils"
+)
+
+// Dialect is the SQL dialect of the database PipeToDB writes to
+//
+//go:generate stringer -type=Dialect
+type Dialect int
+
+const (
+	Postgres Dialect = 0 + iota
+	SQLite
+)
+
+// DBOpts sets an option of PipeToDB
+type DBOpts func(de *dbExport)
+
+// dbExport holds the options of PipeToDB
+type dbExport struct {
+	dialect    Dialect
+	insertRows int // maximum rows per INSERT statement
+}
+
+// WithInsertRows sets the maximum number of rows PipeToDB inserts with each INSERT statement.  The default is 1000.
+// Fewer are used if needed to keep within the limit of the dialect on the number of parameters of a statement.
+func WithInsertRows(rows int) DBOpts {
+	return func(de *dbExport) {
+		if rows > 0 {
+			de.insertRows = rows
+		}
+	}
+}
+
+// PipeToDB creates table in db and saves the pipe data to it.  An existing table of that name is dropped.  db may be
+// any database/sql database whose driver speaks dialect, e.g. Postgres (github.com/lib/pq, github.com/jackc/pgx)
+// or SQLite (github.com/mattn/go-sqlite3, modernc.org/sqlite).  The fields are those of TableSpec: FRCts fields are
+// double precision and FRCat fields are integer, text or date, as their levels are.  The rows are inserted in bulk
+// within a single transaction; nothing is saved if there's an error.  See PipeToSQL for ClickHouse.
+func PipeToDB(pipe Pipeline, db *sql.DB, table string, dialect Dialect, opts ...DBOpts) error {
+	de := &dbExport{dialect: dialect, insertRows: 1000}
+	for _, opt := range opts {
+		opt(de)
+	}
+
+	if table == "" {
+		return Wrapper(ErrPipe, "PipeToDB: table cannot be empty")
+	}
+
+	gd := pipe.GData()
+
+	td := gd.TableSpec()
+	if td == nil {
+		return Wrapper(ErrPipe, "PipeToDB: fields have unsupported types")
+	}
+
+	create, e := de.createSQL(table, td)
+	if e != nil {
+		return e
+	}
+
+	// a row must fit in a statement
+	cols := len(td.FieldDefs)
+	if cols > de.maxParams() {
+		return Wrapper(ErrPipe, fmt.Sprintf("PipeToDB: %d fields is more than the %d parameters a statement may have",
+			cols, de.maxParams()))
+	}
+
+	tx, e := db.Begin()
+	if e != nil {
+		return Wrapper(e, "PipeToDB")
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	if _, e := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(table))); e != nil {
+		return Wrapper(e, "PipeToDB")
+	}
+
+	if _, e := tx.Exec(create); e != nil {
+		return Wrapper(e, "PipeToDB")
+	}
+
+	rows := de.insertRows
+
+	if maxRows := de.maxParams() / cols; rows > maxRows {
+		rows = maxRows
+	}
+
+	// stream the rows, so the raw data of the fields isn't created
+	stream := gd.NewStream()
+
+	for {
+		data, _, err := stream.Read(rows, false)
+		if len(data) > 0 {
+			args := make([]any, 0, len(data)*cols)
+			for _, row := range data {
+				args = append(args, row...)
+			}
+
+			if _, e := tx.Exec(de.insertSQL(table, td, len(data)), args...); e != nil {
+				return Wrapper(e, "PipeToDB")
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		// the deferred Rollback discards the rows inserted so far
+		if err != nil {
+			return Wrapper(err, "PipeToDB")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// maxParams is the maximum number of parameters of a statement in the dialect
+func (de *dbExport) maxParams() int {
+	if de.dialect == SQLite {
+		return 999
+	}
+
+	return 65535
+}
+
+// createSQL returns the CREATE TABLE statement for the fields of td
+func (de *dbExport) createSQL(table string, td *chutils.TableDef) (string, error) {
+	defs := make([]string, len(td.FieldDefs))
+
+	for ind := 0; ind < len(td.FieldDefs); ind++ {
+		fd := td.FieldDefs[ind]
+
+		var typ string
+
+		switch fd.ChSpec.Base {
+		case chutils.ChFloat:
+			typ = map[Dialect]string{Postgres: "DOUBLE PRECISION", SQLite: "REAL"}[de.dialect]
+		case chutils.ChInt:
+			typ = "INTEGER"
+			if fd.ChSpec.Length == 64 {
+				typ = "BIGINT"
+			}
+		case chutils.ChString:
+			typ = "TEXT"
+		case chutils.ChDate:
+			typ = map[Dialect]string{Postgres: "DATE", SQLite: "TEXT"}[de.dialect]
+		default:
+			return "", Wrapper(ErrPipe, fmt.Sprintf("PipeToDB: field %s has unsupported type", fd.Name))
+		}
+
+		defs[ind] = fmt.Sprintf("%s %s", quoteIdent(fd.Name), typ)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(table), strings.Join(defs, ", ")), nil
+}
+
+// insertSQL returns the INSERT statement for rows rows of the fields of td
+func (de *dbExport) insertSQL(table string, td *chutils.TableDef, rows int) string {
+	cols := len(td.FieldDefs)
+	names := make([]string, cols)
+
+	for ind := 0; ind < cols; ind++ {
+		names[ind] = quoteIdent(td.FieldDefs[ind].Name)
+	}
+
+	values := make([]string, rows)
+	params := make([]string, cols)
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			params[col] = "?"
+			if de.dialect == Postgres {
+				params[col] = fmt.Sprintf("$%d", row*cols+col+1)
+			}
+		}
+
+		values[row] = "(" + strings.Join(params, ", ") + ")"
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdent(table), strings.Join(names, ", "),
+		strings.Join(values, ", "))
+}
+
+// quoteIdent quotes the SQL identifier name
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,147 @@
+package seafan
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeToDB_SQL(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	td := pipe.GData().TableSpec()
+	assert.NotNil(t, td)
+
+	pg := &dbExport{dialect: Postgres}
+	create, e := pg.createSQL("out", td)
+	assert.Nil(t, e)
+	assert.Contains(t, create, `CREATE TABLE "out" (`)
+	assert.Contains(t, create, `"Field3" DOUBLE PRECISION`)
+	assert.Contains(t, create, `"Field1" TEXT`)
+
+	cols := len(td.FieldDefs)
+	ins := pg.insertSQL("out", td, 2)
+	assert.Contains(t, ins, `INSERT INTO "out" ("`)
+	assert.Contains(t, ins, "($1, ")
+	assert.Contains(t, ins, "$"+fmt.Sprint(2*cols)+")")
+
+	lite := &dbExport{dialect: SQLite}
+	create, e = lite.createSQL("out", td)
+	assert.Nil(t, e)
+	assert.Contains(t, create, `"Field3" REAL`)
+	assert.NotContains(t, lite.insertSQL("out", td, 2), "$")
+
+	assert.Equal(t, `"a""b"`, quoteIdent(`a"b`))
+	assert.Equal(t, "SQLite", SQLite.String())
+}
+
+// fakeDB is a database/sql driver that records the statements executed on it
+type fakeDB struct {
+	failOn     string // Exec fails for statements that start with failOn, if not ""
+	execs      []string
+	args       int // total # of arguments of the INSERT statements
+	committed  bool
+	rolledBack bool
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return db }
+func (db *fakeDB) Open(string) (driver.Conn, error)             { return db, nil }
+func (db *fakeDB) Close() error                                 { return nil }
+func (db *fakeDB) Begin() (driver.Tx, error)                    { return db, nil }
+func (db *fakeDB) Commit() error                                { db.committed = true; return nil }
+func (db *fakeDB) Rollback() error                              { db.rolledBack = !db.committed; return nil }
+
+func (db *fakeDB) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: db, query: query}, nil
+}
+
+// fakeStmt is a statement of fakeDB
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (st *fakeStmt) Close() error  { return nil }
+func (st *fakeStmt) NumInput() int { return -1 }
+
+func (st *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if st.db.failOn != "" && strings.HasPrefix(st.query, st.db.failOn) {
+		return nil, fmt.Errorf("exec failed")
+	}
+
+	st.db.execs = append(st.db.execs, st.query)
+	if strings.HasPrefix(st.query, "INSERT") {
+		st.db.args += len(args)
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (st *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func TestPipeToDB(t *testing.T) {
+	n := 2500
+	x, c := make([]any, n), make([]any, n)
+
+	for ind := 0; ind < n; ind++ {
+		x[ind], c[ind] = float64(ind), fmt.Sprintf("c%d", ind%3)
+	}
+
+	pipe, e := VecFromAny([][]any{x, c}, []string{"x", "c"}, nil)
+	assert.Nil(t, e)
+
+	// SQLite allows 999 parameters, so there are 499 rows per INSERT
+	fake := &fakeDB{}
+	db := sql.OpenDB(fake)
+	assert.Nil(t, PipeToDB(pipe, db, "out", SQLite))
+	assert.True(t, fake.committed)
+	assert.Equal(t, 2*n, fake.args)
+	assert.Equal(t, 2+(n+498)/499, len(fake.execs))
+	assert.Contains(t, fake.execs[0], "DROP TABLE")
+
+	// a failed INSERT is returned and nothing is committed
+	fake = &fakeDB{failOn: "INSERT"}
+	assert.NotNil(t, PipeToDB(pipe, sql.OpenDB(fake), "out", SQLite))
+	assert.False(t, fake.committed)
+	assert.True(t, fake.rolledBack)
+
+	// sequence fields are not exported
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "b", "a"}, nil), "id", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{3.0, 1.0, 1.0, 2.0, 2.0}, nil), "month", false, nil, false))
+	assert.Nil(t, gd.MakeSequence("seq", "id", "month", 2, "month"))
+
+	fake = &fakeDB{}
+	assert.Nil(t, PipeToDB(NewVecData("seq", gd), sql.OpenDB(fake), "out", SQLite))
+	assert.True(t, fake.committed)
+	assert.Equal(t, 2*5, fake.args)
+
+	// a row of a wide table does not fit in an SQLite statement
+	cols, fields := make([][]any, 1000), make([]string, 1000)
+	for ind := range cols {
+		cols[ind], fields[ind] = []any{1.0, 2.0}, fmt.Sprintf("x%d", ind)
+	}
+
+	wide, e := VecFromAny(cols, fields, nil)
+	assert.Nil(t, e)
+
+	fake = &fakeDB{}
+	assert.NotNil(t, PipeToDB(wide, sql.OpenDB(fake), "out", SQLite))
+	assert.False(t, fake.committed)
+	assert.Equal(t, 0, len(fake.execs))
+
+	assert.Nil(t, PipeToDB(wide, sql.OpenDB(&fakeDB{}), "out", Postgres))
+}
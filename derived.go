@@ -0,0 +1,76 @@
+package seafan
+
+// derived.go implements fields calculated from expressions that are added to a Pipeline whenever its data is loaded
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DerivedFields maps the names of calculated fields to the expressions that define them. For example,
+//
+//	DerivedFields{"ltv": "balance / value", "ltvHigh": "if(ltv > 0.8, 'yes', 'no')"}
+//
+// Attached to a Pipeline by WithDerived, the fields are evaluated and added each time the data is loaded or
+// refreshed, so they need not be calculated in a callback.  A derived field may use other derived fields.
+type DerivedFields map[string]string
+
+// Fields returns the names of the derived fields in sorted order
+func (df DerivedFields) Fields() []string {
+	flds := make([]string, 0, len(df))
+	for fld := range df {
+		flds = append(flds, fld)
+	}
+
+	sort.Strings(flds)
+
+	return flds
+}
+
+// Apply evaluates the derived fields on gd and adds them to it.  Fields in fts fix the FParam (e.g. levels) of
+// the derived field; otherwise they're calculated from the data.  Derived fields are evaluated in sorted order,
+// deferring those that use derived fields not yet calculated.
+func (df DerivedFields) Apply(gd *GData, fts FTypes, keepRaw bool) error {
+	roots := make(map[string]*OpNode)
+	for fld, expr := range df {
+		root := &OpNode{Expression: expr}
+		if e := Expr2Tree(root); e != nil {
+			return Wrapper(ErrPipe, fmt.Sprintf("derived field %s: %v", fld, e))
+		}
+
+		roots[fld] = root
+	}
+
+	// a field is dropped in case it's a derived field that was calculated on an earlier load
+	for _, fld := range df.Fields() {
+		_ = gd.Drop(fld)
+	}
+
+	todo := df.Fields()
+	for len(todo) > 0 {
+		var (
+			left    []string
+			lastErr error
+		)
+
+		for _, fld := range todo {
+			if e := EvaluateGD(roots[fld], gd); e != nil {
+				left, lastErr = append(left, fld), Wrapper(ErrPipe, fmt.Sprintf("derived field %s: %v", fld, e))
+				continue
+			}
+
+			if e := addToGData(roots[fld], fld, gd, fts.Get(fld), keepRaw); e != nil {
+				return Wrapper(ErrPipe, fmt.Sprintf("derived field %s: %v", fld, e))
+			}
+		}
+
+		// no progress -- the errors are not due to the order of evaluation
+		if len(left) == len(todo) {
+			return lastErr
+		}
+
+		todo = left
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	G "gorgonia.org/gorgonia"
+)
+
+func TestDerivedFields_Apply(t *testing.T) {
+	Verbose = false
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4}, nil), "x", false, nil, false))
+
+	// z uses y, which is evaluated after it in sorted order
+	df := DerivedFields{"y": "x * 2", "z": "y + x", "big": "if(z > 6, 'yes', 'no')"}
+	assert.Equal(t, []string{"big", "y", "z"}, df.Fields())
+
+	fts := FTypes{{Name: "y", Role: FRCts, Normalized: true, FP: &FParam{Location: 1, Scale: 2}}}
+	assert.Nil(t, df.Apply(gd, fts, false))
+
+	raw, e := gd.GetRaw("z")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{3.0, 6.0, 9.0, 12.0}, raw.Data)
+
+	// FTypes supply the FParam
+	assert.True(t, gd.GetFType("y").Normalized)
+	assert.InDeltaSlice(t, []float64{0.5, 1.5, 2.5, 3.5}, gd.Get("y").Data, 1e-10)
+
+	assert.Equal(t, FRCat, gd.GetFType("big").Role)
+	assert.Equal(t, 2, gd.GetFType("big").Cats)
+
+	// reapplying replaces the fields
+	assert.Nil(t, df.Apply(gd, nil, false))
+	assert.Equal(t, 4, gd.FieldCount())
+
+	assert.NotNil(t, DerivedFields{"w": "q + 1"}.Apply(gd, nil, false))
+	assert.NotNil(t, DerivedFields{"a": "b", "b": "a"}.Apply(gd, nil, false))
+	assert.NotNil(t, DerivedFields{"w": "x +* 1"}.Apply(gd, nil, false))
+}
+
+func TestWithDerived(t *testing.T) {
+	Verbose = false
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4}, nil), "x", false, nil, false))
+
+	// the callback changes x, the derived field follows
+	cb := func(p Pipeline) {
+		x := make([]float64, p.Rows())
+		for ind := range x {
+			x[ind] = p.Get("x").Data.([]float64)[ind] + 1
+		}
+
+		assert.Nil(t, p.Drop("x"))
+		assert.Nil(t, p.GData().AppendC(NewRawCast(x, nil), "x", false, nil, false))
+	}
+
+	pipe := NewVecData("derived", gd, WithBatchSize(4), WithCallBack(cb),
+		WithDerived(DerivedFields{"x2": "x * x"}))
+	assert.Nil(t, pipe.Init())
+	assert.Equal(t, []float64{1, 4, 9, 16}, pipe.Get("x2").Data)
+
+	nd := G.NewTensor(G.NewGraph(), G.Float64, 2, G.WithName("x2"), G.WithShape(4, 1), G.WithInit(G.Zeroes()))
+	assert.True(t, pipe.Batch(G.Nodes{nd}))
+	assert.False(t, pipe.Batch(G.Nodes{nd}))
+	assert.Equal(t, []float64{4, 9, 16, 25}, pipe.Get("x2").Data)
+
+	data := os.Getenv("data")
+	csv, e := CSVToPipe(data+"/pipeTest1.csv", nil, false, WithDerived(DerivedFields{"f": "Field3 * row"}))
+	assert.Nil(t, e)
+
+	raw, e := csv.GData().GetRaw("f")
+	assert.Nil(t, e)
+	assert.InDelta(t, 3.0, raw.Data[0].(float64), 1e-10)
+	assert.InDelta(t, 4.4, raw.Data[1].(float64), 1e-10)
+
+	_, e = CSVToPipe(data+"/pipeTest1.csv", nil, false, WithDerived(DerivedFields{"f": "nope * row"}))
+	assert.NotNil(t, e)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/invertedv/utilities"
 
@@ -94,6 +95,95 @@ func Coalesce(vals []float64, nCat int, trg []int, binary, logodds bool, sl Slic
 	return coalesced, nil
 }
 
+// ClassGroups maps the name of a group of classes to the levels of the target field in the group, for instance
+// {"bad": {"D90", "D120"}, "good": {"current"}}.
+type ClassGroups map[string][]any
+
+// CoalesceGroups combines the columns of a softmax output, vals, into the groups of classes in groups.  ft is the
+// FRCat field whose levels are the columns of vals (for a one-hot target, the field it is made from).  A level may
+// be in at most one group.
+//
+// If renorm is true, the output is renormalized over the classes that are in a group: each group is divided by the
+// sum over all the groups, so the groups sum to 1 and classes in no group are dropped.  A row whose groups sum to 0
+// is NaN.
+//
+// The result has a field for each group, in alphabetical order, with a row for each row of vals.
+func CoalesceGroups(vals []float64, ft *FType, groups ClassGroups, renorm bool) (Pipeline, error) {
+	if ft == nil || ft.Role != FRCat || ft.FP == nil || ft.FP.Lvl == nil {
+		return nil, Wrapper(ErrDiags, "CoalesceGroups: ft must be an FRCat field with levels")
+	}
+
+	if len(groups) == 0 {
+		return nil, Wrapper(ErrDiags, "CoalesceGroups: no groups")
+	}
+
+	nCat := ft.Cats
+	if nCat < 1 || len(vals)%nCat != 0 {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("CoalesceGroups: length of vals is not a multiple of %d levels of %s", nCat, ft.Name))
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// the columns of each group.  The Default level is not used to find the levels.
+	fp := &FParam{Lvl: ft.FP.Lvl}
+	cols := make([][]int, len(names))
+	inGroup := make(map[int32]string)
+
+	for ind, name := range names {
+		if len(groups[name]) == 0 {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("CoalesceGroups: group %s is empty", name))
+		}
+
+		for _, lvl := range groups[name] {
+			col, ok := lookupLevel(fp, lvl)
+			if !ok {
+				return nil, Wrapper(ErrDiags, fmt.Sprintf("CoalesceGroups: %v is not a level of %s", lvl, ft.Name))
+			}
+
+			if other, ok := inGroup[col]; ok {
+				return nil, Wrapper(ErrDiags, fmt.Sprintf("CoalesceGroups: level %v is in groups %s and %s", lvl, other, name))
+			}
+
+			inGroup[col] = name
+			cols[ind] = append(cols[ind], int(col))
+		}
+	}
+
+	n := len(vals) / nCat
+	out := make([][]any, len(names))
+
+	for row := 0; row < n; row++ {
+		grp := make([]float64, len(names))
+		tot := 0.0
+
+		for ind := range names {
+			for _, col := range cols[ind] {
+				grp[ind] += vals[row*nCat+col]
+			}
+
+			tot += grp[ind]
+		}
+
+		for ind, g := range grp {
+			if renorm {
+				g = g / tot
+				if tot == 0.0 {
+					g = math.NaN()
+				}
+			}
+
+			out[ind] = append(out[ind], g)
+		}
+	}
+
+	return VecFromAny(out, names, nil)
+}
+
 // KS finds the KS of a softmax model that is reduced to a binary outcome.
 //
 //	xy        XY struct where x is fitted value and y is the binary observed value
@@ -215,7 +305,7 @@ func KS(xy *XY, plt *utilities.PlotDef) (ks float64, notTarget *Desc, target *De
 
 		lay := &grob.Layout{}
 		lay.Legend = &grob.LayoutLegend{X: target.Q[0], Y: 1.0}
-		err = utilities.Plotter(fig, lay, plt)
+		err = plotter(fig, lay, plt)
 	}
 	return ks, notTarget, target, err
 }
@@ -228,12 +318,44 @@ func KS(xy *XY, plt *utilities.PlotDef) (ks float64, notTarget *Desc, target *De
 //	    seg       segmenting field name
 //		plt       PlotDef plot options.  If plt is nil an error is generated.
 func SegPlot(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, minVal, maxVal *float64) error {
+	return SegPlotWith(pipe, obs, fit, seg, plt, &SegPlotOpts{MinVal: minVal, MaxVal: maxVal})
+}
+
+// SegPlotWeighted is SegPlot with the segments of a continuous seg field formed from quantiles weighted by the
+// field weight. For instance, if weight is the loan balance, each segment has roughly the same balance.
+func SegPlotWeighted(pipe Pipeline, obs, fit, seg, weight string, plt *utilities.PlotDef, minVal, maxVal *float64) error {
+	return SegPlotWith(pipe, obs, fit, seg, plt, &SegPlotOpts{Weight: weight, MinVal: minVal, MaxVal: maxVal})
+}
+
+// SegPlotOpts are the options for SegPlotWith.
+type SegPlotOpts struct {
+	Weight      string    // if not "", the quantiles of a continuous seg are weighted by this field
+	Target      []int     // levels (FRCat) or columns (FROneHot) of a categorical obs that are the event
+	SizeByCount bool      // if true, the marker sizes are proportional to the square root of the segment counts
+	MinVal      *float64  // if not nil, the lower limit of the reference line
+	MaxVal      *float64  // if not nil, the upper limit of the reference line
+	Bins        int       // if > 0, a continuous seg is sliced into this many quantile bins (see WithQuantileBins)
+	Cuts        []float64 // if not nil, a continuous seg is sliced at these cut points (see WithCuts)
+	TopK        int       // if > 0, only the TopK most frequent levels of a discrete seg are used (see WithTopK)
+}
+
+// SegPlotWith is SegPlot with options.
+//
+// obs may be FRCts or an event indicator: FRCat or FROneHot. For the latter, the observed value is 1 if obs is
+// one of the levels in opts.Target (if Target is nil and obs has two levels, the event is level 1).
+// The confidence intervals are then Wilson binomial intervals rather than normal intervals.
+// fit must be FRCts (e.g. the fitted probability of the event).
+func SegPlotWith(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, opts *SegPlotOpts) error {
 	const minCnt = 100 // min # of obs for each point
 
 	if plt == nil {
 		return Wrapper(ErrDiags, "Decile: plt cannot be nil")
 	}
 
+	if opts == nil {
+		opts = &SegPlotOpts{}
+	}
+
 	fitFtype := pipe.GetFType(fit)
 	if fitFtype == nil {
 		return Wrapper(ErrDiags, fmt.Sprintf("no such field: %s", fit))
@@ -244,19 +366,47 @@ func SegPlot(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, minVal
 		return Wrapper(ErrDiags, fmt.Sprintf("no such field: %s", obs))
 	}
 
-	if fitFtype.Role != FRCts || obsFit.Role != FRCts {
+	if fitFtype.Role != FRCts {
 		return Wrapper(ErrDiags, "decile Inputs must be type FRCts")
 	}
 
-	sliceGrp, e := NewSlice(seg, minCnt, pipe, nil)
+	trg, e := obsTarget(obsFit, opts.Target)
+	if e != nil {
+		return Wrapper(e, "SegPlot")
+	}
+
+	var sliceGrp *Slice
+
+	sliceOpts := []SliceOpts{WithQuantileBins(opts.Bins), WithCuts(opts.Cuts...), WithTopK(opts.TopK)}
+
+	switch opts.Weight {
+	case "":
+		sliceGrp, e = NewSlice(seg, minCnt, pipe, nil, sliceOpts...)
+	default:
+		sliceGrp, e = NewSliceWeighted(seg, opts.Weight, minCnt, pipe, nil, sliceOpts...)
+	}
+
 	if e != nil {
 		return e
 	}
 
-	fig := &grob.Fig{}
+	obsAll, e := segObs(pipe.Get(obs), trg)
+	if e != nil {
+		return e
+	}
+
+	// the points of the plot
+	type segment struct {
+		n                        int
+		label                    string
+		fitMean, obsMean, lo, hi float64
+	}
+
+	var segs []segment
+
 	minV, maxV := math.MaxFloat64, -math.MaxFloat64
-	ind, mad, rowTot := 0, float64(0), float64(0)
-	bias := pipe.Get(fit).Summary.DistrC.Mean - pipe.Get(obs).Summary.DistrC.Mean
+	mad, maxN := float64(0), 0
+	bias := pipe.Get(fit).Summary.DistrC.Mean - obsAll.mean
 
 	for sliceGrp.Iter() {
 		slicer := sliceGrp.MakeSlicer()
@@ -270,24 +420,30 @@ func SegPlot(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, minVal
 			continue
 		}
 
-		nSqrt := math.Sqrt(float64(pipeSlice.Rows()))
+		obsSeg, e := segObs(pipeSlice.Get(obs), trg)
+		if e != nil {
+			return e
+		}
 
-		distr := pipeSlice.Get(obs).Summary.DistrC
-		obsMean, obsStd := distr.Mean, distr.Std/nSqrt
 		fitMean := pipeSlice.Get(fit).Summary.DistrC.Mean - bias
+		lo, hi := obsSeg.ci()
 
-		mad += math.Abs(fitMean - obsMean)
-		rowTot++
-		ci := []float64{obsMean - 2.0*obsStd, obsMean + 2.0*obsStd}
-		maxV = math.Max(maxV, ci[1])
-		minV = math.Min(minV, ci[0])
-		ind++
+		mad += math.Abs(fitMean - obsSeg.mean)
+		maxV = math.Max(maxV, hi)
+		minV = math.Min(minV, lo)
+		maxN = utilities.MaxInt(maxN, pipeSlice.Rows())
 
+		segs = append(segs, segment{n: pipeSlice.Rows(), label: sliceGrp.Label(),
+			fitMean: fitMean, obsMean: obsSeg.mean, lo: lo, hi: hi})
+	}
+
+	fig := &grob.Fig{}
+	for _, sg := range segs {
 		trCI := &grob.Scatter{
 			Type:       grob.TraceTypeScatter,
-			X:          []float64{fitMean, fitMean},
-			Y:          ci,
-			Name:       fmt.Sprintf("%d: %v", pipeSlice.Rows(), sliceGrp.Value()),
+			X:          []float64{sg.fitMean, sg.fitMean},
+			Y:          []float64{sg.lo, sg.hi},
+			Name:       fmt.Sprintf("%d: %s", sg.n, sg.label),
 			Hoverlabel: &grob.ScatterHoverlabel{Namelength: -1},
 			Mode:       grob.ScatterModeLines,
 			Line:       &grob.ScatterLine{Color: "black"},
@@ -296,22 +452,29 @@ func SegPlot(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, minVal
 
 		tr := &grob.Scatter{
 			Type:       grob.TraceTypeScatter,
-			X:          []float64{fitMean},
-			Y:          []float64{obsMean},
-			Name:       fmt.Sprintf("%v", sliceGrp.Value()),
+			X:          []float64{sg.fitMean},
+			Y:          []float64{sg.obsMean},
+			Name:       sg.label,
 			Hoverlabel: &grob.ScatterHoverlabel{Namelength: -1},
 			Mode:       grob.ScatterModeMarkers,
 			Line:       &grob.ScatterLine{Color: "green"},
 		}
+
+		if opts.SizeByCount {
+			const minSize, maxSize = 6.0, 30.0
+			tr.Marker = &grob.ScatterMarker{Color: "green",
+				Size: minSize + (maxSize-minSize)*math.Sqrt(float64(sg.n)/float64(maxN))}
+		}
+
 		fig.AddTraces(tr)
 	}
 
 	// if user has supplied graph limits, use them
-	if minVal != nil {
-		minV = *minVal
+	if opts.MinVal != nil {
+		minV = *opts.MinVal
 	}
-	if maxVal != nil {
-		maxV = *maxVal
+	if opts.MaxVal != nil {
+		maxV = *opts.MaxVal
 	}
 
 	tr := &grob.Scatter{
@@ -324,7 +487,7 @@ func SegPlot(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, minVal
 	}
 	fig.AddTraces(tr)
 
-	mad /= rowTot
+	mad /= float64(len(segs))
 	plt.STitle = fmt.Sprintf("MAD (unbiased fit): %0.4f Bias: %0.4f", mad, bias)
 
 	if plt.XTitle == "" {
@@ -340,11 +503,238 @@ func SegPlot(pipe Pipeline, obs, fit, seg string, plt *utilities.PlotDef, minVal
 	}
 	plt.Title = fmt.Sprintf("%s<br>%s", plt.Title, "Bias Corrected")
 
-	err := utilities.Plotter(fig, &grob.Layout{}, plt)
+	err := plotter(fig, &grob.Layout{}, plt)
 
 	return err
 }
 
+// segStat is the mean of the observed field of a segment
+type segStat struct {
+	n      int
+	mean   float64
+	std    float64 // std dev of the observed field, not the mean
+	binary bool    // the observed field is an event indicator
+}
+
+// obsTarget checks that ft can be an observed field and returns the target levels.  If ft is categorical and trg
+// is nil, the target is level 1 of a field with two levels.
+func obsTarget(ft *FType, trg []int) ([]int, error) {
+	switch ft.Role {
+	case FRCts:
+		return nil, nil
+	case FRCat, FROneHot:
+		if trg == nil && ft.Cats == 2 {
+			return []int{1}, nil
+		}
+
+		if trg == nil {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("need target levels for field %s with %d levels", ft.Name, ft.Cats))
+		}
+
+		return trg, nil
+	}
+
+	return nil, Wrapper(ErrDiags, fmt.Sprintf("observed field %s must be FRCts, FRCat or FROneHot", ft.Name))
+}
+
+// obsValues returns the values of the observed field d.  If d is categorical, the value is 1 if d is one of the
+// levels in trg and 0 otherwise.
+func obsValues(d *GDatum, trg []int) ([]float64, error) {
+	switch d.FT.Role {
+	case FRCts:
+		return UnNormalize(append([]float64{}, d.Floats()...), d.FT), nil
+	case FRCat:
+		events := make([]float64, len(d.Data.([]int32)))
+		for ind, lvl := range d.Data.([]int32) {
+			for _, t := range trg {
+				if int(lvl) == t {
+					events[ind] = 1.0
+				}
+			}
+		}
+
+		return events, nil
+	case FROneHot:
+		return Coalesce(d.Data.([]float64), d.FT.Cats, trg, true, false, nil)
+	}
+
+	return nil, Wrapper(ErrDiags, fmt.Sprintf("observed field %s must be FRCts, FRCat or FROneHot", d.FT.Name))
+}
+
+// segObs finds the mean of d.  If d is categorical, the mean is the share of rows that are one of the levels in trg.
+func segObs(d *GDatum, trg []int) (*segStat, error) {
+	if d.FT.Role == FRCts {
+		return &segStat{n: d.Summary.NRows, mean: d.Summary.DistrC.Mean, std: d.Summary.DistrC.Std}, nil
+	}
+
+	events, e := obsValues(d, trg)
+	if e != nil {
+		return nil, e
+	}
+
+	ss := &segStat{n: len(events), binary: true}
+	for _, ev := range events {
+		ss.mean += ev
+	}
+
+	ss.mean /= float64(ss.n)
+
+	return ss, nil
+}
+
+// ci returns the 2 standard deviation confidence interval of the mean.  For an event indicator, this is the Wilson
+// interval, which stays within [0, 1].
+func (ss *segStat) ci() (lo, hi float64) {
+	const z = 2.0
+
+	n := float64(ss.n)
+	if !ss.binary {
+		return ss.mean - z*ss.std/math.Sqrt(n), ss.mean + z*ss.std/math.Sqrt(n)
+	}
+
+	p := ss.mean
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	half := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+
+	return center - half, center + half
+}
+
+// TimeSeriesPlot plots the means of the observed and fitted values over time, aggregated by the period of dateField.
+//
+//	obs        observed field name.  As in SegPlotWith, a categorical field with two levels is an event indicator
+//	           for level 1.
+//	fit        fitted field name (FRCts)
+//	dateField  date field name. The raw values must be time.Time.
+//	freq       aggregation period: month, quarter or year
+//	plt        PlotDef plot options.  If plt is nil an error is generated.
+//
+// The observed means are plotted with their 2 standard deviation confidence intervals (Wilson intervals for event
+// indicators).
+func TimeSeriesPlot(pipe Pipeline, obs, fit, dateField, freq string, plt *utilities.PlotDef) error {
+	if plt == nil {
+		return Wrapper(ErrDiags, "TimeSeriesPlot: plt cannot be nil")
+	}
+
+	obsD, fitD := pipe.Get(obs), pipe.Get(fit)
+	if obsD == nil || fitD == nil {
+		return Wrapper(ErrDiags, fmt.Sprintf("TimeSeriesPlot: no such field: %s or %s", obs, fit))
+	}
+
+	if fitD.FT.Role != FRCts {
+		return Wrapper(ErrDiags, fmt.Sprintf("TimeSeriesPlot: fit field %s must be FRCts", fit))
+	}
+
+	trg, e := obsTarget(obsD.FT, nil)
+	if e != nil {
+		return Wrapper(e, "TimeSeriesPlot")
+	}
+
+	obsV, e := obsValues(obsD, trg)
+	if e != nil {
+		return Wrapper(e, "TimeSeriesPlot")
+	}
+
+	fitV := UnNormalize(append([]float64{}, fitD.Floats()...), fitD.FT)
+
+	dates, e := panelDates(pipe, dateField)
+	if e != nil {
+		return Wrapper(e, "TimeSeriesPlot")
+	}
+
+	// aggregate by period
+	type period struct {
+		n                  int
+		obsSum, obsSq, fit float64
+	}
+
+	periods := make(map[string]*period)
+
+	for row, dt := range dates {
+		key, e := periodLabel(dt, freq)
+		if e != nil {
+			return Wrapper(e, "TimeSeriesPlot")
+		}
+
+		p, ok := periods[key]
+		if !ok {
+			p = &period{}
+			periods[key] = p
+		}
+
+		p.n++
+		p.obsSum += obsV[row]
+		p.obsSq += obsV[row] * obsV[row]
+		p.fit += fitV[row]
+	}
+
+	keys := make([]string, 0, len(periods))
+	for k := range periods {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	obsMean, fitMean, lo, hi := make([]float64, len(keys)), make([]float64, len(keys)), make([]float64, len(keys)),
+		make([]float64, len(keys))
+
+	for ind, k := range keys {
+		p := periods[k]
+		n := float64(p.n)
+		ss := &segStat{n: p.n, mean: p.obsSum / n, binary: trg != nil}
+
+		if p.n > 1 {
+			ss.std = math.Sqrt(math.Max(0, (p.obsSq-n*ss.mean*ss.mean)/(n-1)))
+		}
+
+		obsMean[ind], fitMean[ind] = ss.mean, p.fit/n
+		lo[ind], hi[ind] = ss.ci()
+	}
+
+	fig := &grob.Fig{}
+	fig.AddTraces(
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: keys, Y: obsMean, Name: "observed",
+			Mode: grob.ScatterModeLines + "+" + grob.ScatterModeMarkers, Line: &grob.ScatterLine{Color: "black"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: keys, Y: fitMean, Name: "fitted",
+			Mode: grob.ScatterModeLines + "+" + grob.ScatterModeMarkers, Line: &grob.ScatterLine{Color: "red"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: keys, Y: lo, Name: "observed lower CI",
+			Mode: grob.ScatterModeLines, Line: &grob.ScatterLine{Color: "grey", Dash: "dot"}},
+		&grob.Scatter{Type: grob.TraceTypeScatter, X: keys, Y: hi, Name: "observed upper CI",
+			Mode: grob.ScatterModeLines, Line: &grob.ScatterLine{Color: "grey", Dash: "dot"}},
+	)
+
+	plt.Legend = true
+	plt.STitle = fmt.Sprintf("Mean observed: %0.4f Mean fitted: %0.4f", stat.Mean(obsV, nil), stat.Mean(fitV, nil))
+
+	if plt.XTitle == "" {
+		plt.XTitle = dateField
+	}
+
+	if plt.YTitle == "" {
+		plt.YTitle = obs
+	}
+
+	if plt.Title == "" {
+		plt.Title = "Actual vs Predicted"
+	}
+
+	return plotter(fig, &grob.Layout{}, plt)
+}
+
+// periodLabel returns the period that dt falls in as a label that sorts in date order, e.g. 2024-03 (month),
+// 2024-Q1 (quarter), 2024 (year).
+func periodLabel(dt time.Time, freq string) (string, error) {
+	switch freq {
+	case "month":
+		return dt.Format("2006-01"), nil
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", dt.Year(), (int(dt.Month())+2)/3), nil
+	case "year":
+		return dt.Format("2006"), nil
+	}
+
+	return "", Wrapper(ErrDiags, fmt.Sprintf("unknown frequency %s, must be month, quarter or year", freq))
+}
+
 // Decile generates a decile plot based on xy
 //
 //	XY        values to base the plot on.
@@ -467,7 +857,7 @@ func Decile(xyIn *XY, plt *utilities.PlotDef) error {
 		plt.Title = "Decile Plot"
 	}
 
-	err := utilities.Plotter(fig, &grob.Layout{}, plt)
+	err := plotter(fig, &grob.Layout{}, plt)
 
 	return err
 }
@@ -528,6 +918,113 @@ func Assess(xy *XY, cutoff float64) (n int, precision, recall, accuracy float64,
 	return n, precision, recall, accuracy, obs, fit, err
 }
 
+// AssessSweep evaluates a binary classifier at each threshold in thresholds.  If thresholds is nil, the thresholds
+// are 0.01, 0.02, ..., 0.99.  As in Assess, an observation is predicted positive if its fitted value (xy.X) exceeds
+// the threshold and is positive if its observed value (xy.Y) is 1.
+//
+// The sweep has one row per threshold with the fields threshold, precision, recall, f1, accuracy, tpr and fpr.
+// Precision (and so f1) is 0 at thresholds with no predicted positives.
+// bestF1 is the threshold with the highest F1; bestJ is the threshold with the highest Youden's J (tpr - fpr).
+func AssessSweep(xy *XY, thresholds []float64) (sweep Pipeline, bestF1, bestJ float64, err error) {
+	if thresholds == nil {
+		for t := 1; t < 100; t++ {
+			thresholds = append(thresholds, float64(t)/100.0)
+		}
+	}
+
+	if len(thresholds) == 0 {
+		return nil, 0, 0, Wrapper(ErrDiags, "AssessSweep: no thresholds")
+	}
+
+	// sort the fitted values and count the positives above each
+	n := xy.Len()
+	ord := make([]int, n)
+	for ind := range ord {
+		ord[ind] = ind
+	}
+
+	sort.Slice(ord, func(i, j int) bool { return xy.X[ord[i]] < xy.X[ord[j]] })
+
+	fitted := make([]float64, n)
+	posAbove := make([]int, n+1) // # of positives in sorted rows ind, ind+1, ...
+
+	for ind := n - 1; ind >= 0; ind-- {
+		fitted[ind] = xy.X[ord[ind]]
+		posAbove[ind] = posAbove[ind+1]
+
+		if xy.Y[ord[ind]] > 0.999 {
+			posAbove[ind]++
+		}
+	}
+
+	pos := posAbove[0]
+	if pos == 0 {
+		return nil, 0, 0, Wrapper(ErrDiags, "AssessSweep: there are no positive outcomes")
+	}
+
+	if pos == n {
+		return nil, 0, 0, Wrapper(ErrDiags, "AssessSweep: there are no negative outcomes")
+	}
+
+	fields := []string{"threshold", "precision", "recall", "f1", "accuracy", "tpr", "fpr"}
+	cols := make([][]any, len(fields))
+	maxF1, maxJ := -1.0, math.Inf(-1)
+
+	for _, thr := range thresholds {
+		// first row predicted positive
+		first := sort.Search(n, func(i int) bool { return fitted[i] > thr })
+		predPos, truePos := n-first, posAbove[first]
+		falsePos, trueNeg := predPos-truePos, (n-pos)-(predPos-truePos)
+
+		precision := 0.0
+		if predPos > 0 {
+			precision = float64(truePos) / float64(predPos)
+		}
+
+		recall := float64(truePos) / float64(pos)
+		fpr := float64(falsePos) / float64(n-pos)
+		accuracy := float64(truePos+trueNeg) / float64(n)
+
+		f1 := 0.0
+		if precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+
+		if f1 > maxF1 {
+			maxF1, bestF1 = f1, thr
+		}
+
+		if j := recall - fpr; j > maxJ {
+			maxJ, bestJ = j, thr
+		}
+
+		for col, v := range []float64{thr, precision, recall, f1, accuracy, recall, fpr} {
+			cols[col] = append(cols[col], v)
+		}
+	}
+
+	sweep, err = VecFromAny(cols, fields, nil)
+
+	return sweep, bestF1, bestJ, err
+}
+
+// FittedOpts sets an option of AddFitted
+type FittedOpts func(fo *fittedOpts)
+
+// fittedOpts holds the options of AddFitted
+type fittedOpts struct {
+	scoreBatch int // rows scored at a time, 0 means all rows
+}
+
+// WithScoreBatch has AddFitted score n rows at a time rather than all the rows at once, so the model graph holds
+// only n rows.  Every row is scored: if n does not divide the rows, the last batch is padded with copies of its last
+// row and the padding is dropped.
+func WithScoreBatch(n int) FittedOpts {
+	return func(fo *fittedOpts) {
+		fo.scoreBatch = n
+	}
+}
+
 // AddFitted addes fitted values to a Pipeline. The features can be re-normalized/re-mapped to align pipeIn with
 // the model build
 // pipeIn -- input Pipeline to run the model on
@@ -535,20 +1032,44 @@ func Assess(xy *XY, cutoff float64) (n int, precision, recall, accuracy float64,
 // target -- target columns of the model output to coalesce
 // name -- name of fitted value in Pipeline
 // fts -- options FTypes to use for normalizing pipeIn
-func AddFitted(pipeIn Pipeline, nnFile string, target []int, name string, fts FTypes, logodds bool, obsFit *FType) error {
-	// operate on all data
-	bSize := pipeIn.BatchSize()
-	WithBatchSize(0)(pipeIn) // all rows
-	nn1, e := PredictNNwFts(nnFile, pipeIn, false, fts)
-	if e != nil {
-		return e
+// opts -- options (see WithScoreBatch)
+func AddFitted(pipeIn Pipeline, nnFile string, target []int, name string, fts FTypes, logodds bool, obsFit *FType,
+	opts ...FittedOpts) error {
+	fo := &fittedOpts{}
+	for _, o := range opts {
+		o(fo)
 	}
 
-	// Coalesce the output
-	bigFit := nn1.FitSlice()
+	if fo.scoreBatch < 0 {
+		return Wrapper(ErrDiags, fmt.Sprintf("AddFitted: score batch %d must be non-negative", fo.scoreBatch))
+	}
+
+	var (
+		bigFit  []float64
+		outCols int
+	)
+
+	if fo.scoreBatch > 0 {
+		var e error
+		if bigFit, outCols, e = chunkedFit(pipeIn, nnFile, fts, fo.scoreBatch); e != nil {
+			return Wrapper(e, "AddFitted")
+		}
+	} else {
+		// operate on all data
+		bSize := pipeIn.BatchSize()
+		WithBatchSize(0)(pipeIn) // all rows
+		nn1, e := PredictNNwFts(nnFile, pipeIn, false, fts)
+		WithBatchSize(bSize)(pipeIn)
+
+		if e != nil {
+			return e
+		}
 
+		bigFit, outCols = nn1.FitSlice(), nn1.outCols
+	}
+
+	// Coalesce the output
 	fit := make([]float64, pipeIn.Rows())
-	outCols := nn1.outCols // nn1.Cols()
 	for row := 0; row < len(fit); row++ {
 		for _, col := range target {
 			fit[row] += bigFit[row*outCols+col]
@@ -577,11 +1098,23 @@ func AddFitted(pipeIn Pipeline, nnFile string, target []int, name string, fts FT
 		return e
 	}
 
-	WithBatchSize(bSize)(pipeIn)
-
 	return nil
 }
 
+// chunkedFit returns the output of the model saved in nnFile for all the rows of pipeIn, scoring n rows at a time.
+// The data are preprocessed with fts, if it is not nil.
+func chunkedFit(pipeIn Pipeline, nnFile string, fts FTypes, n int) (fit []float64, outCols int, err error) {
+	// a pipeline of its own keeps the cursor and batch size of pipeIn as they are
+	pipe := NewVecData("chunked scoring", pipeIn.GData(), WithBatchSize(n))
+
+	nn, e := PredictNNwFts(nnFile, pipe, false, fts, WithPadBatch())
+	if e != nil {
+		return nil, 0, e
+	}
+
+	return nn.FitSlice(), nn.outCols, nil
+}
+
 // Marginal produces a set of plots to aid in understanding the effect of a feature.
 // The plot takes the model output and creates six segments based on the quantiles of the model output:
 // (<.1, .1-.25, .25-.5, .5-.75, .75-.9, .9-1).
@@ -646,7 +1179,7 @@ func Marginal(nnFile string, feat string, target []int, pipe Pipeline, pd *utili
 			x := make([]float64, n)
 
 			for ind := 0; ind < n; ind++ {
-				x[ind] = gd.Data.([]float64)[ind]*gd.FT.FP.Scale + gd.FT.FP.Location
+				x[ind] = gd.floatAt(ind)*gd.FT.FP.Scale + gd.FT.FP.Location
 			}
 
 			tr := &grob.Histogram{Xaxis: xAxis, Yaxis: yAxis, X: x, Type: grob.TraceTypeHistogram}
@@ -714,7 +1247,8 @@ func Marginal(nnFile string, feat string, target []int, pipe Pipeline, pd *utili
 
 		xAxis, yAxis = fmt.Sprintf("x%d", plotNo-cols), fmt.Sprintf("y%d", plotNo-cols)
 		plotNo--
-		tr := &grob.Box{X: xs1, Y: fit, Type: grob.TraceTypeBox, Xaxis: xAxis, Yaxis: yAxis}
+		tr := &grob.Box{X: xs1, Y: fit, Type: grob.TraceTypeBox, Xaxis: xAxis, Yaxis: yAxis,
+			Name: fmt.Sprintf("fitted %s", slice.Label())}
 
 		fig.AddTraces(tr)
 		if plotNo == cols {
@@ -723,7 +1257,7 @@ func Marginal(nnFile string, feat string, target []int, pipe Pipeline, pd *utili
 	}
 
 	pd.Title = fmt.Sprintf("Marginal Effect of %s by Quartile of Fitted Value (High to Low)<br>%s", name, pd.Title)
-	if e := utilities.Plotter(fig, lay, pd); e != nil {
+	if e := plotter(fig, lay, pd); e != nil {
 		return Wrapper(e, "Marginal")
 	}
 
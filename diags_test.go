@@ -1,10 +1,14 @@
 package seafan
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/invertedv/utilities"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -176,3 +180,356 @@ func ExampleSlice_Iter() {
 	// Slice x4=18 has 429 observations
 	// Slice x4=19 has 423 observations
 }
+
+func TestNewSlicerExpr(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data") + "/pipeTest1.csv"
+	pipe, e := CSVToPipe(data, nil, false)
+	assert.Nil(t, e)
+
+	sl, e := NewSlicerExpr("Field3 > 2 && Field1 != 'x'", pipe)
+	assert.Nil(t, e)
+
+	act := make([]bool, pipe.Rows())
+	for row := 0; row < pipe.Rows(); row++ {
+		act[row] = sl(row)
+	}
+
+	assert.Equal(t, []bool{true, true, false, false, true, true, true}, act)
+
+	_, e = NewSlicerExpr("Field1", pipe)
+	assert.NotNil(t, e)
+}
+
+func TestNewSlicerIn(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data") + "/pipeTest1.csv"
+	pipe, e := CSVToPipe(data, nil, false)
+	assert.Nil(t, e)
+
+	sl, e := NewSlicerIn("Field1", pipe, "a", "x", "Last")
+	assert.Nil(t, e)
+
+	act := make([]bool, pipe.Rows())
+	for row := 0; row < pipe.Rows(); row++ {
+		act[row] = sl(row)
+	}
+
+	assert.Equal(t, []bool{true, false, false, true, false, false, true}, act)
+
+	sl, e = NewSlicerIn("Field3", pipe, 3, 100.0)
+	assert.Nil(t, e)
+
+	for row := 0; row < pipe.Rows(); row++ {
+		act[row] = sl(row)
+	}
+
+	assert.Equal(t, []bool{true, false, false, false, false, true, false}, act)
+
+	_, e = NewSlicerIn("Field1", pipe)
+	assert.NotNil(t, e)
+
+	_, e = NewSlicerIn("Field1", pipe, "it's")
+	assert.NotNil(t, e)
+}
+
+func TestNewSliceWeighted(t *testing.T) {
+	gd := NewGData()
+	x := make([]any, 100)
+	w := make([]any, 100)
+
+	for ind := 0; ind < len(x); ind++ {
+		x[ind], w[ind] = float64(ind), 1.0
+		// the top decile has 90% of the weight
+		if ind >= 90 {
+			w[ind] = 81.0
+		}
+	}
+
+	assert.Nil(t, gd.AppendC(NewRaw(x, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(w, nil), "w", false, nil, false))
+	pipe := NewVecData("test", gd)
+
+	s, e := NewSliceWeighted("x", "w", 0, pipe, nil)
+	assert.Nil(t, e)
+	// the first decile of weight ends at 89, the rest fall in the top decile
+	for _, q := range s.q[1 : len(s.q)-1] {
+		assert.GreaterOrEqual(t, q, 89.0)
+	}
+
+	_, e = NewSliceWeighted("x", "nope", 0, pipe, nil)
+	assert.NotNil(t, e)
+}
+
+func TestSegPlotWith(t *testing.T) {
+	save := Backend
+	defer func() { Backend = save }()
+
+	jb := &JSONBackend{}
+	Backend = jb
+
+	// segment a has event rate 0.1, b 0.5.  fit is unbiased.
+	n := 400
+	fit, event, seg := make([]any, n), make([]any, n), make([]any, n)
+
+	for ind := 0; ind < n; ind++ {
+		seg[ind], fit[ind], event[ind] = "a", 0.1, "N"
+		if ind%10 == 0 {
+			event[ind] = "Y"
+		}
+
+		if ind >= n/2 {
+			seg[ind], fit[ind] = "b", 0.5
+			if ind%2 == 0 {
+				event[ind] = "Y"
+			}
+		}
+	}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRaw(fit, nil), "fit", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw(event, nil), "event", nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw(seg, nil), "seg", nil, false))
+	assert.Nil(t, gd.MakeOneHot("event", "eventOH"))
+
+	pipe := NewVecData("seg", gd)
+
+	// the default event is level 1 ("Y")
+	assert.Nil(t, SegPlotWith(pipe, "event", "fit", "seg", &utilities.PlotDef{}, &SegPlotOpts{SizeByCount: true}))
+	assert.Nil(t, SegPlotWith(pipe, "eventOH", "fit", "seg", &utilities.PlotDef{}, &SegPlotOpts{Target: []int{1}}))
+	assert.Equal(t, 2, len(jb.Specs))
+
+	for _, spec := range jb.Specs {
+		fig := &grob.Fig{}
+		assert.Nil(t, json.Unmarshal(spec, fig))
+
+		// CI and point for each segment plus the reference line
+		assert.Equal(t, 5, len(fig.Data))
+
+		ci := fig.Data[0].(*grob.Scatter).Y.([]any)
+		assert.InDelta(t, 0.1, fig.Data[1].(*grob.Scatter).Y.([]any)[0], 1e-10)
+		assert.Greater(t, ci[0].(float64), 0.0)
+		assert.Less(t, ci[0].(float64), 0.1)
+		assert.Greater(t, ci[1].(float64), 0.1)
+		assert.InDelta(t, 0.5, fig.Data[3].(*grob.Scatter).Y.([]any)[0], 1e-10)
+		assert.Contains(t, string(spec), "Bias: 0.0000")
+	}
+
+	assert.Contains(t, string(jb.Specs[0]), `"size":30`)
+	assert.NotContains(t, string(jb.Specs[1]), `"size"`)
+
+	assert.NotNil(t, SegPlotWith(pipe, "eventOH", "fit", "seg", &utilities.PlotDef{}, &SegPlotOpts{Target: []int{2}}))
+	assert.NotNil(t, SegPlotWith(pipe, "fit", "event", "seg", &utilities.PlotDef{}, nil))
+}
+
+func TestTimeSeriesPlot(t *testing.T) {
+	save := Backend
+	defer func() { Backend = save }()
+
+	jb := &JSONBackend{}
+	Backend = jb
+
+	// six months: the event rate is 0.2 in the first quarter and 0.4 in the second.  fit is always 0.3
+	n := 600
+	dt, event, fit, y := make([]any, n), make([]any, n), make([]any, n), make([]any, n)
+
+	for ind := 0; ind < n; ind++ {
+		dt[ind] = time.Date(2024, time.Month(1+ind/100), 1, 0, 0, 0, 0, time.UTC)
+		fit[ind], event[ind], y[ind] = 0.3, int32(0), float64(ind%2)
+
+		rate := 5
+		if ind >= n/2 {
+			rate = 2
+		}
+
+		if ind%5 == 0 || (rate == 2 && ind%5 == 1) {
+			event[ind] = int32(1)
+		}
+	}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw(dt, nil), "dt", nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw(event, nil), "event", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(fit, nil), "fit", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(y, nil), "y", false, nil, false))
+	pipe := NewVecData("ts", gd)
+
+	assert.Nil(t, TimeSeriesPlot(pipe, "event", "fit", "dt", "quarter", &utilities.PlotDef{}))
+	assert.Nil(t, TimeSeriesPlot(pipe, "y", "fit", "dt", "month", &utilities.PlotDef{}))
+
+	fig := &grob.Fig{}
+	assert.Nil(t, json.Unmarshal(jb.Specs[0], fig))
+	assert.Equal(t, []any{"2024-Q1", "2024-Q2"}, fig.Data[0].(*grob.Scatter).X)
+	assert.InDeltaSlice(t, []float64{0.2, 0.4}, toFloats(fig.Data[0].(*grob.Scatter).Y), 1e-10)
+	assert.InDeltaSlice(t, []float64{0.3, 0.3}, toFloats(fig.Data[1].(*grob.Scatter).Y), 1e-10)
+
+	lo, hi := toFloats(fig.Data[2].(*grob.Scatter).Y), toFloats(fig.Data[3].(*grob.Scatter).Y)
+	assert.True(t, lo[0] > 0.15 && lo[0] < 0.2 && hi[0] > 0.2 && hi[0] < 0.25)
+
+	fig = &grob.Fig{}
+	assert.Nil(t, json.Unmarshal(jb.Specs[1], fig))
+	assert.Equal(t, 6, len(toFloats(fig.Data[0].(*grob.Scatter).Y)))
+	assert.InDelta(t, 0.5, toFloats(fig.Data[0].(*grob.Scatter).Y)[0], 1e-10)
+
+	assert.NotNil(t, TimeSeriesPlot(pipe, "event", "fit", "dt", "week", &utilities.PlotDef{}))
+	assert.NotNil(t, TimeSeriesPlot(pipe, "event", "fit", "fit", "month", &utilities.PlotDef{}))
+	assert.NotNil(t, TimeSeriesPlot(pipe, "event", "dt", "dt", "month", &utilities.PlotDef{}))
+}
+
+// toFloats converts the []any of an unmarshalled trace to []float64
+func toFloats(x any) []float64 {
+	var out []float64
+	for _, v := range x.([]any) {
+		out = append(out, v.(float64))
+	}
+
+	return out
+}
+
+func TestAssessSweep(t *testing.T) {
+	xy, e := NewXY([]float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8}, []float64{0, 0, 1, 0, 1, 1, 0, 1})
+	assert.Nil(t, e)
+
+	sweep, bestF1, bestJ, e := AssessSweep(xy, []float64{0.15, 0.25, 0.45, 0.75, 0.9})
+	assert.Nil(t, e)
+	assert.Equal(t, 5, sweep.Rows())
+
+	// matches Assess
+	_, precision, recall, accuracy, _, _, e := Assess(xy, 0.45)
+	assert.Nil(t, e)
+	assert.InDelta(t, precision, sweep.Get("precision").Data.([]float64)[2], 1e-10)
+	assert.InDelta(t, recall, sweep.Get("recall").Data.([]float64)[2], 1e-10)
+	assert.InDelta(t, accuracy, sweep.Get("accuracy").Data.([]float64)[2], 1e-10)
+
+	// at 0.25: tp=4, fp=2 -> precision 2/3, recall 1, f1 0.8, fpr 0.5
+	assert.InDeltaSlice(t, []float64{8.0 / 11.0, 0.8, 0.75, 0.4, 0}, sweep.Get("f1").Data, 1e-10)
+	assert.InDeltaSlice(t, []float64{0.75, 0.5, 0.25, 0, 0}, sweep.Get("fpr").Data, 1e-10)
+	assert.Equal(t, sweep.Get("recall").Data, sweep.Get("tpr").Data)
+
+	// J is 0.5 at 0.25 and 0.45
+	assert.Equal(t, 0.25, bestF1)
+	assert.Equal(t, 0.25, bestJ)
+
+	sweep, _, _, e = AssessSweep(xy, nil)
+	assert.Nil(t, e)
+	assert.Equal(t, 99, sweep.Rows())
+
+	xy, e = NewXY([]float64{0.1, 0.2}, []float64{0, 0})
+	assert.Nil(t, e)
+	_, _, _, e = AssessSweep(xy, nil)
+	assert.NotNil(t, e)
+}
+
+func TestAddFitted_ScoreBatch(t *testing.T) {
+	Verbose = false
+	pipe, root := scorerModel(t, 5)
+
+	bSize := pipe.BatchSize()
+	assert.Nil(t, AddFitted(pipe, root, []int{1}, "fitAll", nil, false, nil))
+
+	// 30 does not divide 100, so the last batch is padded with 20 rows
+	for _, n := range []int{30, 50, 200} {
+		name := fmt.Sprintf("fit%d", n)
+		assert.Nil(t, AddFitted(pipe, root, []int{1}, name, nil, false, nil, WithScoreBatch(n)))
+
+		chunked := pipe.Get(name).Floats()
+		assert.Equal(t, pipe.Rows(), len(chunked))
+		assert.InDeltaSlice(t, pipe.Get("fitAll").Floats(), chunked, 1e-10)
+	}
+
+	assert.Equal(t, bSize, pipe.BatchSize())
+	assert.NotNil(t, AddFitted(pipe, root, []int{1}, "fitBad", nil, false, nil, WithScoreBatch(-1)))
+}
+
+func TestCoalesceGroups(t *testing.T) {
+	fit := []float64{.2, .3, .5,
+		.2, .5, .3,
+		.2, .4, .4,
+		.5, .3, .2}
+	ft := &FType{Name: "status", Role: FRCat, Cats: 3, FP: &FParam{Lvl: Levels{"current": 0, "D90": 1, "D120": 2}}}
+
+	grouped, e := CoalesceGroups(fit, ft, ClassGroups{"bad": {"D90", "D120"}, "good": {"current"}}, false)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"bad", "good"}, grouped.FieldList())
+	assert.InDeltaSlice(t, []float64{.8, .8, .8, .5}, grouped.Get("bad").Floats(), 1e-10)
+	assert.InDeltaSlice(t, []float64{.2, .2, .2, .5}, grouped.Get("good").Floats(), 1e-10)
+
+	// D120 is dropped and the rest renormalized
+	grouped, e = CoalesceGroups(fit, ft, ClassGroups{"D90": {"D90"}, "current": {"current"}}, true)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, []float64{.6, .5 / .7, .4 / .6, .3 / .8}, grouped.Get("D90").Floats(), 1e-10)
+	assert.InDeltaSlice(t, []float64{.4, .2 / .7, .2 / .6, .5 / .8}, grouped.Get("current").Floats(), 1e-10)
+
+	_, e = CoalesceGroups(fit, ft, ClassGroups{"bad": {"D30"}}, false)
+	assert.NotNil(t, e)
+
+	_, e = CoalesceGroups(fit, ft, ClassGroups{"bad": {"D90"}, "worse": {"D90", "D120"}}, false)
+	assert.NotNil(t, e)
+
+	_, e = CoalesceGroups(fit[:5], ft, ClassGroups{"bad": {"D90"}}, false)
+	assert.NotNil(t, e)
+}
+
+func TestNewSlice_Opts(t *testing.T) {
+	gd := NewGData()
+	x := make([]any, 100)
+	c := make([]any, 100)
+
+	for ind := 0; ind < len(x); ind++ {
+		x[ind] = float64(ind)
+		// level a is the most frequent, then b, then c
+		c[ind] = "a"
+		switch {
+		case ind >= 90:
+			c[ind] = "c"
+		case ind >= 60:
+			c[ind] = "b"
+		}
+	}
+
+	assert.Nil(t, gd.AppendC(NewRaw(x, nil), "x", true, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw(c, nil), "c", nil, false))
+	pipe := NewVecData("test", gd)
+
+	s, e := NewSlice("x", 0, pipe, nil, WithQuantileBins(10))
+	assert.Nil(t, e)
+	assert.Equal(t, 10, len(s.Bins()))
+
+	// cuts are in raw units even though x is normalized
+	s, e = NewSlice("x", 0, pipe, nil, WithCuts(25, 50, 200))
+	assert.Nil(t, e)
+
+	bins := s.Bins()
+	assert.Equal(t, 3, len(bins))
+	assert.InDelta(t, 0.0, bins[0].Lo, 1e-10)
+	assert.InDelta(t, 25.0, bins[0].Hi, 1e-10)
+	assert.InDelta(t, 99.0, bins[2].Hi, 1e-10)
+	assert.Equal(t, "[50, 99]", bins[2].Label)
+
+	n := 0
+	for s.Iter() {
+		sl := s.MakeSlicer()
+		for row := 0; row < pipe.Rows(); row++ {
+			if sl(row) {
+				n++
+			}
+		}
+	}
+
+	assert.Equal(t, pipe.Rows(), n)
+
+	s, e = NewSlice("c", 0, pipe, nil, WithTopK(2))
+	assert.Nil(t, e)
+
+	levels := make([]any, 0)
+	for _, b := range s.Bins() {
+		levels = append(levels, b.Level)
+	}
+
+	assert.ElementsMatch(t, []any{"a", "b"}, levels)
+
+	_, e = NewSlice("x", 0, pipe, nil, WithQuantileBins(4), WithCuts(10))
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,170 @@
+package seafan
+
+// env.go binds names to values that expressions can use in place of fields
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/invertedv/utilities"
+)
+
+// Env binds names to values for expressions.  A bound name is used in an expression as a field would be, e.g.
+//
+//	env := NewEnv()
+//	_ = env.Bind("hpiShock", -0.2)
+//	e := Evaluate(root, pipe, WithEnv(env)) // root from "value * (1 + hpiShock)"
+//
+// so scenario parameters can be changed without rebuilding the expression.  A value is either a scalar (number,
+// string or time.Time) or a slice of them.  A slice is a small lookup table that can be indexed with index(), e.g.
+// index(hpiPath, month).
+//
+// The names are available only to the evaluations the Env is passed to.  A bound name hides a field of the same
+// name.  An Env is safe for concurrent use.
+type Env struct {
+	mu     sync.RWMutex
+	vals   map[string]*Raw
+	parent *Env // names not bound in the Env are looked up here (see RunScenarios)
+}
+
+// NewEnv returns an empty Env
+func NewEnv() *Env {
+	return &Env{vals: make(map[string]*Raw)}
+}
+
+// Bind binds name to value, replacing any existing binding.  Numbers are stored as float64.
+func (env *Env) Bind(name string, value any) error {
+	if name == "" {
+		return Wrapper(ErrParser, "Bind: empty name")
+	}
+
+	raw, e := bindRaw(value)
+	if e != nil {
+		return Wrapper(ErrParser, fmt.Sprintf("Bind %s: %v", name, e))
+	}
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	env.vals[name] = raw
+
+	return nil
+}
+
+// Unbind removes the binding of name
+func (env *Env) Unbind(name string) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	delete(env.vals, name)
+}
+
+// Get returns the value bound to name, nil if there is none
+func (env *Env) Get(name string) *Raw {
+	env.mu.RLock()
+	raw := env.vals[name]
+	env.mu.RUnlock()
+
+	if raw == nil && env.parent != nil {
+		return env.parent.Get(name)
+	}
+
+	return raw
+}
+
+// Names returns the bound names in sorted order
+func (env *Env) Names() []string {
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+
+	names := make([]string, 0, len(env.vals))
+	for name := range env.vals {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// WithEnv makes the names bound in env available to the expression.
+func WithEnv(env *Env) EvalOpt {
+	return func(cfg *evalConfig) {
+		cfg.env = env
+	}
+}
+
+// lookup returns the value bound to name by cfg's Env, nil if there is none.
+func (cfg *evalConfig) lookup(name string) *Raw {
+	if cfg.env == nil {
+		return nil
+	}
+
+	return cfg.env.Get(name)
+}
+
+// bindRaw converts value to a *Raw
+func bindRaw(value any) (*Raw, error) {
+	scalar := func(x any) (any, error) {
+		switch v := x.(type) {
+		case float64, float32, int, int32, int64:
+			f, _ := utilities.Any2Float64(v)
+			return *f, nil
+		case string, time.Time:
+			return v, nil
+		}
+
+		return nil, fmt.Errorf("unsupported type %T", x)
+	}
+
+	var vals []any
+
+	switch v := value.(type) {
+	case []any:
+		vals = v
+	case []float64:
+		for _, x := range v {
+			vals = append(vals, x)
+		}
+	case []int:
+		for _, x := range v {
+			vals = append(vals, x)
+		}
+	case []string:
+		for _, x := range v {
+			vals = append(vals, x)
+		}
+	case []time.Time:
+		for _, x := range v {
+			vals = append(vals, x)
+		}
+	default:
+		vals = []any{value}
+	}
+
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("no values")
+	}
+
+	data := make([]any, len(vals))
+	for ind, val := range vals {
+		x, e := scalar(val)
+		if e != nil {
+			return nil, e
+		}
+
+		data[ind] = x
+	}
+
+	raw := NewRaw(data, nil)
+	for _, x := range data {
+		if reflect.TypeOf(x).Kind() != raw.Kind {
+			return nil, fmt.Errorf("values are not all the same type")
+		}
+	}
+
+	return raw, nil
+}
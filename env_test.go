@@ -0,0 +1,74 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnv_Bind(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	env := NewEnv()
+	assert.Nil(t, env.Bind("shock", 2))
+	assert.Nil(t, env.Bind("path", []float64{10, 20, 30, 40, 50, 60, 70}))
+	assert.Nil(t, env.Bind("state", "CA"))
+	assert.Equal(t, []string{"path", "shock", "state"}, env.Names())
+
+	assert.NotNil(t, env.Bind("bad", []any{1.0, "a"}))
+	assert.NotNil(t, env.Bind("bad", map[string]int{"a": 1}))
+	assert.NotNil(t, env.Bind("", 1.0))
+
+	exprs := []struct {
+		expr string
+		exp  []any
+	}{
+		{"row * shock", []any{2.0, 4.0, 6.0, 8.0, 10.0, 12.0, 14.0}},
+		{"-shock + row", []any{-1.0, 0.0, 1.0, 2.0, 3.0, 4.0, 5.0}},
+		{"index(path, row-1) - row", []any{9.0, 18.0, 27.0, 36.0, 45.0, 54.0, 63.0}},
+		{"state == 'CA'", []any{1.0}},
+	}
+
+	for _, ex := range exprs {
+		root := &OpNode{Expression: ex.expr}
+		assert.Nil(t, Expr2Tree(root), ex.expr)
+		assert.Nil(t, Evaluate(root, pipe, WithEnv(env)), ex.expr)
+		assert.Equal(t, ex.exp, root.Raw.Data, ex.expr)
+	}
+
+	// the binding is not changed by negation
+	assert.Equal(t, []any{2.0}, env.Get("shock").Data)
+
+	root := &OpNode{Expression: "row * shock"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, pipe))
+
+	assert.Nil(t, Evaluate(root, pipe, WithEnv(env)))
+	assert.Equal(t, 14.0, root.Raw.Data[6])
+
+	// the names of the Env given to Compile are known to it and used by Run, unless Run has another Env
+	_, e = Compile("row * shock", pipe.GetFTypes())
+	assert.NotNil(t, e)
+
+	prog, e := Compile("row * shock", pipe.GetFTypes(), WithEnv(env))
+	assert.Nil(t, e)
+
+	raw, e := prog.Run(pipe)
+	assert.Nil(t, e)
+	assert.Equal(t, 14.0, raw.Data[6])
+
+	other := NewEnv()
+	assert.Nil(t, other.Bind("shock", 3.0))
+
+	raw, e = prog.Run(pipe, WithEnv(other))
+	assert.Nil(t, e)
+	assert.Equal(t, 21.0, raw.Data[6])
+
+	env.Unbind("shock")
+	assert.Nil(t, env.Get("shock"))
+}
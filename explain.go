@@ -0,0 +1,284 @@
+package seafan
+
+// explain.go decomposes individual predictions into the contributions of the model inputs
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/invertedv/utilities"
+)
+
+// Contribution is the Shapley value of a model input for a prediction
+type Contribution struct {
+	Field   string  // Field is the input.  A one-hot or embedded input is a single field.
+	Contrib float64 // Contrib is the contribution of the input to the model output
+}
+
+// Explanation is the decomposition of the model output for a row by Explain.  Base plus the sum of the contributions
+// is Fit.
+type Explanation struct {
+	Row      int             // Row is the row of the pipeline explained
+	Fit      float64         // Fit is the model output for the row
+	Base     float64         // Base is the average model output over the sampled background rows
+	Samples  int             // Samples is the number of permutations sampled
+	Contribs []*Contribution // Contribs are the contributions of the inputs, largest in absolute value first
+}
+
+// String produces a table of the explanation
+func (ex *Explanation) String() string {
+	const pad = 3
+
+	table := [][]string{{"Field", "Contribution"}, {"Base", fmt.Sprintf("%0.6f", ex.Base)}}
+
+	for _, c := range ex.Contribs {
+		table = append(table, []string{c.Field, fmt.Sprintf("%0.6f", c.Contrib)})
+	}
+
+	table = append(table, []string{"Fit", fmt.Sprintf("%0.6f", ex.Fit)})
+
+	return fmt.Sprintf("Row %d, %d samples\n%s", ex.Row, ex.Samples, utilities.Pad(table, pad))
+}
+
+// Get returns the contribution of field.  It returns 0 if field is not an input.
+func (ex *Explanation) Get(field string) float64 {
+	for _, c := range ex.Contribs {
+		if c.Field == field {
+			return c.Contrib
+		}
+	}
+
+	return 0.0
+}
+
+// Explain approximates the Shapley values of the inputs of the model saved in nnFile for the prediction of row of pipe.
+// The columns of a one-hot or embedded input are treated as a single feature.
+//
+// Each of the nSamples samples draws a random ordering of the inputs and a random background row of pipe.
+// Starting from the background row, the inputs are switched to the values of row in that order and each input is
+// credited with the change in the output when it is switched (Strumbelj and Kononenko).  The contributions are
+// averaged over the samples.  The offset, if any, is held at its value in row.
+//
+// The output is the sum of the target columns.  If target is omitted, all the output columns are summed--for a
+// softmax output this sum is 1, so a target should be given.  The data in pipe must be on the same scale as the
+// model build (see PredictNNwFts).
+func Explain(nnFile string, pipe Pipeline, row, nSamples int, target ...int) (*Explanation, error) {
+	return explain(nnFile, pipe, row, nSamples, rng, target...)
+}
+
+// explain is Explain with the permutations and background rows drawn from rnd
+func explain(nnFile string, pipe Pipeline, row, nSamples int, rnd *rand.Rand, target ...int) (*Explanation, error) {
+	if row < 0 || row >= pipe.Rows() {
+		return nil, Wrapper(ErrNNModel, fmt.Sprintf("Explain: row %d out of range", row))
+	}
+
+	if nSamples <= 0 {
+		return nil, Wrapper(ErrNNModel, "Explain: nSamples must be positive")
+	}
+
+	sc, e := NewScorer(nnFile, pipe.GetFTypes())
+	if e != nil {
+		return nil, Wrapper(e, "Explain")
+	}
+
+	if target == nil {
+		for col := 0; col < sc.OutputCols(); col++ {
+			target = append(target, col)
+		}
+	}
+
+	for _, col := range target {
+		if col < 0 || col >= sc.OutputCols() {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("Explain: target column %d out of range", col))
+		}
+	}
+
+	data, e := sc.inputData(pipe)
+	if e != nil {
+		return nil, Wrapper(e, "Explain")
+	}
+
+	// each sample scores the background row and then one row for each input switched
+	nFeat := len(sc.inputFT)
+	nRow := nSamples * (nFeat + 1)
+	comp := make([][]float64, len(data))
+	perms := make([][]int, nSamples)
+
+	for ind := range data {
+		nCol := 1
+		if ind < nFeat {
+			nCol = sc.inCols(sc.inputFT[ind])
+		}
+
+		comp[ind] = make([]float64, 0, nRow*nCol)
+	}
+
+	for s := 0; s < nSamples; s++ {
+		perms[s] = rnd.Perm(nFeat)
+		bg := rnd.Intn(pipe.Rows())
+
+		// from is the source row of each input
+		from := make([]int, nFeat)
+		for ind := range from {
+			from[ind] = bg
+		}
+
+		for step := 0; step <= nFeat; step++ {
+			if step > 0 {
+				from[perms[s][step-1]] = row
+			}
+
+			for ind := range data {
+				if ind == nFeat {
+					comp[ind] = append(comp[ind], data[ind][row])
+					continue
+				}
+
+				nCol := sc.inCols(sc.inputFT[ind])
+				comp[ind] = append(comp[ind], data[ind][from[ind]*nCol:(from[ind]+1)*nCol]...)
+			}
+		}
+	}
+
+	out, e := sc.scoreData(nRow, comp)
+	if e != nil {
+		return nil, Wrapper(e, "Explain")
+	}
+
+	// output of row r of comp
+	fit := func(r int) float64 {
+		f := 0.0
+		for _, col := range target {
+			f += out[r*sc.OutputCols()+col]
+		}
+
+		return f
+	}
+
+	contrib := make([]float64, nFeat)
+	base := 0.0
+
+	for s := 0; s < nSamples; s++ {
+		start := s * (nFeat + 1)
+		base += fit(start)
+
+		for step := 1; step <= nFeat; step++ {
+			contrib[perms[s][step-1]] += fit(start+step) - fit(start+step-1)
+		}
+	}
+
+	ex := &Explanation{Row: row, Fit: fit(nFeat), Base: base / float64(nSamples), Samples: nSamples}
+
+	for ind, ft := range sc.inputFT {
+		ex.Contribs = append(ex.Contribs, &Contribution{Field: ft.Name, Contrib: contrib[ind] / float64(nSamples)})
+	}
+
+	sort.SliceStable(ex.Contribs, func(i, j int) bool {
+		return math.Abs(ex.Contribs[i].Contrib) > math.Abs(ex.Contribs[j].Contrib)
+	})
+
+	return ex, nil
+}
+
+// ReasonCode is an input that pushes a prediction toward the adverse outcome
+type ReasonCode struct {
+	Rank    int     // Rank is 1 for the input that pushes the hardest
+	Field   string  // Field is the input
+	Value   any     // Value is the value of the input in the units of the raw data
+	Contrib float64 // Contrib is the contribution of the input to the output (see Explain)
+}
+
+// Reasons is the result of ReasonCodes
+type Reasons []*ReasonCode
+
+// String lists the reasons, one per line
+func (rs Reasons) String() string {
+	str := ""
+	for _, r := range rs {
+		str = fmt.Sprintf("%s%d. %s = %v (%+0.4f)\n", str, r.Rank, r.Field, r.Value, r.Contrib)
+	}
+
+	return str
+}
+
+// ReasonCodes returns the (at most) k inputs that push the prediction of row of pipe toward the adverse outcome the
+// most, for the model saved in nnFile.  The adverse outcome is the sum of the target columns--for a softmax output,
+// target is the column(s) of the adverse class.  If target is omitted, all the output columns are summed.
+//
+// The contributions are the Shapley values found by Explain with nSamples samples drawn from a random number
+// generator seeded with seed, so the codes for a row are reproducible.  Only inputs with positive contributions are
+// returned. The values are in the units of the raw data: normalized inputs are unnormalized and one-hot and embedded
+// inputs are mapped back to the level of the field they are made from.
+func ReasonCodes(nnFile string, pipe Pipeline, row, k, nSamples int, seed int64, target ...int) (Reasons, error) {
+	if k <= 0 {
+		return nil, Wrapper(ErrNNModel, "ReasonCodes: k must be positive")
+	}
+
+	ex, e := explain(nnFile, pipe, row, nSamples, newRand(seed), target...)
+	if e != nil {
+		return nil, Wrapper(e, "ReasonCodes")
+	}
+
+	rs := make(Reasons, 0)
+
+	// the contributions are sorted by absolute value
+	for _, c := range ex.Contribs {
+		if len(rs) == k {
+			break
+		}
+
+		if c.Contrib <= 0.0 {
+			continue
+		}
+
+		rs = append(rs, &ReasonCode{Rank: len(rs) + 1, Field: c.Field, Value: rawValue(pipe, c.Field, row), Contrib: c.Contrib})
+	}
+
+	return rs, nil
+}
+
+// rawValue returns the value of the input field at row of pipe in the units of the raw data
+func rawValue(pipe Pipeline, field string, row int) any {
+	d := pipe.Get(field)
+	if d == nil {
+		return nil
+	}
+
+	ft := d.FT
+
+	switch ft.Role {
+	case FRCts:
+		x := d.floatAt(row)
+		if ft.Normalized && ft.FP != nil {
+			x = x*ft.FP.Scale + ft.FP.Location
+		}
+
+		return x
+	case FRCat, FROrdinal:
+		if ft.FP != nil {
+			return ft.FP.Lvl.FindValue(d.Data.([]int32)[row])
+		}
+	case FROneHot, FREmbed:
+		x := d.Data.([]float64)[row*ft.Cats : (row+1)*ft.Cats]
+		src := pipe.GetFType(ft.From)
+
+		// ordinal fields have cumulative coding, so the level is the last column that is 1
+		ordinal := src != nil && src.Role == FROrdinal
+
+		for col, xv := range x {
+			if xv != 1.0 || (ordinal && col+1 < len(x) && x[col+1] == 1.0) {
+				continue
+			}
+
+			if src != nil && src.FP != nil {
+				return src.FP.Lvl.FindValue(int32(col))
+			}
+
+			return col
+		}
+	}
+
+	return nil
+}
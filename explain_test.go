@@ -0,0 +1,78 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	Verbose = false
+	pipe, root := scorerModel(t, 13)
+
+	ex, e := Explain(root, pipe, 10, 50, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, 4, len(ex.Contribs))
+	assert.Equal(t, 50, ex.Samples)
+
+	// the contributions add up to the fit
+	total := ex.Base
+	for _, c := range ex.Contribs {
+		total += c.Contrib
+	}
+
+	assert.InDelta(t, ex.Fit, total, 1e-10)
+
+	sc, e := NewScorer(root, pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	score, e := sc.Score(pipe)
+	assert.Nil(t, e)
+	assert.InDelta(t, score[10*2+1], ex.Fit, 1e-10)
+
+	assert.Contains(t, ex.String(), "x4oh")
+
+	// the softmax columns sum to 1, so there is nothing to explain
+	ex, e = Explain(root, pipe, 10, 20)
+	assert.Nil(t, e)
+	assert.InDelta(t, 0.0, ex.Get("x1"), 1e-10)
+
+	_, e = Explain(root, pipe, pipe.Rows(), 20)
+	assert.NotNil(t, e)
+
+	_, e = Explain(root, pipe, 0, 20, 2)
+	assert.NotNil(t, e)
+}
+
+func TestReasonCodes(t *testing.T) {
+	Verbose = false
+	pipe, root := scorerModel(t, 13)
+
+	rs, e := ReasonCodes(root, pipe, 10, 2, 500, 7, 1)
+	assert.Nil(t, e)
+	assert.LessOrEqual(t, len(rs), 2)
+
+	// the codes do not depend on the package random number generator
+	SetSeed(99)
+	again, e := ReasonCodes(root, pipe, 10, 2, 500, 7, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, rs, again)
+
+	for ind, r := range rs {
+		assert.Equal(t, ind+1, r.Rank)
+		assert.Greater(t, r.Contrib, 0.0)
+		assert.NotNil(t, r.Value)
+	}
+
+	// values are in raw units
+	x1 := pipe.Get("x1").FT
+	assert.InDelta(t, pipe.Get("x1").Floats()[10]*x1.FP.Scale+x1.FP.Location, rawValue(pipe, "x1", 10), 1e-10)
+	assert.NotNil(t, rawValue(pipe, "x4oh", 10))
+	assert.Equal(t, rawValue(pipe, "x4oh", 10), rawValue(pipe, "x4", 10))
+
+	_, e = ReasonCodes(root, pipe, 10, 0, 500, 7, 1)
+	assert.NotNil(t, e)
+
+	_, e = ReasonCodes(root, pipe, 10, 2, 0, 7, 1)
+	assert.NotNil(t, e)
+}
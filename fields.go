@@ -3,13 +3,19 @@ package seafan
 // fields.go implements structures/methods dealing with fields
 
 import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/invertedv/utilities"
 )
 
 // FType represents a single field. It holds key information about the feature: its role, dimensions, summary info.
@@ -21,6 +27,7 @@ type FType struct {
 	Normalized bool
 	From       string
 	FP         *FParam
+	Float32    bool // FRCts data is stored as []float32 (see (*GData).ToFloat32)
 }
 
 type FTypes []*FType
@@ -46,12 +53,26 @@ func (fts FTypes) DropFields(dropFields ...string) FTypes {
 // FParam -- field parameters -- is summary data about a field. These values may not be derived from the current
 // data but are applied to the current data.
 type FParam struct {
-	Location float64 `json:"location"` // location parameter for *Cts
-	Scale    float64 `json:"scale"`    // scale parameter for *Cts
-	Default  any     `json:"default"`  // default level for *Dscrt
-	Lvl      Levels  `json:"lvl"`      // map of values to int32 category for *Dscrt
+	Location float64      `json:"location"`           // location parameter for *Cts
+	Scale    float64      `json:"scale"`              // scale parameter for *Cts
+	Default  any          `json:"default"`            // default level for *Dscrt
+	Lvl      Levels       `json:"lvl"`                // map of values to int32 category for *Dscrt
+	Knot     *float64     `json:"knot,omitempty"`     // knot of a spline basis field (see Splines)
+	Unseen   UnseenPolicy `json:"unseen,omitempty"`   // treatment of values not in Lvl for *Dscrt
+	Frequent any          `json:"frequent,omitempty"` // most frequent level of the data Lvl was built from, for *Dscrt
 }
 
+// UnseenPolicy is how values of a FRCat field that are not in its Levels are treated, e.g. new values met at
+// scoring time.  The number of such values is Summary.Unseen of the field.
+type UnseenPolicy int
+
+const (
+	UnseenOther        UnseenPolicy = 0 + iota // map to the Default level, an error if there is none
+	UnseenError                                // error
+	UnseenMostFrequent                         // map to FParam Frequent, the most frequent level at build time, else Default
+	UnseenAverage                              // map to the Default level, the one-hot/embedding is the average of all levels
+)
+
 // FRole is the role a feature plays
 type FRole int
 
@@ -61,6 +82,9 @@ const (
 	FROneHot
 	FREmbed
 	FREither
+	FRSeq     // ordered history of FRCts fields (see MakeSequence)
+	FRDate    // dates, stored as days since 1/1/1970 (see AppendDate)
+	FROrdinal // discrete with ordered levels (see AppendOrdinal)
 )
 
 //go:generate stringer -type=FRole
@@ -70,6 +94,7 @@ type Summary struct {
 	NRows  int    // size of the data
 	DistrC *Desc  // summary of continuous field
 	DistrD Levels // summary of discrete field
+	Unseen int    // # of values of a discrete field not in its Levels (see UnseenPolicy)
 }
 
 func (ft *FType) String() string {
@@ -78,6 +103,9 @@ func (ft *FType) String() string {
 	switch ft.Role {
 	case FRCts:
 		str = fmt.Sprintf("%s\tcontinuous\n", str)
+		if ft.Float32 {
+			str = fmt.Sprintf("%s\tstored as float32\n", str)
+		}
 		if ft.Normalized {
 			str = fmt.Sprintf("%s\tnormalized by:\n", str)
 			str = fmt.Sprintf("%s\tlocation\t%.2f\n", str, ft.FP.Location)
@@ -92,11 +120,32 @@ func (ft *FType) String() string {
 		str = fmt.Sprintf("%s\tderived from feature %s\n", str, ft.From)
 		str = fmt.Sprintf("%s\tlength %d\n", str, ft.Cats)
 		str = fmt.Sprintf("%s\tembedding dimension of %d\n", str, ft.EmbCols)
+	case FRDate:
+		str = fmt.Sprintf("%s\tdate\n", str)
+	case FROrdinal:
+		str = fmt.Sprintf("%s\tordinal\n", str)
+	case FRSeq:
+		steps, _ := ft.SeqShape()
+		str = fmt.Sprintf("%s\tsequence\n", str)
+		str = fmt.Sprintf("%s\tderived from features %s\n", str, ft.From)
+		str = fmt.Sprintf("%s\tsteps %d\n", str, steps)
 	}
 
 	return str
 }
 
+// SeqShape returns the number of time steps and features of an FRSeq field.  Each row of the field holds
+// steps*features values ordered by time step then feature.  It returns 0, 0 for other roles.
+func (ft *FType) SeqShape() (steps, features int) {
+	if ft.Role != FRSeq || ft.From == "" {
+		return 0, 0
+	}
+
+	features = len(strings.Split(ft.From, ","))
+
+	return ft.Cats / features, features
+}
+
 // Get returns the *FType of name
 func (fts FTypes) Get(name string) *FType {
 	for _, f := range fts {
@@ -108,13 +157,33 @@ func (fts FTypes) Get(name string) *FType {
 	return nil
 }
 
+// names returns the names of the fields
+func (fts FTypes) names() []string {
+	names := make([]string, len(fts))
+	for ind, ft := range fts {
+		names[ind] = ft.Name
+	}
+
+	return names
+}
+
+// ftVersion is the version of the FTypes save file format.
+//   - 1: a json array of fType.  Level keys are written with %v, dates with time.RFC3339.
+//   - 2: a json object with the version and the array of fType. Level keys and default values are encoded by
+//     encodeLevel, dates with time.RFC3339Nano.
+const ftVersion = 2
+
 // fps is a json-friendly version of FParam
 type fps struct {
 	Location float64          `json:"location"` // location parameter for *Cts
 	Scale    float64          `json:"scale"`    // scale parameter for *Cts
 	Default  any              `json:"default"`  // default level for *Dscrt
-	Kind     string           `json:"kind"`
+	Kind     string           `json:"kind"`     // kind of the level keys: string, int, int32, int64, date
 	Lvl      map[string]int32 `json:"lvl"`
+	NilLvl   *int32           `json:"nilLvl,omitempty"`   // mapped value of a nil key (a nil Default)
+	Knot     *float64         `json:"knot,omitempty"`     // knot of a spline basis field
+	Unseen   UnseenPolicy     `json:"unseen,omitempty"`   // treatment of values not in Lvl
+	Frequent any              `json:"frequent,omitempty"` // most frequent level, encoded as Default
 }
 
 // ftype is a json-friendly version of FType
@@ -126,43 +195,156 @@ type fType struct {
 	Normalized bool
 	From       string
 	FP         *fps
+	Float32    bool `json:",omitempty"`
+}
+
+// ftFile is the format of the FTypes save file
+type ftFile struct {
+	Version int     `json:"version"`
+	FTypes  []fType `json:"ftypes"`
+}
+
+// encodeLevel returns the kind of the level key k and its value as a string
+func encodeLevel(k any) (kind, val string, err error) {
+	switch x := k.(type) {
+	case string:
+		return "string", x, nil
+	case int:
+		return "int", strconv.FormatInt(int64(x), 10), nil
+	case int32:
+		return "int32", strconv.FormatInt(int64(x), 10), nil
+	case int64:
+		return "int64", strconv.FormatInt(x, 10), nil
+	case time.Time:
+		return "date", x.Format(time.RFC3339Nano), nil
+	}
+
+	return "", "", Wrapper(ErrFields, fmt.Sprintf("unsupported level type %T", k))
+}
+
+// decodeLevel converts val to kind. It is the inverse of encodeLevel.
+func decodeLevel(kind, val string) (any, error) {
+	switch kind {
+	case "string":
+		return val, nil
+	case "int":
+		i, e := strconv.ParseInt(val, 10, 64)
+		if e != nil {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("cannot convert %s to int", val))
+		}
+
+		return int(i), nil
+	case "int32":
+		i, e := strconv.ParseInt(val, 10, 32)
+		if e != nil {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("cannot convert %s to int32", val))
+		}
+
+		return int32(i), nil
+	case "int64":
+		i, e := strconv.ParseInt(val, 10, 64)
+		if e != nil {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("cannot convert %s to int64", val))
+		}
+
+		return i, nil
+	case "date":
+		// RFC3339Nano also parses RFC3339 values (version 1 files)
+		dt, e := time.Parse(time.RFC3339Nano, val)
+		if e != nil {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("cannot convert %s to date", val))
+		}
+
+		return dt, nil
+	}
+
+	return nil, Wrapper(ErrFields, fmt.Sprintf("unknown kind %s", kind))
 }
 
 // Save saves FTypes to a json file--fileName
 func (fts FTypes) Save(fileName string) (err error) {
+	out, err := fts.toFile()
+	if err != nil {
+		return
+	}
+
+	jfp, err := json.MarshalIndent(out, "", "  ")
+
+	if err != nil {
+		return
+	}
+
 	f, err := os.Create(fileName)
 	if err != nil {
 		return
 	}
 	defer func() { _ = f.Close() }()
 
-	out := make([]fType, 0)
+	if _, err = f.WriteString(string(jfp)); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// toFile converts fts to the format of the save file
+func (fts FTypes) toFile() (*ftFile, error) {
+	out := &ftFile{Version: ftVersion, FTypes: make([]fType, 0)}
 
 	for _, ft := range fts {
 		fpStr := &fps{}
 
-		if ft.Role == FRCts || ft.Role == FRCat {
-			var dataType string
-
-			lvl := make(map[string]int32)
+		if (ft.Role == FRCts || ft.Role == FRCat || ft.Role == FROrdinal) && ft.FP != nil {
+			fpStr = &fps{Location: ft.FP.Location, Scale: ft.FP.Scale, Default: ft.FP.Default, Knot: ft.FP.Knot,
+				Unseen: ft.FP.Unseen}
+			fpStr.Lvl = make(map[string]int32)
 
 			for k, v := range ft.FP.Lvl {
-				dataType = reflect.TypeOf(k).Kind().String()
-				kOut := fmt.Sprintf("%v", k)
-				if dataType == "struct" {
-					val, ok := k.(time.Time)
-					if !ok {
-						return Wrapper(ErrFields, fmt.Sprintf("(FTypes) Save: unexpect struct type, field %s", ft.Name))
-					}
-					dataType = "date"
-					kOut = val.Format(time.RFC3339)
+				if k == nil {
+					nilLvl := v
+					fpStr.NilLvl = &nilLvl
+
+					continue
 				}
-				lvl[kOut] = v
+
+				kind, kOut, e := encodeLevel(k)
+				if e != nil {
+					return nil, Wrapper(e, fmt.Sprintf("(FTypes) Save: field %s", ft.Name))
+				}
+
+				if fpStr.Kind != "" && fpStr.Kind != kind {
+					return nil, Wrapper(ErrFields, fmt.Sprintf("(FTypes) Save: mixed level types, field %s", ft.Name))
+				}
+
+				fpStr.Kind = kind
+				fpStr.Lvl[kOut] = v
 			}
 
-			fpStr = &fps{Location: ft.FP.Location, Scale: ft.FP.Scale, Default: ft.FP.Default}
-			fpStr.Lvl = lvl
-			fpStr.Kind = dataType
+			if (ft.Role == FRCat || ft.Role == FROrdinal) && ft.FP.Default != nil {
+				kind, def, e := encodeLevel(ft.FP.Default)
+				if e != nil {
+					return nil, Wrapper(e, fmt.Sprintf("(FTypes) Save: default value, field %s", ft.Name))
+				}
+
+				if fpStr.Kind == "" {
+					fpStr.Kind = kind
+				}
+
+				fpStr.Default = def
+			}
+
+			if (ft.Role == FRCat || ft.Role == FROrdinal) && ft.FP.Frequent != nil {
+				kind, freq, e := encodeLevel(ft.FP.Frequent)
+				if e != nil {
+					return nil, Wrapper(e, fmt.Sprintf("(FTypes) Save: most frequent value, field %s", ft.Name))
+				}
+
+				if fpStr.Kind == "" {
+					fpStr.Kind = kind
+				}
+
+				fpStr.Frequent = freq
+			}
 		}
 
 		ftype := fType{
@@ -173,27 +355,27 @@ func (fts FTypes) Save(fileName string) (err error) {
 			Normalized: ft.Normalized,
 			From:       ft.From,
 			FP:         fpStr,
+			Float32:    ft.Float32,
 		}
-		out = append(out, ftype)
-	}
-
-	jfp, err := json.MarshalIndent(out, "", "  ")
-
-	if err != nil {
-		return
-	}
-
-	if _, err = f.WriteString(string(jfp)); err != nil {
-		return err
+		out.FTypes = append(out.FTypes, ftype)
 	}
 
-	return err
+	return out, nil
 }
 
-// LoadFTypes loads a file created by the FTypes Save method
+// LoadFTypes loads a file created by the FTypes Save method. Levels of an unknown kind are dropped.
 func LoadFTypes(fileName string) (fts FTypes, err error) {
-	fts = nil
+	return loadFTypes(fileName, false)
+}
+
+// LoadFTypesStrict loads a file created by the FTypes Save method. Unlike LoadFTypes, it returns an error if
+// the kind of the levels is unknown or the levels cannot be reloaded exactly.
+func LoadFTypesStrict(fileName string) (fts FTypes, err error) {
+	return loadFTypes(fileName, true)
+}
 
+// loadFTypes loads an FTypes save file. If strict, an error is returned if the levels can't be reloaded exactly.
+func loadFTypes(fileName string, strict bool) (fts FTypes, err error) {
 	f, err := os.Open(fileName)
 	if err != nil {
 		return
@@ -205,82 +387,396 @@ func LoadFTypes(fileName string) (fts FTypes, err error) {
 		return
 	}
 
-	data := make([]fType, 0)
+	var data ftFile
 
-	if e := json.Unmarshal(js, &data); e != nil {
+	// version 1 files are a json array
+	if trimmed := strings.TrimSpace(string(js)); strings.HasPrefix(trimmed, "[") {
+		data.Version = 1
+		if e := json.Unmarshal(js, &data.FTypes); e != nil {
+			return nil, e
+		}
+	} else if e := json.Unmarshal(js, &data); e != nil {
 		return nil, e
 	}
 
+	return data.fTypes(strict)
+}
+
+// fTypes converts the contents of a save file to FTypes
+func (data *ftFile) fTypes(strict bool) (fts FTypes, err error) {
+	if data.Version < 1 || data.Version > ftVersion {
+		return nil, Wrapper(ErrFields, fmt.Sprintf("LoadFTypes: unsupported version %d", data.Version))
+	}
+
 	fts = make(FTypes, 0)
 
-	for _, d := range data {
-		ft := FType{
+	for _, d := range data.FTypes {
+		ft := &FType{
 			Name:       d.Name,
 			Role:       d.Role,
 			Cats:       d.Cats,
 			EmbCols:    d.EmbCols,
 			Normalized: d.Normalized,
 			From:       d.From,
-			FP:         nil,
+			FP:         &FParam{},
+			Float32:    d.Float32,
 		}
-		fp := FParam{Location: d.FP.Location, Scale: d.FP.Scale, Default: d.FP.Default}
 
-		switch d.FP.Kind {
-		case "string":
-			fp.Default = fmt.Sprintf("%v", d.FP.Default)
-		case "int32":
-			switch d.FP.Default.(type) {
-			case float64:
-				fp.Default = int32(d.FP.Default.(float64))
-			default:
-				fp.Default = nil
+		if d.FP != nil {
+			if ft.FP, err = d.FP.fParam(data.Version, strict); err != nil {
+				return nil, Wrapper(err, fmt.Sprintf("LoadFTypes: field %s", d.Name))
 			}
-		case "int64":
-			switch d.FP.Default.(type) {
-			case float64:
-				fp.Default = int64(d.FP.Default.(float64))
-			default:
-				fp.Default = nil
+		}
+
+		fts = append(fts, ft)
+	}
+
+	return fts, nil
+}
+
+// fParam converts fps to *FParam
+func (fp *fps) fParam(version int, strict bool) (*FParam, error) {
+	out := &FParam{Location: fp.Location, Scale: fp.Scale, Default: fp.Default, Lvl: make(Levels), Knot: fp.Knot,
+		Unseen: fp.Unseen}
+
+	known := fp.Kind == "" || utilities.Has(fp.Kind, ",", "string,int,int32,int64,date")
+	if !known || (fp.Kind == "" && len(fp.Lvl) > 0) {
+		if strict {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("unknown kind %s", fp.Kind))
+		}
+
+		fp.Lvl = nil
+	}
+
+	if fp.Default != nil && known && fp.Kind != "" {
+		var e error
+		switch version {
+		case 1:
+			out.Default = defaultV1(fp.Kind, fp.Default)
+			if fp.Kind == "date" && out.Default == nil {
+				return nil, Wrapper(ErrFields, fmt.Sprintf("cannot convert default value %v to date", fp.Default))
 			}
-		case "date":
-			val, e := time.Parse(time.RFC3339, fmt.Sprintf("%s", d.FP.Default))
-			if e != nil {
-				return nil, Wrapper(ErrFields, fmt.Sprintf("LoadTypes: cannot convert default value %v to date", d.FP.Default))
+		default:
+			def, ok := fp.Default.(string)
+			if !ok {
+				return nil, Wrapper(ErrFields, fmt.Sprintf("bad default value %v", fp.Default))
+			}
+
+			if out.Default, e = decodeLevel(fp.Kind, def); e != nil {
+				return nil, e
 			}
-			fp.Default = val
 		}
+	}
 
-		lvl := make(Levels)
-		for k, v := range d.FP.Lvl {
-			switch d.FP.Kind {
-			case "string":
-				lvl[k] = v
-			case "int32":
-				i, e := strconv.ParseInt(k, 10, 32)
-				if e != nil {
-					return nil, Wrapper(ErrFields, fmt.Sprintf("LoadFTypes: cannot convert %s to int32", k))
-				}
+	if freq, ok := fp.Frequent.(string); ok && known && fp.Kind != "" {
+		var e error
+		if out.Frequent, e = decodeLevel(fp.Kind, freq); e != nil {
+			return nil, e
+		}
+	}
 
-				lvl[int32(i)] = v
-			case "int64":
-				i, e := strconv.ParseInt(k, 10, 64)
-				if e != nil {
-					return nil, Wrapper(ErrFields, fmt.Sprintf("LoadFTypes: cannot convert %s to int64", k))
-				}
+	for k, v := range fp.Lvl {
+		key, e := decodeLevel(fp.Kind, k)
+		if e != nil {
+			return nil, e
+		}
 
-				lvl[i] = v
-			case "date":
-				dt, e := time.Parse(time.RFC3339, k)
-				if e != nil {
-					return nil, Wrapper(ErrFields, fmt.Sprintf("LoadFTypes: cannot convert %s to date", k))
-				}
-				lvl[dt] = v
+		out.Lvl[key] = v
+	}
+
+	if fp.NilLvl != nil {
+		out.Lvl[nil] = *fp.NilLvl
+	}
+
+	return out, nil
+}
+
+// defaultV1 converts the default value of a version 1 save file
+func defaultV1(kind string, def any) any {
+	switch kind {
+	case "string":
+		return fmt.Sprintf("%v", def)
+	case "int", "int32", "int64":
+		x, ok := def.(float64)
+		if !ok {
+			return nil
+		}
+
+		switch kind {
+		case "int":
+			return int(x)
+		case "int32":
+			return int32(x)
+		}
+
+		return int64(x)
+	case "date":
+		val, e := time.Parse(time.RFC3339, fmt.Sprintf("%s", def))
+		if e != nil {
+			return nil
+		}
+
+		return val
+	}
+
+	return def
+}
+
+// levelsFile is the save format of Levels
+type levelsFile struct {
+	Kind     string           `json:"kind"`     // kind of the keys
+	Checksum string           `json:"checksum"` // sha256 of the contents
+	Lvl      map[string]int32 `json:"lvl"`
+	NilLvl   *int32           `json:"nilLvl,omitempty"` // mapped value of a nil key
+}
+
+// newLevelsFile converts l to the save format
+func newLevelsFile(l Levels) (*levelsFile, error) {
+	lf := &levelsFile{Lvl: make(map[string]int32)}
+
+	for k, v := range l {
+		if k == nil {
+			nilLvl := v
+			lf.NilLvl = &nilLvl
+
+			continue
+		}
+
+		kind, kOut, e := encodeLevel(k)
+		if e != nil {
+			return nil, e
+		}
+
+		if lf.Kind != "" && lf.Kind != kind {
+			return nil, Wrapper(ErrFields, "mixed level types")
+		}
+
+		lf.Kind = kind
+		lf.Lvl[kOut] = v
+	}
+
+	lf.Checksum = lf.checksum()
+
+	return lf, nil
+}
+
+// ErrChecksum is returned by LoadLevels when the file's contents do not match its checksum.  It wraps ErrFields.
+var ErrChecksum = Wrapper(ErrFields, "checksum mismatch")
+
+// checksum calculates the checksum of the contents of lf
+func (lf *levelsFile) checksum() string {
+	keys := make([]string, 0, len(lf.Lvl))
+	for k := range lf.Lvl {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n", lf.Kind)
+
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(h, "%q\t%d\n", k, lf.Lvl[k])
+	}
+
+	if lf.NilLvl != nil {
+		_, _ = fmt.Fprintf(h, "nil\t%d\n", *lf.NilLvl)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// levels converts lf to Levels, checking the checksum
+func (lf *levelsFile) levels() (Levels, error) {
+	if lf.Checksum != lf.checksum() {
+		return nil, Wrapper(ErrChecksum, "LoadLevels")
+	}
+
+	l := make(Levels)
+
+	for k, v := range lf.Lvl {
+		key, e := decodeLevel(lf.Kind, k)
+		if e != nil {
+			return nil, e
+		}
+
+		l[key] = v
+	}
+
+	if lf.NilLvl != nil {
+		l[nil] = *lf.NilLvl
+	}
+
+	return l, nil
+}
+
+// Save saves the Levels to fileName. If fileName ends in ".csv", the file is a CSV with the columns
+// value and code.  The first line of the CSV has the kind of the values and the checksum.  Otherwise, the file is
+// json.  The checksum is verified by LoadLevels.
+func (l Levels) Save(fileName string) error {
+	lf, e := newLevelsFile(l)
+	if e != nil {
+		return Wrapper(e, "(Levels) Save")
+	}
+
+	f, e := os.Create(fileName)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = f.Close() }()
+
+	if !strings.HasSuffix(strings.ToLower(fileName), ".csv") {
+		js, e := json.MarshalIndent(lf, "", "  ")
+		if e != nil {
+			return e
+		}
+
+		_, e = f.Write(js)
+
+		return e
+	}
+
+	w := csv.NewWriter(f)
+	meta := []string{"#kind=" + lf.Kind, "checksum=" + lf.Checksum}
+	if lf.NilLvl != nil {
+		meta = append(meta, fmt.Sprintf("nil=%d", *lf.NilLvl))
+	}
+
+	if e := w.Write(meta); e != nil {
+		return e
+	}
+
+	if e := w.Write([]string{"value", "code"}); e != nil {
+		return e
+	}
+
+	keys, vals := l.Sort(false, true)
+	for ind, k := range keys {
+		if k == nil {
+			continue
+		}
+
+		_, kOut, _ := encodeLevel(k)
+		if e := w.Write([]string{kOut, strconv.FormatInt(int64(vals[ind]), 10)}); e != nil {
+			return e
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+// LoadLevels loads Levels saved by the Levels Save method. An error is returned if the checksum does not match.
+func LoadLevels(fileName string) (Levels, error) {
+	f, e := os.Open(fileName)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = f.Close() }()
+
+	lf := &levelsFile{Lvl: make(map[string]int32)}
+
+	if !strings.HasSuffix(strings.ToLower(fileName), ".csv") {
+		js, e := io.ReadAll(f)
+		if e != nil {
+			return nil, e
+		}
+
+		if e := json.Unmarshal(js, lf); e != nil {
+			return nil, e
+		}
+
+		return lf.levels()
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	recs, e := r.ReadAll()
+	if e != nil {
+		return nil, e
+	}
+
+	if len(recs) < 2 {
+		return nil, Wrapper(ErrFields, "LoadLevels: file too short")
+	}
+
+	for _, meta := range recs[0] {
+		kv := strings.SplitN(strings.TrimPrefix(meta, "#"), "=", 2)
+		if len(kv) != 2 {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("LoadLevels: bad header %s", meta))
+		}
+
+		switch kv[0] {
+		case "kind":
+			lf.Kind = kv[1]
+		case "checksum":
+			lf.Checksum = kv[1]
+		case "nil":
+			v, e := strconv.ParseInt(kv[1], 10, 32)
+			if e != nil {
+				return nil, Wrapper(ErrFields, fmt.Sprintf("LoadLevels: bad header %s", meta))
 			}
+
+			nilLvl := int32(v)
+			lf.NilLvl = &nilLvl
 		}
+	}
 
-		fp.Lvl = lvl
-		ft.FP = &fp
-		fts = append(fts, &ft)
+	// recs[1] is the column header
+	for _, rec := range recs[2:] {
+		if len(rec) != 2 {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("LoadLevels: bad record %v", rec))
+		}
+
+		v, e := strconv.ParseInt(rec[1], 10, 32)
+		if e != nil {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("LoadLevels: bad code %s", rec[1]))
+		}
+
+		lf.Lvl[rec[0]] = int32(v)
 	}
-	return fts, err
+
+	return lf.levels()
+}
+
+// MergeLevels merges add into base. The codes of base are retained. Values in add that are not in base are
+// assigned codes after the largest code in base, in the order of their codes in add.
+func MergeLevels(base, add Levels) (Levels, error) {
+	lfBase, e := newLevelsFile(base)
+	if e != nil {
+		return nil, Wrapper(e, "MergeLevels")
+	}
+
+	lfAdd, e := newLevelsFile(add)
+	if e != nil {
+		return nil, Wrapper(e, "MergeLevels")
+	}
+
+	if lfBase.Kind != "" && lfAdd.Kind != "" && lfBase.Kind != lfAdd.Kind {
+		return nil, Wrapper(ErrFields, fmt.Sprintf("MergeLevels: kinds differ: %s and %s", lfBase.Kind, lfAdd.Kind))
+	}
+
+	merged := make(Levels)
+	next := int32(0)
+
+	for k, v := range base {
+		merged[k] = v
+		if v >= next {
+			next = v + 1
+		}
+	}
+
+	keys, _ := add.Sort(false, true)
+	for _, k := range keys {
+		if _, ok := merged[k]; ok {
+			continue
+		}
+
+		merged[k] = next
+		next++
+	}
+
+	return merged, nil
 }
@@ -1,7 +1,9 @@
 package seafan
 
 import (
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +19,7 @@ func TestFTypes_Save(t *testing.T) {
 		Scale:    0,
 		Default:  d[0],
 		Lvl:      lvl,
+		Frequent: d[1],
 	}
 	ft0 := &FType{
 		Name:       "Field0",
@@ -77,6 +80,7 @@ func TestFTypes_Save(t *testing.T) {
 		assert.Equal(t, ft1.FP.Location, ft.FP.Location)
 		assert.Equal(t, ft1.FP.Scale, ft.FP.Scale)
 		assert.Equal(t, ft1.FP.Default, ft.FP.Default)
+		assert.Equal(t, ft1.FP.Frequent, ft.FP.Frequent)
 		lvl1 := ft1.FP.Lvl
 		lvl := ft.FP.Lvl
 		if lvl == nil {
@@ -90,6 +94,52 @@ func TestFTypes_Save(t *testing.T) {
 	}
 }
 
+func TestLoadFTypesStrict(t *testing.T) {
+	dt := time.Date(2000, 11, 14, 10, 0, 0, 1, time.UTC)
+	lvl := Levels{dt: 0, time.Date(2001, 12, 21, 0, 0, 1, 0, time.UTC): 1, nil: -1}
+	fts := FTypes{&FType{Name: "Field0", Role: FRCat, Cats: 2, FP: &FParam{Default: dt, Lvl: lvl}},
+		&FType{Name: "Field1", Role: FRCat, Cats: 2, FP: &FParam{Default: int32(3), Lvl: Levels{int32(3): 0, int32(4): 1}}}}
+
+	fileName := os.TempDir() + "/seafanTestStrict.json"
+	e := fts.Save(fileName)
+	assert.Nil(t, e)
+
+	fts1, e := LoadFTypesStrict(fileName)
+	assert.Nil(t, e)
+
+	for ind, ft := range fts {
+		assert.Equal(t, ft.FP.Default, fts1[ind].FP.Default)
+		assert.Equal(t, len(ft.FP.Lvl), len(fts1[ind].FP.Lvl))
+
+		for k, v := range ft.FP.Lvl {
+			v1, ok := fts1[ind].FP.Lvl[k]
+			assert.True(t, ok)
+			assert.Equal(t, v, v1)
+		}
+	}
+
+	// version 1 files load
+	v1 := `[{"Name": "Field0", "Role": 1, "Cats": 2, "EmbCols": 0, "Normalized": false, "From": "",
+		"FP": {"location": 0, "scale": 0, "default": 3, "kind": "int32", "lvl": {"3": 0, "4": 1}}}]`
+	assert.Nil(t, os.WriteFile(fileName, []byte(v1), 0644))
+
+	fts1, e = LoadFTypesStrict(fileName)
+	assert.Nil(t, e)
+	assert.Equal(t, int32(3), fts1[0].FP.Default)
+	assert.Equal(t, int32(1), fts1[0].FP.Lvl[int32(4)])
+
+	// unknown kinds fail only when strict
+	bad := strings.ReplaceAll(v1, "int32", "float64")
+	assert.Nil(t, os.WriteFile(fileName, []byte(bad), 0644))
+
+	_, e = LoadFTypesStrict(fileName)
+	assert.NotNil(t, e)
+
+	fts1, e = LoadFTypes(fileName)
+	assert.Nil(t, e)
+	assert.Equal(t, 0, len(fts1[0].FP.Lvl))
+}
+
 func TestFTypes_DropFields(t *testing.T) {
 	d := []any{"z", "a", "r", "a", "b"}
 	lvl := ByPtr(NewRaw(d, nil))
@@ -149,3 +199,60 @@ func TestFTypes_DropFields(t *testing.T) {
 		assert.NotNil(t, ft)
 	}
 }
+
+func TestLevels_Save(t *testing.T) {
+	lvls := []Levels{{"a": 0, "b,c": 1, "d": 2, nil: -1},
+		{time.Date(2000, 11, 14, 10, 0, 0, 1, time.UTC): 0, time.Date(2001, 12, 21, 0, 0, 1, 0, time.UTC): 1},
+		{int64(10): 1, int64(-3): 0}}
+
+	for _, ext := range []string{".json", ".csv"} {
+		fileName := os.TempDir() + "/seafanLevels" + ext
+
+		for _, lvl := range lvls {
+			e := lvl.Save(fileName)
+			assert.Nil(t, e)
+
+			lvl1, e := LoadLevels(fileName)
+			assert.Nil(t, e)
+			assert.Equal(t, lvl, lvl1)
+		}
+
+		// the untampered file loads
+		contents, e := os.ReadFile(fileName)
+		assert.Nil(t, e)
+
+		lvl1, e := LoadLevels(fileName)
+		assert.Nil(t, e)
+		assert.Equal(t, lvls[2], lvl1)
+
+		// tamper with a code
+		tampered := strings.Replace(string(contents), "10", "11", 1)
+		assert.NotEqual(t, string(contents), tampered)
+		assert.Nil(t, os.WriteFile(fileName, []byte(tampered), 0644))
+
+		_, e = LoadLevels(fileName)
+		assert.True(t, errors.Is(e, ErrChecksum), ext)
+		assert.True(t, errors.Is(e, ErrFields), ext)
+
+		// restoring the contents restores the load
+		assert.Nil(t, os.WriteFile(fileName, contents, 0644))
+
+		lvl1, e = LoadLevels(fileName)
+		assert.Nil(t, e)
+		assert.Equal(t, lvls[2], lvl1)
+
+		_ = os.Remove(fileName)
+	}
+}
+
+func TestMergeLevels(t *testing.T) {
+	base := Levels{"a": 0, "b": 1}
+	add := Levels{"c": 0, "b": 1, "a": 2, "d": 3}
+
+	merged, e := MergeLevels(base, add)
+	assert.Nil(t, e)
+	assert.Equal(t, Levels{"a": 0, "b": 1, "c": 2, "d": 3}, merged)
+
+	_, e = MergeLevels(base, Levels{int32(1): 0})
+	assert.NotNil(t, e)
+}
@@ -13,11 +13,14 @@ func _() {
 	_ = x[FROneHot-2]
 	_ = x[FREmbed-3]
 	_ = x[FREither-4]
+	_ = x[FRSeq-5]
+	_ = x[FRDate-6]
+	_ = x[FROrdinal-7]
 }
 
-const _FRole_name = "FRCtsFRCatFROneHotFREmbedFREither"
+const _FRole_name = "FRCtsFRCatFROneHotFREmbedFREitherFRSeqFRDateFROrdinal"
 
-var _FRole_index = [...]uint8{0, 5, 10, 18, 25, 33}
+var _FRole_index = [...]uint8{0, 5, 10, 18, 25, 33, 38, 44, 53}
 
 func (i FRole) String() string {
 	if i < 0 || i >= FRole(len(_FRole_index)-1) {
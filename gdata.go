@@ -3,10 +3,12 @@ package seafan
 import (
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/invertedv/utilities"
@@ -21,8 +23,10 @@ import (
 type GDatum struct {
 	FT      *FType  // FT stores the details of the field: it's role, # categories, mappings
 	Summary Summary // Summary of the Data (e.g. distribution)
-	Data    any     // Data. This will be either []float64 (FRCts, FROneHot, FREmbed) or []int32 (FRCat)
+	Data    any     // Data. This will be either []float64 (FRCts, FROneHot, FREmbed), []float32 (FRCts with FT.Float32), []int32 (FRCat, FROrdinal) or []int64 (FRDate)
 	Raw     *Raw
+
+	unseen []bool // rows of a FRCat field whose values are not in its Levels, kept under UnseenAverage
 }
 
 type GData struct {
@@ -32,6 +36,8 @@ type GData struct {
 	sortData      *GDatum   // *GDatum of sortField
 	sortAscending bool      // sorts ascending, if true
 	currRow       int       // current row for reader
+
+	freezeMu sync.Mutex // serializes Freeze
 }
 
 // NewGData returns a new instance of GData
@@ -50,15 +56,262 @@ func (g *GDatum) Describe(topK int) string {
 	case FRCat:
 		str = fmt.Sprintf("%s\tTop 5 Values\n", str)
 		str = fmt.Sprintf("%s%s", str, "\t"+strings.ReplaceAll(g.Summary.DistrD.TopK(topK, false, false), "\n", "\n\t"))
+	case FROrdinal:
+		str = fmt.Sprintf("%s\tValues in Order\n", str)
+		str = fmt.Sprintf("%s%s", str, "\t"+strings.ReplaceAll(g.Summary.DistrD.TopKOrdered(topK, g.FT.FP.Lvl), "\n", "\n\t"))
+	case FRDate:
+		if lo, hi, ok := dateRange(g.Data.([]int64)); ok {
+			str = fmt.Sprintf("%s\tfrom %s to %s\n", str, dayDate(lo).Format("2006-01-02"), dayDate(hi).Format("2006-01-02"))
+		}
 	}
 
 	return str
 }
 
+// DescribeWeighted returns the top k values of the discrete field weighted by the field weightField along
+// with their share of the total weight. topK is # of values to return. If topK <= 0, all values are returned.
+func (gd *GData) DescribeWeighted(field, weightField string, topK int) (string, error) {
+	d := gd.Get(field)
+	if d == nil {
+		return "", Wrapper(ErrGData, fmt.Sprintf("DescribeWeighted: field %s not found", field))
+	}
+
+	if d.FT.Role != FRCat && d.FT.Role != FROrdinal {
+		return "", Wrapper(ErrGData, fmt.Sprintf("DescribeWeighted: field %s is not discrete", field))
+	}
+
+	raw, e := gd.GetRaw(field)
+	if e != nil {
+		return "", e
+	}
+
+	weights, e := gd.GetRaw(weightField)
+	if e != nil {
+		return "", e
+	}
+
+	wl, e := ByWeights(raw, weights, nil)
+	if e != nil {
+		return "", e
+	}
+
+	str := fmt.Sprintf("%s\tweighted by %s\n", d.FT.String(), weightField)
+	str = fmt.Sprintf("%s%s", str, "\t"+strings.ReplaceAll(wl.TopK(topK, false, false), "\n", "\n\t"))
+
+	return str, nil
+}
+
+// Summary returns a profile of the fields of gd with one row per field.  The fields of the profile are:
+//   - field, role: the name and FRole of the field.
+//   - n, missing: the number of rows and the number of missing values. Missing values are NaN for continuous fields
+//     and empty strings for discrete fields.
+//   - mean, std, q0, q10, q25, q50, q75, q90, q100: the mean, standard deviation and quantiles of continuous fields,
+//     excluding missing values.  Normalized fields are summarized on their original scale. NaN for other fields.
+//   - levels: the number of levels of discrete, one-hot and embedded fields. NaN for continuous fields.
+//   - top1, top1N, ..., topK, topKN: the topK most frequent levels of discrete fields and their counts.  topK may
+//     be 0, but not negative.
+//
+// The result can be exported with, e.g., PipeToCSV(NewVecData("summary", gdSumm), ...).
+func (gd *GData) Summary(topK int) (*GData, error) {
+	if gd.FieldCount() == 0 {
+		return nil, Wrapper(ErrGData, "(*GData) Summary: no fields")
+	}
+
+	if topK < 0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("(*GData) Summary: topK must be non-negative, got %d", topK))
+	}
+
+	u := []float64{0, .1, .25, .5, .75, .9, 1.0}
+	nFld := gd.FieldCount()
+
+	flds, roles := make([]any, nFld), make([]any, nFld)
+	n, miss, mean, std, lvls := make([]any, nFld), make([]any, nFld), make([]any, nFld), make([]any, nFld), make([]any, nFld)
+
+	qs := make([][]any, len(u))
+	for ind := range qs {
+		qs[ind] = make([]any, nFld)
+	}
+
+	tops, topN := make([][]any, topK), make([][]any, topK)
+	for ind := 0; ind < topK; ind++ {
+		tops[ind], topN[ind] = make([]any, nFld), make([]any, nFld)
+	}
+
+	for fi, d := range gd.data {
+		flds[fi], roles[fi], n[fi] = d.FT.Name, d.FT.Role.String(), float64(gd.rows)
+		miss[fi], mean[fi], std[fi], lvls[fi] = 0.0, math.NaN(), math.NaN(), float64(d.FT.Cats)
+
+		for ind := range qs {
+			qs[ind][fi] = math.NaN()
+		}
+
+		for ind := 0; ind < topK; ind++ {
+			tops[ind][fi], topN[ind][fi] = "", 0.0
+		}
+
+		switch d.FT.Role {
+		case FRCts:
+			lvls[fi] = math.NaN()
+			x := make([]float64, 0, gd.rows)
+
+			for _, xv := range d.Floats() {
+				if math.IsNaN(xv) {
+					continue
+				}
+
+				if d.FT.Normalized {
+					xv = xv*d.FT.FP.Scale + d.FT.FP.Location
+				}
+
+				x = append(x, xv)
+			}
+
+			miss[fi] = float64(gd.rows - len(x))
+			if len(x) == 0 {
+				continue
+			}
+
+			desc, _ := NewDesc(u, d.FT.Name)
+			desc.Populate(x, false, nil)
+
+			mean[fi], std[fi] = desc.Mean, desc.Std
+			for ind := range qs {
+				qs[ind][fi] = desc.Q[ind]
+			}
+		case FRCat, FROrdinal:
+			cnts := d.Summary.DistrD
+			miss[fi] = float64(cnts[""])
+
+			keys, vals := cnts.Sort(false, false)
+			for ind := 0; ind < utilities.MinInt(topK, len(keys)); ind++ {
+				tops[ind][fi], topN[ind][fi] = fmt.Sprintf("%v", keys[ind]), float64(vals[ind])
+			}
+		}
+	}
+
+	gdOut := NewGData()
+	cats := map[string][]any{"field": flds, "role": roles}
+	ctss := map[string][]any{"n": n, "missing": miss, "mean": mean, "std": std, "levels": lvls}
+
+	names := []string{"field", "role", "n", "missing", "mean", "std"}
+	for ind, uv := range u {
+		nm := fmt.Sprintf("q%d", int(100*uv+0.5))
+		names = append(names, nm)
+		ctss[nm] = qs[ind]
+	}
+
+	names = append(names, "levels")
+
+	for ind := 0; ind < topK; ind++ {
+		nm := fmt.Sprintf("top%d", ind+1)
+		names = append(names, nm, nm+"N")
+		cats[nm], ctss[nm+"N"] = tops[ind], topN[ind]
+	}
+
+	for _, nm := range names {
+		var e error
+		if x, ok := cats[nm]; ok {
+			e = gdOut.AppendD(NewRaw(x, nil), nm, nil, true)
+		} else {
+			e = gdOut.AppendC(NewRaw(ctss[nm], nil), nm, false, nil, true)
+		}
+
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return gdOut, nil
+}
+
 func (g *GDatum) String() string {
 	return g.Describe(0)
 }
 
+// Floats returns the data of a float field as []float64.  If the data is stored as []float32 (see ToFloat32), the
+// return is a converted copy, otherwise it is the data itself. Floats returns nil for FRCat fields.
+func (g *GDatum) Floats() []float64 {
+	switch x := g.Data.(type) {
+	case []float64:
+		return x
+	case []float32:
+		xOut := make([]float64, len(x))
+		for ind, xv := range x {
+			xOut[ind] = float64(xv)
+		}
+
+		return xOut
+	}
+
+	return nil
+}
+
+// floatAt returns row of the data of a FRCts field
+func (g *GDatum) floatAt(row int) float64 {
+	if x, ok := g.Data.([]float32); ok {
+		return float64(x[row])
+	}
+
+	return g.Data.([]float64)[row]
+}
+
+// setFloats sets the data of a FRCts field to x, stored at the precision of the field
+func (g *GDatum) setFloats(x []float64) {
+	if !g.FT.Float32 {
+		g.Data = x
+		return
+	}
+
+	g.Data = toFloat32(x)
+}
+
+// toFloat32 converts x to []float32
+func toFloat32(x []float64) []float32 {
+	xOut := make([]float32, len(x))
+	for ind, xv := range x {
+		xOut[ind] = float32(xv)
+	}
+
+	return xOut
+}
+
+// ToFloat32 stores the data of the FRCts fields as []float32, halving their memory.  The data is converted back to
+// float64 when it's batched and in summary statistics, so models and diagnostics are unaffected other than by the
+// loss of precision.  The FTypes of the fields have Float32 set, so pipelines built from them also store float32.
+func (gd *GData) ToFloat32(fields ...string) error {
+	for _, field := range fields {
+		d := gd.Get(field)
+		if d == nil {
+			return Wrapper(ErrGData, fmt.Sprintf("(*GData) ToFloat32: field %s not found", field))
+		}
+
+		if d.FT.Role != FRCts {
+			return Wrapper(ErrGData, fmt.Sprintf("(*GData) ToFloat32: field %s is not FRCts", field))
+		}
+
+		x, ok := d.Data.([]float64)
+		if !ok {
+			continue
+		}
+
+		// FTypes may be shared with other pipelines, so don't modify in place
+		d.FT = copyFType(d.FT, d.FT.Name)
+		d.FT.Float32 = true
+		d.setFloats(x)
+	}
+
+	return nil
+}
+
+// keepFloat32 stores field ft.Name of gd as float32 if ft calls for it
+func (gd *GData) keepFloat32(ft *FType) error {
+	if ft == nil || !ft.Float32 || ft.Role != FRCts {
+		return nil
+	}
+
+	return gd.ToFloat32(ft.Name)
+}
+
 // check performs a sanity check on GData
 func (gd *GData) check(name string) error {
 	if name != "" {
@@ -152,7 +405,8 @@ func (gd *GData) AppendC(raw *Raw, name string, normalize bool, fp *FParam, keep
 	return nil
 }
 
-// AppendD appends a discrete feature
+// AppendD appends a discrete feature.  If fp is nil or has no Lvl, the levels are built from raw and FParam Frequent
+// is set to the most frequent value of raw.
 func (gd *GData) AppendD(raw *Raw, name string, fp *FParam, keepRaw bool) error {
 	if e := gd.check(name); e != nil {
 		return e
@@ -161,6 +415,7 @@ func (gd *GData) AppendD(raw *Raw, name string, fp *FParam, keepRaw bool) error
 	// it could be that fp is populated only with the default value
 	if fp != nil && fp.Lvl == nil {
 		fp.Lvl = ByPtr(raw)
+		fp.Frequent = mostFrequent(raw, fp.Lvl)
 
 		if _, ok := fp.Lvl[fp.Default]; !ok {
 			fp.Lvl[fp.Default] = -1
@@ -169,7 +424,7 @@ func (gd *GData) AppendD(raw *Raw, name string, fp *FParam, keepRaw bool) error
 
 	if fp == nil {
 		lv := ByPtr(raw)
-		fp = &FParam{Lvl: lv}
+		fp = &FParam{Lvl: lv, Frequent: mostFrequent(raw, lv)}
 	}
 
 	if raw.Kind == reflect.Float64 || raw.Kind == reflect.Float32 {
@@ -180,20 +435,9 @@ func (gd *GData) AppendD(raw *Raw, name string, fp *FParam, keepRaw bool) error
 		return fmt.Errorf("differing # of rows *GData.AppendD: %d and %d", gd.rows, raw.Len())
 	}
 
-	ds := make([]int32, raw.Len())
-
-	for ind := 0; ind < len(ds); ind++ {
-		v := raw.Data[ind]
-		val, ok := fp.Lvl[v]
-
-		if !ok {
-			val, ok = fp.Lvl[fp.Default]
-			if !ok {
-				return Wrapper(ErrGData, fmt.Sprintf("AppendD: default value %v not in dictionary, field %s", fp.Default, name))
-			}
-		}
-
-		ds[ind] = val
+	ds, unseen, e := mapLevels(raw, fp, name)
+	if e != nil {
+		return e
 	}
 
 	distr := ByCounts(raw, nil)
@@ -213,6 +457,13 @@ func (gd *GData) AppendD(raw *Raw, name string, fp *FParam, keepRaw bool) error
 	}
 	d := &GDatum{Data: ds, FT: ft, Summary: summ}
 
+	if unseen != nil {
+		d.Summary.Unseen = countTrue(unseen)
+		if fp.Unseen == UnseenAverage {
+			d.unseen = unseen
+		}
+	}
+
 	if keepRaw {
 		d.Raw = raw
 	}
@@ -227,6 +478,204 @@ func (gd *GData) AppendD(raw *Raw, name string, fp *FParam, keepRaw bool) error
 	return nil
 }
 
+// AppendOrdinal appends an ordinal feature: a discrete feature whose levels are ordered.  order lists the levels
+// from lowest to highest. If order is nil, the levels are ordered by their values. The mapped value of a level is
+// its place in the order, so comparisons of the mapped values follow the order.
+func (gd *GData) AppendOrdinal(raw *Raw, name string, order []any, keepRaw bool) error {
+	var fp *FParam
+
+	if order != nil {
+		fp = &FParam{Lvl: make(Levels)}
+		for ind, lvl := range order {
+			if _, ok := fp.Lvl[lvl]; ok {
+				return Wrapper(ErrGData, fmt.Sprintf("AppendOrdinal: level %v repeated in order, field %s", lvl, name))
+			}
+
+			fp.Lvl[lvl] = int32(ind)
+		}
+
+		fp.Unseen = UnseenError
+	}
+
+	return gd.appendOrdinal(raw, name, fp, keepRaw)
+}
+
+// appendOrdinal appends an ordinal feature using the levels of fp.  If fp is nil, the levels are ordered by value.
+func (gd *GData) appendOrdinal(raw *Raw, name string, fp *FParam, keepRaw bool) error {
+	if e := gd.AppendD(raw, name, fp, keepRaw); e != nil {
+		return e
+	}
+
+	gd.data[len(gd.data)-1].FT.Role = FROrdinal
+
+	return nil
+}
+
+// AppendDate appends a date feature.  The values of raw are dates or values that convert to dates (e.g. strings
+// in CCYYMMDD format).  The dates are stored as days since 1/1/1970, so the time of day is dropped.  GetRaw returns
+// the dates as time.Time in UTC.
+func (gd *GData) AppendDate(raw *Raw, name string, keepRaw bool) error {
+	if e := gd.check(name); e != nil {
+		return e
+	}
+
+	if gd.rows > 0 && gd.rows != raw.Len() {
+		return fmt.Errorf("differing # of rows *GData.AppendDate: %d and %d", gd.rows, raw.Len())
+	}
+
+	days := make([]int64, raw.Len())
+
+	for ind := 0; ind < len(days); ind++ {
+		dt, e := any2Date(raw.Data[ind])
+		if e != nil {
+			return Wrapper(ErrGData, fmt.Sprintf("AppendDate: cannot convert %v to date, field %s", raw.Data[ind], name))
+		}
+
+		days[ind] = dateDay(dt)
+	}
+
+	ft := &FType{
+		Name: name,
+		Role: FRDate,
+		FP:   &FParam{},
+	}
+	d := &GDatum{Data: days, FT: ft, Summary: Summary{NRows: len(days)}}
+
+	if keepRaw {
+		d.Raw = raw
+	}
+
+	gd.data = append(gd.data, d)
+	gd.rows = len(days)
+
+	return gd.check("")
+}
+
+// secPerDay is the number of seconds in a day
+const secPerDay = 24 * 60 * 60
+
+// dateDay returns the number of days from 1/1/1970 to the date of dt
+func dateDay(dt time.Time) int64 {
+	y, m, d := dt.Date()
+	sec := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()
+
+	// round toward -infinity for dates before 1970
+	day := sec / secPerDay
+	if sec%secPerDay < 0 {
+		day--
+	}
+
+	return day
+}
+
+// dayDate is the inverse of dateDay
+func dayDate(day int64) time.Time {
+	return time.Unix(day*secPerDay, 0).UTC()
+}
+
+// dateRange returns the first and last days of days. ok is false if days is empty.
+func dateRange(days []int64) (lo, hi int64, ok bool) {
+	if len(days) == 0 {
+		return 0, 0, false
+	}
+
+	lo, hi = days[0], days[0]
+	for _, day := range days {
+		lo, hi = min(lo, day), max(hi, day)
+	}
+
+	return lo, hi, true
+}
+
+// mostFrequent returns the value of raw in lvl that occurs most often.  Ties go to the lower level.
+func mostFrequent(raw *Raw, lvl Levels) any {
+	var (
+		freq any
+		most int32
+	)
+
+	for val, n := range ByCounts(raw, nil) {
+		l, ok := lvl[val]
+		if !ok {
+			continue
+		}
+
+		if n > most || (n == most && l < lvl[freq]) {
+			freq, most = val, n
+		}
+	}
+
+	return freq
+}
+
+// unseenLevel returns the level that values not in fp.Lvl map to under fp.Unseen: Frequent under
+// UnseenMostFrequent, if it is set, else Default.  ok is false if there is no such level.
+func unseenLevel(fp *FParam) (lvl int32, ok bool) {
+	if fp.Unseen == UnseenMostFrequent && fp.Frequent != nil {
+		if lvl, ok = fp.Lvl[fp.Frequent]; ok {
+			return lvl, true
+		}
+	}
+
+	lvl, ok = fp.Lvl[fp.Default]
+
+	return lvl, ok
+}
+
+// mapLevels maps the values of raw to their levels in fp.Lvl.  Values not in fp.Lvl are treated as set by
+// fp.Unseen.  unseen is nil if all the values are in fp.Lvl.
+func mapLevels(raw *Raw, fp *FParam, name string) (ds []int32, unseen []bool, err error) {
+	ds = make([]int32, raw.Len())
+
+	for ind := 0; ind < len(ds); ind++ {
+		val, ok := fp.Lvl[raw.Data[ind]]
+		if ok {
+			ds[ind] = val
+
+			continue
+		}
+
+		if fp.Unseen == UnseenError {
+			return nil, nil, Wrapper(ErrGData, fmt.Sprintf("AppendD: value %v not in dictionary, field %s", raw.Data[ind], name))
+		}
+
+		if unseen == nil {
+			unseen = make([]bool, len(ds))
+		}
+
+		unseen[ind] = true
+	}
+
+	if unseen == nil {
+		return ds, nil, nil
+	}
+
+	fill, ok := unseenLevel(fp)
+	if !ok {
+		return nil, nil, Wrapper(ErrGData, fmt.Sprintf("AppendD: default value %v not in dictionary, field %s", fp.Default, name))
+	}
+
+	for ind, isUnseen := range unseen {
+		if isUnseen {
+			ds[ind] = fill
+		}
+	}
+
+	return ds, unseen, nil
+}
+
+// countTrue returns the number of elements of x that are true
+func countTrue(x []bool) int {
+	n := 0
+	for _, v := range x {
+		if v {
+			n++
+		}
+	}
+
+	return n
+}
+
 // MakeOneHot creates & appends a one hot feature from a discrete feature
 func (gd *GData) MakeOneHot(from, name string) error {
 	if e := gd.check(name); e != nil {
@@ -239,16 +688,39 @@ func (gd *GData) MakeOneHot(from, name string) error {
 		return Wrapper(ErrGData, fmt.Sprintf("MakeOneHot: 'from' feature %s not found", from))
 	}
 
-	if d.FT.Role != FRCat {
+	if d.FT.Role != FRCat && d.FT.Role != FROrdinal {
 		return Wrapper(ErrGData, fmt.Sprintf("MakeOneHot: input %s is not discrete", from))
 	}
 
 	nRow := d.Summary.NRows
 	nCat := len(d.FT.FP.Lvl)
 	oh := make([]float64, nRow*nCat)
+	cume := d.FT.Role == FROrdinal
 
 	for row := 0; row < nRow; row++ {
-		oh[int32(row*nCat)+d.Data.([]int32)[row]] = 1
+		// values not in the levels get the average under UnseenAverage
+		if d.unseen != nil && d.unseen[row] {
+			for col := 0; col < nCat; col++ {
+				oh[row*nCat+col] = 1 / float64(nCat)
+				if cume {
+					oh[row*nCat+col] = float64(nCat-col) / float64(nCat)
+				}
+			}
+
+			continue
+		}
+
+		// ordinal fields have cumulative coding: column k is 1 if the level is at least the kth level
+		lvl := int(d.Data.([]int32)[row])
+		if cume {
+			for col := 0; col <= lvl; col++ {
+				oh[row*nCat+col] = 1
+			}
+
+			continue
+		}
+
+		oh[row*nCat+lvl] = 1
 	}
 
 	summ := Summary{NRows: d.Summary.NRows}
@@ -313,9 +785,10 @@ func (gd *GData) Slice(sl Slicer) (*GData, error) {
 	for _, g := range gd.data {
 		ft := g.FT
 		switch role := ft.Role; role {
-		// These are all float64, but FROneHot and FREmbed are matrices
-		case FRCts, FROneHot, FREmbed:
+		// These are all float64, but FROneHot, FREmbed and FRSeq are matrices
+		case FRCts, FROneHot, FREmbed, FRSeq:
 			cats := utilities.MaxInt(1, ft.Cats)
+			x := g.Floats()
 
 			d := make([]float64, 0)
 			n := 0
@@ -323,7 +796,7 @@ func (gd *GData) Slice(sl Slicer) (*GData, error) {
 				if sl(row) {
 					n++
 					for r := 0; r < cats; r++ {
-						d = append(d, g.Data.([]float64)[row*cats+r])
+						d = append(d, x[row*cats+r])
 					}
 				}
 			}
@@ -342,6 +815,7 @@ func (gd *GData) Slice(sl Slicer) (*GData, error) {
 				Normalized: ft.Normalized,
 				From:       ft.From,
 				FP:         fp,
+				Float32:    ft.Float32,
 			}
 			desc, e := NewDesc(nil, ft.Name)
 			if e != nil {
@@ -358,16 +832,22 @@ func (gd *GData) Slice(sl Slicer) (*GData, error) {
 			datum := &GDatum{
 				FT:      ftNew,
 				Summary: summ,
-				Data:    d,
 			}
+			datum.setFloats(d)
 			gOut.data = append(gOut.data, datum)
 			gOut.rows = n
 
-		case FRCat:
+		case FRCat, FROrdinal:
+			var unseen []bool
+
 			d := make([]int32, 0)
 			for row := 0; row < g.Summary.NRows; row++ {
 				if sl(row) {
 					d = append(d, g.Data.([]int32)[row])
+
+					if g.unseen != nil {
+						unseen = append(unseen, g.unseen[row])
+					}
 				}
 			}
 
@@ -375,7 +855,7 @@ func (gd *GData) Slice(sl Slicer) (*GData, error) {
 				return nil, Wrapper(ErrGData, "slice result is empty")
 			}
 
-			fp := &FParam{Lvl: ft.FP.Lvl, Default: ft.FP.Default}
+			fp := &FParam{Lvl: ft.FP.Lvl, Default: ft.FP.Default, Unseen: ft.FP.Unseen, Frequent: ft.FP.Frequent}
 			ftNew := &FType{
 				Name:       ft.Name,
 				Role:       ft.Role,
@@ -407,6 +887,32 @@ func (gd *GData) Slice(sl Slicer) (*GData, error) {
 				FT:      ftNew,
 				Summary: summ,
 				Data:    d,
+				unseen:  unseen,
+			}
+
+			if unseen != nil {
+				datum.Summary.Unseen = countTrue(unseen)
+			}
+
+			gOut.rows = len(d)
+			gOut.data = append(gOut.data, datum)
+
+		case FRDate:
+			d := make([]int64, 0)
+			for row := 0; row < g.Summary.NRows; row++ {
+				if sl(row) {
+					d = append(d, g.Data.([]int64)[row])
+				}
+			}
+
+			if len(d) == 0 {
+				return nil, Wrapper(ErrGData, "slice result is empty")
+			}
+
+			datum := &GDatum{
+				FT:      copyFType(ft, ft.Name),
+				Summary: Summary{NRows: len(d)},
+				Data:    d,
 			}
 			gOut.rows = len(d)
 			gOut.data = append(gOut.data, datum)
@@ -422,22 +928,37 @@ func (gd *GData) Swap(i, j int) {
 	for ind := 0; ind < len(gd.data); ind++ {
 		switch gd.data[ind].FT.Role {
 		case FRCts:
-			gd.data[ind].Data.([]float64)[i], gd.data[ind].Data.([]float64)[j] = gd.data[ind].Data.([]float64)[j], gd.data[ind].Data.([]float64)[i]
+			switch x := gd.data[ind].Data.(type) {
+			case []float64:
+				x[i], x[j] = x[j], x[i]
+			case []float32:
+				x[i], x[j] = x[j], x[i]
+			}
 
 			// if *Raw data isn't nil, must swap it, too
 			if gd.data[ind].Raw != nil {
 				gd.data[ind].Raw.Data[i], gd.data[ind].Raw.Data[j] = gd.data[ind].Raw.Data[j], gd.data[ind].Raw.Data[i]
 			}
-		case FRCat:
+		case FRCat, FROrdinal:
 			gd.data[ind].Data.([]int32)[i], gd.data[ind].Data.([]int32)[j] = gd.data[ind].Data.([]int32)[j], gd.data[ind].Data.([]int32)[i]
 
+			if gd.data[ind].unseen != nil {
+				gd.data[ind].unseen[i], gd.data[ind].unseen[j] = gd.data[ind].unseen[j], gd.data[ind].unseen[i]
+			}
+
 			if gd.data[ind].Raw != nil {
 				gd.data[ind].Raw.Data[i], gd.data[ind].Raw.Data[j] = gd.data[ind].Raw.Data[j], gd.data[ind].Raw.Data[i]
 			}
-		case FROneHot, FREmbed:
-			cats := gd.data[ind].FT.Cats
-			for c := 0; c < cats; c++ {
-				gd.data[ind].Data.([]float64)[i*cats+c], gd.data[ind].Data.([]float64)[j*cats+c] =
+		case FRDate:
+			gd.data[ind].Data.([]int64)[i], gd.data[ind].Data.([]int64)[j] = gd.data[ind].Data.([]int64)[j], gd.data[ind].Data.([]int64)[i]
+
+			if gd.data[ind].Raw != nil {
+				gd.data[ind].Raw.Data[i], gd.data[ind].Raw.Data[j] = gd.data[ind].Raw.Data[j], gd.data[ind].Raw.Data[i]
+			}
+		case FROneHot, FREmbed, FRSeq:
+			cats := gd.data[ind].FT.Cats
+			for c := 0; c < cats; c++ {
+				gd.data[ind].Data.([]float64)[i*cats+c], gd.data[ind].Data.([]float64)[j*cats+c] =
 					gd.data[ind].Data.([]float64)[j*cats+c], gd.data[ind].Data.([]float64)[i*cats+c]
 			}
 		}
@@ -452,21 +973,27 @@ func (gd *GData) Less(i, j int) bool {
 	switch gd.sortData.FT.Role {
 	case FRCts:
 		if gd.sortAscending {
-			return gd.sortData.Data.([]float64)[i] < gd.sortData.Data.([]float64)[j]
+			return gd.sortData.floatAt(i) < gd.sortData.floatAt(j)
 		}
-		return gd.sortData.Data.([]float64)[i] > gd.sortData.Data.([]float64)[j]
-	case FRCat:
+		return gd.sortData.floatAt(i) > gd.sortData.floatAt(j)
+	case FRCat, FROrdinal:
 		if gd.sortAscending {
 			return gd.sortData.Data.([]int32)[i] < gd.sortData.Data.([]int32)[j]
 		}
 		return gd.sortData.Data.([]int32)[i] > gd.sortData.Data.([]int32)[j]
+	case FRDate:
+		if gd.sortAscending {
+			return gd.sortData.Data.([]int64)[i] < gd.sortData.Data.([]int64)[j]
+		}
+		return gd.sortData.Data.([]int64)[i] > gd.sortData.Data.([]int64)[j]
 	}
 
 	return false
 }
 
 // Sort sorts the GData on field.  Calling Sort.Sort directly will cause a panic.
-// Sorting a OneHot or Embedded field sorts on the underlying Categorical field
+// Sorting a OneHot or Embedded field sorts on the underlying Categorical field.
+// The sort is stable.  The order of field is found first and then applied once to each field.
 func (gd *GData) Sort(field string, ascending bool) error {
 	defer func() { gd.sortData = nil }()
 
@@ -487,11 +1014,73 @@ func (gd *GData) Sort(field string, ascending bool) error {
 	}
 
 	gd.sortData = gDatum
-	sort.Sort(gd)
+
+	perm := make([]int, gd.rows)
+	for ind := range perm {
+		perm[ind] = ind
+	}
+
+	sort.SliceStable(perm, func(i, j int) bool { return gd.Less(perm[i], perm[j]) })
+	gd.permute(perm)
+
 	gd.sortField = field
 	return nil
 }
 
+// permute reorders the rows of gd so that row i is the old row perm[i].  The data stay in the same slices.
+func (gd *GData) permute(perm []int) {
+	var (
+		fBuf   []float64
+		f32Buf []float32
+		iBuf   []int32
+		dBuf   []int64
+		aBuf   []any
+	)
+
+	for _, d := range gd.data {
+		switch d.FT.Role {
+		case FRCts:
+			switch x := d.Data.(type) {
+			case []float64:
+				fBuf = permuteSlice(x, perm, 1, fBuf)
+			case []float32:
+				f32Buf = permuteSlice(x, perm, 1, f32Buf)
+			}
+		case FRCat, FROrdinal:
+			iBuf = permuteSlice(d.Data.([]int32), perm, 1, iBuf)
+			if d.unseen != nil {
+				permuteSlice(d.unseen, perm, 1, nil)
+			}
+		case FRDate:
+			dBuf = permuteSlice(d.Data.([]int64), perm, 1, dBuf)
+		case FROneHot, FREmbed, FRSeq:
+			fBuf = permuteSlice(d.Data.([]float64), perm, d.FT.Cats, fBuf)
+		}
+
+		if d.Raw != nil && d.FT.Role != FROneHot && d.FT.Role != FREmbed && d.FT.Role != FRSeq {
+			aBuf = permuteSlice(d.Raw.Data, perm, 1, aBuf)
+		}
+	}
+}
+
+// permuteSlice reorders x, which has width elements per row, so that row i is the old row perm[i].  buf is scratch
+// space, which is returned for reuse.
+func permuteSlice[T any](x []T, perm []int, width int, buf []T) []T {
+	if cap(buf) < len(x) {
+		buf = make([]T, len(x))
+	}
+
+	buf = buf[:len(x)]
+
+	for row, from := range perm {
+		copy(buf[row*width:(row+1)*width], x[from*width:(from+1)*width])
+	}
+
+	copy(x, buf)
+
+	return buf
+}
+
 // IsSorted returns true if GData has been sorted by SortField
 func (gd *GData) IsSorted() bool {
 	return gd.sortField != ""
@@ -504,9 +1093,18 @@ func (gd *GData) SortField() string {
 
 // Shuffle shuffles the GData fields as a unit
 func (gd *GData) Shuffle() {
+	gd.shuffle(nil)
+}
+
+// shuffle shuffles gd using rnd. If rnd is nil, the package source is used.
+func (gd *GData) shuffle(rnd *rand.Rand) {
 	gd.sortField = ""
 
-	rand.Shuffle(gd.Len(), gd.Swap)
+	if rnd == nil {
+		rnd = rng
+	}
+
+	rnd.Shuffle(gd.Len(), gd.Swap)
 }
 
 // GetData returns the slice of *GDatums
@@ -532,23 +1130,31 @@ func (gd *GData) GetRaw(field string) (*Raw, error) {
 	case FRCts:
 		switch fd.FT.Normalized {
 		case false:
-			fd.Raw = NewRawCast(fd.Data.([]float64), nil)
+			fd.Raw = NewRawCast(fd.Floats(), nil)
 		case true:
 			x := make([]any, gd.rows)
 			for ind := 0; ind < len(x); ind++ {
-				x[ind] = fd.Data.([]float64)[ind]*fd.FT.FP.Scale + fd.FT.FP.Location
+				x[ind] = fd.floatAt(ind)*fd.FT.FP.Scale + fd.FT.FP.Location
 			}
 			fd.Raw = NewRaw(x, nil)
 		}
-	case FRCat:
+	case FRCat, FROrdinal:
 		key, _ := fd.FT.FP.Lvl.Sort(false, true)
 		x := make([]any, gd.rows)
 		for ind := 0; ind < len(x); ind++ {
 			x[ind] = key[int(fd.Data.([]int32)[ind])]
 		}
 		fd.Raw = NewRaw(x, nil)
+	case FRDate:
+		x := make([]any, gd.rows)
+		for ind := 0; ind < len(x); ind++ {
+			x[ind] = dayDate(fd.Data.([]int64)[ind])
+		}
+		fd.Raw = NewRaw(x, nil)
 	case FROneHot, FREmbed:
 		return gd.GetRaw(fd.FT.From)
+	case FRSeq:
+		return nil, Wrapper(ErrGData, fmt.Sprintf("(*GData) GetRaw: sequence field %s has no raw data", field))
 	}
 
 	return fd.Raw, nil
@@ -584,15 +1190,27 @@ func (gd *GData) UpdateFts(newFts FTypes) (*GData, error) {
 			if e := newGd.AppendC(raw, newFt.Name, newFt.Normalized, newFt.FP, false); e != nil {
 				return nil, e
 			}
+
+			if e := newGd.keepFloat32(newFt); e != nil {
+				return nil, e
+			}
 		case FRCat:
 			if e := newGd.AppendD(raw, newFt.Name, newFt.FP, false); e != nil {
 				return nil, e
 			}
+		case FROrdinal:
+			if e := newGd.appendOrdinal(raw, newFt.Name, newFt.FP, false); e != nil {
+				return nil, e
+			}
+		case FRDate:
+			if e := newGd.AppendDate(raw, newFt.Name, false); e != nil {
+				return nil, e
+			}
 		}
 	}
 
 	for _, newFt := range newFts {
-		if newFt.Role == FRCts || newFt.Role == FRCat {
+		if newFt.Role == FRCts || newFt.Role == FRCat || newFt.Role == FROrdinal || newFt.Role == FRDate {
 			continue
 		}
 
@@ -651,9 +1269,192 @@ func (gd *GData) Keep(fields []string) error {
 	return nil
 }
 
+// Rename renames field oldName to newName. The FType metadata is retained. Fields built from oldName
+// (e.g. one-hot fields) are updated to point to newName.
+func (gd *GData) Rename(oldName, newName string) error {
+	datum := gd.Get(oldName)
+	if datum == nil {
+		return fmt.Errorf("field %s not found, (*GData) Rename", oldName)
+	}
+
+	if e := gd.check(newName); e != nil {
+		return e
+	}
+
+	// FTypes may be shared with other pipelines, so don't modify in place
+	datum.FT = copyFType(datum.FT, newName)
+
+	if datum.Summary.DistrC != nil {
+		dc := *datum.Summary.DistrC
+		dc.Name = newName
+		datum.Summary.DistrC = &dc
+	}
+
+	for _, d := range gd.data {
+		if d.FT.From == oldName {
+			d.FT = copyFType(d.FT, d.FT.Name)
+			d.FT.From = newName
+		}
+	}
+
+	if gd.sortField == oldName {
+		gd.sortField = newName
+	}
+
+	return nil
+}
+
+// CopyField adds a copy of field src to gd with name dst. The FType metadata is retained.
+func (gd *GData) CopyField(src, dst string) error {
+	datum := gd.Get(src)
+	if datum == nil {
+		return fmt.Errorf("field %s not found, (*GData) CopyField", src)
+	}
+
+	if e := gd.check(dst); e != nil {
+		return e
+	}
+
+	newDatum := &GDatum{FT: copyFType(datum.FT, dst), Summary: Summary{NRows: datum.Summary.NRows}}
+
+	switch x := datum.Data.(type) {
+	case []float64:
+		xNew := make([]float64, len(x))
+		copy(xNew, x)
+		newDatum.Data = xNew
+	case []float32:
+		xNew := make([]float32, len(x))
+		copy(xNew, x)
+		newDatum.Data = xNew
+	case []int32:
+		xNew := make([]int32, len(x))
+		copy(xNew, x)
+		newDatum.Data = xNew
+	case []int64:
+		xNew := make([]int64, len(x))
+		copy(xNew, x)
+		newDatum.Data = xNew
+	}
+
+	if datum.Raw != nil {
+		rawNew := make([]any, datum.Raw.Len())
+		copy(rawNew, datum.Raw.Data)
+		newDatum.Raw = NewRaw(rawNew, nil)
+	}
+
+	if datum.Summary.DistrC != nil {
+		dc := *datum.Summary.DistrC
+		dc.Name = dst
+		newDatum.Summary.DistrC = &dc
+	}
+
+	if datum.Summary.DistrD != nil {
+		newDatum.Summary.DistrD = make(Levels)
+		for k, v := range datum.Summary.DistrD {
+			newDatum.Summary.DistrD[k] = v
+		}
+	}
+
+	gd.data = append(gd.data, newDatum)
+
+	return nil
+}
+
+// Compact rebuilds the data of gd so that each slice uses only the memory it needs. If keepRaw is false, the *Raw
+// data is dropped (it can be recreated by GetRaw). Compact returns an estimate of the bytes reclaimed.
+func (gd *GData) Compact(keepRaw bool) (reclaimed int) {
+	for _, d := range gd.data {
+		switch x := d.Data.(type) {
+		case []float64:
+			xNew := make([]float64, len(x))
+			copy(xNew, x)
+			d.Data = xNew
+			reclaimed += 8 * (cap(x) - len(x))
+		case []float32:
+			xNew := make([]float32, len(x))
+			copy(xNew, x)
+			d.Data = xNew
+			reclaimed += 4 * (cap(x) - len(x))
+		case []int32:
+			xNew := make([]int32, len(x))
+			copy(xNew, x)
+			d.Data = xNew
+			reclaimed += 4 * (cap(x) - len(x))
+		case []int64:
+			xNew := make([]int64, len(x))
+			copy(xNew, x)
+			d.Data = xNew
+			reclaimed += 8 * (cap(x) - len(x))
+		}
+
+		if d.Raw == nil {
+			continue
+		}
+
+		// if Data is nil (e.g. after AppendRowsRaw), *Raw is all there is
+		if !keepRaw && d.Data != nil {
+			reclaimed += rawBytes(d.Raw, cap(d.Raw.Data))
+			d.Raw = nil
+
+			continue
+		}
+
+		rawNew := make([]any, d.Raw.Len())
+		copy(rawNew, d.Raw.Data)
+		reclaimed += rawBytes(d.Raw, cap(d.Raw.Data)-d.Raw.Len())
+		d.Raw = &Raw{Kind: d.Raw.Kind, Data: rawNew}
+	}
+
+	// the sort data may be a field that has been dropped
+	if gd.sortData != nil && gd.Get(gd.sortField) != gd.sortData {
+		gd.sortField, gd.sortData = "", nil
+	}
+
+	return reclaimed
+}
+
+// rawBytes estimates the memory used by n elements of raw
+func rawBytes(raw *Raw, n int) int {
+	// each element of []any is a 16 byte interface plus the value
+	size := 16
+
+	switch raw.Kind {
+	case reflect.Float64, reflect.Int64, reflect.Int:
+		size += 8
+	case reflect.Float32, reflect.Int32:
+		size += 4
+	case reflect.String:
+		size += 16
+	case reflect.Struct:
+		size += 24
+	}
+
+	return n * size
+}
+
+// copyFType returns a copy of ft with Name name. The FParam is also copied.
+func copyFType(ft *FType, name string) *FType {
+	ftNew := *ft
+	ftNew.Name = name
+
+	if ft.FP != nil {
+		fp := *ft.FP
+		ftNew.FP = &fp
+	}
+
+	return &ftNew
+}
+
 // Read reads row(s) in the format of chutils.  Note: valids are all chutils.Valid.  Invoking Read for the first
-// time causes it to recreate the raw data of existing fields -- so the memory requirement will go up.
+// time causes it to recreate the raw data of existing fields -- so the memory requirement will go up.  See
+// NewStream for a reader that does not.  As in TableSpec, FROneHot, FREmbed and FRSeq fields are not read.
 func (gd *GData) Read(nTarget int, validate bool) (data []chutils.Row, valid []chutils.Valid, err error) {
+	return gd.read(&gd.currRow, nTarget, gd.rawValue)
+}
+
+// read reads nTarget rows starting at *currRow, updating *currRow.  value returns the value of a field at a row.
+func (gd *GData) read(currRow *int, nTarget int, value func(datum *GDatum, row int) (any, error)) (
+	data []chutils.Row, valid []chutils.Valid, err error) {
 	if nTarget <= 0 {
 		return nil, nil, fmt.Errorf("(*GData) Read invalid nTarget")
 	}
@@ -661,30 +1462,28 @@ func (gd *GData) Read(nTarget int, validate bool) (data []chutils.Row, valid []c
 	data = make([]chutils.Row, 0)
 	valid = make([]chutils.Valid, 0)
 
-	for row := gd.currRow; row < gd.currRow+nTarget; row++ {
-		gd.currRow = row
+	for row := *currRow; row < *currRow+nTarget; row++ {
+		*currRow = row
 		rows := make(chutils.Row, 0)
 		valids := make(chutils.Valid, 0)
 
 		if row >= gd.rows {
 			err = io.EOF
-			gd.currRow = 0
+			*currRow = 0
 			return
 		}
 
 		for col := 0; col < len(gd.data); col++ {
-			var e error
 			datum := gd.data[col]
-			if datum.Raw == nil {
-				datum.Raw, e = gd.GetRaw(datum.FT.Name)
-				if e != nil {
-					return nil, nil, e
-				}
-			}
-			if datum.FT.Role == FREmbed || datum.FT.Role == FROneHot {
+			if datum.FT.Role == FREmbed || datum.FT.Role == FROneHot || datum.FT.Role == FRSeq {
 				continue
 			}
-			x := gd.data[col].Raw.Data[row]
+
+			x, e := value(datum, row)
+			if e != nil {
+				return nil, nil, e
+			}
+
 			rows = append(rows, x)
 		}
 
@@ -692,10 +1491,147 @@ func (gd *GData) Read(nTarget int, validate bool) (data []chutils.Row, valid []c
 		valid = append(valid, valids)
 	}
 
-	gd.currRow++ // set to next row
+	*currRow++ // set to next row
 	return data, valid, err
 }
 
+// rawValue returns the value of datum at row from its *Raw, which is created if needed
+func (gd *GData) rawValue(datum *GDatum, row int) (any, error) {
+	if datum.Raw == nil {
+		var e error
+		if datum.Raw, e = gd.GetRaw(datum.FT.Name); e != nil {
+			return nil, e
+		}
+	}
+
+	return datum.Raw.Data[row], nil
+}
+
+// Freeze fills in the *Raw data of the fields, which GetRaw and Read otherwise create when first needed.  After
+// Freeze, reading gd does not change it, so any number of goroutines may read it at once using GetRaw, NewReader
+// or pipelines made by NewCursor.  Freeze may itself be called by several goroutines at once: the calls are
+// serialized, and each returns once the *Raw data are filled in.  Changing gd (e.g. Sort, AppendC, Drop) while it
+// is being read is not safe.
+func (gd *GData) Freeze() error {
+	gd.freezeMu.Lock()
+	defer gd.freezeMu.Unlock()
+
+	for _, datum := range gd.data {
+		if datum.Raw != nil || datum.FT.Role == FRSeq {
+			continue
+		}
+
+		var e error
+		if datum.Raw, e = gd.GetRaw(datum.FT.Name); e != nil {
+			return Wrapper(e, "(*GData) Freeze")
+		}
+	}
+
+	return nil
+}
+
+// GDataReader reads a *GData with a cursor of its own, so that a *GData can have several readers at once.  It
+// implements chutils.Input.
+type GDataReader struct {
+	gd      *GData
+	currRow int
+
+	stream bool             // if true, values are built row by row rather than from the *Raw data
+	levels map[string][]any // values of the levels of FRCat fields, by field, for streaming
+}
+
+// NewReader freezes gd (see Freeze) and returns a reader of it with its own cursor.
+func (gd *GData) NewReader() (*GDataReader, error) {
+	if e := gd.Freeze(); e != nil {
+		return nil, e
+	}
+
+	return &GDataReader{gd: gd}, nil
+}
+
+// NewStream returns a reader of gd with its own cursor that does not create the *Raw data of the fields.  Values
+// are rebuilt as each row is read: continuous fields are un-normalized and categorical fields are mapped back to
+// their levels.  Where a field already has its *Raw data, that is used.  The memory needed to export gd is then
+// a row, rather than a copy of gd.  A stream, like the readers of NewReader, does not change gd.
+func (gd *GData) NewStream() *GDataReader {
+	return &GDataReader{gd: gd, stream: true, levels: make(map[string][]any)}
+}
+
+// Read reads row(s) in the format of chutils.  See (*GData) Read.
+func (rdr *GDataReader) Read(nTarget int, validate bool) (data []chutils.Row, valid []chutils.Valid, err error) {
+	if rdr.stream {
+		return rdr.gd.read(&rdr.currRow, nTarget, rdr.streamValue)
+	}
+
+	return rdr.gd.read(&rdr.currRow, nTarget, rdr.gd.rawValue)
+}
+
+// streamValue returns the value of datum at row without creating its *Raw
+func (rdr *GDataReader) streamValue(datum *GDatum, row int) (any, error) {
+	if datum.Raw != nil {
+		return datum.Raw.Data[row], nil
+	}
+
+	switch datum.FT.Role {
+	case FRCts:
+		x := datum.floatAt(row)
+		if datum.FT.Normalized {
+			x = x*datum.FT.FP.Scale + datum.FT.FP.Location
+		}
+
+		return x, nil
+	case FRCat, FROrdinal:
+		key, ok := rdr.levels[datum.FT.Name]
+		if !ok {
+			key, _ = datum.FT.FP.Lvl.Sort(false, true)
+			rdr.levels[datum.FT.Name] = key
+		}
+
+		return key[int(datum.Data.([]int32)[row])], nil
+	case FRDate:
+		return dayDate(datum.Data.([]int64)[row]), nil
+	}
+
+	return nil, Wrapper(ErrGData, fmt.Sprintf("(*GDataReader) Read: cannot read field %s", datum.FT.Name))
+}
+
+// CountLines returns the number of rows of the *GData
+func (rdr *GDataReader) CountLines() (numLines int, err error) {
+	return rdr.gd.CountLines()
+}
+
+// Reset moves the cursor to the first row
+func (rdr *GDataReader) Reset() error {
+	rdr.currRow = 0
+	return nil
+}
+
+// Seek moves the cursor to row lineNo
+func (rdr *GDataReader) Seek(lineNo int) error {
+	if lineNo >= rdr.gd.rows {
+		return chutils.Wrapper(chutils.ErrSeek, "seek past end of data")
+	}
+
+	if lineNo < 0 {
+		return chutils.Wrapper(chutils.ErrSeek, "seek past beginning of data")
+	}
+
+	rdr.currRow = lineNo
+
+	return nil
+}
+
+// Close moves the cursor to the first row.  The *GData is not changed.
+func (rdr *GDataReader) Close() error {
+	rdr.currRow = 0
+	return nil
+}
+
+// TableSpec returns the TableDef of the *GData
+func (rdr *GDataReader) TableSpec() *chutils.TableDef {
+	return rdr.gd.TableSpec()
+}
+
 func (gd *GData) CountLines() (numLines int, err error) {
 	return gd.rows, nil
 }
@@ -742,11 +1678,11 @@ func (gd *GData) TableSpec() *chutils.TableDef {
 		}
 
 		switch datum.FT.Role {
-		case FREmbed, FROneHot:
+		case FREmbed, FROneHot, FRSeq:
 			continue
 		case FRCts:
 			fd.ChSpec.Base, fd.ChSpec.Length = chutils.ChFloat, 64
-		case FRCat:
+		case FRCat, FROrdinal:
 			x := datum.FT.FP.Lvl.FindValue(0)
 			switch x.(type) {
 			case int32:
@@ -760,12 +1696,18 @@ func (gd *GData) TableSpec() *chutils.TableDef {
 			default:
 				return nil
 			}
+		case FRDate:
+			fd.ChSpec.Base = chutils.ChDate
 		}
 
 		fds[ind] = fd
 		ind++
 	}
 
+	if len(fds) == 0 {
+		return nil
+	}
+
 	key := fds[0].Name
 	td := chutils.NewTableDef(key, chutils.MergeTree, fds)
 
@@ -837,12 +1779,20 @@ func (gd *GData) Row(take int) (gdNew *GData, err error) {
 		switch datum.FT.Role {
 		case FRCat:
 			err = gdNew.AppendD(raw, datum.FT.Name, datum.FT.FP, datum.Raw != nil)
+		case FROrdinal:
+			err = gdNew.appendOrdinal(raw, datum.FT.Name, datum.FT.FP, datum.Raw != nil)
+		case FRDate:
+			err = gdNew.AppendDate(raw, datum.FT.Name, datum.Raw != nil)
 		case FRCts, FREither:
 			err = gdNew.AppendC(raw, datum.FT.Name, datum.FT.Normalized, datum.FT.FP, datum.Raw != nil)
 		case FROneHot, FREmbed:
 			err = gdNew.MakeOneHot(datum.FT.From, datum.FT.Name)
 		}
 
+		if err == nil {
+			err = gdNew.keepFloat32(datum.FT)
+		}
+
 		if err != nil {
 			return nil, err
 		}
@@ -863,6 +1813,11 @@ func (gd *GData) Subset(keepRows []int) (gdOut *GData, err error) {
 			data []any
 		)
 
+		// sequence fields are added after the others
+		if gd.data[ind].FT.Role == FRSeq {
+			continue
+		}
+
 		raw, e = gd.GetRaw(flds[ind])
 		if e != nil {
 			return nil, e
@@ -883,17 +1838,41 @@ func (gd *GData) Subset(keepRows []int) (gdOut *GData, err error) {
 		switch datum.FT.Role {
 		case FRCat:
 			e = gdOut.AppendD(rawNew, datum.FT.Name, datum.FT.FP, datum.Raw != nil)
+		case FROrdinal:
+			e = gdOut.appendOrdinal(rawNew, datum.FT.Name, datum.FT.FP, datum.Raw != nil)
+		case FRDate:
+			e = gdOut.AppendDate(rawNew, datum.FT.Name, datum.Raw != nil)
 		case FRCts, FREither:
 			e = gdOut.AppendC(rawNew, datum.FT.Name, datum.FT.Normalized, datum.FT.FP, datum.Raw != nil)
 		case FROneHot, FREmbed:
 			e = gdOut.MakeOneHot(datum.FT.From, datum.FT.Name)
 		}
 
+		// the raw values of unseen rows are their fill level, so the rows are carried over
+		if e == nil && datum.unseen != nil {
+			dOut := gdOut.Get(datum.FT.Name)
+			dOut.unseen = make([]bool, len(keepRows))
+
+			for indx, indKeep := range keepRows {
+				dOut.unseen[indx] = datum.unseen[indKeep]
+			}
+
+			dOut.Summary.Unseen = countTrue(dOut.unseen)
+		}
+
+		if e == nil {
+			e = gdOut.keepFloat32(datum.FT)
+		}
+
 		if e != nil {
 			return nil, e
 		}
 	}
 
+	if e := gdOut.appendSeq(gd, keepRows); e != nil {
+		return nil, e
+	}
+
 	return gdOut, nil
 }
 
@@ -971,6 +1950,228 @@ func (gd *GData) Where(field string, equalTo []any) (gdOut *GData, err error) {
 	return gd.Subset(rows)
 }
 
+// WhereDates returns the rows of gd for which the FRDate field is between from and to, inclusive.  The time of
+// day of from and to is ignored.
+func (gd *GData) WhereDates(field string, from, to time.Time) (gdOut *GData, err error) {
+	d := gd.Get(field)
+	if d == nil {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("(*GData) WhereDates: field %s not found", field))
+	}
+
+	if d.FT.Role != FRDate {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("(*GData) WhereDates: field %s is not FRDate", field))
+	}
+
+	lo, hi := dateDay(from), dateDay(to)
+
+	var rows []int
+
+	for row, day := range d.Data.([]int64) {
+		if day >= lo && day <= hi {
+			rows = append(rows, row)
+		}
+	}
+
+	if rows == nil {
+		return nil, fmt.Errorf("no matches in WhereDates")
+	}
+
+	return gd.Subset(rows)
+}
+
+// WhereExpr subsets gd to the rows where the expression expr evaluates to a positive value.
+func (gd *GData) WhereExpr(expr string) (gdOut *GData, err error) {
+	if gd.Rows() == 0 {
+		return nil, fmt.Errorf("WhereExpr: no data")
+	}
+
+	var sl Slicer
+	if sl, err = NewSlicerExpr(expr, NewVecData("where", gd)); err != nil {
+		return nil, err
+	}
+
+	var rows []int
+
+	for ind := 0; ind < gd.Rows(); ind++ {
+		if sl(ind) {
+			rows = append(rows, ind)
+		}
+	}
+
+	if rows == nil {
+		return nil, fmt.Errorf("no matches in WhereExpr")
+	}
+
+	return gd.Subset(rows)
+}
+
+// SampleN returns a simple random sample of n rows of gd using reservoir sampling. The rows are kept in their
+// original order. If n is at least the number of rows, all rows are returned. The sample is reproducible for a given seed.
+func (gd *GData) SampleN(n int, seed int64) (*GData, error) {
+	if n <= 0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("SampleN: sample size must be positive, got %d", n))
+	}
+
+	rows := reservoir(utilities.MinInt(n, gd.Rows()), gd.Rows(), nil, newRand(seed))
+	sort.Ints(rows)
+
+	return gd.Subset(rows)
+}
+
+// SampleFrac returns a simple random sample of the fraction f of the rows of gd. See SampleN.
+func (gd *GData) SampleFrac(f float64, seed int64) (*GData, error) {
+	if f <= 0.0 || f > 1.0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("SampleFrac: fraction must be in (0,1], got %v", f))
+	}
+
+	n := int(math.Round(f * float64(gd.Rows())))
+	if n == 0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("SampleFrac: fraction %v of %d rows is no rows", f, gd.Rows()))
+	}
+
+	return gd.SampleN(n, seed)
+}
+
+// StratifiedSample returns a random sample of perLevel rows for each level of field. Levels with fewer than perLevel
+// rows are included in full. The rows are kept in their original order. The sample is reproducible for a given seed.
+func (gd *GData) StratifiedSample(field string, perLevel int, seed int64) (*GData, error) {
+	if perLevel <= 0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("StratifiedSample: sample size must be positive, got %d", perLevel))
+	}
+
+	raw, e := gd.GetRaw(field)
+	if e != nil {
+		return nil, Wrapper(e, "StratifiedSample")
+	}
+
+	// rows of gd for each level
+	levelRows := make(map[any][]int)
+	for row, val := range raw.Data {
+		levelRows[val] = append(levelRows[val], row)
+	}
+
+	var rows []int
+
+	rnd := newRand(seed)
+	for _, lvl := range Unique(raw.Data) {
+		rows = append(rows, reservoir(utilities.MinInt(perLevel, len(levelRows[lvl])), len(levelRows[lvl]), levelRows[lvl], rnd)...)
+	}
+
+	sort.Ints(rows)
+
+	return gd.Subset(rows)
+}
+
+// reservoir selects k of n items using reservoir sampling. The items are from if it is not nil and 0,..,n-1 otherwise.
+// If rnd is nil, the package source is used.
+func reservoir(k, n int, from []int, rnd *rand.Rand) []int {
+	if rnd == nil {
+		rnd = rng
+	}
+
+	item := func(ind int) int {
+		if from == nil {
+			return ind
+		}
+
+		return from[ind]
+	}
+
+	sample := make([]int, k)
+	for ind := 0; ind < k; ind++ {
+		sample[ind] = item(ind)
+	}
+
+	for ind := k; ind < n; ind++ {
+		if j := rnd.Intn(ind + 1); j < k {
+			sample[j] = item(ind)
+		}
+	}
+
+	return sample
+}
+
+// rowKeys returns a key for each row based on the values of onFields. If onFields is nil, all fields are used.
+func (gd *GData) rowKeys(onFields []string) ([]string, error) {
+	if onFields == nil {
+		onFields = gd.FieldList()
+	}
+
+	keys := make([]string, gd.Rows())
+
+	for _, fld := range onFields {
+		d := gd.Get(fld)
+		if d == nil {
+			return nil, fmt.Errorf("field %s not found", fld)
+		}
+
+		// one-hot/embedded/sequence fields are built from other fields
+		if d.FT.Role == FROneHot || d.FT.Role == FREmbed || d.FT.Role == FRSeq {
+			continue
+		}
+
+		raw, e := gd.GetRaw(fld)
+		if e != nil {
+			return nil, e
+		}
+
+		for row := 0; row < gd.Rows(); row++ {
+			keys[row] = fmt.Sprintf("%s%T:%v\x00", keys[row], raw.Data[row], raw.Data[row])
+		}
+	}
+
+	return keys, nil
+}
+
+// Distinct returns a new GData with duplicate rows removed. Rows are duplicates if they have the same values of
+// the fields onFields (all fields, if onFields is nil). keep is "first" or "last" and specifies which of the
+// duplicate rows, in the current order of the data, is kept.
+func (gd *GData) Distinct(onFields []string, keep string) (gdOut *GData, err error) {
+	if keep != "first" && keep != "last" {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("Distinct: keep must be first or last, got %s", keep))
+	}
+
+	var keys []string
+	if keys, err = gd.rowKeys(onFields); err != nil {
+		return nil, Wrapper(err, "Distinct")
+	}
+
+	// position holds the row to keep for each key
+	position := make(map[string]int)
+	for row, key := range keys {
+		if _, ok := position[key]; ok && keep == "first" {
+			continue
+		}
+
+		position[key] = row
+	}
+
+	rows := make([]int, 0, len(position))
+	for row, key := range keys {
+		if position[key] == row {
+			rows = append(rows, row)
+		}
+	}
+
+	return gd.Subset(rows)
+}
+
+// DuplicateCount returns the number of rows that duplicate an earlier row. Rows are duplicates if they have the
+// same values of the fields onFields (all fields, if onFields is nil).
+func (gd *GData) DuplicateCount(onFields []string) (int, error) {
+	keys, err := gd.rowKeys(onFields)
+	if err != nil {
+		return 0, Wrapper(err, "DuplicateCount")
+	}
+
+	uniq := make(map[string]bool)
+	for _, key := range keys {
+		uniq[key] = true
+	}
+
+	return len(keys) - len(uniq), nil
+}
+
 // AppendRowsRaw simply appends rows, in place, to the existing GData.  Only the *Raw data is updated.
 // The .Data field is set to nil.
 func (gd *GData) AppendRowsRaw(gdApp *GData) error {
@@ -996,10 +2197,45 @@ func (gd *GData) AppendRowsRaw(gdApp *GData) error {
 	return nil
 }
 
+// AppendOpts sets an option of AppendRows
+type AppendOpts func(ao *appendOpts)
+
+// appendOpts holds the options of AppendRows
+type appendOpts struct {
+	grow   bool      // add values of FRCat fields not in Levels as new levels
+	report NewLevels // levels added, by field
+}
+
+// NewLevels is the levels added to each field by AppendRows.  See WithGrowLevels.
+type NewLevels map[string][]any
+
+// WithGrowLevels makes AppendRows add values of FRCat fields that are not in the existing Levels as new levels,
+// rather than failing when there's no Default.  The existing levels keep their codes and the new ones follow, in
+// the order they occur, so one-hot fields widen with the new levels in the last columns.  If report is not nil, the
+// levels added to each field are recorded in it.
+func WithGrowLevels(report NewLevels) AppendOpts {
+	return func(ao *appendOpts) {
+		ao.grow = true
+		ao.report = report
+	}
+}
+
 // AppendRows appends rows to the existing GData and then re-initializes each GDatum, using the fTypes, if provided.
-func (gd *GData) AppendRows(gdApp *GData, fTypes FTypes) (gdOut *GData, err error) {
+// With WithGrowLevels, the Levels of FRCat fields are extended by the new values.  FRSeq fields are stacked as they
+// are, after the other fields (see MakeSequence): the histories of the rows of gdApp are those of gdApp.
+func (gd *GData) AppendRows(gdApp *GData, fTypes FTypes, opts ...AppendOpts) (gdOut *GData, err error) {
+	ao := &appendOpts{}
+	for _, opt := range opts {
+		opt(ao)
+	}
+
 	gdOut = NewGData()
 	for ind, fld := range gd.FieldList() {
+		// sequence fields are added after the others
+		if gd.data[ind].FT.Role == FRSeq {
+			continue
+		}
+
 		rawApp, e := gdApp.GetRaw(fld)
 		if e != nil {
 			return nil, e
@@ -1032,21 +2268,71 @@ func (gd *GData) AppendRows(gdApp *GData, fTypes FTypes) (gdOut *GData, err erro
 
 		switch ft.Role {
 		case FRCat:
+			if ao.grow && ft.FP != nil && ft.FP.Lvl != nil {
+				var added []any
+				if fp, added = growLevels(ft.FP, rawApp); len(added) > 0 && ao.report != nil {
+					ao.report[ft.Name] = added
+				}
+			}
+
 			e = gdOut.AppendD(rawNew, ft.Name, fp, hasRaw)
+		case FROrdinal:
+			// the order of the levels isn't re-derived from the data
+			if fp == nil {
+				fp = ft.FP
+			}
+
+			e = gdOut.appendOrdinal(rawNew, ft.Name, fp, hasRaw)
+		case FRDate:
+			e = gdOut.AppendDate(rawNew, ft.Name, hasRaw)
 		case FRCts, FREither:
 			e = gdOut.AppendC(rawNew, ft.Name, ft.Normalized, fp, hasRaw)
 		case FROneHot, FREmbed:
 			e = gdOut.MakeOneHot(ft.From, ft.Name)
 		}
 
+		if e == nil {
+			e = gdOut.keepFloat32(ft)
+		}
+
 		if e != nil {
 			return nil, e
 		}
 	}
 
+	if e := gdOut.stackSeq(gd, gdApp); e != nil {
+		return nil, e
+	}
+
 	return gdOut, nil
 }
 
+// growLevels returns a copy of fp whose Levels include the values of raw.  Values not in fp.Lvl are returned.
+func growLevels(fp *FParam, raw *Raw) (fpOut *FParam, added []any) {
+	fpNew := *fp
+	fpNew.Lvl = make(Levels)
+
+	next := int32(0)
+	for k, v := range fp.Lvl {
+		fpNew.Lvl[k] = v
+		if v >= next {
+			next = v + 1
+		}
+	}
+
+	for _, val := range raw.Data {
+		if _, ok := fpNew.Lvl[val]; ok {
+			continue
+		}
+
+		fpNew.Lvl[val] = next
+		next++
+		added = append(added, val)
+	}
+
+	return &fpNew, added
+}
+
 // Copy makes an independent copy of gd
 func (gd *GData) Copy() (gdOut *GData, err error) {
 	gdOut = NewGData()
@@ -1065,14 +2351,25 @@ func (gd *GData) Copy() (gdOut *GData, err error) {
 			err = gdOut.AppendC(raw, fTypes[ind].Name, fTypes[ind].Normalized, fTypes[ind].FP, keepRaw)
 		case FRCat:
 			err = gdOut.AppendD(raw, fTypes[ind].Name, fTypes[ind].FP, keepRaw)
+		case FROrdinal:
+			err = gdOut.appendOrdinal(raw, fTypes[ind].Name, fTypes[ind].FP, keepRaw)
+		case FRDate:
+			err = gdOut.AppendDate(raw, fTypes[ind].Name, keepRaw)
 		case FROneHot, FREmbed:
 			err = gdOut.MakeOneHot(fTypes[ind].From, fTypes[ind].Name)
 		}
+		if err == nil {
+			err = gdOut.keepFloat32(fTypes[ind])
+		}
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if err = gdOut.appendSeq(gd, nil); err != nil {
+		return nil, err
+	}
+
 	return gdOut, nil
 }
 
@@ -1108,12 +2405,20 @@ func (gd *GData) ReInit(fTypes *FTypes) (gdOut *GData, err error) {
 		switch ft.Role {
 		case FRCat:
 			err = gdOut.AppendD(rawData, ft.Name, fp, true)
+		case FROrdinal:
+			err = gdOut.appendOrdinal(rawData, ft.Name, fp, true)
+		case FRDate:
+			err = gdOut.AppendDate(rawData, ft.Name, true)
 		case FRCts, FREither:
 			err = gdOut.AppendC(rawData, ft.Name, ft.Normalized, fp, true)
 		case FROneHot, FREmbed:
 			err = gdOut.MakeOneHot(ft.From, ft.Name)
 		}
 
+		if err == nil {
+			err = gdOut.keepFloat32(ft)
+		}
+
 		if err != nil {
 			return nil, err
 		}
@@ -1155,8 +2460,27 @@ const (
 	Outer
 )
 
+// JoinOpts sets an option of Join
+type JoinOpts func(jo *joinOpts)
+
+// joinOpts holds the options of Join
+type joinOpts struct {
+	hash bool // match rows with a hash table rather than sort-merge
+}
+
+// WithHashJoin makes Join match rows using a hash table of the right *GData rather than by sorting both *GData.
+// Neither *GData is sorted. The result has the rows of the left *GData in order, each followed by its matches in
+// the order of right, then (for Right and Outer joins) the rows of right that have no match.  This is faster for
+// large *GData that are not already sorted on onField.
+func WithHashJoin() JoinOpts {
+	return func(jo *joinOpts) {
+		jo.hash = true
+	}
+}
+
 // Join joins two *GData on onField.
 // Both *GData are sorted by onField, though the result may not be in sort order for Outer and Right joins.
+// A *GData that is already in ascending order of onField is not re-sorted.  With WithHashJoin, neither is sorted.
 // If a field value is missing, the FType.FParam.Default value is filled in. If that value is nil, the following
 // are used:
 //   - int,float : 0
@@ -1168,13 +2492,13 @@ const (
 //
 // The resulting *GData has only *Raw fields populated. To populate the .data fields, use ReInit.
 // FROneHot and FREmbed fields are left behind -- they'll need to be recreated after the join.
-func (gd *GData) Join(right *GData, onField string, joinType JoinType) (result *GData, err error) {
-	var (
-		ulRaw, urRaw, lRaw, rRaw             []*Raw
-		lJoin, rJoin                         *Raw
-		lFts, rFts                           FTypes
-		ulFields, urFields, lFields, rFields []string
-	)
+func (gd *GData) Join(right *GData, onField string, joinType JoinType, opts ...JoinOpts) (result *GData, err error) {
+	var lJoin, rJoin *Raw
+
+	jo := &joinOpts{}
+	for _, opt := range opts {
+		opt(jo)
+	}
 
 	if right == nil {
 		return nil, fmt.Errorf("right *GDatais nil")
@@ -1188,47 +2512,44 @@ func (gd *GData) Join(right *GData, onField string, joinType JoinType) (result *
 		return nil, err
 	}
 
-	if gd.sortField != onField || !gd.sortAscending {
+	if e := joinCheck(lJoin, rJoin); e != nil {
+		return nil, e
+	}
+
+	if jo.hash {
+		return gd.hashJoin(right, onField, joinType, lJoin, rJoin)
+	}
+
+	if !gd.sortedOn(onField, lJoin) {
 		if e := gd.Sort(onField, true); e != nil {
 			return nil, e
 		}
 	}
 
-	if right.sortField != onField || !right.sortAscending {
+	if !right.sortedOn(onField, rJoin) {
 		if e := right.Sort(onField, true); e != nil {
 			return nil, e
 		}
 	}
 
-	if e := joinCheck(lJoin, rJoin); e != nil {
-		return nil, e
-	}
-
-	if ulRaw, _, ulFields, err = gd.Back2Raw(); err != nil {
-		return nil, err
-	}
-	if urRaw, _, urFields, err = right.Back2Raw(); err != nil {
+	lFields, rFields, lRaw, rRaw, lFts, rFts, err := joinFields(gd, right, onField)
+	if err != nil {
 		return nil, err
 	}
 
-	ulFts, urFts := gd.GetFTypes(), right.GetFTypes()
-
-	// subset to fields to keep
-	lFields, lRaw, lFts = subsetFields(ulFields, ulRaw, ulFts, []string{onField})
-
-	omit := []string{onField}
+	lInd, rInd := 0, 0
+	lResult, rResult := make([][]any, len(lFields)), make([][]any, len(rFields))
+	joinResult := make([]any, 0, gd.Rows())
 
-	for _, fld := range urFields {
-		if utilities.Position(fld, "", lFields...) >= 0 {
-			omit = append(omit, fld)
-		}
+	// most joins have about as many rows as the larger side
+	size := utilities.MaxInt(gd.Rows(), right.Rows())
+	for col := range lResult {
+		lResult[col] = make([]any, 0, size)
 	}
 
-	rFields, rRaw, rFts = subsetFields(urFields, urRaw, urFts, omit)
-
-	lInd, rInd := 0, 0
-	lResult, rResult := make([][]any, len(lFields)), make([][]any, len(rFields))
-	joinResult := make([]any, 0)
+	for col := range rResult {
+		rResult[col] = make([]any, 0, size)
+	}
 
 	for {
 		// list of all indices that equal jl.Data[lInd]
@@ -1278,11 +2599,64 @@ func (gd *GData) Join(right *GData, onField string, joinType JoinType) (result *
 		rResult, lResult, joinResult = collectResults(rRaw, lRaw, lFts, rTake, nil, rResult, lResult, rJoin, joinResult)
 	}
 
-	if joinType == Inner && lResult[0] == nil {
+	if joinType == Inner && len(joinResult) == 0 {
 		return nil, fmt.Errorf("join has no elements")
 	}
 
-	result = NewGData()
+	return joinOutput(lResult, rResult, joinResult, lFields, rFields, lFts, rFts, onField)
+}
+
+// sortedOn returns true if gd has been sorted ascending on field or the values of field, raw, are in ascending order.
+func (gd *GData) sortedOn(field string, raw *Raw) bool {
+	if gd.sortField == field && gd.sortAscending {
+		return true
+	}
+
+	for row := 1; row < raw.Len(); row++ {
+		if less, e := utilities.Comparer(raw.Data[row], raw.Data[row-1], "<"); e != nil || less {
+			return false
+		}
+	}
+
+	return true
+}
+
+// joinFields returns the fields of gd and right that are in the output of a join on onField.
+func joinFields(gd, right *GData, onField string) (lFields, rFields []string, lRaw, rRaw []*Raw, lFts, rFts FTypes, err error) {
+	var (
+		ulRaw, urRaw       []*Raw
+		ulFields, urFields []string
+	)
+
+	if ulRaw, _, ulFields, err = gd.Back2Raw(); err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	if urRaw, _, urFields, err = right.Back2Raw(); err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+
+	ulFts, urFts := gd.GetFTypes(), right.GetFTypes()
+
+	// subset to fields to keep
+	lFields, lRaw, lFts = subsetFields(ulFields, ulRaw, ulFts, []string{onField})
+
+	omit := []string{onField}
+
+	for _, fld := range urFields {
+		if utilities.Position(fld, "", lFields...) >= 0 {
+			omit = append(omit, fld)
+		}
+	}
+
+	rFields, rRaw, rFts = subsetFields(urFields, urRaw, urFts, omit)
+
+	return lFields, rFields, lRaw, rRaw, lFts, rFts, nil
+}
+
+// joinOutput builds the *GData that is the result of a join
+func joinOutput(lResult, rResult [][]any, joinResult []any, lFields, rFields []string, lFts, rFts FTypes,
+	onField string) (*GData, error) {
+	result := NewGData()
 	if e := result.AddRaw(lResult, lFields, lFts, true); e != nil {
 		return nil, e
 	}
@@ -1298,6 +2672,126 @@ func (gd *GData) Join(right *GData, onField string, joinType JoinType) (result *
 	return result, nil
 }
 
+// hashJoin joins gd and right on onField using a hash table of the rows of right.  lJoin and rJoin are the values
+// of onField. The output is allocated once, after counting its rows.
+func (gd *GData) hashJoin(right *GData, onField string, joinType JoinType, lJoin, rJoin *Raw) (*GData, error) {
+	lFields, rFields, lRaw, rRaw, lFts, rFts, err := joinFields(gd, right, onField)
+	if err != nil {
+		return nil, err
+	}
+
+	rRows := make(map[any][]int, rJoin.Len())
+	for row, key := range rJoin.Data {
+		k := hashKey(key)
+		rRows[k] = append(rRows[k], row)
+	}
+
+	keepLeft := joinType == Left || joinType == Outer
+	keepRight := joinType == Right || joinType == Outer
+
+	// count the output rows
+	n := 0
+	matched := make([]bool, rJoin.Len())
+
+	for _, key := range lJoin.Data {
+		rows := rRows[hashKey(key)]
+		n += len(rows)
+
+		if len(rows) == 0 && keepLeft {
+			n++
+		}
+
+		for _, row := range rows {
+			matched[row] = true
+		}
+	}
+
+	if keepRight {
+		for _, m := range matched {
+			if !m {
+				n++
+			}
+		}
+	}
+
+	if n == 0 && joinType == Inner {
+		return nil, fmt.Errorf("join has no elements")
+	}
+
+	lResult, rResult := make([][]any, len(lFields)), make([][]any, len(rFields))
+	for col := range lResult {
+		lResult[col] = make([]any, n)
+	}
+
+	for col := range rResult {
+		rResult[col] = make([]any, n)
+	}
+
+	joinResult := make([]any, n)
+
+	// fill sets output row out from row lRow of gd and rRow of right.  A row of -1 is missing.
+	fill := func(out, lRow, rRow int) {
+		for col := range lResult {
+			if lRow < 0 {
+				lResult[col][out] = getMiss(lFts[col], lRaw[col].Kind)
+				continue
+			}
+
+			lResult[col][out] = lRaw[col].Data[lRow]
+		}
+
+		for col := range rResult {
+			if rRow < 0 {
+				rResult[col][out] = getMiss(rFts[col], rRaw[col].Kind)
+				continue
+			}
+
+			rResult[col][out] = rRaw[col].Data[rRow]
+		}
+
+		if lRow >= 0 {
+			joinResult[out] = lJoin.Data[lRow]
+			return
+		}
+
+		joinResult[out] = rJoin.Data[rRow]
+	}
+
+	out := 0
+	for lRow, key := range lJoin.Data {
+		rows := rRows[hashKey(key)]
+		for _, rRow := range rows {
+			fill(out, lRow, rRow)
+			out++
+		}
+
+		if len(rows) == 0 && keepLeft {
+			fill(out, lRow, -1)
+			out++
+		}
+	}
+
+	if keepRight {
+		for rRow, m := range matched {
+			if !m {
+				fill(out, -1, rRow)
+				out++
+			}
+		}
+	}
+
+	return joinOutput(lResult, rResult, joinResult, lFields, rFields, lFts, rFts, onField)
+}
+
+// hashKey returns the map key for the join value x.  Dates are keyed by their instant.
+func hashKey(x any) any {
+	if dt, ok := x.(time.Time); ok {
+		return dt.UnixNano()
+	}
+
+	return x
+}
+
 // AddRaw adds a number of fields in []any format to *GData. The fts are only used to determine the Role.
 func (gd *GData) AddRaw(data [][]any, fields []string, fts FTypes, keepRaw bool) error {
 	for ind := 0; ind < len(fields); ind++ {
@@ -1307,10 +2801,24 @@ func (gd *GData) AddRaw(data [][]any, fields []string, fts FTypes, keepRaw bool)
 			if e := gd.AppendD(raw, fields[ind], nil, keepRaw); e != nil {
 				return e
 			}
+		case FROrdinal:
+			if e := gd.appendOrdinal(raw, fields[ind], fts[ind].FP, keepRaw); e != nil {
+				return e
+			}
+		case FRDate:
+			if e := gd.AppendDate(raw, fields[ind], keepRaw); e != nil {
+				return e
+			}
 		case FRCts, FREither:
 			if e := gd.AppendC(raw, fields[ind], false, nil, keepRaw); e != nil {
 				return e
 			}
+
+			if fts[ind].Float32 {
+				if e := gd.ToFloat32(fields[ind]); e != nil {
+					return e
+				}
+			}
 		case FROneHot, FREmbed:
 			if e := gd.MakeOneHot(fts[ind].From, fts[ind].Name); e != nil {
 				return e
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/invertedv/chutils"
 
@@ -279,6 +283,78 @@ func TestGData_Sort(t *testing.T) {
 	assert.ElementsMatch(t, x0, gd.Get("Field0").Data.([]float64))
 }
 
+func TestGData_SortRows(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRaw([]any{3.0, 1.0, 2.0, 1.0}, nil), "x", false, nil, true))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"c", "a", "b", "d"}, nil), "s", nil, true))
+	assert.Nil(t, gd.MakeOneHot("s", "sOh"))
+
+	assert.Nil(t, gd.Sort("x", true))
+	assert.Equal(t, "x", gd.SortField())
+
+	// rows stay together and ties keep their order
+	x, e := gd.GetRaw("x")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{1.0, 1.0, 2.0, 3.0}, x.Data)
+	assert.Equal(t, []float64{1, 1, 2, 3}, gd.Get("x").Data.([]float64))
+
+	s, e := gd.GetRaw("s")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"a", "d", "b", "c"}, s.Data)
+
+	oh := gd.Get("sOh").Data.([]float64)
+	cats := gd.Get("sOh").FT.Cats
+	lvls := gd.Get("s").Data.([]int32)
+
+	for row := 0; row < gd.Rows(); row++ {
+		assert.Equal(t, 1.0, oh[row*cats+int(lvls[row])])
+	}
+
+	assert.Nil(t, gd.Sort("s", false))
+	s, e = gd.GetRaw("s")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"d", "c", "b", "a"}, s.Data)
+}
+
+// benchSort builds a GData with n rows, a key in random order and fields other fields
+func benchSort(b *testing.B, n, fields int) *GData {
+	gd := NewGData()
+
+	for fld := 0; fld < fields; fld++ {
+		x := make([]any, n)
+		for ind := range x {
+			x[ind] = rng.Float64()
+		}
+
+		assert.Nil(b, gd.AppendC(NewRaw(x, nil), fmt.Sprintf("x%d", fld), false, nil, false))
+	}
+
+	return gd
+}
+
+func BenchmarkGData_Sort(b *testing.B) {
+	gd := benchSort(b, 100000, 50)
+	b.ResetTimer()
+
+	for ind := 0; ind < b.N; ind++ {
+		// alternate keys so the data aren't already in order
+		if e := gd.Sort(fmt.Sprintf("x%d", ind%2), true); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+// BenchmarkGData_SortSwap sorts by swapping the rows of every field, as sort.Sort does, for comparison
+func BenchmarkGData_SortSwap(b *testing.B) {
+	gd := benchSort(b, 100000, 50)
+	b.ResetTimer()
+
+	for ind := 0; ind < b.N; ind++ {
+		gd.sortData, gd.sortAscending = gd.Get(fmt.Sprintf("x%d", ind%2)), true
+		sort.Sort(gd)
+	}
+}
+
 func TestGData_GetRaw(t *testing.T) {
 	gd := NewGData()
 	x0 := make([]any, 0)
@@ -325,6 +401,107 @@ func TestGData_GetRaw(t *testing.T) {
 	assert.ElementsMatch(t, x1, x1Test.Data)
 }
 
+func TestGData_Rename(t *testing.T) {
+	gd := NewGData()
+	x0 := []any{1.0, 2.0, 3.0, 4.0}
+	e := gd.AppendC(NewRaw(x0, nil), "Field0", true, nil, false)
+	assert.Nil(t, e)
+
+	x1 := []any{"a", "b", "c", "a"}
+	e = gd.AppendD(NewRaw(x1, nil), "Field1", nil, false)
+	assert.Nil(t, e)
+
+	e = gd.MakeOneHot("Field1", "Field2")
+	assert.Nil(t, e)
+
+	ft0 := gd.GetFType("Field0")
+
+	e = gd.Rename("Field0", "New0")
+	assert.Nil(t, e)
+	assert.Nil(t, gd.Get("Field0"))
+	assert.True(t, gd.GetFType("New0").Normalized)
+	assert.Equal(t, "Field0", ft0.Name)
+
+	e = gd.Rename("Field1", "New1")
+	assert.Nil(t, e)
+	assert.Equal(t, "New1", gd.GetFType("Field2").From)
+
+	x1Test, e := gd.GetRaw("Field2")
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, x1, x1Test.Data)
+
+	assert.NotNil(t, gd.Rename("New0", "New1"))
+	assert.NotNil(t, gd.Rename("xyz", "abc"))
+}
+
+func TestGData_CopyField(t *testing.T) {
+	gd := NewGData()
+	x0 := []any{1.0, 2.0, 3.0, 4.0}
+	e := gd.AppendC(NewRaw(x0, nil), "Field0", true, nil, true)
+	assert.Nil(t, e)
+
+	e = gd.CopyField("Field0", "Copy0")
+	assert.Nil(t, e)
+
+	ft, ftCopy := gd.GetFType("Field0"), gd.GetFType("Copy0")
+	assert.Equal(t, ft.FP.Location, ftCopy.FP.Location)
+	assert.Equal(t, ft.Normalized, ftCopy.Normalized)
+	assert.Equal(t, gd.Get("Field0").Data, gd.Get("Copy0").Data)
+
+	gd.Get("Copy0").Data.([]float64)[0] = 100.0
+	assert.NotEqual(t, gd.Get("Field0").Data, gd.Get("Copy0").Data)
+
+	assert.NotNil(t, gd.CopyField("Field0", "Copy0"))
+}
+
+func TestGData_Compact(t *testing.T) {
+	gd := getData(t)
+
+	x2, e := gd.GetRaw("x2")
+	assert.Nil(t, e)
+
+	// after compacting, there's nothing left to reclaim
+	gd.Compact(true)
+	assert.Equal(t, 0, gd.Compact(true))
+	assert.NotNil(t, gd.Get("x1").Raw)
+
+	assert.Greater(t, gd.Compact(false), 0)
+	assert.Nil(t, gd.Get("x1").Raw)
+	assert.Nil(t, gd.Get("x2").Raw)
+
+	x2Compact, e := gd.GetRaw("x2")
+	assert.Nil(t, e)
+	assert.Equal(t, x2.Data, x2Compact.Data)
+}
+
+func TestGData_Distinct(t *testing.T) {
+	// x2: "a", "b", "c", "a", "a", "a", "a"
+	// x3: 4, 5, 6, 1, 2, 2, 2
+	gd := getData(t)
+
+	n, e := gd.DuplicateCount([]string{"x2"})
+	assert.Nil(t, e)
+	assert.Equal(t, 4, n)
+
+	n, e = gd.DuplicateCount(nil)
+	assert.Nil(t, e)
+	assert.Equal(t, 0, n)
+
+	gdFirst, e := gd.Distinct([]string{"x2", "x3"}, "first")
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{1, 2, 3, 4, 8}, gdFirst.Get("x1").Data)
+
+	gdLast, e := gd.Distinct([]string{"x2", "x3"}, "last")
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{1, 2, 3, 4, 10}, gdLast.Get("x1").Data)
+
+	_, e = gd.Distinct(nil, "middle")
+	assert.NotNil(t, e)
+
+	_, e = gd.Distinct([]string{"xyz"}, "first")
+	assert.NotNil(t, e)
+}
+
 func TestGData_Read(t *testing.T) {
 	var e error
 
@@ -582,3 +759,491 @@ func ExampleGData_Join() {
 	// field1
 	// [a a b c l r s s k]
 }
+
+// joinTestGData builds a GData with the categorical field keyField and the continuous field valField
+func joinTestGData(t testing.TB, keys []any, keyField, valField string, vals []any) *GData {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw(keys, nil), keyField, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(vals, nil), valField, false, nil, false))
+
+	return gd
+}
+
+func TestGData_HashJoin(t *testing.T) {
+	left := joinTestGData(t, []any{"c", "a", "b", "e", "a"}, "key", "l", []any{0.0, 1.0, 2.0, 3.0, 4.0})
+	right := joinTestGData(t, []any{"a", "k", "c", "a"}, "key", "r", []any{10.0, 11.0, 12.0, 13.0})
+
+	exp := map[JoinType][]string{
+		Inner: {"l", "0 1 1 4 4", "r", "12 10 13 10 13", "key", "c a a a a"},
+		Left:  {"l", "0 1 1 2 3 4 4", "r", "12 10 13 0 0 10 13", "key", "c a a b e a a"},
+		Right: {"l", "0 1 1 4 4 0", "r", "12 10 13 10 13 11", "key", "c a a a a k"},
+		Outer: {"l", "0 1 1 2 3 4 4 0", "r", "12 10 13 0 0 10 13 11", "key", "c a a b e a a k"},
+	}
+
+	for _, jt := range []JoinType{Inner, Left, Right, Outer} {
+		hj, e := left.Join(right, "key", jt, WithHashJoin())
+		assert.Nil(t, e)
+
+		// the hash join does not sort
+		assert.False(t, left.IsSorted())
+
+		for ind := 0; ind < len(exp[jt]); ind += 2 {
+			raw, e := hj.GetRaw(exp[jt][ind])
+			assert.Nil(t, e)
+
+			act := strings.Trim(fmt.Sprint(raw.Data), "[]")
+			assert.Equal(t, exp[jt][ind+1], act, jt.String())
+		}
+
+		// same rows as sort-merge
+		lCopy, e := left.Copy()
+		assert.Nil(t, e)
+		rCopy, e := right.Copy()
+		assert.Nil(t, e)
+
+		sj, e := lCopy.Join(rCopy, "key", jt)
+		assert.Nil(t, e)
+
+		for _, fld := range []string{"l", "r", "key"} {
+			hRaw, _ := hj.GetRaw(fld)
+			sRaw, _ := sj.GetRaw(fld)
+			assert.ElementsMatch(t, sRaw.Data, hRaw.Data)
+		}
+	}
+
+	none := joinTestGData(t, []any{"x"}, "key", "r", []any{1.0})
+	_, e := left.Join(none, "key", Inner, WithHashJoin())
+	assert.NotNil(t, e)
+}
+
+func TestGData_JoinSorted(t *testing.T) {
+	left := joinTestGData(t, []any{"a", "b", "c"}, "key", "l", []any{0.0, 1.0, 2.0})
+	right := joinTestGData(t, []any{"b", "c", "d"}, "key", "r", []any{3.0, 4.0, 5.0})
+
+	jn, e := left.Join(right, "key", Inner)
+	assert.Nil(t, e)
+
+	// already in order, so not re-sorted
+	assert.False(t, left.IsSorted())
+	assert.False(t, right.IsSorted())
+
+	raw, e := jn.GetRaw("r")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{3.0, 4.0}, raw.Data)
+}
+
+// benchJoin builds left and right GData with n rows each with keys in random order
+func benchJoin(b *testing.B, n int) (left, right *GData) {
+	lKeys, rKeys, vals := make([]any, n), make([]any, n), make([]any, n)
+	for ind := 0; ind < n; ind++ {
+		lKeys[ind] = int32(rng.Intn(n))
+		rKeys[ind] = int32(ind)
+		vals[ind] = float64(ind)
+	}
+
+	rng.Shuffle(n, func(i, j int) { rKeys[i], rKeys[j] = rKeys[j], rKeys[i] })
+
+	left, right = NewGData(), NewGData()
+	assert.Nil(b, left.AppendD(NewRaw(lKeys, nil), "key", nil, false))
+	assert.Nil(b, left.AppendC(NewRaw(vals, nil), "l", false, nil, false))
+	assert.Nil(b, right.AppendD(NewRaw(rKeys, nil), "key", nil, false))
+	assert.Nil(b, right.AppendC(NewRaw(vals, nil), "r", false, nil, false))
+
+	return left, right
+}
+
+func BenchmarkGData_Join(b *testing.B) {
+	left, right := benchJoin(b, 100000)
+	b.ResetTimer()
+
+	for ind := 0; ind < b.N; ind++ {
+		b.StopTimer()
+		l, _ := left.Copy()
+		r, _ := right.Copy()
+		b.StartTimer()
+
+		if _, e := l.Join(r, "key", Inner); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkGData_HashJoin(b *testing.B) {
+	left, right := benchJoin(b, 100000)
+	b.ResetTimer()
+
+	for ind := 0; ind < b.N; ind++ {
+		if _, e := left.Join(right, "key", Inner, WithHashJoin()); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func TestGData_Summary(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, math.NaN(), 4}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4, 5}, nil), "z", true, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "", "a"}, nil), "s", nil, false))
+	assert.Nil(t, gd.MakeOneHot("s", "sOh"))
+
+	summ, e := gd.Summary(2)
+	assert.Nil(t, e)
+	assert.Equal(t, 4, summ.Rows())
+	assert.Equal(t, []string{"field", "role", "n", "missing", "mean", "std", "q0", "q10", "q25", "q50", "q75",
+		"q90", "q100", "levels", "top1", "top1N", "top2", "top2N"}, summ.FieldList())
+
+	get := func(field string) []any {
+		raw, e := summ.GetRaw(field)
+		assert.Nil(t, e)
+
+		return raw.Data
+	}
+
+	assert.Equal(t, []any{"x", "z", "s", "sOh"}, get("field"))
+	assert.Equal(t, []any{"FRCts", "FRCts", "FRCat", "FROneHot"}, get("role"))
+	assert.Equal(t, []any{5.0, 5.0, 5.0, 5.0}, get("n"))
+	assert.Equal(t, []any{1.0, 0.0, 1.0, 0.0}, get("missing"))
+
+	// normalized fields are on the original scale
+	assert.InDeltaSlice(t, []float64{2.5, 3.0}, get("mean")[:2], 1e-10)
+	assert.Equal(t, []any{1.0, 1.0}, get("q0")[:2])
+	assert.Equal(t, []any{4.0, 5.0}, get("q100")[:2])
+	assert.True(t, math.IsNaN(get("mean")[2].(float64)))
+
+	assert.Equal(t, 3.0, get("levels")[2])
+	assert.Equal(t, 3.0, get("levels")[3])
+	assert.True(t, math.IsNaN(get("levels")[0].(float64)))
+
+	assert.Equal(t, []any{"", "", "a", ""}, get("top1"))
+	assert.Equal(t, []any{0.0, 0.0, 3.0, 0.0}, get("top1N"))
+
+	_, e = NewGData().Summary(2)
+	assert.NotNil(t, e)
+
+	_, e = gd.Summary(-1)
+	assert.NotNil(t, e)
+
+	summ0, e := gd.Summary(0)
+	assert.Nil(t, e)
+	assert.Nil(t, summ0.Get("top1"))
+
+	// the summary can be exported
+	assert.Nil(t, PipeToCSV(NewVecData("summary", summ), os.TempDir()+"/summary.csv", ',', '\n', '"'))
+	_ = os.Remove(os.TempDir() + "/summary.csv")
+}
+
+func TestGData_NewReader(t *testing.T) {
+	gd := getData(t)
+
+	rdr1, e := gd.NewReader()
+	assert.Nil(t, e)
+	rdr2, e := gd.NewReader()
+	assert.Nil(t, e)
+
+	// Freeze has filled in the *Raw data
+	for _, fld := range gd.FieldList() {
+		assert.NotNil(t, gd.Get(fld).Raw, fld)
+	}
+
+	row1, _, e := rdr1.Read(2, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(row1))
+
+	// the cursors are independent
+	row2, _, e := rdr2.Read(1, false)
+	assert.Nil(t, e)
+	assert.Equal(t, row1[0], row2[0])
+
+	row1, _, e = rdr1.Read(1, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 3.0, row1[0][0])
+	assert.Equal(t, 0, gd.currRow)
+
+	assert.Nil(t, rdr2.Seek(6))
+	_, _, e = rdr2.Read(2, false)
+	assert.Equal(t, io.EOF, e)
+	assert.NotNil(t, rdr2.Seek(7))
+
+	n, e := rdr1.CountLines()
+	assert.Nil(t, e)
+	assert.Equal(t, 7, n)
+}
+
+func TestGData_NewStream(t *testing.T) {
+	x1 := []float64{1, 2, 3, 4, 8, 9, 10}
+	x2 := []string{"a", "b", "c", "a", "a", "a", "a"}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast(x1, nil), "x1", true, nil, false))
+	assert.Nil(t, gd.AppendD(NewRawCast(x2, nil), "x2", nil, false))
+	assert.Nil(t, gd.MakeOneHot("x2", "x2Oh"))
+
+	stream := gd.NewStream()
+	act, _, e := stream.Read(gd.Rows(), false)
+	assert.Nil(t, e)
+
+	// the raw data are not created
+	assert.Nil(t, gd.Get("x1").Raw)
+	assert.Nil(t, gd.Get("x2").Raw)
+
+	for row := range act {
+		assert.InEpsilon(t, x1[row], act[row][0], 1e-10)
+		assert.Equal(t, x2[row], act[row][1])
+	}
+
+	_, _, e = stream.Read(1, false)
+	assert.Equal(t, io.EOF, e)
+
+	// the same rows as Read
+	exp, _, e := gd.Read(gd.Rows(), false)
+	assert.Nil(t, e)
+	assert.Equal(t, len(exp), len(act))
+	assert.Equal(t, exp[3][1], act[3][1])
+}
+
+func TestGData_AppendRowsGrow(t *testing.T) {
+	gd := getData(t)
+
+	gdApp := NewGData()
+	assert.Nil(t, gdApp.AppendC(NewRawCast([]float64{5, 6}, nil), "x1", false, nil, true))
+	assert.Nil(t, gdApp.AppendD(NewRawCast([]string{"d", "a"}, nil), "x2", nil, true))
+	assert.Nil(t, gdApp.AppendD(NewRawCast([]int32{4, 4}, nil), "x3", nil, true))
+	assert.Nil(t, gdApp.MakeOneHot("x2", "x2Oh"))
+
+	fts := gd.GetFTypes()
+
+	// "d" is not a level of x2 and there's no default
+	_, e := gd.AppendRows(gdApp, fts)
+	assert.NotNil(t, e)
+
+	report := make(NewLevels)
+	gdOut, e := gd.AppendRows(gdApp, fts, WithGrowLevels(report))
+	assert.Nil(t, e)
+	assert.Equal(t, NewLevels{"x2": []any{"d"}}, report)
+
+	// existing levels keep their codes
+	ft := gdOut.GetFType("x2")
+	assert.Equal(t, Levels{"a": 0, "b": 1, "c": 2, "d": 3}, ft.FP.Lvl)
+	assert.Equal(t, []int32{0, 1, 2, 0, 0, 0, 0, 3, 0}, gdOut.Get("x2").Data)
+
+	// the one-hot field is widened
+	oh := gdOut.Get("x2Oh")
+	assert.Equal(t, 4, oh.FT.Cats)
+	assert.Equal(t, []float64{0, 0, 0, 1}, oh.Data.([]float64)[28:32])
+
+	// the input FTypes are not changed
+	assert.Equal(t, 3, len(fts.Get("x2").FP.Lvl))
+}
+
+func TestGData_UnseenPolicy(t *testing.T) {
+	raw := NewRawCast([]string{"a", "b", "d", "a", "e"}, nil)
+
+	tests := []struct {
+		policy UnseenPolicy
+		exp    []int32
+	}{
+		{UnseenOther, []int32{0, 1, 2, 0, 2}},
+		{UnseenError, nil},
+		{UnseenMostFrequent, []int32{0, 1, 0, 0, 0}},
+		{UnseenAverage, []int32{0, 1, 2, 0, 2}},
+	}
+
+	for _, tst := range tests {
+		fp := &FParam{Lvl: Levels{"a": 0, "b": 1, "c": 2}, Default: "c", Unseen: tst.policy, Frequent: "a"}
+		gd := NewGData()
+
+		e := gd.AppendD(raw, "x", fp, false)
+		if tst.exp == nil {
+			assert.NotNil(t, e)
+			continue
+		}
+
+		assert.Nil(t, e)
+		assert.Equal(t, tst.exp, gd.Get("x").Data)
+		assert.Equal(t, 2, gd.Get("x").Summary.Unseen)
+
+		assert.Nil(t, gd.MakeOneHot("x", "xOh"))
+		oh := gd.Get("xOh").Data.([]float64)
+
+		expOh := []float64{0, 0, 1}
+		switch tst.policy {
+		case UnseenMostFrequent:
+			expOh = []float64{1, 0, 0}
+		case UnseenAverage:
+			expOh = []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}
+		}
+
+		assert.Equal(t, expOh, oh[6:9])
+	}
+
+	// without a default, unseen values are an error unless they map to the most frequent level
+	fp := &FParam{Lvl: Levels{"a": 0, "b": 1, "c": 2}}
+	assert.NotNil(t, NewGData().AppendD(raw, "x", fp, false))
+
+	fp.Unseen = UnseenMostFrequent
+	assert.NotNil(t, NewGData().AppendD(raw, "x", fp, false))
+
+	fp.Frequent = "b"
+	assert.Nil(t, NewGData().AppendD(raw, "x", fp, false))
+
+	// the most frequent level is found when the levels are built, not from the data scored
+	build := NewGData()
+	assert.Nil(t, build.AppendD(NewRawCast([]string{"b", "a", "b", "c"}, nil), "x", nil, false))
+	fp = build.GetFType("x").FP
+	assert.Equal(t, "b", fp.Frequent)
+
+	fp.Unseen = UnseenMostFrequent
+	score := NewGData()
+	assert.Nil(t, score.AppendD(raw, "x", fp, false))
+	assert.Equal(t, []int32{0, 1, 1, 0, 1}, score.Get("x").Data)
+}
+
+func TestGData_UnseenRows(t *testing.T) {
+	raw := NewRawCast([]string{"a", "d", "b", "a", "e"}, nil)
+	fp := &FParam{Lvl: Levels{"a": 0, "b": 1}, Default: "a", Unseen: UnseenAverage}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(raw, "x", fp, false))
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{0, 1, 2, 3, 4}, nil), "row", false, nil, false))
+	assert.Equal(t, []bool{false, true, false, false, true}, gd.Get("x").unseen)
+
+	gd.Swap(0, 1)
+	assert.Equal(t, []bool{true, false, false, false, true}, gd.Get("x").unseen)
+	gd.Swap(0, 1)
+
+	sub, e := gd.Subset([]int{1, 2, 4})
+	assert.Nil(t, e)
+	assert.Equal(t, []bool{true, false, true}, sub.Get("x").unseen)
+	assert.Equal(t, 2, sub.Get("x").Summary.Unseen)
+
+	sl, e := gd.Slice(func(row int) bool { return row > 2 })
+	assert.Nil(t, e)
+	assert.Equal(t, []bool{false, true}, sl.Get("x").unseen)
+	assert.Equal(t, UnseenAverage, sl.GetFType("x").FP.Unseen)
+
+	// the unseen rows keep the average coding
+	assert.Nil(t, sl.MakeOneHot("x", "xOh"))
+	assert.Equal(t, []float64{1, 0, 0.5, 0.5}, sl.Get("xOh").Data)
+}
+
+func TestGData_ToFloat32(t *testing.T) {
+	gd := NewGData()
+	x0 := []any{3.0, 1.0, 2.0, 4.0}
+	assert.Nil(t, gd.AppendC(NewRaw(x0, nil), "x", true, nil, false))
+	assert.Nil(t, gd.AppendD(NewRawCast([]string{"c", "a", "b", "d"}, nil), "y", nil, false))
+
+	assert.Nil(t, gd.ToFloat32("x"))
+	assert.NotNil(t, gd.ToFloat32("y"))
+	assert.NotNil(t, gd.ToFloat32("z"))
+
+	d := gd.Get("x")
+	assert.True(t, d.FT.Float32)
+	assert.Len(t, d.Data.([]float32), 4)
+
+	// values come back as float64 on their original scale
+	raw, e := gd.GetRaw("x")
+	assert.Nil(t, e)
+	for ind, x := range x0 {
+		assert.InEpsilon(t, x.(float64), raw.Data[ind].(float64), 1e-6)
+	}
+
+	backing, shape := batchSlice(d, 1, 3)
+	assert.Equal(t, []int{2, 1}, shape)
+	assert.Len(t, backing.([]float64), 2)
+
+	assert.Nil(t, gd.Sort("x", true))
+	assert.Equal(t, []int32{0, 1, 2, 3}, gd.Get("y").Data)
+
+	// the precision is kept when the GData is rebuilt
+	gdCopy, e := gd.Copy()
+	assert.Nil(t, e)
+	_, ok := gdCopy.Get("x").Data.([]float32)
+	assert.True(t, ok)
+
+	gdSl, e := gd.Slice(func(row int) bool { return row > 0 })
+	assert.Nil(t, e)
+	assert.Len(t, gdSl.Get("x").Data.([]float32), 3)
+}
+
+func TestGData_AppendDate(t *testing.T) {
+	dts := []any{"20200301", "19691231", "20200115", "20210701"}
+	gd := NewGData()
+	assert.Nil(t, gd.AppendDate(NewRaw(dts, nil), "dt", false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{1.0, 2.0, 3.0, 4.0}, nil), "x", false, nil, false))
+	assert.NotNil(t, gd.AppendDate(NewRaw([]any{"junk", "20200101", "20200101", "20200101"}, nil), "bad", false))
+
+	d := gd.Get("dt")
+	assert.Equal(t, FRDate, d.FT.Role)
+	assert.Equal(t, []int64{18322, -1, 18276, 18809}, d.Data)
+
+	raw, e := gd.GetRaw("dt")
+	assert.Nil(t, e)
+	assert.Equal(t, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), raw.Data[1])
+
+	assert.Nil(t, gd.Sort("dt", true))
+	assert.Equal(t, []int64{-1, 18276, 18322, 18809}, gd.Get("dt").Data)
+	assert.Equal(t, []float64{2, 3, 1, 4}, gd.Get("x").Data)
+
+	gdW, e := gd.WhereDates("dt", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{3, 1}, gdW.Get("x").Data)
+	assert.Equal(t, FRDate, gdW.GetFType("dt").Role)
+
+	_, e = gd.WhereDates("x", time.Time{}, time.Time{})
+	assert.NotNil(t, e)
+
+	sl, e := NewSlicerDates("dt", NewVecData("test", gd), time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, e)
+
+	gdSl, e := gd.Slice(sl)
+	assert.Nil(t, e)
+	assert.Equal(t, []int64{18322, 18809}, gdSl.Get("dt").Data)
+}
+
+func TestGData_AppendOrdinal(t *testing.T) {
+	raw := NewRawCast([]string{"low", "high", "mid", "low"}, nil)
+	gd := NewGData()
+	assert.Nil(t, gd.AppendOrdinal(raw, "risk", []any{"low", "mid", "high"}, false))
+	assert.NotNil(t, gd.AppendOrdinal(raw, "bad", []any{"low", "mid"}, false))
+	assert.NotNil(t, gd.AppendOrdinal(raw, "rep", []any{"low", "low", "high"}, false))
+
+	d := gd.Get("risk")
+	assert.Equal(t, FROrdinal, d.FT.Role)
+	assert.Equal(t, []int32{0, 2, 1, 0}, d.Data)
+
+	// cumulative coding
+	assert.Nil(t, gd.MakeOneHot("risk", "riskOh"))
+	assert.Equal(t, []float64{1, 0, 0, 1, 1, 1, 1, 1, 0, 1, 0, 0}, gd.Get("riskOh").Data)
+
+	assert.Nil(t, gd.Sort("risk", false))
+	rawSort, e := gd.GetRaw("risk")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"high", "mid", "low", "low"}, rawSort.Data)
+
+	desc := d.Describe(0)
+	assert.Less(t, strings.Index(desc, "low"), strings.Index(desc, "mid"))
+	assert.Less(t, strings.Index(desc, "mid"), strings.Index(desc, "high"))
+
+	gdCopy, e := gd.Copy()
+	assert.Nil(t, e)
+	assert.Equal(t, FROrdinal, gdCopy.GetFType("risk").Role)
+	assert.Equal(t, gd.Get("riskOh").Data, gdCopy.Get("riskOh").Data)
+
+	for _, tst := range []struct {
+		expr string
+		exp  []any
+	}{
+		{"risk < 'high'", []any{0.0, 1.0, 1.0, 1.0}},
+		{"risk >= 'mid'", []any{1.0, 1.0, 0.0, 0.0}},
+		{"'low' == risk", []any{0.0, 0.0, 1.0, 1.0}},
+	} {
+		root := &OpNode{Expression: tst.expr}
+		assert.Nil(t, Expr2Tree(root), tst.expr)
+		assert.Nil(t, Evaluate(root, NewVecData("test", gd)), tst.expr)
+		assert.Equal(t, tst.exp, root.Raw.Data, tst.expr)
+	}
+
+	root := &OpNode{Expression: "risk < 'none'"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, NewVecData("test", gd)))
+}
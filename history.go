@@ -0,0 +1,147 @@
+package seafan
+
+// history.go records the training dynamics of a Fit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// FitHistory records the progress of a Fit by epoch.
+type FitHistory struct {
+	Epoch     []int     // epoch number (starting at 1)
+	InCost    []float64 // in-sample cost at the end of the epoch
+	ValCost   []float64 // validation cost (NaN if there is no validation Pipeline)
+	LearnRate []float64 // learning rate used in the epoch
+	GradNorm  []float64 // average L2 norm of the gradient across the batches of the epoch
+	ParamNorm []float64 // L2 norm of the parameters at the end of the epoch
+	Seconds   []float64 // time to run the epoch, in seconds
+	Best      []bool    // true if the epoch improved on the best cost so far
+}
+
+// fields are the names of the fields of the history for export
+var historyFields = []string{"epoch", "inCost", "valCost", "learnRate", "gradNorm", "paramNorm", "seconds", "best"}
+
+// add appends an epoch to the history
+func (h *FitHistory) add(ep int, inCost, valCost, lr, gradNorm, paramNorm, seconds float64, best bool) {
+	h.Epoch = append(h.Epoch, ep)
+	h.InCost = append(h.InCost, inCost)
+	h.ValCost = append(h.ValCost, valCost)
+	h.LearnRate = append(h.LearnRate, lr)
+	h.GradNorm = append(h.GradNorm, gradNorm)
+	h.ParamNorm = append(h.ParamNorm, paramNorm)
+	h.Seconds = append(h.Seconds, seconds)
+	h.Best = append(h.Best, best)
+}
+
+// Len returns the number of epochs in the history
+func (h *FitHistory) Len() int {
+	return len(h.Epoch)
+}
+
+// columns returns the history as columns in the order of historyFields. best is returned as 0/1.
+func (h *FitHistory) columns() [][]any {
+	cols := make([][]any, len(historyFields))
+
+	for row := 0; row < h.Len(); row++ {
+		best := int32(0)
+		if h.Best[row] {
+			best = 1
+		}
+
+		vals := []any{int32(h.Epoch[row]), h.InCost[row], h.ValCost[row], h.LearnRate[row], h.GradNorm[row],
+			h.ParamNorm[row], h.Seconds[row], best}
+
+		for col, v := range vals {
+			cols[col] = append(cols[col], v)
+		}
+	}
+
+	return cols
+}
+
+// ToCSV writes the history to a CSV file with a header row.
+func (h *FitHistory) ToCSV(fileName string) error {
+	f, e := os.Create(fileName)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = f.Close() }()
+
+	w := csv.NewWriter(f)
+	if e := w.Write(historyFields); e != nil {
+		return e
+	}
+
+	cols := h.columns()
+
+	for row := 0; row < h.Len(); row++ {
+		line := make([]string, len(cols))
+		for col := range cols {
+			line[col] = fmt.Sprintf("%v", cols[col][row])
+		}
+
+		if e := w.Write(line); e != nil {
+			return e
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+// ToPipe returns the history as a Pipeline. All fields are FRCts. The field best is 1 if the epoch improved on the
+// best cost so far.
+func (h *FitHistory) ToPipe() (Pipeline, error) {
+	if h.Len() == 0 {
+		return nil, Wrapper(ErrNNModel, "ToPipe: history is empty")
+	}
+
+	return VecFromAny(h.columns(), historyFields, nil)
+}
+
+// gradNorm returns the L2 norm of the gradients of nodes
+func gradNorm(nodes G.Nodes) float64 {
+	ss := 0.0
+
+	for _, n := range nodes {
+		g, e := n.Grad()
+		if e != nil {
+			continue
+		}
+
+		if x, ok := g.Data().([]float64); ok {
+			ss += sumSq(x)
+		}
+	}
+
+	return math.Sqrt(ss)
+}
+
+// paramNorm returns the L2 norm of the values of nodes
+func paramNorm(nodes G.Nodes) float64 {
+	ss := 0.0
+
+	for _, n := range nodes {
+		if x, ok := n.Value().Data().([]float64); ok {
+			ss += sumSq(x)
+		}
+	}
+
+	return math.Sqrt(ss)
+}
+
+// sumSq returns the sum of squares of x
+func sumSq(x []float64) float64 {
+	ss := 0.0
+	for _, xv := range x {
+		ss += xv * xv
+	}
+
+	return ss
+}
@@ -0,0 +1,387 @@
+package seafan
+
+// jsonl.go reads and writes pipelines as JSON lines: one flat JSON object per row
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// PipeToJSONL saves the pipe as JSON lines.  Each row is a JSON object of the fields of TableSpec (FROneHot and
+// FREmbed fields are omitted).  Dates are written in RFC 3339 format and NaN values as null.
+func PipeToJSONL(pipe Pipeline, outFile string) error {
+	if outFile == "" {
+		return fmt.Errorf("PipeToJSONL: outFile cannot be empty")
+	}
+
+	gd := pipe.GData()
+
+	td := gd.TableSpec()
+	if td == nil {
+		return Wrapper(ErrPipe, "PipeToJSONL: fields have unsupported types")
+	}
+
+	// keys are encoded once
+	keys := make([][]byte, len(td.FieldDefs))
+	for ind := 0; ind < len(keys); ind++ {
+		var e error
+		if keys[ind], e = json.Marshal(td.FieldDefs[ind].Name); e != nil {
+			return e
+		}
+	}
+
+	handle, e := os.Create(outFile)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = handle.Close() }()
+
+	const chunk = 1000 // rows read at a time
+
+	wtr := bufio.NewWriter(handle)
+	stream := gd.NewStream()
+
+	for {
+		data, _, err := stream.Read(chunk, false)
+		for _, row := range data {
+			if e := writeJSONRow(wtr, keys, row); e != nil {
+				return e
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return Wrapper(err, "PipeToJSONL")
+		}
+	}
+
+	return wtr.Flush()
+}
+
+// writeJSONRow writes row as a JSON object with keys
+func writeJSONRow(wtr *bufio.Writer, keys [][]byte, row []any) error {
+	var b bytes.Buffer
+
+	b.WriteByte('{')
+
+	for col, val := range row {
+		if col > 0 {
+			b.WriteByte(',')
+		}
+
+		b.Write(keys[col])
+		b.WriteByte(':')
+
+		if x, ok := val.(float64); ok && (math.IsNaN(x) || math.IsInf(x, 0)) {
+			val = nil
+		}
+
+		js, e := json.Marshal(val)
+		if e != nil {
+			return e
+		}
+
+		b.Write(js)
+	}
+
+	b.WriteString("}\n")
+
+	_, e := wtr.Write(b.Bytes())
+
+	return e
+}
+
+// JSONLToPipe creates a pipe from a file of JSON lines.  Each line is a flat JSON object: values may be numbers,
+// strings, booleans or null but not objects or arrays.  The fields are the keys, in the order they are first met.
+//
+// Optional fts specifies the FTypes, usually to match an existing pipeline.  Values are coerced to the type of their
+// FType: numbers and numeric strings to float64 for FRCts, and to the type of the levels for FRCat.  FROneHot and
+// FREmbed fields of fts are made from their FRCat fields.  Without an FType, numbers are FRCts and strings are FRCat
+// (as dates if they all are dates).  Missing and null values are NaN for FRCts fields and the Default level for
+// FRCat fields (an error if there is no Default).
+//
+// Optional opts are applied to the pipe before it is initialized (e.g. WithDerived).
+func JSONLToPipe(jsonlFile string, fts FTypes, keepRaw bool, opts ...Opts) (pipe Pipeline, err error) {
+	handle, e := os.Open(jsonlFile)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = handle.Close() }()
+
+	fields, cols, e := readJSONL(handle)
+	if e != nil {
+		return nil, Wrapper(e, "JSONLToPipe")
+	}
+
+	if len(fields) == 0 {
+		return nil, Wrapper(ErrPipe, "JSONLToPipe: no data")
+	}
+
+	gd := NewGData()
+
+	for ind, field := range fields {
+		ft := fts.Get(field)
+
+		raw, role, e := coerceJSON(cols[ind], ft)
+		if e != nil {
+			return nil, Wrapper(e, fmt.Sprintf("JSONLToPipe: field %s", field))
+		}
+
+		switch role {
+		case FRCts:
+			normalize, fp := false, (*FParam)(nil)
+			if ft != nil {
+				normalize, fp = ft.Normalized, ft.FP
+			}
+
+			e = gd.AppendC(raw, field, normalize, fp, keepRaw)
+			if e == nil && ft != nil && ft.Float32 {
+				e = gd.ToFloat32(field)
+			}
+		case FRCat:
+			var fp *FParam
+			if ft != nil && ft.FP != nil {
+				fpCopy := *ft.FP
+				fp = &fpCopy
+			}
+
+			e = gd.AppendD(raw, field, fp, keepRaw)
+		}
+
+		if e != nil {
+			return nil, Wrapper(e, "JSONLToPipe")
+		}
+	}
+
+	for _, ft := range fts {
+		if ft.Role != FROneHot && ft.Role != FREmbed {
+			continue
+		}
+
+		if e := gd.MakeOneHot(ft.From, ft.Name); e != nil {
+			return nil, Wrapper(e, "JSONLToPipe")
+		}
+
+		if ft.Role == FREmbed {
+			datum := gd.Get(ft.Name)
+			datum.FT.Role, datum.FT.EmbCols = FREmbed, ft.EmbCols
+		}
+	}
+
+	pipe = NewVecData("JSONL Pipeline", gd)
+	WithBatchSize(0)(pipe)
+	WithKeepRaw(keepRaw)(pipe)
+
+	for _, o := range opts {
+		o(pipe)
+	}
+
+	if e := pipe.Init(); e != nil {
+		return nil, e
+	}
+
+	return pipe, nil
+}
+
+// readJSONL reads the JSON lines of rdr into columns.  Missing values are nil.
+func readJSONL(rdr io.Reader) (fields []string, cols [][]any, err error) {
+	dec := json.NewDecoder(rdr)
+	dec.UseNumber()
+
+	pos := make(map[string]int)
+
+	for rows := 0; dec.More(); rows++ {
+		for ind := range cols {
+			cols[ind] = append(cols[ind], nil)
+		}
+
+		if tok, e := dec.Token(); e != nil || tok != json.Delim('{') {
+			return nil, nil, Wrapper(ErrPipe, fmt.Sprintf("row %d is not a JSON object", rows))
+		}
+
+		for dec.More() {
+			tok, e := dec.Token()
+			if e != nil {
+				return nil, nil, Wrapper(ErrPipe, fmt.Sprintf("row %d: %v", rows, e))
+			}
+
+			key := tok.(string)
+
+			val, e := dec.Token()
+			if e != nil {
+				return nil, nil, Wrapper(ErrPipe, fmt.Sprintf("row %d: %v", rows, e))
+			}
+
+			if _, ok := val.(json.Delim); ok {
+				return nil, nil, Wrapper(ErrPipe, fmt.Sprintf("row %d: field %s is not a scalar", rows, key))
+			}
+
+			if _, ok := pos[key]; !ok {
+				pos[key] = len(fields)
+				fields = append(fields, key)
+				cols = append(cols, make([]any, rows+1))
+			}
+
+			cols[pos[key]][rows] = val
+		}
+
+		// closing }
+		if _, e := dec.Token(); e != nil {
+			return nil, nil, Wrapper(ErrPipe, fmt.Sprintf("row %d: %v", rows, e))
+		}
+	}
+
+	return fields, cols, nil
+}
+
+// coerceJSON converts the values of a field read by readJSONL to the type of ft.  If ft is nil, the type is
+// inferred.
+func coerceJSON(vals []any, ft *FType) (raw *Raw, role FRole, err error) {
+	role = FRCat
+	if ft != nil {
+		role = ft.Role
+	}
+
+	// the type of the values: float64, string, int64, int32 or time.Time
+	var kind any
+
+	switch {
+	case ft != nil && ft.Role == FRCts:
+		kind = 0.0
+	case ft != nil && ft.Role == FRCat:
+		if ft.FP != nil {
+			for key := range ft.FP.Lvl {
+				if key != nil {
+					kind = key
+					break
+				}
+			}
+		}
+
+		// no levels to go by
+		if kind == nil {
+			kind = ""
+			if allJSON(vals, json.Number("")) {
+				kind = int64(0)
+			}
+		}
+	case ft != nil:
+		return nil, role, Wrapper(ErrPipe, fmt.Sprintf("role %v not supported", ft.Role))
+	case allJSON(vals, json.Number(""), true):
+		role, kind = FRCts, 0.0
+	case allJSON(vals, "") && allDates(vals):
+		kind = time.Time{}
+	default:
+		kind = ""
+	}
+
+	data := make([]any, len(vals))
+
+	for row, val := range vals {
+		if val == nil {
+			switch {
+			case role == FRCts:
+				data[row] = math.NaN()
+			case ft != nil && ft.FP != nil && ft.FP.Default != nil:
+				data[row] = ft.FP.Default
+			default:
+				return nil, role, Wrapper(ErrPipe, fmt.Sprintf("row %d: missing value", row))
+			}
+
+			continue
+		}
+
+		if data[row], err = coerceValue(val, kind); err != nil {
+			return nil, role, Wrapper(ErrPipe, fmt.Sprintf("row %d: %v", row, err))
+		}
+	}
+
+	return NewRaw(data, nil), role, nil
+}
+
+// coerceValue converts the JSON value val to the type of kind
+func coerceValue(val, kind any) (any, error) {
+	str := fmt.Sprint(val)
+	if b, ok := val.(bool); ok {
+		str = "0"
+		if b {
+			str = "1"
+		}
+	}
+
+	switch kind.(type) {
+	case float64:
+		return strconv.ParseFloat(str, 64)
+	case int64:
+		return strconv.ParseInt(str, 10, 64)
+	case int32:
+		x, e := strconv.ParseInt(str, 10, 32)
+		return int32(x), e
+	case int:
+		x, e := strconv.ParseInt(str, 10, 64)
+		return int(x), e
+	case time.Time:
+		return any2Date(str)
+	case string:
+		if _, ok := val.(bool); ok {
+			return fmt.Sprint(val), nil
+		}
+
+		return str, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %v to %s", val, reflect.TypeOf(kind))
+}
+
+// allJSON returns true if the non-nil values of vals are all of the type of one of types
+func allJSON(vals []any, types ...any) bool {
+	for _, val := range vals {
+		if val == nil {
+			continue
+		}
+
+		ok := false
+		for _, t := range types {
+			if reflect.TypeOf(val) == reflect.TypeOf(t) {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allDates returns true if the non-nil values of vals, which are strings, are all dates in a registered format
+func allDates(vals []any) bool {
+	n := 0
+
+	for _, val := range vals {
+		if val == nil {
+			continue
+		}
+
+		if _, ok := regDate(val.(string)); !ok {
+			return false
+		}
+
+		n++
+	}
+
+	return n > 0
+}
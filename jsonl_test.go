@@ -0,0 +1,108 @@
+package seafan
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLToPipe(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	outFile := os.TempDir() + "/pipeTest1.jsonl"
+	defer func() { _ = os.Remove(outFile) }()
+
+	assert.Nil(t, PipeToJSONL(pipe, outFile))
+
+	back, e := JSONLToPipe(outFile, nil, false)
+	assert.Nil(t, e)
+	assert.Equal(t, pipe.FieldList(), back.FieldList())
+	assert.Equal(t, pipe.Rows(), back.Rows())
+
+	for _, fld := range pipe.FieldList() {
+		exp, _ := pipe.GData().GetRaw(fld)
+		act, _ := back.GData().GetRaw(fld)
+		assert.Equal(t, exp.Data, act.Data, fld)
+	}
+
+	// types are coerced by the FTypes
+	jsonl := `{"id": 3, "x": "1.5", "d": "2023-01-31", "c": "a"}
+{"id": 4, "x": 2, "d": "2023-02-28", "flag": true}
+{"id": 3, "x": null, "d": "2023-03-31", "c": "b"}
+`
+	inFile := os.TempDir() + "/coerce.jsonl"
+	defer func() { _ = os.Remove(inFile) }()
+
+	assert.Nil(t, os.WriteFile(inFile, []byte(jsonl), 0o644))
+
+	fts := FTypes{
+		{Name: "id", Role: FRCat},
+		{Name: "x", Role: FRCts},
+		{Name: "c", Role: FRCat, FP: &FParam{Default: "zz"}},
+		{Name: "cOh", Role: FROneHot, From: "c"},
+	}
+
+	in, e := JSONLToPipe(inFile, fts, true)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"id", "x", "d", "c", "flag", "cOh"}, in.FieldList())
+
+	assert.Equal(t, []any{int64(3), int64(4), int64(3)}, in.Get("id").Raw.Data)
+	assert.Equal(t, 1.5, in.Get("x").Raw.Data[0])
+	assert.Equal(t, 2.0, in.Get("x").Raw.Data[1])
+	assert.Equal(t, []any{"a", "zz", "b"}, in.Get("c").Raw.Data)
+	assert.Equal(t, FRCat, in.GetFType("d").Role)
+	assert.Equal(t, time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC), in.Get("d").Raw.Data[0])
+	assert.Equal(t, 3, in.GetFType("cOh").Cats)
+
+	// booleans are numbers, missing values are NaN
+	assert.Equal(t, FRCts, in.GetFType("flag").Role)
+	assert.Equal(t, 1.0, in.Get("flag").Raw.Data[1])
+	assert.True(t, math.IsNaN(in.Get("flag").Raw.Data[0].(float64)))
+
+	// nested values are not allowed
+	assert.Nil(t, os.WriteFile(inFile, []byte(`{"a": {"b": 1}}`), 0o644))
+	_, e = JSONLToPipe(inFile, nil, false)
+	assert.NotNil(t, e)
+	assert.True(t, strings.Contains(e.Error(), "not a scalar"))
+}
+
+func TestPipeToJSONL_Seq(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "b", "a"}, nil), "id", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{3.0, 1.0, 1.0, 2.0, 2.0}, nil), "month", false, nil, false))
+	assert.Nil(t, gd.MakeSequence("seq", "id", "month", 2, "month"))
+
+	outFile := os.TempDir() + "/seq.jsonl"
+	defer func() { _ = os.Remove(outFile) }()
+
+	// sequence fields are not written
+	assert.Nil(t, PipeToJSONL(NewVecData("seq", gd), outFile))
+
+	back, e := JSONLToPipe(outFile, nil, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 5, back.Rows())
+	assert.ElementsMatch(t, []string{"id", "month"}, back.FieldList())
+
+	// more rows than are read at a time
+	n := 2500
+	x := make([]any, n)
+	for ind := range x {
+		x[ind] = float64(ind)
+	}
+
+	pipe, e := VecFromAny([][]any{x}, []string{"x"}, nil)
+	assert.Nil(t, e)
+	assert.Nil(t, PipeToJSONL(pipe, outFile))
+
+	back, e = JSONLToPipe(outFile, nil, false)
+	assert.Nil(t, e)
+	assert.Equal(t, n, back.Rows())
+}
@@ -12,11 +12,21 @@ func _() {
 	_ = x[FC-1]
 	_ = x[DropOut-2]
 	_ = x[Target-3]
+	_ = x[Output-4]
+	_ = x[Offset-5]
+	_ = x[GRU-6]
+	_ = x[LSTM-7]
+	_ = x[Conv1D-8]
+	_ = x[MaxPool-9]
+	_ = x[AvgPool-10]
+	_ = x[Attention-11]
+	_ = x[InputDropout-12]
+	_ = x[GaussianNoise-13]
 }
 
-const _Layer_name = "InputFCDropOutTarget"
+const _Layer_name = "InputFCDropOutTargetOutputOffsetGRULSTMConv1DMaxPoolAvgPoolAttentionInputDropoutGaussianNoise"
 
-var _Layer_index = [...]uint8{0, 5, 7, 14, 20}
+var _Layer_index = [...]uint8{0, 5, 7, 14, 20, 26, 32, 35, 39, 45, 52, 59, 68, 80, 93}
 
 func (i Layer) String() string {
 	if i < 0 || i >= Layer(len(_Layer_index)-1) {
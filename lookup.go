@@ -0,0 +1,152 @@
+package seafan
+
+// lookup.go maps values through reference tables registered by RegisterLookup
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/invertedv/utilities"
+)
+
+// lookupTable is a reference table registered by RegisterLookup
+type lookupTable struct {
+	gd   *GData
+	rows map[any]int // row of gd for each key
+}
+
+var (
+	lookupMu     sync.RWMutex
+	lookupTables = make(map[string]*lookupTable)
+)
+
+// RegisterLookup registers gd as the reference table name for the expression function lookup.  keyField is the
+// field of gd that lookup matches.  Its values must be unique.  For instance, with a table of states and regions:
+//
+//	e := RegisterLookup("regions", regionGD, "state")
+//
+// the expression
+//
+//	lookup(state, 'regions', 'region')
+//
+// maps the field state to its region without a Join.  A table is replaced if name is registered again.  If gd is nil,
+// the table is removed.
+func RegisterLookup(name string, gd *GData, keyField string) error {
+	lookupMu.Lock()
+	defer lookupMu.Unlock()
+
+	if gd == nil {
+		delete(lookupTables, name)
+		return nil
+	}
+
+	keys, e := gd.GetRaw(keyField)
+	if e != nil {
+		return Wrapper(ErrGData, fmt.Sprintf("RegisterLookup %s: %v", name, e))
+	}
+
+	tbl := &lookupTable{gd: gd, rows: make(map[any]int)}
+
+	for row, key := range keys.Data {
+		k := lookupKey(key)
+		if _, ok := tbl.rows[k]; ok {
+			return Wrapper(ErrGData, fmt.Sprintf("RegisterLookup %s: key %v occurs more than once", name, key))
+		}
+
+		tbl.rows[k] = row
+	}
+
+	// load the values now, so lookup doesn't change gd
+	for _, fld := range gd.FieldList() {
+		_, _ = gd.GetRaw(fld)
+	}
+
+	lookupTables[name] = tbl
+
+	return nil
+}
+
+// Lookups returns the names of the registered reference tables in sorted order
+func Lookups() []string {
+	lookupMu.RLock()
+	defer lookupMu.RUnlock()
+
+	names := make([]string, 0, len(lookupTables))
+	for name := range lookupTables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupKey normalizes numeric keys to float64, so keys match regardless of the type of number
+func lookupKey(key any) any {
+	switch key.(type) {
+	case float64, float32, int, int32, int64:
+		x, _ := utilities.Any2Float64(key)
+		return *x
+	}
+
+	return key
+}
+
+// lookup evaluates lookup(<key>, <table>, <field>[, <default>]): the value of <field> in the row of the table whose
+// key is <key>.  Keys not in the table are <default> or, if there isn't one, an error.
+func lookup(node *OpNode) error {
+	var deltas []int
+
+	node.Raw, deltas = getDeltas(node)
+	if node.Raw == nil {
+		return fmt.Errorf("argument to lookup is missing")
+	}
+
+	name, ok1 := node.Inputs[1].Raw.Data[0].(string)
+	field, ok2 := node.Inputs[2].Raw.Data[0].(string)
+
+	if !ok1 || !ok2 || deltas[1]+deltas[2] > 0 {
+		return fmt.Errorf("lookup: table and field must be strings")
+	}
+
+	lookupMu.RLock()
+	tbl := lookupTables[name]
+	lookupMu.RUnlock()
+
+	if tbl == nil {
+		return newParseError(node.Inputs[1].Expression, fmt.Sprintf("lookup table %s not registered", name),
+			suggest(name, Lookups()))
+	}
+
+	vals, e := tbl.gd.GetRaw(field)
+	if e != nil {
+		return newParseError(node.Inputs[2].Expression, fmt.Sprintf("field %s not in lookup table %s", field, name),
+			suggest(field, tbl.gd.FieldList()))
+	}
+
+	ind0, indDef := 0, 0
+
+	for row := 0; row < node.Raw.Len(); row++ {
+		key := node.Inputs[0].Raw.Data[ind0]
+
+		switch tblRow, ok := tbl.rows[lookupKey(key)]; {
+		case ok:
+			node.Raw.Data[row] = vals.Data[tblRow]
+		case len(node.Inputs) == 4:
+			node.Raw.Data[row] = node.Inputs[3].Raw.Data[indDef]
+		default:
+			return fmt.Errorf("lookup: key %v not in table %s", key, name)
+		}
+
+		ind0 += deltas[0]
+		if len(node.Inputs) == 4 {
+			indDef += deltas[3]
+		}
+	}
+
+	node.Raw = NewRaw(node.Raw.Data, nil)
+	node.Role = tbl.gd.GetFType(field).Role
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	ref, e := VecFromAny([][]any{{"a", "x", "Last"}, {"r1", "r2", "r3"}, {10.0, 20.0, 30.0}, {1.0, 4.0, 7.0}},
+		[]string{"key", "region", "score", "num"}, nil)
+	assert.Nil(t, e)
+
+	assert.Nil(t, RegisterLookup("ref", ref.GData(), "key"))
+	defer func() { _ = RegisterLookup("ref", nil, "") }()
+
+	assert.Nil(t, RegisterLookup("byNum", ref.GData(), "num"))
+	defer func() { _ = RegisterLookup("byNum", nil, "") }()
+
+	assert.Equal(t, []string{"byNum", "ref"}, Lookups())
+
+	exprs := []struct {
+		expr string
+		exp  []any
+	}{
+		{"lookup(Field1,'ref','region','none')", []any{"r1", "none", "none", "r2", "none", "none", "r3"}},
+		{"lookup(Field1,'ref','score',0) * 2", []any{20.0, 0.0, 0.0, 40.0, 0.0, 0.0, 60.0}},
+		{"lookup(row,'byNum','key','')", []any{"a", "", "", "x", "", "", "Last"}},
+		{"lookup('x','ref','score')", []any{20.0}},
+	}
+
+	for _, ex := range exprs {
+		root := &OpNode{Expression: ex.expr}
+		assert.Nil(t, Expr2Tree(root), ex.expr)
+		assert.Nil(t, Evaluate(root, pipe), ex.expr)
+		assert.Equal(t, ex.exp, root.Raw.Data, ex.expr)
+	}
+
+	for _, expr := range []string{"lookup(Field1,'ref','region')", "lookup(Field1,'reff','region',0)",
+		"lookup(Field1,'ref','nope',0)"} {
+		root := &OpNode{Expression: expr}
+		assert.Nil(t, Expr2Tree(root), expr)
+		assert.NotNil(t, Evaluate(root, pipe), expr)
+	}
+
+	dup, e := VecFromAny([][]any{{"a", "a"}}, []string{"key"}, nil)
+	assert.Nil(t, e)
+	assert.NotNil(t, RegisterLookup("dup", dup.GData(), "key"))
+}
@@ -0,0 +1,217 @@
+package seafan
+
+// metrics.go implements the model metrics shared by Fit, CompareModels and user reports
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// MetricFunc computes a metric of the fitted values fit against the observed values obs.  It returns NaN if the
+// metric cannot be computed, for instance if obs and fit have different lengths.
+type MetricFunc func(obs, fit []float64) float64
+
+// metricDef is a metric and the direction in which it improves
+type metricDef struct {
+	fn     MetricFunc
+	higher bool // true if larger values are better
+}
+
+// metrics are the metrics available by name (lower case)
+var metrics = map[string]metricDef{
+	"rmse":    {fn: RMSE},
+	"mae":     {fn: MAE},
+	"mape":    {fn: MAPE},
+	"r2":      {fn: r2Metric, higher: true},
+	"auc":     {fn: AUC, higher: true},
+	"ks":      {fn: ksMetric, higher: true},
+	"logloss": {fn: LogLoss},
+	"gini":    {fn: Gini, higher: true},
+}
+
+// Metric returns the metric called name.  The names, which are not case-sensitive, are:
+//   - RMSE: root mean squared error;
+//   - MAE: mean absolute error;
+//   - MAPE: mean absolute percentage error.  Rows with an observed value of 0 are skipped;
+//   - R2: r-square, in percent (see R2);
+//   - AUC: area under the ROC curve.  obs is binary (values above 0.5 are events);
+//   - KS: KS statistic, in percent (see KS).  obs is binary;
+//   - LogLoss: the average negative log-likelihood of binary obs, with fit the probability of an event;
+//   - Gini: the Gini coefficient, 2*AUC-1.
+//
+// It returns nil if there is no such metric.
+func Metric(name string) MetricFunc {
+	if m, ok := metrics[strings.ToLower(name)]; ok {
+		return m.fn
+	}
+
+	return nil
+}
+
+// MetricHigher returns true if larger values of the metric name are better.
+func MetricHigher(name string) bool {
+	return metrics[strings.ToLower(name)].higher
+}
+
+// MetricNames returns the names of the metrics in alphabetical order
+func MetricNames() []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// RMSE returns the root mean squared error of fit
+func RMSE(obs, fit []float64) float64 {
+	if len(obs) != len(fit) || len(obs) == 0 {
+		return math.NaN()
+	}
+
+	sse := 0.0
+	for ind, o := range obs {
+		sse += (o - fit[ind]) * (o - fit[ind])
+	}
+
+	return math.Sqrt(sse / float64(len(obs)))
+}
+
+// MAE returns the mean absolute error of fit
+func MAE(obs, fit []float64) float64 {
+	if len(obs) != len(fit) || len(obs) == 0 {
+		return math.NaN()
+	}
+
+	sae := 0.0
+	for ind, o := range obs {
+		sae += math.Abs(o - fit[ind])
+	}
+
+	return sae / float64(len(obs))
+}
+
+// MAPE returns the mean absolute percentage error of fit.  Rows with an observed value of 0 are skipped.
+func MAPE(obs, fit []float64) float64 {
+	if len(obs) != len(fit) {
+		return math.NaN()
+	}
+
+	sape, n := 0.0, 0
+	for ind, o := range obs {
+		if o == 0.0 {
+			continue
+		}
+
+		sape += math.Abs((o - fit[ind]) / o)
+		n++
+	}
+
+	if n == 0 {
+		return math.NaN()
+	}
+
+	return 100.0 * sape / float64(n)
+}
+
+// AUC returns the area under the ROC curve of fit for binary obs.  Ties in fit count half.
+func AUC(obs, fit []float64) float64 {
+	if len(obs) != len(fit) {
+		return math.NaN()
+	}
+
+	ord := make([]int, len(fit))
+	for ind := range ord {
+		ord[ind] = ind
+	}
+
+	sort.SliceStable(ord, func(i, j int) bool { return fit[ord[i]] < fit[ord[j]] })
+
+	// Mann-Whitney: the sum of the ranks of the events, with tied fits given their average rank
+	rankSum, nEvent := 0.0, 0
+	for start := 0; start < len(ord); {
+		end := start
+		for end+1 < len(ord) && fit[ord[end+1]] == fit[ord[start]] {
+			end++
+		}
+
+		rank := float64(start+end)/2.0 + 1.0
+		for _, row := range ord[start : end+1] {
+			if obs[row] > thresh {
+				rankSum += rank
+				nEvent++
+			}
+		}
+
+		start = end + 1
+	}
+
+	nNon := len(obs) - nEvent
+	if nEvent == 0 || nNon == 0 {
+		return math.NaN()
+	}
+
+	return (rankSum - float64(nEvent*(nEvent+1))/2.0) / float64(nEvent*nNon)
+}
+
+// Gini returns the Gini coefficient, 2*AUC-1, of fit for binary obs
+func Gini(obs, fit []float64) float64 {
+	return 2.0*AUC(obs, fit) - 1.0
+}
+
+// LogLoss returns the average negative log-likelihood of binary obs where fit is the probability of an event.
+// The probabilities are kept away from 0 and 1 by 1e-15.
+func LogLoss(obs, fit []float64) float64 {
+	const eps = 1e-15
+
+	if len(obs) != len(fit) || len(obs) == 0 {
+		return math.NaN()
+	}
+
+	ll := 0.0
+	for ind, o := range obs {
+		p := math.Min(math.Max(fit[ind], eps), 1.0-eps)
+
+		if o > thresh {
+			ll -= math.Log(p)
+			continue
+		}
+
+		ll -= math.Log(1.0 - p)
+	}
+
+	return ll / float64(len(obs))
+}
+
+// r2Metric is R2 as a MetricFunc.  It returns NaN, rather than the -1 of R2, if the obs are all equal.
+func r2Metric(obs, fit []float64) float64 {
+	if len(obs) != len(fit) || len(obs) == 0 {
+		return math.NaN()
+	}
+
+	for _, o := range obs {
+		if o != obs[0] {
+			return R2(obs, fit)
+		}
+	}
+
+	return math.NaN()
+}
+
+// ksMetric is KS as a MetricFunc
+func ksMetric(obs, fit []float64) float64 {
+	xy, e := NewXY(append([]float64{}, fit...), append([]float64{}, obs...))
+	if e != nil {
+		return math.NaN()
+	}
+
+	ks, _, _, e := KS(xy, nil)
+	if e != nil {
+		return math.NaN()
+	}
+
+	return ks
+}
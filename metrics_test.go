@@ -0,0 +1,38 @@
+package seafan
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetric(t *testing.T) {
+	obs := []float64{0, 0, 1, 1}
+	fit := []float64{0.1, 0.6, 0.4, 0.9}
+
+	assert.InDelta(t, math.Sqrt((0.01+0.36+0.36+0.01)/4), Metric("RMSE")(obs, fit), 1e-10)
+	assert.InDelta(t, (0.1+0.6+0.6+0.1)/4, Metric("mae")(obs, fit), 1e-10)
+	assert.InDelta(t, 100*(0.6+0.1)/2, Metric("MAPE")(obs, fit), 1e-10)
+
+	// 3 of the 4 event/non-event pairs are ordered correctly
+	assert.InDelta(t, 0.75, Metric("AUC")(obs, fit), 1e-10)
+	assert.InDelta(t, 0.5, Metric("Gini")(obs, fit), 1e-10)
+	assert.InDelta(t, 0.5, AUC(obs, []float64{1, 1, 1, 1}), 1e-10)
+	assert.InDelta(t, 1.0, AUC(obs, obs), 1e-10)
+
+	ll := -(math.Log(0.9) + math.Log(0.4) + math.Log(0.4) + math.Log(0.9)) / 4
+	assert.InDelta(t, ll, Metric("LogLoss")(obs, fit), 1e-10)
+
+	assert.InDelta(t, R2(obs, fit), Metric("R2")(obs, fit), 1e-10)
+	assert.Greater(t, Metric("KS")(obs, fit), 0.0)
+
+	assert.True(t, MetricHigher("auc"))
+	assert.False(t, MetricHigher("RMSE"))
+	assert.Equal(t, 8, len(MetricNames()))
+
+	assert.Nil(t, Metric("nope"))
+	assert.True(t, math.IsNaN(Metric("RMSE")(obs, fit[:2])))
+	assert.True(t, math.IsNaN(Metric("AUC")([]float64{1, 1}, []float64{0.2, 0.3})))
+	assert.True(t, math.IsNaN(Metric("R2")([]float64{1, 1}, []float64{0.2, 0.3})))
+}
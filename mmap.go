@@ -0,0 +1,232 @@
+package seafan
+
+// mmap.go implements saving GData in a binary layout that can be opened with mmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// mmapLayout describes the contents of the data file written by SaveMmap
+type mmapLayout struct {
+	Rows   int         `json:"rows"`
+	Fields []mmapField `json:"fields"`
+}
+
+// mmapSize is the size in bytes of an element of each kind of field
+var mmapSize = map[string]int{"float64": 8, "float32": 4, "int32": 4, "int64": 8}
+
+// mmapField describes the location and summary of a single field in the data file
+type mmapField struct {
+	Name   string      `json:"name"`
+	Kind   string      `json:"kind"`   // float64 or int32
+	Offset int         `json:"offset"` // offset in bytes from the start of the data file
+	Len    int         `json:"len"`    // number of elements
+	DistrC *Desc       `json:"distrC,omitempty"`
+	DistrD *levelsFile `json:"distrD,omitempty"`
+}
+
+// MappedGData is a GData whose data is memory-mapped copy-on-write from files created by SaveMmap. Multiple
+// processes that open the same files share a single copy of the data in memory.
+//
+// Methods that change the data in place (e.g. Sort, Shuffle) give the process a private copy of the pages they
+// change; the files are not changed.  Fields can be added and dropped.
+type MappedGData struct {
+	*GData
+	mem []byte // mapped data file
+}
+
+// SaveMmap saves the data in gd to three files:
+//   - fileRoot + "F.json" the FTypes (see FTypes Save)
+//   - fileRoot + "M.json" the layout and summaries of the fields
+//   - fileRoot + "D.bin" the data
+//
+// The data is saved in the byte order of the host, so the files are not portable between little- and big-endian
+// machines. The *Raw data is not saved.  Use OpenMmap to open the files.
+func (gd *GData) SaveMmap(fileRoot string) (err error) {
+	if gd.Rows() == 0 {
+		return Wrapper(ErrGData, "SaveMmap: no data")
+	}
+
+	if err = gd.GetFTypes().Save(fileRoot + "F.json"); err != nil {
+		return Wrapper(err, "SaveMmap")
+	}
+
+	f, err := os.Create(fileRoot + "D.bin")
+	if err != nil {
+		return Wrapper(err, "SaveMmap")
+	}
+	defer func() { _ = f.Close() }()
+
+	layout := mmapLayout{Rows: gd.Rows()}
+	offset := 0
+
+	for _, d := range gd.data {
+		fld := mmapField{Name: d.FT.Name, Offset: offset, DistrC: d.Summary.DistrC}
+
+		if d.Summary.DistrD != nil {
+			if fld.DistrD, err = newLevelsFile(d.Summary.DistrD); err != nil {
+				return Wrapper(err, fmt.Sprintf("SaveMmap: field %s", d.FT.Name))
+			}
+		}
+
+		var buf []byte
+
+		switch x := d.Data.(type) {
+		case []float64:
+			fld.Kind, fld.Len = "float64", len(x)
+			buf = unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(x))), len(x)*8)
+		case []float32:
+			fld.Kind, fld.Len = "float32", len(x)
+			buf = unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(x))), len(x)*4)
+		case []int32:
+			fld.Kind, fld.Len = "int32", len(x)
+			buf = unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(x))), len(x)*4)
+		case []int64:
+			fld.Kind, fld.Len = "int64", len(x)
+			buf = unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(x))), len(x)*8)
+		default:
+			return Wrapper(ErrGData, fmt.Sprintf("SaveMmap: field %s has unsupported data type", d.FT.Name))
+		}
+
+		// pad so that each field starts on an 8-byte boundary
+		pad := (8 - len(buf)%8) % 8
+		if _, err = f.Write(buf); err != nil {
+			return Wrapper(err, "SaveMmap")
+		}
+
+		if _, err = f.Write(make([]byte, pad)); err != nil {
+			return Wrapper(err, "SaveMmap")
+		}
+
+		offset += len(buf) + pad
+		layout.Fields = append(layout.Fields, fld)
+	}
+
+	js, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return Wrapper(err, "SaveMmap")
+	}
+
+	return os.WriteFile(fileRoot+"M.json", js, 0644)
+}
+
+// OpenMmap opens the files created by SaveMmap. The data is mapped copy-on-write. Call Close when done.
+func OpenMmap(fileRoot string) (*MappedGData, error) {
+	fts, e := LoadFTypes(fileRoot + "F.json")
+	if e != nil {
+		return nil, Wrapper(e, "OpenMmap")
+	}
+
+	js, e := os.ReadFile(fileRoot + "M.json")
+	if e != nil {
+		return nil, Wrapper(e, "OpenMmap")
+	}
+
+	var layout mmapLayout
+	if e := json.Unmarshal(js, &layout); e != nil {
+		return nil, Wrapper(e, "OpenMmap")
+	}
+
+	if len(layout.Fields) != len(fts) {
+		return nil, Wrapper(ErrGData, "OpenMmap: layout and FTypes files do not agree")
+	}
+
+	f, e := os.Open(fileRoot + "D.bin")
+	if e != nil {
+		return nil, Wrapper(e, "OpenMmap")
+	}
+	defer func() { _ = f.Close() }()
+
+	fi, e := f.Stat()
+	if e != nil {
+		return nil, Wrapper(e, "OpenMmap")
+	}
+
+	mem, e := mmapFile(f, int(fi.Size()))
+	if e != nil {
+		return nil, Wrapper(e, "OpenMmap")
+	}
+
+	mgd := &MappedGData{GData: NewGData(), mem: mem}
+	mgd.rows = layout.Rows
+
+	for ind, fld := range layout.Fields {
+		ft := fts[ind]
+		if ft.Name != fld.Name {
+			_ = mgd.Close()
+			return nil, Wrapper(ErrGData, fmt.Sprintf("OpenMmap: expected field %s, got %s", fld.Name, ft.Name))
+		}
+
+		size, ok := mmapSize[fld.Kind]
+		if !ok {
+			_ = mgd.Close()
+			return nil, Wrapper(ErrGData, fmt.Sprintf("OpenMmap: field %s has unknown kind %s", fld.Name, fld.Kind))
+		}
+
+		if fld.Offset < 0 || fld.Len < 0 || fld.Offset+fld.Len*size > len(mem) {
+			_ = mgd.Close()
+			return nil, Wrapper(ErrGData, fmt.Sprintf("OpenMmap: field %s is outside the data file", fld.Name))
+		}
+
+		d := &GDatum{FT: ft, Summary: Summary{NRows: layout.Rows, DistrC: fld.DistrC}}
+
+		if fld.DistrD != nil {
+			if d.Summary.DistrD, e = fld.DistrD.levels(); e != nil {
+				_ = mgd.Close()
+				return nil, Wrapper(e, fmt.Sprintf("OpenMmap: field %s", fld.Name))
+			}
+		}
+
+		d.Data = mmapSlice(mem, fld)
+
+		mgd.data = append(mgd.data, d)
+	}
+
+	return mgd, nil
+}
+
+// mmapSlice returns the data of fld in mem.  A field with no elements does not point into mem, since its offset may
+// be the end of mem.
+func mmapSlice(mem []byte, fld mmapField) any {
+	if fld.Len == 0 {
+		switch fld.Kind {
+		case "float64":
+			return []float64{}
+		case "float32":
+			return []float32{}
+		case "int32":
+			return []int32{}
+		default:
+			return []int64{}
+		}
+	}
+
+	ptr := unsafe.Pointer(&mem[fld.Offset])
+
+	switch fld.Kind {
+	case "float64":
+		return unsafe.Slice((*float64)(ptr), fld.Len)
+	case "float32":
+		return unsafe.Slice((*float32)(ptr), fld.Len)
+	case "int32":
+		return unsafe.Slice((*int32)(ptr), fld.Len)
+	default:
+		return unsafe.Slice((*int64)(ptr), fld.Len)
+	}
+}
+
+// Close unmaps the data.  After Close the MappedGData has no fields, so reading it returns an error (e.g. GetRaw)
+// or nil (e.g. Get) rather than reading unmapped memory.  Data obtained before Close must not be used after it.
+func (m *MappedGData) Close() error {
+	if m.mem == nil {
+		return nil
+	}
+
+	mem := m.mem
+	m.mem, m.GData = nil, NewGData()
+
+	return munmap(mem)
+}
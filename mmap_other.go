@@ -0,0 +1,17 @@
+//go:build !unix
+
+package seafan
+
+import (
+	"os"
+)
+
+// mmapFile is not supported on this platform
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, Wrapper(ErrGData, "mmap is not supported on this platform")
+}
+
+// munmap is not supported on this platform
+func munmap(mem []byte) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMmap(t *testing.T) {
+	gd := getData(t)
+	fileRoot := os.TempDir() + "/mmapTest"
+
+	assert.Nil(t, gd.SaveMmap(fileRoot))
+
+	defer func() {
+		for _, suffix := range []string{"F.json", "M.json", "D.bin"} {
+			_ = os.Remove(fileRoot + suffix)
+		}
+	}()
+
+	mgd, e := OpenMmap(fileRoot)
+	assert.Nil(t, e)
+
+	assert.Equal(t, gd.Rows(), mgd.Rows())
+	assert.Equal(t, gd.FieldList(), mgd.FieldList())
+
+	for _, fld := range gd.FieldList() {
+		assert.Equal(t, gd.Get(fld).Data, mgd.Get(fld).Data)
+		assert.Equal(t, gd.Get(fld).Summary, mgd.Get(fld).Summary)
+	}
+
+	x2, e := mgd.GetRaw("x2")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"a", "b", "c", "a", "a", "a", "a"}, x2.Data)
+
+	// pipelines can read the mapped data
+	pipe, e := NewVecData("mapped", mgd.GData).WhereExpr("x1 > 3")
+	assert.Nil(t, e)
+	assert.Equal(t, 4, pipe.Rows())
+
+	// the mapping is copy-on-write: sorting changes this process's copy, not the files
+	assert.Nil(t, mgd.Sort("x1", false))
+	assert.Equal(t, 10.0, mgd.Get("x1").Data.([]float64)[0])
+
+	mgd2, e := OpenMmap(fileRoot)
+	assert.Nil(t, e)
+	assert.Equal(t, gd.Get("x1").Data, mgd2.Get("x1").Data)
+	assert.Nil(t, mgd2.Close())
+
+	assert.Nil(t, mgd.Close())
+	assert.Nil(t, mgd.Close())
+
+	// after Close there is no data to read
+	assert.Equal(t, 0, mgd.Rows())
+	assert.Nil(t, mgd.Get("x1"))
+	_, e = mgd.GetRaw("x2")
+	assert.NotNil(t, e)
+
+	// a field with no elements may start at the end of the file
+	mem := make([]byte, 8)
+	assert.Equal(t, []int32{}, mmapSlice(mem, mmapField{Kind: "int32", Offset: len(mem)}))
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package seafan
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps size bytes of f copy-on-write: pages are shared until they are written, and writes are private to
+// the process and never reach f
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+}
+
+// munmap unmaps mem
+func munmap(mem []byte) error {
+	return syscall.Munmap(mem)
+}
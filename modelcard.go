@@ -0,0 +1,241 @@
+package seafan
+
+// modelcard.go records how a model was built, for audit and reproducibility reviews
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// ModelCard describes how a model was fit.  With WithModelCard, Fit saves it as <fileRoot>M.json alongside the
+// <fileRoot>S.nn and <fileRoot>P.nn files of the model.  Costs that are not available (e.g. the validation cost
+// without a validation Pipeline) are NaN.
+type ModelCard struct {
+	Name      string            // name of the model (see WithName)
+	Created   time.Time         // time the card was made
+	Version   string            // version of seafan
+	GoVersion string            // version of Go
+	ModSpec   ModSpec           // model specification
+	Target    string            // name of the target field
+	CostFn    string            // name of the cost function
+	Epochs    int               // epochs requested
+	EpochsRun int               // epochs run, fewer than Epochs if the fit stopped early
+	BestEpoch int               // epoch of the saved model
+	InCost    float64           // in-sample cost at the best epoch
+	ValCost   float64           // validation cost at the best epoch
+	InRows    int               // rows of the model Pipeline
+	ValRows   int               // rows of the validation Pipeline
+	Seed      *int64            // seed of the fit (see WithFitSeed), nil if not set
+	FTypes    FTypes            // FTypes of the model Pipeline
+	Notes     map[string]string // user notes
+}
+
+// modelCardFile is the format of the ModelCard save file
+type modelCardFile struct {
+	Name      string            `json:"name"`
+	Created   time.Time         `json:"created"`
+	Version   string            `json:"version"`
+	GoVersion string            `json:"goVersion"`
+	ModSpec   ModSpec           `json:"modSpec"`
+	Target    string            `json:"target"`
+	CostFn    string            `json:"costFn"`
+	Epochs    int               `json:"epochs"`
+	EpochsRun int               `json:"epochsRun"`
+	BestEpoch int               `json:"bestEpoch"`
+	InCost    *float64          `json:"inCost"` // json has no NaN, so NaN is null
+	ValCost   *float64          `json:"valCost"`
+	InRows    int               `json:"inRows"`
+	ValRows   int               `json:"valRows"`
+	Seed      *int64            `json:"seed,omitempty"`
+	FTypes    *ftFile           `json:"fTypes"`
+	Notes     map[string]string `json:"notes,omitempty"`
+}
+
+// WithModelCard makes Fit save a ModelCard with the model, as <fileRoot>M.json.  notes are added to the card.
+func WithModelCard(notes map[string]string) FitOpts {
+	f := func(ft *Fit) {
+		ft.card = true
+		ft.cardNotes = notes
+	}
+
+	return f
+}
+
+// ModelCard returns the ModelCard of the fit.  It's complete once Do has run.
+func (ft *Fit) ModelCard() *ModelCard {
+	mc := &ModelCard{
+		Name:      ft.nn.Name(),
+		Created:   time.Now(),
+		Version:   seafanVersion(),
+		GoVersion: runtime.Version(),
+		ModSpec:   ft.nn.ModSpec(),
+		Target:    ft.nn.ModSpec().TargetName(),
+		CostFn:    funcName(ft.costFn),
+		Epochs:    ft.epochs,
+		BestEpoch: ft.bestEpoch,
+		InCost:    math.NaN(),
+		ValCost:   math.NaN(),
+		InRows:    ft.modelPipe.Rows(),
+		Seed:      ft.seed,
+		FTypes:    ft.modelPipe.GetFTypes(),
+		Notes:     ft.cardNotes,
+	}
+
+	if ft.valPipe != nil {
+		mc.ValRows = ft.valPipe.Rows()
+	}
+
+	if h := ft.history; h != nil {
+		mc.EpochsRun = h.Len()
+
+		for ind, ep := range h.Epoch {
+			if ep == ft.bestEpoch {
+				mc.InCost, mc.ValCost = h.InCost[ind], h.ValCost[ind]
+			}
+		}
+	}
+
+	return mc
+}
+
+// Save saves the ModelCard as <fileRoot>M.json
+func (mc *ModelCard) Save(fileRoot string) error {
+	fts, e := mc.FTypes.toFile()
+	if e != nil {
+		return Wrapper(e, "(*ModelCard) Save")
+	}
+
+	out := &modelCardFile{
+		Name:      mc.Name,
+		Created:   mc.Created,
+		Version:   mc.Version,
+		GoVersion: mc.GoVersion,
+		ModSpec:   mc.ModSpec,
+		Target:    mc.Target,
+		CostFn:    mc.CostFn,
+		Epochs:    mc.Epochs,
+		EpochsRun: mc.EpochsRun,
+		BestEpoch: mc.BestEpoch,
+		InCost:    nanToNil(mc.InCost),
+		ValCost:   nanToNil(mc.ValCost),
+		InRows:    mc.InRows,
+		ValRows:   mc.ValRows,
+		Seed:      mc.Seed,
+		FTypes:    fts,
+		Notes:     mc.Notes,
+	}
+
+	js, e := json.MarshalIndent(out, "", "  ")
+	if e != nil {
+		return Wrapper(e, "(*ModelCard) Save")
+	}
+
+	return os.WriteFile(fileRoot+"M.json", js, 0o644)
+}
+
+// LoadModelCard loads the ModelCard saved as <fileRoot>M.json
+func LoadModelCard(fileRoot string) (*ModelCard, error) {
+	js, e := os.ReadFile(fileRoot + "M.json")
+	if e != nil {
+		return nil, e
+	}
+
+	var in modelCardFile
+	if e := json.Unmarshal(js, &in); e != nil {
+		return nil, Wrapper(e, "LoadModelCard")
+	}
+
+	mc := &ModelCard{
+		Name:      in.Name,
+		Created:   in.Created,
+		Version:   in.Version,
+		GoVersion: in.GoVersion,
+		ModSpec:   in.ModSpec,
+		Target:    in.Target,
+		CostFn:    in.CostFn,
+		Epochs:    in.Epochs,
+		EpochsRun: in.EpochsRun,
+		BestEpoch: in.BestEpoch,
+		InCost:    nilToNaN(in.InCost),
+		ValCost:   nilToNaN(in.ValCost),
+		InRows:    in.InRows,
+		ValRows:   in.ValRows,
+		Seed:      in.Seed,
+		Notes:     in.Notes,
+	}
+
+	if in.FTypes != nil {
+		if mc.FTypes, e = in.FTypes.fTypes(false); e != nil {
+			return nil, Wrapper(e, "LoadModelCard")
+		}
+	}
+
+	return mc, nil
+}
+
+// seafanVersion returns the version of the seafan module in the build, "(devel)" if it's the main module and ""
+// if it's not known
+func seafanVersion() string {
+	const path = "github.com/invertedv/seafan"
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	if bi.Main.Path == path {
+		return bi.Main.Version
+	}
+
+	for _, dep := range bi.Deps {
+		if dep.Path == path {
+			return dep.Version
+		}
+	}
+
+	return ""
+}
+
+// funcName returns the name of the function f without its package path, "" if f is nil
+func funcName(f any) string {
+	v := reflect.ValueOf(f)
+	if !v.IsValid() || v.IsNil() {
+		return ""
+	}
+
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+
+	name := fn.Name()
+	if ind := strings.LastIndex(name, "/"); ind >= 0 {
+		name = name[ind+1:]
+	}
+
+	return strings.TrimPrefix(name, "seafan.")
+}
+
+// nanToNil returns nil if x is NaN, otherwise a pointer to x
+func nanToNil(x float64) *float64 {
+	if math.IsNaN(x) {
+		return nil
+	}
+
+	return &x
+}
+
+// nilToNaN returns NaN if x is nil, otherwise *x
+func nilToNaN(x *float64) float64 {
+	if x == nil {
+		return math.NaN()
+	}
+
+	return *x
+}
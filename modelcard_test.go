@@ -0,0 +1,46 @@
+package seafan
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadModelCard(t *testing.T) {
+	Verbose = false
+
+	const n = 200
+
+	gd := synthData(t, 31, n, []string{"x"}, []string{"y"}, func(x []float64, rnd *rand.Rand) []any {
+		return []any{2*x[0] + 0.1*rnd.NormFloat64()}
+	})
+	pipe := NewVecData("card", gd, WithBatchSize(50))
+
+	mod := ModSpec{"Input(x)", "FC(size:1)", "Target(y)"}
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS), WithName("card test"))
+	assert.Nil(t, e)
+
+	ft := fitModel(t, nn, 5, pipe, WithFitSeed(31), WithModelCard(map[string]string{"owner": "risk"}))
+
+	mc, e := LoadModelCard(ft.OutFile())
+	assert.Nil(t, e)
+
+	assert.Equal(t, "card test", mc.Name)
+	assert.Equal(t, mod, mc.ModSpec)
+	assert.Equal(t, "y", mc.Target)
+	assert.Equal(t, "RMS", mc.CostFn)
+	assert.Equal(t, 5, mc.Epochs)
+	assert.Equal(t, 5, mc.EpochsRun)
+	assert.Equal(t, ft.BestEpoch(), mc.BestEpoch)
+	assert.Equal(t, ft.History().InCost[ft.BestEpoch()-1], mc.InCost)
+	assert.True(t, math.IsNaN(mc.ValCost))
+	assert.Equal(t, n, mc.InRows)
+	assert.Equal(t, int64(31), *mc.Seed)
+	assert.Equal(t, map[string]string{"owner": "risk"}, mc.Notes)
+	assert.Equal(t, pipe.GetFTypes().names(), mc.FTypes.names())
+
+	_, e = LoadModelCard(ft.OutFile() + "x")
+	assert.NotNil(t, e)
+}
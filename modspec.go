@@ -20,6 +20,16 @@ const (
 	FC
 	DropOut
 	Target
+	Output
+	Offset
+	GRU
+	LSTM
+	Conv1D
+	MaxPool
+	AvgPool
+	Attention
+	InputDropout
+	GaussianNoise
 )
 
 //go:generate stringer -type=Layer
@@ -52,6 +62,61 @@ type DOLayer struct {
 	DropProb float64 // dropout probability
 }
 
+// NoiseLayer specifies a GaussianNoise(sigma) layer.  Like InputDropout(p), it regularizes the model by perturbing the
+// concatenated inputs to the FC layers: it adds normal noise with standard deviation Sigma to each input.  Both are
+// applied in build mode only and must come before the FC layers.
+type NoiseLayer struct {
+	Sigma float64 // standard deviation of the noise
+}
+
+// RNNLayer specifies a recurrent layer: GRU(size) or LSTM(size).  It is a sequence layer (see SeqLayers).
+type RNNLayer struct {
+	Cell Layer // GRU or LSTM
+	Size int   // dimension of the hidden state
+}
+
+// ConvLayer specifies a 1-D convolution across the steps of a sequence: Conv1D(filters, kernel, activation).  The
+// arguments may also be given by key: Conv1D(filters:8, kernel:3, activation:relu).  The activation defaults to
+// linear.  There is no padding, so the output has kernel-1 fewer steps than the input.  It is a sequence layer (see
+// SeqLayers).
+type ConvLayer struct {
+	Filters int // number of output channels
+	Kernel  int // number of steps covered by each filter
+	Act     Activation
+	ActParm float64
+}
+
+// PoolLayer specifies pooling over non-overlapping windows of Size steps: MaxPool(size) or AvgPool(size). Steps
+// beyond the last full window are dropped.  It is a sequence layer (see SeqLayers).
+type PoolLayer struct {
+	Pool Layer // MaxPool or AvgPool
+	Size int
+}
+
+// AttnLayer specifies a self-attention block over the steps of a sequence: Attention(heads, dim).  The arguments may
+// also be given by key: Attention(heads:2, dim:4).  The steps are those of the FRSeq inputs or, if there are none, the
+// embedded inputs, e.g. Input(E(cat1,4)+E(cat2,4)+x).  Each head projects the steps to queries, keys and values of
+// dimension dim.  The heads are concatenated, projected back to the channels of the input and added to the input.
+// It is a sequence layer (see SeqLayers).
+type AttnLayer struct {
+	Heads int // number of attention heads
+	Dim   int // dimension of the queries, keys and values of each head
+}
+
+// OutputLayer specifies an output head of a multi-output model.  The head is a fully connected layer fed by the
+// last hidden layer.  Its arguments are those of FC plus:
+//   - target: the target field of the head (required).  The "target:" may be omitted if it is the first argument.
+//   - weight: the weight of the head's cost in the model cost (default 1).  See MultiCost.
+//   - quantiles: a list of quantiles to fit, e.g. quantiles:[0.1,0.5,0.9].  See Pinball.
+//
+// For example: Output(target:default, size:1, activation:sigmoid, weight:2) or Output(ycts, quantiles:[0.1,0.5,0.9])
+type OutputLayer struct {
+	FCLayer
+	Target    string
+	Weight    float64
+	Quantiles []float64 // Quantiles are increasing and in (0, 1).  The head has a column for each.
+}
+
 // ModSpec holds layers--each slice element is a layer
 type ModSpec []string
 
@@ -217,6 +282,305 @@ func DropOutParse(s string) (*DOLayer, error) {
 	return do, nil
 }
 
+// NoiseParse parses the arguments to a GaussianNoise layer
+func NoiseParse(s string) (*NoiseLayer, error) {
+	_, args, err := Strip(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sigma, err := strconv.ParseFloat(args, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	if sigma <= 0.0 {
+		return nil, Wrapper(ErrModSpec, "GaussianNoise: bad standard deviation <=0")
+	}
+
+	return &NoiseLayer{Sigma: sigma}, nil
+}
+
+// RNNParse parses a recurrent layer.  The size may be given with or without its key: GRU(8) or GRU(size:8).
+func RNNParse(s string) (*RNNLayer, error) {
+	l, args, err := Strip(s)
+	if err != nil {
+		return nil, err
+	}
+
+	rnn := &RNNLayer{Cell: GRU}
+	switch strings.ToLower(l) {
+	case "gru":
+	case "lstm":
+		rnn.Cell = LSTM
+	default:
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("unknown recurrent layer: %s", l))
+	}
+
+	args = strings.ReplaceAll(strings.ToLower(args), " ", "")
+	args = strings.TrimPrefix(args, "size:")
+
+	size, err := strconv.ParseInt(args, 10, 32)
+	if err != nil || size < 1 {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("%s: illegal size", rnn.Cell))
+	}
+
+	rnn.Size = int(size)
+
+	return rnn, nil
+}
+
+// ConvParse parses a Conv1D layer
+func ConvParse(s string) (*ConvLayer, error) {
+	l, args, err := Strip(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(l) != "conv1d" {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("not a Conv1D layer: %s", l))
+	}
+
+	conv := &ConvLayer{Act: Linear}
+	keys := []string{"filters", "kernel", "activation"}
+	kval := make(Args)
+
+	// arguments without keys are in the order filters, kernel, activation
+	for ind, arg := range strings.Split(strings.ToLower(args), ",") {
+		k, v, found := strings.Cut(arg, ":")
+		if !found {
+			if ind >= len(keys) {
+				return nil, Wrapper(ErrModSpec, fmt.Sprintf("Conv1D: too many arguments: %s", args))
+			}
+
+			k, v = keys[ind], arg
+		}
+
+		kval[k] = v
+	}
+
+	if val := kval.Get("filters", reflect.Int); val != nil {
+		conv.Filters = val.(int)
+	}
+
+	if val := kval.Get("kernel", reflect.Int); val != nil {
+		conv.Kernel = val.(int)
+	}
+
+	if conv.Filters < 1 || conv.Kernel < 1 {
+		return nil, Wrapper(ErrModSpec, "Conv1D: filters and kernel must be positive")
+	}
+
+	if val := kval.Get("activation", reflect.String); val != nil {
+		a, p := StrAct(val.(string))
+		if a == nil || *a == SoftMax {
+			return nil, Wrapper(ErrModSpec, fmt.Sprintf("Conv1D: illegal activation %s", val))
+		}
+
+		conv.Act, conv.ActParm = *a, p
+	}
+
+	return conv, nil
+}
+
+// PoolParse parses a MaxPool or AvgPool layer.  The size may be given with or without its key: MaxPool(2) or
+// MaxPool(size:2).
+func PoolParse(s string) (*PoolLayer, error) {
+	l, args, err := Strip(s)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &PoolLayer{Pool: MaxPool}
+	switch strings.ToLower(l) {
+	case "maxpool":
+	case "avgpool":
+		pool.Pool = AvgPool
+	default:
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("unknown pooling layer: %s", l))
+	}
+
+	size, err := strconv.ParseInt(strings.TrimPrefix(strings.ToLower(args), "size:"), 10, 32)
+	if err != nil || size < 1 {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("%s: illegal size", pool.Pool))
+	}
+
+	pool.Size = int(size)
+
+	return pool, nil
+}
+
+// AttnParse parses an Attention layer
+func AttnParse(s string) (*AttnLayer, error) {
+	l, args, err := Strip(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(l) != "attention" {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("not an Attention layer: %s", l))
+	}
+
+	keys := []string{"heads", "dim"}
+	kval := make(Args)
+
+	// arguments without keys are in the order heads, dim
+	for ind, arg := range strings.Split(strings.ToLower(args), ",") {
+		k, v, found := strings.Cut(arg, ":")
+		if !found {
+			if ind >= len(keys) {
+				return nil, Wrapper(ErrModSpec, fmt.Sprintf("Attention: too many arguments: %s", args))
+			}
+
+			k, v = keys[ind], arg
+		}
+
+		kval[k] = v
+	}
+
+	attn := &AttnLayer{}
+	if val := kval.Get("heads", reflect.Int); val != nil {
+		attn.Heads = val.(int)
+	}
+
+	if val := kval.Get("dim", reflect.Int); val != nil {
+		attn.Dim = val.(int)
+	}
+
+	if attn.Heads < 1 || attn.Dim < 1 {
+		return nil, Wrapper(ErrModSpec, "Attention: heads and dim must be positive")
+	}
+
+	return attn, nil
+}
+
+// OutputParse parses the arguments to an Output layer.  The size defaults to 1.
+func OutputParse(s string) (*OutputLayer, error) {
+	_, args, err := Strip(s)
+	if err != nil {
+		return nil, err
+	}
+
+	args, qStr, err := cutList(args, "quantiles")
+	if err != nil {
+		return nil, err
+	}
+
+	// the target may be given without its key
+	target := ""
+	if first, rest, _ := strings.Cut(args, ","); first != "" && !strings.Contains(first, ":") {
+		target, args = first, rest
+	}
+
+	kv, err := MakeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// the keys are case-insensitive, the target field is not
+	kval := make(Args)
+	for k, v := range kv {
+		kval[strings.ToLower(k)] = v
+	}
+
+	out := &OutputLayer{FCLayer: FCLayer{Size: 1, Bias: true, Act: Linear}, Weight: 1.0}
+
+	out.Target = target
+	if val := kval.Get("target", reflect.String); val != nil {
+		out.Target = val.(string)
+	}
+
+	if out.Target == "" {
+		return nil, Wrapper(ErrModSpec, "Output: no target")
+	}
+
+	if val := kval.Get("size", reflect.Int); val != nil {
+		if out.Size = val.(int); out.Size < 1 {
+			return nil, Wrapper(ErrModSpec, "Output: illegal size")
+		}
+	}
+
+	if val := kval.Get("activation", reflect.String); val != nil {
+		a, p := StrAct(val.(string))
+		if a == nil {
+			return nil, Wrapper(ErrModSpec, fmt.Sprintf("Output: unknown activation %s", val))
+		}
+
+		out.Act, out.ActParm = *a, p
+	}
+
+	if val := kval.Get("bias", reflect.Bool); val != nil {
+		out.Bias = val.(bool)
+	}
+
+	if _, ok := kval["weight"]; ok {
+		val := kval.Get("weight", reflect.Float64)
+		if val == nil || val.(float64) < 0.0 {
+			return nil, Wrapper(ErrModSpec, "Output: illegal weight")
+		}
+
+		out.Weight = val.(float64)
+	}
+
+	if qStr != "" {
+		if out.Quantiles, err = parseQuantiles(qStr); err != nil {
+			return nil, err
+		}
+
+		if _, ok := kval["size"]; ok && out.Size != len(out.Quantiles) {
+			return nil, Wrapper(ErrModSpec, "Output: size must equal the number of quantiles")
+		}
+
+		if out.Act != Linear {
+			return nil, Wrapper(ErrModSpec, "Output: quantile heads must have linear activation")
+		}
+
+		out.Size = len(out.Quantiles)
+	}
+
+	return out, nil
+}
+
+// cutList removes the argument key:[list] from args.  It returns the remaining arguments and the list.
+func cutList(args, key string) (rest, list string, err error) {
+	start := strings.Index(strings.ToLower(args), strings.ToLower(key)+":[")
+	if start < 0 {
+		return args, "", nil
+	}
+
+	end := strings.Index(args[start:], "]")
+	if end < 0 {
+		return "", "", Wrapper(ErrModSpec, fmt.Sprintf("missing ] in %s", key))
+	}
+
+	end += start
+	list = args[start+len(key)+2 : end]
+	rest = strings.Trim(args[:start]+args[end+1:], ",")
+	rest = strings.ReplaceAll(rest, ",,", ",")
+
+	return rest, list, nil
+}
+
+// parseQuantiles parses a comma-separated list of increasing quantiles
+func parseQuantiles(s string) ([]float64, error) {
+	qs := make([]float64, 0)
+
+	for _, qStr := range strings.Split(s, ",") {
+		q, e := strconv.ParseFloat(qStr, 64)
+		if e != nil || q <= 0.0 || q >= 1.0 {
+			return nil, Wrapper(ErrModSpec, fmt.Sprintf("Output: bad quantile %s", qStr))
+		}
+
+		if len(qs) > 0 && q <= qs[len(qs)-1] {
+			return nil, Wrapper(ErrModSpec, "Output: quantiles must be increasing")
+		}
+
+		qs = append(qs, q)
+	}
+
+	return qs, nil
+}
+
 // Check checks that the layer name is valid
 func (m ModSpec) Check() error {
 	for _, ms := range m {
@@ -282,6 +646,36 @@ func (m ModSpec) DropOut(loc int) *DOLayer {
 	return do
 }
 
+// InputDropout returns the *DOLayer for layer i, if it is of type InputDropout.  Returns nil o.w.
+func (m ModSpec) InputDropout(loc int) *DOLayer {
+	l, e := m.LType(loc)
+	if e != nil || *l != InputDropout {
+		return nil
+	}
+
+	do, err := DropOutParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return do
+}
+
+// GaussianNoise returns the *NoiseLayer for layer i, if it is of type GaussianNoise.  Returns nil o.w.
+func (m ModSpec) GaussianNoise(loc int) *NoiseLayer {
+	l, e := m.LType(loc)
+	if e != nil || *l != GaussianNoise {
+		return nil
+	}
+
+	gn, err := NoiseParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return gn
+}
+
 // FC returns the *FCLayer for layer i, if it is of type FC. Returns nil o.w.
 func (m ModSpec) FC(loc int) *FCLayer {
 	l, e := m.LType(loc)
@@ -302,8 +696,212 @@ func (m ModSpec) FC(loc int) *FCLayer {
 	return fc
 }
 
-// Inputs returns the FTypes of the input features
+// RNN returns the *RNNLayer for layer i, if it is of type GRU or LSTM. Returns nil o.w.
+func (m ModSpec) RNN(loc int) *RNNLayer {
+	l, e := m.LType(loc)
+	if e != nil {
+		return nil
+	}
+
+	if *l != GRU && *l != LSTM {
+		return nil
+	}
+
+	rnn, err := RNNParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return rnn
+}
+
+// Conv returns the *ConvLayer for layer i, if it is of type Conv1D. Returns nil o.w.
+func (m ModSpec) Conv(loc int) *ConvLayer {
+	l, e := m.LType(loc)
+	if e != nil || *l != Conv1D {
+		return nil
+	}
+
+	conv, err := ConvParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return conv
+}
+
+// Pool returns the *PoolLayer for layer i, if it is of type MaxPool or AvgPool. Returns nil o.w.
+func (m ModSpec) Pool(loc int) *PoolLayer {
+	l, e := m.LType(loc)
+	if e != nil || (*l != MaxPool && *l != AvgPool) {
+		return nil
+	}
+
+	pool, err := PoolParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return pool
+}
+
+// Attn returns the *AttnLayer for layer i, if it is of type Attention. Returns nil o.w.
+func (m ModSpec) Attn(loc int) *AttnLayer {
+	l, e := m.LType(loc)
+	if e != nil || *l != Attention {
+		return nil
+	}
+
+	attn, err := AttnParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return attn
+}
+
+// isSeqLayer returns true if l is a sequence layer
+func isSeqLayer(l Layer) bool {
+	return l == GRU || l == LSTM || l == Conv1D || l == MaxPool || l == AvgPool || l == Attention
+}
+
+// SeqLayers returns the number of sequence layers: GRU, LSTM, Conv1D, MaxPool, AvgPool and Attention.  They must directly follow
+// the Input layer, so they are layers 1 to SeqLayers.  The first takes the FRSeq inputs (see MakeSequence) and each
+// subsequent one takes the sequence output of the layer before it.  If there are no FRSeq inputs, the first takes the
+// embedded inputs, each embedding a step.  These must have the same number of columns and cannot be grouped.  The output of the last sequence layer is its
+// final hidden state if it is recurrent and the concatenation of its steps otherwise.  This output is concatenated
+// with the other inputs to feed the FC layers.
+func (m ModSpec) SeqLayers() (int, error) {
+	n := 0
+
+	for ind := 1; ind < len(m); ind++ {
+		l, e := m.LType(ind)
+		if e != nil {
+			return 0, e
+		}
+
+		if !isSeqLayer(*l) {
+			continue
+		}
+
+		if n != ind-1 {
+			return 0, Wrapper(ErrModSpec, fmt.Sprintf("sequence layer %d must follow the Input layer", ind))
+		}
+
+		var err error
+
+		switch *l {
+		case GRU, LSTM:
+			_, err = RNNParse(m[ind])
+		case Conv1D:
+			_, err = ConvParse(m[ind])
+		case Attention:
+			_, err = AttnParse(m[ind])
+		default:
+			_, err = PoolParse(m[ind])
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// Output returns the *OutputLayer for layer i, if it is of type Output. Returns nil o.w.
+func (m ModSpec) Output(loc int) *OutputLayer {
+	l, e := m.LType(loc)
+	if e != nil {
+		return nil
+	}
+
+	if *l != Output {
+		return nil
+	}
+
+	out, err := OutputParse(m[loc])
+	if err != nil {
+		return nil
+	}
+
+	return out
+}
+
+// Outputs returns the Output layers of a multi-output model in order. It returns nil if there are none.
+// The Output layers must be the last layers of the ModSpec, there must be no Target layer and each head must have
+// a different target.
+func (m ModSpec) Outputs() ([]*OutputLayer, error) {
+	var outs []*OutputLayer
+
+	hasTarget := false
+	targets := make(map[string]bool)
+
+	for ind := 0; ind < len(m); ind++ {
+		l, e := m.LType(ind)
+		if e != nil {
+			return nil, e
+		}
+
+		if *l == Target {
+			hasTarget = true
+		}
+
+		if *l == Offset {
+			continue
+		}
+
+		if *l != Output {
+			if outs != nil {
+				return nil, Wrapper(ErrModSpec, fmt.Sprintf("layer %d follows an Output layer", ind))
+			}
+
+			continue
+		}
+
+		out, e := OutputParse(m[ind])
+		if e != nil {
+			return nil, e
+		}
+
+		if targets[out.Target] {
+			return nil, Wrapper(ErrModSpec, fmt.Sprintf("target %s is in more than one Output layer", out.Target))
+		}
+
+		targets[out.Target] = true
+		outs = append(outs, out)
+	}
+
+	if outs != nil && hasTarget {
+		return nil, Wrapper(ErrModSpec, "a model cannot have both Output and Target layers")
+	}
+
+	return outs, nil
+}
+
+// Inputs returns the FTypes of the input features. Inputs that are terms (e.g. x1*x2) and are not in p are
+// added to p (see AddTerm), so Inputs changes p: the model's batches are drawn from p, which must hold every input.
+// To leave a Pipeline unchanged, call Inputs (or NewNNModel) on a Pipeline of a copy of its GData (see GData Copy).
 func (m ModSpec) Inputs(p Pipeline) (FTypes, error) {
+	getFT := func(field string) *FType {
+		if ft := p.GetFType(field); ft != nil {
+			return ft
+		}
+
+		if _, ok := parseTerm(field); ok && AddTerm(p, field) == nil {
+			return p.GetFType(field)
+		}
+
+		return nil
+	}
+
+	return m.inputs(getFT)
+}
+
+// inputs returns the FTypes of the input features. getFT returns the FType of a field.
+func (m ModSpec) inputs(getFT func(field string) *FType) (FTypes, error) {
 	var err error
 
 	modSpec := make([]*FType, 0)
@@ -317,57 +915,39 @@ func (m ModSpec) Inputs(p Pipeline) (FTypes, error) {
 		return nil, Wrapper(ErrModSpec, "first layer is not Input")
 	}
 
-	_, inStr, e := Strip(m[0])
-
+	fs, _, e := inputGroups(m[0])
 	if e != nil {
 		return nil, e
 	}
 
 	var feat *FType
 
-	fs := strings.Split(inStr, "+")
-
 	for _, f := range fs {
 		ft := f
 		embCols := 0
 
-		if strings.Contains(f, "E(") || strings.Contains(f, "e(") {
-			l := strings.Split(ft, ",")
-			if len(l) != 2 {
-				return nil, Wrapper(ErrModSpec, "Inputs: parse error")
-			}
-
-			ft = l[0][2:]
-
-			var em int64
-			em, err = strconv.ParseInt(l[1][0:len(l[1])-1], 10, 32)
-
-			if err != nil {
+		if isEmbed(f) {
+			if ft, embCols, _, err = parseEmbed(f); err != nil {
 				return nil, err
 			}
-
-			if em <= 1 {
-				return nil, Wrapper(ErrModSpec, "embedding columns must be at least 2")
-			}
-
-			embCols = int(em)
 		}
 
-		feat = p.GetFType(ft)
+		feat = getFT(ft)
 
 		if feat == nil {
 			return nil, Wrapper(ErrModSpec, fmt.Sprintf("Inputs: feature %s not found", f))
 		}
 
-		if feat.Role == FRCat {
-			return nil, Wrapper(ErrModSpec, fmt.Sprintf("feature %s is categorical--must convert to one-hot", feat.Name))
+		if feat.Role == FRCat && embCols == 0 {
+			return nil, Wrapper(ErrModSpec, fmt.Sprintf("feature %s is categorical--must convert to one-hot or embed", feat.Name))
 		}
 
 		feat.EmbCols = embCols
 
-		if embCols > 0 {
+		// an embedded FRCat feature stays FRCat: the model uses its codes
+		if embCols > 0 && feat.Role != FRCat {
 			if feat.Role != FROneHot && feat.Role != FREmbed {
-				return nil, Wrapper(ErrModSpec, fmt.Sprintf("feature %s can't be continuous/categorical", ft))
+				return nil, Wrapper(ErrModSpec, fmt.Sprintf("feature %s can't be continuous", ft))
 			}
 
 			feat.Role = FREmbed
@@ -379,7 +959,144 @@ func (m ModSpec) Inputs(p Pipeline) (FTypes, error) {
 	return modSpec, nil
 }
 
+// InputGroups returns the interaction group of each input of a model with grouped inputs, e.g.
+// Input(g1:(x1+x2), g2:(x3+x4oh)).  The hidden FC layers of such a model are block-diagonal: each group feeds its own
+// block of the columns of the layer, so the model has no interactions between inputs in different groups.
+// InputGroups returns nil if the inputs are not grouped.
+func (m ModSpec) InputGroups() ([]string, error) {
+	if len(m) == 0 {
+		return nil, Wrapper(ErrModSpec, "empty ModSpec")
+	}
+
+	_, groups, e := inputGroups(m[0])
+
+	return groups, e
+}
+
+// inputGroups returns the features of the Input layer and, if the inputs are grouped, the group of each.  groups is
+// nil if the inputs are not grouped.
+func inputGroups(layer string) (feats, groups []string, err error) {
+	if _, _, err = Strip(layer); err != nil {
+		return nil, nil, err
+	}
+
+	_, args, bad := specArgs(layer, ',')
+	if bad != nil {
+		return nil, nil, Wrapper(ErrModSpec, fmt.Sprintf("Inputs: %s", bad.Msg))
+	}
+
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+		text := strings.ReplaceAll(strings.ReplaceAll(arg.text, " ", ""), "\n", "")
+		group, inner, ok := parseGroup(text)
+
+		if !ok {
+			if len(args) > 1 {
+				return nil, nil, Wrapper(ErrModSpec, fmt.Sprintf("Inputs: bad input group %s", arg.text))
+			}
+
+			return strings.Split(text, "+"), nil, nil
+		}
+
+		if seen[group] {
+			return nil, nil, Wrapper(ErrModSpec, fmt.Sprintf("Inputs: input group %s is repeated", group))
+		}
+
+		seen[group] = true
+
+		for _, f := range strings.Split(inner, "+") {
+			feats = append(feats, f)
+			groups = append(groups, group)
+		}
+	}
+
+	return feats, groups, nil
+}
+
+// parseGroup parses the input group name:(features)
+func parseGroup(text string) (group, inner string, ok bool) {
+	loc := strings.Index(text, ":(")
+	if loc <= 0 || strings.ContainsAny(text[:loc], "(),") || !strings.HasSuffix(text, ")") {
+		return "", "", false
+	}
+
+	return text[:loc], text[loc+2 : len(text)-1], true
+}
+
+// isEmbed returns true if the input f is an embedding, E(field, cols)
+func isEmbed(f string) bool {
+	return strings.Contains(f, "E(") || strings.Contains(f, "e(")
+}
+
+// parseEmbed parses the embedding input E(field, cols) or E(field, cols, share:group)
+func parseEmbed(f string) (field string, cols int, share string, err error) {
+	l := strings.Split(f, ",")
+	if len(l) != 2 && len(l) != 3 {
+		return "", 0, "", Wrapper(ErrModSpec, "Inputs: parse error")
+	}
+
+	// drop the closing )
+	l[len(l)-1] = l[len(l)-1][:len(l[len(l)-1])-1]
+	field = l[0][2:]
+
+	em, err := strconv.ParseInt(l[1], 10, 32)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	if em <= 1 {
+		return "", 0, "", Wrapper(ErrModSpec, "embedding columns must be at least 2")
+	}
+
+	if len(l) == 3 {
+		kv, e := MakeArgs(l[2])
+		if e != nil {
+			return "", 0, "", e
+		}
+
+		if share = kv["share"]; share == "" {
+			return "", 0, "", Wrapper(ErrModSpec, fmt.Sprintf("Inputs: bad embedding argument %s", l[2]))
+		}
+	}
+
+	return field, int(em), share, nil
+}
+
+// EmbedName returns the name of the embedding parameter node of the embedded input field.  Inputs with the same
+// share group, e.g. E(stateOrigOh, 5, share:geo) and E(stateCurrOh, 5, share:geo), share a single embedding named
+// <group>Embed.  Otherwise, the embedding is named <field>Embed.
+func (m ModSpec) EmbedName(field string) string {
+	if len(m) > 0 {
+		if fs, _, e := inputGroups(m[0]); e == nil {
+			for _, f := range fs {
+				if !isEmbed(f) {
+					continue
+				}
+
+				if fld, _, share, e := parseEmbed(f); e == nil && fld == field && share != "" {
+					return share + "Embed"
+				}
+			}
+		}
+	}
+
+	return field + "Embed"
+}
+
+// TargetName returns the name of the target field
 func (m ModSpec) TargetName() string {
+	return m.targetArg(0)
+}
+
+// ExposureName returns the name of the exposure field of a Target with two arguments, e.g. Target(event, exposure).
+// It returns "" if there is no exposure field.  See Hazard.
+func (m ModSpec) ExposureName() string {
+	return m.targetArg(1)
+}
+
+// targetArg returns argument ind of the Target layer
+func (m ModSpec) targetArg(ind int) string {
 	l, e := m.LType(len(m) - 1)
 	if e != nil {
 		return ""
@@ -395,7 +1112,71 @@ func (m ModSpec) TargetName() string {
 		return ""
 	}
 
-	return arg
+	args := strings.Split(arg, ",")
+	if ind >= len(args) {
+		return ""
+	}
+
+	return strings.TrimSpace(args[ind])
+}
+
+// Exposure returns the *FType of the exposure field.  It returns nil if there is no exposure field.
+func (m ModSpec) Exposure(p Pipeline) (*FType, error) {
+	name := m.ExposureName()
+	if name == "" {
+		return nil, nil
+	}
+
+	feat := p.GetFType(name)
+	if feat == nil {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("exposure %s not found", name))
+	}
+
+	if feat.Role != FRCts || feat.Normalized {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("exposure %s must be FRCts and not normalized", name))
+	}
+
+	return feat, nil
+}
+
+// OffsetName returns the name of the field of the Offset layer, e.g. Offset(logExposure). It returns "" if there is
+// no Offset layer.
+func (m ModSpec) OffsetName() string {
+	for ind := 0; ind < len(m); ind++ {
+		l, e := m.LType(ind)
+		if e != nil || *l != Offset {
+			continue
+		}
+
+		_, arg, e := Strip(m[ind])
+		if e != nil {
+			return ""
+		}
+
+		return arg
+	}
+
+	return ""
+}
+
+// Offset returns the *FType of the offset field. It returns nil if there is no Offset layer.  The offset is added to
+// the linear predictor of the last layer, so it is typically the log of the exposure.
+func (m ModSpec) Offset(p Pipeline) (*FType, error) {
+	name := m.OffsetName()
+	if name == "" {
+		return nil, nil
+	}
+
+	feat := p.GetFType(name)
+	if feat == nil {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("offset %s not found", name))
+	}
+
+	if feat.Role != FRCts || feat.Normalized {
+		return nil, Wrapper(ErrModSpec, fmt.Sprintf("offset %s must be FRCts and not normalized", name))
+	}
+
+	return feat, nil
 }
 
 // Target returns the *FType of the target
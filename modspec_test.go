@@ -36,6 +36,60 @@ func TestFCParse(t *testing.T) {
 	}
 }
 
+func TestOutputParse(t *testing.T) {
+	out, e := OutputParse("Output(target:prepayOh, size:3, activation:softmax, weight:0.5)")
+	assert.Nil(t, e)
+	assert.Equal(t, "prepayOh", out.Target)
+	assert.Equal(t, 3, out.Size)
+	assert.Equal(t, SoftMax, out.Act)
+	assert.Equal(t, 0.5, out.Weight)
+
+	out, e = OutputParse("Output(Target:dflt)")
+	assert.Nil(t, e)
+	assert.Equal(t, 1, out.Size)
+	assert.Equal(t, 1.0, out.Weight)
+
+	out, e = OutputParse("Output(ycts, quantiles:[0.1, 0.5, 0.9], weight:2)")
+	assert.Nil(t, e)
+	assert.Equal(t, "ycts", out.Target)
+	assert.Equal(t, []float64{0.1, 0.5, 0.9}, out.Quantiles)
+	assert.Equal(t, 3, out.Size)
+	assert.Equal(t, 2.0, out.Weight)
+
+	bad := []string{"Output(size:1)", "Output(target:y, weight:-1)", "Output(target:y, activation:junk)",
+		"Output(target:y, size:0)", "Output(y, quantiles:[0.5, 0.1])", "Output(y, quantiles:[0.5, 1])",
+		"Output(y, quantiles:[0.1, 0.5], size:3)", "Output(y, quantiles:[0.1, 0.5], activation:sigmoid)",
+		"Output(y, quantiles:[0.1, 0.5)"}
+	for _, b := range bad {
+		_, e = OutputParse(b)
+		assert.NotNil(t, e, b)
+	}
+}
+
+func TestModSpec_Outputs(t *testing.T) {
+	mod := ModSpec{"Input(x1+x2)", "FC(size:3)", "Output(target:y1)", "Output(target:y2, weight:2)"}
+	outs, e := mod.Outputs()
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(outs))
+	assert.Equal(t, "y2", outs[1].Target)
+	assert.Nil(t, mod.Output(1))
+	assert.NotNil(t, mod.Output(2))
+
+	outs, e = ModSpec{"Input(x1+x2)", "FC(size:1)", "Target(y)"}.Outputs()
+	assert.Nil(t, e)
+	assert.Nil(t, outs)
+
+	bad := []ModSpec{
+		{"Input(x1+x2)", "Output(target:y1)", "FC(size:3)"},
+		{"Input(x1+x2)", "Output(target:y1)", "Output(target:y1)"},
+		{"Input(x1+x2)", "FC(size:1)", "Target(y)", "Output(target:y1)"},
+	}
+	for _, m := range bad {
+		_, e = m.Outputs()
+		assert.NotNil(t, e)
+	}
+}
+
 func TestStrip(t *testing.T) {
 	inputs := []string{"ab(3)", "AB()", "r(as", "afdf)"}
 	expectL := []string{"ab", "AB", "", ""}
@@ -0,0 +1,182 @@
+package seafan
+
+// monotone.go checks that the response of a model to its inputs is monotone
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/invertedv/utilities"
+)
+
+// monoTol is the size of a change in the wrong direction that is ignored
+const monoTol = 1e-10
+
+// MonotoneResult is the result of checking a monotonicity constraint on a single field.
+type MonotoneResult struct {
+	Field        string  // Field is the input checked
+	Direction    int     // Direction is +1 for increasing, -1 for decreasing
+	Rows         int     // Rows is the number of rows checked
+	Violations   int     // Violations is the number of rows where the output moves in the wrong direction
+	Share        float64 // Share is the fraction of rows with a violation
+	MaxViolation float64 // MaxViolation is the largest step in the wrong direction
+}
+
+// MonotoneReport is the result of CheckMonotone
+type MonotoneReport []*MonotoneResult
+
+// String produces a table of the report
+func (mr MonotoneReport) String() string {
+	const pad = 3
+
+	table := [][]string{{"Field", "Direction", "Rows", "Violations", "Share", "Max Violation"}}
+
+	for _, r := range mr {
+		table = append(table, []string{r.Field, fmt.Sprintf("%+d", r.Direction), fmt.Sprintf("%d", r.Rows),
+			fmt.Sprintf("%d", r.Violations), fmt.Sprintf("%0.4f", r.Share), fmt.Sprintf("%0.6f", r.MaxViolation)})
+	}
+
+	return utilities.Pad(table, pad)
+}
+
+// OK returns true if no violations were found
+func (mr MonotoneReport) OK() bool {
+	for _, r := range mr {
+		if r.Violations > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckMonotone checks the constraints declared by WithMonotone.  See Scorer CheckMonotone.
+func (m *NNModel) CheckMonotone(pipe Pipeline, target []int, steps int) (MonotoneReport, error) {
+	if len(m.monotone) == 0 {
+		return nil, Wrapper(ErrNNModel, "CheckMonotone: no constraints declared")
+	}
+
+	sc, e := newScorerNN(m)
+	if e != nil {
+		return nil, Wrapper(e, "CheckMonotone")
+	}
+
+	return sc.CheckMonotone(pipe, target, m.monotone, steps)
+}
+
+// CheckMonotone checks that the model output is monotone in each field of constraints.  The keys of constraints are
+// FRCts inputs, the values are +1 (increasing) or -1 (decreasing).  The output is the sum of the target columns
+// (as in AddFitted).
+//
+// For each row of pipe, the field is moved across a grid of steps values from its minimum to its maximum in pipe
+// with the other inputs held fixed.  A row violates the constraint if the output moves in the wrong direction
+// between any two adjacent grid points.
+func (sc *Scorer) CheckMonotone(pipe Pipeline, target []int, constraints map[string]int, steps int) (MonotoneReport, error) {
+	if steps < 2 {
+		return nil, Wrapper(ErrNNModel, "CheckMonotone: steps must be at least 2")
+	}
+
+	for _, col := range target {
+		if col < 0 || col >= sc.outCols {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("CheckMonotone: target column %d out of range", col))
+		}
+	}
+
+	data, e := sc.inputData(pipe)
+	if e != nil {
+		return nil, Wrapper(e, "CheckMonotone")
+	}
+
+	// report in a consistent order
+	fields := make([]string, 0, len(constraints))
+	for field := range constraints {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	nRow := pipe.Rows()
+	report := make(MonotoneReport, 0)
+
+	for _, field := range fields {
+		dir := constraints[field]
+		if dir != 1 && dir != -1 {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("CheckMonotone: direction of %s must be +1 or -1", field))
+		}
+
+		fInd := -1
+
+		for ind, ft := range sc.inputFT {
+			if ft.Name == field {
+				fInd = ind
+			}
+		}
+
+		if fInd < 0 || sc.inputFT[fInd].Role != FRCts {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("CheckMonotone: %s is not an FRCts input", field))
+		}
+
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, x := range data[fInd] {
+			lo, hi = math.Min(lo, x), math.Max(hi, x)
+		}
+
+		// evaluate all rows at each grid point
+		orig := data[fInd]
+		grid := make([]float64, nRow)
+		data[fInd] = grid
+
+		res := &MonotoneResult{Field: field, Direction: dir, Rows: nRow}
+		violate := make([]bool, nRow)
+
+		var last []float64
+
+		for step := 0; step < steps; step++ {
+			val := lo + (hi-lo)*float64(step)/float64(steps-1)
+			for row := range grid {
+				grid[row] = val
+			}
+
+			out, e := sc.scoreData(nRow, data)
+			if e != nil {
+				data[fInd] = orig
+				return nil, Wrapper(e, "CheckMonotone")
+			}
+
+			score := make([]float64, nRow)
+			for row := 0; row < nRow; row++ {
+				for _, col := range target {
+					score[row] += out[row*sc.outCols+col]
+				}
+			}
+
+			if last != nil {
+				for row := 0; row < nRow; row++ {
+					if wrong := float64(dir) * (last[row] - score[row]); wrong > monoTol {
+						violate[row] = true
+						res.MaxViolation = math.Max(res.MaxViolation, wrong)
+					}
+				}
+			}
+
+			last = score
+		}
+
+		data[fInd] = orig
+
+		for _, v := range violate {
+			if v {
+				res.Violations++
+			}
+		}
+
+		if nRow > 0 {
+			res.Share = float64(res.Violations) / float64(nRow)
+		}
+
+		report = append(report, res)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,52 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNNModel_CheckMonotone(t *testing.T) {
+	Verbose = false
+	pipe := chPipe(100, "test1.csv")
+
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS), WithMonotone("x1", 1), WithMonotone("x2", -1))
+	assert.Nil(t, e)
+	assert.Equal(t, map[string]int{"x1": 1, "x2": -1}, nn.Monotone())
+
+	// the model is linear, so the sign of the weight determines the direction for every row
+	wts := nn.paramsW[0].Value().Data().([]float64)
+
+	report, e := nn.CheckMonotone(pipe, []int{0}, 5)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(report))
+	assert.Equal(t, "x1", report[0].Field)
+	assert.Equal(t, pipe.Rows(), report[0].Rows)
+
+	expect := func(w float64, dir int) float64 {
+		if w*float64(dir) < 0 {
+			return 1.0
+		}
+
+		return 0.0
+	}
+
+	assert.Equal(t, expect(wts[0], 1), report[0].Share)
+	assert.Equal(t, expect(wts[1], -1), report[1].Share)
+	assert.Equal(t, report[0].Share == 0 && report[1].Share == 0, report.OK())
+
+	sc, e := newScorerNN(nn)
+	assert.Nil(t, e)
+
+	_, e = sc.CheckMonotone(pipe, []int{0}, map[string]int{"x1": 2}, 5)
+	assert.NotNil(t, e)
+
+	_, e = sc.CheckMonotone(pipe, []int{0}, map[string]int{"ycts": 1}, 5)
+	assert.NotNil(t, e)
+}
@@ -17,28 +17,65 @@ import (
 	"gorgonia.org/tensor"
 )
 
+// adamLR is the default learning rate of the Adam solver
+const adamLR = 0.001
+
 // CostFunc function prototype for cost functions
 type CostFunc func(model *NNModel) *G.Node
 
 // NNModel structure
 type NNModel struct {
-	name      string       // name of model
-	g         *G.ExprGraph // model graph
-	paramsW   G.Nodes      // weight parameters
-	paramsB   G.Nodes      // bias parameters
-	paramsEmb G.Nodes      // embedding parameters
-	output    G.Result     // graph output
-	inputsC   G.Nodes      // continuous (including one-hot) Inputs
-	inputsE   G.Nodes      // embedding Inputs
-	obs       *G.Node      // observed values for model fit
-	cost      *G.Node      // cost node for model build
-	construct ModSpec      // model spec
-	costFn    CostFunc     // costFn corresponding to cost *G.Node
-	build     bool         // build mode includes drop out layers
-	inputFT   FTypes       // FTypes of input features
-	targetFT  *FType       // FType of output (target)
-	outCols   int          // columns in output
-	opts      []NNOpts     // input options
+	name      string         // name of model
+	g         *G.ExprGraph   // model graph
+	paramsW   G.Nodes        // weight parameters
+	paramsB   G.Nodes        // bias parameters
+	paramsEmb G.Nodes        // embedding parameters
+	embOf     []int          // index into paramsEmb of each embedding input
+	embOut    G.Nodes        // embeddings of the embedding Inputs (see Fwd)
+	output    G.Result       // graph output
+	inputsC   G.Nodes        // continuous (including one-hot) Inputs
+	inputsE   G.Nodes        // embedding Inputs
+	inputsS   G.Nodes        // sequence Inputs of the sequence layers
+	embSeq    bool           // the embeddings are the steps of the input of the sequence layers (see SeqLayers)
+	obs       *G.Node        // observed values for model fit
+	exposure  *G.Node        // exposure of each observation (see Hazard)
+	offset    *G.Node        // offset added to the linear predictor of the last layer
+	cost      *G.Node        // cost node for model build
+	construct ModSpec        // model spec
+	costFn    CostFunc       // costFn corresponding to cost *G.Node
+	build     bool           // build mode includes drop out layers
+	inputFT   FTypes         // FTypes of input features
+	targetFT  *FType         // FType of output (target)
+	outCols   int            // columns in output
+	opts      []NNOpts       // input options
+	dropMasks G.Nodes        // dropout masks (build mode)
+	dropProbs []float64      // dropout probabilities
+	noise     G.Nodes        // input noise (build mode)
+	noiseSDs  []float64      // standard deviations of the input noise
+	blkMasks  G.Nodes        // masks of the block-diagonal hidden FC layers of a model with grouped inputs
+	monotone  map[string]int // monotonicity constraints by input (+1 increasing, -1 decreasing)
+	heads     []*head        // output heads of a multi-output model
+	view      *head          // head of a view returned by Head
+	unseen    map[string]int // # of values not in the Levels, by field of the pipeline scored by PredictNN
+	padBatch  bool           // PredictNN scores all the rows of the pipeline (see WithPadBatch)
+	fitAll    []float64      // fitted values of all the rows scored by PredictNN with WithPadBatch
+	obsAll    []float64      // observed values of all the rows scored by PredictNN with WithPadBatch
+}
+
+// head is an output head of a multi-output model
+type head struct {
+	target    string    // name of the target field
+	targetFT  *FType    // FType of the target (nil if not in the pipeline)
+	loc       int       // ModSpec layer of the head
+	weight    float64   // weight of the head cost in the model cost
+	quantiles []float64 // quantiles fit by the head (see Pinball)
+	obs       *G.Node   // observed values of the target
+	output    *G.Node   // head output
+	cost      *G.Node   // head cost (see MultiCost)
+	fit       G.Value   // value of output read on each run of the graph
+	costVal   G.Value   // value of cost read on each run of the graph
+	fitAll    []float64 // fitted values of all the rows scored by PredictNN with WithPadBatch
+	obsAll    []float64 // observed values of all the rows scored by PredictNN with WithPadBatch
 }
 
 // Opts returns user-input With options
@@ -46,6 +83,12 @@ func (m *NNModel) Opts() []NNOpts {
 	return m.opts
 }
 
+// Unseen returns the number of values of each FRCat field of the pipeline scored by PredictNN that are not in the
+// Levels of the field (see UnseenPolicy).  Fields without any are omitted.
+func (m *NNModel) Unseen() map[string]int {
+	return m.unseen
+}
+
 // ModSpec returns the ModSpec for the model
 func (m *NNModel) ModSpec() ModSpec {
 	return m.construct
@@ -73,10 +116,19 @@ func (m *NNModel) String() string {
 
 	str = fmt.Sprintf("%sTarget\n", str)
 
-	switch m.targetFT == nil {
-	case true:
+	switch {
+	case m.heads != nil:
+		for _, h := range m.heads {
+			if h.targetFT == nil {
+				str = fmt.Sprintf("%s%s\n", str, h.target)
+				continue
+			}
+
+			str = fmt.Sprintf("%s%v", str, h.targetFT)
+		}
+	case m.targetFT == nil:
 		str = fmt.Sprintf("%sNone\n", str)
-	case false:
+	default:
 		str = fmt.Sprintf("%s%v", str, m.targetFT)
 	}
 
@@ -92,7 +144,7 @@ func (m *NNModel) String() string {
 		str = fmt.Sprintf("%sCost function: %s\n\n", str, m.cost.Name())
 	}
 
-	bSize := m.inputsC[0].Shape()[0]
+	bSize := m.Features()[0].Shape()[0]
 	str = fmt.Sprintf("%sBatch size: %d\n", str, bSize)
 
 	nPar := 0
@@ -104,7 +156,27 @@ func (m *NNModel) String() string {
 		nPar += n.Shape()[0] * n.Shape()[1]
 	}
 
-	str = fmt.Sprintf("%s%d FC parameters\n", str, nPar)
+	// parameters of the sequence layers
+	nSeq := 0
+	if layers, e := m.construct.SeqLayers(); e == nil {
+		for ind := 1; ind <= layers; ind++ {
+			loc := strconv.Itoa(ind)
+			params := G.Nodes{GetNode(m.paramsW, "lWeights"+loc), GetNode(m.paramsW, "rWeights"+loc),
+				GetNode(m.paramsW, "oWeights"+loc), GetNode(m.paramsB, "lBias"+loc)}
+
+			for _, n := range params {
+				if n != nil {
+					nSeq += n.Shape().TotalSize()
+				}
+			}
+		}
+	}
+
+	if nSeq > 0 {
+		str = fmt.Sprintf("%s%d Sequence parameters\n", str, nSeq)
+	}
+
+	str = fmt.Sprintf("%s%d FC parameters\n", str, nPar-nSeq)
 	nEmb := 0
 
 	for _, n := range m.paramsEmb {
@@ -126,21 +198,58 @@ func (m *NNModel) Cost() *G.Node {
 	return m.cost
 }
 
-// FitSlice returns fitted values as a slice
+// FitSlice returns fitted values as a slice.  The slice is row-major with OutputCols columns.  For a multi-output
+// model, the columns of the heads are side by side in the order of the Output layers, and a quantile head has one
+// column per quantile in increasing order.  After PredictNN with WithPadBatch, it has a row for every row of the
+// pipeline.
 func (m *NNModel) FitSlice() []float64 {
+	// intermediate nodes don't hold their values after a run, so heads are read from copies
+	if m.view != nil {
+		if m.view.fitAll != nil {
+			return m.view.fitAll
+		}
+
+		if m.view.fit == nil {
+			return nil
+		}
+
+		return m.view.fit.Data().([]float64)
+	}
+
+	if m.fitAll != nil {
+		return m.fitAll
+	}
+
 	return m.output.Nodes()[0].Value().Data().([]float64)
 }
 
-// ObsSlice returns target values as a slice
+// ObsSlice returns target values as a slice.  After PredictNN with WithPadBatch, it has a row for every row of the
+// pipeline.
 func (m *NNModel) ObsSlice() []float64 {
 	if m.obs == nil {
 		return nil
 	}
+
+	if m.view != nil && m.view.obsAll != nil {
+		return m.view.obsAll
+	}
+
+	if m.view == nil && m.obsAll != nil {
+		return m.obsAll
+	}
 	return m.obs.Value().Data().([]float64)
 }
 
 // CostFlt returns the value of the cost node
 func (m *NNModel) CostFlt() float64 {
+	if m.view != nil {
+		if m.view.costVal == nil {
+			return math.NaN()
+		}
+
+		return m.view.costVal.Data().(float64)
+	}
+
 	return m.cost.Value().Data().(float64)
 }
 
@@ -159,20 +268,94 @@ func (m *NNModel) OutputCols() int {
 	return m.output.Nodes()[0].Shape()[1]
 }
 
-// Inputs returns input (continuous+embedded+observed) Inputs
+// Inputs returns input (continuous+embedded+sequence+offset+observed+exposure) Inputs
 func (m *NNModel) Inputs() G.Nodes {
 	n := append(m.inputsC, m.inputsE...)
+	n = append(n, m.inputsS...)
+
+	if m.offset != nil {
+		n = append(n, m.offset)
+	}
 
 	if m.obs == nil {
 		return n
 	}
 
-	return append(n, m.obs)
+	if m.heads != nil {
+		for _, h := range m.heads {
+			n = append(n, h.obs)
+		}
+
+		return n
+	}
+
+	n = append(n, m.obs)
+
+	if m.exposure == nil {
+		return n
+	}
+
+	return append(n, m.exposure)
+}
+
+// Offset returns the offset node.  It is nil if the ModSpec has no Offset layer.
+func (m *NNModel) Offset() *G.Node {
+	return m.offset
+}
+
+// Exposure returns the exposure node.  It is nil if the ModSpec Target has no exposure field.
+func (m *NNModel) Exposure() *G.Node {
+	return m.exposure
+}
+
+// Heads returns the target fields of the output heads of a multi-output model.  It is nil if the model has a single
+// output.
+func (m *NNModel) Heads() []string {
+	var targets []string
+
+	for _, h := range m.heads {
+		targets = append(targets, h.target)
+	}
+
+	return targets
+}
+
+// Head returns a view of the output head of a multi-output model with target field target. The view's Fitted, Obs,
+// FitSlice, ObsSlice and OutputCols are those of the head and its Cost is the head cost (see MultiCost). It returns
+// nil if there is no such head.
+func (m *NNModel) Head(target string) *NNModel {
+	for _, h := range m.heads {
+		if h.target != target {
+			continue
+		}
+
+		hm := *m
+		hm.output, hm.obs, hm.cost = h.output, h.obs, h.cost
+		hm.targetFT, hm.exposure, hm.heads, hm.view = h.targetFT, nil, nil, h
+		hm.outCols = h.output.Shape()[1]
+
+		return &hm
+	}
+
+	return nil
+}
+
+// Quantiles returns the quantiles of a quantile head, or of the head of a model with a single Output layer.  The
+// columns of the output of the head are the quantiles in this order.  It returns nil if there are no quantiles.
+func (m *NNModel) Quantiles() []float64 {
+	switch {
+	case m.view != nil:
+		return m.view.quantiles
+	case len(m.heads) == 1:
+		return m.heads[0].quantiles
+	}
+
+	return nil
 }
 
-// Features returns the model input features (continuous+embedded)
+// Features returns the model input features (continuous+embedded+sequence)
 func (m *NNModel) Features() G.Nodes {
-	return append(m.inputsC, m.inputsE...)
+	return append(append(m.inputsC, m.inputsE...), m.inputsS...)
 }
 
 // Params retursn the model parameter nodes (weights, biases, embeddings)
@@ -183,6 +366,18 @@ func (m *NNModel) Params() G.Nodes {
 	return p
 }
 
+// softmaxOut returns true if the last FC layer of the model has a softmax activation
+func (m *NNModel) softmaxOut() bool {
+	for ind := len(m.construct) - 1; ind >= 0; ind-- {
+		if ly, e := m.construct.LType(ind); e == nil && *ly == FC {
+			fc := m.construct.FC(ind)
+			return fc != nil && fc.Act == SoftMax
+		}
+	}
+
+	return false
+}
+
 // G returns model graph
 func (m *NNModel) G() *G.ExprGraph {
 	return m.g
@@ -210,49 +405,192 @@ func WithName(name string) NNOpts {
 	return f
 }
 
+// WithMonotone declares that the model output should be increasing (direction = +1) or decreasing (direction = -1)
+// in the FRCts input field.  The constraint is checked by CheckMonotone.
+func WithMonotone(field string, direction int) NNOpts {
+	f := func(m *NNModel) {
+		if m.monotone == nil {
+			m.monotone = make(map[string]int)
+		}
+
+		m.monotone[field] = direction
+	}
+
+	return f
+}
+
+// WithPadBatch has PredictNN score every row of the pipeline exactly once, a batch at a time, rather than only the
+// first batch.  If the batch size does not divide the rows, the last batch is padded with copies of its last row and
+// the padding is trimmed from FitSlice and ObsSlice.
+func WithPadBatch() NNOpts {
+	f := func(m *NNModel) {
+		m.padBatch = true
+	}
+
+	return f
+}
+
+// Monotone returns the monotonicity constraints declared by WithMonotone
+func (m *NNModel) Monotone() map[string]int {
+	return m.monotone
+}
+
 // NewNNModel creates a new NN model.
-// Specs for fields in modSpec are pulled from pipe.
+// Specs for fields in modSpec are pulled from pipe.  Inputs that are terms (e.g. x1*x2) not in pipe are added to
+// it (see ModSpec Inputs).
 // if build is true, DropOut layers are included.
 func NewNNModel(modSpec ModSpec, pipe Pipeline, build bool, nnOpts ...NNOpts) (*NNModel, error) {
 	bSize := pipe.BatchSize()
 	g := G.NewGraph()
 	xs := make(G.Nodes, 0)
-	embParm := make(G.Nodes, 0) // embedding parameters
-	xEmInp := make(G.Nodes, 0)  // one-hot input
-	xEmProd := make(G.Nodes, 0) // product of one-hot input and embedding parameters
+	seqs := make(G.Nodes, 0)     // sequence input
+	embParm := make(G.Nodes, 0)  // embedding parameters
+	xEmInp := make(G.Nodes, 0)   // one-hot input
+	xEmProd := make(G.Nodes, 0)  // product of one-hot input and embedding parameters
+	embOf := make([]int, 0)      // index into embParm of each embedding input
+	embFrom := make([]*FType, 0) // first input of each embedding
 	// work through the features
 	inps, e := modSpec.Inputs(pipe)
 	if e != nil {
 		return nil, e
 	}
 
+	// interaction group of each input, if the inputs are grouped
+	groups, e := modSpec.InputGroups()
+	if e != nil {
+		return nil, e
+	}
+
+	grpInd := make(map[string]int)
+	for _, grp := range groups {
+		if _, ok := grpInd[grp]; !ok {
+			grpInd[grp] = len(grpInd)
+		}
+	}
+
+	grpC, grpE := make([]int, 0), make([]int, 0) // group of each of xs and xEmProd
+
 	for ind := 0; ind < len(inps); ind++ {
 		f := inps[ind]
+		grp := -1
+
+		if groups != nil {
+			grp = grpInd[groups[ind]]
+		}
+
 		// first element is the target--skip
 		switch f.Role {
 		case FRCts:
 			x := G.NewTensor(g, tensor.Float64, 2, G.WithName(f.Name), G.WithShape(bSize, 1))
 			xs = append(xs, x)
+			grpC = append(grpC, grp)
 		case FROneHot:
 			x := G.NewTensor(g, tensor.Float64, 2, G.WithName(f.Name), G.WithShape(bSize, f.Cats))
 			xs = append(xs, x)
-		case FREmbed:
-			xemb := G.NewTensor(g, tensor.Float64, 2, G.WithName(f.Name), G.WithShape(bSize, f.Cats))
+			grpC = append(grpC, grp)
+		case FRSeq:
+			if groups != nil {
+				return nil, Wrapper(ErrNNModel, "NewNNModel: sequence inputs cannot be grouped")
+			}
+
+			steps, feats := f.SeqShape()
+			if len(seqs) > 0 && seqs[0].Shape()[1] != steps {
+				return nil, Wrapper(ErrNNModel, "NewNNModel: sequence inputs have differing steps")
+			}
+
+			x := G.NewTensor(g, tensor.Float64, 3, G.WithName(f.Name), G.WithShape(bSize, steps, feats))
+			seqs = append(seqs, x)
+		case FREmbed, FRCat:
+			// an FRCat input is embedded directly from its codes, without a one-hot field
+			xemb := G.NewTensor(g, tensor.Int, 1, G.WithName(f.Name), G.WithShape(bSize))
+			if f.Role == FREmbed {
+				xemb = G.NewTensor(g, tensor.Float64, 2, G.WithName(f.Name), G.WithShape(bSize, f.Cats))
+			}
+
 			xEmInp = append(xEmInp, xemb)
-			wemb := G.NewTensor(g, G.Float64, 2, G.WithName(f.Name+"Embed"), G.WithShape(f.Cats, f.EmbCols), G.WithInit(G.GlorotN(1)))
-			embParm = append(embParm, wemb)
-			z := G.Must(G.Mul(xemb, wemb))
-			xEmProd = append(xEmProd, z)
+
+			// inputs in the same share group use the same embedding
+			embName := modSpec.EmbedName(f.Name)
+			embInd := -1
+
+			for k, n := range embParm {
+				if n.Name() == embName {
+					embInd = k
+				}
+			}
+
+			if embInd < 0 {
+				wemb := G.NewTensor(g, G.Float64, 2, G.WithName(embName), G.WithShape(f.Cats, f.EmbCols), G.WithInit(glorotN(1.0)))
+				embParm = append(embParm, wemb)
+				embFrom = append(embFrom, f)
+				embInd = len(embParm) - 1
+			} else if e := sameVocab(pipe, embFrom[embInd], f); e != nil {
+				return nil, e
+			}
+
+			embOf = append(embOf, embInd)
+			xEmProd = append(xEmProd, embed(xemb, embParm[embInd]))
+			grpE = append(grpE, grp)
+		}
+	}
+
+	// steps and channels of the input to the sequence layers.  Without sequence inputs, the embeddings are the steps.
+	steps, chans := 0, 0
+	if len(seqs) > 0 {
+		steps = seqs[0].Shape()[1]
+		for _, x := range seqs {
+			chans += x.Shape()[2]
+		}
+	}
+
+	nSeq, e := modSpec.SeqLayers()
+	if e != nil {
+		return nil, e
+	}
+
+	embSeq := nSeq > 0 && len(seqs) == 0 && len(xEmProd) > 0
+	if embSeq {
+		if groups != nil {
+			return nil, Wrapper(ErrNNModel, "NewNNModel: embedded inputs of sequence layers cannot be grouped")
+		}
+
+		steps, chans = len(xEmProd), xEmProd[0].Shape()[1]
+		for _, x := range xEmProd {
+			if x.Shape()[1] != chans {
+				return nil, Wrapper(ErrNNModel, "NewNNModel: embedded inputs of sequence layers have differing columns")
+			}
+		}
+	}
+
+	// columns of the input to the first FC layer and, if the inputs are grouped, the group of each
+	lastCols := 0
+	grpX := append(grpC, grpE...)
+
+	var colGroup []int
+
+	fcIn := append(xs, xEmProd...)
+	if embSeq {
+		fcIn = xs
+	}
+
+	for ind, x := range fcIn {
+		lastCols += x.Shape()[1]
+
+		if groups != nil {
+			for col := 0; col < x.Shape()[1]; col++ {
+				colGroup = append(colGroup, grpX[ind])
+			}
 		}
 	}
 
-	// Inputs
-	xall := G.Must(G.Concat(1, xs...))
+	// sequence layers
+	parW, parB, seqCols, e := newSequence(modSpec, steps, chans, g)
+	if e != nil {
+		return nil, e
+	}
 
-	// add Inputs to embeddings, if present
-	if len(xEmInp) > 0 {
-		zemb := G.Must(G.Concat(1, xEmProd...)) // embeddings for input to FCLayer layer
-		xall = G.Must(G.Concat(1, xall, zemb))
+	if lastCols += seqCols; lastCols == 0 {
+		return nil, Wrapper(ErrNNModel, "NewNNModel: no inputs")
 	}
 
 	// target.  There may not be a target if the model has been built and is now in prediction mode.
@@ -272,9 +610,49 @@ func NewNNModel(modSpec ModSpec, pipe Pipeline, build bool, nnOpts ...NNOpts) (*
 		}
 	}
 
-	lastCols := xall.Shape()[1] // layer output dim
-	parW := make(G.Nodes, 0)
-	parB := make(G.Nodes, 0)
+	// offset.  Unlike the target, it is needed in prediction mode.
+	var offset *G.Node
+
+	offF, e := modSpec.Offset(pipe)
+	if e != nil {
+		return nil, e
+	}
+
+	if offF != nil {
+		offset = G.NewTensor(g, tensor.Float64, 2, G.WithName(offF.Name), G.WithShape(bSize, 1))
+	}
+
+	// output heads of a multi-output model
+	heads, e := newHeads(modSpec, pipe, bSize, g)
+	if e != nil {
+		return nil, e
+	}
+
+	if heads != nil && heads[0].obs != nil {
+		headObs := make(G.Nodes, 0)
+		for _, h := range heads {
+			headObs = append(headObs, h.obs)
+		}
+
+		yoh = headObs[0]
+		if len(headObs) > 1 {
+			yoh = G.Must(G.Concat(1, headObs...))
+		}
+	}
+
+	headCols := 0
+
+	// the last FC layer is the output layer unless the model has heads
+	lastFC := -1
+
+	for ind := 1; ind < len(modSpec); ind++ {
+		if modSpec.FC(ind) != nil && heads == nil {
+			lastFC = ind
+		}
+	}
+
+	blkMasks := make(G.Nodes, 0)
+	hidden := false // true once an FC or Output layer is reached
 
 	adder := 0 // add 1 if the output is softmax
 	for ind := 0; ind < len(modSpec); ind++ {
@@ -283,6 +661,40 @@ func NewNNModel(modSpec ModSpec, pipe Pipeline, build bool, nnOpts ...NNOpts) (*
 			return nil, e
 		}
 
+		switch *ly {
+		case InputDropout, GaussianNoise:
+			if hidden {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: %s layer %d must precede the FC layers", ly, ind))
+			}
+
+			if modSpec.InputDropout(ind) == nil && modSpec.GaussianNoise(ind) == nil {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: error parsing layer %d", ind))
+			}
+		case FC, Output:
+			hidden = true
+		}
+
+		// the heads all take the output of the last hidden layer
+		if *ly == Output {
+			out := modSpec.Output(ind)
+			cols := out.Size
+			headCols += cols
+
+			if out.Act == SoftMax {
+				cols--
+			}
+
+			parW = append(parW, G.NewTensor(g, tensor.Float64, 2, G.WithName("lWeights"+strconv.Itoa(ind)),
+				G.WithShape(lastCols, cols), G.WithInit(glorotN(1.0))))
+
+			if out.Bias {
+				parB = append(parB, G.NewTensor(g, tensor.Float64, 2, G.WithName("lBias"+strconv.Itoa(ind)),
+					G.WithShape(1, cols), G.WithInit(glorotN(1.0))))
+			}
+
+			continue
+		}
+
 		if *ly != FC {
 			continue
 		}
@@ -307,11 +719,29 @@ func NewNNModel(modSpec ModSpec, pipe Pipeline, build bool, nnOpts ...NNOpts) (*
 		}
 
 		nmw := "lWeights" + strconv.Itoa(ind)
-		w := G.NewTensor(g, tensor.Float64, 2, G.WithName(nmw), G.WithShape(lastCols, cols), G.WithInit(G.GlorotN(1.0)))
+		w := G.NewTensor(g, tensor.Float64, 2, G.WithName(nmw), G.WithShape(lastCols, cols), G.WithInit(glorotN(1.0)))
+
+		// the hidden layers of a model with grouped inputs are block-diagonal.  The output layer combines the groups.
+		if colGroup != nil && ind != lastFC {
+			if cols < len(grpInd) {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: layer %d has fewer columns than input groups", ind))
+			}
+
+			mask, outGroup := blockMask(colGroup, cols, len(grpInd))
+
+			wData := w.Value().Data().([]float64)
+			for k := range wData {
+				wData[k] *= mask[k]
+			}
+
+			blkMasks = append(blkMasks, G.NewTensor(g, tensor.Float64, 2, G.WithName("lMask"+strconv.Itoa(ind)),
+				G.WithShape(lastCols, cols), G.WithValue(tensor.New(tensor.WithShape(lastCols, cols), tensor.WithBacking(mask)))))
+			colGroup = outGroup
+		}
 
 		if fc.Bias {
 			nmb := "lBias" + strconv.Itoa(ind)
-			b := G.NewTensor(g, tensor.Float64, 2, G.WithName(nmb), G.WithShape(1, cols), G.WithInit(G.GlorotN(1.0)))
+			b := G.NewTensor(g, tensor.Float64, 2, G.WithName(nmb), G.WithShape(1, cols), G.WithInit(glorotN(1.0)))
 			parB = append(parB, b)
 		}
 
@@ -321,27 +751,52 @@ func NewNNModel(modSpec ModSpec, pipe Pipeline, build bool, nnOpts ...NNOpts) (*
 	}
 
 	outputCols := lastCols + adder
+	if heads != nil {
+		outputCols = headCols
+	}
 
-	if yoh != nil {
+	// the heads are checked by newHeads
+	if yoh != nil && heads == nil {
 		if yoh.Shape()[1] != outputCols {
 			return nil, Wrapper(ErrNNModel, "NewNNModel: output node and obs node have differing columns")
 		}
 	}
 
+	// exposure.  As with the target, it may not be in the pipeline in prediction mode.
+	var expo *G.Node
+
+	if yoh != nil {
+		expF, e := modSpec.Exposure(pipe)
+		if e != nil {
+			return nil, e
+		}
+
+		if expF != nil {
+			expo = G.NewTensor(g, tensor.Float64, 2, G.WithName(expF.Name), G.WithShape(bSize, 1))
+		}
+	}
+
 	nn := &NNModel{
 		g:         g,
 		paramsW:   parW,
 		paramsB:   parB,
 		paramsEmb: embParm,
+		embOf:     embOf,
 		inputsC:   xs,
 		inputsE:   xEmInp,
+		inputsS:   seqs,
+		embSeq:    embSeq,
 		obs:       yoh,
+		exposure:  expo,
+		offset:    offset,
 		construct: modSpec,
 		build:     build,
 		inputFT:   inps,
 		targetFT:  obsF,
 		outCols:   outputCols,
 		opts:      nnOpts,
+		heads:     heads,
+		blkMasks:  blkMasks,
 	}
 
 	nn.Fwd() // init forward pass
@@ -354,106 +809,417 @@ func NewNNModel(modSpec ModSpec, pipe Pipeline, build bool, nnOpts ...NNOpts) (*
 	return nn, nil
 }
 
-// Fwd builds forward pass
-func (m *NNModel) Fwd() {
-	// input nodes
-	xall := G.Must(G.Concat(1, m.inputsC...))
-	// add embeddings
-	if len(m.inputsE) > 0 {
-		zp := make(G.Nodes, 0)
+// embed returns the embedding of the input x by the parameters emb.  x is either one-hot or, for an FRCat input,
+// a vector of the codes, which select rows of emb.
+func embed(x, emb *G.Node) *G.Node {
+	if x.Dtype() == tensor.Int {
+		return G.Must(G.ByIndices(emb, x, 0))
+	}
 
-		for ind, x := range m.inputsE {
-			z := G.Must(G.Mul(x, m.paramsEmb[ind]))
-			zp = append(zp, z)
-		}
+	return G.Must(G.Mul(x, emb))
+}
 
-		emb := G.Must(G.Concat(1, zp...))
-		xall = G.Must(G.Concat(1, xall, emb))
+// sameVocab checks that the embedded inputs ft1 and ft2 can share an embedding.  They must have the same dimensions
+// and their levels must map to the same one-hot columns.
+func sameVocab(pipe Pipeline, ft1, ft2 *FType) error {
+	if ft1.Cats != ft2.Cats || ft1.EmbCols != ft2.EmbCols {
+		return Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: %s and %s share an embedding but differ in size", ft1.Name, ft2.Name))
 	}
 
-	out := xall
-
-	// work through layers
-	for ind := 1; ind < len(m.construct); ind++ {
-		ltype, e := m.construct.LType(ind)
-		if e != nil {
-			panic(e)
+	// the levels of an embedded FRCat input are its own
+	vocab := func(ft *FType) *FType {
+		if ft.Role == FRCat {
+			return ft
 		}
 
-		switch *ltype {
-		case FC:
-			fc := m.construct.FC(ind)
-			nmw := "lWeights" + strconv.Itoa(ind)
+		return pipe.GetFType(ft.From)
+	}
 
-			px := GetNode(m.paramsW, nmw)
-			out = G.Must(G.Mul(out, px))
-			nmb := "lBias" + strconv.Itoa(ind)
-			bias := GetNode(m.paramsB, nmb)
+	from1, from2 := vocab(ft1), vocab(ft2)
+	if from1 == nil || from2 == nil || from1.FP == nil || from2.FP == nil {
+		return nil
+	}
 
-			if bias != nil {
-				out = G.Must(G.BroadcastAdd(out, bias, nil, []byte{0}))
-			}
+	if len(from1.FP.Lvl) != len(from2.FP.Lvl) {
+		return Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: %s and %s share an embedding but not a vocabulary", ft1.Name, ft2.Name))
+	}
 
-			switch fc.Act {
-			case Relu:
-				out = ReluAct(out)
-			case LeakyRelu:
-				out = LeakyReluAct(out, fc.ActParm)
-			case Sigmoid:
-				out = SigmoidAct(out)
-			case SoftMax:
-				out = SoftMaxAct(out)
-			}
-		case DropOut:
-			if m.build {
-				if d := m.construct.DropOut(ind); d != nil {
-					out = G.Must(G.Dropout(out, d.DropProb))
-				}
-			}
+	for k, v := range from1.FP.Lvl {
+		if v2, ok := from2.FP.Lvl[k]; !ok || v2 != v {
+			return Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: %s and %s share an embedding but not a vocabulary", ft1.Name, ft2.Name))
 		}
 	}
 
-	m.output = out
-}
-
-// struct to save nodes to json file
-type saveNode struct {
-	Name  string    `json:"name"`
-	Dims  []int     `json:"dims"`
-	Parms []float64 `json:"parms"`
+	return nil
 }
 
-func noNaN(parms G.Nodes) (hasNans bool) {
-	hasNans = false
-
-	for _, node := range parms {
-		data := node.Value().Data().([]float64)
-		for _, x := range data {
-			if math.IsNaN(x) {
-				return true
-			}
-		}
+// newHeads creates the heads of a multi-output model.  It returns nil if the model has a single output. The obs
+// nodes of the heads are nil if any of the targets are not in pipe (prediction mode).
+func newHeads(modSpec ModSpec, pipe Pipeline, bSize int, g *G.ExprGraph) ([]*head, error) {
+	outs, e := modSpec.Outputs()
+	if e != nil {
+		return nil, e
 	}
 
-	return
-}
+	if outs == nil {
+		return nil, nil
+	}
 
-// Save saves a model to disk.  Two files are created: <fileRoot>S.nn for the ModSpec and
-// <fileRoot>P.nn form the parameters.
-func (m *NNModel) Save(fileRoot string) (err error) {
-	fileP := fileRoot + "P.nn"
-	f, err := os.Create(fileP)
+	heads := make([]*head, 0)
+	allObs := true
 
-	if err != nil {
-		return
-	}
+	for ind := 0; ind < len(modSpec); ind++ {
+		out := modSpec.Output(ind)
+		if out == nil {
+			continue
+		}
 
-	defer func() { _ = f.Close() }()
+		h := &head{target: out.Target, targetFT: pipe.GetFType(out.Target), loc: ind, weight: out.Weight,
+			quantiles: out.Quantiles}
+		heads = append(heads, h)
 
-	ps := make([]saveNode, 0)
+		if h.targetFT == nil {
+			allObs = false
+			continue
+		}
 
-	for ind := 0; ind < len(m.Params()); ind++ {
-		n := m.Params()[ind]
+		cols := 0
+
+		switch h.targetFT.Role {
+		case FRCts:
+			cols = 1
+		case FROneHot:
+			cols = h.targetFT.Cats
+		default:
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: target %s must be either FRCts or FROneHot", out.Target))
+		}
+
+		if out.Act == SoftMax && h.targetFT.Role != FROneHot {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: target %s not one-hot but softmax activation", out.Target))
+		}
+
+		// a quantile head has a column for each quantile of its FRCts target
+		if out.Quantiles != nil && h.targetFT.Role != FRCts {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: quantile target %s must be FRCts", out.Target))
+		}
+
+		if cols != out.Size && out.Quantiles == nil {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: Output layer size and target %s have differing columns", out.Target))
+		}
+
+		h.obs = G.NewTensor(g, tensor.Float64, 2, G.WithName(out.Target), G.WithShape(bSize, cols))
+	}
+
+	if !allObs {
+		for _, h := range heads {
+			h.obs = nil
+		}
+	}
+
+	return heads, nil
+}
+
+// Fwd builds forward pass
+func (m *NNModel) Fwd() {
+	// input nodes
+	xs := append(G.Nodes{}, m.inputsC...)
+
+	// add embeddings
+	m.embOut = make(G.Nodes, 0)
+	for ind, x := range m.inputsE {
+		m.embOut = append(m.embOut, embed(x, m.paramsEmb[m.embOf[ind]]))
+	}
+
+	if !m.embSeq {
+		xs = append(xs, m.embOut...)
+	}
+
+	// add the output of the sequence layers
+	if len(m.inputsS) > 0 || m.embSeq {
+		xs = append(xs, m.sequence())
+	}
+
+	out := xs[0]
+	if len(xs) > 1 {
+		out = G.Must(G.Concat(1, xs...))
+	}
+	headOut := make(G.Nodes, 0)
+
+	// the offset is added to the last FC layer (or to each head)
+	lastFC := 0
+
+	for ind := 1; ind < len(m.construct); ind++ {
+		if m.construct.FC(ind) != nil {
+			lastFC = ind
+		}
+	}
+
+	// work through layers
+	for ind := 1; ind < len(m.construct); ind++ {
+		ltype, e := m.construct.LType(ind)
+		if e != nil {
+			panic(e)
+		}
+
+		switch *ltype {
+		case FC:
+			fc := m.construct.FC(ind)
+			out = m.dense(out, ind, fc.Act, fc.ActParm, ind == lastFC && m.heads == nil)
+		case Output:
+			// out is the last hidden layer, which feeds each head
+			o := m.construct.Output(ind)
+			hout := m.dense(out, ind, o.Act, o.ActParm, true)
+			if len(o.Quantiles) > 1 {
+				hout = nonCrossing(hout)
+			}
+
+			headOut = append(headOut, hout)
+
+			for _, h := range m.heads {
+				if h.loc == ind {
+					h.output = hout
+					G.Read(hout, &h.fit)
+				}
+			}
+		case DropOut:
+			if m.build {
+				if d := m.construct.DropOut(ind); d != nil && d.DropProb > 0.0 {
+					out = m.dropout(out, d.DropProb, ind)
+				}
+			}
+		case InputDropout:
+			if m.build {
+				if d := m.construct.InputDropout(ind); d != nil && d.DropProb > 0.0 {
+					out = m.dropout(out, d.DropProb, ind)
+				}
+			}
+		case GaussianNoise:
+			if m.build {
+				if gn := m.construct.GaussianNoise(ind); gn != nil && gn.Sigma > 0.0 {
+					out = m.addNoise(out, gn.Sigma, ind)
+				}
+			}
+		}
+	}
+
+	switch len(headOut) {
+	case 0:
+		m.output = out
+	case 1:
+		m.output = headOut[0]
+	default:
+		m.output = G.Must(G.Concat(1, headOut...))
+	}
+}
+
+// nonCrossing maps the columns z of the output of a quantile head to increasing quantiles: the first is z1 and each
+// subsequent quantile adds softplus(zj) to the one before.
+func nonCrossing(z *G.Node) *G.Node {
+	rows, cols := z.Shape()[0], z.Shape()[1]
+
+	first := G.Must(G.Slice(z, nil, G.S(0, 1)))
+	incr := G.Must(G.Softplus(G.Must(G.Slice(z, nil, G.S(1, cols)))))
+	incr = G.Must(G.Reshape(incr, tensor.Shape{rows, cols - 1}))
+	s := G.Must(G.Concat(1, G.Must(G.Reshape(first, tensor.Shape{rows, 1})), incr))
+
+	// cumulative sum across columns
+	back := make([]float64, cols*cols)
+	for row := 0; row < cols; row++ {
+		for col := row; col < cols; col++ {
+			back[row*cols+col] = 1.0
+		}
+	}
+
+	ut := tensor.New(tensor.WithShape(cols, cols), tensor.WithBacking(back))
+	upper := G.NewTensor(z.Graph(), G.Float64, 2, G.WithName("cumSum"), G.WithShape(cols, cols), G.WithValue(ut))
+
+	return G.Must(G.Mul(s, upper))
+}
+
+// dense applies the weights and bias of layer ind to in followed by the activation act. If addOffset is true, the
+// offset is added to each column before the activation.
+func (m *NNModel) dense(in *G.Node, ind int, act Activation, actParm float64, addOffset bool) *G.Node {
+	w := GetNode(m.paramsW, "lWeights"+strconv.Itoa(ind))
+
+	// the mask of a block-diagonal layer keeps the weights between groups at zero
+	if mask := GetNode(m.blkMasks, "lMask"+strconv.Itoa(ind)); mask != nil {
+		w = G.Must(G.HadamardProd(w, mask))
+	}
+
+	out := G.Must(G.Mul(in, w))
+
+	if bias := GetNode(m.paramsB, "lBias"+strconv.Itoa(ind)); bias != nil {
+		out = G.Must(G.BroadcastAdd(out, bias, nil, []byte{0}))
+	}
+
+	if addOffset && m.offset != nil {
+		off := G.Must(G.Reshape(m.offset, tensor.Shape{m.offset.Shape()[0]}))
+		out = G.Must(G.BroadcastAdd(out, off, nil, []byte{1}))
+	}
+
+	switch act {
+	case Relu:
+		out = ReluAct(out)
+	case LeakyRelu:
+		out = LeakyReluAct(out, actParm)
+	case Sigmoid:
+		out = SigmoidAct(out)
+	case SoftMax:
+		out = SoftMaxAct(out)
+	}
+
+	return out
+}
+
+// dropout applies dropout with probability prob to the node out of layer ind.  The dropout mask is an input node
+// that is resampled before each batch by sampleDropouts.
+func (m *NNModel) dropout(out *G.Node, prob float64, ind int) *G.Node {
+	shp := out.Shape().Clone()
+	mask := G.NewTensor(m.g, tensor.Float64, 2, G.WithName("dropMask"+strconv.Itoa(ind)), G.WithShape(shp...),
+		G.WithValue(tensor.New(tensor.WithShape(shp...), tensor.WithBacking(dropMask(prob, shp.TotalSize(), rng)))))
+
+	m.dropMasks = append(m.dropMasks, mask)
+	m.dropProbs = append(m.dropProbs, prob)
+
+	return G.Must(G.HadamardProd(out, mask))
+}
+
+// addNoise adds normal noise with standard deviation sigma to the node out of layer ind.  As with dropout, the noise
+// is an input node that is resampled before each batch by sampleDropouts.
+func (m *NNModel) addNoise(out *G.Node, sigma float64, ind int) *G.Node {
+	shp := out.Shape().Clone()
+	nz := G.NewTensor(m.g, tensor.Float64, 2, G.WithName("noise"+strconv.Itoa(ind)), G.WithShape(shp...),
+		G.WithValue(tensor.New(tensor.WithShape(shp...), tensor.WithBacking(normNoise(sigma, shp.TotalSize(), rng)))))
+
+	m.noise = append(m.noise, nz)
+	m.noiseSDs = append(m.noiseSDs, sigma)
+
+	return G.Must(G.Add(out, nz))
+}
+
+// sampleDropouts draws new dropout masks and input noise using rnd
+func (m *NNModel) sampleDropouts(rnd *rand.Rand) error {
+	for ind, mask := range m.dropMasks {
+		shp := mask.Shape().Clone()
+		t := tensor.New(tensor.WithShape(shp...), tensor.WithBacking(dropMask(m.dropProbs[ind], shp.TotalSize(), rnd)))
+
+		if e := G.Let(mask, t); e != nil {
+			return e
+		}
+	}
+
+	for ind, nz := range m.noise {
+		shp := nz.Shape().Clone()
+		t := tensor.New(tensor.WithShape(shp...), tensor.WithBacking(normNoise(m.noiseSDs[ind], shp.TotalSize(), rnd)))
+
+		if e := G.Let(nz, t); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// dropMask returns a dropout mask of size n. Elements are 0 with probability prob and 1/(1-prob) otherwise.
+func dropMask(prob float64, n int, rnd *rand.Rand) []float64 {
+	keep := 1.0 - prob
+	mask := make([]float64, n)
+
+	for ind := 0; ind < n; ind++ {
+		if rnd.Float64() < keep {
+			mask[ind] = 1.0 / keep
+		}
+	}
+
+	return mask
+}
+
+// normNoise returns n draws from a normal distribution with mean 0 and standard deviation sigma
+func normNoise(sigma float64, n int, rnd *rand.Rand) []float64 {
+	nz := make([]float64, n)
+	for ind := 0; ind < n; ind++ {
+		nz[ind] = sigma * rnd.NormFloat64()
+	}
+
+	return nz
+}
+
+// blockMask returns the mask of a block-diagonal layer with cols columns whose input columns are in the groups
+// inGroup.  The columns of the layer are split evenly among the nGroup groups.  The mask is 1 where the input and
+// output columns are in the same group and 0 otherwise.  outGroup is the group of each output column.
+func blockMask(inGroup []int, cols, nGroup int) (mask []float64, outGroup []int) {
+	outGroup = make([]int, cols)
+	for col := 0; col < cols; col++ {
+		outGroup[col] = col * nGroup / cols
+	}
+
+	mask = make([]float64, len(inGroup)*cols)
+
+	for row, grp := range inGroup {
+		for col := 0; col < cols; col++ {
+			if outGroup[col] == grp {
+				mask[row*cols+col] = 1.0
+			}
+		}
+	}
+
+	return mask, outGroup
+}
+
+// glorotN initializes weights with a Glorot normal distribution (as G.GlorotN) using the package random number
+// generator.
+func glorotN(gain float64) G.InitWFn {
+	return func(dt tensor.Dtype, s ...int) any {
+		n := tensor.Shape(s).TotalSize()
+		stdev := gain * math.Sqrt(2.0/float64(s[0]+s[1]))
+		w := make([]float64, n)
+
+		for ind := 0; ind < n; ind++ {
+			w[ind] = rng.NormFloat64() * stdev
+		}
+
+		return w
+	}
+}
+
+// struct to save nodes to json file
+type saveNode struct {
+	Name  string    `json:"name"`
+	Dims  []int     `json:"dims"`
+	Parms []float64 `json:"parms"`
+}
+
+func noNaN(parms G.Nodes) (hasNans bool) {
+	hasNans = false
+
+	for _, node := range parms {
+		data := node.Value().Data().([]float64)
+		for _, x := range data {
+			if math.IsNaN(x) {
+				return true
+			}
+		}
+	}
+
+	return
+}
+
+// Save saves a model to disk.  Two files are created: <fileRoot>S.nn for the ModSpec and
+// <fileRoot>P.nn form the parameters.  A Fit may also save a ModelCard as <fileRoot>M.json (see WithModelCard).
+func (m *NNModel) Save(fileRoot string) (err error) {
+	fileP := fileRoot + "P.nn"
+	f, err := os.Create(fileP)
+
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = f.Close() }()
+
+	ps := make([]saveNode, 0)
+
+	for ind := 0; ind < len(m.Params()); ind++ {
+		n := m.Params()[ind]
 
 		p := saveNode{
 			Name:  n.Name(),
@@ -494,26 +1260,11 @@ func LoadNN(fileRoot string, p Pipeline, build bool) (nn *NNModel, err error) {
 		return
 	}
 
-	fileP := fileRoot + "P.nn"
-	f, err := os.Open(fileP)
-
+	data, err := loadParams(fileRoot + "P.nn")
 	if err != nil {
 		return
 	}
 
-	defer func() { _ = f.Close() }()
-
-	js, err := io.ReadAll(f)
-
-	if err != nil {
-		return
-	}
-
-	data := make([]saveNode, 0)
-	if e := json.Unmarshal(js, &data); e != nil {
-		return nil, e
-	}
-
 	nn, err = NewNNModel(modSpec, p, build)
 	if err != nil {
 		return nil, err
@@ -552,6 +1303,28 @@ func LoadNN(fileRoot string, p Pipeline, build bool) (nn *NNModel, err error) {
 	return nn, nil
 }
 
+// loadParams reads the parameters saved by Save
+func loadParams(fileP string) ([]saveNode, error) {
+	f, err := os.Open(fileP)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	js, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]saveNode, 0)
+	if e := json.Unmarshal(js, &data); e != nil {
+		return nil, e
+	}
+
+	return data, nil
+}
+
 func SoftRMS(model *NNModel) (cost *G.Node) {
 	nCats := model.OutputCols()
 	for ind := 1; ind < nCats; ind++ {
@@ -585,6 +1358,121 @@ func CrossEntropy(model *NNModel) (cost *G.Node) {
 	return
 }
 
+// WeightedCrossEntropy returns a cross entropy cost function that weights the classes by weights. The keys of
+// weights are the columns of the (one-hot) target. Classes not in weights have a weight of 1.
+func WeightedCrossEntropy(weights map[int]float64) CostFunc {
+	return func(model *NNModel) (cost *G.Node) {
+		nCats := model.OutputCols()
+		back := make([]float64, nCats)
+
+		for ind := 0; ind < nCats; ind++ {
+			back[ind] = 1.0
+			if w, ok := weights[ind]; ok {
+				back[ind] = w
+			}
+		}
+
+		wts := tensor.New(tensor.WithBacking(back), tensor.WithShape(1, nCats))
+		wNode := G.NewTensor(model.G(), G.Float64, 2, G.WithName("classWeights"), G.WithShape(1, nCats), G.WithValue(wts))
+		obs := G.Must(G.BroadcastHadamardProd(model.Obs(), wNode, nil, []byte{0}))
+
+		// if a fitted value is 0, we drop it from the calculation.
+		isZero := G.Must(G.Lte(model.Fitted().Nodes()[0], G.NewConstant(0.0), true))
+		fit := G.Must(G.Add(model.Fitted().Nodes()[0], isZero))
+
+		cost = G.Must(G.Neg(G.Must(G.Mean(G.Must(G.HadamardProd(G.Must(G.Log(fit)), obs))))))
+
+		G.WithName("WeightedCrossEntropy")(cost)
+
+		return
+	}
+}
+
+// Hazard is a cost function for censored time-to-event targets (e.g. default, prepayment).  The data is a panel
+// with one row per entity and period at risk.  The target is 1 if the event occurred in the period, 0 otherwise
+// (including censored periods).  The ModSpec target may include an exposure field: Target(event, exposure).  The
+// exposure is the length of time at risk in the row (e.g. a fraction of a month). If there is no exposure field,
+// the exposure is 1.
+//
+// The model output is the log of the hazard rate, so the last layer should be FC(size:1) with linear activation.
+// The cost is the negative log-likelihood of the piecewise-exponential model (equivalently, Poisson with an offset
+// of log exposure): mean(exposure * exp(output) - event * output).
+func Hazard(model *NNModel) (cost *G.Node) {
+	logH := model.Fitted().Nodes()[0]
+	rate := G.Must(G.Exp(logH))
+
+	if model.Exposure() != nil {
+		rate = G.Must(G.HadamardProd(rate, model.Exposure()))
+	}
+
+	cost = G.Must(G.Mean(G.Must(G.Sub(rate, G.Must(G.HadamardProd(model.Obs(), logH))))))
+
+	G.WithName("Hazard")(cost)
+
+	return
+}
+
+// MultiCost returns the cost function of a multi-output model.  costs are the cost functions of the heads keyed by
+// their target fields.  The model cost is the sum over the heads of the head weight (see OutputLayer) times the head
+// cost.  Heads not in costs do not contribute to the model cost.
+func MultiCost(costs map[string]CostFunc) CostFunc {
+	return func(model *NNModel) (cost *G.Node) {
+		for _, h := range model.heads {
+			cf, ok := costs[h.target]
+			if !ok {
+				continue
+			}
+
+			h.cost = cf(model.Head(h.target))
+			G.Read(h.cost, &h.costVal)
+			wCost := G.Must(G.Mul(h.cost, G.NewConstant(h.weight)))
+
+			if cost == nil {
+				cost = wCost
+				continue
+			}
+
+			cost = G.Must(G.Add(cost, wCost))
+		}
+
+		if cost != nil {
+			G.WithName("MultiCost")(cost)
+		}
+
+		return
+	}
+}
+
+// Pinball is the cost function for a quantile head (see OutputLayer).  The cost is the mean over rows and quantiles
+// of the pinball loss: q * (y - yhat) if y >= yhat and (q - 1) * (y - yhat) otherwise.  To ensure the quantiles do not
+// cross, the head output is built as the first quantile plus positive increments.  Pinball applies to a view of a
+// head (see MultiCost) or to a model with a single Output layer.  If there are no quantiles, the median is fit.
+func Pinball(model *NNModel) (cost *G.Node) {
+	quantiles := model.Quantiles()
+	if quantiles == nil {
+		quantiles = []float64{0.5}
+	}
+
+	fit := model.Fitted().Nodes()[0]
+	if model.heads != nil {
+		fit = model.heads[0].output
+	}
+
+	nq := len(quantiles)
+	qt := tensor.New(tensor.WithShape(1, nq), tensor.WithBacking(append([]float64{}, quantiles...)))
+	qs := G.NewTensor(model.G(), G.Float64, 2, G.WithName("quantiles"), G.WithShape(1, nq), G.WithValue(qt))
+
+	// y - yhat for each quantile
+	obs := G.Must(G.Reshape(model.Obs(), tensor.Shape{model.Obs().Shape()[0]}))
+	diff := G.Must(G.BroadcastSub(obs, fit, []byte{1}, nil))
+	loss := G.Must(G.Add(G.Must(G.BroadcastHadamardProd(diff, qs, nil, []byte{0})), ReluAct(G.Must(G.Neg(diff)))))
+	cost = G.Must(G.Mean(loss))
+
+	G.WithName("Pinball")(cost)
+
+	return
+}
+
 // RMS cost function
 func RMS(model *NNModel) (cost *G.Node) {
 	cost = G.Must(golgi.RMS(model.Fitted().Nodes()[0], model.Obs()))
@@ -596,20 +1484,39 @@ func RMS(model *NNModel) (cost *G.Node) {
 
 // Fit struct for fitting a NNModel
 type Fit struct {
-	nn        *NNModel
-	modelPipe Pipeline
-	epochs    int
-	lrStart   float64
-	lrEnd     float64
-	outFile   string
-	tmpFile   string
-	valPipe   Pipeline
-	inCosts   *XY
-	outCosts  *XY
-	wait      int
-	bestEpoch int
-	l2Penalty float64
-	shuffle   int
+	nn         *NNModel
+	modelPipe  Pipeline
+	epochs     int
+	lrStart    float64
+	lrEnd      float64
+	outFile    string
+	tmpFile    string
+	valPipe    Pipeline
+	inCosts    *XY
+	outCosts   *XY
+	wait       int
+	sinceBest  int // epochs since the best epoch, for early stopping
+	bestEpoch  int
+	l2Penalty  float64
+	l1Penalty  float64
+	regBias    bool // if true, the penalties are not applied to biases
+	regEmb     bool // if true, the penalties are not applied to embeddings
+	shuffle    int
+	classWts   map[int]float64
+	rnd        *rand.Rand
+	epochCB    func(ep int, inCost, valCost float64)
+	progress   io.Writer
+	history    *FitHistory
+	outSet     bool              // true if the out file was set by WithOutFile
+	checkpoint string            // file root of checkpoints (see WithCheckpoint)
+	resume     string            // file root of the checkpoint to resume from (see WithResume)
+	seed       *int64            // seed set by WithFitSeed
+	card       bool              // if true, a ModelCard is saved with the model (see WithModelCard)
+	cardNotes  map[string]string // notes for the ModelCard
+	costFn     CostFunc          // cost function of the fit
+	cost       *G.Node           // cost node of the fit: the model cost or the class-weighted cost
+	valMetric  string            // metric that judges the best epoch on the validation Pipeline (see WithValidationMetric)
+	metricTrg  []int             // target columns of the validation metric
 }
 
 // FitOpts functions add options
@@ -617,14 +1524,10 @@ type FitOpts func(*Fit)
 
 // NewFit creates a new *Fit.
 func NewFit(nn *NNModel, epochs int, p Pipeline, opts ...FitOpts) *Fit {
-	outFile := fmt.Sprintf("%s/NN%d", os.TempDir(), int(rand.Uint32()))
-	tmpFile := fmt.Sprintf("%s/NN%d", os.TempDir(), int(rand.Uint32()))
 	fit := &Fit{
 		nn:        nn,
 		epochs:    epochs,
 		modelPipe: p,
-		outFile:   outFile,
-		tmpFile:   tmpFile,
 		shuffle:   0,
 	}
 
@@ -632,10 +1535,53 @@ func NewFit(nn *NNModel, epochs int, p Pipeline, opts ...FitOpts) *Fit {
 		o(fit)
 	}
 
+	if fit.outFile == "" {
+		fit.outFile = tempRoot()
+	}
+
+	fit.tmpFile = tempRoot()
+
 	return fit
 }
 
-// WithL2Reg adds L2 regularization
+// tempRoot returns a root name for model files in the temporary directory.  The name comes from os.CreateTemp, so it
+// does not depend on the random number generator of the fit.
+func tempRoot() string {
+	f, e := os.CreateTemp("", "NN")
+	if e != nil {
+		return fmt.Sprintf("%s/NN%d", os.TempDir(), int(rng.Uint32()))
+	}
+
+	// only the name is needed: the model files are <name>P.nn and <name>S.nn
+	name := f.Name()
+	_ = f.Close()
+	_ = os.Remove(name)
+
+	return name
+}
+
+// WithFitSeed sets the seed of the random number generator used for dropouts during the fit.  Without it, the package
+// generator is used (see SetSeed). The initial weights are drawn when the NNModel is created, so use SetSeed before
+// NewNNModel to make them reproducible.
+func WithFitSeed(seed int64) FitOpts {
+	f := func(ft *Fit) {
+		ft.rnd = newRand(seed)
+		ft.seed = &seed
+	}
+
+	return f
+}
+
+// random returns the random number generator for the fit
+func (ft *Fit) random() *rand.Rand {
+	if ft.rnd != nil {
+		return ft.rnd
+	}
+
+	return rng
+}
+
+// WithL2Reg adds L2 regularization.  The gradient of each parameter w is increased by penalty * w.
 func WithL2Reg(penalty float64) FitOpts {
 	f := func(ft *Fit) {
 		ft.l2Penalty = penalty
@@ -644,6 +1590,35 @@ func WithL2Reg(penalty float64) FitOpts {
 	return f
 }
 
+// WithL1Reg adds L1 regularization.  The gradient of each parameter w is increased by penalty * sign(w).
+func WithL1Reg(penalty float64) FitOpts {
+	f := func(ft *Fit) {
+		ft.l1Penalty = penalty
+	}
+
+	return f
+}
+
+// WithElasticNet adds both L1 and L2 regularization (see WithL1Reg, WithL2Reg).
+func WithElasticNet(l1, l2 float64) FitOpts {
+	f := func(ft *Fit) {
+		ft.l1Penalty = l1
+		ft.l2Penalty = l2
+	}
+
+	return f
+}
+
+// WithRegExclude excludes biases and/or embeddings from the L1 and L2 penalties.
+func WithRegExclude(biases, embeddings bool) FitOpts {
+	f := func(ft *Fit) {
+		ft.regBias = biases
+		ft.regEmb = embeddings
+	}
+
+	return f
+}
+
 // WithShuffle shuffles after interval epochs
 // Default is 0 (don't shuffle ever)
 func WithShuffle(interval int) FitOpts {
@@ -654,6 +1629,37 @@ func WithShuffle(interval int) FitOpts {
 	return f
 }
 
+// WithClassWeights fits the model using cross entropy with the classes weighted by w. The keys of w are the
+// columns of the (one-hot) target. Classes not in w have a weight of 1.  Use this when the classes are imbalanced,
+// for instance to up-weight a rare positive outcome.  The target must be FROneHot and the output softmax.  The
+// weighted cost is used by the fit only: the cost of the NNModel is not changed.
+func WithClassWeights(w map[int]float64) FitOpts {
+	f := func(ft *Fit) {
+		ft.classWts = w
+	}
+
+	return f
+}
+
+// WithEpochCallback sets a function that is called at the end of each epoch with the epoch, the in-sample cost and
+// the validation cost. The validation cost is NaN if there is no validation Pipeline.
+func WithEpochCallback(cb func(ep int, inCost, valCost float64)) FitOpts {
+	f := func(ft *Fit) {
+		ft.epochCB = cb
+	}
+
+	return f
+}
+
+// WithProgress writes a line to w at the end of each epoch with the costs, learning rate and elapsed time.
+func WithProgress(w io.Writer) FitOpts {
+	f := func(ft *Fit) {
+		ft.progress = w
+	}
+
+	return f
+}
+
 // WithLearnRate sets a learning rate function that declines linearly across the epochs.
 func WithLearnRate(lrStart, lrEnd float64) FitOpts {
 	f := func(ft *Fit) {
@@ -675,15 +1681,71 @@ func WithValidation(p Pipeline, wait int) FitOpts {
 	return f
 }
 
+// WithValidationMetric judges the best epoch, and early stopping, by the metric name (see Metric) on the validation
+// Pipeline rather than by the validation cost.  The fitted value is the sum of the target columns of the output.  If
+// target is omitted, it is column 0 for an FRCts target and column 1 for a target with two levels.  The history
+// still records the validation cost.  It requires WithValidation.
+func WithValidationMetric(name string, target ...int) FitOpts {
+	f := func(ft *Fit) {
+		ft.valMetric = name
+		ft.metricTrg = target
+	}
+
+	return f
+}
+
 // WithOutFile specifies the file root name to save the best model.
 func WithOutFile(fileName string) FitOpts {
 	f := func(ft *Fit) {
 		ft.outFile = fileName
+		ft.outSet = true
 	}
 
 	return f
 }
 
+// regularize adds the gradients of the L1 and L2 penalties to the gradients of the parameters. This is what the
+// gorgonia solvers do, but allows biases and embeddings to be excluded.
+func (ft *Fit) regularize() {
+	if ft.l1Penalty == 0.0 && ft.l2Penalty == 0.0 {
+		return
+	}
+
+	params := append(G.Nodes{}, ft.nn.paramsW...)
+	if !ft.regBias {
+		params = append(params, ft.nn.paramsB...)
+	}
+
+	if !ft.regEmb {
+		params = append(params, ft.nn.paramsEmb...)
+	}
+
+	for _, n := range params {
+		g, e := n.Grad()
+		if e != nil {
+			continue
+		}
+
+		grad, ok1 := g.Data().([]float64)
+		w, ok2 := n.Value().Data().([]float64)
+
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		for ind, wv := range w {
+			switch {
+			case wv > 0.0:
+				grad[ind] += ft.l1Penalty
+			case wv < 0.0:
+				grad[ind] -= ft.l1Penalty
+			}
+
+			grad[ind] += ft.l2Penalty * wv
+		}
+	}
+}
+
 // NNModel returns model
 func (ft *Fit) NNModel() *NNModel {
 	return ft.nn
@@ -704,6 +1766,11 @@ func (ft *Fit) InCosts() *XY {
 	return ft.inCosts
 }
 
+// History returns the epoch-by-epoch history of the fit
+func (ft *Fit) History() *FitHistory {
+	return ft.history
+}
+
 // OutCosts returns XY: X=epoch, Y=validation cost
 func (ft *Fit) OutCosts() *XY {
 	return ft.outCosts
@@ -712,9 +1779,38 @@ func (ft *Fit) OutCosts() *XY {
 // Do is the fitting loop.  Upon completion ft.nn will have the best model.
 func (ft *Fit) Do() (err error) {
 	best := math.MaxFloat64
-	ft.bestEpoch = 0
+	ft.bestEpoch, ft.sinceBest = 0, 0
+	ft.cost, ft.costFn = ft.nn.Cost(), ft.nn.CostFn()
+
+	if ft.classWts != nil {
+		if ft.nn.Obs() == nil {
+			return Wrapper(ErrNNModel, "class weights require a target")
+		}
+
+		if ft.nn.heads != nil {
+			return Wrapper(ErrNNModel, "class weights are not supported for multi-output models--use WeightedCrossEntropy in MultiCost")
+		}
+
+		if ft.nn.targetFT == nil || ft.nn.targetFT.Role != FROneHot || !ft.nn.softmaxOut() {
+			return Wrapper(ErrNNModel, "class weights require a one-hot target and a softmax output")
+		}
+
+		for class, w := range ft.classWts {
+			if class < 0 || class >= ft.nn.OutputCols() {
+				return Wrapper(ErrNNModel, fmt.Sprintf("class weight for class %d: model has %d classes", class, ft.nn.OutputCols()))
+			}
+
+			if w < 0.0 {
+				return Wrapper(ErrNNModel, fmt.Sprintf("class weight for class %d is negative", class))
+			}
+		}
 
-	if _, e := G.Grad(ft.nn.Cost(), ft.nn.Params()...); e != nil {
+		// the weighted cost belongs to the fit, so the cost of the model is not changed
+		ft.costFn = WeightedCrossEntropy(ft.classWts)
+		ft.cost = ft.costFn(ft.nn)
+	}
+
+	if _, e := G.Grad(ft.cost, ft.nn.Params()...); e != nil {
 		panic(e)
 	}
 
@@ -723,31 +1819,82 @@ func (ft *Fit) Do() (err error) {
 
 	t := time.Now()
 	itv := make([]float64, 0)
-	solv := G.NewAdamSolver()
+	solv := newAdam(adamLR)
+
+	if ft.l1Penalty < 0.0 || ft.l2Penalty < 0.0 {
+		return Wrapper(ErrNNModel, "regularization penalties cannot be negative")
+	}
 
-	if ft.l2Penalty > 0.0 {
-		G.WithL2Reg(ft.l2Penalty)(solv)
+	if ft.valMetric != "" && (Metric(ft.valMetric) == nil || ft.valPipe == nil) {
+		return Wrapper(ErrNNModel, fmt.Sprintf("validation metric %s: unknown metric or no validation Pipeline", ft.valMetric))
 	}
 
 	cv := make([]float64, 0)
 	cVal := make([]float64, 0)
 	cte := true
-	for ep := 1; ep <= ft.epochs && cte; ep++ {
+	lr := adamLR
+	ft.history = &FitHistory{}
+	start := 1
+
+	// pick up where the checkpoint left off
+	if ft.resume != "" {
+		state, e := loadFitState(ft.resume)
+		if e != nil {
+			return Wrapper(e, "Do: resume")
+		}
+
+		ft.resume = ""
+		solv, ft.history = state.Solver, state.History.history()
+		best, ft.bestEpoch, start = state.BestCost, state.BestEpoch, state.Epoch+1
+		ft.sinceBest = state.Wait
+
+		// the checkpointed fit had stopped early
+		if ft.valPipe != nil && ft.wait > 0 && ft.sinceBest > ft.wait {
+			cte = false
+		}
+
+		if !ft.outSet {
+			ft.outFile = state.OutFile
+		}
+
+		for ind, ep := range ft.history.Epoch {
+			itv = append(itv, float64(ep))
+			cv = append(cv, ft.history.InCost[ind])
+
+			if ft.valPipe != nil {
+				cVal = append(cVal, ft.history.ValCost[ind])
+			}
+		}
+	}
+
+	for ep := start; ep <= ft.epochs && cte; ep++ {
+		tEpoch := time.Now()
+		gNorm, nBatch := 0.0, 0
+
 		if ft.shuffle > 0 && ep%ft.shuffle == 0 {
 			ft.modelPipe.Shuffle()
 		}
 		// check for user specified learning rate
 		if ft.lrStart > 0.0 {
-			lr := ft.lrEnd + (ft.lrStart-ft.lrEnd)*(1.0-float64(ep)/float64(ft.epochs))
-			G.WithLearnRate(lr)(solv)
+			lr = ft.lrEnd + (ft.lrStart-ft.lrEnd)*(1.0-float64(ep)/float64(ft.epochs))
+			solv.Eta = lr
 		}
 		// run through batches in one epoch
 		for ft.modelPipe.Batch(ft.nn.Inputs()) {
+			if err = ft.nn.sampleDropouts(ft.random()); err != nil {
+				return
+			}
+
 			if err = vm.RunAll(); err != nil {
 				return
 			}
 
-			if err = solv.Step(G.NodesToValueGrads(ft.nn.Params())); err != nil {
+			gNorm += gradNorm(ft.nn.Params())
+			nBatch++
+
+			ft.regularize()
+
+			if err = solv.step(ft.nn.Params()); err != nil {
 				return
 			}
 
@@ -775,14 +1922,15 @@ func (ft *Fit) Do() (err error) {
 		ft.modelPipe.Epoch(ft.modelPipe.Epoch(-1) + 1)
 
 		itv = append(itv, float64(ep))
-		cv = append(cv, ft.nn.CostFlt())
+		cv = append(cv, ft.cost.Value().Data().(float64))
 
 		switch ft.valPipe == nil {
 		case true:
 			// judge best epoch by in-sample cost
+			ft.sinceBest++
 			if cv[len(cv)-1] < best {
 				best = cv[len(cv)-1]
-				ft.bestEpoch = ep
+				ft.bestEpoch, ft.sinceBest = ep, 0
 
 				if err = ft.nn.Save(ft.outFile); err != nil {
 					return
@@ -796,16 +1944,25 @@ func (ft *Fit) Do() (err error) {
 
 			var valMod *NNModel
 			// with a validation set, don't use dropouts
-			valMod, err = PredictNN(ft.tmpFile, ft.valPipe, false, WithCostFn(ft.nn.CostFn()))
+			valMod, err = PredictNN(ft.tmpFile, ft.valPipe, false, WithCostFn(ft.costFn))
 			if err != nil {
 				return
 			}
 
 			cVal = append(cVal, valMod.CostFlt())
-			// judge best epoch by validation cost
-			if cVal[len(cVal)-1] < best {
-				best = cVal[len(cVal)-1]
-				ft.bestEpoch = ep
+			valScore := cVal[len(cVal)-1]
+
+			if ft.valMetric != "" {
+				if valScore, err = ft.metricScore(valMod); err != nil {
+					return
+				}
+			}
+
+			// judge best epoch by validation cost (or metric)
+			ft.sinceBest++
+			if valScore < best {
+				best = valScore
+				ft.bestEpoch, ft.sinceBest = ep, 0
 
 				if err = ft.nn.Save(ft.outFile); err != nil {
 					return
@@ -813,10 +1970,33 @@ func (ft *Fit) Do() (err error) {
 			}
 
 			// check for early stopping
-			if ft.wait > 0 && ep-ft.bestEpoch > ft.wait {
+			if ft.wait > 0 && ft.sinceBest > ft.wait {
 				cte = false
 			}
 		}
+
+		valCost := math.NaN()
+		if len(cVal) > 0 {
+			valCost = cVal[len(cVal)-1]
+		}
+
+		ft.history.add(ep, cv[len(cv)-1], valCost, lr, gNorm/math.Max(float64(nBatch), 1.0), paramNorm(ft.nn.Params()),
+			time.Since(tEpoch).Seconds(), ft.bestEpoch == ep)
+
+		if ft.progress != nil {
+			_, _ = fmt.Fprintf(ft.progress, "epoch %d/%d: cost %0.6f, validation cost %0.6f, learning rate %0.6f, best epoch %d, elapsed %0.2f minutes\n",
+				ep, ft.epochs, cv[len(cv)-1], valCost, lr, ft.bestEpoch, time.Since(t).Minutes())
+		}
+
+		if ft.checkpoint != "" {
+			if err = ft.saveCheckpoint(ep, best, solv); err != nil {
+				return
+			}
+		}
+
+		if ft.epochCB != nil {
+			ft.epochCB(ep, cv[len(cv)-1], valCost)
+		}
 	}
 
 	elapsed := time.Since(t).Minutes()
@@ -829,9 +2009,18 @@ func (ft *Fit) Do() (err error) {
 	ft.inCosts, err = NewXY(itv, cv)
 	ft.outCosts, err = NewXY(itv, cVal)
 
+	// the card is made before the reload, which drops the options of the model
+	card := ft.ModelCard()
+
 	// load best epoch
 	ft.nn, _ = LoadNN(ft.outFile, ft.modelPipe, false)
 
+	if ft.card {
+		if err = card.Save(ft.outFile); err != nil {
+			return
+		}
+	}
+
 	// clean up
 	_ = os.Remove(ft.tmpFile + "P.nn")
 	_ = os.Remove(ft.tmpFile + "S.nn")
@@ -839,8 +2028,49 @@ func (ft *Fit) Do() (err error) {
 	return nil
 }
 
+// metricScore returns the validation metric (see WithValidationMetric) of valMod.  It is negated if larger values
+// are better, so that smaller scores are always better.
+func (ft *Fit) metricScore(valMod *NNModel) (float64, error) {
+	if valMod.targetFT == nil || valMod.Obs() == nil {
+		return 0, Wrapper(ErrNNModel, "validation metric requires a model with a single target")
+	}
+
+	trg, e := obsTarget(valMod.targetFT, ft.metricTrg)
+	if e != nil {
+		return 0, e
+	}
+
+	if trg == nil {
+		trg = []int{0}
+	}
+
+	fit, e := Coalesce(valMod.FitSlice(), valMod.OutputCols(), trg, false, false, nil)
+	if e != nil {
+		return 0, e
+	}
+
+	obs, e := Coalesce(valMod.ObsSlice(), valMod.Obs().Shape()[1], trg, valMod.targetFT.Role != FRCts, false, nil)
+	if e != nil {
+		return 0, e
+	}
+
+	score := Metric(ft.valMetric)(obs, fit)
+	if math.IsNaN(score) {
+		return 0, Wrapper(ErrNNModel, fmt.Sprintf("validation metric %s is NaN", ft.valMetric))
+	}
+
+	if MetricHigher(ft.valMetric) {
+		return -score, nil
+	}
+
+	return score, nil
+}
+
 // PredictNN reads in a NNModel from a file and populates it with a batch from p.
-// Methods such as FitSlice and ObsSlice are immediately available.
+// Methods such as FitSlice and ObsSlice are immediately available, as is Unseen, the count of categorical values
+// not in the Levels of their fields.
+// PredictNN advances the batch cursor of pipe.  To score the same Pipeline from several goroutines, give each its
+// own cursor with NewCursor.
 func PredictNN(fileRoot string, pipe Pipeline, build bool, opts ...NNOpts) (nn *NNModel, err error) {
 	nn, err = LoadNN(fileRoot, pipe, build)
 	if err != nil {
@@ -851,6 +2081,21 @@ func PredictNN(fileRoot string, pipe Pipeline, build bool, opts ...NNOpts) (nn *
 		o(nn)
 	}
 
+	nn.unseen = make(map[string]int)
+	for _, fld := range pipe.FieldList() {
+		if n := pipe.Get(fld).Summary.Unseen; n > 0 {
+			nn.unseen[fld] = n
+		}
+	}
+
+	if nn.padBatch {
+		if err = nn.predictAll(pipe); err != nil {
+			return nil, err
+		}
+
+		return nn, nil
+	}
+
 	for !pipe.Batch(nn.Inputs()) {
 	}
 
@@ -865,6 +2110,79 @@ func PredictNN(fileRoot string, pipe Pipeline, build bool, opts ...NNOpts) (nn *
 	return
 }
 
+// predictAll runs the model on all the rows of pipe, a batch at a time, and keeps the fitted and observed values.
+// The last batch is padded with copies of its last row, if need be, and the values of the padding are dropped.
+func (m *NNModel) predictAll(pipe Pipeline) error {
+	gd := pipe.GData()
+	nRow, bs := gd.Rows(), m.Features()[0].Shape()[0]
+
+	vm := G.NewTapeMachine(m.g)
+	defer func() { _ = vm.Close() }()
+
+	m.fitAll, m.obsAll = make([]float64, 0, nRow*m.outCols), nil
+	for _, h := range m.heads {
+		h.fitAll, h.obsAll = nil, nil
+	}
+
+	// keep appends the values of the first n rows of v to x
+	keep := func(x []float64, v G.Value, n int) []float64 {
+		if v == nil {
+			return x
+		}
+
+		vals := v.Data().([]float64)
+
+		return append(x, vals[:n*len(vals)/v.Shape()[0]]...)
+	}
+
+	for startRow := 0; startRow < nRow; startRow += bs {
+		n := nRow - startRow
+		if n > bs {
+			n = bs
+		}
+
+		for _, nd := range m.Inputs() {
+			d := gd.Get(nd.Name())
+			if d == nil {
+				return Wrapper(ErrNNModel, fmt.Sprintf("predictAll: feature %s not in pipeline", nd.Name()))
+			}
+
+			var e error
+			if n < bs {
+				e = letPadded(nd, d, startRow, bs)
+			} else {
+				e = letBatch(nd, d, startRow, startRow+bs, nil)
+			}
+
+			if e != nil {
+				return Wrapper(e, "predictAll")
+			}
+		}
+
+		if e := vm.RunAll(); e != nil {
+			return Wrapper(e, "predictAll")
+		}
+
+		m.fitAll = keep(m.fitAll, m.output.Nodes()[0].Value(), n)
+
+		if m.obs != nil && m.heads == nil {
+			m.obsAll = keep(m.obsAll, m.obs.Value(), n)
+		}
+
+		for _, h := range m.heads {
+			h.fitAll = keep(h.fitAll, h.fit, n)
+
+			if h.obs != nil {
+				h.obsAll = keep(h.obsAll, h.obs.Value(), n)
+			}
+		}
+
+		vm.Reset()
+	}
+
+	return nil
+}
+
 // PredictNNwFts creates a new Pipeline that updates the input pipe to have the FTypes specified by fts.
 // For instance, if one has normalized a continuous input, the normalization factor used in the NN must
 // be the same as its build values.  One should save the FTypes from the model build pass them here.
@@ -874,22 +2192,11 @@ func PredictNNwFts(fileRoot string, pipe Pipeline, build bool, fts FTypes, opts
 		return PredictNN(fileRoot, pipe, build, opts...)
 	}
 
-	gd := pipe.GData()
-	newGd, e := gd.UpdateFts(fts)
+	newGd, e := NewPreprocessor(fts).GData(pipe.GData())
 	if e != nil {
 		return nil, e
 	}
 
-	// if something is in here as a FRCat or FREmbed then we need to add a one-hot field
-	for _, fld := range newGd.FieldList() {
-		ft := newGd.Get(fld).FT
-		if ft.Role == FRCat || ft.Role == FREmbed {
-			if e := newGd.MakeOneHot(ft.Name, ft.Name+"Oh"); e != nil {
-				return nil, e
-			}
-		}
-	}
-
 	vecPipe := NewVecData("predict with FTypes", newGd, WithBatchSize(pipe.BatchSize()))
 
 	return PredictNN(fileRoot, vecPipe, build, opts...)
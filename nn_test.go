@@ -1,16 +1,21 @@
 package seafan
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/invertedv/utilities"
 	"math"
+	"math/rand"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/invertedv/chutils"
 	"github.com/invertedv/chutils/file"
 	"github.com/stretchr/testify/assert"
 	"gonum.org/v1/gonum/stat"
+	G "gorgonia.org/gorgonia"
 )
 
 func chPipe(bSize int, fileName string) *ChData {
@@ -48,6 +53,91 @@ func chPipe(bSize int, fileName string) *ChData {
 	return ch
 }
 
+// synthData seeds the package generator with seed and returns n rows of synthetic data: the continuous fields xs,
+// uniform on [0,1), and the fields ys, the values y returns for the xs of the row.  Each row draws the xs in order
+// from newRand(seed) and then calls y, which may draw more.  String fields of ys are FRCat, the rest FRCts.
+func synthData(t *testing.T, seed int64, n int, xs, ys []string, y func(x []float64, rnd *rand.Rand) []any) *GData {
+	SetSeed(seed)
+
+	rnd := newRand(seed)
+	xCols, yCols := make([][]any, len(xs)), make([][]any, len(ys))
+
+	for row := 0; row < n; row++ {
+		x := make([]float64, len(xs))
+		for ind := range x {
+			x[ind] = rnd.Float64()
+			xCols[ind] = append(xCols[ind], x[ind])
+		}
+
+		for ind, v := range y(x, rnd) {
+			yCols[ind] = append(yCols[ind], v)
+		}
+	}
+
+	gd := NewGData()
+	for ind, name := range xs {
+		assert.Nil(t, gd.AppendC(NewRaw(xCols[ind], nil), name, false, nil, false))
+	}
+
+	for ind, name := range ys {
+		raw := NewRaw(yCols[ind], nil)
+		if raw.Kind == reflect.String {
+			assert.Nil(t, gd.AppendD(raw, name, nil, false))
+			continue
+		}
+
+		assert.Nil(t, gd.AppendC(raw, name, false, nil, false))
+	}
+
+	return gd
+}
+
+// fitModel fits nn to pipe for epochs.  The files of the fit are removed when the test ends.
+func fitModel(t *testing.T, nn *NNModel, epochs int, pipe Pipeline, opts ...FitOpts) *Fit {
+	ft := NewFit(nn, epochs, pipe, opts...)
+	assert.Nil(t, ft.Do())
+
+	t.Cleanup(func() {
+		for _, suffix := range []string{"P.nn", "S.nn", "O.nn", "M.json"} {
+			_ = os.Remove(ft.OutFile() + suffix)
+		}
+	})
+
+	return ft
+}
+
+// hazardData returns the exposure and the event of a row with event rate exp(-1 + x) per unit of exposure (see
+// synthData)
+func hazardData(x []float64, rnd *rand.Rand) []any {
+	expo, event := 0.5+0.5*rnd.Float64(), 0.0
+	if rnd.Float64() < 1.0-math.Exp(-math.Exp(-1.0+x[0])*expo) {
+		event = 1
+	}
+
+	return []any{expo, event}
+}
+
+// stateData returns y and orig, a state, for a row with y = x + the length of orig (see synthData)
+func stateData(x []float64, rnd *rand.Rand) []any {
+	orig := []string{"CA", "NY", "TX", "FL"}[rnd.Intn(4)]
+
+	return []any{x[0] + float64(len(orig)), orig}
+}
+
+// scorerModel seeds the package generator with seed and fits a softmax model of yoh on x1, x2, x3 and x4oh to
+// scorerPipe for 2 epochs.  It returns the pipeline and the root of the model files.
+func scorerModel(t *testing.T, seed int64) (pipe *ChData, root string) {
+	SetSeed(seed)
+	pipe = scorerPipe(t, 100)
+
+	mod := ModSpec{"Input(x1+x2+x3+x4oh)", "FC(size:3, activation:relu)", "FC(size:2, activation:softmax)", "Target(yoh)"}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+	assert.Nil(t, e)
+
+	return pipe, fitModel(t, nn, 2, pipe).OutFile()
+}
+
 func TestNNModel_Save(t *testing.T) {
 	Verbose = false
 	pipe := chPipe(100, "test1.csv")
@@ -118,6 +208,639 @@ func TestFit_Do(t *testing.T) {
 	}
 }
 
+func TestWithL1Reg(t *testing.T) {
+	Verbose = false
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:5, activation:relu)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	// sumAbs returns the sum of the absolute values of the weights after fitting
+	sumAbs := func(opts ...FitOpts) (w float64) {
+		SetSeed(5)
+		pipe := chPipe(100, "test1.csv")
+		nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+		assert.Nil(t, e)
+
+		ft := NewFit(nn, 5, pipe, append(opts, WithFitSeed(5))...)
+		assert.Nil(t, ft.Do())
+
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+
+		for _, n := range ft.NNModel().paramsW {
+			for _, x := range n.Value().Data().([]float64) {
+				w += math.Abs(x)
+			}
+		}
+
+		return w
+	}
+
+	w0 := sumAbs()
+	w1 := sumAbs(WithL1Reg(1.0))
+	assert.Less(t, w1, w0)
+
+	w2 := sumAbs(WithElasticNet(1.0, 1.0))
+	assert.Less(t, w2, w0)
+
+	// excluding the biases leaves the weights penalized
+	w3 := sumAbs(WithL1Reg(1.0), WithRegExclude(true, true))
+	assert.Less(t, w3, w0)
+
+	pipe := chPipe(100, "test1.csv")
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.NotNil(t, NewFit(nn, 1, pipe, WithL1Reg(-1)).Do())
+}
+
+func TestNNModel_Offset(t *testing.T) {
+	Verbose = false
+
+	// event rate exp(-1 + x) per unit of exposure
+	const n = 4000
+
+	gd := synthData(t, 13, n, []string{"x"}, []string{"expo", "event"}, hazardData)
+
+	x, expo := gd.Get("x").Floats(), gd.Get("expo").Floats()
+	logExpo := make([]float64, len(expo))
+	for ind, ex := range expo {
+		logExpo[ind] = math.Log(ex)
+	}
+
+	assert.Nil(t, gd.AppendC(NewRawCast(logExpo, nil), "logExpo", false, nil, false))
+	pipe := NewVecData("offset", gd, WithBatchSize(100))
+
+	mod := ModSpec{
+		"Input(x)",
+		"Offset(logExpo)",
+		"FC(size:1)",
+		"Target(event)",
+	}
+	assert.Equal(t, "logExpo", mod.OffsetName())
+
+	// with log exposure as an offset, the Hazard cost has the same gradients as with exposure in the Target
+	fits := make([]*Fit, 0)
+
+	for ind, m := range []ModSpec{mod, {"Input(x)", "FC(size:1)", "Target(event, expo)"}} {
+		SetSeed(13)
+		nn, e := NewNNModel(m, pipe, true, WithCostFn(Hazard))
+		assert.Nil(t, e)
+
+		fits = append(fits, fitModel(t, nn, 10, pipe, WithLearnRate(0.02, 0.002), WithFitSeed(int64(13+ind))))
+	}
+
+	nn := fits[0].NNModel()
+	assert.NotNil(t, nn.Offset())
+	assert.Equal(t, 3, len(nn.Inputs()))
+	assert.Contains(t, nn.dot(), "\"offset\" -> \"layer2\"")
+
+	// the offset is not a parameter
+	assert.Equal(t, 2, len(nn.Params()))
+
+	w, b := nn.paramsW[0].Value().Data().([]float64)[0], nn.paramsB[0].Value().Data().([]float64)[0]
+	assert.InDelta(t, fits[1].NNModel().paramsW[0].Value().Data().([]float64)[0], w, 1e-8)
+	assert.InDelta(t, fits[1].NNModel().paramsB[0].Value().Data().([]float64)[0], b, 1e-8)
+
+	// the offset is needed to predict
+	pipe = NewVecData("offset", gd, WithBatchSize(n))
+	pred, e := PredictNN(fits[0].OutFile(), pipe, false)
+	assert.Nil(t, e)
+
+	fit := pred.FitSlice()
+	for row := 0; row < n; row += 500 {
+		assert.InDelta(t, w*x[row]+b+logExpo[row], fit[row], 1e-8)
+	}
+
+	sc, e := NewScorer(fits[0].OutFile(), pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	score, e := sc.Score(pipe)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, fit, score, 1e-8)
+
+	row, e := sc.ScoreRow(map[string]any{"x": x[7], "logExpo": logExpo[7]})
+	assert.Nil(t, e)
+	assert.InDelta(t, fit[7], row[0], 1e-8)
+
+	_, e = sc.ScoreRow(map[string]any{"x": x[7]})
+	assert.NotNil(t, e)
+
+	// the offset must not be normalized
+	assert.Nil(t, gd.AppendC(NewRawCast(logExpo, nil), "logExpoN", true, nil, false))
+	_, e = NewNNModel(ModSpec{"Input(x)", "Offset(logExpoN)", "FC(size:1)", "Target(event)"}, pipe, true)
+	assert.NotNil(t, e)
+}
+
+func TestNNModel_SharedEmbed(t *testing.T) {
+	Verbose = false
+	SetSeed(19)
+
+	const n = 1000
+
+	rnd := newRand(19)
+	states := []string{"CA", "NY", "TX", "FL"}
+	x, y := make([]float64, n), make([]float64, n)
+	orig, curr, other := make([]string, n), make([]string, n), make([]string, n)
+
+	for ind := 0; ind < n; ind++ {
+		x[ind] = rnd.Float64()
+		orig[ind], curr[ind] = states[rnd.Intn(4)], states[rnd.Intn(4)]
+		other[ind] = states[rnd.Intn(3)]
+		y[ind] = x[ind] + float64(len(orig[ind])+len(curr[ind]))
+	}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast(x, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast(y, nil), "y", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRawCast(orig, nil), "orig", nil, false))
+
+	// curr uses the levels of orig
+	assert.Nil(t, gd.AppendD(NewRawCast(curr, nil), "curr", gd.Get("orig").FT.FP, false))
+	assert.Nil(t, gd.AppendD(NewRawCast(other, nil), "other", nil, false))
+
+	for _, f := range []string{"orig", "curr", "other"} {
+		assert.Nil(t, gd.MakeOneHot(f, f+"Oh"))
+	}
+
+	pipe := NewVecData("shared", gd, WithBatchSize(100))
+
+	mod := ModSpec{
+		"Input(x+E(origOh,2,share:geo)+E(currOh,2,share:geo))",
+		"FC(size:1)",
+		"Target(y)",
+	}
+	assert.Equal(t, "geoEmbed", mod.EmbedName("currOh"))
+	assert.Equal(t, "xEmbed", mod.EmbedName("x"))
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(nn.paramsEmb))
+	assert.Equal(t, []int{4, 2}, []int(nn.paramsEmb[0].Shape()))
+	assert.Contains(t, nn.String(), "8 Embedding parameters")
+
+	ft := NewFit(nn, 5, pipe, WithFitSeed(19))
+	assert.Nil(t, ft.Do())
+
+	defer func() {
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+	}()
+
+	// file names do not depend on the seed
+	assert.NotEqual(t, ft.OutFile(), NewFit(nn, 5, pipe, WithFitSeed(19)).OutFile())
+
+	pipe = NewVecData("shared", gd, WithBatchSize(n))
+	pred, e := PredictNN(ft.OutFile(), pipe, false)
+	assert.Nil(t, e)
+
+	sc, e := NewScorer(ft.OutFile(), pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	score, e := sc.Score(pipe)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pred.FitSlice(), score, 1e-8)
+
+	// the inputs must share a vocabulary
+	_, e = NewNNModel(ModSpec{"Input(x+E(origOh,2,share:geo)+E(otherOh,2,share:geo))", "FC(size:1)", "Target(y)"},
+		pipe, true)
+	assert.NotNil(t, e)
+
+	_, e = NewNNModel(ModSpec{"Input(x+E(origOh,2,share:geo)+E(currOh,3,share:geo))", "FC(size:1)", "Target(y)"},
+		pipe, true)
+	assert.NotNil(t, e)
+
+	_, e = NewNNModel(ModSpec{"Input(x+E(origOh,2,geo))", "FC(size:1)", "Target(y)"}, pipe, true)
+	assert.NotNil(t, e)
+}
+
+func TestNNModel_EmbedCat(t *testing.T) {
+	Verbose = false
+
+	const n = 200
+
+	gd := synthData(t, 23, n, []string{"x"}, []string{"y", "orig"}, stateData)
+	assert.Nil(t, gd.MakeOneHot("orig", "origOh"))
+
+	pipe := NewVecData("embed", gd, WithBatchSize(n))
+
+	// the categorical field needs an embedding
+	_, e := NewNNModel(ModSpec{"Input(x+orig)", "FC(size:1)", "Target(y)"}, pipe, false)
+	assert.NotNil(t, e)
+
+	nnOh, e := NewNNModel(ModSpec{"Input(x+E(origOh,2))", "FC(size:1)", "Target(y)"}, pipe, false)
+	assert.Nil(t, e)
+
+	nnCat, e := NewNNModel(ModSpec{"Input(x+E(orig,2))", "FC(size:1)", "Target(y)"}, pipe, false)
+	assert.Nil(t, e)
+	assert.Equal(t, []int{4, 2}, []int(nnCat.paramsEmb[0].Shape()))
+
+	// with the same parameters, the models agree
+	for ind, p := range nnOh.Params() {
+		copy(nnCat.Params()[ind].Value().Data().([]float64), p.Value().Data().([]float64))
+	}
+
+	for _, nn := range []*NNModel{nnOh, nnCat} {
+		cur, e := NewCursor(pipe)
+		assert.Nil(t, e)
+		assert.True(t, cur.Batch(nn.Inputs()))
+
+		vm := G.NewTapeMachine(nn.G())
+		assert.Nil(t, vm.RunAll())
+		_ = vm.Close()
+	}
+
+	assert.InDeltaSlice(t, nnOh.FitSlice(), nnCat.FitSlice(), 1e-10)
+}
+
+func TestNNModel_InputGroups(t *testing.T) {
+	Verbose = false
+
+	const n = 200
+
+	gd := synthData(t, 7, n, []string{"x1", "x2", "x3"}, []string{"y"}, func(x []float64, _ *rand.Rand) []any {
+		return []any{x[0]*x[1] + x[2]}
+	})
+	pipe := NewVecData("groups", gd, WithBatchSize(n))
+
+	mod := ModSpec{"Input(g1:(x1+x2), g2:(x3))", "FC(size:4, activation:relu)", "FC(size:1)", "Target(y)"}
+	groups, e := mod.InputGroups()
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"g1", "g1", "g2"}, groups)
+	assert.Nil(t, mod.Validate(pipe))
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	fitModel(t, nn, 5, pipe, WithFitSeed(7))
+
+	// x1 and x2 feed columns 0 and 1 of the first layer, x3 feeds columns 2 and 3
+	w := GetNode(nn.paramsW, "lWeights1").Value().Data().([]float64)
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 4; col++ {
+			assert.Equal(t, (row < 2) != (col < 2), w[row*4+col] == 0.0)
+		}
+	}
+
+	// ungrouped inputs
+	groups, e = ModSpec{"Input(x1+x2)", "FC(size:1)", "Target(y)"}.InputGroups()
+	assert.Nil(t, e)
+	assert.Nil(t, groups)
+
+	// the first layer needs a column for each group
+	_, e = NewNNModel(ModSpec{"Input(g1:(x1+x2), g2:(x3))", "FC(size:1)", "FC(size:1)", "Target(y)"}, pipe, true)
+	assert.NotNil(t, e)
+
+	_, e = NewNNModel(ModSpec{"Input(g1:(x1+x2), g1:(x3))", "FC(size:2)", "FC(size:1)", "Target(y)"}, pipe, true)
+	assert.NotNil(t, e)
+	assert.NotNil(t, ModSpec{"Input(g1:(x1+x2), x3)", "FC(size:2)", "FC(size:1)", "Target(y)"}.Validate(pipe))
+}
+
+func TestNNModel_InputNoise(t *testing.T) {
+	Verbose = false
+
+	gd := synthData(t, 5, 200, []string{"x1", "x2"}, []string{"y"}, func(x []float64, _ *rand.Rand) []any {
+		return []any{x[0] + x[1]}
+	})
+	pipe := NewVecData("noise", gd, WithBatchSize(50))
+
+	mod, e := NewModSpec().Input("x1", "x2").InputDropout(0.1).GaussianNoise(0.05).FC(3, Relu).FC(1, Linear).
+		Target("y").Build()
+	assert.Nil(t, e)
+	assert.Equal(t, "GaussianNoise(0.05)", mod[2])
+	assert.Nil(t, mod.Validate(pipe))
+	assert.Equal(t, 0.05, mod.GaussianNoise(2).Sigma)
+	assert.Equal(t, 0.1, mod.InputDropout(1).DropProb)
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(nn.dropMasks))
+	assert.Equal(t, 1, len(nn.noise))
+	assert.Equal(t, []int{50, 2}, []int(nn.noise[0].Shape()))
+
+	fitModel(t, nn, 3, pipe, WithFitSeed(5))
+
+	// the noise is applied in build mode only
+	nn, e = NewNNModel(mod, pipe, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 0, len(nn.dropMasks)+len(nn.noise))
+
+	// the noise must precede the FC layers
+	bad := ModSpec{"Input(x1+x2)", "FC(size:3)", "GaussianNoise(0.1)", "FC(size:1)", "Target(y)"}
+	assert.NotNil(t, bad.Validate(pipe))
+
+	_, e = NewNNModel(bad, pipe, true)
+	assert.NotNil(t, e)
+
+	assert.NotNil(t, ModSpec{"Input(x1+x2)", "GaussianNoise(-1)", "FC(size:1)", "Target(y)"}.Validate(pipe))
+}
+
+func TestMultiCost(t *testing.T) {
+	Verbose = false
+
+	const n = 2000
+
+	gd := synthData(t, 11, n, []string{"x1", "x2"}, []string{"y1", "y2"}, func(x []float64, _ *rand.Rand) []any {
+		return []any{x[0] + x[1], x[0] - 2.0*x[1]}
+	})
+	y2 := gd.Get("y2").Floats()
+	pipe := NewVecData("multi", gd, WithBatchSize(100))
+
+	mod := ModSpec{
+		"Input(x1+x2)",
+		"FC(size:8, activation:leakyrelu(0.1))",
+		"Output(target:y1)",
+		"Output(target:y2, weight:2)",
+	}
+
+	cf := MultiCost(map[string]CostFunc{"y1": RMS, "y2": RMS})
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(cf))
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"y1", "y2"}, nn.Heads())
+	assert.Equal(t, 2, nn.OutputCols())
+	assert.Equal(t, 4, len(nn.Inputs()))
+	assert.Nil(t, nn.Head("x1"))
+	assert.Equal(t, "MultiCost", nn.Cost().Name())
+	assert.Contains(t, nn.dot(), "Output 3|Linear|(100, 1)|9 parameters|weight 2")
+
+	ft := fitModel(t, nn, 30, pipe, WithLearnRate(0.02, 0.002), WithFitSeed(11))
+
+	WithBatchSize(n)(pipe)
+	pred, e := PredictNN(ft.OutFile(), pipe, false, WithCostFn(cf))
+	assert.Nil(t, e)
+
+	// the model cost is the weighted sum of the head costs
+	c1, c2 := pred.Head("y1").CostFlt(), pred.Head("y2").CostFlt()
+	assert.InDelta(t, c1+2.0*c2, pred.CostFlt(), 1e-8)
+	assert.Less(t, c1, 0.1)
+	assert.Less(t, c2, 0.1)
+
+	// the model output is the heads side by side
+	fit, fit2 := pred.FitSlice(), pred.Head("y2").FitSlice()
+	assert.Equal(t, 2*n, len(fit))
+	assert.Equal(t, n, len(fit2))
+
+	for row := 0; row < n; row += 100 {
+		assert.Equal(t, fit2[row], fit[2*row+1])
+		assert.InDelta(t, y2[row], fit2[row], 0.3)
+	}
+
+	// head sizes must match their targets
+	_, e = NewNNModel(ModSpec{"Input(x1)", "FC(size:2)", "Output(target:y1, size:2)"}, pipe, true)
+	assert.NotNil(t, e)
+}
+
+func TestPinball(t *testing.T) {
+	Verbose = false
+
+	const n = 4000
+
+	gd := synthData(t, 17, n, []string{"x"}, []string{"y"}, func(x []float64, rnd *rand.Rand) []any {
+		return []any{x[0] + 0.5*rnd.NormFloat64()}
+	})
+	y := gd.Get("y").Floats()
+	pipe := NewVecData("quantiles", gd, WithBatchSize(100))
+
+	mod := ModSpec{
+		"Input(x)",
+		"FC(size:4, activation:leakyrelu(0.1))",
+		"Output(y, quantiles:[0.1,0.5,0.9])",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(Pinball))
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{0.1, 0.5, 0.9}, nn.Quantiles())
+	assert.Equal(t, 3, nn.OutputCols())
+
+	ft := fitModel(t, nn, 30, pipe, WithLearnRate(0.02, 0.002), WithFitSeed(17))
+
+	pipe = NewVecData("quantiles", gd, WithBatchSize(n))
+	pred, e := PredictNN(ft.OutFile(), pipe, false)
+	assert.Nil(t, e)
+
+	// the columns are the quantiles in order, they don't cross and they cover about the right share of y
+	fit := pred.FitSlice()
+	below := make([]float64, 3)
+
+	for row := 0; row < n; row++ {
+		q := fit[row*3 : row*3+3]
+		assert.LessOrEqual(t, q[0], q[1])
+		assert.LessOrEqual(t, q[1], q[2])
+
+		for col := 0; col < 3; col++ {
+			if y[row] <= q[col] {
+				below[col] += 1.0 / n
+			}
+		}
+	}
+
+	assert.InDelta(t, 0.1, below[0], 0.04)
+	assert.InDelta(t, 0.5, below[1], 0.04)
+	assert.InDelta(t, 0.9, below[2], 0.04)
+
+	// a head can be fed by the inputs directly
+	nn, e = NewNNModel(ModSpec{"Input(x)", "Output(y, quantiles:[0.25,0.75])"}, pipe, true, WithCostFn(Pinball))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, nn.OutputCols())
+}
+
+func TestHazard(t *testing.T) {
+	Verbose = false
+
+	// event rate exp(-1 + x) per unit of exposure
+	gd := synthData(t, 9, 4000, []string{"x"}, []string{"expo", "event"}, hazardData)
+	expo := gd.Get("expo").Floats()
+	pipe := NewVecData("hazard", gd, WithBatchSize(100))
+
+	mod := ModSpec{
+		"Input(x)",
+		"FC(size:1)",
+		"Target(event, expo)",
+	}
+	assert.Equal(t, "event", mod.TargetName())
+	assert.Equal(t, "expo", mod.ExposureName())
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(Hazard))
+	assert.Nil(t, e)
+	assert.NotNil(t, nn.Exposure())
+	assert.Equal(t, 3, len(nn.Inputs()))
+
+	ft := fitModel(t, nn, 40, pipe, WithLearnRate(0.02, 0.002), WithFitSeed(9))
+
+	// the fitted log hazard is about -1 + x
+	w := ft.NNModel().paramsW[0].Value().Data().([]float64)[0]
+	b := ft.NNModel().paramsB[0].Value().Data().([]float64)[0]
+	assert.InDelta(t, 1.0, w, 0.3)
+	assert.InDelta(t, -1.0, b, 0.2)
+
+	// the exposure must not be normalized
+	assert.Nil(t, gd.AppendC(NewRawCast(expo, nil), "expoN", true, nil, false))
+	_, e = NewNNModel(ModSpec{"Input(x)", "FC(size:1)", "Target(event, expoN)"}, pipe, true)
+	assert.NotNil(t, e)
+}
+
+func TestWithClassWeights(t *testing.T) {
+	Verbose = false
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:2, activation:softmax)",
+		"Target(yoh)",
+	}
+
+	// meanP0 returns the average fitted probability of class 0 on the validation data
+	meanP0 := func(opts ...FitOpts) float64 {
+		pipe := chPipe(100, "test1.csv")
+		nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+		assert.Nil(t, e)
+
+		ft := NewFit(nn, 20, pipe, opts...)
+		assert.Nil(t, ft.Do())
+
+		pred, e := PredictNN(ft.OutFile(), chPipe(1000, "testVal.csv"), false)
+		assert.Nil(t, e)
+
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+
+		fit := pred.FitSlice()
+		p0 := 0.0
+
+		for ind := 0; ind < len(fit); ind += 2 {
+			p0 += fit[ind]
+		}
+
+		return p0 / float64(len(fit)/2)
+	}
+
+	// up-weighting class 0 increases its fitted probability
+	assert.Greater(t, meanP0(WithClassWeights(map[int]float64{0: 5})), meanP0())
+
+	pipe := chPipe(100, "test1.csv")
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+	assert.Nil(t, e)
+	assert.NotNil(t, NewFit(nn, 1, pipe, WithClassWeights(map[int]float64{2: 5})).Do())
+
+	// the model keeps its cost function
+	assert.Nil(t, NewFit(nn, 1, pipe, WithClassWeights(map[int]float64{0: 5})).Do())
+	assert.Equal(t, "CrossEntropy", nn.Cost().Name())
+
+	// class weights need a one-hot target
+	cts, e := NewNNModel(ModSpec{"Input(x1+x2+x3)", "FC(size:1)", "Target(ycts)"}, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.NotNil(t, NewFit(cts, 1, pipe, WithClassWeights(map[int]float64{0: 5})).Do())
+}
+
+func TestSetSeed(t *testing.T) {
+	Verbose = false
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:3, activation:relu)",
+		"DropOut(.1)",
+		"FC(size:2, activation:softmax)",
+		"Target(yoh)",
+	}
+
+	// fitWeights returns the fitted weights of the first layer
+	fitWeights := func() []float64 {
+		SetSeed(42)
+
+		pipe := chPipe(100, "test1.csv")
+		WithSeed(7)(pipe)
+
+		nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+		assert.Nil(t, e)
+
+		ft := NewFit(nn, 3, pipe, WithShuffle(1), WithFitSeed(11))
+		assert.Nil(t, ft.Do())
+
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+
+		return ft.NNModel().G().ByName("lWeights1").Nodes()[0].Value().Data().([]float64)
+	}
+
+	assert.Equal(t, fitWeights(), fitWeights())
+}
+
+func TestWithEpochCallback(t *testing.T) {
+	Verbose = false
+	pipe := chPipe(100, "test1.csv")
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	var (
+		eps      []int
+		valCosts []float64
+		progress bytes.Buffer
+	)
+
+	cb := func(ep int, inCost, valCost float64) {
+		eps = append(eps, ep)
+		valCosts = append(valCosts, valCost)
+	}
+
+	ft := NewFit(nn, 3, pipe, WithValidation(chPipe(1000, "testVal.csv"), 0), WithEpochCallback(cb), WithProgress(&progress))
+	assert.Nil(t, ft.Do())
+
+	_ = os.Remove(ft.OutFile() + "P.nn")
+	_ = os.Remove(ft.OutFile() + "S.nn")
+
+	assert.Equal(t, []int{1, 2, 3}, eps)
+	assert.Equal(t, ft.OutCosts().Y, valCosts)
+	assert.Equal(t, 3, strings.Count(progress.String(), "\n"))
+	assert.True(t, strings.HasPrefix(progress.String(), "epoch 1/3: cost"))
+}
+
+func TestFit_History(t *testing.T) {
+	Verbose = false
+	pipe := chPipe(100, "test1.csv")
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	ft := NewFit(nn, 3, pipe, WithLearnRate(0.01, 0.001))
+	assert.Nil(t, ft.Do())
+
+	_ = os.Remove(ft.OutFile() + "P.nn")
+	_ = os.Remove(ft.OutFile() + "S.nn")
+
+	h := ft.History()
+	assert.Equal(t, 3, h.Len())
+	assert.Equal(t, []int{1, 2, 3}, h.Epoch)
+	assert.Equal(t, ft.InCosts().Y, h.InCost)
+	assert.True(t, math.IsNaN(h.ValCost[0]))
+	assert.True(t, h.Best[0])
+	assert.Greater(t, h.LearnRate[0], h.LearnRate[2])
+	assert.Greater(t, h.GradNorm[0], 0.0)
+	assert.Greater(t, h.ParamNorm[0], 0.0)
+
+	hPipe, e := h.ToPipe()
+	assert.Nil(t, e)
+	assert.Equal(t, 3, hPipe.Rows())
+	assert.Equal(t, FRCts, hPipe.GetFType("best").Role)
+
+	fileName := os.TempDir() + "/history.csv"
+	assert.Nil(t, h.ToCSV(fileName))
+	defer func() { _ = os.Remove(fileName) }()
+
+	csvPipe, e := CSVToPipe(fileName, nil, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, csvPipe.Rows())
+}
+
 func ExampleWithOneHot() {
 	// This example shows a model that incorporates a feature (x4) as one-hot and an embedding
 	Verbose = false
@@ -481,3 +1204,54 @@ func ExampleWithCallBack() {
 	//New data at end of epoch  100
 	//Number of rows  1000
 }
+
+func TestPredictNN_PadBatch(t *testing.T) {
+	Verbose = false
+	pipe, root := scorerModel(t, 7)
+
+	bSize := pipe.BatchSize()
+	defer WithBatchSize(bSize)(pipe)
+
+	WithBatchSize(pipe.Rows())(pipe)
+	all, e := PredictNN(root, pipe, false)
+	assert.Nil(t, e)
+
+	// 30 does not divide 100, so the last batch has 20 rows of padding
+	WithBatchSize(30)(pipe)
+	padded, e := PredictNN(root, pipe, false, WithPadBatch())
+	assert.Nil(t, e)
+	assert.Equal(t, 2*pipe.Rows(), len(padded.FitSlice()))
+	assert.InDeltaSlice(t, all.FitSlice(), padded.FitSlice(), 1e-10)
+	assert.Equal(t, all.ObsSlice(), padded.ObsSlice())
+
+	// without padding, only the first batch is scored
+	first, e := PredictNN(root, pipe, false)
+	assert.Nil(t, e)
+	assert.Equal(t, 2*30, len(first.FitSlice()))
+}
+
+func TestWithValidationMetric(t *testing.T) {
+	Verbose = false
+	SetSeed(11)
+	pipe := scorerPipe(t, 100)
+
+	mod := ModSpec{"Input(x1+x2+x3+x4oh)", "FC(size:3, activation:relu)", "FC(size:2, activation:softmax)", "Target(yoh)"}
+
+	for _, metric := range []string{"AUC", "LogLoss"} {
+		nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+		assert.Nil(t, e)
+
+		ft := NewFit(nn, 5, pipe, WithValidation(pipe, 0), WithValidationMetric(metric))
+		assert.Nil(t, ft.Do())
+		assert.Greater(t, ft.BestEpoch(), 0)
+
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+	assert.Nil(t, e)
+
+	assert.NotNil(t, NewFit(nn, 5, pipe, WithValidationMetric("AUC")).Do())
+	assert.NotNil(t, NewFit(nn, 5, pipe, WithValidation(pipe, 0), WithValidationMetric("nope")).Do())
+}
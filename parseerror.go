@@ -0,0 +1,184 @@
+package seafan
+
+// parseerror.go reports where problems are in expressions and suggests fixes for misspellings
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseError is a problem with an expression found by Expr2Tree, Evaluate or Compile.
+type ParseError struct {
+	Expr    string // the expression, as given
+	Sub     string // the sub-expression with the problem
+	Offset  int    // offset of Sub in Expr, -1 if not known
+	Msg     string // description of the problem
+	Suggest string // a near-miss function or field name, if there is one
+}
+
+func (pe *ParseError) Error() string {
+	msg := pe.Msg
+
+	if pe.Sub != "" {
+		msg = fmt.Sprintf("%s in %q", msg, pe.Sub)
+	}
+
+	if pe.Offset >= 0 {
+		msg = fmt.Sprintf("%s at offset %d of %q", msg, pe.Offset, pe.Expr)
+	}
+
+	if pe.Suggest != "" {
+		msg = fmt.Sprintf("%s; did you mean %s?", msg, pe.Suggest)
+	}
+
+	return msg
+}
+
+// Unwrap returns ErrParser
+func (pe *ParseError) Unwrap() error {
+	return ErrParser
+}
+
+// newParseError returns a *ParseError for sub.  The location is filled in by locate.
+func newParseError(sub, msg, suggest string) *ParseError {
+	return &ParseError{Sub: sub, Offset: -1, Msg: msg, Suggest: suggest}
+}
+
+// locate fills in the expression and the offset of a *ParseError in e. source is the expression as given.
+// Expressions are parsed without spaces, so sub-expressions are found in source ignoring spaces outside quotes.
+func locate(source string, e error) error {
+	var pe *ParseError
+	if !errors.As(e, &pe) || pe.Offset >= 0 {
+		return e
+	}
+
+	pe.Expr = source
+
+	// positions in source of the characters that remain after spaces are removed
+	var (
+		stripped []byte
+		pos      []int
+	)
+
+	inQuote := false
+
+	for ind := 0; ind < len(source); ind++ {
+		if source[ind] == '\'' {
+			inQuote = !inQuote
+		}
+
+		if source[ind] == ' ' && !inQuote {
+			continue
+		}
+
+		stripped = append(stripped, source[ind])
+		pos = append(pos, ind)
+	}
+
+	if pe.Sub == "" {
+		return pe
+	}
+
+	if ind := strings.Index(string(stripped), pe.Sub); ind >= 0 {
+		pe.Offset = pos[ind]
+	}
+
+	return pe
+}
+
+// suggest returns the element of candidates nearest to name, if it's a plausible misspelling.  Otherwise, it
+// returns "".
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", len(name)
+
+	// allow one edit for short names, two for longer ones
+	limit := 1
+	if len(name) > 4 {
+		limit = 2
+	}
+
+	for _, cand := range candidates {
+		if dist := editDistance(strings.ToLower(name), strings.ToLower(cand)); dist <= limit && dist < bestDist {
+			best, bestDist = cand, dist
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and transpositions of adjacent
+// characters needed to change a to b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+
+	for j := 0; j <= len(b); j++ {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+// functionNames returns the names of the functions the parser supports
+func functionNames() []string {
+	var names []string
+
+	for _, fn := range Functions {
+		// skip operations
+		if strings.ContainsAny(fn.Name[:1], "+-*/^&|<>=!") {
+			continue
+		}
+
+		names = append(names, fn.Name)
+	}
+
+	return names
+}
+
+// unmatchedParen returns the position of the first unmatched parenthesis of expr outside quotes, -1 if there is none
+func unmatchedParen(expr string) int {
+	var open []int
+
+	inQuote := false
+
+	for ind := 0; ind < len(expr); ind++ {
+		switch {
+		case expr[ind] == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case expr[ind] == '(':
+			open = append(open, ind)
+		case expr[ind] == ')':
+			if len(open) == 0 {
+				return ind
+			}
+
+			open = open[:len(open)-1]
+		}
+	}
+
+	if len(open) > 0 {
+		return open[0]
+	}
+
+	return -1
+}
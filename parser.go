@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
 
+	"github.com/invertedv/chutils"
 	"github.com/invertedv/utilities"
 	"github.com/pkg/errors"
 
@@ -28,7 +32,13 @@ var (
 	Height = 1200.0
 	Width  = 1200.0
 
-	fig = &grob.Fig{}
+	fig    = &grob.Fig{}
+	plotSt = &plotState{}
+
+	// dateFormats are the layouts (see time.Parse) tried, in order, when converting strings to dates. They are tried
+	// before the formats of utilities.Any2Date (CCYYMMDD, MM/DD/CCYY, ...). See RegisterDateFormat.
+	dateFormats = []string{"2006-01-02", "2006-01-02 15:04:05", "2006-01-02T15:04:05", time.RFC3339}
+	dateMu      sync.RWMutex
 )
 
 const (
@@ -54,6 +64,12 @@ const (
 	// operations is a list of supported operations
 	operations = logicals + "$" + comparisons + "$" + arithmetics
 
+	// ftFunctions are functions that return the FType metadata of a field in the Pipeline
+	ftFunctions = "nLevels$isCat$locationOf$scaleOf"
+
+	// randFunctions are functions that return a random value for each row of the Pipeline
+	randFunctions = "rand$randn$randInt"
+
 	colors = "black,red,blue,green,yellow"
 	mType  = "line,markers"
 )
@@ -68,7 +84,10 @@ const (
 // subexpressions create two new nodes in Inputs.
 //
 // Comparison operations with fields of type FRCat are permitted if the underlying data is type string or date.
-// // Strings and dates are enclosed in a single quote ('). Date formats supported are: CCYYMMDD and MM/DD/CCYY.
+// Comparisons with fields of type FROrdinal follow the order of the levels, e.g. grade<'C' is true for 'A' and 'B'
+// if the levels of grade are ordered A, B, C, D.
+// // Strings and dates are enclosed in a single quote ('). Date formats supported are: CCYYMMDD, MM/DD/CCYY, CCYY-MM-DD
+// and those added by RegisterDateFormat.
 //
 // Functions:
 // If the expression is a function, each argument is assigned to an Input (in order).  Functions have at least one
@@ -81,6 +100,13 @@ const (
 //   - log(<expr>)
 //   - lag(<expr>,<missing>), where <missing> is used for the first element.
 //   - abs(<expr>) absolute value
+//   - safeDiv(<a>,<b>,<default>) returns <a>/<b>, or <default> where <b> is 0
+//   - not(<expr>) returns 1 if <expr> is not positive and 0 o.w.  Same as !<expr>.
+//   - in(<expr>,<value1>,<value2>,...) returns 1 if <expr> equals any of the values and 0 o.w., e.g.
+//     in(state,'CA','TX') or in(fico,700,720)
+//   - lookup(<key>,<table>,<field>) returns <field> from the row of the reference table <table> (see RegisterLookup)
+//     with key <key>, e.g. lookup(state,'regions','region').  It's an error if <key> is not in the table.
+//   - lookup(<key>,<table>,<field>,<default>) returns <default> if <key> is not in the table.
 //   - if(<test>, <true>, <false>), where the value <yes> is used if <condition> is greater than 0 and <false> o.w.
 //   - row(<expr>) row number in pipeline. Row starts as 0 and is continuous.
 //   - countAfter(<expr>), countBefore(<expr>) is the number of rows after (before) the current row.
@@ -90,6 +116,7 @@ const (
 //   - index(<expr>,<index>) returns <expr> in the order of <index>
 //   - cat(<expr>) converts <expr> to a categorical field. Only applicable to continuous fields.
 //   - toDate(<expr>) converts a string field to a date
+//   - toDateFmt(<expr>,<layout>) converts a string field to a date using <layout>, a Go time layout such as '02.01.2006'
 //   - toString(<expr>) converts <expr> to string
 //   - toFloatSP(<expr>) converts <expr> to float32
 //   - toFloatDP(<expr>) converts <expr> to float64
@@ -107,8 +134,21 @@ const (
 //   - strPos(<string>,<target>) first position of <target> in <string>. -1 if does not occur.
 //   - strCount(<string>,<target>) number of times <target> occurs in <string>
 //   - strLen(<string>) length of string
+//   - strMatch(<string>,<pattern>) returns 1 if the regular expression <pattern> matches <string> and 0 o.w.
+//   - strExtract(<string>,<pattern>,<group>) returns capture group <group> of the first match of <pattern> in
+//     <string>, an empty string if there is no match.  Group 0 is the entire match.
+//   - strReplaceRe(<string>,<pattern>,<repl>) replaces all matches of <pattern> in <string> with <repl>. <repl> may
+//     refer to capture groups as $1, $2, ...
 //   - trunc(<expr>)  truncate to int
 //   - exist(x,y) if x exists, returns x. If x does not exist, returns y.
+//   - rand() uniform random number on [0,1)
+//   - randn(<mu>,<sigma>) normal random number with mean <mu> and standard deviation <sigma>
+//   - randInt(<lo>,<hi>) random integer between <lo> and <hi>, inclusive.  For instance, randInt(0,4)==0 flags
+//     a random 20% of the rows.
+//   - cross(<expr>,<expr>) creates a categorical field whose levels are the combinations of the levels of the two
+//     inputs, e.g. cross(state, product) has levels such as 'CA:auto'.
+//
+// The random functions use the package random number generator, so SetSeed makes them reproducible.
 //
 // The values in <...> can be any expression.  The functions prodAfter, prodBefore, cumAfter,cumBefore,
 // countAfter, countBefore do NOT include the current row.
@@ -129,11 +169,32 @@ const (
 //   - print(<expr>,<rows>) print <rows> of the <expr>.  If <rows>=0, print entire slice.
 //   - printIf(<expr>,<rows>,<cond>) if condition evaluates to a value > 0, execute print(<expr>,<rows>)
 //   - histogram(<x>,<color>, <normalization>).  Creates a histogram. normalization is one of: percent, count, density
+//   - histogram(<x>,<color>, <normalization>, <label>)
 //   - plotLine(<x>,<markerType>, <color>)
+//   - plotLine(<x>,<markerType>, <color>, <label>)
 //   - plotXY(<x>,<y>,<markerType>, <color>)
+//   - plotXY(<x>,<y>,<markerType>, <color>, <label>)
+//   - plotXY(<x>,<y>,<markerType>, <color>, <label>, <axis>). <axis> is 'y' or 'y2' (secondary y-axis)
+//   - boxplot(<x>,<by>) box plots of <x> for each value of <by>
+//   - barplot(<cat>,<value>) bars of the sum of <value> for each value of <cat>
+//   - barplot(<cat>,<value>,<agg>) <agg> is one of sum, mean, count
+//   - heatmap(<x>,<y>,<value>) heatmap of the sum of <value> for each combination of <x> and <y>
+//   - heatmap(<x>,<y>,<value>,<agg>) <agg> is one of sum, mean, count
+//   - facet(<by>) subsequent traces are split into one panel for each value of <by>
 //   - setPlotDim(<width>,<height>), <width>, <height> are in pixels
 //   - render(<file>,<title>,<x label>,<y label>)
-//   - newPlot()
+//   - render(<file>,<title>,<x label>,<y label>,<y2 label>)
+//   - newPlot() starts a new plot, removing any facet
+//
+// Colors are black, red, blue, green, yellow or hex (e.g. '#1f77b4').  Traces with a <label> are shown in the legend.
+//
+// Functions that return the FType metadata of a field in the Pipeline are available. These allow expressions to use
+// the values stored with the FTypes (e.g. from the training data) rather than recalculating them. The field name is
+// enclosed in single quotes.
+//   - nLevels('<field>') number of levels of a categorical field
+//   - isCat('<field>') 1 if the field is categorical, 0 o.w.
+//   - locationOf('<field>') the location parameter (mean) used to normalize the field
+//   - scaleOf('<field>') the scale parameter (std dev) used to normalize the field
 //
 // Comparisons
 //   - ==, !=, >,>=, <, <=
@@ -141,6 +202,7 @@ const (
 // Logical operators are supported:
 //   - && for "and"
 //   - || for "or"
+//   - ! for "not".  This applies to the term that follows, e.g. !(state=='CA') && fico<640
 //
 // Logical operators resolve to 0 or 1.
 type OpNode struct {
@@ -151,6 +213,10 @@ type OpNode struct {
 	Neg        bool      // negate result when populating Value
 	Inputs     []*OpNode // Inputs to node calculation
 	stet       bool      // if stet then Value is not updated (used by Loop)
+	source     string    // expression as given to Expr2Tree, for locating errors (root only)
+	lvl        Levels    // order of the levels, if the node is an FROrdinal field
+	reuse      bool      // keep the result slice between runs (see Compile)
+	buf        *Raw      // result slice kept if reuse
 }
 
 // FuncSpec stores the details about a function call.
@@ -159,6 +225,8 @@ type FuncSpec struct {
 	Return reflect.Kind   // The type of the return.  This will either be float64 or any.
 	Args   []reflect.Kind // The types of the inputs to the function.
 	Level  rune           // 'S' if the function is summary-level (1 element) or 'R' if it is row-level.
+	// Variadic is true if the last argument may be repeated.  It is marked by "..." in FunctionsStr.
+	Variadic bool
 }
 
 // loadFunctions loads the slice of FuncSpec that is all the defined functions the parser supports.
@@ -172,8 +240,13 @@ func loadFunctions() {
 		}
 
 		for ind := 3; ind < len(fdetail); ind++ {
-			if fdetail[ind] != "" {
-				fSpec.Args = append(fSpec.Args, utilities.String2Kind(fdetail[ind]))
+			arg := fdetail[ind]
+			if strings.HasSuffix(arg, "...") {
+				arg, fSpec.Variadic = strings.TrimSuffix(arg, "..."), true
+			}
+
+			if arg != "" {
+				fSpec.Args = append(fSpec.Args, utilities.String2Kind(arg))
 			}
 		}
 		Functions = append(Functions, fSpec)
@@ -197,12 +270,27 @@ func loadFunctions() {
 //   - if statements: if(condition, value if true, value if false). The true value is applied if the condition evaluates
 //     to a positive value.
 //   - parentheses
+//
+// Problems are returned as a *ParseError, which gives the offending sub-expression, its offset in the expression and,
+// for misspelled functions, a suggestion.
 func Expr2Tree(curNode *OpNode) error {
 	// Load the global slice of functions if they are not
 	if Functions == nil {
 		loadFunctions()
 	}
 
+	curNode.source = curNode.Expression
+
+	if ind := unmatchedParen(curNode.source); ind >= 0 {
+		return &ParseError{Expr: curNode.source, Sub: curNode.source[ind : ind+1], Offset: ind,
+			Msg: "mismatched parentheses"}
+	}
+
+	return locate(curNode.source, expr2Tree(curNode))
+}
+
+// expr2Tree builds the tree below curNode
+func expr2Tree(curNode *OpNode) error {
 	curNode.Expression = utilities.ReplaceSmart(curNode.Expression, " ", "", "'")
 
 	if e := matchedParen(curNode.Expression); e != nil {
@@ -233,6 +321,15 @@ func Expr2Tree(curNode *OpNode) error {
 		return err
 	}
 
+	// ! negates the term that follows. Binary operations have been split off, so that's the whole expression.
+	if op == "" && strings.HasPrefix(curNode.Expression, "!") {
+		if len(curNode.Expression) == 1 {
+			return newParseError(curNode.Expression, "missing operand of !", "")
+		}
+
+		op, args = "not", []string{curNode.Expression[1:]}
+	}
+
 	// nothing to do (leaf)
 	if op == "" {
 		return nil
@@ -244,7 +341,7 @@ func Expr2Tree(curNode *OpNode) error {
 		args[1] = "-" + args[1]
 	}
 
-	curNode.Func, curNode.Role = getFuncSpec(op)
+	curNode.Func, curNode.Role = getFuncSpec(op, len(args))
 	if args == nil {
 		return nil
 	}
@@ -254,7 +351,7 @@ func Expr2Tree(curNode *OpNode) error {
 		curNode.Inputs[ind] = &OpNode{Neg: false}
 
 		curNode.Inputs[ind].Expression = args[ind]
-		if e := Expr2Tree(curNode.Inputs[ind]); e != nil {
+		if e := expr2Tree(curNode.Inputs[ind]); e != nil {
 			return e
 		}
 	}
@@ -267,26 +364,50 @@ func Expr2Tree(curNode *OpNode) error {
 	return nil
 }
 
-// getFuncSpec returns the FuncSpec for the function/operation op
+// getFuncSpec returns the FuncSpec for the function/operation op with nArgs arguments.
+// A function may be defined more than once with different numbers of arguments (e.g. plotXY). If no definition
+// has nArgs arguments, the first definition is returned.  The Args of a variadic function are extended to nArgs.
 // FRole is the default role for the function
-func getFuncSpec(op string) (*FuncSpec, FRole) {
-	for _, fSpec := range Functions {
-		if op == fSpec.Name {
-			var role FRole
-			switch fSpec.Return {
-			case reflect.String, reflect.Struct:
-				role = FRCat
-			case reflect.Interface:
-				role = FREither
-			default:
-				role = FRCts
-			}
+func getFuncSpec(op string, nArgs int) (*FuncSpec, FRole) {
+	var spec *FuncSpec
+
+	for ind, fSpec := range Functions {
+		if op != fSpec.Name {
+			continue
+		}
+
+		match := fSpec.Args == nil || len(fSpec.Args) == nArgs || (fSpec.Variadic && nArgs > len(fSpec.Args))
+		if spec == nil || match {
+			spec = &Functions[ind]
+		}
 
-			return &fSpec, role
+		if match {
+			break
 		}
 	}
 
-	return nil, FREither
+	if spec == nil {
+		return nil, FREither
+	}
+
+	fSpec := *spec
+	fSpec.Args = append([]reflect.Kind{}, spec.Args...)
+
+	for fSpec.Variadic && len(fSpec.Args) > 0 && len(fSpec.Args) < nArgs {
+		fSpec.Args = append(fSpec.Args, fSpec.Args[len(fSpec.Args)-1])
+	}
+
+	var role FRole
+	switch fSpec.Return {
+	case reflect.String, reflect.Struct:
+		role = FRCat
+	case reflect.Interface:
+		role = FREither
+	default:
+		role = FRCts
+	}
+
+	return &fSpec, role
 }
 
 // negLocation determines where to place a leading minus sign.
@@ -408,17 +529,18 @@ func getFunction(expr string) (funName string, args []string, err error) {
 		}
 	}
 
-	fSpec, _ := getFuncSpec(f)
+	// get arguments
+	args = getArgs(inner)
+
+	fSpec, _ := getFuncSpec(f, len(args))
 	// Is this a known function?
 	if fSpec == nil {
-		return f, nil, fmt.Errorf("unknown function: %s", f)
+		return f, nil, newParseError(expr, fmt.Sprintf("unknown function: %s", f), suggest(f, functionNames()))
 	}
 
-	// get arguments
-	args = getArgs(inner)
-
 	if fSpec.Args != nil && len(fSpec.Args) != len(args) {
-		return f, args, fmt.Errorf("wrong number of arguments in %s", f)
+		return f, args, newParseError(expr, fmt.Sprintf("wrong number of arguments in %s: expected %d, got %d",
+			f, len(fSpec.Args), len(args)), "")
 	}
 
 	return f, args, nil
@@ -522,6 +644,131 @@ func ifCond(node *OpNode) error {
 	return nil
 }
 
+// safeDiv evaluates safeDiv(a, b, default): a/b where b is not 0 and default where it is.
+func safeDiv(node *OpNode) error {
+	var deltas []int
+	node.Raw, deltas = getDeltas(node)
+
+	indA, indB, indD := 0, 0, 0
+	for ind := 0; ind < node.Raw.Len(); ind++ {
+		a, e1 := utilities.Any2Float64(node.Inputs[0].Raw.Data[indA])
+		b, e2 := utilities.Any2Float64(node.Inputs[1].Raw.Data[indB])
+		def, e3 := utilities.Any2Float64(node.Inputs[2].Raw.Data[indD])
+
+		if e1 != nil || e2 != nil || e3 != nil {
+			return fmt.Errorf("safeDiv: cannot convert to float64")
+		}
+
+		node.Raw.Data[ind] = *def
+		if *b != 0.0 {
+			node.Raw.Data[ind] = *a / *b
+		}
+
+		indA += deltas[0]
+		indB += deltas[1]
+		indD += deltas[2]
+	}
+
+	return nil
+}
+
+// inList evaluates in(x, v1, v2, ...): 1 if x equals any of the values and 0 o.w.
+func inList(node *OpNode) error {
+	var deltas []int
+	node.Raw, deltas = getDeltas(node)
+
+	inds := make([]int, len(node.Inputs))
+
+	for row := 0; row < node.Raw.Len(); row++ {
+		x := node.Inputs[0].Raw.Data[inds[0]]
+		node.Raw.Data[row] = 0.0
+
+		for ind := 1; ind < len(node.Inputs); ind++ {
+			eq, e := inEqual(x, node.Inputs[ind].Raw.Data[inds[ind]])
+			if e != nil {
+				return fmt.Errorf("in: %v", e)
+			}
+
+			if eq {
+				node.Raw.Data[row] = 1.0
+				break
+			}
+		}
+
+		for ind := range inds {
+			inds[ind] += deltas[ind]
+		}
+	}
+
+	return nil
+}
+
+// inEqual returns true if a and b are equal.  Numbers of different types are compared as float64.
+func inEqual(a, b any) (bool, error) {
+	isNum := func(v any) bool {
+		switch v.(type) {
+		case float64, float32, int, int32, int64:
+			return true
+		}
+
+		return false
+	}
+
+	if isNum(a) && isNum(b) {
+		x, _ := utilities.Any2Float64(a)
+		y, _ := utilities.Any2Float64(b)
+
+		return *x == *y, nil
+	}
+
+	return compare(a, b, "==")
+}
+
+// logicalNot returns 1 where x is not positive and 0 where it is
+func logicalNot(x *Raw) (*Raw, error) {
+	xOut := make([]any, x.Len())
+	for ind, xval := range x.Data {
+		v, e := utilities.Any2Float64(xval)
+		if e != nil {
+			return nil, fmt.Errorf("not: %v", e)
+		}
+
+		xOut[ind] = 0.0
+		if *v <= 0.0 {
+			xOut[ind] = 1.0
+		}
+	}
+
+	return NewRaw(xOut, nil), nil
+}
+
+// nanLog is the log of node's input, with NaN for non-positive values
+func nanLog(node *OpNode, cfg *evalConfig) (*Raw, error) {
+	x := node.Inputs[0].Raw
+	if !x.IsNumeric() {
+		return nil, fmt.Errorf("numeric operation on %v", x.Kind)
+	}
+
+	xOut := make([]any, x.Len())
+	for ind, xval := range x.Data {
+		v, e := utilities.Any2Float64(xval)
+		if e != nil {
+			return nil, e
+		}
+
+		if *v <= 0 {
+			xOut[ind] = math.NaN()
+			cfg.nan(node.Expression)
+
+			continue
+		}
+
+		xOut[ind] = math.Log(*v)
+	}
+
+	return NewRaw(xOut, nil), nil
+}
+
 // getDeltas returns an array for the results and a slice of increments for moving through the Inputs
 func getDeltas(node *OpNode) (x *Raw, deltas []int) {
 	if node.Inputs == nil {
@@ -546,7 +793,17 @@ func getDeltas(node *OpNode) (x *Raw, deltas []int) {
 		deltas = append(deltas, d)
 	}
 
-	return AllocRaw(n, node.Func.Return), deltas
+	// an interior node of a compiled Program reuses the slice of the last run
+	if node.reuse && node.buf != nil && node.buf.Len() == n && node.buf.Kind == node.Func.Return {
+		return node.buf, deltas
+	}
+
+	x = AllocRaw(n, node.Func.Return)
+	if node.reuse {
+		node.buf = x
+	}
+
+	return x, deltas
 }
 
 // npv finds NPV when the discount rate is a constant. The first cashflow has a discount factor of 1.0
@@ -735,17 +992,27 @@ func EvalSFunction(node *OpNode) error {
 	case "printIf":
 		result, e = printIf(node.Inputs[0].Raw, node.Inputs[0].Expression, node.Inputs[1].Raw.Data[0], node.Inputs[2].Raw.Data[0])
 	case "plotXY":
-		result, e = plotXY(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, node.Inputs[3].Raw)
+		result, e = plotXY(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, node.Inputs[3].Raw,
+			optInput(node, 4), optInput(node, 5))
 	case "plotLine":
-		result, e = plotLine(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw)
+		result, e = plotLine(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, optInput(node, 3))
 	case "histogram":
-		result, e = histogram(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw)
+		result, e = histogram(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, optInput(node, 3))
+	case "facet":
+		result, e = facet(node.Inputs[0].Raw)
+	case "boxplot":
+		result, e = boxplot(node.Inputs[0].Raw, node.Inputs[1].Raw)
+	case "barplot":
+		result, e = barplot(node.Inputs[0].Raw, node.Inputs[1].Raw, optInput(node, 2))
+	case "heatmap":
+		result, e = heatmap(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, optInput(node, 3))
 	case "setPlotDim":
 		result, e = setPlotDim(node.Inputs[0].Raw, node.Inputs[1].Raw)
 	case "newPlot":
 		result = newPlot()
 	case "render":
-		result, e = render(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, node.Inputs[3].Raw)
+		result, e = render(node.Inputs[0].Raw, node.Inputs[1].Raw, node.Inputs[2].Raw, node.Inputs[3].Raw,
+			optInput(node, 4))
 	case "sum":
 		result, e = node.Inputs[0].Raw.Sum()
 	case "max":
@@ -1057,6 +1324,107 @@ func strLen(node *OpNode) error {
 	return nil
 }
 
+// regexpFunc evaluates the row-level regular expression functions strMatch, strExtract and strReplaceRe.
+// The first argument is the string, the second the pattern.  Patterns are compiled once for each distinct value.
+func regexpFunc(node *OpNode) error {
+	var deltas []int
+
+	name := node.Func.Name
+	node.Raw, deltas = getDeltas(node)
+
+	if node.Raw == nil {
+		return fmt.Errorf("argument to %s is missing", name)
+	}
+
+	compiled := make(map[string]*regexp.Regexp)
+	inds := make([]int, len(node.Inputs))
+
+	for row := 0; row < node.Raw.Len(); row++ {
+		str, ok := node.Inputs[0].Raw.Data[inds[0]].(string)
+		if !ok {
+			return fmt.Errorf("arg 1 to %s isn't a string", name)
+		}
+
+		pattern, ok := node.Inputs[1].Raw.Data[inds[1]].(string)
+		if !ok {
+			return fmt.Errorf("arg 2 to %s isn't a string", name)
+		}
+
+		re, ok := compiled[pattern]
+		if !ok {
+			var e error
+			if re, e = regexp.Compile(pattern); e != nil {
+				return fmt.Errorf("%s: bad pattern %s: %v", name, pattern, e)
+			}
+
+			compiled[pattern] = re
+		}
+
+		switch name {
+		case "strMatch":
+			node.Raw.Data[row] = 0.0
+			if re.MatchString(str) {
+				node.Raw.Data[row] = 1.0
+			}
+		case "strExtract":
+			x, e := utilities.Any2Kind(node.Inputs[2].Raw.Data[inds[2]], reflect.Int32)
+			if e != nil {
+				return fmt.Errorf("arg 3 to strExtract isn't an int")
+			}
+
+			group := int(x.(int32))
+			if group < 0 || group > re.NumSubexp() {
+				return fmt.Errorf("strExtract: pattern %s has no group %d", pattern, group)
+			}
+
+			node.Raw.Data[row] = ""
+			if match := re.FindStringSubmatch(str); match != nil {
+				node.Raw.Data[row] = match[group]
+			}
+		case "strReplaceRe":
+			repl, ok := node.Inputs[2].Raw.Data[inds[2]].(string)
+			if !ok {
+				return fmt.Errorf("arg 3 to strReplaceRe isn't a string")
+			}
+
+			node.Raw.Data[row] = re.ReplaceAllString(str, repl)
+		}
+
+		for ind := range inds {
+			inds[ind] += deltas[ind]
+		}
+	}
+
+	return nil
+}
+
+// crossLevels creates the interaction of two fields as a categorical field. The levels are of the form "a:b".
+func crossLevels(node *OpNode) error {
+	var deltas []int
+
+	_, deltas = getDeltas(node)
+
+	if node.Inputs[0].Raw == nil || node.Inputs[1].Raw == nil {
+		return fmt.Errorf("arg to cross is missing")
+	}
+
+	n := utilities.MaxInt(node.Inputs[0].Raw.Len(), node.Inputs[1].Raw.Len())
+	levels := make([]any, n)
+	ind1, ind2 := 0, 0
+
+	for ind := 0; ind < n; ind++ {
+		levels[ind] = utilities.Any2String(node.Inputs[0].Raw.Data[ind1]) + ":" +
+			utilities.Any2String(node.Inputs[1].Raw.Data[ind2])
+		ind1 += deltas[0]
+		ind2 += deltas[1]
+	}
+
+	node.Raw = NewRaw(levels, nil)
+	node.Role = FRCat
+
+	return nil
+}
+
 // abs takes the absolute value
 func abs(node *OpNode) error {
 	var deltas []int
@@ -1255,6 +1623,139 @@ func toWhatever(node *OpNode, kind reflect.Kind) error {
 	return nil
 }
 
+// RegisterDateFormat adds layouts (see time.Parse) used to convert strings to dates.  The layouts are tried before
+// those already registered, so, for instance, after
+//
+//	RegisterDateFormat("02/01/2006")
+//
+// '03/04/2023' is April 3.  The layouts are used by toDate, by comparisons of dates with string constants and by CSV
+// ingestion (chutils.DateFormats) when imputing field types.
+//
+// chutils reads chutils.DateFormats without a lock, so RegisterDateFormat should be called at initialization, before
+// any data is read.
+func RegisterDateFormat(layouts ...string) {
+	dateMu.Lock()
+	defer dateMu.Unlock()
+
+	for ind := len(layouts) - 1; ind >= 0; ind-- {
+		dateFormats = prependFormat(dateFormats, layouts[ind])
+		chutils.DateFormats = prependFormat(chutils.DateFormats, layouts[ind])
+	}
+}
+
+// DateFormats returns the registered date layouts in the order they are tried.
+func DateFormats() []string {
+	dateMu.RLock()
+	defer dateMu.RUnlock()
+
+	return append([]string{}, dateFormats...)
+}
+
+// prependFormat moves layout to the front of formats
+func prependFormat(formats []string, layout string) []string {
+	out := []string{layout}
+	for _, f := range formats {
+		if f != layout {
+			out = append(out, f)
+		}
+	}
+
+	return out
+}
+
+// regDate converts x to a date using the registered formats
+func regDate(x string) (time.Time, bool) {
+	dateMu.RLock()
+	defer dateMu.RUnlock()
+
+	for _, layout := range dateFormats {
+		if dt, e := time.Parse(layout, x); e == nil {
+			return dt, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// any2Date converts x to a date, trying the registered formats before utilities.Any2Date
+func any2Date(x any) (time.Time, error) {
+	if str, ok := x.(string); ok {
+		if dt, ok := regDate(strings.ReplaceAll(str, "'", "")); ok {
+			return dt, nil
+		}
+	}
+
+	dt, e := utilities.Any2Date(x)
+	if e != nil {
+		return time.Time{}, e
+	}
+
+	return *dt, nil
+}
+
+// compare applies the comparison op to a and b.  A string compared to a date is converted to a date using the
+// registered formats.
+func compare(a, b any, op string) (bool, error) {
+	_, aDate := a.(time.Time)
+	_, bDate := b.(time.Time)
+
+	if _, ok := a.(string); ok && bDate {
+		if dt, e := any2Date(a); e == nil {
+			a = dt
+		}
+	}
+
+	if _, ok := b.(string); ok && aDate {
+		if dt, e := any2Date(b); e == nil {
+			b = dt
+		}
+	}
+
+	return utilities.Comparer(a, b, op)
+}
+
+// toDate converts the first input to a date.  If there is a second input, it is the layout of the dates.
+func toDate(node *OpNode) error {
+	var layout string
+	if len(node.Inputs) == 2 {
+		// the layout must be a constant, so it is taken from the expression rather than the value
+		if !strings.Contains(node.Inputs[1].Expression, "'") {
+			return fmt.Errorf("arg 2 to toDateFmt must be a string constant")
+		}
+
+		layout = strings.ReplaceAll(node.Inputs[1].Expression, "'", "")
+	}
+
+	xIn := node.Inputs[0].Raw.Data
+	xOut := make([]any, len(xIn))
+
+	for ind, x := range xIn {
+		var (
+			dt time.Time
+			e  error
+		)
+
+		str, isStr := x.(string)
+
+		switch {
+		case layout != "" && isStr:
+			dt, e = time.Parse(layout, str)
+		default:
+			dt, e = any2Date(x)
+		}
+
+		if e != nil {
+			return fmt.Errorf("conversion of %v to date failed", x)
+		}
+
+		xOut[ind] = dt
+	}
+
+	node.Raw = NewRaw(xOut, nil)
+
+	return nil
+}
+
 // nowDate puts the current date into node
 func nowDate(node *OpNode) error {
 	xOut := make([]any, 1)
@@ -1275,7 +1776,7 @@ func nowTime(node *OpNode) error {
 }
 
 // evalFunction evaluates a function call
-func evalFunction(node *OpNode) error {
+func evalFunction(node *OpNode, cfg *evalConfig) error {
 	if e := consistent(node); e != nil {
 		return e
 	}
@@ -1315,8 +1816,10 @@ func evalFunction(node *OpNode) error {
 		err = strCount(node)
 	case "strLen":
 		err = strLen(node)
-	case "toDate":
-		err = toWhatever(node, reflect.Struct)
+	case "strMatch", "strExtract", "strReplaceRe":
+		err = regexpFunc(node)
+	case "toDate", "toDateFmt":
+		err = toDate(node)
 	case "toString":
 		err = toWhatever(node, reflect.String)
 	case "toFloatDP":
@@ -1330,6 +1833,10 @@ func evalFunction(node *OpNode) error {
 		err = toWhatever(node, reflect.Int32)
 	case "abs":
 		err = abs(node)
+	case "safeDiv":
+		err = safeDiv(node)
+	case "cross":
+		err = crossLevels(node)
 	default:
 		gotOne = false
 	}
@@ -1381,7 +1888,18 @@ func evalFunction(node *OpNode) error {
 		node.Raw, err = node.Inputs[0].Raw.Index(node.Inputs[1].Raw)
 	case "exp":
 		node.Raw, err = node.Inputs[0].Raw.Exp()
+	case "not":
+		node.Raw, err = logicalNot(node.Inputs[0].Raw)
+	case "in":
+		err = inList(node)
+	case "lookup":
+		err = lookup(node)
 	case "log":
+		if cfg.nanProp {
+			node.Raw, err = nanLog(node, cfg)
+			break
+		}
+
 		node.Raw, err = node.Inputs[0].Raw.Log()
 	default:
 		return fmt.Errorf("unknown function %s", node.Func.Name)
@@ -1419,12 +1937,44 @@ func evalConstant(node *OpNode) bool {
 	return false
 }
 
+// fromEnv loads the value bound to the name node.Expression, if there is one.  The value is copied so the binding
+// isn't affected by the evaluation.
+func fromEnv(node *OpNode, cfg *evalConfig) bool {
+	raw := cfg.lookup(node.Expression)
+	if raw == nil {
+		return false
+	}
+
+	xOut := make([]any, raw.Len())
+	copy(xOut, raw.Data)
+	node.Raw = NewRaw(xOut, nil)
+	goNegative(node.Raw, node.Neg)
+
+	node.Role = FRCat
+	if node.Raw.Kind == reflect.Float64 {
+		node.Role = FRCts
+	}
+
+	return true
+}
+
 // fromPipeline loads data which originates in the pipeline
-func fromPipeline(node *OpNode, pipe Pipeline) error {
-	field := node.Expression
+func fromPipeline(node *OpNode, pipes pipeFinder) error {
+	gd, field, e := pipes(node.Expression)
+	if e != nil {
+		return e
+	}
 
-	var e error
-	node.Raw, e = pipe.GData().GetRaw(field)
+	if gd == nil || gd.Get(field) == nil {
+		var fields []string
+		if gd != nil {
+			fields = gd.FieldList()
+		}
+
+		return newParseError(node.Expression, fmt.Sprintf("%s not in pipeline", field), suggest(field, fields))
+	}
+
+	node.Raw, e = gd.GetRaw(field)
 	if e != nil {
 		return fmt.Errorf("%s not in pipeline", node.Expression)
 	}
@@ -1437,26 +1987,52 @@ func fromPipeline(node *OpNode, pipe Pipeline) error {
 		goNegative(node.Raw, node.Neg)
 	}
 
-	ft := pipe.GetFType(field)
+	ft := gd.GetFType(field)
 	if ft.Role == FROneHot || ft.Role == FREmbed {
 		return fmt.Errorf("cannot operate on onehot or embedded fields")
 	}
 
 	node.Role = ft.Role
+	node.lvl = nil
+
+	if ft.Role == FROrdinal {
+		node.lvl = ft.FP.Lvl
+	}
 
 	return nil
 }
 
-// evalOpsCat evaluates operations (inequalities) for FRCat (string, date) fields
-func evalOpsCat(node *OpNode) error {
+// evalOpsOrd evaluates comparisons with FROrdinal fields.  The values are compared by their place in the order
+// of the levels of the field.
+func evalOpsOrd(node *OpNode) error {
+	lvl0, lvl1 := node.Inputs[0].lvl, node.Inputs[1].lvl
+	if lvl0 == nil {
+		lvl0 = lvl1
+	}
+
+	if lvl1 == nil {
+		lvl1 = lvl0
+	}
+
 	var deltas []int
 	node.Raw, deltas = getDeltas(node)
+	node.Raw.Kind = reflect.Float64
 	ind1, ind2 := 0, 0
 
 	for ind := 0; ind < node.Raw.Len(); ind++ {
-		// check same type...
+		x0, ok0 := ordRank(lvl0, node.Inputs[0].Raw.Data[ind1])
+		x1, ok1 := ordRank(lvl1, node.Inputs[1].Raw.Data[ind2])
+
+		if !ok0 {
+			return fmt.Errorf("%v is not a level of %s", node.Inputs[0].Raw.Data[ind1], node.Inputs[1].Expression)
+		}
+
+		if !ok1 {
+			return fmt.Errorf("%v is not a level of %s", node.Inputs[1].Raw.Data[ind2], node.Inputs[0].Expression)
+		}
+
 		node.Raw.Data[ind] = float64(0)
-		test, e := utilities.Comparer(node.Inputs[0].Raw.Data[ind1], node.Inputs[1].Raw.Data[ind2], node.Func.Name)
+		test, e := utilities.Comparer(float64(x0), float64(x1), node.Func.Name)
 		if e != nil {
 			return e
 		}
@@ -1472,14 +2048,56 @@ func evalOpsCat(node *OpNode) error {
 	return nil
 }
 
-// consistent checks that the Inputs are consistent with what's needed as specified in node.Func.args
+// ordRank returns the place of x in the order lvl. Values that aren't keys of lvl, such as the float64 constants
+// of an expression, are matched by their string form.
+func ordRank(lvl Levels, x any) (int32, bool) {
+	if rank, ok := lvl[x]; ok {
+		return rank, true
+	}
+
+	xStr := fmt.Sprintf("%v", x)
+	for k, rank := range lvl {
+		if fmt.Sprintf("%v", k) == xStr {
+			return rank, true
+		}
+	}
+
+	return 0, false
+}
+
+// evalOpsCat evaluates operations (inequalities) for FRCat (string, date) fields
+func evalOpsCat(node *OpNode) error {
+	var deltas []int
+	node.Raw, deltas = getDeltas(node)
+	ind1, ind2 := 0, 0
+
+	for ind := 0; ind < node.Raw.Len(); ind++ {
+		// check same type...
+		node.Raw.Data[ind] = float64(0)
+		test, e := compare(node.Inputs[0].Raw.Data[ind1], node.Inputs[1].Raw.Data[ind2], node.Func.Name)
+		if e != nil {
+			return e
+		}
+
+		if test {
+			node.Raw.Data[ind] = float64(1)
+		}
+
+		ind1 += deltas[0]
+		ind2 += deltas[1]
+	}
+
+	return nil
+}
+
+// consistent checks that the Inputs are consistent with what's needed as specified in node.Func.args
 func consistent(node *OpNode) error {
 	if node.Func == nil {
 		return nil
 	}
 
 	if len(node.Inputs) != len(node.Func.Args) {
-		return fmt.Errorf("argument count mismatch")
+		return newParseError(node.Expression, "argument count mismatch", "")
 	}
 
 	for ind, arg := range node.Func.Args {
@@ -1487,11 +2105,13 @@ func consistent(node *OpNode) error {
 		case reflect.Float64:
 			switch node.Inputs[ind].Raw.Kind {
 			case reflect.Struct, reflect.String:
-				return fmt.Errorf("argument type mismatch, function %s", node.Func.Name)
+				return newParseError(node.Inputs[ind].Expression,
+					fmt.Sprintf("argument type mismatch, function %s", node.Func.Name), "")
 			}
 		case reflect.Struct:
 			if node.Inputs[ind].Raw.Kind != reflect.Struct {
-				return fmt.Errorf("argument type mismatch, function %s", node.Func.Name)
+				return newParseError(node.Inputs[ind].Expression,
+					fmt.Sprintf("argument type mismatch, function %s", node.Func.Name), "")
 			}
 		}
 	}
@@ -1500,7 +2120,7 @@ func consistent(node *OpNode) error {
 }
 
 // evalOps evaluates an operation
-func evalOps(node *OpNode) error {
+func evalOps(node *OpNode, cfg *evalConfig) error {
 	if node.Inputs == nil || len(node.Inputs) != 2 {
 		return fmt.Errorf("operations require two operands")
 	}
@@ -1509,6 +2129,10 @@ func evalOps(node *OpNode) error {
 		return e
 	}
 
+	if (node.Inputs[0].lvl != nil || node.Inputs[1].lvl != nil) && utilities.Has(node.Func.Name, delim, comparisons) {
+		return evalOpsOrd(node)
+	}
+
 	// interface?
 	if node.Func.Return == reflect.String || node.Func.Return == reflect.Struct || node.Func.Return == reflect.Interface {
 		return evalOpsCat(node)
@@ -1553,7 +2177,7 @@ func evalOps(node *OpNode) error {
 			node.Raw.Data[ind] = val
 		case ">", ">=", "==", "!=", "<", "<=":
 			node.Raw.Data[ind] = float64(0)
-			test, _ := utilities.Comparer(x0, x1, node.Func.Name)
+			test, _ := compare(x0, x1, node.Func.Name)
 			if test {
 				node.Raw.Data[ind] = float64(1)
 			}
@@ -1563,7 +2187,14 @@ func evalOps(node *OpNode) error {
 			node.Raw.Data[ind] = x0.(float64) * x1.(float64)
 		case "/":
 			if x1.(float64) == 0.0 {
-				return fmt.Errorf("divide by zero")
+				if !cfg.nanProp {
+					return fmt.Errorf("divide by zero")
+				}
+
+				node.Raw.Data[ind] = math.NaN()
+				cfg.nan(node.Expression)
+
+				break
 			}
 
 			node.Raw.Data[ind] = x0.(float64) / x1.(float64)
@@ -1587,11 +2218,150 @@ func evalOps(node *OpNode) error {
 //
 // Note, you can access the values after Evaluate without adding the field to the Pipeline from the *Raw item
 // of the root node.
-func Evaluate(curNode *OpNode, pipe Pipeline) error {
+//
+// By default, division by zero and the log of a non-positive number are errors.  With WithNaNPropagation, they are
+// NaN instead.
+func Evaluate(curNode *OpNode, pipe Pipeline, opts ...EvalOpt) error {
+	return locate(curNode.sourceExpr(), evaluate(curNode, onePipe(pipe), newEvalConfig(opts)))
+}
+
+// EvaluatePair evaluates an expression parsed by Expr2Tree using fields from two Pipelines.
+// Fields are prefixed with the Pipeline they come from: "a.x" is field x of Pipeline a and "b.x" is field x of
+// Pipeline b.  Summary-level functions are calculated within each Pipeline, so, for instance,
+//
+//	mean(a.x) - mean(b.x)
+//
+// compares the average of x in the two Pipelines. Row-level operations across the two Pipelines require they
+// have the same number of rows.
+func EvaluatePair(curNode *OpNode, a, b Pipeline, opts ...EvalOpt) error {
+	return locate(curNode.sourceExpr(), evaluate(curNode, pairPipe(a, b), newEvalConfig(opts)))
+}
+
+// EvaluateGD evaluates an expression parsed by Expr2Tree using the fields of gd.  It is the same as Evaluate but
+// does not require a Pipeline, so it can be used on the output of, for instance, Join or Subset.
+// The result is in the *Raw item of the root node and can be added to gd with AddToGData.
+func EvaluateGD(curNode *OpNode, gd *GData, opts ...EvalOpt) error {
+	return locate(curNode.sourceExpr(), evaluate(curNode, oneGData(gd), newEvalConfig(opts)))
+}
+
+// sourceExpr returns the expression as given to Expr2Tree
+func (node *OpNode) sourceExpr() string {
+	if node.source != "" {
+		return node.source
+	}
+
+	return node.Expression
+}
+
+// pipeFinder returns the GData that has field and the name of the field in that GData
+type pipeFinder func(field string) (gd *GData, name string, err error)
+
+// onePipe is a pipeFinder for a single Pipeline
+func onePipe(pipe Pipeline) pipeFinder {
+	return func(field string) (*GData, string, error) {
+		return pipe.GData(), field, nil
+	}
+}
+
+// oneGData is a pipeFinder for a single GData
+func oneGData(gd *GData) pipeFinder {
+	return func(field string) (*GData, string, error) {
+		return gd, field, nil
+	}
+}
+
+// pairPipe is a pipeFinder for the Pipelines of EvaluatePair
+func pairPipe(a, b Pipeline) pipeFinder {
+	return func(field string) (*GData, string, error) {
+		switch {
+		case strings.HasPrefix(field, "a."):
+			return a.GData(), field[2:], nil
+		case strings.HasPrefix(field, "b."):
+			return b.GData(), field[2:], nil
+		}
+
+		return nil, "", fmt.Errorf("prefix (a. or b.) missing from field %s", field)
+	}
+}
+
+// EvalOpt is an option for Evaluate, EvaluatePair and EvaluateGD
+type EvalOpt func(cfg *evalConfig)
+
+// evalConfig holds the options of an evaluation
+type evalConfig struct {
+	nanProp bool      // division by 0 and log of non-positive numbers are NaN rather than errors
+	report  NaNReport // counts of NaNs produced
+	env     *Env      // names bound by WithEnv
+}
+
+func newEvalConfig(opts []EvalOpt) *evalConfig {
+	cfg := &evalConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// nan records a NaN produced by expr
+func (cfg *evalConfig) nan(expr string) {
+	if cfg.report != nil {
+		cfg.report[expr]++
+	}
+}
+
+// WithNaNPropagation makes division by zero and the log of a non-positive number NaN rather than an error.
+// NaNs propagate through arithmetic, so the rows affected are NaN in the result.
+func WithNaNPropagation() EvalOpt {
+	return func(cfg *evalConfig) {
+		cfg.nanProp = true
+	}
+}
+
+// WithNaNReport records in report the number of NaNs produced by each sub-expression under WithNaNPropagation.
+// report accumulates over evaluations.
+func WithNaNReport(report NaNReport) EvalOpt {
+	return func(cfg *evalConfig) {
+		cfg.report = report
+	}
+}
+
+// NaNReport is the number of NaNs produced by each sub-expression.  See WithNaNReport.
+type NaNReport map[string]int
+
+// Total returns the number of NaNs produced
+func (nr NaNReport) Total() int {
+	total := 0
+	for _, n := range nr {
+		total += n
+	}
+
+	return total
+}
+
+// String lists the sub-expressions that produced NaNs and how many
+func (nr NaNReport) String() string {
+	exprs := make([]string, 0, len(nr))
+	for expr := range nr {
+		exprs = append(exprs, expr)
+	}
+
+	sort.Strings(exprs)
+
+	lines := make([]string, len(exprs))
+	for ind, expr := range exprs {
+		lines[ind] = fmt.Sprintf("%s: %d NaN", expr, nr[expr])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// evaluate evaluates curNode, using pipes to find the data for fields
+func evaluate(curNode *OpNode, pipes pipeFinder, cfg *evalConfig) error {
 	// recurse to evaluate from bottom up
 	for ind := 0; ind < len(curNode.Inputs); ind++ {
 
-		e := Evaluate(curNode.Inputs[ind], pipe)
+		e := evaluate(curNode.Inputs[ind], pipes, cfg)
 
 		// Super special case: "exist" function that returns 1 if argument is in the pipeline
 		if ind == 0 && curNode.Func.Name == "exist" && len(curNode.Inputs) == 2 {
@@ -1606,14 +2376,24 @@ func Evaluate(curNode *OpNode, pipe Pipeline) error {
 		}
 	}
 
+	// functions that need the FTypes of the pipeline
+	if curNode.Func != nil && utilities.Has(curNode.Func.Name, delim, ftFunctions) {
+		return ftMeta(curNode, pipes)
+	}
+
+	// functions that need the number of rows of the pipeline
+	if curNode.Func != nil && utilities.Has(curNode.Func.Name, delim, randFunctions) {
+		return randomRows(curNode, pipes)
+	}
+
 	// check: are these operations: && || > >= = == != + - * / ^
 	if curNode.Func != nil && utilities.Has(curNode.Func.Name, delim, operations) {
-		return evalOps(curNode)
+		return evalOps(curNode, cfg)
 	}
 
 	// is this a function eval?
 	if curNode.Func != nil {
-		return evalFunction(curNode)
+		return evalFunction(curNode, cfg)
 	}
 
 	if curNode.stet {
@@ -1625,8 +2405,149 @@ func Evaluate(curNode *OpNode, pipe Pipeline) error {
 		return nil
 	}
 
+	// is it a bound name (see Env)?
+	if fromEnv(curNode, cfg) {
+		return nil
+	}
+
 	// must be a field from the pipeline then
-	return fromPipeline(curNode, pipe)
+	return fromPipeline(curNode, pipes)
+}
+
+// pipeRows returns the number of rows of the Pipeline(s) pipes draws from.  The Pipelines of EvaluatePair have
+// the same number of rows for row-level calculations, so the first is used.
+func pipeRows(pipes pipeFinder) (int, error) {
+	gd, _, e := pipes("")
+	if e != nil {
+		gd, _, e = pipes("a.")
+	}
+
+	if e != nil {
+		return 0, e
+	}
+
+	if gd == nil {
+		return 0, fmt.Errorf("no pipeline")
+	}
+
+	return gd.Rows(), nil
+}
+
+// randomRows evaluates the functions rand, randn and randInt, which return a value for each row of the pipeline.
+// The values are drawn from the package random number generator (see SetSeed).
+func randomRows(node *OpNode, pipes pipeFinder) error {
+	if e := consistent(node); e != nil {
+		return e
+	}
+
+	n, e := pipeRows(pipes)
+	if e != nil {
+		return e
+	}
+
+	params := make([]float64, len(node.Inputs))
+	deltas := make([]int, len(node.Inputs))
+
+	for ind, input := range node.Inputs {
+		switch input.Raw.Len() {
+		case 1:
+		case n:
+			deltas[ind] = 1
+		default:
+			return fmt.Errorf("arg %d to %s has %d rows, pipeline has %d", ind+1, node.Func.Name, input.Raw.Len(), n)
+		}
+	}
+
+	node.Raw = AllocRaw(n, reflect.Float64)
+
+	for row := 0; row < n; row++ {
+		for ind, input := range node.Inputs {
+			x, e := utilities.Any2Float64(input.Raw.Data[row*deltas[ind]])
+			if e != nil {
+				return fmt.Errorf("arg %d to %s isn't numeric", ind+1, node.Func.Name)
+			}
+
+			params[ind] = *x
+		}
+
+		switch node.Func.Name {
+		case "rand":
+			node.Raw.Data[row] = rng.Float64()
+		case "randn":
+			node.Raw.Data[row] = params[0] + params[1]*rng.NormFloat64()
+		case "randInt":
+			lo, hi := int(params[0]), int(params[1])
+			if hi < lo {
+				return fmt.Errorf("randInt: upper limit %d is less than lower limit %d", hi, lo)
+			}
+
+			node.Raw.Data[row] = float64(lo + rng.Intn(hi-lo+1))
+		}
+	}
+
+	return nil
+}
+
+// ftMeta returns FType metadata for the field named by the first Input
+func ftMeta(node *OpNode, pipes pipeFinder) error {
+	if e := consistent(node); e != nil {
+		return e
+	}
+
+	field, ok := node.Inputs[0].Raw.Data[0].(string)
+	if !ok {
+		return fmt.Errorf("arg to %s must be a field name", node.Func.Name)
+	}
+
+	gd, field, e := pipes(field)
+	if e != nil {
+		return e
+	}
+
+	var ft *FType
+	if gd != nil {
+		ft = gd.GetFType(field)
+	}
+
+	if ft == nil {
+		var fields []string
+		if gd != nil {
+			fields = gd.FieldList()
+		}
+
+		return newParseError(node.Inputs[0].Expression, fmt.Sprintf("%s: field %s not in pipeline", node.Func.Name, field),
+			suggest(field, fields))
+	}
+
+	var val float64
+
+	switch node.Func.Name {
+	case "nLevels":
+		if ft.Role == FRCts {
+			return fmt.Errorf("nLevels: field %s is continuous", field)
+		}
+
+		val = float64(ft.Cats)
+	case "isCat":
+		if ft.Role != FRCts {
+			val = 1.0
+		}
+	case "locationOf", "scaleOf":
+		if ft.FP == nil || ft.Role != FRCts {
+			return fmt.Errorf("%s: field %s has no location/scale", node.Func.Name, field)
+		}
+
+		val = ft.FP.Location
+		if node.Func.Name == "scaleOf" {
+			val = ft.FP.Scale
+		}
+	}
+
+	node.Raw = NewRaw([]any{val}, nil)
+	node.Role = FRCts
+	goNegative(node.Raw, node.Neg)
+
+	return nil
 }
 
 // goNegative negates Value if Neg is true
@@ -1716,14 +2637,38 @@ func AddToPipe(rootNode *OpNode, fieldName string, pipe Pipeline) (outPipe Pipel
 		}
 	}
 
+	return pipe, addToGData(rootNode, fieldName, pipe.GData(), nil, pipe.GetKeepRaw())
+}
+
+// AddToGData adds the Value slice in rootNode to gd. The field will have name fieldName.  If fieldName is already in
+// gd, it is replaced.  It is the analogue of AddToPipe for expressions evaluated by EvaluateGD:
+//  1. Create the *OpNode tree to evaluate the expression using Expr2Tree
+//  2. Populate the values from gd using EvaluateGD.
+//  3. Add the values to gd using AddToGData
+//
+// The value of rootNode must either be a scalar, which is repeated for each row, or have gd.Rows() elements.
+func AddToGData(rootNode *OpNode, fieldName string, gd *GData) error {
+	if rootNode.Raw == nil {
+		return fmt.Errorf("root node is nil")
+	}
+
+	if gd.FieldCount() > 0 && rootNode.Raw.Len() > 1 && rootNode.Raw.Len() != gd.Rows() {
+		return fmt.Errorf("AddToGData: expected length %d got length %d", gd.Rows(), rootNode.Raw.Len())
+	}
+
+	return addToGData(rootNode, fieldName, gd, nil, false)
+}
+
+// addToGData adds the values of rootNode to gd as field fieldName.  If ft is not nil, its FParam is used.
+func addToGData(rootNode *OpNode, fieldName string, gd *GData, ft *FType, keepRaw bool) error {
 	// drop if already there
-	_ = pipe.GData().Drop(fieldName)
+	_ = gd.Drop(fieldName)
 
 	rawx := rootNode.Raw.Data
-	if len(rawx) == 1 {
+	if len(rawx) == 1 && gd.FieldCount() > 0 {
 		tmp := rawx[0]
-		rawx = make([]any, pipe.Rows())
-		for ind := 0; ind < pipe.Rows(); ind++ {
+		rawx = make([]any, gd.Rows())
+		for ind := 0; ind < gd.Rows(); ind++ {
 			rawx[ind] = tmp
 		}
 	}
@@ -1741,21 +2686,32 @@ func AddToPipe(rootNode *OpNode, fieldName string, pipe Pipeline) (outPipe Pipel
 	var fp *FParam
 	normalize := false
 
-	// see if in the pipeline
-	if ft := pipe.GetFType(fieldName); ft != nil {
+	if ft != nil {
 		fp = ft.FP
 		normalize = ft.Normalized
+
+		if ft.Role == FRCts || ft.Role == FRCat || ft.Role == FRDate || ft.Role == FROrdinal {
+			role = ft.Role
+		}
+	}
+
+	if role == FROrdinal {
+		if fp == nil && rootNode.lvl != nil {
+			fp = &FParam{Lvl: rootNode.lvl, Unseen: UnseenError}
+		}
+
+		return gd.appendOrdinal(NewRaw(rawx, nil), fieldName, fp, keepRaw)
 	}
 
-	// see in in pre-set FTypes
+	if role == FRDate {
+		return gd.AppendDate(NewRaw(rawx, nil), fieldName, keepRaw)
+	}
 
 	if role == FRCat {
-		err = pipe.GData().AppendD(NewRaw(rawx, nil), fieldName, fp, pipe.GetKeepRaw())
-		return pipe, err
+		return gd.AppendD(NewRaw(rawx, nil), fieldName, fp, keepRaw)
 	}
 
-	err = pipe.GData().AppendC(NewRaw(rawx, nil), fieldName, normalize, fp, pipe.GetKeepRaw())
-	return pipe, err
+	return gd.AppendC(NewRaw(rawx, nil), fieldName, normalize, fp, keepRaw)
 }
 
 // setValue sets the value of the loop variable
@@ -1811,13 +2767,16 @@ func CopyNode(src *OpNode) (dest *OpNode) {
 	dest.Neg = src.Neg
 	dest.stet = src.stet
 	dest.Role = src.Role
+	dest.source = src.source
+	dest.lvl = src.lvl
 
 	if src.Func != nil {
 		dest.Func = &FuncSpec{
-			Name:   src.Func.Name,
-			Return: src.Func.Return,
-			Args:   nil,
-			Level:  src.Func.Level,
+			Name:     src.Func.Name,
+			Return:   src.Func.Return,
+			Args:     nil,
+			Level:    src.Func.Level,
+			Variadic: src.Func.Variadic,
 		}
 		dest.Func.Args = make([]reflect.Kind, len(src.Func.Args))
 		copy(dest.Func.Args, src.Func.Args)
@@ -1842,25 +2801,190 @@ func CopyNode(src *OpNode) (dest *OpNode) {
 	return dest
 }
 
+// plotState is the state of the plot being built by the plotting functions.  newPlot resets it.
+type plotState struct {
+	facet  []string // facet of each row, nil if the plot is not faceted
+	levels []string // sorted values of facet.  The ith level is drawn on axes x<i+1>, y<i+1>
+	legend bool     // a trace has a label, so the legend is shown
+	y2     bool     // a trace is on the secondary y-axis
+}
+
+// panels returns the rows of data with n rows that go into each panel of the plot.  If there is no facet, there is
+// one panel with all the rows.
+func (ps *plotState) panels(n int) ([][]int, error) {
+	if ps.facet == nil {
+		rows := make([]int, n)
+		for ind := 0; ind < n; ind++ {
+			rows[ind] = ind
+		}
+
+		return [][]int{rows}, nil
+	}
+
+	if len(ps.facet) != n {
+		return nil, fmt.Errorf("facet has %d rows, plot data has %d", len(ps.facet), n)
+	}
+
+	lvl := make(map[string]int)
+	for ind, l := range ps.levels {
+		lvl[l] = ind
+	}
+
+	rows := make([][]int, len(ps.levels))
+	for ind, f := range ps.facet {
+		rows[lvl[f]] = append(rows[lvl[f]], ind)
+	}
+
+	return rows, nil
+}
+
+// layout returns the Plotly layout for the facets and secondary axis of the plot.
+func (ps *plotState) layout(y2Title string) *grob.Layout {
+	lay := &grob.Layout{}
+
+	if ps.y2 {
+		yaxis2 := map[string]any{"overlaying": "y", "side": "right", "showgrid": false}
+		if y2Title != "" {
+			yaxis2["title"] = map[string]any{"text": y2Title}
+		}
+
+		// Layout has no yaxis2, so it's supplied by the template
+		lay.Template = map[string]any{"layout": map[string]any{"yaxis2": yaxis2}}
+	}
+
+	if ps.levels == nil {
+		return lay
+	}
+
+	cols := int(math.Ceil(math.Sqrt(float64(len(ps.levels)))))
+	rows := (len(ps.levels) + cols - 1) / cols
+	lay.Grid = &grob.LayoutGrid{Rows: int64(rows), Columns: int64(cols), Pattern: grob.LayoutGridPatternIndependent,
+		Roworder: grob.LayoutGridRoworderTopToBottom}
+
+	// title each panel with its level
+	var notes []map[string]any
+	for ind, l := range ps.levels {
+		notes = append(notes, map[string]any{"text": l, "showarrow": false, "x": 0.5, "y": 1.0,
+			"xanchor": "center", "yanchor": "bottom", "xref": axisRef("x", ind) + " domain",
+			"yref": axisRef("y", ind) + " domain"})
+	}
+
+	lay.Annotations = notes
+
+	return lay
+}
+
+// axisRef returns the Plotly reference to the axis of panel ind, e.g. "x", "x2".
+func axisRef(xy string, ind int) string {
+	if ind == 0 {
+		return xy
+	}
+
+	return fmt.Sprintf("%s%d", xy, ind+1)
+}
+
+// subsetAny returns the elements of x in rows
+func subsetAny(x []any, rows []int) []any {
+	out := make([]any, len(rows))
+	for ind, r := range rows {
+		out[ind] = x[r]
+	}
+
+	return out
+}
+
+// plotColor returns the color of a trace.  Supported are the colors in the colors constant and hex colors (#rgb or
+// #rrggbb).
+func plotColor(color *Raw) (string, error) {
+	sColor := strings.ToLower(utilities.Any2String(color.Data[0]))
+	if utilities.Has(sColor, ",", colors) {
+		return sColor, nil
+	}
+
+	if (len(sColor) == 4 || len(sColor) == 7) && sColor[0] == '#' {
+		if _, e := strconv.ParseUint(sColor[1:], 16, 32); e == nil {
+			return sColor, nil
+		}
+	}
+
+	return "", fmt.Errorf("color %s not supported", sColor)
+}
+
+// plotLabel returns the legend name of a trace from label, which may be nil.
+func plotLabel(label *Raw) string {
+	if label == nil {
+		return ""
+	}
+
+	return utilities.Any2String(label.Data[0])
+}
+
+// optInput returns the Raw of the ind input of node, if there is one
+func optInput(node *OpNode, ind int) *Raw {
+	if ind >= len(node.Inputs) {
+		return nil
+	}
+
+	return node.Inputs[ind].Raw
+}
+
 func newPlot() *Raw {
 	ret := NewRaw([]any{1}, nil)
 
 	fig = &grob.Fig{}
+	plotSt = &plotState{}
 
 	return ret
 }
 
-func plotLine(y, lineType, color *Raw) (*Raw, error) {
+// facet splits subsequent traces of the plot into panels, one for each value of by.
+func facet(by *Raw) (*Raw, error) {
+	const maxPanels = 25
+
+	ret := NewRaw([]any{1}, nil)
+
+	fac := make([]string, by.Len())
+	lvls := make(map[string]bool)
+
+	for ind, v := range by.Data {
+		fac[ind] = plotKey(v)
+		lvls[fac[ind]] = true
+	}
+
+	if len(lvls) > maxPanels {
+		return ret, fmt.Errorf("facet has %d levels, max is %d", len(lvls), maxPanels)
+	}
+
+	plotSt.facet, plotSt.levels = fac, make([]string, 0, len(lvls))
+	for l := range lvls {
+		plotSt.levels = append(plotSt.levels, l)
+	}
+
+	sort.Strings(plotSt.levels)
+
+	return ret, nil
+}
+
+func plotLine(y, lineType, color, label *Raw) (*Raw, error) {
+	// x counts the rows within each facet
+	counts := make(map[string]int)
 	x := make([]any, y.Len())
+
 	for ind := 0; ind < len(x); ind++ {
-		x[ind] = float64(ind + 1)
+		key := ""
+		if plotSt.facet != nil && len(plotSt.facet) == len(x) {
+			key = plotSt.facet[ind]
+		}
+
+		counts[key]++
+		x[ind] = float64(counts[key])
 	}
 
 	xRaw := NewRaw(x, nil)
-	return plotXY(xRaw, y, lineType, color)
+	return plotXY(xRaw, y, lineType, color, label, nil)
 }
 
-func plotXY(x, y, lineType, color *Raw) (*Raw, error) {
+func plotXY(x, y, lineType, color, label, axis *Raw) (*Raw, error) {
 	var sType grob.ScatterMode
 
 	ret := NewRaw([]any{1}, nil)
@@ -1874,9 +2998,26 @@ func plotXY(x, y, lineType, color *Raw) (*Raw, error) {
 		return ret, fmt.Errorf("plotXY slices not same length: %d, %d", x.Len(), y.Len())
 	}
 
-	sColor := strings.ToLower(utilities.Any2String(color.Data[0]))
-	if !utilities.Has(sColor, ",", colors) {
-		return ret, fmt.Errorf("color %s not supported", sColor)
+	sColor, e := plotColor(color)
+	if e != nil {
+		return ret, e
+	}
+
+	yAxis := "y"
+	if axis != nil {
+		yAxis = strings.ToLower(utilities.Any2String(axis.Data[0]))
+	}
+
+	switch yAxis {
+	case "y":
+	case "y2":
+		if plotSt.facet != nil {
+			return ret, fmt.Errorf("secondary y-axis not supported with facets")
+		}
+
+		plotSt.y2 = true
+	default:
+		return ret, fmt.Errorf("axis must be 'y' or 'y2', got %s", yAxis)
 	}
 
 	switch marker {
@@ -1886,21 +3027,40 @@ func plotXY(x, y, lineType, color *Raw) (*Raw, error) {
 		sType = grob.ScatterModeLines
 	}
 
-	tr := &grob.Scatter{
-		Type: grob.TraceTypeScatter,
-		X:    x.Data,
-		Y:    y.Data,
-		Name: "Scatter",
-		Mode: sType,
-		Line: &grob.ScatterLine{Color: sColor},
-	}
+	name := plotLabel(label)
+	plotSt.legend = plotSt.legend || name != ""
+
+	panels, e := plotSt.panels(x.Len())
+	if e != nil {
+		return ret, e
+	}
+
+	for ind, rows := range panels {
+		tr := &grob.Scatter{
+			Type:        grob.TraceTypeScatter,
+			X:           subsetAny(x.Data, rows),
+			Y:           subsetAny(y.Data, rows),
+			Name:        name,
+			Legendgroup: name,
+			Mode:        sType,
+			Line:        &grob.ScatterLine{Color: sColor},
+			Marker:      &grob.ScatterMarker{Color: sColor},
+			Xaxis:       axisRef("x", ind),
+			Yaxis:       axisRef(yAxis, ind),
+		}
+
+		// only one legend entry across the facets
+		if ind > 0 {
+			tr.Showlegend = grob.False
+		}
 
-	fig.AddTraces(tr)
+		fig.AddTraces(tr)
+	}
 
 	return ret, nil
 }
 
-func histogram(x, color, norm *Raw) (*Raw, error) {
+func histogram(x, color, norm, label *Raw) (*Raw, error) {
 	const normalized = "counts,percent,density"
 
 	ret := NewRaw([]any{1}, nil)
@@ -1909,9 +3069,9 @@ func histogram(x, color, norm *Raw) (*Raw, error) {
 		return ret, fmt.Errorf("histogram only for float64 currently, got %v", x.Kind)
 	}
 
-	sColor := strings.ToLower(utilities.Any2String(color.Data[0]))
-	if !utilities.Has(sColor, ",", colors) {
-		return ret, fmt.Errorf("color %s not supported", sColor)
+	sColor, e := plotColor(color)
+	if e != nil {
+		return ret, e
 	}
 
 	sNorm := strings.ToLower(utilities.Any2String(norm.Data[0]))
@@ -1929,21 +3089,279 @@ func histogram(x, color, norm *Raw) (*Raw, error) {
 		normGrob = grob.HistogramHistnormPercent
 	}
 
-	xRaw, err := utilities.AnySlice2Float64(x.Data)
-	if err != nil {
-		return ret, err
+	name := plotLabel(label)
+	plotSt.legend = plotSt.legend || name != ""
+
+	panels, e := plotSt.panels(x.Len())
+	if e != nil {
+		return ret, e
 	}
 
-	tr := &grob.Histogram{X: xRaw, Type: grob.TraceTypeHistogram,
-		Histnorm: normGrob,
-		Marker:   &grob.HistogramMarker{Color: sColor}}
+	for ind, rows := range panels {
+		xRaw, err := utilities.AnySlice2Float64(subsetAny(x.Data, rows))
+		if err != nil {
+			return ret, err
+		}
+
+		tr := &grob.Histogram{X: xRaw, Type: grob.TraceTypeHistogram,
+			Histnorm:    normGrob,
+			Name:        name,
+			Legendgroup: name,
+			Marker:      &grob.HistogramMarker{Color: sColor},
+			Xaxis:       axisRef("x", ind),
+			Yaxis:       axisRef("y", ind)}
 
-	fig.AddTraces(tr)
+		if ind > 0 {
+			tr.Showlegend = grob.False
+		}
+
+		fig.AddTraces(tr)
+	}
 
 	return ret, nil
 }
 
-func render(fileName, title, xlab, ylab *Raw) (*Raw, error) {
+// plotKeys returns the values of r as strings for a categorical axis.  A single value is repeated n times.
+func plotKeys(r *Raw, n int) ([]string, error) {
+	if r.Len() != 1 && r.Len() != n {
+		return nil, fmt.Errorf("plot slices not same length: %d, %d", r.Len(), n)
+	}
+
+	keys := make([]string, n)
+	for ind := 0; ind < n; ind++ {
+		keys[ind] = plotKey(r.Data[utilities.MinInt(ind, r.Len()-1)])
+	}
+
+	return keys, nil
+}
+
+// plotNums returns the values of r as float64.  A single value is repeated n times.
+func plotNums(r *Raw, n int, fn string) ([]float64, error) {
+	if r.Len() != 1 && r.Len() != n {
+		return nil, fmt.Errorf("plot slices not same length: %d, %d", r.Len(), n)
+	}
+
+	x, e := utilities.AnySlice2Float64(r.Data)
+	if e != nil {
+		return nil, fmt.Errorf("%s values must be numeric", fn)
+	}
+
+	for len(x) < n {
+		x = append(x, x[0])
+	}
+
+	return x, nil
+}
+
+// plotKey converts a value of a categorical axis (or facet) to a string
+func plotKey(v any) string {
+	if dt, ok := v.(time.Time); ok {
+		return dt.Format("2006-01-02")
+	}
+
+	return utilities.Any2String(v)
+}
+
+// plotAggregate aggregates value over the rows with each key, using agg, which is one of sum, mean, count.  Only
+// the rows in rows are used.  It returns the sorted keys and their aggregates.
+func plotAggregate(keys []string, value []float64, rows []int, agg string) ([]string, []float64) {
+	sums, counts := make(map[string]float64), make(map[string]int)
+	for _, r := range rows {
+		sums[keys[r]] += value[r]
+		counts[keys[r]]++
+	}
+
+	out := make([]string, 0, len(sums))
+	for k := range sums {
+		out = append(out, k)
+	}
+
+	sort.Strings(out)
+
+	aggs := make([]float64, len(out))
+	for ind, k := range out {
+		switch agg {
+		case "mean":
+			aggs[ind] = sums[k] / float64(counts[k])
+		case "count":
+			aggs[ind] = float64(counts[k])
+		default:
+			aggs[ind] = sums[k]
+		}
+	}
+
+	return out, aggs
+}
+
+// plotAggType returns the aggregation of a barplot or heatmap from agg, which may be nil.
+func plotAggType(agg *Raw) (string, error) {
+	const aggs = "sum,mean,count"
+
+	if agg == nil {
+		return "sum", nil
+	}
+
+	sAgg := strings.ToLower(utilities.Any2String(agg.Data[0]))
+	if !utilities.Has(sAgg, ",", aggs) {
+		return "", fmt.Errorf("aggregation must be sum, mean or count, got %s", sAgg)
+	}
+
+	return sAgg, nil
+}
+
+// boxplot adds a box plot of x for each value of by
+func boxplot(x, by *Raw) (*Raw, error) {
+	ret := NewRaw([]any{1}, nil)
+
+	n := utilities.MaxInt(x.Len(), by.Len())
+
+	xv, e := plotNums(x, n, "boxplot")
+	if e != nil {
+		return ret, e
+	}
+
+	keys, e := plotKeys(by, n)
+	if e != nil {
+		return ret, e
+	}
+
+	panels, e := plotSt.panels(len(xv))
+	if e != nil {
+		return ret, e
+	}
+
+	for ind, rows := range panels {
+		cats, ys := make([]string, len(rows)), make([]float64, len(rows))
+		for r, row := range rows {
+			cats[r], ys[r] = keys[row], xv[row]
+		}
+
+		fig.AddTraces(&grob.Box{Type: grob.TraceTypeBox, X: cats, Y: ys, Xaxis: axisRef("x", ind),
+			Yaxis: axisRef("y", ind)})
+	}
+
+	return ret, nil
+}
+
+// barplot adds a bar for each value of cat whose height is the aggregate of value
+func barplot(cat, value, agg *Raw) (*Raw, error) {
+	ret := NewRaw([]any{1}, nil)
+
+	sAgg, e := plotAggType(agg)
+	if e != nil {
+		return ret, e
+	}
+
+	n := utilities.MaxInt(cat.Len(), value.Len())
+
+	yv, e := plotNums(value, n, "barplot")
+	if e != nil {
+		return ret, e
+	}
+
+	keys, e := plotKeys(cat, n)
+	if e != nil {
+		return ret, e
+	}
+
+	panels, e := plotSt.panels(len(yv))
+	if e != nil {
+		return ret, e
+	}
+
+	for ind, rows := range panels {
+		cats, heights := plotAggregate(keys, yv, rows, sAgg)
+		fig.AddTraces(&grob.Bar{Type: grob.TraceTypeBar, X: cats, Y: heights, Xaxis: axisRef("x", ind),
+			Yaxis: axisRef("y", ind)})
+	}
+
+	return ret, nil
+}
+
+// heatmap adds a heatmap of the aggregate of value for each combination of x and y
+func heatmap(x, y, value, agg *Raw) (*Raw, error) {
+	ret := NewRaw([]any{1}, nil)
+
+	sAgg, e := plotAggType(agg)
+	if e != nil {
+		return ret, e
+	}
+
+	n := utilities.MaxInt(x.Len(), y.Len(), value.Len())
+
+	zv, e := plotNums(value, n, "heatmap")
+	if e != nil {
+		return ret, e
+	}
+
+	xKeys, e := plotKeys(x, n)
+	if e != nil {
+		return ret, e
+	}
+
+	yKeys, e := plotKeys(y, n)
+	if e != nil {
+		return ret, e
+	}
+
+	// aggregate over the cells
+	cells := make([]string, len(zv))
+	for ind := range cells {
+		cells[ind] = xKeys[ind] + "\x00" + yKeys[ind]
+	}
+
+	panels, e := plotSt.panels(len(zv))
+	if e != nil {
+		return ret, e
+	}
+
+	for ind, rows := range panels {
+		xs, ys := make(map[string]int), make(map[string]int)
+		for _, r := range rows {
+			xs[xKeys[r]], ys[yKeys[r]] = 0, 0
+		}
+
+		xLvls, yLvls := sortedKeys(xs), sortedKeys(ys)
+		for c, k := range xLvls {
+			xs[k] = c
+		}
+
+		for r, k := range yLvls {
+			ys[k] = r
+		}
+
+		// cells with no data are nil
+		z := make([][]any, len(yLvls))
+		for r := range z {
+			z[r] = make([]any, len(xLvls))
+		}
+
+		keys, aggs := plotAggregate(cells, zv, rows, sAgg)
+		for k, key := range keys {
+			xy := strings.Split(key, "\x00")
+			z[ys[xy[1]]][xs[xy[0]]] = aggs[k]
+		}
+
+		fig.AddTraces(&grob.Heatmap{Type: grob.TraceTypeHeatmap, X: xLvls, Y: yLvls, Z: z, Xaxis: axisRef("x", ind),
+			Yaxis: axisRef("y", ind)})
+	}
+
+	return ret, nil
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func render(fileName, title, xlab, ylab, y2lab *Raw) (*Raw, error) {
 	ret := NewRaw([]any{1}, nil)
 
 	sFile := utilities.Any2String(fileName.Data[0])
@@ -1959,13 +3377,13 @@ func render(fileName, title, xlab, ylab *Raw) (*Raw, error) {
 		XTitle:   sXlab,
 		YTitle:   sYlab,
 		STitle:   "",
-		Legend:   false,
+		Legend:   plotSt.legend,
 		Height:   Height,
 		Width:    Width,
 		FileName: sFile,
 	}
 
-	return ret, utilities.Plotter(fig, nil, pd)
+	return ret, plotter(fig, plotSt.layout(plotLabel(y2lab)), pd)
 }
 
 func setPlotDim(width, height *Raw) (*Raw, error) {
@@ -1,7 +1,9 @@
 package seafan
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"strings"
@@ -336,6 +338,169 @@ func TestStrLen(t *testing.T) {
 	}
 }
 
+func TestCross(t *testing.T) {
+	Verbose = false
+	var err error
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest6.csv", nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	root := &OpNode{Expression: "cross(s1,s2)"}
+	if err = Expr2Tree(root); err != nil {
+		panic(err)
+	}
+	if err = Evaluate(root, pipe); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, []any{"a:b", "bb:a"}, root.Raw.Data)
+
+	pipe, err = AddToPipe(root, "s1s2", pipe)
+	assert.Nil(t, err)
+
+	ft := pipe.GetFType("s1s2")
+	assert.Equal(t, FRCat, ft.Role)
+	assert.Equal(t, 2, len(ft.FP.Lvl))
+}
+
+func TestEvaluateGD(t *testing.T) {
+	Verbose = false
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "c"}, nil), "s", nil, false))
+
+	sub, e := gd.Subset([]int{1, 2, 3})
+	assert.Nil(t, e)
+
+	root := &OpNode{Expression: "x * 2 + mean(x)"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.Nil(t, EvaluateGD(root, sub))
+	assert.InDeltaSlice(t, []any{7.0, 9.0, 11.0}, root.Raw.Data, 1e-10)
+
+	assert.Nil(t, AddToGData(root, "y", sub))
+	raw, e := sub.GetRaw("y")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{7.0, 9.0, 11.0}, raw.Data)
+	assert.Equal(t, FRCts, sub.GetFType("y").Role)
+
+	root = &OpNode{Expression: "cross(s, 'z')"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.Nil(t, EvaluateGD(root, sub))
+	assert.Nil(t, AddToGData(root, "sz", sub))
+	assert.Equal(t, FRCat, sub.GetFType("sz").Role)
+	assert.Equal(t, 3, len(sub.GetFType("sz").FP.Lvl))
+
+	// scalars are repeated for each row
+	root = &OpNode{Expression: "nLevels('s')"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.Nil(t, EvaluateGD(root, gd))
+	assert.Nil(t, AddToGData(root, "n", gd))
+	raw, e = gd.GetRaw("n")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{3.0, 3.0, 3.0, 3.0}, raw.Data)
+
+	// the original GData is unchanged by additions to the subset
+	assert.Nil(t, gd.Get("y"))
+
+	root = &OpNode{Expression: "w + 1"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, EvaluateGD(root, gd))
+
+	root = &OpNode{Raw: NewRaw([]any{1.0, 2.0}, nil)}
+	assert.NotNil(t, AddToGData(root, "bad", gd))
+}
+
+func TestFTMeta(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	fp := pipe.GetFType("Field3").FP
+
+	assert.Equal(t, []any{float64(7)}, tester("nLevels('Field1')", pipe))
+	assert.Equal(t, []any{float64(1)}, tester("isCat('Field1')", pipe))
+	assert.Equal(t, []any{float64(0)}, tester("isCat('Field3')", pipe))
+	assert.Equal(t, []any{fp.Location}, tester("locationOf('Field3')", pipe))
+	assert.Equal(t, []any{fp.Scale}, tester("scaleOf('Field3')", pipe))
+
+	act := tester("(Field3-locationOf('Field3'))/scaleOf('Field3')", pipe)
+	assert.InDelta(t, (3.0-fp.Location)/fp.Scale, act[0].(float64), 1e-10)
+
+	root := &OpNode{Expression: "scaleOf('Field1')"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, pipe))
+}
+
+func TestEvaluatePair(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipeA, e := CSVToPipe(data+"/pipeTest8.csv", nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	pipeB, e := CSVToPipe(data+"/pipeTest9.csv", nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	exprs := []string{"a.c - b.d", "mean(a.a) - mean(b.b)", "locationOf('a.c') - locationOf('b.c')"}
+	exp := [][]any{{-1.0, 0.0, 1.0}, {5.0 / 3.0}, {0.0}}
+
+	for ind, expr := range exprs {
+		root := &OpNode{Expression: expr}
+		if err := Expr2Tree(root); err != nil {
+			panic(err)
+		}
+
+		assert.Nil(t, EvaluatePair(root, pipeA, pipeB))
+		assert.InDeltaSlice(t, exp[ind], root.Raw.Data, 1e-10)
+	}
+
+	root := &OpNode{Expression: "c + 1"}
+	if err := Expr2Tree(root); err != nil {
+		panic(err)
+	}
+
+	assert.NotNil(t, EvaluatePair(root, pipeA, pipeB))
+}
+
+func TestOpNode_ToSQL(t *testing.T) {
+	exprs := []string{"x+y*2", "x-y", "-log(x)", "x^2", "x>1 && y", "if(x==1,'a','b')", "dateAdd(dt,3) >= '20230101'",
+		"mean(x)", "strPos(s,'a')", "dateDiff(d1,d2,'month')"}
+	exp := []string{"(x + (y * 2))", "(x - y)", "-log(x)", "pow(x, 2)", "((x > 1) AND (y > 0))", "if((x = 1), 'a', 'b')",
+		"(addMonths(dt, 3) >= toDate('2023-01-01'))", "avg(x)", "if(position(s, 'a') = 0, -1, position(s, 'a'))",
+		"dateDiff('month', d2, d1)"}
+
+	for ind, expr := range exprs {
+		root := &OpNode{Expression: expr}
+		assert.Nil(t, Expr2Tree(root))
+
+		sql, e := root.ToSQL("clickhouse")
+		assert.Nil(t, e)
+		assert.Equal(t, exp[ind], sql)
+	}
+
+	root := &OpNode{Expression: "lag(x,0)"}
+	assert.Nil(t, Expr2Tree(root))
+	_, e := root.ToSQL("clickhouse")
+	assert.NotNil(t, e)
+
+	root = &OpNode{Expression: "x+1"}
+	assert.Nil(t, Expr2Tree(root))
+	_, e = root.ToSQL("postgres")
+	assert.NotNil(t, e)
+}
+
 func TestGoNegative(t *testing.T) {
 	Verbose = false
 	var err error
@@ -671,6 +836,47 @@ func TestEvaluate_date(t *testing.T) {
 	assert.ElementsMatch(t, root.Raw.Data, results)
 }
 
+func TestToDateFmt(t *testing.T) {
+	Verbose = false
+
+	pipe, e := VecFromAny([][]any{{"2022-03-25", "2023-02-28 10:30:00"}, {"25.03.2022", "28.02.2023"}},
+		[]string{"iso", "eu"}, nil)
+	assert.Nil(t, e)
+
+	exp := []any{time.Date(2022, 3, 25, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC)}
+
+	assert.Equal(t, exp, tester("toDateFmt(eu, '02.01.2006')", pipe))
+	assert.Equal(t, []any{exp[0], exp[1].(time.Time).Add(630 * time.Minute)}, tester("toDate(iso)", pipe))
+	assert.Equal(t, []any{int32(2022), int32(2023)}, tester("year(toDate(iso))", pipe))
+
+	// string constants are compared as dates only if the other operand is a date
+	assert.Equal(t, []any{0.0, 1.0}, tester("toDate(iso) > '2022-12-31'", pipe))
+	assert.Equal(t, []any{1.0, 0.0}, tester("iso == '2022-03-25'", pipe))
+	assert.Equal(t, []any{0.0, 1.0}, tester("eu != '25.03.2022'", pipe))
+
+	root := &OpNode{Expression: "toDate(eu)"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, pipe))
+
+	root = &OpNode{Expression: "toDateFmt(eu, eu)"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, pipe))
+
+	// European dates take precedence once registered
+	save, saveCh := DateFormats(), chutils.DateFormats
+	defer func() { dateFormats, chutils.DateFormats = save, saveCh }()
+
+	RegisterDateFormat("02/01/2006", "02.01.2006")
+	assert.Equal(t, "02/01/2006", DateFormats()[0])
+	assert.Equal(t, "02.01.2006", chutils.DateFormats[1])
+
+	assert.Equal(t, exp, tester("toDate(eu)", pipe))
+	assert.Equal(t, []any{time.Date(2023, 4, 3, 0, 0, 0, 0, time.UTC)}, tester("toDate('03/04/2023')", pipe))
+
+	// constants compared to dates use the registered formats: '01/04/2022' is April 1
+	assert.Equal(t, []any{1.0, 0.0}, tester("toDate(eu) < '01/04/2022'", pipe))
+}
+
 // tests conditional statements with strings
 func TestExpr2Tree(t *testing.T) {
 	Verbose = false
@@ -1230,3 +1436,276 @@ func ExampleEvaluate_2() {
 
 	// output:
 }
+
+func TestParseError(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest6.csv", nil, false)
+	assert.Nil(t, e)
+
+	tree := []struct {
+		expr    string
+		sub     string
+		offset  int
+		suggest string
+	}{
+		{"x + mxa(y)", "mxa(y)", 4, "max"},
+		{"x + meen(y)", "meen(y)", 4, "mean"},
+		{"2 * exp(x, y)", "exp(x,y)", 4, ""},
+		{"(x + y", "(", 0, ""},
+		{"x + y) * 2", ")", 5, ""},
+		{"x + zzzzz(y)", "zzzzz(y)", 4, ""},
+	}
+
+	for _, tr := range tree {
+		root := &OpNode{Expression: tr.expr}
+		err := Expr2Tree(root)
+
+		var pe *ParseError
+		assert.True(t, errors.As(err, &pe), tr.expr)
+		assert.ErrorIs(t, err, ErrParser)
+		assert.Equal(t, tr.sub, pe.Sub, tr.expr)
+		assert.Equal(t, tr.offset, pe.Offset, tr.expr)
+		assert.Equal(t, tr.suggest, pe.Suggest, tr.expr)
+		assert.Equal(t, tr.expr, pe.Expr)
+	}
+
+	// the types of FRCat fields, like s1, aren't known until the data is seen, so Compile can't find all problems
+	eval := []struct {
+		expr     string
+		sub      string
+		offset   int
+		suggest  string
+		compiles bool
+	}{
+		{"x + 2 * yy", "yy", 8, "y", false},
+		{"exp(s1)", "s1", 4, "", true},
+		{"scaleOf('xx') + 1", "'xx'", 8, "x", false},
+	}
+
+	for _, ev := range eval {
+		root := &OpNode{Expression: ev.expr}
+		assert.Nil(t, Expr2Tree(root))
+
+		err := Evaluate(root, pipe)
+
+		var pe *ParseError
+		assert.True(t, errors.As(err, &pe), ev.expr)
+		assert.Equal(t, ev.sub, pe.Sub, ev.expr)
+		assert.Equal(t, ev.offset, pe.Offset, ev.expr)
+		assert.Equal(t, ev.suggest, pe.Suggest, ev.expr)
+
+		prog, err := Compile(ev.expr, pipe.GetFTypes())
+		if ev.compiles {
+			assert.Nil(t, err)
+			_, err = prog.Run(pipe)
+		}
+
+		assert.True(t, errors.As(err, &pe), ev.expr)
+		assert.Equal(t, ev.offset, pe.Offset, ev.expr)
+	}
+
+	root := &OpNode{Expression: "x + mxa(y)"}
+	assert.Equal(t, `unknown function: mxa in "mxa(y)" at offset 4 of "x + mxa(y)"; did you mean max?`,
+		Expr2Tree(root).Error())
+}
+
+func TestSafeDivision(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest6.csv", nil, false)
+	assert.Nil(t, e)
+
+	root := &OpNode{Expression: "safeDiv(x, y-2, -1)"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.Nil(t, Evaluate(root, pipe))
+	assert.Equal(t, []any{-1.0, 20.0 / -3.0}, root.Raw.Data)
+
+	// errors by default
+	root = &OpNode{Expression: "x/(y-2) + log(y)"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, pipe))
+
+	report := make(NaNReport)
+	assert.Nil(t, Evaluate(root, pipe, WithNaNPropagation(), WithNaNReport(report)))
+	assert.True(t, math.IsNaN(root.Raw.Data[0].(float64)))
+	assert.True(t, math.IsNaN(root.Raw.Data[1].(float64)))
+	assert.Equal(t, NaNReport{"x/(y-2)": 1, "log(y)": 1}, report)
+	assert.Equal(t, 2, report.Total())
+	assert.Equal(t, "log(y): 1 NaN\nx/(y-2): 1 NaN", report.String())
+
+	prog, e := Compile("log(x)/y", pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	raw, e := prog.Run(pipe, WithNaNPropagation())
+	assert.Nil(t, e)
+	assert.Equal(t, []any{0.0, math.Log(20) / -1}, raw.Data)
+}
+
+func TestNot(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest6.csv", nil, false)
+	assert.Nil(t, e)
+
+	exprs := []struct {
+		expr string
+		exp  []any
+	}{
+		{"!(s1=='a') && x>1", []any{0.0, 1.0}},
+		{"not(s1=='a')", []any{0.0, 1.0}},
+		{"!x>1", []any{0.0, 0.0}},
+		{"!!(y > 0)", []any{1.0, 0.0}},
+		{"!(x>1) || !(y>0)", []any{1.0, 1.0}},
+		{"!y + 1", []any{1.0, 2.0}},
+		{"x != 1 && !(y==2)", []any{0.0, 1.0}},
+		{"if(!(x>1), 'small', 'big')", []any{"small", "big"}},
+	}
+
+	for _, ex := range exprs {
+		root := &OpNode{Expression: ex.expr}
+		assert.Nil(t, Expr2Tree(root), ex.expr)
+		assert.Nil(t, Evaluate(root, pipe), ex.expr)
+		assert.Equal(t, ex.exp, root.Raw.Data, ex.expr)
+	}
+
+	root := &OpNode{Expression: "!(state=='CA') && fico<640"}
+	assert.Nil(t, Expr2Tree(root))
+
+	sql, e := root.ToSQL("clickhouse")
+	assert.Nil(t, e)
+	assert.Equal(t, "((NOT (state = 'CA')) AND (fico < 640))", sql)
+
+	root = &OpNode{Expression: "x && !"}
+	assert.ErrorIs(t, Expr2Tree(root), ErrParser)
+}
+
+func TestIn(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest6.csv", nil, false)
+	assert.Nil(t, e)
+
+	exprs := []struct {
+		expr string
+		exp  []any
+	}{
+		{"in(s1,'a','c')", []any{1.0, 0.0}},
+		{"in(s1,'bb')", []any{0.0, 1.0}},
+		{"in(s1,s2)", []any{0.0, 0.0}},
+		{"in(x,3,20,40)", []any{0.0, 1.0}},
+		{"in(x+y,3,19)", []any{1.0, 1.0}},
+		{"!in(s2,'a') && x>0", []any{1.0, 0.0}},
+		{"in(toDate(d1),'2023-03-01')", []any{1.0, 0.0}},
+	}
+
+	for _, ex := range exprs {
+		root := &OpNode{Expression: ex.expr}
+		assert.Nil(t, Expr2Tree(root), ex.expr)
+		assert.Nil(t, Evaluate(root, pipe), ex.expr)
+		assert.Equal(t, ex.exp, root.Raw.Data, ex.expr)
+
+		_, e := Compile(ex.expr, pipe.GetFTypes())
+		assert.Nil(t, e, ex.expr)
+	}
+
+	root := &OpNode{Expression: "in(s1)"}
+	assert.ErrorIs(t, Expr2Tree(root), ErrParser)
+
+	root = &OpNode{Expression: "in(state,'CA','TX') && fico>700"}
+	assert.Nil(t, Expr2Tree(root))
+
+	sql, e := root.ToSQL("clickhouse")
+	assert.Nil(t, e)
+	assert.Equal(t, "((state IN ('CA', 'TX')) AND (fico > 700))", sql)
+}
+
+func TestStrRegex(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest7.csv", nil, false)
+	assert.Nil(t, e)
+
+	exprs := []struct {
+		expr string
+		exp  []any
+	}{
+		{"strMatch(str,'^[a-g]')", []any{0.0, 0.0, 1.0, 1.0}},
+		{"strMatch(str,look)", []any{1.0, 1.0, 1.0, 1.0}},
+		{"strExtract(str,'(b+)(c?)',1)", []any{"", "", "b", "bbbb"}},
+		{"strExtract(str,'(b+)(c?)',2)", []any{"", "", "", "c"}},
+		{"strExtract(str,'[a-z]+ [a-z]+',0)", []any{"", "hello there", "", ""}},
+		{"strReplaceRe(str,'[aeiou]','_')", []any{"t_stm_", "h_ll_ th_r_", "g__dby_", "_bbbbc"}},
+		{"strReplaceRe(str,'(b+)','<$1>')", []any{"testme", "hello there", "good<b>ye", "a<bbbb>c"}},
+	}
+
+	for _, ex := range exprs {
+		root := &OpNode{Expression: ex.expr}
+		assert.Nil(t, Expr2Tree(root), ex.expr)
+		assert.Nil(t, Evaluate(root, pipe), ex.expr)
+		assert.Equal(t, ex.exp, root.Raw.Data, ex.expr)
+	}
+
+	for _, expr := range []string{"strMatch(str,'[')", "strExtract(str,'(b+)',2)"} {
+		root := &OpNode{Expression: expr}
+		assert.Nil(t, Expr2Tree(root), expr)
+		assert.NotNil(t, Evaluate(root, pipe), expr)
+	}
+
+	root := &OpNode{Expression: "strReplaceRe(id,'([0-9]+)-x','$1')"}
+	assert.Nil(t, Expr2Tree(root))
+
+	sql, e := root.ToSQL("clickhouse")
+	assert.Nil(t, e)
+	assert.Equal(t, `replaceRegexpAll(id, '([0-9]+)-x', '\\1')`, sql)
+}
+
+func TestRandom(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	draw := func(expr string) []any {
+		root := &OpNode{Expression: expr}
+		assert.Nil(t, Expr2Tree(root), expr)
+		assert.Nil(t, Evaluate(root, pipe), expr)
+		assert.Equal(t, pipe.Rows(), root.Raw.Len(), expr)
+
+		return root.Raw.Data
+	}
+
+	for _, x := range draw("rand()") {
+		assert.True(t, x.(float64) >= 0.0 && x.(float64) < 1.0)
+	}
+
+	for _, x := range draw("randInt(2,4)") {
+		assert.Contains(t, []any{2.0, 3.0, 4.0}, x)
+	}
+
+	for _, x := range draw("randn(row,0)") {
+		assert.Contains(t, []any{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0}, x)
+	}
+
+	SetSeed(4)
+	first := draw("randn(0,1)")
+	SetSeed(4)
+	assert.Equal(t, first, draw("randn(0,1)"))
+
+	root := &OpNode{Expression: "randInt(4,2)"}
+	assert.Nil(t, Expr2Tree(root))
+	assert.NotNil(t, Evaluate(root, pipe))
+
+	root = &OpNode{Expression: "rand() < 0.8"}
+	assert.Nil(t, Expr2Tree(root))
+
+	sql, e := root.ToSQL("clickhouse")
+	assert.Nil(t, e)
+	assert.Equal(t, "(randCanonical() < 0.8)", sql)
+}
@@ -0,0 +1,208 @@
+package seafan
+
+// pca.go implements principal components of continuous fields
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// PCALoadings is the rotation found by PCA.  It can be applied to other data by Apply.
+type PCALoadings struct {
+	Fields   []string    `json:"fields"`   // Fields are the input fields
+	Location []float64   `json:"location"` // Location is the mean of each field in the PCA data
+	Scale    []float64   `json:"scale"`    // Scale is the standard deviation of each field in the PCA data
+	Vectors  [][]float64 `json:"vectors"`  // Vectors[i] is the loading of component i+1 on Fields
+	Variance []float64   `json:"variance"` // Variance is the variance of each component in the PCA data
+	Prefix   string      `json:"prefix"`   // Prefix of the names of the score fields, "pc" if empty
+}
+
+// PCAOpts are options for PCA
+type PCAOpts func(pl *PCALoadings)
+
+// WithPCAPrefix sets the prefix of the names of the score fields: <prefix>1, <prefix>2, ....  The default is "pc".
+func WithPCAPrefix(prefix string) PCAOpts {
+	return func(pl *PCALoadings) {
+		pl.Prefix = prefix
+	}
+}
+
+// name returns the name of the field of component ind (starting at 1)
+func (pl *PCALoadings) name(ind int) string {
+	if pl.Prefix == "" {
+		return fmt.Sprintf("pc%d", ind)
+	}
+
+	return fmt.Sprintf("%s%d", pl.Prefix, ind)
+}
+
+// PCA finds the first nComp principal components of the FRCts fields in gd and appends the scores as the fields
+// pc1, pc2, ... (see WithPCAPrefix).  The components are found on the standardized fields (mean 0, standard
+// deviation 1 in the raw units).  The loadings are returned so that the same rotation can be applied to other data
+// by Apply.
+func PCA(gd *GData, fields []string, nComp int, opts ...PCAOpts) (*PCALoadings, error) {
+	if nComp < 1 || nComp > len(fields) {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("PCA: nComp must be between 1 and %d", len(fields)))
+	}
+
+	if gd.Rows() <= len(fields) {
+		return nil, Wrapper(ErrGData, "PCA: need more rows than fields")
+	}
+
+	pl := &PCALoadings{Fields: fields}
+	for _, o := range opts {
+		o(pl)
+	}
+
+	cols, e := pl.rawCols(gd)
+	if e != nil {
+		return nil, Wrapper(e, "PCA")
+	}
+
+	for _, x := range cols {
+		m, s := stat.MeanStdDev(x, nil)
+		if s < 1e-8 {
+			return nil, Wrapper(ErrGData, "PCA: a field has 0 variance")
+		}
+
+		pl.Location = append(pl.Location, m)
+		pl.Scale = append(pl.Scale, s)
+	}
+
+	data := pl.standardize(cols, gd.Rows())
+
+	var pc stat.PC
+	if ok := pc.PrincipalComponents(data, nil); !ok {
+		return nil, Wrapper(ErrGData, "PCA: decomposition failed")
+	}
+
+	var vecs mat.Dense
+	pc.VectorsTo(&vecs)
+	vars := pc.VarsTo(nil)
+
+	for comp := 0; comp < nComp; comp++ {
+		pl.Vectors = append(pl.Vectors, mat.Col(nil, comp, &vecs))
+		pl.Variance = append(pl.Variance, vars[comp])
+	}
+
+	if e := pl.Apply(gd); e != nil {
+		return nil, Wrapper(e, "PCA")
+	}
+
+	return pl, nil
+}
+
+// Apply appends the component scores pc1, pc2, ... to gd using the loadings in pl.  It is an error if gd already has
+// a field of that name.
+func (pl *PCALoadings) Apply(gd *GData) error {
+	for comp := range pl.Vectors {
+		if gd.Get(pl.name(comp+1)) != nil {
+			return Wrapper(ErrGData, fmt.Sprintf("Apply: field %s already exists", pl.name(comp+1)))
+		}
+	}
+
+	cols, e := pl.rawCols(gd)
+	if e != nil {
+		return Wrapper(e, "Apply")
+	}
+
+	data := pl.standardize(cols, gd.Rows())
+
+	var scores mat.Dense
+	scores.Mul(data, mat.NewDense(len(pl.Fields), len(pl.Vectors), pl.vectorData()))
+
+	for comp := range pl.Vectors {
+		if e := gd.AppendC(NewRawCast(mat.Col(nil, comp, &scores), nil), pl.name(comp+1), false, nil, false); e != nil {
+			return Wrapper(e, "Apply")
+		}
+	}
+
+	return nil
+}
+
+// Save saves the loadings to a json file
+func (pl *PCALoadings) Save(fileName string) error {
+	js, e := json.MarshalIndent(pl, "", "  ")
+	if e != nil {
+		return e
+	}
+
+	return os.WriteFile(fileName, js, 0644)
+}
+
+// LoadPCA loads loadings saved by Save
+func LoadPCA(fileName string) (*PCALoadings, error) {
+	js, e := os.ReadFile(fileName)
+	if e != nil {
+		return nil, e
+	}
+
+	pl := &PCALoadings{}
+	if e := json.Unmarshal(js, pl); e != nil {
+		return nil, e
+	}
+
+	if len(pl.Location) != len(pl.Fields) || len(pl.Scale) != len(pl.Fields) {
+		return nil, Wrapper(ErrGData, "LoadPCA: bad file")
+	}
+
+	for _, v := range pl.Vectors {
+		if len(v) != len(pl.Fields) {
+			return nil, Wrapper(ErrGData, "LoadPCA: bad file")
+		}
+	}
+
+	return pl, nil
+}
+
+// rawCols returns the fields of pl from gd in the units of the raw data
+func (pl *PCALoadings) rawCols(gd *GData) ([][]float64, error) {
+	cols := make([][]float64, len(pl.Fields))
+
+	for ind, field := range pl.Fields {
+		d := gd.Get(field)
+		if d == nil {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("field %s not found", field))
+		}
+
+		if d.FT.Role != FRCts {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("field %s is not FRCts", field))
+		}
+
+		x := make([]float64, gd.Rows())
+		copy(x, d.Floats())
+		cols[ind] = UnNormalize(x, d.FT)
+	}
+
+	return cols, nil
+}
+
+// standardize returns the rows x fields matrix of cols standardized by the Location and Scale of pl
+func (pl *PCALoadings) standardize(cols [][]float64, rows int) *mat.Dense {
+	data := mat.NewDense(rows, len(cols), nil)
+
+	for col, x := range cols {
+		for row, xv := range x {
+			data.Set(row, col, (xv-pl.Location[col])/pl.Scale[col])
+		}
+	}
+
+	return data
+}
+
+// vectorData returns the loadings as a row-major fields x components slice
+func (pl *PCALoadings) vectorData() []float64 {
+	out := make([]float64, 0, len(pl.Fields)*len(pl.Vectors))
+
+	for row := range pl.Fields {
+		for _, v := range pl.Vectors {
+			out = append(out, v[row])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,70 @@
+package seafan
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestPCA(t *testing.T) {
+	x1 := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	x2 := []float64{2.1, 3.9, 6.2, 8.1, 9.8, 12.2, 13.9, 16.1}
+	x3 := []float64{5, 1, 4, 2, 3, 5, 1, 2}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast(x1, nil), "x1", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast(x2, nil), "x2", true, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast(x3, nil), "x3", false, nil, false))
+
+	pl, e := PCA(gd, []string{"x1", "x2", "x3"}, 2)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(pl.Vectors))
+
+	// x1 and x2 are nearly collinear, so the first component loads equally on them
+	assert.InDelta(t, math.Abs(pl.Vectors[0][0]), math.Abs(pl.Vectors[0][1]), 0.01)
+	assert.Greater(t, pl.Variance[0], pl.Variance[1])
+
+	// the scores are uncorrelated with the variances of the components
+	pc1, pc2 := gd.Get("pc1").Data.([]float64), gd.Get("pc2").Data.([]float64)
+	assert.InDelta(t, 0.0, stat.Correlation(pc1, pc2, nil), 1e-8)
+	assert.InDelta(t, pl.Variance[0], stat.Variance(pc1, nil), 1e-8)
+
+	// the saved loadings give the same scores on new data
+	file := os.TempDir() + "/pca.json"
+	assert.Nil(t, pl.Save(file))
+	pl2, e := LoadPCA(file)
+	assert.Nil(t, e)
+	_ = os.Remove(file)
+
+	gd2 := NewGData()
+	assert.Nil(t, gd2.AppendC(NewRawCast(x1, nil), "x1", false, nil, false))
+	assert.Nil(t, gd2.AppendC(NewRawCast(x2, nil), "x2", false, nil, false))
+	assert.Nil(t, gd2.AppendC(NewRawCast(x3, nil), "x3", false, nil, false))
+	assert.Nil(t, pl2.Apply(gd2))
+	assert.InDeltaSlice(t, pc1, gd2.Get("pc1").Data, 1e-10)
+
+	// the scores cannot replace fields
+	assert.NotNil(t, pl2.Apply(gd2))
+	fields := gd2.FieldList()
+
+	_, e = PCA(gd2, []string{"x1", "x2", "x3"}, 2)
+	assert.NotNil(t, e)
+	assert.Equal(t, fields, gd2.FieldList())
+
+	// unless they have a different prefix, which is saved with the loadings
+	pl3, e := PCA(gd2, []string{"x1", "x2", "x3"}, 2, WithPCAPrefix("comp"))
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pc1, gd2.Get("comp1").Data, 1e-10)
+
+	assert.Nil(t, pl3.Save(file))
+	pl3, e = LoadPCA(file)
+	assert.Nil(t, e)
+	_ = os.Remove(file)
+	assert.Equal(t, "comp", pl3.Prefix)
+
+	_, e = PCA(gd, []string{"x1", "x2"}, 3)
+	assert.NotNil(t, e)
+}
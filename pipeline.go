@@ -3,7 +3,9 @@ package seafan
 // pipeline.go has the interface and "With" funcs for Pipelines.
 import (
 	"fmt"
+	"math"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/invertedv/utilities"
@@ -11,39 +13,45 @@ import (
 	"github.com/invertedv/chutils"
 	cf "github.com/invertedv/chutils/file"
 	s "github.com/invertedv/chutils/sql"
+	"gonum.org/v1/gonum/stat"
 	G "gorgonia.org/gorgonia"
 )
 
 // The Pipeline interface specifies the methods required to be a data Pipeline. The Pipeline is the middleware between
 // the data and the fitting routines.
 type Pipeline interface {
-	Init() error                                                              // initialize the pipeline
-	Rows() int                                                                // # of observations in the pipeline (size of the epoch)
-	Batch(inputs G.Nodes) bool                                                // puts the next batch in the input nodes
-	Epoch(setTo int) int                                                      // manage epoch count
-	IsNormalized(field string) bool                                           // true if feature is normalized
-	IsCat(field string) bool                                                  // true if feature is one-hot encoded
-	Cols(field string) int                                                    // # of columns in the feature
-	IsCts(field string) bool                                                  // true if the feature is continuous
-	GetFType(field string) *FType                                             // Get FType for the feature
-	GetFTypes() FTypes                                                        // Get Ftypes for pipeline
-	BatchSize() int                                                           // batch size
-	FieldList() []string                                                      // fields available
-	FieldCount() int                                                          // number of fields in the pipeline
-	GData() *GData                                                            // return underlying GData
-	Get(field string) *GDatum                                                 // return data for field
-	GetKeepRaw() bool                                                         // returns whether raw data is kept
-	Join(right Pipeline, onField string, joinType JoinType) (Pipeline, error) // joins two pipelines
-	Slice(sl Slicer) (Pipeline, error)                                        // slice the pipeline
-	Shuffle()                                                                 // shuffle data
-	Describe(field string, topK int) string                                   // describes a field
-	Subset(rows []int) (newPipe Pipeline, err error)                          // subsets pipeline to rows
-	Where(field string, equalTo []any) (Pipeline, error)                      // subset pipeline to where field=equalTo
-	Keep(fields []string) error                                               // keep on fields in the pipeline
-	Drop(field string) error                                                  // drop field from the pipeline
-	AppendRows(gd *GData, fTypes FTypes) (Pipeline, error)                    // appends gd to pipeline
-	AppendRowsRaw(gd *GData) error                                            // appends gd ONLY to *Raw data
-	ReInit(ftypes *FTypes) (Pipeline, error)                                  // reinitialized pipeline from *Raw data
+	Init() error                                                               // initialize the pipeline
+	Rows() int                                                                 // # of observations in the pipeline (size of the epoch)
+	Batch(inputs G.Nodes) bool                                                 // puts the next batch in the input nodes
+	Epoch(setTo int) int                                                       // manage epoch count
+	IsNormalized(field string) bool                                            // true if feature is normalized
+	IsCat(field string) bool                                                   // true if feature is one-hot encoded
+	Cols(field string) int                                                     // # of columns in the feature
+	IsCts(field string) bool                                                   // true if the feature is continuous
+	GetFType(field string) *FType                                              // Get FType for the feature
+	GetFTypes() FTypes                                                         // Get Ftypes for pipeline
+	BatchSize() int                                                            // batch size
+	FieldList() []string                                                       // fields available
+	FieldCount() int                                                           // number of fields in the pipeline
+	GData() *GData                                                             // return underlying GData
+	Get(field string) *GDatum                                                  // return data for field
+	GetKeepRaw() bool                                                          // returns whether raw data is kept
+	Join(right Pipeline, onField string, joinType JoinType) (Pipeline, error)  // joins two pipelines
+	Slice(sl Slicer) (Pipeline, error)                                         // slice the pipeline
+	Shuffle()                                                                  // shuffle data
+	Describe(field string, topK int) string                                    // describes a field
+	Subset(rows []int) (newPipe Pipeline, err error)                           // subsets pipeline to rows
+	Where(field string, equalTo []any) (Pipeline, error)                       // subset pipeline to where field=equalTo
+	WhereExpr(expr string) (Pipeline, error)                                   // subset pipeline to where expr is positive
+	Keep(fields []string) error                                                // keep on fields in the pipeline
+	Drop(field string) error                                                   // drop field from the pipeline
+	AppendRows(gd *GData, fTypes FTypes, opts ...AppendOpts) (Pipeline, error) // appends gd to pipeline
+	AppendRowsRaw(gd *GData) error                                             // appends gd ONLY to *Raw data
+	ReInit(ftypes *FTypes) (Pipeline, error)                                   // reinitialized pipeline from *Raw data
+	Compact() int                                                              // compacts the data, returns bytes reclaimed
+	SampleN(n int, seed int64) (Pipeline, error)                               // random sample of n rows
+	SampleFrac(f float64, seed int64) (Pipeline, error)                        // random sample of fraction f of rows
+	StratifiedSample(field string, perLevel int, seed int64) (Pipeline, error) // random sample of perLevel rows per level of field
 }
 
 // Opts function sets an option to a Pipeline
@@ -93,6 +101,55 @@ func WithKeepRaw(keepRaw bool) Opts {
 	return f
 }
 
+// WithSeed sets the seed of the random number generator used to shuffle the pipeline.  Without it, the package
+// generator is used (see SetSeed).
+func WithSeed(seed int64) Opts {
+	f := func(c Pipeline) {
+		switch d := c.(type) {
+		case *ChData:
+			d.rnd = newRand(seed)
+		case *VecData:
+			d.rnd = newRand(seed)
+		}
+	}
+
+	return f
+}
+
+// WithBatchBuffers sets whether Batch copies each batch into tensors allocated once per input node rather than
+// creating new tensors backed by the data.  This avoids allocations for each batch in long fits.  The tensors
+// are reallocated if the batch size changes.
+func WithBatchBuffers(reuse bool) Opts {
+	f := func(c Pipeline) {
+		var bufs batchBuffers
+		if reuse {
+			bufs = make(batchBuffers)
+		}
+
+		switch d := c.(type) {
+		case *ChData:
+			d.buffers = bufs
+		case *VecData:
+			d.buffers = bufs
+		}
+	}
+
+	return f
+}
+
+// WithTolerant sets a *ChData pipeline to load the fields it can. Init does not fail if a field fails to load--the
+// field is omitted and the error recorded in the LoadReport. One-hot and embedded fields built from a failed field
+// are also omitted.  Use LoadReport().Fatal to check that the required fields loaded.
+func WithTolerant(tolerant bool) Opts {
+	f := func(c Pipeline) {
+		if d, ok := c.(*ChData); ok {
+			d.tolerant = tolerant
+		}
+	}
+
+	return f
+}
+
 // WithCats specifies a list of categorical features.
 func WithCats(names ...string) Opts {
 	f := func(c Pipeline) {
@@ -133,6 +190,95 @@ func WithCats(names ...string) Opts {
 	return f
 }
 
+// WithDates specifies a list of date features (see AppendDate).
+func WithDates(names ...string) Opts {
+	f := func(c Pipeline) {
+		switch d := c.(type) {
+		case *ChData:
+			for _, nm := range names {
+				ft := d.ftypes.Get(nm)
+				if ft != nil {
+					ft.Role = FRDate
+
+					continue
+				}
+
+				ft = &FType{
+					Name: nm,
+					Role: FRDate,
+				}
+				d.ftypes = append(d.ftypes, ft)
+			}
+		case *VecData:
+			for _, nm := range names {
+				ft := d.ftypes.Get(nm)
+				if ft != nil {
+					ft.Role = FRDate
+
+					continue
+				}
+
+				ft = &FType{
+					Name: nm,
+					Role: FRDate,
+				}
+				d.ftypes = append(d.ftypes, ft)
+			}
+		}
+	}
+
+	return f
+}
+
+// WithOrdinal sets the feature name to be ordinal (see AppendOrdinal) with levels in the order given.  If order is
+// empty, the levels are ordered by their values.
+func WithOrdinal(name string, order ...any) Opts {
+	f := func(c Pipeline) {
+		var fp *FParam
+		if len(order) > 0 {
+			fp = &FParam{Lvl: make(Levels), Unseen: UnseenError}
+			for ind, lvl := range order {
+				fp.Lvl[lvl] = int32(ind)
+			}
+		}
+
+		switch d := c.(type) {
+		case *ChData:
+			ft := d.ftypes.Get(name)
+			if ft != nil {
+				ft.Role = FROrdinal
+				ft.FP = fp
+
+				return
+			}
+
+			ft = &FType{
+				Name: name,
+				Role: FROrdinal,
+				FP:   fp,
+			}
+			d.ftypes = append(d.ftypes, ft)
+		case *VecData:
+			ft := d.ftypes.Get(name)
+			if ft != nil {
+				ft.Role = FROrdinal
+				ft.FP = fp
+
+				return
+			}
+
+			ft = &FType{
+				Name: name,
+				Role: FROrdinal,
+				FP:   fp,
+			}
+			d.ftypes = append(d.ftypes, ft)
+		}
+	}
+
+	return f
+}
+
 // WithOneHot adds a one-hot field "name" based of field "from"
 func WithOneHot(name, from string) Opts {
 	f := func(c Pipeline) {
@@ -217,6 +363,50 @@ func WithNormalized(names ...string) Opts {
 	return f
 }
 
+// WithFloat32 sets the features to be continuous and stored as float32 (see (*GData).ToFloat32).
+func WithFloat32(names ...string) Opts {
+	f := func(c Pipeline) {
+		switch d := c.(type) {
+		case *ChData:
+			for _, nm := range names {
+				ft := d.ftypes.Get(nm)
+				if ft != nil {
+					ft.Role = FRCts
+					ft.Float32 = true
+
+					continue
+				}
+
+				ft = &FType{
+					Name:    nm,
+					Role:    FRCts,
+					Float32: true,
+				}
+				d.ftypes = append(d.ftypes, ft)
+			}
+		case *VecData:
+			for _, nm := range names {
+				ft := d.ftypes.Get(nm)
+				if ft != nil {
+					ft.Role = FRCts
+					ft.Float32 = true
+
+					continue
+				}
+
+				ft = &FType{
+					Name:    nm,
+					Role:    FRCts,
+					Float32: true,
+				}
+				d.ftypes = append(d.ftypes, ft)
+			}
+		}
+	}
+
+	return f
+}
+
 // WithFtypes sets the FTypes of the Pipeline. The feature is used to override the default levels.
 func WithFtypes(fts FTypes) Opts {
 	f := func(c Pipeline) {
@@ -276,6 +466,22 @@ func WithCallBack(cb Opts) Opts {
 	return f
 }
 
+// WithDerived adds fields calculated from expressions to the Pipeline. The fields are added on Init and after the
+// callback at the end of each epoch.  The FTypes of the Pipeline (see WithFtypes, WithCats, WithNormalized) apply to
+// derived fields, so their levels can be fixed across loads.
+func WithDerived(df DerivedFields) Opts {
+	f := func(c Pipeline) {
+		switch d := c.(type) {
+		case *ChData:
+			d.derived = df
+		case *VecData:
+			d.derived = df
+		}
+	}
+
+	return f
+}
+
 // WithReader adds a reader.
 func WithReader(rdr any) Opts {
 	f := func(c Pipeline) {
@@ -295,7 +501,8 @@ func WithReader(rdr any) Opts {
 
 // SQLToPipe creates a pipe from the query sql
 // Optional fts specifies the FTypes, usually to match an existing pipeline.
-func SQLToPipe(sql string, fts FTypes, keepRaw bool, conn *chutils.Connect) (pipe Pipeline, err error) {
+// Optional opts are applied to the pipe before it is initialized (e.g. WithDerived).
+func SQLToPipe(sql string, fts FTypes, keepRaw bool, conn *chutils.Connect, opts ...Opts) (pipe Pipeline, err error) {
 	rdr := s.NewReader(sql, conn)
 	defer func() { _ = rdr.Close() }()
 
@@ -313,6 +520,11 @@ func SQLToPipe(sql string, fts FTypes, keepRaw bool, conn *chutils.Connect) (pip
 	WithKeepRaw(keepRaw)(pipe)
 
 	WithBatchSize(0)(pipe)
+
+	for _, o := range opts {
+		o(pipe)
+	}
+
 	if e := pipe.Init(); e != nil {
 		return nil, e
 	}
@@ -322,7 +534,8 @@ func SQLToPipe(sql string, fts FTypes, keepRaw bool, conn *chutils.Connect) (pip
 
 // CSVToPipe creates a pipe from a CSV file
 // Optional fts specifies the FTypes, usually to match an existing pipeline.
-func CSVToPipe(csvFile string, fts FTypes, keepRaw bool) (pipe Pipeline, err error) {
+// Optional opts are applied to the pipe before it is initialized (e.g. WithDerived).
+func CSVToPipe(csvFile string, fts FTypes, keepRaw bool, opts ...Opts) (pipe Pipeline, err error) {
 	const tol = 0.98
 
 	handle, ex := os.Open(csvFile)
@@ -356,6 +569,10 @@ func CSVToPipe(csvFile string, fts FTypes, keepRaw bool) (pipe Pipeline, err err
 	WithBatchSize(0)(pipe)
 	WithKeepRaw(keepRaw)(pipe)
 
+	for _, o := range opts {
+		o(pipe)
+	}
+
 	if e := pipe.Init(); e != nil {
 		return nil, e
 	}
@@ -375,16 +592,16 @@ func PipeToSQL(pipe Pipeline, table string, after int, conn *chutils.Connect) er
 
 	gd := pipe.GData()
 	tb := gd.TableSpec()
-
-	if e := tb.Create(conn, table); e != nil {
-		return e
+	if tb == nil {
+		return fmt.Errorf("exportSQL: pipe has fields that cannot be exported")
 	}
 
-	if e := pipe.GData().Reset(); e != nil {
+	if e := tb.Create(conn, table); e != nil {
 		return e
 	}
 
-	if e := chutils.Export(pipe.GData(), wtr, after, false); e != nil {
+	// stream the rows, so the raw data of the fields isn't created
+	if e := chutils.Export(gd.NewStream(), wtr, after, false); e != nil {
 		return e
 	}
 
@@ -397,6 +614,10 @@ func PipeToCSV(pipe Pipeline, outFile string, sep, eol, quote rune) error {
 		return fmt.Errorf("exportCSV: outFile cannot be empty")
 	}
 
+	if pipe.GData().TableSpec() == nil {
+		return fmt.Errorf("exportCSV: pipe has fields that cannot be exported")
+	}
+
 	handle, err := os.Create(outFile)
 	if err != nil {
 		return err
@@ -412,18 +633,34 @@ func PipeToCSV(pipe Pipeline, outFile string, sep, eol, quote rune) error {
 	wtr := cf.NewWriter(handle, "output", nil, sep, eol, quote, "tmp.xyz")
 	defer func() { _ = wtr.Close() }()
 
-	if e := pipe.GData().Reset(); e != nil {
-		return e
-	}
-
 	// if after < 0, then won't also move to ClickHouse
-	if e := chutils.Export(pipe.GData(), wtr, -1, false); e != nil {
+	if e := chutils.Export(pipe.GData().NewStream(), wtr, -1, false); e != nil {
 		return e
 	}
 
 	return nil
 }
 
+// NewCursor returns a Pipeline that shares the data of pipe but has a batch cursor of its own.  The data are
+// frozen (see (*GData) Freeze), so several goroutines may each score pipe at once with their own cursor:
+//
+//	cur, e := NewCursor(pipe)
+//	nn, e := PredictNN(fileRoot, cur, false)
+//
+// The cursor has the batch size and FTypes of pipe.  It does not pull new data or run the callbacks of pipe.
+// pipe should not be changed while cursors are reading it.
+func NewCursor(pipe Pipeline) (Pipeline, error) {
+	gd := pipe.GData()
+	if e := gd.Freeze(); e != nil {
+		return nil, Wrapper(e, "NewCursor")
+	}
+
+	cur := NewVecData("cursor", gd, WithBatchSize(pipe.BatchSize()))
+	WithKeepRaw(pipe.GetKeepRaw())(cur)
+
+	return cur, nil
+}
+
 // Append appends pipe2 to the bottom of pipe1. pipe2 must have all the fields of pipe1 but may have extra,
 // which are not in the returned pipe
 func Append(pipe1, pipe2 Pipeline) (Pipeline, error) {
@@ -462,3 +699,282 @@ func Append(pipe1, pipe2 Pipeline) (Pipeline, error) {
 
 	return VecFromAny(forVec, flds1, nil)
 }
+
+// AppendLoose appends pipe2 to the bottom of pipe1. Unlike Append, the pipelines need not have the same fields.
+// The returned pipe has the union of the fields. Rows from a pipeline without a field are filled with the
+// FParam.Default value of the field, if set. Otherwise, they are filled with 0, "" or 1/1/1970 (as in Join).
+func AppendLoose(pipe1, pipe2 Pipeline) (Pipeline, error) {
+	if pipe1 == nil {
+		return pipe2, nil
+	}
+
+	flds := pipe1.FieldList()
+	for _, fld := range pipe2.FieldList() {
+		if utilities.Position(fld, "", flds...) < 0 {
+			flds = append(flds, fld)
+		}
+	}
+
+	forVec := make([][]any, len(flds))
+
+	for ind, fld := range flds {
+		var (
+			d1, d2 *Raw
+			e      error
+		)
+
+		ft1, ft2 := pipe1.GetFType(fld), pipe2.GetFType(fld)
+
+		if ft1 != nil {
+			if d1, e = pipe1.GData().GetRaw(fld); e != nil {
+				return nil, e
+			}
+		}
+
+		if ft2 != nil {
+			if d2, e = pipe2.GData().GetRaw(fld); e != nil {
+				return nil, e
+			}
+		}
+
+		switch {
+		case d1 == nil:
+			d1 = fillRaw(ft2, d2.Kind, pipe1.Rows())
+		case d2 == nil:
+			d2 = fillRaw(ft1, d1.Kind, pipe2.Rows())
+		}
+
+		data := make([]any, 0, d1.Len()+d2.Len())
+		data = append(data, d1.Data...)
+		forVec[ind] = append(data, d2.Data...)
+	}
+
+	return VecFromAny(forVec, flds, nil)
+}
+
+// fillRaw returns a *Raw of length n filled with the missing value of ft.  ft is not changed.
+func fillRaw(ft *FType, kind reflect.Kind, n int) *Raw {
+	// getMiss sets the Default of the FParam, so work on a copy
+	ft = copyFType(ft, ft.Name)
+	if ft.FP == nil {
+		ft.FP = &FParam{}
+	}
+
+	miss := getMiss(ft, kind)
+	data := make([]any, n)
+
+	for ind := 0; ind < n; ind++ {
+		data[ind] = miss
+	}
+
+	return NewRaw(data, nil)
+}
+
+// BalancePipe returns a pipeline in which each level of targetField has the same number of rows. The strategy is
+// one of:
+//   - "up": rows of the smaller classes are sampled with replacement until each class has as many rows as the largest.
+//   - "down": rows of the larger classes are sampled without replacement until each class has as many rows as the smallest.
+//
+// The rows of the output are grouped by class and should be shuffled before fitting (see WithShuffle).
+func BalancePipe(pipe Pipeline, targetField, strategy string) (Pipeline, error) {
+	raw, e := pipe.GData().GetRaw(targetField)
+	if e != nil {
+		return nil, Wrapper(e, "BalancePipe")
+	}
+
+	classRows := make(map[any][]int)
+	for row, val := range raw.Data {
+		classRows[val] = append(classRows[val], row)
+	}
+
+	minN, maxN := raw.Len(), 0
+	for _, rows := range classRows {
+		minN, maxN = utilities.MinInt(minN, len(rows)), utilities.MaxInt(maxN, len(rows))
+	}
+
+	var keep []int
+
+	for _, class := range Unique(raw.Data) {
+		rows := classRows[class]
+
+		switch strategy {
+		case "up":
+			keep = append(keep, rows...)
+			for ind := len(rows); ind < maxN; ind++ {
+				keep = append(keep, rows[rng.Intn(len(rows))])
+			}
+		case "down":
+			keep = append(keep, reservoir(minN, len(rows), rows, nil)...)
+		default:
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("BalancePipe: unknown strategy %s", strategy))
+		}
+	}
+
+	gdOut, e := pipe.GData().Subset(keep)
+	if e != nil {
+		return nil, Wrapper(e, "BalancePipe")
+	}
+
+	newPipe := NewVecData("balanced", gdOut)
+	WithKeepRaw(pipe.GetKeepRaw())(newPipe)
+
+	return newPipe, nil
+}
+
+// UpdateFParams refreshes the FParam values of pipe using newData. The update is exponentially weighted:
+// decay is the weight given to the current values and 1-decay the weight given to newData.
+//   - FRCts: the location and scale are updated and normalized fields are re-normalized in place.
+//   - FRCat: the level frequencies in Summary.DistrD are updated and kept as counts on the rows of pipe. The mapping
+//     FP.Lvl is not changed.
+//
+// Fields of pipe that are not in newData are left as is.
+func UpdateFParams(pipe Pipeline, newData *GData, decay float64) error {
+	if decay < 0.0 || decay > 1.0 {
+		return Wrapper(ErrPipe, fmt.Sprintf("UpdateFParams: decay must be in [0,1], got %v", decay))
+	}
+
+	if newData == nil || newData.Rows() == 0 {
+		return Wrapper(ErrPipe, "UpdateFParams: no new data")
+	}
+
+	gd := pipe.GData()
+
+	for _, datum := range gd.GetData() {
+		ft := datum.FT
+		if newData.Get(ft.Name) == nil {
+			continue
+		}
+
+		rawNew, e := newData.GetRaw(ft.Name)
+		if e != nil {
+			return e
+		}
+
+		switch ft.Role {
+		case FRCts:
+			xNew, e := utilities.AnySlice2Float64(rawNew.Data)
+			if e != nil {
+				return Wrapper(e, fmt.Sprintf("UpdateFParams: field %s", ft.Name))
+			}
+
+			// need the un-normalized values before FP changes
+			rawOld, e := gd.GetRaw(ft.Name)
+			if e != nil {
+				return e
+			}
+
+			mNew, sNew := stat.MeanStdDev(xNew, nil)
+			mOld, sOld := ft.FP.Location, ft.FP.Scale
+
+			// weighted mixture of the two distributions
+			loc := decay*mOld + (1.0-decay)*mNew
+			dOld, dNew := mOld-loc, mNew-loc
+			scale := math.Sqrt(decay*(sOld*sOld+dOld*dOld) + (1.0-decay)*(sNew*sNew+dNew*dNew))
+
+			fp := &FParam{Location: loc, Scale: scale, Default: ft.FP.Default, Lvl: ft.FP.Lvl}
+
+			if ft.Normalized {
+				if scale < 1e-8 {
+					return Wrapper(ErrPipe, fmt.Sprintf("UpdateFParams: %s cannot be normalized--0 variance", ft.Name))
+				}
+
+				x := datum.Floats()
+				for ind := 0; ind < len(x); ind++ {
+					xOld, e := utilities.Any2Float64(rawOld.Data[ind])
+					if e != nil {
+						return e
+					}
+
+					x[ind] = (*xOld - loc) / scale
+				}
+
+				datum.Summary.DistrC.Populate(x, true, nil)
+				datum.setFloats(x)
+			}
+
+			ft.FP = fp
+		case FRCat:
+			// the counts are mixed as frequencies, so newData may have any number of rows
+			lvlNew := ByCounts(rawNew, nil)
+			freqs := make(map[any]float64)
+			nOld, nNew := float64(datum.Summary.NRows), float64(rawNew.Len())
+
+			for k, v := range datum.Summary.DistrD {
+				freqs[k] = decay * float64(v) / nOld
+			}
+
+			for k, v := range lvlNew {
+				freqs[k] += (1.0 - decay) * float64(v) / nNew
+			}
+
+			// back to counts on the rows of pipe
+			lvl := make(Levels)
+			for k, v := range freqs {
+				lvl[k] = int32(math.Round(v * nOld))
+			}
+
+			datum.Summary.DistrD = lvl
+		}
+	}
+
+	return nil
+}
+
+// Compatible checks that the fields of pipe1 are in pipe2 with the same FType: role, categories, normalization and
+// levels. Typically, pipe1 is the pipeline the model was trained on and pipe2 is the pipeline to score.
+// The report lists the mismatches. ok is true if there are none. Fields in pipe2 that are not in pipe1 are ignored.
+func Compatible(pipe1, pipe2 Pipeline) (report string, ok bool) {
+	const tol = 1e-8
+
+	for _, ft1 := range pipe1.GetFTypes() {
+		ft2 := pipe2.GetFType(ft1.Name)
+		if ft2 == nil {
+			report = fmt.Sprintf("%sfield %s: missing from pipe2\n", report, ft1.Name)
+			continue
+		}
+
+		if ft1.Role != ft2.Role {
+			report = fmt.Sprintf("%sfield %s: role %v vs %v\n", report, ft1.Name, ft1.Role, ft2.Role)
+			continue
+		}
+
+		if ft1.From != ft2.From {
+			report = fmt.Sprintf("%sfield %s: derived from %s vs %s\n", report, ft1.Name, ft1.From, ft2.From)
+		}
+
+		if ft1.Cats != ft2.Cats {
+			report = fmt.Sprintf("%sfield %s: # of categories %d vs %d\n", report, ft1.Name, ft1.Cats, ft2.Cats)
+		}
+
+		if ft1.Normalized != ft2.Normalized {
+			report = fmt.Sprintf("%sfield %s: normalized %v vs %v\n", report, ft1.Name, ft1.Normalized, ft2.Normalized)
+			continue
+		}
+
+		if ft1.FP == nil || ft2.FP == nil {
+			continue
+		}
+
+		if ft1.Normalized &&
+			(math.Abs(ft1.FP.Location-ft2.FP.Location) > tol || math.Abs(ft1.FP.Scale-ft2.FP.Scale) > tol) {
+			report = fmt.Sprintf("%sfield %s: location/scale %v/%v vs %v/%v\n", report, ft1.Name,
+				ft1.FP.Location, ft1.FP.Scale, ft2.FP.Location, ft2.FP.Scale)
+		}
+
+		if ft1.Role == FRCts {
+			continue
+		}
+
+		for k, v1 := range ft1.FP.Lvl {
+			v2, okLvl := ft2.FP.Lvl[k]
+			switch {
+			case !okLvl:
+				report = fmt.Sprintf("%sfield %s: level %v missing from pipe2\n", report, ft1.Name, k)
+			case v1 != v2:
+				report = fmt.Sprintf("%sfield %s: level %v maps to %d vs %d\n", report, ft1.Name, k, v1, v2)
+			}
+		}
+	}
+
+	return report, report == ""
+}
@@ -3,6 +3,10 @@ package seafan
 import (
 	"fmt"
 	"os"
+	"testing"
+
+	"github.com/invertedv/chutils"
+	"github.com/stretchr/testify/assert"
 )
 
 // Create a Pipeline from a CSV and force a specific FType.
@@ -253,6 +257,90 @@ func ExampleAppend() {
 	// Field3:  [3 2.2 1.9 10.1 12.99 100 1001.4 -1 -2]
 }
 
+// AppendLoose stacks pipelines that have different fields. pipeTest4.csv has a field, Field4, that
+// pipeTest1.csv does not.
+func ExampleAppendLoose() {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe1, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	pipe2, e := CSVToPipe(data+"/pipeTest4.csv", nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	pipeOut, e := AppendLoose(pipe1, pipe2)
+	if e != nil {
+		panic(e)
+	}
+
+	fmt.Println("appended pipe rows: ", pipeOut.Rows())
+	fmt.Println("fields: ", pipeOut.FieldList())
+	fmt.Printf("Field4: %q\n", pipeOut.Get("Field4").Raw.Data)
+	// output:
+	// appended pipe rows:  9
+	// fields:  [Field1 row Field3 Field4]
+	// Field4: ["" "" "" "" "" "" "" "x" "y"]
+}
+
+func TestAppendLoose(t *testing.T) {
+	gd1 := NewGData()
+	assert.Nil(t, gd1.AppendC(NewRawCast([]float64{1, 2}, nil), "x", false, nil, false))
+
+	gd2 := NewGData()
+	assert.Nil(t, gd2.AppendC(NewRawCast([]float64{3}, nil), "x", false, nil, false))
+	assert.Nil(t, gd2.AppendD(NewRawCast([]string{"a"}, nil), "s", nil, false))
+
+	pipe1, pipe2 := NewVecData("p1", gd1), NewVecData("p2", gd2)
+	ft := pipe2.GetFType("s")
+	fp, def := ft.FP, ft.FP.Default
+
+	pipeOut, e := AppendLoose(pipe1, pipe2)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, pipeOut.Rows())
+	raw, e := pipeOut.GData().GetRaw("s")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"", "", "a"}, raw.Data)
+
+	// the FTypes of the inputs are not changed
+	assert.True(t, fp == pipe2.GetFType("s").FP)
+	assert.Equal(t, def, pipe2.GetFType("s").FP.Default)
+}
+
+func TestPipeToCSV_Date(t *testing.T) {
+	Verbose = false
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendDate(NewRaw([]any{"20200101", "19691231", "20230615"}, nil), "dt", false))
+
+	td := gd.TableSpec()
+	assert.NotNil(t, td)
+	assert.Equal(t, chutils.ChDate, td.FieldDefs[1].ChSpec.Base)
+
+	fileName := os.TempDir() + "/seafanDate.csv"
+	defer func() { _ = os.Remove(fileName) }()
+
+	assert.Nil(t, PipeToCSV(NewVecData("dates", gd), fileName, ',', '\n', '"'))
+
+	pipe, e := CSVToPipe(fileName, FTypes{{Name: "dt", Role: FRDate}}, false)
+	assert.Nil(t, e)
+	assert.Equal(t, gd.Get("x").Data, pipe.Get("x").Data)
+	assert.Equal(t, gd.Get("dt").Data, pipe.Get("dt").Data)
+
+	// a GData reader feeds a ChData
+	rdr, e := gd.NewReader()
+	assert.Nil(t, e)
+
+	ch := NewChData("dates", WithReader(rdr), WithFtypes(FTypes{{Name: "dt", Role: FRDate}}))
+	assert.Nil(t, ch.Init())
+	assert.Equal(t, gd.Get("dt").Data, ch.Get("dt").Data)
+}
+
 func ExampleSubset() {
 	Verbose = false
 
@@ -303,3 +391,104 @@ func ExampleWhere() {
 	// output:
 	// Field1:  [c x]
 }
+
+// Compatible checks that a pipeline to score matches the pipeline the model was trained on.
+func ExampleCompatible() {
+	Verbose = false
+
+	data := os.Getenv("data") + "/pipeTest1.csv"
+	pipeTrain, e := CSVToPipe(data, nil, false)
+	if e != nil {
+		panic(e)
+	}
+
+	// build the scoring pipeline using the FTypes of the training pipeline
+	pipeScore, e := CSVToPipe(data, pipeTrain.GetFTypes(), false)
+	if e != nil {
+		panic(e)
+	}
+
+	_, ok := Compatible(pipeTrain, pipeScore)
+	fmt.Println("compatible: ", ok)
+
+	// now normalize Field3 in the scoring pipeline
+	ft := &FType{Name: "Field3", Role: FRCts, Normalized: true}
+	if pipeScore, e = CSVToPipe(data, FTypes{ft}, false); e != nil {
+		panic(e)
+	}
+
+	report, ok := Compatible(pipeTrain, pipeScore)
+	fmt.Println("compatible: ", ok)
+	fmt.Print(report)
+	// output:
+	// compatible:  true
+	// compatible:  false
+	// field Field3: normalized false vs true
+}
+
+func ExampleBalancePipe() {
+	Verbose = false
+
+	// x2 has 5 a's, 1 b and 1 c
+	x1 := []any{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0}
+	x2 := []any{"a", "b", "c", "a", "a", "a", "a"}
+
+	pipe, e := VecFromAny([][]any{x1, x2}, []string{"x1", "x2"}, nil)
+	if e != nil {
+		panic(e)
+	}
+
+	for _, strategy := range []string{"up", "down"} {
+		balanced, e := BalancePipe(pipe, "x2", strategy)
+		if e != nil {
+			panic(e)
+		}
+
+		raw, e := balanced.GData().GetRaw("x2")
+		if e != nil {
+			panic(e)
+		}
+
+		counts := ByCounts(raw, nil)
+		fmt.Printf("%s: rows %d, a %d, b %d, c %d\n", strategy, balanced.Rows(), counts["a"], counts["b"], counts["c"])
+	}
+	// output:
+	// up: rows 15, a 5, b 5, c 5
+	// down: rows 3, a 1, b 1, c 1
+}
+
+func TestUpdateFParams(t *testing.T) {
+	x1 := []float64{1, 2, 3, 4, 5}
+	gData := NewGData()
+	e := gData.AppendC(NewRawCast(x1, nil), "x1", true, nil, true)
+	assert.Nil(t, e)
+
+	e = gData.AppendD(NewRawCast([]string{"a", "a", "b", "b", "b"}, nil), "x2", nil, true)
+	assert.Nil(t, e)
+
+	pipe := NewVecData("test", gData)
+
+	newData := NewGData()
+	e = newData.AppendC(NewRawCast([]float64{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, nil), "x1", false, nil, true)
+	assert.Nil(t, e)
+
+	e = newData.AppendD(NewRawCast([]string{"a", "a", "a", "a", "a", "a", "a", "a", "b", "b"}, nil), "x2", nil, true)
+	assert.Nil(t, e)
+
+	e = UpdateFParams(pipe, newData, 0.5)
+	assert.Nil(t, e)
+
+	ft := pipe.GetFType("x1")
+	assert.InDelta(t, 9.25, ft.FP.Location, 1e-8)
+
+	// data is re-normalized with the new location/scale
+	x1Norm := pipe.Get("x1").Data.([]float64)
+	assert.InDelta(t, (1.0-9.25)/ft.FP.Scale, x1Norm[0], 1e-8)
+
+	// frequencies .5*.4+.5*.8 and .5*.6+.5*.2 on 5 rows
+	assert.Equal(t, int32(3), pipe.Get("x2").Summary.DistrD["a"])
+	assert.Equal(t, int32(2), pipe.Get("x2").Summary.DistrD["b"])
+
+	e = UpdateFParams(pipe, newData, 1.5)
+	assert.NotNil(t, e)
+}
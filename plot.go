@@ -0,0 +1,166 @@
+package seafan
+
+// plot.go implements the backends that render plots: Plotly (browser/orca), JSON specs and static SVG/PNG images
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/invertedv/utilities"
+)
+
+// PlotBackend renders a plot.  fig holds the traces, lay the layout and pd the options (title, size, file name...).
+type PlotBackend interface {
+	Render(fig *grob.Fig, lay *grob.Layout, pd *utilities.PlotDef) error
+}
+
+// Backend is the PlotBackend used by the plotting functions (KS, Decile, SegPlot, Marginal, the parser's render...).
+// The default, PlotlyBackend, shows the plot in the browser and/or creates files with orca as specified by the
+// PlotDef.  For headless use, set Backend to a *StaticBackend or a *JSONBackend.
+var Backend PlotBackend = PlotlyBackend{}
+
+// plotter renders fig with Backend
+func plotter(fig *grob.Fig, lay *grob.Layout, pd *utilities.PlotDef) error {
+	if Backend == nil {
+		return utilities.Plotter(fig, lay, pd)
+	}
+
+	return Backend.Render(fig, lay, pd)
+}
+
+// PlotlyBackend renders plots with utilities.Plotter.
+type PlotlyBackend struct{}
+
+func (PlotlyBackend) Render(fig *grob.Fig, lay *grob.Layout, pd *utilities.PlotDef) error {
+	return utilities.Plotter(fig, lay, pd)
+}
+
+// applyLayout sets fig.Layout from lay and pd without producing any output
+func applyLayout(fig *grob.Fig, lay *grob.Layout, pd *utilities.PlotDef) error {
+	var pdLay utilities.PlotDef
+	if pd != nil {
+		pdLay = *pd
+	}
+
+	pdLay.Show, pdLay.FileName, pdLay.ImageTypes = false, "", nil
+
+	return utilities.Plotter(fig, lay, &pdLay)
+}
+
+// plotFile returns the file for the plot with extension ext.  It is empty if pd has no FileName.
+func plotFile(pd *utilities.PlotDef, ext string) string {
+	if pd == nil || pd.FileName == "" {
+		return ""
+	}
+
+	if pd.OutDir == "" {
+		return fmt.Sprintf("%s.%s", pd.FileName, ext)
+	}
+
+	return fmt.Sprintf("%s%s.%s", utilities.Slash(pd.OutDir), pd.FileName, ext)
+}
+
+// JSONBackend returns the Plotly JSON spec of plots rather than rendering them.  The spec can be embedded in a report
+// and rendered by plotly.js.
+type JSONBackend struct {
+	Specs []json.RawMessage // specs of the plots rendered, in order
+}
+
+// Render appends the spec of the plot to Specs.  If pd has a FileName, the spec is also saved in
+// <OutDir>/<FileName>.json.
+func (jb *JSONBackend) Render(fig *grob.Fig, lay *grob.Layout, pd *utilities.PlotDef) error {
+	if e := applyLayout(fig, lay, pd); e != nil {
+		return e
+	}
+
+	js, e := json.Marshal(fig)
+	if e != nil {
+		return e
+	}
+
+	jb.Specs = append(jb.Specs, js)
+
+	if file := plotFile(pd, "json"); file != "" {
+		return os.WriteFile(file, js, 0644)
+	}
+
+	return nil
+}
+
+// StaticFormat is the image format of a StaticBackend
+type StaticFormat int
+
+const (
+	StaticSVG StaticFormat = 0 + iota
+	StaticPNG
+)
+
+func (sf StaticFormat) String() string {
+	switch sf {
+	case StaticSVG:
+		return "svg"
+	case StaticPNG:
+		return "png"
+	}
+
+	return ""
+}
+
+// StaticBackend renders plots as SVG or PNG images without a browser or orca.  Scatter, Bar, Histogram, Box and
+// Heatmap traces are supported; subplots are laid out on the grid of the layout (or a square grid if there is none).
+// Legends and hover labels are not drawn. PNG images have no text (titles, tick labels).
+type StaticBackend struct {
+	Format StaticFormat // image format
+	Images [][]byte     // images rendered, in order
+}
+
+// Render appends the image of the plot to Images.  If pd has a FileName, the image is also saved in
+// <OutDir>/<FileName>.<svg or png>.
+func (sb *StaticBackend) Render(fig *grob.Fig, lay *grob.Layout, pd *utilities.PlotDef) error {
+	const (
+		width  = 800.0
+		height = 600.0
+	)
+
+	if e := applyLayout(fig, lay, pd); e != nil {
+		return e
+	}
+
+	w, h := width, height
+	if pd != nil && pd.Width > 0 {
+		w = pd.Width
+	}
+
+	if pd != nil && pd.Height > 0 {
+		h = pd.Height
+	}
+
+	var cv canvas
+	switch sb.Format {
+	case StaticSVG:
+		cv = newSVGCanvas(w, h)
+	case StaticPNG:
+		cv = newPNGCanvas(w, h)
+	default:
+		return Wrapper(ErrDiags, fmt.Sprintf("StaticBackend: unknown format %d", sb.Format))
+	}
+
+	if e := drawFig(cv, fig, w, h); e != nil {
+		return e
+	}
+
+	img, e := cv.bytes()
+	if e != nil {
+		return e
+	}
+
+	sb.Images = append(sb.Images, img)
+
+	if file := plotFile(pd, sb.Format.String()); file != "" {
+		return os.WriteFile(file, img, 0644)
+	}
+
+	return nil
+}
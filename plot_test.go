@@ -0,0 +1,242 @@
+package seafan
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/invertedv/utilities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticBackend(t *testing.T) {
+	save := Backend
+	defer func() { Backend = save }()
+
+	n := 200
+	fit, obs := make([]float64, n), make([]float64, n)
+	rnd := newRand(5)
+
+	for ind := 0; ind < n; ind++ {
+		fit[ind] = rnd.Float64()
+		if rnd.Float64() < fit[ind] {
+			obs[ind] = 1
+		}
+	}
+
+	xy, e := NewXY(fit, obs)
+	assert.Nil(t, e)
+
+	sb := &StaticBackend{Format: StaticSVG}
+	Backend = sb
+
+	pd := &utilities.PlotDef{Title: "Test & Check", OutDir: os.TempDir(), FileName: "ksStatic"}
+	defer func() { _ = os.Remove(os.TempDir() + "/ksStatic.svg") }()
+
+	_, _, _, e = KS(xy, pd)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(sb.Images))
+
+	svg := string(sb.Images[0])
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, "Test &amp; Check")
+	assert.Contains(t, svg, "Fitted Values")
+	assert.Contains(t, svg, "<line")
+
+	file, e := os.ReadFile(os.TempDir() + "/ksStatic.svg")
+	assert.Nil(t, e)
+	assert.Equal(t, sb.Images[0], file)
+
+	// PNG, decile plot
+	sb = &StaticBackend{Format: StaticPNG}
+	Backend = sb
+
+	assert.Nil(t, Decile(xy, &utilities.PlotDef{Width: 400, Height: 300}))
+	assert.Equal(t, 1, len(sb.Images))
+
+	img, e := png.Decode(bytes.NewReader(sb.Images[0]))
+	assert.Nil(t, e)
+	assert.Equal(t, 400, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	// bar, histogram, box and heatmap traces
+	sb = &StaticBackend{Format: StaticSVG}
+	fig := &grob.Fig{Data: grob.Traces{
+		&grob.Bar{Type: grob.TraceTypeBar, X: []string{"a", "b"}, Y: []float64{1, 2}},
+		&grob.Histogram{Type: grob.TraceTypeHistogram, X: fit, Xaxis: "x2", Yaxis: "y2"},
+		&grob.Box{Type: grob.TraceTypeBox, X: []string{"a", "a", "b", "b"}, Y: []float64{1, 2, 3, 4}, Xaxis: "x3"},
+		&grob.Heatmap{Type: grob.TraceTypeHeatmap, X: []string{"u", "v"}, Y: []string{"u", "v"},
+			Z: [][]float64{{1, -1}, {-1, 1}}, Xaxis: "x4"},
+	}}
+
+	assert.Nil(t, sb.Render(fig, nil, &utilities.PlotDef{}))
+	assert.Contains(t, string(sb.Images[0]), "<rect")
+
+	fig = &grob.Fig{Data: grob.Traces{&grob.Pie{Type: grob.TraceTypePie}}}
+	assert.NotNil(t, sb.Render(fig, nil, &utilities.PlotDef{}))
+}
+
+func TestJSONBackend(t *testing.T) {
+	save := Backend
+	defer func() { Backend = save }()
+
+	jb := &JSONBackend{}
+	Backend = jb
+
+	n := 100
+	fit, obs := make([]float64, n), make([]float64, n)
+
+	for ind := 0; ind < n; ind++ {
+		fit[ind] = float64(ind) / float64(n)
+		obs[ind] = float64(ind % 2)
+	}
+
+	xy, e := NewXY(fit, obs)
+	assert.Nil(t, e)
+
+	assert.Nil(t, Decile(xy, &utilities.PlotDef{Title: "Deciles"}))
+	assert.Equal(t, 1, len(jb.Specs))
+
+	spec := make(map[string]any)
+	assert.Nil(t, json.Unmarshal(jb.Specs[0], &spec))
+	assert.Contains(t, spec, "data")
+	assert.Equal(t, "Deciles", spec["layout"].(map[string]any)["title"].(map[string]any)["text"])
+}
+
+func TestPlotFacet(t *testing.T) {
+	Verbose = false
+
+	save := Backend
+	defer func() { Backend = save }()
+
+	jb := &JSONBackend{}
+	Backend = jb
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4, 5, 6}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{2, 4, 1, 3, 5, 7}, nil), "y", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"b", "a", "b", "a", "c", "c"}, nil), "grp", nil, false))
+
+	run := func(cmd string) error {
+		node := &OpNode{Expression: cmd}
+		if e := Expr2Tree(node); e != nil {
+			return e
+		}
+
+		return EvaluateGD(node, gd)
+	}
+
+	// legend labels, hex colors and a secondary axis
+	for _, cmd := range []string{"newPlot()", "plotXY(x, y, 'line', '#1f77b4', 'Y')",
+		"plotXY(x, x * 10, 'markers', 'red', 'X10', 'y2')", "render('', 'Two Axes', 'x', 'y', 'x * 10')"} {
+		assert.Nil(t, run(cmd))
+	}
+
+	spec := string(jb.Specs[0])
+	assert.Contains(t, spec, `"name":"X10"`)
+	assert.Contains(t, spec, `"yaxis":"y2"`)
+	assert.Contains(t, spec, `"color":"#1f77b4"`)
+	assert.Contains(t, spec, `"overlaying":"y"`)
+	assert.NotContains(t, spec, `"showlegend":false`)
+
+	// one panel for each level of grp
+	for _, cmd := range []string{"newPlot()", "facet(grp)", "plotXY(x, y, 'line', 'blue', 'Y')",
+		"plotLine(y, 'markers', 'black')", "render('', 'Facets', 'x', 'y')"} {
+		assert.Nil(t, run(cmd))
+	}
+
+	fig := &grob.Fig{}
+	assert.Nil(t, json.Unmarshal(jb.Specs[1], fig))
+	assert.Equal(t, 6, len(fig.Data))
+	assert.Equal(t, int64(2), fig.Layout.Grid.Rows)
+	assert.Equal(t, int64(2), fig.Layout.Grid.Columns)
+
+	// panel "b" is the second level
+	tr := fig.Data[1].(*grob.Scatter)
+	assert.Equal(t, "x2", tr.Xaxis)
+	assert.Equal(t, []any{1.0, 3.0}, tr.X)
+	assert.Equal(t, []any{2.0, 1.0}, tr.Y)
+
+	// plotLine counts the rows within each panel
+	assert.Equal(t, []any{1.0, 2.0}, fig.Data[4].(*grob.Scatter).X)
+
+	assert.Contains(t, string(jb.Specs[1]), `"text":"c"`)
+
+	assert.NotNil(t, run("plotXY(x, y, 'line', 'blue', 'Y', 'y2')"))
+	assert.NotNil(t, run("plotXY(x, y, 'line', '#12345z')"))
+	assert.NotNil(t, run("plotXY(x, y, 'line', 'blue', 'Y', 'y3')"))
+	assert.NotNil(t, run("plotXY(x, y, 'line', 'blue', 'Y', 'y2', 'extra')"))
+
+	// newPlot removes the facet
+	assert.Nil(t, run("newPlot()"))
+	assert.Nil(t, run("plotXY(x, y, 'line', 'blue', 'Y', 'y2')"))
+}
+
+func TestPlotCategorical(t *testing.T) {
+	Verbose = false
+
+	save := Backend
+	defer func() { Backend = save }()
+
+	jb := &JSONBackend{}
+	Backend = jb
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4, 5, 6}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"b", "a", "b", "a", "c", "c"}, nil), "grp", nil, false))
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"u", "u", "v", "v", "u", "u"}, nil), "kind", nil, false))
+
+	run := func(cmd string) error {
+		node := &OpNode{Expression: cmd}
+		if e := Expr2Tree(node); e != nil {
+			return e
+		}
+
+		return EvaluateGD(node, gd)
+	}
+
+	for _, cmd := range []string{"newPlot()", "boxplot(x, grp)", "render('', 'Box', 'grp', 'x')",
+		"newPlot()", "barplot(grp, x)", "barplot(grp, 1, 'count')", "render('', 'Bar', 'grp', 'x')",
+		"newPlot()", "heatmap(grp, kind, x, 'mean')", "render('', 'Heat', 'grp', 'kind')"} {
+		assert.Nil(t, run(cmd))
+	}
+
+	figs := make([]*grob.Fig, len(jb.Specs))
+	for ind, spec := range jb.Specs {
+		figs[ind] = &grob.Fig{}
+		assert.Nil(t, json.Unmarshal(spec, figs[ind]))
+	}
+
+	box := figs[0].Data[0].(*grob.Box)
+	assert.Equal(t, []any{"b", "a", "b", "a", "c", "c"}, box.X)
+
+	bar := figs[1].Data[0].(*grob.Bar)
+	assert.Equal(t, []any{"a", "b", "c"}, bar.X)
+	assert.Equal(t, []any{6.0, 4.0, 11.0}, bar.Y)
+	assert.Equal(t, []any{2.0, 2.0, 2.0}, figs[1].Data[1].(*grob.Bar).Y)
+
+	// rows are kind (u, v), columns grp (a, b, c).  (a, u), (b, u) and (c, v) are the cells with one row
+	heat := figs[2].Data[0].(*grob.Heatmap)
+	assert.Equal(t, []any{"a", "b", "c"}, heat.X)
+	assert.Equal(t, []any{[]any{2.0, 1.0, 5.5}, []any{4.0, 3.0, nil}}, heat.Z)
+
+	// facets and the static renderer
+	sb := &StaticBackend{}
+	Backend = sb
+
+	for _, cmd := range []string{"newPlot()", "facet(kind)", "boxplot(x, grp)", "render('', 'Box', 'grp', 'x')",
+		"newPlot()", "heatmap(grp, kind, x)", "render('', 'Heat', 'grp', 'kind')"} {
+		assert.Nil(t, run(cmd))
+	}
+
+	assert.Equal(t, 2, len(sb.Images))
+	assert.Contains(t, string(sb.Images[1]), "<rect")
+
+	assert.NotNil(t, run("barplot(grp, x, 'median')"))
+	assert.NotNil(t, run("boxplot(grp, x)"))
+	assert.NotNil(t, run("heatmap(grp, kind, x, 'max')"))
+}
@@ -0,0 +1,793 @@
+package seafan
+
+// plotstatic.go draws Plotly figures as SVG or PNG images for StaticBackend
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/invertedv/utilities"
+)
+
+// canvas is a surface to draw on.  Coordinates are in pixels from the top left.
+type canvas interface {
+	line(x0, y0, x1, y1, width float64, c color.RGBA)
+	rect(x, y, w, h float64, fill color.RGBA)
+	circle(x, y, r float64, fill color.RGBA)
+	text(x, y, size float64, anchor string, vertical bool, str string)
+	bytes() ([]byte, error)
+}
+
+// svgCanvas draws SVG
+type svgCanvas struct {
+	w, h float64
+	buf  strings.Builder
+}
+
+func newSVGCanvas(w, h float64) *svgCanvas {
+	sc := &svgCanvas{w: w, h: h}
+	sc.rect(0, 0, w, h, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	return sc
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (sc *svgCanvas) line(x0, y0, x1, y1, width float64, c color.RGBA) {
+	fmt.Fprintf(&sc.buf, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"%s\" stroke-width=\"%.1f\"/>\n",
+		x0, y0, x1, y1, svgColor(c), width)
+}
+
+func (sc *svgCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&sc.buf, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%s\"/>\n",
+		x, y, w, h, svgColor(fill))
+}
+
+func (sc *svgCanvas) circle(x, y, r float64, fill color.RGBA) {
+	fmt.Fprintf(&sc.buf, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\" fill=\"%s\"/>\n", x, y, r, svgColor(fill))
+}
+
+func (sc *svgCanvas) text(x, y, size float64, anchor string, vertical bool, str string) {
+	rot := ""
+	if vertical {
+		rot = fmt.Sprintf(" transform=\"rotate(-90 %.1f %.1f)\"", x, y)
+	}
+
+	fmt.Fprintf(&sc.buf, "<text x=\"%.1f\" y=\"%.1f\" font-family=\"sans-serif\" font-size=\"%.0f\" text-anchor=\"%s\"%s>%s</text>\n",
+		x, y, size, anchor, rot, html.EscapeString(str))
+}
+
+func (sc *svgCanvas) bytes() ([]byte, error) {
+	return []byte(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\">\n%s</svg>\n",
+		sc.w, sc.h, sc.buf.String())), nil
+}
+
+// pngCanvas draws a PNG.  Text is not drawn.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(w, h float64) *pngCanvas {
+	pc := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, int(w), int(h)))}
+	pc.rect(0, 0, w, h, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	return pc
+}
+
+func (pc *pngCanvas) line(x0, y0, x1, y1, width float64, c color.RGBA) {
+	steps := math.Max(math.Abs(x1-x0), math.Abs(y1-y0))
+	if steps < 1 {
+		steps = 1
+	}
+
+	half := math.Max(width/2, 0.5)
+
+	for s := 0.0; s <= steps; s++ {
+		x, y := x0+(x1-x0)*s/steps, y0+(y1-y0)*s/steps
+		pc.rect(x-half, y-half, 2*half, 2*half, c)
+	}
+}
+
+func (pc *pngCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	for px := int(math.Round(x)); px < int(math.Round(x+w)); px++ {
+		for py := int(math.Round(y)); py < int(math.Round(y+h)); py++ {
+			pc.img.SetRGBA(px, py, fill)
+		}
+	}
+}
+
+func (pc *pngCanvas) circle(x, y, r float64, fill color.RGBA) {
+	for px := int(x - r); px <= int(x+r); px++ {
+		for py := int(y - r); py <= int(y+r); py++ {
+			if (float64(px)-x)*(float64(px)-x)+(float64(py)-y)*(float64(py)-y) <= r*r {
+				pc.img.SetRGBA(px, py, fill)
+			}
+		}
+	}
+}
+
+func (pc *pngCanvas) text(_, _, _ float64, _ string, _ bool, _ string) {}
+
+func (pc *pngCanvas) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if e := png.Encode(&buf, pc.img); e != nil {
+		return nil, e
+	}
+
+	return buf.Bytes(), nil
+}
+
+var (
+	// palette is used for traces with no color
+	palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+	namedColors = map[string]string{"black": "#000000", "red": "#ff0000", "blue": "#0000ff", "green": "#008000",
+		"yellow": "#ffff00", "white": "#ffffff", "grey": "#808080", "gray": "#808080", "orange": "#ffa500",
+		"purple": "#800080"}
+)
+
+// toColor converts a Plotly color (name or #rrggbb) to RGBA.  def is used if c is not recognized.
+func toColor(c any, def string) color.RGBA {
+	str := strings.ToLower(fmt.Sprintf("%v", c))
+	if hex, ok := namedColors[str]; ok {
+		str = hex
+	}
+
+	if len(str) != 7 || str[0] != '#' {
+		str = def
+	}
+
+	v, e := strconv.ParseUint(str[1:], 16, 32)
+	if e != nil {
+		return color.RGBA{A: 255}
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}
+}
+
+// plotVals converts Plotly data to numbers.  If any value is a string, the values are categorical and returned
+// in cats instead.
+func plotVals(data any) (nums []float64, cats []string) {
+	v := reflect.ValueOf(data)
+	if !v.IsValid() || v.Kind() != reflect.Slice {
+		return nil, nil
+	}
+
+	vals := make([]any, v.Len())
+	isCat := false
+
+	for ind := range vals {
+		vals[ind] = v.Index(ind).Interface()
+		if _, ok := vals[ind].(string); ok {
+			isCat = true
+		}
+	}
+
+	for ind := 0; ind < len(vals) && !isCat; ind++ {
+		x, e := utilities.Any2Float64(vals[ind])
+		if e != nil {
+			isCat = true
+			break
+		}
+
+		nums = append(nums, *x)
+	}
+
+	if isCat {
+		cats = make([]string, len(vals))
+		for ind, val := range vals {
+			cats[ind] = fmt.Sprintf("%v", val)
+		}
+
+		return nil, cats
+	}
+
+	return nums, nil
+}
+
+// axis maps data values to pixels
+type axis struct {
+	lo, hi     float64  // data range (numeric axis)
+	cats       []string // categories (categorical axis)
+	pLo, pHi   float64  // pixel range
+	hasNum     bool
+	categories map[string]int
+}
+
+func newAxis() *axis {
+	return &axis{lo: math.Inf(1), hi: math.Inf(-1), categories: make(map[string]int)}
+}
+
+// add adds numeric data or categories to the axis range
+func (ax *axis) add(nums []float64, cats []string) {
+	for _, x := range nums {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			continue
+		}
+
+		ax.hasNum = true
+		ax.lo, ax.hi = math.Min(ax.lo, x), math.Max(ax.hi, x)
+	}
+
+	for _, c := range cats {
+		if _, ok := ax.categories[c]; !ok {
+			ax.categories[c] = len(ax.cats)
+			ax.cats = append(ax.cats, c)
+		}
+	}
+}
+
+// finish pads the numeric range
+func (ax *axis) finish() {
+	if !ax.hasNum {
+		ax.lo, ax.hi = 0, 1
+		return
+	}
+
+	if ax.lo == ax.hi {
+		ax.lo, ax.hi = ax.lo-1, ax.hi+1
+		return
+	}
+
+	pad := 0.05 * (ax.hi - ax.lo)
+	ax.lo, ax.hi = ax.lo-pad, ax.hi+pad
+}
+
+// isCat is true if the axis is categorical
+func (ax *axis) isCat() bool {
+	return len(ax.cats) > 0
+}
+
+// pix maps a numeric value to pixels
+func (ax *axis) pix(x float64) float64 {
+	return ax.pLo + (x-ax.lo)/(ax.hi-ax.lo)*(ax.pHi-ax.pLo)
+}
+
+// catPix maps a category to the pixel at the center of its band; width is the width of the band
+func (ax *axis) catPix(c string) (center, width float64) {
+	width = (ax.pHi - ax.pLo) / float64(len(ax.cats))
+
+	return ax.pLo + (float64(ax.categories[c])+0.5)*width, width
+}
+
+// ticks returns about n round values in the range of the axis
+func (ax *axis) ticks(n int) []float64 {
+	raw := (ax.hi - ax.lo) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+
+	step := mag
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+
+	var tks []float64
+	for t := math.Ceil(ax.lo/step) * step; t <= ax.hi; t += step {
+		tks = append(tks, t)
+	}
+
+	return tks
+}
+
+// panel is a subplot: the traces that share a pair of axes
+type panel struct {
+	num    int
+	traces []grob.Trace
+	x, y   *axis
+}
+
+// axisNum returns the number of a Plotly axis reference: "x" or "" is 1, "x3" is 3
+func axisNum(ref any) int {
+	str := strings.TrimLeft(fmt.Sprintf("%v", ref), "xy")
+	if n, e := strconv.Atoi(str); e == nil && n > 0 {
+		return n
+	}
+
+	return 1
+}
+
+// supported returns true if the static renderer can draw tr
+func supported(tr grob.Trace) bool {
+	switch tr.(type) {
+	case *grob.Scatter, *grob.Bar, *grob.Histogram, *grob.Box, *grob.Heatmap:
+		return true
+	}
+
+	return false
+}
+
+// traceAxes returns the x and y axis references of a trace
+func traceAxes(tr grob.Trace) (xRef, yRef any) {
+	switch t := tr.(type) {
+	case *grob.Scatter:
+		return t.Xaxis, t.Yaxis
+	case *grob.Bar:
+		return t.Xaxis, t.Yaxis
+	case *grob.Histogram:
+		return t.Xaxis, t.Yaxis
+	case *grob.Box:
+		return t.Xaxis, t.Yaxis
+	case *grob.Heatmap:
+		return t.Xaxis, t.Yaxis
+	}
+
+	return nil, nil
+}
+
+// histBars bins the x values of a histogram, returning the left edges, width and heights of the bars
+func histBars(h *grob.Histogram) (left []float64, width float64, height []float64) {
+	x, _ := plotVals(h.X)
+	if len(x) == 0 {
+		return nil, 0, nil
+	}
+
+	gd := &GDatum{FT: &FType{Role: FRCts}, Data: x}
+
+	bins, e := gd.Histogram(utilities.MinInt(30, utilities.MaxInt(5, int(math.Sqrt(float64(len(x)))))))
+	if e != nil {
+		return nil, 0, nil
+	}
+
+	left = bins.Edges[:len(bins.Edges)-1]
+	width = bins.Edges[1] - bins.Edges[0]
+	height = make([]float64, len(bins.Counts))
+
+	for ind, c := range bins.Counts {
+		height[ind] = float64(c)
+
+		switch h.Histnorm {
+		case grob.HistogramHistnormPercent:
+			height[ind] = 100 * float64(c) / float64(len(x))
+		case grob.HistogramHistnormProbability:
+			height[ind] = float64(c) / float64(len(x))
+		case grob.HistogramHistnormDensity, grob.HistogramHistnormProbabilityDensity:
+			if width > 0 {
+				height[ind] = float64(c) / float64(len(x)) / width
+			}
+		}
+	}
+
+	return left, width, height
+}
+
+// boxStats returns the values of y for each category of x
+func boxStats(b *grob.Box) (cats []string, ys map[string][]float64) {
+	y, _ := plotVals(b.Y)
+	xNum, xCat := plotVals(b.X)
+
+	ys = make(map[string][]float64)
+
+	for ind, yv := range y {
+		c := ""
+
+		switch {
+		case xCat != nil && ind < len(xCat):
+			c = xCat[ind]
+		case xNum != nil && ind < len(xNum):
+			c = fmt.Sprintf("%v", xNum[ind])
+		}
+
+		if _, ok := ys[c]; !ok {
+			cats = append(cats, c)
+		}
+
+		ys[c] = append(ys[c], yv)
+	}
+
+	return cats, ys
+}
+
+// addRange adds the data of tr to the ranges of the axes of p
+func (p *panel) addRange(tr grob.Trace) {
+	switch t := tr.(type) {
+	case *grob.Scatter:
+		p.x.add(plotVals(t.X))
+		p.y.add(plotVals(t.Y))
+	case *grob.Bar:
+		p.x.add(plotVals(t.X))
+		p.y.add(plotVals(t.Y))
+		p.y.add([]float64{0}, nil)
+	case *grob.Histogram:
+		left, width, height := histBars(t)
+		if len(left) > 0 {
+			p.x.add([]float64{left[0], left[len(left)-1] + width}, nil)
+			p.y.add(height, nil)
+			p.y.add([]float64{0}, nil)
+		}
+	case *grob.Box:
+		cats, ys := boxStats(t)
+		p.x.add(nil, cats)
+
+		for _, y := range ys {
+			p.y.add(y, nil)
+		}
+	case *grob.Heatmap:
+		xNum, xCat := plotVals(t.X)
+		yNum, yCat := plotVals(t.Y)
+		p.x.add(nil, append(xCat, floatLabels(xNum)...))
+		p.y.add(nil, append(yCat, floatLabels(yNum)...))
+	}
+}
+
+// floatLabels formats x as strings
+func floatLabels(x []float64) []string {
+	out := make([]string, len(x))
+	for ind, xv := range x {
+		out[ind] = fmt.Sprintf("%g", xv)
+	}
+
+	return out
+}
+
+// xPix and yPix map a value (number or category) to pixels
+func (p *panel) xPix(num float64, cat string) float64 {
+	if p.x.isCat() {
+		c, _ := p.x.catPix(cat)
+		return c
+	}
+
+	return p.x.pix(num)
+}
+
+func (p *panel) yPix(num float64, cat string) float64 {
+	if p.y.isCat() {
+		c, _ := p.y.catPix(cat)
+		return c
+	}
+
+	return p.y.pix(num)
+}
+
+// draw draws the traces of the panel
+func (p *panel) draw(cv canvas) {
+	for ind, tr := range p.traces {
+		def := palette[ind%len(palette)]
+
+		switch t := tr.(type) {
+		case *grob.Scatter:
+			p.drawScatter(cv, t, def)
+		case *grob.Bar:
+			c := toColor(nil, def)
+			if t.Marker != nil {
+				c = toColor(t.Marker.Color, def)
+			}
+
+			xNum, xCat := plotVals(t.X)
+			y, _ := plotVals(t.Y)
+
+			for ind, yv := range y {
+				var xc, w float64
+
+				switch {
+				case xCat != nil:
+					xc, w = p.x.catPix(xCat[ind])
+				case ind < len(xNum):
+					xc, w = p.x.pix(xNum[ind]), (p.x.pHi-p.x.pLo)/float64(len(y)+1)
+				}
+
+				y0, y1 := p.y.pix(0), p.y.pix(yv)
+				cv.rect(xc-0.4*w, math.Min(y0, y1), 0.8*w, math.Abs(y1-y0), c)
+			}
+		case *grob.Histogram:
+			c := toColor(nil, def)
+			if t.Marker != nil {
+				c = toColor(t.Marker.Color, def)
+			}
+
+			left, width, height := histBars(t)
+			for ind, hv := range height {
+				x0, x1 := p.x.pix(left[ind]), p.x.pix(left[ind]+width)
+				y0, y1 := p.y.pix(0), p.y.pix(hv)
+				cv.rect(x0, y1, math.Max(x1-x0-1, 1), y0-y1, c)
+			}
+		case *grob.Box:
+			c := toColor(nil, def)
+			cats, ys := boxStats(t)
+
+			for _, cat := range cats {
+				y := ys[cat]
+				sort.Float64s(y)
+
+				q := func(u float64) float64 { return p.y.pix(y[int(u*float64(len(y)-1))]) }
+				xc, w := p.x.catPix(cat)
+
+				cv.line(xc, q(0), xc, q(1), 1, c)
+				cv.rect(xc-0.3*w, q(0.75), 0.6*w, q(0.25)-q(0.75), c)
+				cv.line(xc-0.3*w, q(0.5), xc+0.3*w, q(0.5), 2, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		case *grob.Heatmap:
+			p.drawHeatmap(cv, t)
+		}
+	}
+}
+
+func (p *panel) drawScatter(cv canvas, t *grob.Scatter, def string) {
+	c := toColor(nil, def)
+	if t.Line != nil {
+		c = toColor(t.Line.Color, def)
+	}
+
+	if t.Marker != nil && t.Marker.Color != nil {
+		c = toColor(t.Marker.Color, def)
+	}
+
+	xNum, xCat := plotVals(t.X)
+	yNum, yCat := plotVals(t.Y)
+
+	n := utilities.MaxInt(len(xNum), len(xCat))
+	n = utilities.MinInt(n, utilities.MaxInt(len(yNum), len(yCat)))
+
+	pt := func(ind int) (float64, float64) {
+		var xv, yv float64
+		var xc, yc string
+
+		if xCat != nil {
+			xc = xCat[ind]
+		} else {
+			xv = xNum[ind]
+		}
+
+		if yCat != nil {
+			yc = yCat[ind]
+		} else {
+			yv = yNum[ind]
+		}
+
+		return p.xPix(xv, xc), p.yPix(yv, yc)
+	}
+
+	mode := fmt.Sprintf("%v", t.Mode)
+	lines := mode == "" || strings.Contains(mode, "lines")
+	markers := strings.Contains(mode, "markers")
+
+	for ind := 0; ind < n; ind++ {
+		x, y := pt(ind)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			continue
+		}
+
+		if markers {
+			cv.circle(x, y, 3, c)
+		}
+
+		if lines && ind > 0 {
+			x0, y0 := pt(ind - 1)
+			if !math.IsNaN(x0) && !math.IsNaN(y0) {
+				cv.line(x0, y0, x, y, 2, c)
+			}
+		}
+	}
+}
+
+func (p *panel) drawHeatmap(cv canvas, t *grob.Heatmap) {
+	xNum, xCat := plotVals(t.X)
+	yNum, yCat := plotVals(t.Y)
+	xs, ys := append(xCat, floatLabels(xNum)...), append(yCat, floatLabels(yNum)...)
+
+	zv := reflect.ValueOf(t.Z)
+	if zv.Kind() != reflect.Slice {
+		return
+	}
+
+	zLo, zHi := t.Zmin, t.Zmax
+	for r := 0; r < zv.Len(); r++ {
+		for _, z := range zRow(zv.Index(r)) {
+			if t.Zmin == t.Zmax && !math.IsNaN(z) {
+				zLo, zHi = math.Min(zLo, z), math.Max(zHi, z)
+			}
+		}
+	}
+
+	for r := 0; r < zv.Len() && r < len(ys); r++ {
+		row := zRow(zv.Index(r))
+
+		for c := 0; c < len(row) && c < len(xs); c++ {
+			// empty cell
+			if math.IsNaN(row[c]) {
+				continue
+			}
+
+			xc, w := p.x.catPix(xs[c])
+			yc, h := p.y.catPix(ys[r])
+
+			// blue (low) to white to red (high)
+			u := 0.5
+			if zHi > zLo {
+				u = math.Max(0, math.Min(1, (row[c]-zLo)/(zHi-zLo)))
+			}
+
+			col := color.RGBA{R: 255, G: uint8(255 * (1 - math.Abs(2*u-1))), B: 255, A: 255}
+			if u < 0.5 {
+				col.R = col.G
+			} else {
+				col.B = col.G
+			}
+
+			cv.rect(xc-w/2, yc-math.Abs(h)/2, w, math.Abs(h), col)
+		}
+	}
+}
+
+// zRow converts a row of heatmap values to float64.  Values that are not numbers (e.g. nil for an empty cell) are NaN.
+func zRow(row reflect.Value) []float64 {
+	for row.Kind() == reflect.Interface {
+		row = row.Elem()
+	}
+
+	if row.Kind() != reflect.Slice {
+		return nil
+	}
+
+	z := make([]float64, row.Len())
+	for ind := range z {
+		z[ind] = math.NaN()
+		if x, e := utilities.Any2Float64(row.Index(ind).Interface()); e == nil {
+			z[ind] = *x
+		}
+	}
+
+	return z
+}
+
+// drawAxes draws the frame, ticks and tick labels of the panel
+func (p *panel) drawAxes(cv canvas) {
+	black, grey := color.RGBA{A: 255}, color.RGBA{R: 220, G: 220, B: 220, A: 255}
+	const fontSize = 10
+
+	cv.line(p.x.pLo, p.y.pLo, p.x.pHi, p.y.pLo, 1, black)
+	cv.line(p.x.pLo, p.y.pLo, p.x.pLo, p.y.pHi, 1, black)
+
+	switch p.x.isCat() {
+	case true:
+		for _, c := range p.x.cats {
+			xc, _ := p.x.catPix(c)
+			cv.text(xc, p.y.pLo+fontSize+4, fontSize, "middle", false, c)
+		}
+	case false:
+		for _, tk := range p.x.ticks(5) {
+			x := p.x.pix(tk)
+			cv.line(x, p.y.pLo, x, p.y.pHi, 0.5, grey)
+			cv.text(x, p.y.pLo+fontSize+4, fontSize, "middle", false, fmt.Sprintf("%g", roundTick(tk)))
+		}
+	}
+
+	switch p.y.isCat() {
+	case true:
+		for _, c := range p.y.cats {
+			yc, _ := p.y.catPix(c)
+			cv.text(p.x.pLo-4, yc+fontSize/2, fontSize, "end", false, c)
+		}
+	case false:
+		for _, tk := range p.y.ticks(5) {
+			y := p.y.pix(tk)
+			cv.line(p.x.pLo, y, p.x.pHi, y, 0.5, grey)
+			cv.text(p.x.pLo-4, y+fontSize/2, fontSize, "end", false, fmt.Sprintf("%g", roundTick(tk)))
+		}
+	}
+}
+
+// roundTick removes floating point noise from tick values
+func roundTick(x float64) float64 {
+	return math.Round(x*1e9) / 1e9
+}
+
+// drawFig draws fig on cv, which is w x h pixels
+func drawFig(cv canvas, fig *grob.Fig, w, h float64) error {
+	const (
+		titleSize = 16.0
+		axisSize  = 12.0
+		margin    = 60.0
+	)
+
+	// group the traces into panels
+	panels := make(map[int]*panel)
+	for _, tr := range fig.Data {
+		if !supported(tr) {
+			return Wrapper(ErrDiags, fmt.Sprintf("StaticBackend: trace type %s not supported", tr.GetType()))
+		}
+
+		xRef, _ := traceAxes(tr)
+		num := axisNum(xRef)
+		if panels[num] == nil {
+			panels[num] = &panel{num: num, x: newAxis(), y: newAxis()}
+		}
+
+		panels[num].traces = append(panels[num].traces, tr)
+	}
+
+	nums := make([]int, 0, len(panels))
+	for num := range panels {
+		nums = append(nums, num)
+	}
+
+	sort.Ints(nums)
+
+	// grid of the panels
+	rows, cols := 1, 1
+	if lay := fig.Layout; lay != nil && lay.Grid != nil && lay.Grid.Rows > 0 && lay.Grid.Columns > 0 {
+		rows, cols = int(lay.Grid.Rows), int(lay.Grid.Columns)
+	} else if len(nums) > 1 {
+		cols = int(math.Ceil(math.Sqrt(float64(len(nums)))))
+		rows = (len(nums) + cols - 1) / cols
+	}
+
+	var title, xTitle, yTitle string
+	if lay := fig.Layout; lay != nil {
+		if lay.Title != nil && lay.Title.Text != nil {
+			title = fmt.Sprintf("%v", lay.Title.Text)
+		}
+
+		if lay.Xaxis != nil && lay.Xaxis.Title != nil && lay.Xaxis.Title.Text != nil {
+			xTitle = fmt.Sprintf("%v", lay.Xaxis.Title.Text)
+		}
+
+		if lay.Yaxis != nil && lay.Yaxis.Title != nil && lay.Yaxis.Title.Text != nil {
+			yTitle = fmt.Sprintf("%v", lay.Yaxis.Title.Text)
+		}
+	}
+
+	// title lines
+	top := margin / 2
+	for _, ln := range strings.Split(strings.ReplaceAll(title, "<br>", "\n"), "\n") {
+		if ln != "" {
+			top += titleSize + 4
+			cv.text(w/2, top, titleSize, "middle", false, ln)
+		}
+	}
+
+	xLines := strings.Split(strings.ReplaceAll(xTitle, "<br>", "\n"), "\n")
+	for ind, ln := range xLines {
+		cv.text(w/2, h-float64(len(xLines)-ind)*(axisSize+4), axisSize, "middle", false, ln)
+	}
+
+	cv.text(axisSize+4, h/2, axisSize, "middle", true, yTitle)
+
+	// area for the panels
+	left, right := margin+axisSize, w-margin/2
+	topP, bottom := top+margin/2, h-margin-float64(len(xLines))*(axisSize+4)
+	pw, ph := (right-left)/float64(cols), (bottom-topP)/float64(rows)
+
+	for ind, num := range nums {
+		p := panels[num]
+
+		pos := ind
+		if fig.Layout != nil && fig.Layout.Grid != nil && fig.Layout.Grid.Rows > 0 {
+			pos = num - 1
+		}
+
+		r, c := pos/cols, pos%cols
+		if r >= rows {
+			continue
+		}
+
+		for _, tr := range p.traces {
+			p.addRange(tr)
+		}
+
+		p.x.finish()
+		p.y.finish()
+
+		const gap = 30.0
+		p.x.pLo, p.x.pHi = left+float64(c)*pw+gap, left+float64(c+1)*pw-gap/2
+		p.y.pLo, p.y.pHi = topP+float64(r+1)*ph-gap, topP+float64(r)*ph+gap/2
+
+		p.drawAxes(cv)
+		p.draw(cv)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package seafan
+
+// poly.go generates polynomial and interaction features
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// factor is a field raised to a power
+type factor struct {
+	field string
+	power int
+}
+
+// parseTerm parses a product of powers of fields such as "x1*x2" or "x1^2*x3". ok is false if term is not a
+// product or power.
+func parseTerm(term string) (factors []factor, ok bool) {
+	if !strings.ContainsAny(term, "*^") {
+		return nil, false
+	}
+
+	for _, f := range strings.Split(term, "*") {
+		fac := factor{field: f, power: 1}
+
+		if ind := strings.Index(f, "^"); ind >= 0 {
+			p, e := strconv.Atoi(f[ind+1:])
+			if e != nil || p < 1 {
+				return nil, false
+			}
+
+			fac = factor{field: f[:ind], power: p}
+		}
+
+		if fac.field == "" {
+			return nil, false
+		}
+
+		factors = append(factors, fac)
+	}
+
+	return factors, true
+}
+
+// termValues returns the values of term for each row. get returns the data of a field.
+func termValues(term string, nRow int, get func(field string) ([]float64, error)) ([]float64, error) {
+	factors, ok := parseTerm(term)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a product of fields", term)
+	}
+
+	vals := make([]float64, nRow)
+	for ind := range vals {
+		vals[ind] = 1.0
+	}
+
+	for _, fac := range factors {
+		x, e := get(fac.field)
+		if e != nil {
+			return nil, e
+		}
+
+		for ind := range vals {
+			vals[ind] *= math.Pow(x[ind], float64(fac.power))
+		}
+	}
+
+	return vals, nil
+}
+
+// AddTerm adds the field term to pipe.  term is a product of powers of FRCts fields, for example "x1*x2", "x1^2"
+// or "x1^2*x3".  The name of the new field is term.  The values are calculated from the data in pipe, so if the
+// fields are normalized, the term is the product of the normalized values. The new field is not normalized.
+//
+// A ModSpec Input that is a term not in the Pipeline, such as Input(x1+x2+x1*x2), is added automatically to the
+// Pipeline passed to NewNNModel.
+func AddTerm(pipe Pipeline, term string) error {
+	if pipe.GetFType(term) != nil {
+		return Wrapper(ErrPipe, fmt.Sprintf("AddTerm: %s exists already", term))
+	}
+
+	get := func(field string) ([]float64, error) {
+		d := pipe.Get(field)
+		if d == nil {
+			return nil, fmt.Errorf("field %s not found", field)
+		}
+
+		if d.FT.Role != FRCts {
+			return nil, fmt.Errorf("field %s is not FRCts", field)
+		}
+
+		return d.Floats(), nil
+	}
+
+	vals, e := termValues(term, pipe.Rows(), get)
+	if e != nil {
+		return Wrapper(ErrPipe, fmt.Sprintf("AddTerm: %v", e))
+	}
+
+	return pipe.GData().AppendC(NewRawCast(vals, nil), term, false, nil, pipe.GetKeepRaw())
+}
+
+// AddPolynomial adds polynomial terms of the FRCts fields to pipe: the powers 2 through degree of each field and the
+// pairwise interactions of the fields.  The fields are named as in AddTerm ("x1^2", "x1*x2"). Terms already in pipe
+// are skipped.  The names of the terms added are returned.
+func AddPolynomial(pipe Pipeline, degree int, fields ...string) ([]string, error) {
+	if degree < 1 {
+		return nil, Wrapper(ErrPipe, "AddPolynomial: degree must be at least 1")
+	}
+
+	terms := make([]string, 0)
+
+	for ind, f := range fields {
+		for p := 2; p <= degree; p++ {
+			terms = append(terms, fmt.Sprintf("%s^%d", f, p))
+		}
+
+		for _, f2 := range fields[ind+1:] {
+			terms = append(terms, f+"*"+f2)
+		}
+	}
+
+	added := make([]string, 0)
+
+	for _, term := range terms {
+		if pipe.GetFType(term) != nil {
+			continue
+		}
+
+		if e := AddTerm(pipe, term); e != nil {
+			return nil, Wrapper(e, "AddPolynomial")
+		}
+
+		added = append(added, term)
+	}
+
+	return added, nil
+}
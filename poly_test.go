@@ -0,0 +1,49 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddPolynomial(t *testing.T) {
+	pipe := NewVecData("poly", getData(t))
+	assert.Nil(t, pipe.GData().AppendC(NewRawCast([]float64{2, 1, 0, 1, 2, 1, 0}, nil), "x4", false, nil, false))
+
+	added, e := AddPolynomial(pipe, 3, "x1", "x4")
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"x1^2", "x1^3", "x1*x4", "x4^2", "x4^3"}, added)
+	assert.Equal(t, []float64{1, 8, 27, 64, 512, 729, 1000}, pipe.Get("x1^3").Data)
+	assert.Equal(t, []float64{2, 2, 0, 4, 16, 9, 0}, pipe.Get("x1*x4").Data)
+
+	// existing terms are skipped
+	added, e = AddPolynomial(pipe, 2, "x1", "x4")
+	assert.Nil(t, e)
+	assert.Equal(t, []string{}, added)
+
+	assert.Nil(t, AddTerm(pipe, "x1^2*x4"))
+	assert.Equal(t, []float64{2, 4, 0, 16, 128, 81, 0}, pipe.Get("x1^2*x4").Data)
+
+	assert.NotNil(t, AddTerm(pipe, "x1*x2"))
+	assert.NotNil(t, AddTerm(pipe, "x1^a"))
+	assert.NotNil(t, AddTerm(pipe, "x1*x4"))
+}
+
+func TestModSpec_InputsTerm(t *testing.T) {
+	pipe := NewVecData("poly", getData(t))
+	assert.Nil(t, pipe.GData().AppendC(NewRawCast([]float64{2, 1, 0, 1, 2, 1, 0}, nil), "x4", false, nil, false))
+
+	mod := ModSpec{
+		"Input(x1+x4+x1*x4)",
+		"FC(size:1)",
+		"Target(x4)",
+	}
+
+	fts, e := mod.Inputs(pipe)
+	assert.Nil(t, e)
+	assert.Equal(t, "x1*x4", fts[2].Name)
+	assert.Equal(t, []float64{2, 2, 0, 4, 16, 9, 0}, pipe.Get("x1*x4").Data)
+
+	_, e = ModSpec{"Input(x1*x2)"}.Inputs(pipe)
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,251 @@
+package seafan
+
+// preprocess.go maps raw data to model inputs using the FTypes of the model build
+
+import (
+	"fmt"
+
+	"github.com/invertedv/utilities"
+)
+
+// Preprocessor maps raw data to the inputs of a model, using the FTypes of the Pipeline the model was built with:
+// continuous fields are normalized as in the build and categorical values are mapped to the levels of the build,
+// for one-hot and embedded fields.  It works on a single row (Row, CSVRow) or a whole *GData (GData), so the same
+// mapping serves batch scoring and online scoring.  See also Scorer, which uses it.
+type Preprocessor struct {
+	fts FTypes
+}
+
+// NewPreprocessor returns a Preprocessor that maps data to fts, usually the FTypes of a model build
+// (see ChData SaveFTypes).
+func NewPreprocessor(fts FTypes) *Preprocessor {
+	return &Preprocessor{fts: fts}
+}
+
+// LoadPreprocessor loads the Preprocessor saved by Save
+func LoadPreprocessor(fileName string) (*Preprocessor, error) {
+	fts, e := LoadFTypes(fileName)
+	if e != nil {
+		return nil, Wrapper(e, "LoadPreprocessor")
+	}
+
+	return NewPreprocessor(fts), nil
+}
+
+// Save saves the Preprocessor.  The file is an FTypes save file (see FTypes Save).
+func (pp *Preprocessor) Save(fileName string) error {
+	return pp.fts.Save(fileName)
+}
+
+// FTypes returns the FTypes of the Preprocessor
+func (pp *Preprocessor) FTypes() FTypes {
+	return pp.fts
+}
+
+// Row returns the model-ready values of fields for a single row of raw data.  The keys of row are field names.
+// FRCts fields have one value, FROneHot and FREmbed fields one per level.  If no fields are given, all the FRCts,
+// FROneHot and FREmbed fields of the FTypes are returned.  Levels not in the FTypes are treated as set by FParam Unseen
+// (see UnseenPolicy).
+func (pp *Preprocessor) Row(row map[string]any, fields ...string) (map[string][]float64, error) {
+	if len(fields) == 0 {
+		for _, ft := range pp.fts {
+			if ft.Role == FRCts || ft.Role == FROneHot || ft.Role == FREmbed {
+				fields = append(fields, ft.Name)
+			}
+		}
+	}
+
+	out := make(map[string][]float64)
+
+	for _, field := range fields {
+		ft := pp.fts.Get(field)
+		if ft == nil {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("(*Preprocessor) Row: field %s not in FTypes", field))
+		}
+
+		if ft.Role == FRCat {
+			return nil, Wrapper(ErrFields, fmt.Sprintf("(*Preprocessor) Row: field %s is FRCat", field))
+		}
+
+		x, e := pp.input(ft, row)
+		if e != nil {
+			return nil, Wrapper(e, "(*Preprocessor) Row")
+		}
+
+		out[field] = x
+	}
+
+	return out, nil
+}
+
+// CSVRow is Row for a record of a CSV file with the given header
+func (pp *Preprocessor) CSVRow(header, record []string, fields ...string) (map[string][]float64, error) {
+	if len(header) != len(record) {
+		return nil, Wrapper(ErrFields, "(*Preprocessor) CSVRow: header and record differ in length")
+	}
+
+	row := make(map[string]any)
+	for ind, field := range header {
+		row[field] = record[ind]
+	}
+
+	return pp.Row(row, fields...)
+}
+
+// GData returns a *GData with the fields of gd that are in the FTypes, remade with the FTypes.  The FROneHot and
+// FREmbed fields of the FTypes are added, as are one-hot fields <field>Oh for the FRCat fields.
+func (pp *Preprocessor) GData(gd *GData) (*GData, error) {
+	newGd, e := gd.UpdateFts(pp.fts)
+	if e != nil {
+		return nil, Wrapper(e, "(*Preprocessor) GData")
+	}
+
+	// if something is in here as a FRCat or FREmbed then we need to add a one-hot field
+	for _, fld := range newGd.FieldList() {
+		ft := newGd.Get(fld).FT
+		if (ft.Role == FRCat || ft.Role == FREmbed) && newGd.Get(ft.Name+"Oh") == nil {
+			if e := newGd.MakeOneHot(ft.Name, ft.Name+"Oh"); e != nil {
+				return nil, Wrapper(e, "(*Preprocessor) GData")
+			}
+		}
+	}
+
+	return newGd, nil
+}
+
+// input returns the input ft for a single row of raw data
+func (pp *Preprocessor) input(ft *FType, row map[string]any) ([]float64, error) {
+	if ft.Role == FRCts {
+		if _, ok := row[ft.Name]; !ok {
+			if ft.FP != nil && ft.FP.Knot != nil {
+				from := &FType{Name: ft.From}
+				x, e := pp.cts(from, row)
+				if e != nil {
+					return nil, e
+				}
+
+				return []float64{hinge(x[0], *ft.FP.Knot)}, nil
+			}
+
+			if _, isTerm := parseTerm(ft.Name); isTerm {
+				get := func(field string) ([]float64, error) {
+					fft := pp.fts.Get(field)
+					if fft == nil {
+						return nil, fmt.Errorf("FType of %s, a factor of %s, not found", field, ft.Name)
+					}
+
+					return pp.cts(fft, row)
+				}
+
+				return termValues(ft.Name, 1, get)
+			}
+		}
+
+		return pp.cts(ft, row)
+	}
+
+	// an embedded FRCat input is looked up by its own levels
+	fromName := ft.From
+	if ft.Role == FRCat {
+		fromName = ft.Name
+	}
+
+	from := pp.fts.Get(fromName)
+	if from == nil || from.FP == nil {
+		return nil, fmt.Errorf("FType of %s, the source of %s, not found", fromName, ft.Name)
+	}
+
+	val, ok := row[from.Name]
+	if !ok {
+		return nil, fmt.Errorf("field %s not in row", from.Name)
+	}
+
+	lvl, unseen, e := unseenLookup(from, val)
+	if e != nil {
+		return nil, e
+	}
+
+	x := make([]float64, ft.Cats)
+	if lvl < 0 || int(lvl) >= len(x) {
+		return nil, fmt.Errorf("field %s: level %v out of range", from.Name, val)
+	}
+
+	// values not in the levels get the average under UnseenAverage, as in MakeOneHot
+	if unseen && from.FP.Unseen == UnseenAverage {
+		for col := 0; col < len(x); col++ {
+			x[col] = 1 / float64(len(x))
+			if from.Role == FROrdinal {
+				x[col] = float64(len(x)-col) / float64(len(x))
+			}
+		}
+
+		return x, nil
+	}
+
+	// ordinal fields have cumulative coding (see MakeOneHot)
+	if from.Role == FROrdinal {
+		for col := 0; col <= int(lvl); col++ {
+			x[col] = 1.0
+		}
+
+		return x, nil
+	}
+
+	x[lvl] = 1.0
+
+	return x, nil
+}
+
+// cts returns the value of the FRCts field ft for a single row of raw data, normalized if ft is normalized
+func (pp *Preprocessor) cts(ft *FType, row map[string]any) ([]float64, error) {
+	val, ok := row[ft.Name]
+	if !ok {
+		return nil, fmt.Errorf("field %s not in row", ft.Name)
+	}
+
+	x, e := utilities.Any2Float64(val)
+	if e != nil {
+		return nil, fmt.Errorf("field %s: %v", ft.Name, e)
+	}
+
+	if ft.Normalized {
+		return []float64{(*x - ft.FP.Location) / ft.FP.Scale}, nil
+	}
+
+	return []float64{*x}, nil
+}
+
+// unseenLookup returns the category of val for the field ft.  Values not in the levels are treated as set by
+// FParam Unseen, as in AppendD: unseen is true if val is not in the levels.
+func unseenLookup(ft *FType, val any) (lvl int32, unseen bool, err error) {
+	if lvl, ok := lookupLevel(ft.FP, val); ok {
+		return lvl, false, nil
+	}
+
+	if ft.FP.Unseen == UnseenError {
+		return 0, true, fmt.Errorf("field %s: level %v not found", ft.Name, val)
+	}
+
+	lvl, ok := unseenLevel(ft.FP)
+	if !ok {
+		return 0, true, fmt.Errorf("field %s: level %v not found and no default", ft.Name, val)
+	}
+
+	return lvl, true, nil
+}
+
+// lookupLevel returns the category of val. If val is not found directly, the levels are compared as strings.
+func lookupLevel(fp *FParam, val any) (int32, bool) {
+	if lvl, ok := fp.Lvl[val]; ok {
+		return lvl, true
+	}
+
+	str := fmt.Sprintf("%v", val)
+	for k, lvl := range fp.Lvl {
+		if fmt.Sprintf("%v", k) == str {
+			return lvl, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,97 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreprocessor_Row(t *testing.T) {
+	fts := FTypes{
+		{Name: "Field1", Role: FRCat, FP: &FParam{Lvl: Levels{"a": 0, "b": 1, "c": 2}, Default: "c"}},
+		{Name: "Field1Oh", Role: FROneHot, From: "Field1", Cats: 3},
+		{Name: "Field3", Role: FRCts, Normalized: true, FP: &FParam{Location: 2, Scale: 4}},
+	}
+
+	fileName := os.TempDir() + "/preprocess.json"
+	defer func() { _ = os.Remove(fileName) }()
+
+	assert.Nil(t, NewPreprocessor(fts).Save(fileName))
+
+	pp, e := LoadPreprocessor(fileName)
+	assert.Nil(t, e)
+
+	got, e := pp.Row(map[string]any{"Field1": "b", "Field3": 10.0})
+	assert.Nil(t, e)
+	assert.Equal(t, map[string][]float64{"Field1Oh": {0, 1, 0}, "Field3": {2}}, got)
+
+	// unseen level goes to the default
+	got, e = pp.Row(map[string]any{"Field1": "q"}, "Field1Oh")
+	assert.Nil(t, e)
+	assert.Equal(t, []float64{0, 0, 1}, got["Field1Oh"])
+
+	csv, e := pp.CSVRow([]string{"Field1", "Field3"}, []string{"b", "10"})
+	assert.Nil(t, e)
+	assert.Equal(t, map[string][]float64{"Field1Oh": {0, 1, 0}, "Field3": {2}}, csv)
+
+	_, e = pp.Row(map[string]any{"Field1": "b"})
+	assert.NotNil(t, e)
+
+	_, e = pp.Row(map[string]any{"Field1": "b"}, "Field1")
+	assert.NotNil(t, e)
+}
+
+func TestPreprocessor_Unseen(t *testing.T) {
+	tests := []struct {
+		policy UnseenPolicy
+		exp    []float64
+	}{
+		{UnseenOther, []float64{0, 0, 1}},
+		{UnseenError, nil},
+		{UnseenMostFrequent, []float64{0, 1, 0}},
+		{UnseenAverage, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}},
+	}
+
+	for _, tst := range tests {
+		fts := FTypes{
+			{Name: "Field1", Role: FRCat, FP: &FParam{Lvl: Levels{"a": 0, "b": 1, "c": 2}, Default: "c",
+				Unseen: tst.policy, Frequent: "b"}},
+			{Name: "Field1Oh", Role: FROneHot, From: "Field1", Cats: 3},
+		}
+
+		got, e := NewPreprocessor(fts).Row(map[string]any{"Field1": "q"})
+		if tst.exp == nil {
+			assert.NotNil(t, e)
+			continue
+		}
+
+		assert.Nil(t, e)
+		assert.Equal(t, tst.exp, got["Field1Oh"])
+	}
+
+	// the same coding as MakeOneHot
+	fp := &FParam{Lvl: Levels{"a": 0, "b": 1}, Default: "a", Unseen: UnseenAverage}
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRawCast([]string{"q"}, nil), "Field1", fp, false))
+	assert.Nil(t, gd.MakeOneHot("Field1", "Field1Oh"))
+
+	got, e := NewPreprocessor(gd.GetFTypes()).Row(map[string]any{"Field1": "q"})
+	assert.Nil(t, e)
+	assert.Equal(t, gd.Get("Field1Oh").Data, got["Field1Oh"])
+}
+
+func TestPreprocessor_GData(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	pp := NewPreprocessor(pipe.GetFTypes())
+
+	gd, e := pp.GData(pipe.GData())
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, []string{"Field1", "row", "Field3", "Field1Oh"}, gd.FieldList())
+	assert.Equal(t, pipe.Rows(), gd.Rows())
+}
@@ -0,0 +1,162 @@
+package seafan
+
+// psi.go measures the drift of the distribution of fields between two pipelines
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// PSIFlag is the PSI above which a field is flagged as having shifted
+	PSIFlag = 0.25
+
+	// psiFloor is the smallest share of a bin.  It avoids infinite PSI values for empty bins.
+	psiFloor = 1e-4
+)
+
+// PSI calculates the population stability index of each field between pipeBase and pipeNew.  FRCts fields are
+// grouped into bins with edges at the quantiles of pipeBase (in the units of the raw data).  FRCat fields are
+// grouped by level.  The PSI is sum over bins of (new share - base share) * log(new share / base share).
+//
+// The report has one row per field with the fields: field, psi, bins and flag.  flag is 1 if psi exceeds
+// PSIFlag.  The names of the flagged fields are also returned.
+func PSI(pipeBase, pipeNew Pipeline, fields []string, bins int) (report Pipeline, flagged []string, err error) {
+	if bins < 2 {
+		return nil, nil, Wrapper(ErrDiags, "PSI: bins must be at least 2")
+	}
+
+	var names []any
+
+	var psis, nBins, flags []any
+
+	flagged = make([]string, 0)
+
+	for _, field := range fields {
+		base, nw := pipeBase.Get(field), pipeNew.Get(field)
+		if base == nil || nw == nil {
+			return nil, nil, Wrapper(ErrDiags, fmt.Sprintf("PSI: field %s not in both pipelines", field))
+		}
+
+		if base.FT.Role != nw.FT.Role {
+			return nil, nil, Wrapper(ErrDiags, fmt.Sprintf("PSI: field %s has different roles", field))
+		}
+
+		var (
+			baseShare, newShare []float64
+			e                   error
+		)
+
+		switch base.FT.Role {
+		case FRCts:
+			if baseShare, newShare, e = psiCts(base, nw, bins); e != nil {
+				return nil, nil, e
+			}
+		case FRCat:
+			baseShare, newShare = psiCat(base, nw)
+		default:
+			return nil, nil, Wrapper(ErrDiags, fmt.Sprintf("PSI: field %s must be FRCts or FRCat", field))
+		}
+
+		psi := 0.0
+
+		for ind, b := range baseShare {
+			b, n := math.Max(b, psiFloor), math.Max(newShare[ind], psiFloor)
+			psi += (n - b) * math.Log(n/b)
+		}
+
+		flag := int32(0)
+		if psi > PSIFlag {
+			flag = 1
+			flagged = append(flagged, field)
+		}
+
+		names = append(names, field)
+		psis = append(psis, psi)
+		nBins = append(nBins, int32(len(baseShare)))
+		flags = append(flags, flag)
+	}
+
+	report, err = VecFromAny([][]any{names, psis, nBins, flags}, []string{"field", "psi", "bins", "flag"}, nil)
+	if err != nil {
+		return nil, nil, Wrapper(err, "PSI")
+	}
+
+	return report, flagged, nil
+}
+
+// psiCts returns the share of base and nw in each bin. The bin edges are the quantiles of base.
+func psiCts(base, nw *GDatum, bins int) (baseShare, newShare []float64, err error) {
+	xBase := UnNormalize(append([]float64{}, base.Floats()...), base.FT)
+	xNew := UnNormalize(append([]float64{}, nw.Floats()...), nw.FT)
+
+	if len(xBase) == 0 || len(xNew) == 0 {
+		return nil, nil, Wrapper(ErrDiags, fmt.Sprintf("PSI: field %s has no rows", base.FT.Name))
+	}
+
+	sorted := append([]float64{}, xBase...)
+	sort.Float64s(sorted)
+
+	// interior edges
+	edges := make([]float64, 0)
+
+	for b := 1; b < bins; b++ {
+		q := sorted[int(float64(b)*float64(len(sorted))/float64(bins))]
+		if len(edges) == 0 || q > edges[len(edges)-1] {
+			edges = append(edges, q)
+		}
+	}
+
+	share := func(x []float64) []float64 {
+		s := make([]float64, len(edges)+1)
+		for _, xv := range x {
+			s[sort.Search(len(edges), func(i int) bool { return edges[i] > xv })]++
+		}
+
+		for ind := range s {
+			s[ind] /= float64(len(x))
+		}
+
+		return s
+	}
+
+	return share(xBase), share(xNew), nil
+}
+
+// psiCat returns the share of base and nw at each level. The levels are matched by value.
+func psiCat(base, nw *GDatum) (baseShare, newShare []float64) {
+	counts := func(d *GDatum) map[any]float64 {
+		levels := make(map[int32]any)
+		for k, v := range d.FT.FP.Lvl {
+			levels[v] = k
+		}
+
+		cnt := make(map[any]float64)
+		codes := d.Data.([]int32)
+
+		for _, code := range codes {
+			cnt[levels[code]] += 1.0 / float64(len(codes))
+		}
+
+		return cnt
+	}
+
+	cBase, cNew := counts(base), counts(nw)
+
+	all := make(map[any]bool)
+	for k := range cBase {
+		all[k] = true
+	}
+
+	for k := range cNew {
+		all[k] = true
+	}
+
+	for k := range all {
+		baseShare = append(baseShare, cBase[k])
+		newShare = append(newShare, cNew[k])
+	}
+
+	return baseShare, newShare
+}
@@ -0,0 +1,51 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPSI(t *testing.T) {
+	makePipe := func(shift float64, cats []string) Pipeline {
+		x := make([]float64, 1000)
+		for ind := range x {
+			x[ind] = float64(ind) + shift
+		}
+
+		c := make([]string, 1000)
+		for ind := range c {
+			c[ind] = cats[ind%len(cats)]
+		}
+
+		gd := NewGData()
+		assert.Nil(t, gd.AppendC(NewRawCast(x, nil), "x", true, nil, false))
+		assert.Nil(t, gd.AppendD(NewRawCast(c, nil), "c", nil, false))
+
+		return NewVecData("psi", gd)
+	}
+
+	base := makePipe(0, []string{"a", "b"})
+
+	report, flagged, e := PSI(base, makePipe(0, []string{"b", "a"}), []string{"x", "c"}, 10)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{}, flagged)
+	assert.InDelta(t, 0.0, report.Get("psi").Data.([]float64)[0], 1e-10)
+	assert.InDelta(t, 0.0, report.Get("psi").Data.([]float64)[1], 1e-10)
+	assert.Equal(t, []float64{10, 2}, report.Get("bins").Data)
+
+	// shift x by half its range; add a level to c
+	report, flagged, e = PSI(base, makePipe(500, []string{"a", "b", "c", "c"}), []string{"x", "c"}, 10)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"x", "c"}, flagged)
+	assert.Equal(t, []float64{1, 1}, report.Get("flag").Data)
+
+	_, _, e = PSI(base, base, []string{"x"}, 1)
+	assert.NotNil(t, e)
+
+	empty := &GDatum{FT: &FType{Name: "x", Role: FRCts}, Data: []float64{}}
+	_, _, e = psiCts(empty, base.Get("x"), 10)
+	assert.NotNil(t, e)
+	_, _, e = psiCts(base.Get("x"), empty, 10)
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,384 @@
+package seafan
+
+// registry.go stores saved models by name and version on the filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/invertedv/utilities"
+)
+
+// ModelRegistry stores saved models under <root>/<name>/v<version>.  Each name has a production version, set by
+// Promote and undone by Rollback.  For instance,
+//
+//	reg, e := NewModelRegistry("/models")
+//	version, e := reg.Register("prepay", ft.OutFile(), pipe.GetFTypes(), map[string]string{"data": "2024Q4"})
+//	e = reg.Promote("prepay", version)
+//	sc, e := reg.Scorer("prepay", 0) // the production version
+//
+// A ModelRegistry is safe for concurrent use.  Processes sharing a root should not change the same name at once.
+type ModelRegistry struct {
+	root string
+	mu   sync.Mutex
+}
+
+// ModelVersion describes a version of a model in a ModelRegistry
+type ModelVersion struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// registryFile is the format of <root>/<name>/registry.json
+type registryFile struct {
+	Versions   []ModelVersion `json:"versions"`
+	Production int            `json:"production"` // 0 if no version is promoted
+	History    []int          `json:"history"`    // versions previously in production, most recent last
+}
+
+// the files of a saved model, in the order: required, then optional
+var (
+	modelFiles    = []string{"S.nn", "P.nn"}
+	modelFilesOpt = []string{"M.json"}
+)
+
+const (
+	registryMeta  = "registry.json"
+	registryModel = "model" // file root of a model within its version directory
+	registryFTs   = "F.json"
+)
+
+// NewModelRegistry returns the ModelRegistry at root, which is created if needed
+func NewModelRegistry(root string) (*ModelRegistry, error) {
+	if e := os.MkdirAll(root, 0o755); e != nil {
+		return nil, Wrapper(e, "NewModelRegistry")
+	}
+
+	return &ModelRegistry{root: root}, nil
+}
+
+// Register copies the model saved at fileRoot (by NNModel Save or Fit) into the registry as the next version of
+// name and returns the version.  fts are the FTypes of the model (see Scorer).  If fts is nil, those of the
+// ModelCard saved with the model are used, if there is one.  The version is not put in production (see Promote).
+func (r *ModelRegistry) Register(name, fileRoot string, fts FTypes, meta map[string]string) (int, error) {
+	if e := checkModelName(name); e != nil {
+		return 0, e
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return 0, e
+	}
+
+	version := 1
+	if n := len(reg.Versions); n > 0 {
+		version = reg.Versions[n-1].Version + 1
+	}
+
+	dir := r.dir(name, version)
+	if e := os.MkdirAll(dir, 0o755); e != nil {
+		return 0, Wrapper(e, "(*ModelRegistry) Register")
+	}
+
+	// remove a partial copy on error
+	ok := false
+	defer func() {
+		if !ok {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	dest := filepath.Join(dir, registryModel)
+
+	for _, suffix := range append(modelFiles, modelFilesOpt...) {
+		if e := copyFile(fileRoot+suffix, dest+suffix); e != nil {
+			if os.IsNotExist(e) && utilities.Position(suffix, "", modelFilesOpt...) >= 0 {
+				continue
+			}
+
+			return 0, Wrapper(e, "(*ModelRegistry) Register")
+		}
+	}
+
+	if fts == nil {
+		if mc, e := LoadModelCard(fileRoot); e == nil {
+			fts = mc.FTypes
+		}
+	}
+
+	if fts != nil {
+		if e := fts.Save(dest + registryFTs); e != nil {
+			return 0, Wrapper(e, "(*ModelRegistry) Register")
+		}
+	}
+
+	reg.Versions = append(reg.Versions, ModelVersion{Version: version, Created: time.Now(), Meta: meta})
+	if e := r.save(name, reg); e != nil {
+		return 0, e
+	}
+
+	ok = true
+
+	return version, nil
+}
+
+// Names returns the names of the models in the registry in sorted order
+func (r *ModelRegistry) Names() ([]string, error) {
+	entries, e := os.ReadDir(r.root)
+	if e != nil {
+		return nil, Wrapper(e, "(*ModelRegistry) Names")
+	}
+
+	names := make([]string, 0)
+
+	for _, entry := range entries {
+		if _, e := os.Stat(filepath.Join(r.root, entry.Name(), registryMeta)); entry.IsDir() && e == nil {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Versions returns the versions of name, oldest first
+func (r *ModelRegistry) Versions(name string) ([]ModelVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return nil, e
+	}
+
+	return reg.Versions, nil
+}
+
+// Latest returns the most recent version of name
+func (r *ModelRegistry) Latest(name string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return 0, e
+	}
+
+	if len(reg.Versions) == 0 {
+		return 0, Wrapper(ErrNNModel, fmt.Sprintf("(*ModelRegistry) Latest: no versions of %s", name))
+	}
+
+	return reg.Versions[len(reg.Versions)-1].Version, nil
+}
+
+// Production returns the version of name in production, 0 if none has been promoted
+func (r *ModelRegistry) Production(name string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return 0, e
+	}
+
+	return reg.Production, nil
+}
+
+// Promote puts version of name in production.  The version it replaces can be restored by Rollback.
+func (r *ModelRegistry) Promote(name string, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return e
+	}
+
+	if !reg.has(version) {
+		return Wrapper(ErrNNModel, fmt.Sprintf("(*ModelRegistry) Promote: %s has no version %d", name, version))
+	}
+
+	if reg.Production == version {
+		return nil
+	}
+
+	if reg.Production > 0 {
+		reg.History = append(reg.History, reg.Production)
+	}
+
+	reg.Production = version
+
+	return r.save(name, reg)
+}
+
+// Rollback restores the version of name that was in production before the last Promote and returns it
+func (r *ModelRegistry) Rollback(name string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return 0, e
+	}
+
+	n := len(reg.History)
+	if n == 0 {
+		return 0, Wrapper(ErrNNModel, fmt.Sprintf("(*ModelRegistry) Rollback: %s has no earlier production version", name))
+	}
+
+	reg.Production, reg.History = reg.History[n-1], reg.History[:n-1]
+
+	if e := r.save(name, reg); e != nil {
+		return 0, e
+	}
+
+	return reg.Production, nil
+}
+
+// FileRoot returns the file root of version of name, for LoadNN, PredictNN and the like.  If version is 0, the
+// production version is used or, if there is none, the latest.
+func (r *ModelRegistry) FileRoot(name string, version int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, e := r.load(name)
+	if e != nil {
+		return "", e
+	}
+
+	if version == 0 {
+		version = reg.Production
+		if version == 0 && len(reg.Versions) > 0 {
+			version = reg.Versions[len(reg.Versions)-1].Version
+		}
+	}
+
+	if !reg.has(version) {
+		return "", Wrapper(ErrNNModel, fmt.Sprintf("(*ModelRegistry) FileRoot: %s has no version %d", name, version))
+	}
+
+	return filepath.Join(r.dir(name, version), registryModel), nil
+}
+
+// FTypes returns the FTypes registered with version of name.  version is as for FileRoot.
+func (r *ModelRegistry) FTypes(name string, version int) (FTypes, error) {
+	fileRoot, e := r.FileRoot(name, version)
+	if e != nil {
+		return nil, e
+	}
+
+	return LoadFTypes(fileRoot + registryFTs)
+}
+
+// Scorer returns a Scorer of version of name.  version is as for FileRoot.
+func (r *ModelRegistry) Scorer(name string, version int) (*Scorer, error) {
+	fileRoot, e := r.FileRoot(name, version)
+	if e != nil {
+		return nil, e
+	}
+
+	fts, e := LoadFTypes(fileRoot + registryFTs)
+	if e != nil {
+		return nil, Wrapper(e, fmt.Sprintf("(*ModelRegistry) Scorer: no FTypes for %s", name))
+	}
+
+	return NewScorer(fileRoot, fts)
+}
+
+// dir returns the directory of version of name
+func (r *ModelRegistry) dir(name string, version int) string {
+	return filepath.Join(r.root, name, fmt.Sprintf("v%d", version))
+}
+
+// load reads the registry file of name.  A name without one has no versions.
+func (r *ModelRegistry) load(name string) (*registryFile, error) {
+	reg := &registryFile{}
+
+	js, e := os.ReadFile(filepath.Join(r.root, name, registryMeta))
+	if os.IsNotExist(e) {
+		return reg, nil
+	}
+
+	if e != nil {
+		return nil, Wrapper(e, "ModelRegistry")
+	}
+
+	if e := json.Unmarshal(js, reg); e != nil {
+		return nil, Wrapper(e, "ModelRegistry")
+	}
+
+	return reg, nil
+}
+
+// save writes the registry file of name.  It's written to a temporary file that then replaces it, so the file is
+// never left partly written.
+func (r *ModelRegistry) save(name string, reg *registryFile) error {
+	js, e := json.MarshalIndent(reg, "", "  ")
+	if e != nil {
+		return Wrapper(e, "ModelRegistry")
+	}
+
+	fileName := filepath.Join(r.root, name, registryMeta)
+	if e := os.MkdirAll(filepath.Dir(fileName), 0o755); e != nil {
+		return Wrapper(e, "ModelRegistry")
+	}
+
+	if e := os.WriteFile(fileName+".tmp", js, 0o644); e != nil {
+		return Wrapper(e, "ModelRegistry")
+	}
+
+	return os.Rename(fileName+".tmp", fileName)
+}
+
+// has returns true if version is a version in reg
+func (reg *registryFile) has(version int) bool {
+	for _, v := range reg.Versions {
+		if v.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkModelName returns an error if name can't be a directory name in the registry
+func checkModelName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return Wrapper(ErrNNModel, fmt.Sprintf("ModelRegistry: invalid model name %q", name))
+	}
+
+	return nil
+}
+
+// copyFile copies the file src to dst
+func copyFile(src, dst string) error {
+	in, e := os.Open(src)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = in.Close() }()
+
+	out, e := os.Create(dst)
+	if e != nil {
+		return e
+	}
+
+	if _, e := io.Copy(out, in); e != nil {
+		_ = out.Close()
+		return e
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,94 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelRegistry(t *testing.T) {
+	Verbose = false
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{1, 2, 3, 4}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRawCast([]float64{2, 4, 6, 8}, nil), "y", false, nil, false))
+	pipe := NewVecData("registry", gd, WithBatchSize(4))
+
+	nn, e := NewNNModel(ModSpec{"Input(x)", "FC(size:1)", "Target(y)"}, pipe, false)
+	assert.Nil(t, e)
+
+	tmp, e := os.MkdirTemp("", "registry")
+	assert.Nil(t, e)
+
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	fileRoot := tmp + "/model"
+	assert.Nil(t, nn.Save(fileRoot))
+
+	reg, e := NewModelRegistry(tmp + "/reg")
+	assert.Nil(t, e)
+
+	for exp := 1; exp <= 3; exp++ {
+		version, e := reg.Register("prepay", fileRoot, pipe.GetFTypes(), map[string]string{"run": "test"})
+		assert.Nil(t, e)
+		assert.Equal(t, exp, version)
+	}
+
+	_, e = reg.Register("bad/name", fileRoot, nil, nil)
+	assert.NotNil(t, e)
+
+	_, e = reg.Register("missing", tmp+"/nothere", nil, nil)
+	assert.NotNil(t, e)
+
+	names, e := reg.Names()
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"prepay"}, names)
+
+	versions, e := reg.Versions("prepay")
+	assert.Nil(t, e)
+	assert.Equal(t, 3, len(versions))
+	assert.Equal(t, "test", versions[0].Meta["run"])
+
+	latest, e := reg.Latest("prepay")
+	assert.Nil(t, e)
+	assert.Equal(t, 3, latest)
+
+	// without a production version, the latest is used
+	root, e := reg.FileRoot("prepay", 0)
+	assert.Nil(t, e)
+	assert.Equal(t, reg.dir("prepay", 3)+"/model", root)
+
+	assert.Nil(t, reg.Promote("prepay", 1))
+	assert.Nil(t, reg.Promote("prepay", 2))
+	assert.NotNil(t, reg.Promote("prepay", 9))
+
+	prod, e := reg.Production("prepay")
+	assert.Nil(t, e)
+	assert.Equal(t, 2, prod)
+
+	prod, e = reg.Rollback("prepay")
+	assert.Nil(t, e)
+	assert.Equal(t, 1, prod)
+
+	_, e = reg.Rollback("prepay")
+	assert.NotNil(t, e)
+
+	// the registry is read back from disk
+	reg, e = NewModelRegistry(tmp + "/reg")
+	assert.Nil(t, e)
+
+	root, e = reg.FileRoot("prepay", 0)
+	assert.Nil(t, e)
+	assert.Equal(t, reg.dir("prepay", 1)+"/model", root)
+
+	sc, e := reg.Scorer("prepay", 0)
+	assert.Nil(t, e)
+
+	pred, e := PredictNN(root, pipe, false)
+	assert.Nil(t, e)
+
+	score, e := sc.Score(pipe)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pred.FitSlice(), score, 1e-8)
+}
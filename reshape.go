@@ -0,0 +1,233 @@
+package seafan
+
+// reshape.go implements reshaping GData between long and wide formats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/invertedv/utilities"
+)
+
+// Pivot spreads a long GData to a wide GData.  The output has one row for each value of keyField and one field for
+// each value of nameField. The values of the new fields come from valueField.  The new fields are named
+// by the value of nameField (dates are formatted as CCYYMMDD). Combinations of keyField and nameField that do not
+// occur are filled with the default value of valueField or, if that is not set, with 0, "" or 1/1/1970 (as in Join).
+// Each combination of keyField and nameField can occur only once.
+//
+// For instance, a GData with fields loan, month and balance can be pivoted to one row per loan with a balance
+// field for each month.
+func (gd *GData) Pivot(keyField, nameField, valueField string) (gdOut *GData, err error) {
+	var keyRaw, nameRaw, valRaw *Raw
+
+	if keyRaw, err = gd.GetRaw(keyField); err != nil {
+		return nil, Wrapper(err, "Pivot")
+	}
+
+	if nameRaw, err = gd.GetRaw(nameField); err != nil {
+		return nil, Wrapper(err, "Pivot")
+	}
+
+	if valRaw, err = gd.GetRaw(valueField); err != nil {
+		return nil, Wrapper(err, "Pivot")
+	}
+
+	valFt := gd.GetFType(valueField)
+	if valFt.Role != FRCts && valFt.Role != FRCat {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("Pivot: field %s must be FRCts or FRCat", valueField))
+	}
+
+	// keys in order of first appearance
+	keys := Unique(keyRaw.Data)
+	keyRow := make(map[any]int)
+
+	for ind, k := range keys {
+		keyRow[k] = ind
+	}
+
+	// names in sorted order
+	names := NewRaw(Unique(nameRaw.Data), nil)
+	sort.Sort(names)
+
+	miss := getMiss(&FType{FP: &FParam{Default: gdDefault(valFt)}}, valRaw.Kind)
+	cols := make(map[any][]any)
+	fields := make([]string, len(names.Data))
+
+	for ind, nm := range names.Data {
+		fields[ind] = pivotName(nm)
+		if utilities.Position(fields[ind], "", keyField) >= 0 || utilities.Position(fields[ind], "", fields[:ind]...) >= 0 {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("Pivot: duplicate field name %s", fields[ind]))
+		}
+
+		col := make([]any, len(keys))
+		for row := 0; row < len(keys); row++ {
+			col[row] = miss
+		}
+
+		cols[nm] = col
+	}
+
+	filled := make(map[string]bool)
+
+	for row := 0; row < gd.Rows(); row++ {
+		k, nm := keyRaw.Data[row], nameRaw.Data[row]
+		combo := fmt.Sprintf("%v\x00%v", k, nm)
+
+		if filled[combo] {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("Pivot: %s=%v, %s=%v occurs more than once", keyField, k, nameField, nm))
+		}
+
+		filled[combo] = true
+		cols[nm][keyRow[k]] = valRaw.Data[row]
+	}
+
+	gdOut = NewGData()
+	keyDatum := gd.Get(keyField)
+
+	if err = appendLike(gdOut, NewRaw(keys, nil), keyDatum.FT, keyDatum.Raw != nil); err != nil {
+		return nil, Wrapper(err, "Pivot")
+	}
+
+	keepRaw := gd.Get(valueField).Raw != nil
+
+	for ind, nm := range names.Data {
+		raw := NewRaw(cols[nm], nil)
+
+		switch valFt.Role {
+		case FRCts:
+			err = gdOut.AppendC(raw, fields[ind], false, nil, keepRaw)
+		case FRCat:
+			err = gdOut.AppendD(raw, fields[ind], nil, keepRaw)
+		}
+
+		if err != nil {
+			return nil, Wrapper(err, "Pivot")
+		}
+	}
+
+	return gdOut, nil
+}
+
+// Melt gathers a wide GData to a long GData. It is the inverse of Pivot. The output has the fields idFields
+// plus two new fields:
+//   - variable: the name of the field in valueFields
+//   - value: the value of that field
+//
+// There is one row in the output for each row of gd and field in valueFields. The fields in valueFields must
+// all be FRCts or all FRCat.
+func (gd *GData) Melt(idFields, valueFields []string) (gdOut *GData, err error) {
+	const (
+		variable = "variable"
+		value    = "value"
+	)
+
+	if len(valueFields) == 0 {
+		return nil, Wrapper(ErrGData, "Melt: no value fields")
+	}
+
+	if utilities.Position(variable, "", idFields...) >= 0 || utilities.Position(value, "", idFields...) >= 0 {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("Melt: id fields cannot be named %s or %s", variable, value))
+	}
+
+	nVal := len(valueFields)
+	n := gd.Rows() * nVal
+	vars, vals := make([]any, n), make([]any, n)
+
+	var role FRole
+
+	for indV, fld := range valueFields {
+		ft := gd.GetFType(fld)
+		if ft == nil {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("Melt: field %s not found", fld))
+		}
+
+		if indV == 0 {
+			role = ft.Role
+		}
+
+		if ft.Role != role || (role != FRCts && role != FRCat) {
+			return nil, Wrapper(ErrGData, "Melt: value fields must be all FRCts or all FRCat")
+		}
+
+		raw, e := gd.GetRaw(fld)
+		if e != nil {
+			return nil, Wrapper(e, "Melt")
+		}
+
+		for row := 0; row < gd.Rows(); row++ {
+			vars[row*nVal+indV] = fld
+			vals[row*nVal+indV] = raw.Data[row]
+		}
+	}
+
+	gdOut = NewGData()
+
+	for _, fld := range idFields {
+		raw, e := gd.GetRaw(fld)
+		if e != nil {
+			return nil, Wrapper(e, "Melt")
+		}
+
+		data := make([]any, 0, n)
+		for row := 0; row < gd.Rows(); row++ {
+			for ind := 0; ind < nVal; ind++ {
+				data = append(data, raw.Data[row])
+			}
+		}
+
+		d := gd.Get(fld)
+		if err = appendLike(gdOut, NewRaw(data, nil), d.FT, d.Raw != nil); err != nil {
+			return nil, Wrapper(err, "Melt")
+		}
+	}
+
+	keepRaw := gd.Get(valueFields[0]).Raw != nil
+
+	if err = gdOut.AppendD(NewRaw(vars, nil), variable, nil, keepRaw); err != nil {
+		return nil, Wrapper(err, "Melt")
+	}
+
+	switch role {
+	case FRCts:
+		err = gdOut.AppendC(NewRaw(vals, nil), value, false, nil, keepRaw)
+	case FRCat:
+		err = gdOut.AppendD(NewRaw(vals, nil), value, nil, keepRaw)
+	}
+
+	if err != nil {
+		return nil, Wrapper(err, "Melt")
+	}
+
+	return gdOut, nil
+}
+
+// appendLike appends raw to gd using the role and FParam of ft.
+func appendLike(gd *GData, raw *Raw, ft *FType, keepRaw bool) error {
+	switch ft.Role {
+	case FRCat:
+		return gd.AppendD(raw, ft.Name, ft.FP, keepRaw)
+	case FRCts, FREither:
+		return gd.AppendC(raw, ft.Name, ft.Normalized, ft.FP, keepRaw)
+	}
+
+	return Wrapper(ErrGData, fmt.Sprintf("field %s must be FRCts or FRCat", ft.Name))
+}
+
+// gdDefault returns the default value of ft, if it has one
+func gdDefault(ft *FType) any {
+	if ft.FP == nil {
+		return nil
+	}
+
+	return ft.FP.Default
+}
+
+// pivotName returns the field name to use for the value val
+func pivotName(val any) string {
+	if dt, ok := val.(time.Time); ok {
+		return dt.Format("20060102")
+	}
+
+	return fmt.Sprintf("%v", val)
+}
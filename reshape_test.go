@@ -0,0 +1,51 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGData_Pivot(t *testing.T) {
+	gd := NewGData()
+	e := gd.AppendD(NewRawCast([]string{"l1", "l1", "l2", "l2", "l2", "l3"}, nil), "loan", nil, true)
+	assert.Nil(t, e)
+
+	e = gd.AppendD(NewRawCast([]int64{2, 1, 1, 2, 3, 3}, nil), "month", nil, true)
+	assert.Nil(t, e)
+
+	e = gd.AppendC(NewRawCast([]float64{90, 100, 200, 190, 180, 50}, nil), "balance", false, nil, true)
+	assert.Nil(t, e)
+
+	wide, e := gd.Pivot("loan", "month", "balance")
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"loan", "1", "2", "3"}, wide.FieldList())
+	assert.Equal(t, 3, wide.Rows())
+	assert.Equal(t, []float64{100, 200, 0}, wide.Get("1").Data)
+	assert.Equal(t, []float64{0, 180, 50}, wide.Get("3").Data)
+
+	long, e := wide.Melt([]string{"loan"}, []string{"1", "2"})
+	assert.Nil(t, e)
+	assert.Equal(t, 6, long.Rows())
+
+	vars, e := long.GetRaw("variable")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"1", "2", "1", "2", "1", "2"}, vars.Data)
+	assert.Equal(t, []float64{100, 90, 200, 190, 0, 0}, long.Get("value").Data)
+
+	loans, e := long.GetRaw("loan")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"l1", "l1", "l2", "l2", "l3", "l3"}, loans.Data)
+
+	// round trip
+	wide1, e := long.Pivot("loan", "variable", "value")
+	assert.Nil(t, e)
+	assert.Equal(t, wide.Get("2").Data, wide1.Get("2").Data)
+
+	// each variable occurs for multiple loans
+	_, e = long.Pivot("variable", "variable", "value")
+	assert.NotNil(t, e)
+
+	_, e = wide.Melt([]string{"loan"}, []string{"1", "loan"})
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,188 @@
+package seafan
+
+// scenario.go evaluates a set of expressions under a number of scenarios
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/invertedv/utilities"
+)
+
+// Scenario is a named set of parameter values.  RunScenarios binds the parameters (see Env) when it evaluates the
+// expressions, so they're used as fields are.  For instance,
+//
+//	Scenario{Name: "down", Params: map[string]any{"rateShock": -0.01, "hpiShock": -0.2}}
+type Scenario struct {
+	Name   string
+	Params map[string]any
+}
+
+// ScenarioOpts sets an option of RunScenarios
+type ScenarioOpts func(sr *scenarioRun)
+
+// scenarioRun holds the options of RunScenarios
+type scenarioRun struct {
+	field    string    // name of the scenario field in the output
+	parallel int       // number of scenarios evaluated at the same time
+	evalOpts []EvalOpt // options passed to Evaluate
+}
+
+// WithScenarioField sets the name of the field that holds the scenario name in the output of RunScenarios.  The
+// default is "scenario".
+func WithScenarioField(field string) ScenarioOpts {
+	return func(sr *scenarioRun) {
+		sr.field = field
+	}
+}
+
+// WithParallel sets the number of scenarios RunScenarios evaluates at the same time.  The default is 1.
+func WithParallel(n int) ScenarioOpts {
+	return func(sr *scenarioRun) {
+		if n > 0 {
+			sr.parallel = n
+		}
+	}
+}
+
+// WithScenarioEval sets options that RunScenarios passes to Evaluate.  The Env of each scenario is used in place of
+// an Env set by WithEnv, names not bound by the scenario fall back to that Env.  A NaNReport
+// (WithNaNReport) should not be used with WithParallel.
+func WithScenarioEval(opts ...EvalOpt) ScenarioOpts {
+	return func(sr *scenarioRun) {
+		sr.evalOpts = opts
+	}
+}
+
+// RunScenarios generalizes Loop.  For each scenario, the expressions in exprs are evaluated in order, with the
+// parameters of the scenario bound.  The result of exprs[i] is bound to assign[i], so later expressions may use it.
+// Unlike Loop, pipe is not changed.
+//
+// The output is in long format: the scenario field (see WithScenarioField) followed by the fields assign.  Each
+// scenario contributes as many rows as its longest result.  The other results must have the same length or a single
+// value, which is repeated.  So, if exprs are all summary-level (e.g. mean), there's one row per scenario.
+//
+// Scenarios may be evaluated in parallel (see WithParallel).  Functions that plot should not be used in that case.
+func RunScenarios(scenarios []Scenario, exprs, assign []string, pipe Pipeline, opts ...ScenarioOpts) (Pipeline, error) {
+	sr := &scenarioRun{field: "scenario", parallel: 1}
+	for _, opt := range opts {
+		opt(sr)
+	}
+
+	if len(scenarios) == 0 {
+		return nil, Wrapper(ErrParser, "RunScenarios: no scenarios")
+	}
+
+	if len(exprs) == 0 || len(exprs) != len(assign) {
+		return nil, Wrapper(ErrParser, "RunScenarios: exprs and assign must be non-empty and have the same length")
+	}
+
+	if utilities.Position(sr.field, "", assign...) >= 0 {
+		return nil, Wrapper(ErrParser, fmt.Sprintf("RunScenarios: %s is the scenario field", sr.field))
+	}
+
+	roots := make([]*OpNode, len(exprs))
+	for ind, expr := range exprs {
+		roots[ind] = &OpNode{Expression: expr}
+		if e := Expr2Tree(roots[ind]); e != nil {
+			return nil, e
+		}
+
+		// fields are loaded before the scenarios run in parallel
+		warmFields(roots[ind], pipe.GData())
+	}
+
+	results := make([][]*Raw, len(scenarios))
+	errs := make([]error, len(scenarios))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, sr.parallel)
+
+	for ind := range scenarios {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(ind int) {
+			defer func() { <-sem; wg.Done() }()
+			results[ind], errs[ind] = sr.run(scenarios[ind], roots, assign, pipe)
+		}(ind)
+	}
+
+	wg.Wait()
+
+	cols := make([][]any, len(assign)+1)
+
+	for ind, scen := range scenarios {
+		if errs[ind] != nil {
+			return nil, Wrapper(errs[ind], fmt.Sprintf("RunScenarios: scenario %s", scen.Name))
+		}
+
+		n := 1
+		for _, raw := range results[ind] {
+			n = utilities.MaxInt(n, raw.Len())
+		}
+
+		for row := 0; row < n; row++ {
+			cols[0] = append(cols[0], scen.Name)
+		}
+
+		for col, raw := range results[ind] {
+			if raw.Len() != 1 && raw.Len() != n {
+				return nil, Wrapper(ErrParser, fmt.Sprintf("RunScenarios: scenario %s: %s has %d rows, expected %d",
+					scen.Name, assign[col], raw.Len(), n))
+			}
+
+			for row := 0; row < n; row++ {
+				val := raw.Data[0]
+				if raw.Len() > 1 {
+					val = raw.Data[row]
+				}
+
+				cols[col+1] = append(cols[col+1], val)
+			}
+		}
+	}
+
+	return VecFromAny(cols, append([]string{sr.field}, assign...), nil)
+}
+
+// run evaluates the expressions for a single scenario
+func (sr *scenarioRun) run(scen Scenario, roots []*OpNode, assign []string, pipe Pipeline) ([]*Raw, error) {
+	env := NewEnv()
+	env.parent = newEvalConfig(sr.evalOpts).env
+
+	for name, val := range scen.Params {
+		if e := env.Bind(name, val); e != nil {
+			return nil, e
+		}
+	}
+
+	opts := append(append([]EvalOpt{}, sr.evalOpts...), WithEnv(env))
+	results := make([]*Raw, len(roots))
+
+	for ind, root := range roots {
+		node := CopyNode(root)
+		if e := Evaluate(node, pipe, opts...); e != nil {
+			return nil, e
+		}
+
+		if e := env.Bind(assign[ind], node.Raw.Data); e != nil {
+			return nil, e
+		}
+
+		results[ind] = node.Raw
+	}
+
+	return results, nil
+}
+
+// warmFields loads the *Raw of the fields of gd used by the tree rooted at node
+func warmFields(node *OpNode, gd *GData) {
+	for _, input := range node.Inputs {
+		warmFields(input, gd)
+	}
+
+	if node.Func == nil && gd.Get(node.Expression) != nil {
+		_, _ = gd.GetRaw(node.Expression)
+	}
+}
@@ -0,0 +1,74 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunScenarios(t *testing.T) {
+	Verbose = false
+
+	data := os.Getenv("data")
+	pipe, e := CSVToPipe(data+"/pipeTest1.csv", nil, false)
+	assert.Nil(t, e)
+
+	scens := []Scenario{
+		{Name: "base", Params: map[string]any{"shock": 0.0}},
+		{Name: "up", Params: map[string]any{"shock": 1.0}},
+		{Name: "down", Params: map[string]any{"shock": -1.0}},
+	}
+
+	for _, par := range []int{1, 3} {
+		out, e := RunScenarios(scens, []string{"row + shock", "sum(y)"}, []string{"y", "total"}, pipe,
+			WithParallel(par))
+		assert.Nil(t, e)
+		assert.Equal(t, 3*pipe.Rows(), out.Rows())
+
+		sc, e := out.GData().GetRaw("scenario")
+		assert.Nil(t, e)
+		assert.Equal(t, "up", sc.Data[pipe.Rows()])
+
+		y, e := out.GData().GetRaw("y")
+		assert.Nil(t, e)
+		assert.Equal(t, []any{2.0, 0.0}, []any{y.Data[pipe.Rows()], y.Data[2*pipe.Rows()]})
+
+		total, e := out.GData().GetRaw("total")
+		assert.Nil(t, e)
+		assert.Equal(t, []any{28.0, 35.0, 21.0}, []any{total.Data[0], total.Data[pipe.Rows()], total.Data[2*pipe.Rows()]})
+	}
+
+	// the pipeline is not changed
+	assert.Nil(t, pipe.Get("y"))
+
+	out, e := RunScenarios(scens, []string{"mean(row) * (1 + shock)"}, []string{"m"}, pipe, WithScenarioField("id"))
+	assert.Nil(t, e)
+	assert.Equal(t, 3, out.Rows())
+
+	m, e := out.GData().GetRaw("m")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{4.0, 8.0, 0.0}, m.Data)
+
+	_, e = RunScenarios(scens, []string{"row + nope"}, []string{"y"}, pipe)
+	assert.NotNil(t, e)
+
+	// names the scenario does not bind come from the Env of WithScenarioEval, which the scenario hides
+	base := NewEnv()
+	assert.Nil(t, base.Bind("nope", 10.0))
+	assert.Nil(t, base.Bind("shock", 100.0))
+
+	out, e = RunScenarios(scens, []string{"sum(row) + nope + shock"}, []string{"y"}, pipe,
+		WithScenarioEval(WithEnv(base)))
+	assert.Nil(t, e)
+
+	y, e := out.GData().GetRaw("y")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{38.0, 39.0, 37.0}, []any{y.Data[0], y.Data[pipe.Rows()], y.Data[2*pipe.Rows()]})
+
+	_, e = RunScenarios(scens, []string{"row"}, []string{"scenario"}, pipe)
+	assert.NotNil(t, e)
+
+	_, e = RunScenarios(scens, []string{"row", "row"}, []string{"y"}, pipe)
+	assert.NotNil(t, e)
+}
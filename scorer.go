@@ -0,0 +1,435 @@
+package seafan
+
+// scorer.go evaluates a saved NNModel without building a gorgonia graph
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scorer evaluates a model saved by NNModel.Save using plain float64 arithmetic.  Unlike PredictNN, it does not
+// build a gorgonia graph, so there is no batch size: any number of rows can be scored at once.  DropOut, InputDropout
+// and GaussianNoise layers are ignored.  Models with sequence layers are not supported.
+type Scorer struct {
+	construct ModSpec              // model spec
+	inputFT   FTypes               // FTypes of the inputs, in the order of the ModSpec
+	pp        *Preprocessor        // maps raw rows to inputs, using the FTypes supplied to NewScorer
+	params    map[string]*scoreMat // parameters by node name
+	outCols   int                  // columns in output
+	offset    string               // offset field (see ModSpec Offset)
+}
+
+// scoreMat is a row-major matrix
+type scoreMat struct {
+	rows, cols int
+	data       []float64
+}
+
+// NewScorer loads the model saved in <fileRoot>S.nn and <fileRoot>P.nn.  fts are the FTypes the model was built
+// with (see ChData SaveFTypes).  fts must include the inputs of the model and, for one-hot and embedded inputs, the
+// FRCat fields they are made from.
+func NewScorer(fileRoot string, fts FTypes) (*Scorer, error) {
+	modSpec, e := LoadModSpec(fileRoot + "S.nn")
+	if e != nil {
+		return nil, Wrapper(e, "NewScorer")
+	}
+
+	getFT := func(field string) *FType {
+		if ft := fts.Get(field); ft != nil {
+			return copyFType(ft, ft.Name)
+		}
+
+		return nil
+	}
+
+	inps, e := modSpec.inputs(getFT)
+	if e != nil {
+		return nil, Wrapper(e, "NewScorer")
+	}
+
+	data, e := loadParams(fileRoot + "P.nn")
+	if e != nil {
+		return nil, Wrapper(e, "NewScorer")
+	}
+
+	sc := &Scorer{construct: modSpec, inputFT: inps, pp: NewPreprocessor(fts), params: make(map[string]*scoreMat),
+		offset: modSpec.OffsetName()}
+
+	for _, d := range data {
+		if len(d.Dims) != 2 || d.Dims[0]*d.Dims[1] != len(d.Parms) {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("NewScorer: node %s has bad dimensions", d.Name))
+		}
+
+		sc.params[d.Name] = &scoreMat{rows: d.Dims[0], cols: d.Dims[1], data: d.Parms}
+	}
+
+	if e := sc.check(); e != nil {
+		return nil, Wrapper(e, "NewScorer")
+	}
+
+	return sc, nil
+}
+
+// newScorerNN returns a Scorer with the current parameters of m
+func newScorerNN(m *NNModel) (*Scorer, error) {
+	sc := &Scorer{construct: m.construct, inputFT: m.inputFT, pp: NewPreprocessor(m.inputFT), params: make(map[string]*scoreMat),
+		offset: m.construct.OffsetName()}
+
+	for _, n := range m.Params() {
+		shp := n.Shape()
+		sc.params[n.Name()] = &scoreMat{rows: shp[0], cols: shp[1], data: n.Value().Data().([]float64)}
+	}
+
+	if e := sc.check(); e != nil {
+		return nil, e
+	}
+
+	return sc, nil
+}
+
+// check checks the dimensions of the layers and sets outCols
+func (sc *Scorer) check() error {
+	if outs, e := sc.construct.Outputs(); e != nil || outs != nil {
+		return Wrapper(ErrNNModel, "Scorer does not support multi-output models")
+	}
+
+	// sequence layers may take the embedded inputs, so the columns below would not catch them
+	if nSeq, e := sc.construct.SeqLayers(); e != nil || nSeq > 0 {
+		return Wrapper(ErrNNModel, "Scorer does not support sequence layers")
+	}
+
+	cols := 0
+
+	for _, ft := range sc.inputFT {
+		switch ft.Role {
+		case FRCts:
+			cols++
+		case FROneHot:
+			cols += ft.Cats
+		case FREmbed, FRCat:
+			emb := sc.params[sc.construct.EmbedName(ft.Name)]
+			if emb == nil || emb.rows != ft.Cats {
+				return Wrapper(ErrNNModel, fmt.Sprintf("embedding for %s does not match FTypes", ft.Name))
+			}
+
+			cols += emb.cols
+		default:
+			return Wrapper(ErrNNModel, fmt.Sprintf("input %s has unsupported role", ft.Name))
+		}
+	}
+
+	for ind := 1; ind < len(sc.construct); ind++ {
+		fc := sc.construct.FC(ind)
+		if fc == nil {
+			continue
+		}
+
+		w := sc.params[fmt.Sprintf("lWeights%d", ind)]
+		if w == nil || w.rows != cols {
+			return Wrapper(ErrNNModel, fmt.Sprintf("layer %d weights do not match", ind))
+		}
+
+		cols = w.cols
+		if fc.Act == SoftMax {
+			cols++
+		}
+	}
+
+	sc.outCols = cols
+
+	return nil
+}
+
+// OutputCols returns the number of columns in the output of the model
+func (sc *Scorer) OutputCols() int {
+	return sc.outCols
+}
+
+// InputFT returns the FTypes of the inputs to the model
+func (sc *Scorer) InputFT() FTypes {
+	return sc.inputFT
+}
+
+// Score evaluates the model on all the rows of pipe.  The output is row-major with OutputCols columns per row (as
+// NNModel FitSlice).  The data in pipe must be on the same scale as the model build (see PredictNNwFts).
+func (sc *Scorer) Score(pipe Pipeline) ([]float64, error) {
+	data, e := sc.inputData(pipe)
+	if e != nil {
+		return nil, Wrapper(e, "Score")
+	}
+
+	out, e := sc.scoreData(pipe.Rows(), data)
+	if e != nil {
+		return nil, Wrapper(e, "Score")
+	}
+
+	return out, nil
+}
+
+// inputData returns the data of the inputs from pipe, in the order of inputFT.  If the model has an offset, it is
+// the last element.
+func (sc *Scorer) inputData(pipe Pipeline) ([][]float64, error) {
+	data := make([][]float64, len(sc.inputFT))
+
+	for ind, ft := range sc.inputFT {
+		d := pipe.Get(ft.Name)
+		if d == nil {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("input %s not in pipeline", ft.Name))
+		}
+
+		// an embedded FRCat input is scored as one-hot, which selects the row of the embedding of its code
+		if ft.Role == FRCat {
+			codes, ok := d.Data.([]int32)
+			if !ok || len(codes) != pipe.Rows() {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("input %s does not match the model", ft.Name))
+			}
+
+			x := make([]float64, len(codes)*ft.Cats)
+			for row, code := range codes {
+				if code < 0 || int(code) >= ft.Cats {
+					return nil, Wrapper(ErrNNModel, fmt.Sprintf("input %s has a level out of range", ft.Name))
+				}
+
+				x[row*ft.Cats+int(code)] = 1.0
+			}
+
+			data[ind] = x
+
+			continue
+		}
+
+		x := d.Floats()
+		if x == nil || len(x) != pipe.Rows()*sc.inCols(ft) {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("input %s does not match the model", ft.Name))
+		}
+
+		data[ind] = x
+	}
+
+	if sc.offset != "" {
+		d := pipe.Get(sc.offset)
+		if d == nil {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("offset %s not in pipeline", sc.offset))
+		}
+
+		x := d.Floats()
+		if x == nil || d.FT.Normalized {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("offset %s must be FRCts and not normalized", sc.offset))
+		}
+
+		data = append(data, x)
+	}
+
+	return data, nil
+}
+
+// scoreData evaluates the model on nRow rows of data, which is in the order of inputFT (see inputData)
+func (sc *Scorer) scoreData(nRow int, data [][]float64) ([]float64, error) {
+	get := func(ind, row int) ([]float64, error) {
+		if ind == len(sc.inputFT) {
+			return data[ind][row : row+1], nil
+		}
+
+		nCol := sc.inCols(sc.inputFT[ind])
+		return data[ind][row*nCol : (row+1)*nCol], nil
+	}
+
+	out, e := sc.forward(nRow, get)
+	if e != nil {
+		return nil, e
+	}
+
+	return out.data, nil
+}
+
+// ScoreRow evaluates the model on a single row.  The keys of row are field names and the values are in the units of
+// the raw data.  Continuous inputs are normalized using their FTypes. Terms (see AddTerm) and spline basis fields (see
+// Splines) not in row are calculated from the fields they are made from.  One-hot and embedded inputs are looked up
+// by the value of the field they are made from.  Levels that are not in the FType are mapped to FParam Default.
+// If the model has an offset, row must include it.
+func (sc *Scorer) ScoreRow(row map[string]any) ([]float64, error) {
+	get := func(ind, _ int) ([]float64, error) {
+		if ind == len(sc.inputFT) {
+			return sc.pp.cts(&FType{Name: sc.offset}, row)
+		}
+
+		return sc.pp.input(sc.inputFT[ind], row)
+	}
+
+	out, e := sc.forward(1, get)
+	if e != nil {
+		return nil, Wrapper(e, "ScoreRow")
+	}
+
+	return out.data, nil
+}
+
+// inCols returns the number of columns of input ft in the Pipeline.  An embedded FRCat input is one-hot (see
+// inputData).
+func (sc *Scorer) inCols(ft *FType) int {
+	if ft.Role == FRCts {
+		return 1
+	}
+
+	return ft.Cats
+}
+
+// forward evaluates the model on nRow rows. get returns input ind for a row.  Input len(inputFT) is the offset.
+func (sc *Scorer) forward(nRow int, get func(ind, row int) ([]float64, error)) (*scoreMat, error) {
+	// continuous and one-hot inputs come first, followed by embeddings (as in NewNNModel)
+	nCol := 0
+
+	for _, ft := range sc.inputFT {
+		switch ft.Role {
+		case FREmbed, FRCat:
+			nCol += sc.params[sc.construct.EmbedName(ft.Name)].cols
+		default:
+			nCol += sc.inCols(ft)
+		}
+	}
+
+	x := &scoreMat{rows: nRow, cols: nCol, data: make([]float64, nRow*nCol)}
+
+	for row := 0; row < nRow; row++ {
+		xRow := x.data[row*nCol : (row+1)*nCol]
+		col := 0
+
+		for _, embed := range []bool{false, true} {
+			for ind, ft := range sc.inputFT {
+				if (ft.Role == FREmbed || ft.Role == FRCat) != embed {
+					continue
+				}
+
+				inp, e := get(ind, row)
+				if e != nil {
+					return nil, e
+				}
+
+				if !embed {
+					col += copy(xRow[col:], inp)
+					continue
+				}
+
+				emb := sc.params[sc.construct.EmbedName(ft.Name)]
+				for k, v := range inp {
+					if v == 0.0 {
+						continue
+					}
+
+					for j := 0; j < emb.cols; j++ {
+						xRow[col+j] += v * emb.data[k*emb.cols+j]
+					}
+				}
+
+				col += emb.cols
+			}
+		}
+	}
+
+	lastFC := 0
+
+	for ind := 1; ind < len(sc.construct); ind++ {
+		if sc.construct.FC(ind) != nil {
+			lastFC = ind
+		}
+	}
+
+	for ind := 1; ind < len(sc.construct); ind++ {
+		fc := sc.construct.FC(ind)
+		if fc == nil {
+			continue
+		}
+
+		x = x.mul(sc.params[fmt.Sprintf("lWeights%d", ind)])
+
+		if b := sc.params[fmt.Sprintf("lBias%d", ind)]; b != nil {
+			for row := 0; row < x.rows; row++ {
+				for col := 0; col < x.cols; col++ {
+					x.data[row*x.cols+col] += b.data[col]
+				}
+			}
+		}
+
+		if ind == lastFC && sc.offset != "" {
+			for row := 0; row < x.rows; row++ {
+				off, e := get(len(sc.inputFT), row)
+				if e != nil {
+					return nil, e
+				}
+
+				for col := 0; col < x.cols; col++ {
+					x.data[row*x.cols+col] += off[0]
+				}
+			}
+		}
+
+		x = x.activate(fc.Act, fc.ActParm)
+	}
+
+	return x, nil
+}
+
+// mul returns the matrix product of x and y
+func (x *scoreMat) mul(y *scoreMat) *scoreMat {
+	out := &scoreMat{rows: x.rows, cols: y.cols, data: make([]float64, x.rows*y.cols)}
+
+	for row := 0; row < x.rows; row++ {
+		for k := 0; k < x.cols; k++ {
+			v := x.data[row*x.cols+k]
+			if v == 0.0 {
+				continue
+			}
+
+			for col := 0; col < y.cols; col++ {
+				out.data[row*y.cols+col] += v * y.data[k*y.cols+col]
+			}
+		}
+	}
+
+	return out
+}
+
+// activate applies the activation act to x
+func (x *scoreMat) activate(act Activation, parm float64) *scoreMat {
+	switch act {
+	case Relu, LeakyRelu:
+		alpha := 0.0
+		if act == LeakyRelu {
+			alpha = parm
+		}
+
+		for ind, v := range x.data {
+			if v < 0.0 {
+				x.data[ind] = alpha * v
+			}
+		}
+	case Sigmoid:
+		for ind, v := range x.data {
+			x.data[ind] = 1.0 / (1.0 + math.Exp(-v))
+		}
+	case SoftMax:
+		// the first cols-1 columns are exp(z)/(1+sum(exp(z))), the last is 1 minus the sum of the others (as SoftMaxAct)
+		out := &scoreMat{rows: x.rows, cols: x.cols + 1, data: make([]float64, x.rows*(x.cols+1))}
+
+		for row := 0; row < x.rows; row++ {
+			den := 1.0
+			for col := 0; col < x.cols; col++ {
+				den += math.Exp(x.data[row*x.cols+col])
+			}
+
+			last := 1.0
+
+			for col := 0; col < x.cols; col++ {
+				p := math.Exp(x.data[row*x.cols+col]) / den
+				out.data[row*out.cols+col] = p
+				last -= p
+			}
+
+			out.data[row*out.cols+x.cols] = last
+		}
+
+		return out
+	}
+
+	return x
+}
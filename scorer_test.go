@@ -0,0 +1,168 @@
+package seafan
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/invertedv/chutils"
+	"github.com/invertedv/chutils/file"
+	"github.com/stretchr/testify/assert"
+)
+
+// scorerPipe returns a pipeline of test1.csv with x4 as one-hot and x1, x2, x3 normalized
+func scorerPipe(t *testing.T, bSize int) *ChData {
+	f, e := os.Open(os.Getenv("data") + "/test1.csv")
+	assert.Nil(t, e)
+
+	rdr := file.NewReader("test1.csv", ',', '\n', 0, 0, 1, 0, f, 0)
+	assert.Nil(t, rdr.Init("", chutils.MergeTree))
+	assert.Nil(t, rdr.TableSpec().Impute(rdr, 0, .99))
+
+	pipe := NewChData("scorer", WithBatchSize(bSize), WithReader(rdr), WithCycle(true),
+		WithCats("y", "x4"),
+		WithOneHot("yoh", "y"),
+		WithOneHot("x4oh", "x4"),
+		WithNormalized("x1", "x2", "x3"))
+	assert.Nil(t, pipe.Init())
+
+	return pipe
+}
+
+func TestScorer_Score(t *testing.T) {
+	Verbose = false
+	pipe := scorerPipe(t, 100)
+
+	mod := ModSpec{
+		"Input(x1+x2+x3+E(x4oh,3))",
+		"FC(size:3, activation:relu)",
+		"DropOut(.1)",
+		"FC(size:2, activation:softmax)",
+		"Target(yoh)",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(CrossEntropy))
+	assert.Nil(t, e)
+
+	ft := NewFit(nn, 2, pipe)
+	assert.Nil(t, ft.Do())
+
+	root := ft.OutFile()
+	defer func() {
+		_ = os.Remove(root + "P.nn")
+		_ = os.Remove(root + "S.nn")
+	}()
+
+	sc, e := NewScorer(root, pipe.GetFTypes())
+	assert.Nil(t, e)
+	assert.Equal(t, 2, sc.OutputCols())
+
+	// compare to the gorgonia graph on a fresh pipeline so the rows are in file order
+	scorePipe := scorerPipe(t, 8500)
+
+	got, e := sc.Score(scorePipe)
+	assert.Nil(t, e)
+
+	pred, e := PredictNN(root, scorePipe, false)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pred.FitSlice(), got, 1e-10)
+
+	// first row of test1.csv
+	row := map[string]any{"x1": 0.47009787882515, "x2": 0.0336295029174111, "x3": 0.219553838861159, "x4": 0}
+	got, e = sc.ScoreRow(row)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pred.FitSlice()[0:2], got, 1e-10)
+
+	delete(row, "x1")
+	_, e = sc.ScoreRow(row)
+	assert.NotNil(t, e)
+}
+
+func TestScorer_EmbedCat(t *testing.T) {
+	Verbose = false
+
+	const n = 200
+
+	gd := synthData(t, 23, n, []string{"x"}, []string{"y", "orig"}, stateData)
+	x := gd.Get("x").Floats()
+	orig, e := gd.GetRaw("orig")
+	assert.Nil(t, e)
+
+	pipe := NewVecData("embed", gd, WithBatchSize(n))
+
+	// orig is embedded directly, not through a one-hot field
+	nn, e := NewNNModel(ModSpec{"Input(x+E(orig,2))", "FC(size:1)", "Target(y)"}, pipe, false)
+	assert.Nil(t, e)
+
+	root := os.TempDir() + "/scorerEmbedCat"
+	assert.Nil(t, nn.Save(root))
+	defer func() {
+		_ = os.Remove(root + "P.nn")
+		_ = os.Remove(root + "S.nn")
+	}()
+
+	sc, e := NewScorer(root, pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	got, e := sc.Score(pipe)
+	assert.Nil(t, e)
+
+	pred, e := PredictNN(root, pipe, false)
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pred.FitSlice(), got, 1e-10)
+
+	// the raw level selects its row of the embedding
+	got, e = sc.ScoreRow(map[string]any{"x": x[0], "orig": orig.Data[0]})
+	assert.Nil(t, e)
+	assert.InDeltaSlice(t, pred.FitSlice()[0:1], got, 1e-10)
+
+	_, e = sc.ScoreRow(map[string]any{"x": x[0], "orig": "ZZ"})
+	assert.NotNil(t, e)
+}
+
+func TestScorer_Ordinal(t *testing.T) {
+	Verbose = false
+
+	const n = 200
+
+	gd := synthData(t, 29, n, []string{"x"}, []string{"y", "lvl"}, func(x []float64, rnd *rand.Rand) []any {
+		lvl := float64(rnd.Intn(3))
+		return []any{x[0] + lvl, lvl}
+	})
+
+	x, grades := gd.Get("x").Floats(), []string{"low", "mid", "high"}
+	grade := make([]string, n)
+
+	for ind, lvl := range gd.Get("lvl").Floats() {
+		grade[ind] = grades[int(lvl)]
+	}
+
+	assert.Nil(t, gd.AppendOrdinal(NewRawCast(grade, nil), "grade", []any{"low", "mid", "high"}, false))
+	assert.Nil(t, gd.MakeOneHot("grade", "gradeOh"))
+
+	pipe := NewVecData("ordinal", gd, WithBatchSize(n))
+
+	nn, e := NewNNModel(ModSpec{"Input(x+gradeOh)", "FC(size:1)", "Target(y)"}, pipe, false)
+	assert.Nil(t, e)
+
+	root := os.TempDir() + "/scorerOrdinal"
+	assert.Nil(t, nn.Save(root))
+	defer func() {
+		_ = os.Remove(root + "P.nn")
+		_ = os.Remove(root + "S.nn")
+	}()
+
+	sc, e := NewScorer(root, pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	pred, e := PredictNN(root, pipe, false)
+	assert.Nil(t, e)
+
+	// the ordinal input has cumulative coding in both
+	for _, row := range []int{0, 1, 2, 3} {
+		got, e := sc.ScoreRow(map[string]any{"x": x[row], "grade": grade[row]})
+		assert.Nil(t, e)
+		assert.InDeltaSlice(t, pred.FitSlice()[row:row+1], got, 1e-10)
+		assert.Equal(t, grade[row], rawValue(pipe, "gradeOh", row))
+	}
+}
@@ -20,7 +20,12 @@
 //   - Numeric struct for (x,y) data and plotting and descriptive statistics.
 package seafan
 
-import "fmt"
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
 
 // Verbose controls amount of printing.
 var Verbose = true
@@ -28,6 +33,48 @@ var Verbose = true
 // Browser is the browser to use for plotting.
 var Browser = "firefox"
 
+// rng is the source of random numbers for the package: shuffling, sampling, weight initialization, dropouts and
+// temporary file names. It is seeded with the clock. Use SetSeed for reproducible results.
+var rng = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano()).(rand.Source64)})
+
+// SetSeed seeds the random number generator of the package.  Pipelines and Fits can have their own seeds
+// (see WithSeed and WithFitSeed).
+func SetSeed(seed int64) {
+	rng.Seed(seed)
+}
+
+// newRand returns a new *rand.Rand seeded with seed.
+func newRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// lockedSource is a rand.Source64 that is safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (ls *lockedSource) Int63() int64 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.src.Int63()
+}
+
+func (ls *lockedSource) Uint64() uint64 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.src.Uint64()
+}
+
+func (ls *lockedSource) Seed(seed int64) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.src.Seed(seed)
+}
+
 type SeaError int
 
 const (
@@ -40,6 +87,7 @@ const (
 	ErrNNModel
 	ErrDiags
 	ErrVecData
+	ErrParser
 )
 
 func (seaErr SeaError) Error() string {
@@ -62,6 +110,8 @@ func (seaErr SeaError) Error() string {
 		return "model diagnostics error"
 	case ErrVecData:
 		return "VecData error"
+	case ErrParser:
+		return "parser error"
 	}
 
 	return "error"
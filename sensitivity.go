@@ -0,0 +1,190 @@
+package seafan
+
+// sensitivity.go computes the derivatives of the model output with respect to its inputs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/invertedv/utilities"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// Sensitivity is the derivative of the model output with respect to a column of the model input, averaged over the
+// rows of a Pipeline.
+type Sensitivity struct {
+	Field   string  // Field is the input
+	Column  string  // Column is the level of a one-hot input or the embedding column of an embedded input
+	Rows    int     // Rows is the number of rows averaged over
+	Mean    float64 // Mean is the average derivative
+	MeanAbs float64 // MeanAbs is the average absolute derivative
+}
+
+// SensReport is the result of Sensitivities.  It is sorted by MeanAbs, largest first.
+type SensReport []*Sensitivity
+
+// String produces a table of the report
+func (sr SensReport) String() string {
+	const pad = 3
+
+	table := [][]string{{"Rank", "Field", "Column", "Mean", "Mean Abs"}}
+
+	for ind, s := range sr {
+		table = append(table, []string{fmt.Sprintf("%d", ind+1), s.Field, s.Column, fmt.Sprintf("%0.6f", s.Mean),
+			fmt.Sprintf("%0.6f", s.MeanAbs)})
+	}
+
+	return utilities.Pad(table, pad)
+}
+
+// Get returns the Sensitivity of column of field.  It returns nil if there is none.
+func (sr SensReport) Get(field, column string) *Sensitivity {
+	for _, s := range sr {
+		if s.Field == field && s.Column == column {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Sensitivities returns the derivatives of the output of nn with respect to each column of its FRCts, one-hot and
+// embedded inputs, averaged over the rows of pipe.  The derivatives of an embedded input are with respect to the
+// columns of its embedding.  Sequence inputs are not included.
+//
+// The output is the sum of the target columns.  If target is omitted, all the output columns are summed--for a softmax
+// output this sum is 1, so a target should be given.
+//
+// The derivatives are found by back-propagation, so only one pass through pipe is needed.  Sensitivities reads a full
+// epoch of pipe.  Rows beyond the last full batch are not included.  The derivatives of FRCts inputs are with respect
+// to the inputs as fed to the model, so they are on the normalized scale if the input is normalized.
+func Sensitivities(nn *NNModel, pipe Pipeline, target ...int) (SensReport, error) {
+	// the model is rebuilt so that the gradient nodes aren't added to the graph of nn
+	sm, e := NewNNModel(nn.construct, pipe, false)
+	if e != nil {
+		return nil, Wrapper(e, "Sensitivities")
+	}
+
+	params, smParams := nn.Params(), sm.Params()
+	if len(params) != len(smParams) {
+		return nil, Wrapper(ErrNNModel, "Sensitivities: model and pipeline don't match")
+	}
+
+	for ind, p := range params {
+		copy(smParams[ind].Value().Data().([]float64), p.Value().Data().([]float64))
+	}
+
+	out := sm.output.Nodes()[0]
+	cols := out.Shape()[1]
+
+	if target == nil {
+		for col := 0; col < cols; col++ {
+			target = append(target, col)
+		}
+	}
+
+	sel := make([]float64, cols)
+
+	for _, col := range target {
+		if col < 0 || col >= cols {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("Sensitivities: target column %d out of range", col))
+		}
+
+		sel[col] = 1.0
+	}
+
+	selN := G.NewTensor(sm.g, tensor.Float64, 2, G.WithName("sensTarget"), G.WithShape(cols, 1),
+		G.WithValue(tensor.New(tensor.WithShape(cols, 1), tensor.WithBacking(sel))))
+	tot := G.Must(G.Sum(G.Must(G.Mul(out, selN))))
+
+	// the inputs and their labels
+	wrt := append(append(G.Nodes{}, sm.inputsC...), sm.embOut...)
+	fields, columns := sensLabels(sm)
+
+	grads, e := G.Grad(tot, wrt...)
+	if e != nil {
+		return nil, Wrapper(e, "Sensitivities")
+	}
+
+	// intermediate nodes don't hold their values after a run
+	gradVals := make([]G.Value, len(grads))
+	for ind, g := range grads {
+		G.Read(g, &gradVals[ind])
+	}
+
+	vm := G.NewTapeMachine(sm.g)
+
+	defer func() { _ = vm.Close() }()
+
+	sum, sumAbs := make([]float64, len(fields)), make([]float64, len(fields))
+	rows := 0
+
+	for pipe.Batch(sm.Inputs()) {
+		if e := vm.RunAll(); e != nil {
+			return nil, Wrapper(e, "Sensitivities")
+		}
+
+		col := 0
+
+		for ind := range grads {
+			gv := gradVals[ind].Data().([]float64)
+			nCol := wrt[ind].Shape()[1]
+
+			for k, g := range gv {
+				sum[col+k%nCol] += g
+				sumAbs[col+k%nCol] += math.Abs(g)
+			}
+
+			col += nCol
+		}
+
+		rows += out.Shape()[0]
+
+		vm.Reset()
+	}
+
+	if rows == 0 {
+		return nil, Wrapper(ErrNNModel, "Sensitivities: no rows in pipeline")
+	}
+
+	report := make(SensReport, len(fields))
+	for ind := range fields {
+		report[ind] = &Sensitivity{Field: fields[ind], Column: columns[ind], Rows: rows,
+			Mean: sum[ind] / float64(rows), MeanAbs: sumAbs[ind] / float64(rows)}
+	}
+
+	sort.SliceStable(report, func(i, j int) bool { return report[i].MeanAbs > report[j].MeanAbs })
+
+	return report, nil
+}
+
+// sensLabels returns the field and column of each column of the continuous inputs followed by the embeddings of m
+func sensLabels(m *NNModel) (fields, columns []string) {
+	var embFields, embColumns []string
+
+	for _, ft := range m.inputFT {
+		switch ft.Role {
+		case FRCts:
+			fields, columns = append(fields, ft.Name), append(columns, "")
+		case FROneHot:
+			for col := 0; col < ft.Cats; col++ {
+				level := fmt.Sprintf("%d", col)
+				if ft.FP != nil && ft.FP.Lvl != nil {
+					if val := ft.FP.Lvl.FindValue(int32(col)); val != nil {
+						level = fmt.Sprintf("%v", val)
+					}
+				}
+
+				fields, columns = append(fields, ft.Name), append(columns, level)
+			}
+		case FREmbed, FRCat:
+			for col := 0; col < ft.EmbCols; col++ {
+				embFields, embColumns = append(embFields, ft.Name), append(embColumns, fmt.Sprintf("embed %d", col))
+			}
+		}
+	}
+
+	return append(fields, embFields...), append(columns, embColumns...)
+}
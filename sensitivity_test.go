@@ -0,0 +1,43 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensitivities(t *testing.T) {
+	Verbose = false
+	pipe := chPipe(100, "test1.csv")
+
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	report, e := Sensitivities(nn, pipe)
+	assert.Nil(t, e)
+	assert.Equal(t, 4, len(report))
+
+	// the model is linear, so the derivatives are the weights
+	wts := nn.paramsW[0].Value().Data().([]float64)
+	for ind, field := range []string{"x1", "x2", "x3", "x4"} {
+		s := report.Get(field, "")
+		assert.NotNil(t, s)
+		assert.InDelta(t, wts[ind], s.Mean, 1e-10)
+		assert.InDelta(t, wts[ind]*wts[ind], s.MeanAbs*s.MeanAbs, 1e-10)
+	}
+
+	for ind := 1; ind < len(report); ind++ {
+		assert.GreaterOrEqual(t, report[ind-1].MeanAbs, report[ind].MeanAbs)
+	}
+
+	assert.Contains(t, report.String(), "Mean Abs")
+
+	_, e = Sensitivities(nn, pipe, 1)
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,311 @@
+package seafan
+
+// seqlayer.go implements the sequence layers of NNModel: GRU, LSTM, Conv1D, MaxPool, AvgPool and Attention
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// gates returns the number of gates of a recurrent cell
+func gates(cell Layer) int {
+	if cell == LSTM {
+		return 4
+	}
+
+	return 3
+}
+
+// newSequence creates the parameters of the sequence layers of modSpec.  The input to the first layer has steps
+// steps and chans channels, steps is 0 if there is no input.  It returns the columns of the output of the last layer.  Recurrent layer i has input weights lWeights<i>, recurrent weights
+// rWeights<i> and bias lBias<i>.  The gates are side-by-side in the columns: update, reset and candidate (GRU) or
+// input, forget, cell and output (LSTM).  Conv1D layer i has weights lWeights<i> with a row for each step of the
+// kernel and channel of the input and bias lBias<i>.  Attention layer i has weights lWeights<i> with the queries,
+// keys and values of the heads side-by-side in the columns and output weights oWeights<i>.
+func newSequence(modSpec ModSpec, steps, chans int, g *G.ExprGraph) (parW, parB G.Nodes, cols int, err error) {
+	nSeq, e := modSpec.SeqLayers()
+	if e != nil {
+		return nil, nil, 0, e
+	}
+
+	parW, parB = make(G.Nodes, 0), make(G.Nodes, 0)
+
+	switch {
+	case nSeq == 0 && steps > 0:
+		return nil, nil, 0, Wrapper(ErrNNModel, "NewNNModel: sequence inputs require a sequence layer")
+	case nSeq > 0 && steps == 0:
+		return nil, nil, 0, Wrapper(ErrNNModel, "NewNNModel: sequence layers require sequence or embedded inputs")
+	case nSeq == 0:
+		return parW, parB, 0, nil
+	}
+
+	recurrent := false
+
+	for ind := 1; ind <= nSeq; ind++ {
+		loc := strconv.Itoa(ind)
+		recurrent = false
+
+		switch {
+		case modSpec.RNN(ind) != nil:
+			rnn := modSpec.RNN(ind)
+			k := gates(rnn.Cell) * rnn.Size
+
+			parW = append(parW,
+				G.NewTensor(g, tensor.Float64, 2, G.WithName("lWeights"+loc), G.WithShape(chans, k), G.WithInit(glorotN(1.0))),
+				G.NewTensor(g, tensor.Float64, 2, G.WithName("rWeights"+loc), G.WithShape(rnn.Size, k), G.WithInit(glorotN(1.0))))
+			parB = append(parB,
+				G.NewTensor(g, tensor.Float64, 2, G.WithName("lBias"+loc), G.WithShape(1, k), G.WithInit(glorotN(1.0))))
+
+			chans, recurrent = rnn.Size, true
+		case modSpec.Conv(ind) != nil:
+			conv := modSpec.Conv(ind)
+			if steps -= conv.Kernel - 1; steps < 1 {
+				return nil, nil, 0, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: Conv1D layer %d kernel is longer than its input", ind))
+			}
+
+			parW = append(parW, G.NewTensor(g, tensor.Float64, 2, G.WithName("lWeights"+loc),
+				G.WithShape(conv.Kernel*chans, conv.Filters), G.WithInit(glorotN(1.0))))
+			parB = append(parB, G.NewTensor(g, tensor.Float64, 2, G.WithName("lBias"+loc),
+				G.WithShape(1, conv.Filters), G.WithInit(glorotN(1.0))))
+
+			chans = conv.Filters
+		case modSpec.Attn(ind) != nil:
+			attn := modSpec.Attn(ind)
+			hd := attn.Heads * attn.Dim
+
+			parW = append(parW,
+				G.NewTensor(g, tensor.Float64, 2, G.WithName("lWeights"+loc), G.WithShape(chans, 3*hd), G.WithInit(glorotN(1.0))),
+				G.NewTensor(g, tensor.Float64, 2, G.WithName("oWeights"+loc), G.WithShape(hd, chans), G.WithInit(glorotN(1.0))))
+		default:
+			if steps /= modSpec.Pool(ind).Size; steps < 1 {
+				return nil, nil, 0, Wrapper(ErrNNModel, fmt.Sprintf("NewNNModel: pooling layer %d is longer than its input", ind))
+			}
+		}
+	}
+
+	if recurrent {
+		return parW, parB, chans, nil
+	}
+
+	return parW, parB, steps * chans, nil
+}
+
+// sequence runs the sequence inputs, or the embeddings if there are none, through the sequence layers and returns the
+// output of the last layer
+func (m *NNModel) sequence() *G.Node {
+	seq := append(G.Nodes{}, m.embOut...)
+
+	if !m.embSeq {
+		x := m.inputsS[0]
+		if len(m.inputsS) > 1 {
+			x = G.Must(G.Concat(2, m.inputsS...))
+		}
+
+		seq = make(G.Nodes, x.Shape()[1])
+		for step := range seq {
+			seq[step] = G.Must(G.Slice(x, nil, G.S(step)))
+		}
+	}
+
+	nSeq, e := m.construct.SeqLayers()
+	if e != nil {
+		panic(e)
+	}
+
+	recurrent := false
+
+	for ind := 1; ind <= nSeq; ind++ {
+		recurrent = false
+
+		switch {
+		case m.construct.RNN(ind) != nil:
+			seq, recurrent = m.rnnLayer(seq, ind, m.construct.RNN(ind).Cell), true
+		case m.construct.Conv(ind) != nil:
+			seq = m.convLayer(seq, ind, m.construct.Conv(ind))
+		case m.construct.Attn(ind) != nil:
+			seq = m.attnLayer(seq, ind, m.construct.Attn(ind))
+		default:
+			seq = poolLayer(seq, m.construct.Pool(ind))
+		}
+	}
+
+	if recurrent {
+		return seq[len(seq)-1]
+	}
+
+	if len(seq) == 1 {
+		return seq[0]
+	}
+
+	return G.Must(G.Concat(1, seq...))
+}
+
+// convLayer applies the Conv1D layer ind to the sequence seq
+func (m *NNModel) convLayer(seq G.Nodes, ind int, conv *ConvLayer) G.Nodes {
+	w := GetNode(m.paramsW, "lWeights"+strconv.Itoa(ind))
+	b := GetNode(m.paramsB, "lBias"+strconv.Itoa(ind))
+
+	out := make(G.Nodes, len(seq)-conv.Kernel+1)
+
+	for step := range out {
+		window := seq[step]
+		if conv.Kernel > 1 {
+			window = G.Must(G.Concat(1, seq[step:step+conv.Kernel]...))
+		}
+
+		z := G.Must(G.BroadcastAdd(G.Must(G.Mul(window, w)), b, nil, []byte{0}))
+
+		switch conv.Act {
+		case Relu:
+			z = ReluAct(z)
+		case LeakyRelu:
+			z = LeakyReluAct(z, conv.ActParm)
+		case Sigmoid:
+			z = SigmoidAct(z)
+		}
+
+		out[step] = z
+	}
+
+	return out
+}
+
+// attnLayer applies the Attention layer ind to the sequence seq.  Each step attends to all the steps.
+func (m *NNModel) attnLayer(seq G.Nodes, ind int, attn *AttnLayer) G.Nodes {
+	w := GetNode(m.paramsW, "lWeights"+strconv.Itoa(ind))
+	wo := GetNode(m.paramsW, "oWeights"+strconv.Itoa(ind))
+
+	rows, steps, chans := seq[0].Shape()[0], len(seq), seq[0].Shape()[1]
+
+	// stack the steps into (rows*steps, chans)
+	stack := make(G.Nodes, steps)
+	for step, x := range seq {
+		stack[step] = G.Must(G.Reshape(x, tensor.Shape{rows, 1, chans}))
+	}
+
+	x := stack[0]
+	if steps > 1 {
+		x = G.Must(G.Concat(1, stack...))
+	}
+
+	x = G.Must(G.Reshape(x, tensor.Shape{rows * steps, chans}))
+	qkv := G.Must(G.Mul(x, w))
+
+	// part returns the queries (k=0), keys (k=1) or values (k=2) of head h as (rows, steps, dim)
+	part := func(k, h int) *G.Node {
+		start := (k*attn.Heads + h) * attn.Dim
+		p := G.Must(G.Slice(qkv, nil, G.S(start, start+attn.Dim)))
+
+		return G.Must(G.Reshape(p, tensor.Shape{rows, steps, attn.Dim}))
+	}
+
+	scale := G.NewConstant(1.0 / math.Sqrt(float64(attn.Dim)))
+	heads := make(G.Nodes, attn.Heads)
+
+	for h := 0; h < attn.Heads; h++ {
+		scores := G.Must(G.Mul(G.Must(G.BatchedMatMul(part(0, h), part(1, h), false, true)), scale))
+
+		// softmax across the steps attended to
+		exp := G.Must(G.Exp(scores))
+		wts := G.Must(G.BroadcastHadamardDiv(exp, G.Must(G.Sum(exp, 2)), nil, []byte{2}))
+		heads[h] = G.Must(G.BatchedMatMul(wts, part(2, h)))
+	}
+
+	ctx := heads[0]
+	if len(heads) > 1 {
+		ctx = G.Must(G.Concat(2, heads...))
+	}
+
+	ctx = G.Must(G.Reshape(ctx, tensor.Shape{rows * steps, attn.Heads * attn.Dim}))
+	out := G.Must(G.Reshape(G.Must(G.Add(x, G.Must(G.Mul(ctx, wo)))), tensor.Shape{rows, steps, chans}))
+
+	outSeq := make(G.Nodes, steps)
+	for step := range outSeq {
+		outSeq[step] = G.Must(G.Slice(out, nil, G.S(step)))
+	}
+
+	return outSeq
+}
+
+// poolLayer pools seq over non-overlapping windows
+func poolLayer(seq G.Nodes, pool *PoolLayer) G.Nodes {
+	out := make(G.Nodes, len(seq)/pool.Size)
+
+	for step := range out {
+		window := seq[step*pool.Size : (step+1)*pool.Size]
+
+		switch pool.Pool {
+		case MaxPool:
+			rows, cols := window[0].Shape()[0], window[0].Shape()[1]
+			stack := make(G.Nodes, len(window))
+
+			for ind, x := range window {
+				stack[ind] = G.Must(G.Reshape(x, tensor.Shape{rows, cols, 1}))
+			}
+
+			out[step] = G.Must(G.Max(G.Must(G.Concat(2, stack...)), 2))
+		case AvgPool:
+			sum := window[0]
+			for _, x := range window[1:] {
+				sum = G.Must(G.Add(sum, x))
+			}
+
+			out[step] = G.Must(G.Mul(sum, G.NewConstant(1.0/float64(pool.Size))))
+		}
+	}
+
+	return out
+}
+
+// rnnLayer applies the recurrent layer ind to the sequence seq and returns the hidden state at each step.  The initial
+// state is 0.
+func (m *NNModel) rnnLayer(seq G.Nodes, ind int, cell Layer) G.Nodes {
+	w := GetNode(m.paramsW, "lWeights"+strconv.Itoa(ind))
+	u := GetNode(m.paramsW, "rWeights"+strconv.Itoa(ind))
+	b := GetNode(m.paramsB, "lBias"+strconv.Itoa(ind))
+
+	rows, size := seq[0].Shape()[0], u.Shape()[0]
+
+	// gate k of the columns of z
+	gate := func(z *G.Node, k int) *G.Node {
+		return G.Must(G.Slice(z, nil, G.S(k*size, (k+1)*size)))
+	}
+
+	zeros := func(name string) *G.Node {
+		return G.NewTensor(m.g, tensor.Float64, 2, G.WithName(fmt.Sprintf("%s%d", name, ind)), G.WithShape(rows, size),
+			G.WithValue(tensor.New(tensor.WithShape(rows, size), tensor.WithBacking(make([]float64, rows*size)))))
+	}
+
+	h, c := zeros("h0_"), zeros("c0_")
+	out := make(G.Nodes, len(seq))
+
+	for step, x := range seq {
+		zx := G.Must(G.BroadcastAdd(G.Must(G.Mul(x, w)), b, nil, []byte{0}))
+		zh := G.Must(G.Mul(h, u))
+
+		switch cell {
+		case GRU:
+			update := SigmoidAct(G.Must(G.Add(gate(zx, 0), gate(zh, 0))))
+			reset := SigmoidAct(G.Must(G.Add(gate(zx, 1), gate(zh, 1))))
+			cand := G.Must(G.Tanh(G.Must(G.Add(gate(zx, 2), G.Must(G.HadamardProd(reset, gate(zh, 2)))))))
+
+			// h = (1-update)*cand + update*h
+			h = G.Must(G.Add(cand, G.Must(G.HadamardProd(update, G.Must(G.Sub(h, cand))))))
+		case LSTM:
+			z := G.Must(G.Add(zx, zh))
+			inp, forget := SigmoidAct(gate(z, 0)), SigmoidAct(gate(z, 1))
+			cand, output := G.Must(G.Tanh(gate(z, 2))), SigmoidAct(gate(z, 3))
+
+			c = G.Must(G.Add(G.Must(G.HadamardProd(forget, c)), G.Must(G.HadamardProd(inp, cand))))
+			h = G.Must(G.HadamardProd(output, G.Must(G.Tanh(c))))
+		}
+
+		out[step] = h
+	}
+
+	return out
+}
@@ -0,0 +1,206 @@
+package seafan
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestSeqLayers(t *testing.T) {
+	rnn, e := RNNParse("GRU(8)")
+	assert.Nil(t, e)
+	assert.Equal(t, &RNNLayer{Cell: GRU, Size: 8}, rnn)
+
+	rnn, e = RNNParse("lstm(size:4)")
+	assert.Nil(t, e)
+	assert.Equal(t, &RNNLayer{Cell: LSTM, Size: 4}, rnn)
+
+	_, e = RNNParse("GRU(0)")
+	assert.NotNil(t, e)
+
+	mod := ModSpec{"Input(x+seq)", "Conv1D(4, 2)", "GRU(4)", "LSTM(3)", "FC(size:1)", "Target(y)"}
+	n, e := mod.SeqLayers()
+	assert.Nil(t, e)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, 3, mod.RNN(3).Size)
+	assert.Nil(t, mod.RNN(4))
+
+	_, e = ModSpec{"Input(seq)", "FC(size:2)", "GRU(4)", "FC(size:1)", "Target(y)"}.SeqLayers()
+	assert.NotNil(t, e)
+}
+
+func TestSeqLayerParse(t *testing.T) {
+	conv, e := ConvParse("Conv1D(8, 3, relu)")
+	assert.Nil(t, e)
+	assert.Equal(t, &ConvLayer{Filters: 8, Kernel: 3, Act: Relu}, conv)
+
+	conv, e = ConvParse("Conv1D(filters:4, kernel:2, activation:leakyrelu(0.1))")
+	assert.Nil(t, e)
+	assert.Equal(t, &ConvLayer{Filters: 4, Kernel: 2, Act: LeakyRelu, ActParm: 0.1}, conv)
+
+	for _, bad := range []string{"Conv1D(4)", "Conv1D(4, 0)", "Conv1D(4, 2, softmax)", "Conv1D(4, 2, relu, 1)"} {
+		_, e = ConvParse(bad)
+		assert.NotNil(t, e, bad)
+	}
+
+	pool, e := PoolParse("AvgPool(size:3)")
+	assert.Nil(t, e)
+	assert.Equal(t, &PoolLayer{Pool: AvgPool, Size: 3}, pool)
+
+	_, e = PoolParse("MaxPool(0)")
+	assert.NotNil(t, e)
+
+	attn, e := AttnParse("Attention(2, 4)")
+	assert.Nil(t, e)
+	assert.Equal(t, &AttnLayer{Heads: 2, Dim: 4}, attn)
+
+	attn, e = AttnParse("attention(dim:3, heads:1)")
+	assert.Nil(t, e)
+	assert.Equal(t, &AttnLayer{Heads: 1, Dim: 3}, attn)
+
+	_, e = AttnParse("Attention(2)")
+	assert.NotNil(t, e)
+}
+
+func TestNNModel_Sequence(t *testing.T) {
+	Verbose = false
+
+	// y is x two months back
+	const (
+		ids    = 200
+		months = 10
+	)
+
+	rnd := newRand(29)
+	id, month, x, y := make([]any, 0), make([]any, 0), make([]any, 0), make([]any, 0)
+
+	for ent := 0; ent < ids; ent++ {
+		hist := make([]float64, months)
+		for mon := 0; mon < months; mon++ {
+			hist[mon] = rnd.NormFloat64()
+			id, month, x = append(id, int32(ent)), append(month, float64(mon)), append(x, hist[mon])
+
+			lag := 0.0
+			if mon >= 2 {
+				lag = hist[mon-2]
+			}
+
+			y = append(y, lag)
+		}
+	}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw(id, nil), "id", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(month, nil), "month", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(x, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(y, nil), "y", false, nil, false))
+	assert.Nil(t, gd.MakeSequence("seq", "id", "month", 3, "x"))
+
+	for _, cell := range []string{"GRU(6)", "LSTM(6)", "Conv1D(4, 2, leakyrelu(0.1))", "Attention(2, 3)"} {
+		SetSeed(29)
+		pipe := NewVecData("recurrent", gd, WithBatchSize(100))
+
+		nn, e := NewNNModel(ModSpec{"Input(month+seq)", cell, "FC(size:1)", "Target(y)"}, pipe, true, WithCostFn(RMS))
+		assert.Nil(t, e)
+		assert.Equal(t, 2, len(nn.Features()))
+
+		ft := fitModel(t, nn, 40, pipe, WithLearnRate(0.02, 0.002), WithFitSeed(29))
+
+		pipe = NewVecData("recurrent", gd, WithBatchSize(ids*months))
+		pred, e := PredictNN(ft.OutFile(), pipe, false)
+		assert.Nil(t, e)
+
+		yObs := make([]float64, len(y))
+		for ind, v := range y {
+			yObs[ind] = v.(float64)
+		}
+
+		assert.Greater(t, stat.Correlation(pred.FitSlice(), yObs, nil), 0.9, cell)
+	}
+
+	pipe := NewVecData("recurrent", gd, WithBatchSize(100))
+
+	// the model can have only sequence inputs
+	nn, e := NewNNModel(ModSpec{"Input(seq)", "Conv1D(4, 2)", "MaxPool(2)", "FC(size:1)", "Target(y)"}, pipe, true,
+		WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.Contains(t, nn.String(), "12 Sequence parameters\n5 FC parameters")
+
+	ft := fitModel(t, nn, 2, pipe, WithFitSeed(29))
+
+	nnLoad, e := LoadNN(ft.OutFile(), pipe, false)
+	assert.Nil(t, e)
+	assert.Equal(t, ft.NNModel().Params()[0].Value().Data(), nnLoad.Params()[0].Value().Data())
+
+	nn, e = NewNNModel(ModSpec{"Input(seq)", "Attention(heads:2, dim:3)", "AvgPool(3)", "FC(size:1)", "Target(y)"},
+		pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.Contains(t, nn.String(), "24 Sequence parameters\n2 FC parameters")
+	assert.Nil(t, NewFit(nn, 1, pipe, WithFitSeed(29), WithOutFile(ft.OutFile())).Do())
+
+	// the kernel can't be longer than the sequence
+	_, e = NewNNModel(ModSpec{"Input(seq)", "Conv1D(4, 4)", "FC(size:1)", "Target(y)"}, pipe, true)
+	assert.NotNil(t, e)
+
+	// sequence inputs need a sequence layer and vice versa
+	_, e = NewNNModel(ModSpec{"Input(x+seq)", "FC(size:1)", "Target(y)"}, pipe, true)
+	assert.NotNil(t, e)
+
+	_, e = NewNNModel(ModSpec{"Input(x)", "GRU(4)", "FC(size:1)", "Target(y)"}, pipe, true)
+	assert.NotNil(t, e)
+
+}
+
+func TestAttention_Embed(t *testing.T) {
+	Verbose = false
+	lvls := []string{"a", "b", "c"}
+
+	// y depends on whether the categories agree, which attention across the embeddings can pick up
+	gd := synthData(t, 37, 1000, []string{"x", "u1", "u2"}, []string{"y", "c1", "c2"},
+		func(x []float64, rnd *rand.Rand) []any {
+			c1, c2 := int(3*x[1]), int(3*x[2])
+
+			y := x[0] + 0.1*rnd.NormFloat64()
+			if c1 == c2 {
+				y++
+			}
+
+			return []any{y, lvls[c1], lvls[c2]}
+		})
+
+	pipe := NewVecData("attention", gd, WithBatchSize(100))
+
+	// without sequence inputs, the embeddings are the steps
+	mod := ModSpec{"Input(x+E(c1,3)+E(c2,3))", "Attention(2, 3)", "FC(size:1)", "Target(y)"}
+	assert.Nil(t, mod.Validate(pipe))
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+	assert.Contains(t, nn.String(), "72 Sequence parameters\n8 FC parameters")
+	assert.Contains(t, nn.dot(), `"c1Embed" -> "layer1"`)
+	assert.NotContains(t, nn.dot(), `"c1Embed" -> "concat"`)
+
+	ft := fitModel(t, nn, 2, pipe, WithFitSeed(37))
+
+	nnLoad, e := LoadNN(ft.OutFile(), pipe, false)
+	assert.Nil(t, e)
+	assert.Equal(t, ft.NNModel().Params()[0].Value().Data(), nnLoad.Params()[0].Value().Data())
+
+	_, e = NewScorer(ft.OutFile(), pipe.GetFTypes())
+	assert.NotNil(t, e)
+
+	bad := []ModSpec{
+		{"Input(x+E(c1,3)+E(c2,2))", "Attention(2, 3)", "FC(size:1)", "Target(y)"},
+		{"Input(g1:(x+E(c1,3)), g2:(E(c2,3)))", "Attention(2, 3)", "FC(size:1)", "Target(y)"},
+		{"Input(x)", "Attention(2, 3)", "FC(size:1)", "Target(y)"},
+	}
+
+	for _, mod := range bad {
+		assert.NotNil(t, mod.Validate(pipe), mod)
+
+		_, e := NewNNModel(mod, pipe, true)
+		assert.NotNil(t, e, mod)
+	}
+}
@@ -0,0 +1,188 @@
+package seafan
+
+// sequence.go builds sequence (FRSeq) fields from panel data for recurrent layers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/invertedv/utilities"
+)
+
+// MakeSequence adds the FRSeq field name to gd.  gd is a panel with one row per entity (e.g. loan) and period
+// (e.g. month).  For each row, the field holds the values of the FRCts fields over the steps most recent periods
+// of the row's entity, up to and including the row, oldest first.  Periods before the entity's first row are 0.
+// The periods of an entity are ordered by the field order.
+//
+// Batch returns the field as a tensor of shape (batch size, steps, len(fields)), which is the input to the GRU and
+// LSTM layers of an NNModel. Since normalized fields are used as normalized, the 0 padding is the mean of the field.
+func (gd *GData) MakeSequence(name, entity, order string, steps int, fields ...string) error {
+	if e := gd.check(name); e != nil {
+		return e
+	}
+
+	if steps < 1 {
+		return Wrapper(ErrGData, fmt.Sprintf("MakeSequence: steps must be positive, got %d", steps))
+	}
+
+	if len(fields) == 0 {
+		return Wrapper(ErrGData, "MakeSequence: no fields")
+	}
+
+	xs := make([][]float64, len(fields))
+
+	for ind, fld := range fields {
+		d := gd.Get(fld)
+		if d == nil {
+			return Wrapper(ErrGData, fmt.Sprintf("MakeSequence: field %s not found", fld))
+		}
+
+		if d.FT.Role != FRCts {
+			return Wrapper(ErrGData, fmt.Sprintf("MakeSequence: field %s is not continuous", fld))
+		}
+
+		xs[ind] = d.Floats()
+	}
+
+	hist, e := gd.histories(entity, order)
+	if e != nil {
+		return Wrapper(e, "MakeSequence")
+	}
+
+	nFeat := len(fields)
+	cols := steps * nFeat
+	seq := make([]float64, gd.rows*cols)
+
+	for _, rows := range hist {
+		for pos, row := range rows {
+			for step := 0; step < steps; step++ {
+				// position in the history of the row at this step
+				src := pos - steps + 1 + step
+				if src < 0 {
+					continue
+				}
+
+				for f := 0; f < nFeat; f++ {
+					seq[row*cols+step*nFeat+f] = xs[f][rows[src]]
+				}
+			}
+		}
+	}
+
+	ft := &FType{
+		Name: name,
+		Role: FRSeq,
+		Cats: cols,
+		From: strings.Join(fields, ","),
+	}
+
+	gd.data = append(gd.data, &GDatum{Data: seq, FT: ft, Summary: Summary{NRows: gd.rows}})
+
+	return gd.check("")
+}
+
+// histories returns the rows of each value of entity sorted by order
+func (gd *GData) histories(entity, order string) ([][]int, error) {
+	ent, e := gd.GetRaw(entity)
+	if e != nil {
+		return nil, e
+	}
+
+	ord, e := gd.GetRaw(order)
+	if e != nil {
+		return nil, e
+	}
+
+	var hist [][]int
+
+	entInd := make(map[any]int)
+
+	for row := 0; row < gd.rows; row++ {
+		ind, ok := entInd[ent.Data[row]]
+		if !ok {
+			ind = len(hist)
+			entInd[ent.Data[row]] = ind
+			hist = append(hist, nil)
+		}
+
+		hist[ind] = append(hist[ind], row)
+	}
+
+	var err error
+
+	for _, rows := range hist {
+		sort.SliceStable(rows, func(i, j int) bool {
+			less, e := utilities.GTAny(ord.Data[rows[j]], ord.Data[rows[i]])
+			if e != nil {
+				err = e
+			}
+
+			return less
+		})
+	}
+
+	if err != nil {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("cannot order by field %s: %v", order, err))
+	}
+
+	return hist, nil
+}
+
+// appendSeq appends copies of the FRSeq fields of src restricted to rows. If rows is nil, all rows are copied.
+func (gd *GData) appendSeq(src *GData, rows []int) error {
+	if rows == nil {
+		rows = make([]int, src.rows)
+		for ind := range rows {
+			rows[ind] = ind
+		}
+	}
+
+	for _, d := range src.data {
+		if d.FT.Role != FRSeq {
+			continue
+		}
+
+		cols := d.FT.Cats
+		x := d.Data.([]float64)
+		seq := make([]float64, 0, len(rows)*cols)
+
+		for _, row := range rows {
+			if row < 0 || row >= src.rows {
+				return fmt.Errorf("index out of range: %d to array of length %d", row, src.rows)
+			}
+
+			seq = append(seq, x[row*cols:(row+1)*cols]...)
+		}
+
+		ft := *d.FT
+		gd.data = append(gd.data, &GDatum{Data: seq, FT: &ft, Summary: Summary{NRows: len(rows)}})
+		gd.rows = len(rows)
+	}
+
+	return gd.check("")
+}
+
+// stackSeq appends the FRSeq fields of top with the rows of the same field of bottom below them
+func (gd *GData) stackSeq(top, bottom *GData) error {
+	for _, d := range top.data {
+		if d.FT.Role != FRSeq {
+			continue
+		}
+
+		dApp := bottom.Get(d.FT.Name)
+		if dApp == nil || dApp.FT.Role != FRSeq || dApp.FT.Cats != d.FT.Cats {
+			return Wrapper(ErrGData, fmt.Sprintf("sequence field %s does not match", d.FT.Name))
+		}
+
+		seq := make([]float64, 0, (top.rows+bottom.rows)*d.FT.Cats)
+		seq = append(seq, d.Data.([]float64)...)
+		seq = append(seq, dApp.Data.([]float64)...)
+
+		ft := *d.FT
+		gd.data = append(gd.data, &GDatum{Data: seq, FT: &ft, Summary: Summary{NRows: top.rows + bottom.rows}})
+		gd.rows = top.rows + bottom.rows
+	}
+
+	return gd.check("")
+}
@@ -0,0 +1,87 @@
+package seafan
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGData_MakeSequence(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a", "b", "a"}, nil), "id", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{3.0, 1.0, 1.0, 2.0, 2.0}, nil), "month", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{30.0, 10.0, 10.0, 20.0, 20.0}, nil), "x", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{-3.0, -1.0, -1.0, -2.0, -2.0}, nil), "z", false, nil, false))
+
+	assert.Nil(t, gd.MakeSequence("seq", "id", "month", 2, "x", "z"))
+
+	d := gd.Get("seq")
+	assert.Equal(t, FRSeq, d.FT.Role)
+
+	steps, feats := d.FT.SeqShape()
+	assert.Equal(t, 2, steps)
+	assert.Equal(t, 2, feats)
+
+	// each row: (x, z) at the prior month then the current month
+	exp := []float64{
+		20, -2, 30, -3,
+		0, 0, 10, -1,
+		0, 0, 10, -1,
+		10, -1, 20, -2,
+		10, -1, 20, -2,
+	}
+	assert.Equal(t, exp, d.Data)
+
+	pipe := NewVecData("seq", gd, WithBatchSize(2))
+	assert.Equal(t, 4, pipe.Cols("seq"))
+
+	// sequences follow their rows
+	sub, e := gd.Subset([]int{3, 0})
+	assert.Nil(t, e)
+	assert.Equal(t, append(exp[12:16:16], exp[0:4]...), sub.Get("seq").Data)
+
+	sl, e := gd.Slice(func(row int) bool { return row == 1 })
+	assert.Nil(t, e)
+	assert.Equal(t, exp[4:8], sl.Get("seq").Data)
+
+	assert.Nil(t, gd.Sort("x", true))
+	assert.Equal(t, exp[4:8], gd.Get("seq").Data.([]float64)[0:4])
+
+	assert.NotNil(t, gd.MakeSequence("seq2", "id", "month", 0, "x"))
+	assert.NotNil(t, gd.MakeSequence("seq2", "id", "month", 2, "id"))
+	assert.NotNil(t, gd.MakeSequence("seq", "id", "month", 2, "x"))
+}
+
+func TestGData_MakeSequence_Read(t *testing.T) {
+	gd := NewGData()
+	assert.Nil(t, gd.AppendD(NewRaw([]any{"a", "b", "a"}, nil), "id", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw([]any{2.0, 1.0, 1.0}, nil), "month", false, nil, false))
+	assert.Nil(t, gd.MakeSequence("seq", "id", "month", 2, "month"))
+
+	// sequence fields are not exported
+	td := gd.TableSpec()
+	assert.NotNil(t, td)
+	assert.Equal(t, 2, len(td.FieldDefs))
+
+	rdr, e := gd.NewReader()
+	assert.Nil(t, e)
+
+	data, _, e := rdr.Read(10, false)
+	assert.Equal(t, io.EOF, e)
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, 2, len(data[0]))
+
+	// the sequences of the appended rows are stacked below
+	gdOut, e := gd.AppendRows(gd, nil)
+	assert.Nil(t, e)
+	assert.Equal(t, 6, gdOut.Rows())
+	seq := gd.Get("seq").Data.([]float64)
+	assert.Equal(t, append(append([]float64{}, seq...), seq...), gdOut.Get("seq").Data)
+
+	other := NewGData()
+	assert.Nil(t, other.AppendD(NewRaw([]any{"c"}, nil), "id", nil, false))
+	assert.Nil(t, other.AppendC(NewRaw([]any{1.0}, nil), "month", false, nil, false))
+	_, e = gd.AppendRows(other, nil)
+	assert.NotNil(t, e)
+}
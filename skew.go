@@ -0,0 +1,232 @@
+package seafan
+
+// skew.go simulates differences between the training and scoring data to measure the robustness of a model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/invertedv/utilities"
+)
+
+// Perturbation describes a change to a single field of a scoring Pipeline.
+//   - Shift: for FRCts fields, Shift standard deviations are added to the field.  The standard deviation is the FParam
+//     Scale of the field.
+//   - DropLevel: for FRCat fields, rows with the level DropLevel are set to the FParam Default level.
+//   - MissRate: a random fraction MissRate of the rows are set to the FParam Default value (0 for FRCts fields
+//     without a Default).
+//
+// More than one can be specified.
+type Perturbation struct {
+	Name      string  // Name describes the perturbation in the report
+	Field     string  // Field to perturb
+	Shift     float64 // Shift FRCts fields by Shift standard deviations
+	DropLevel any     // DropLevel is the level of an FRCat field to drop
+	MissRate  float64 // MissRate is the fraction of rows to set to missing
+}
+
+// SkewResult is the change in the model output caused by a Perturbation.
+type SkewResult struct {
+	Name         string  // Name of the Perturbation
+	BaseMean     float64 // BaseMean is the mean model output of the unperturbed data
+	Mean         float64 // Mean is the mean model output of the perturbed data
+	MeanAbsDelta float64 // MeanAbsDelta is the mean of the absolute value of the change in model output by row
+	KS           float64 // KS is the two-sample KS statistic between the unperturbed and perturbed model outputs
+}
+
+// SkewReport is the result of SkewTest
+type SkewReport []*SkewResult
+
+// String produces a table of the report
+func (sr SkewReport) String() string {
+	const pad = 3
+
+	table := [][]string{{"Perturbation", "Base Mean", "Mean", "Mean Abs Delta", "KS"}}
+
+	for _, r := range sr {
+		table = append(table, []string{r.Name, fmt.Sprintf("%0.4f", r.BaseMean), fmt.Sprintf("%0.4f", r.Mean),
+			fmt.Sprintf("%0.4f", r.MeanAbsDelta), fmt.Sprintf("%0.4f", r.KS)})
+	}
+
+	return utilities.Pad(table, pad)
+}
+
+// SkewTest measures the sensitivity of the model saved in nnFile to each of perturbs.  The model output is the sum
+// of the target columns (as in AddFitted).  pipe must have the FTypes the model was built with.
+func SkewTest(nnFile string, pipe Pipeline, target []int, perturbs ...Perturbation) (SkewReport, error) {
+	base, e := scoreNN(nnFile, pipe, target)
+	if e != nil {
+		return nil, Wrapper(e, "SkewTest")
+	}
+
+	baseMean := mean(base)
+	report := make(SkewReport, 0)
+
+	for _, p := range perturbs {
+		pPipe, e := p.apply(pipe)
+		if e != nil {
+			return nil, Wrapper(e, fmt.Sprintf("SkewTest: perturbation %s", p.Name))
+		}
+
+		score, e := scoreNN(nnFile, pPipe, target)
+		if e != nil {
+			return nil, Wrapper(e, fmt.Sprintf("SkewTest: perturbation %s", p.Name))
+		}
+
+		delta := 0.0
+		for ind, s := range score {
+			delta += math.Abs(s - base[ind])
+		}
+
+		report = append(report, &SkewResult{
+			Name:         p.Name,
+			BaseMean:     baseMean,
+			Mean:         mean(score),
+			MeanAbsDelta: delta / float64(len(score)),
+			KS:           ks2(base, score),
+		})
+	}
+
+	return report, nil
+}
+
+// apply returns a copy of pipe with the perturbation applied
+func (p *Perturbation) apply(pipe Pipeline) (Pipeline, error) {
+	gd := pipe.GData()
+	ft := gd.GetFType(p.Field)
+
+	if ft == nil {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("field %s not found", p.Field))
+	}
+
+	if ft.Role != FRCts && ft.Role != FRCat {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("field %s must be FRCts or FRCat", p.Field))
+	}
+
+	if ft.Role == FRCat && (ft.FP == nil || ft.FP.Default == nil) && (p.DropLevel != nil || p.MissRate > 0.0) {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("field %s has no Default level", p.Field))
+	}
+
+	if p.MissRate < 0.0 || p.MissRate > 1.0 {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("MissRate must be in [0,1], got %v", p.MissRate))
+	}
+
+	raw, e := gd.GetRaw(p.Field)
+	if e != nil {
+		return nil, e
+	}
+
+	ftCopy := copyFType(ft, ft.Name)
+	if ftCopy.FP == nil {
+		ftCopy.FP = &FParam{}
+	}
+
+	miss := getMiss(ftCopy, raw.Kind)
+	data := make([]any, raw.Len())
+
+	for ind, val := range raw.Data {
+		data[ind] = val
+
+		switch {
+		case ft.Role == FRCts && p.Shift != 0.0:
+			x, e := utilities.Any2Float64(val)
+			if e != nil {
+				return nil, e
+			}
+
+			data[ind] = *x + p.Shift*ftCopy.FP.Scale
+		case ft.Role == FRCat && p.DropLevel != nil && val == p.DropLevel:
+			data[ind] = miss
+		}
+
+		if p.MissRate > 0.0 && rng.Float64() < p.MissRate {
+			data[ind] = miss
+		}
+	}
+
+	gdOut := NewGData()
+
+	for _, d := range gd.GetData() {
+		var e error
+
+		switch {
+		case d.FT.Name == p.Field:
+			e = appendLike(gdOut, NewRaw(data, nil), ft, false)
+		case d.FT.Role == FROneHot || d.FT.Role == FREmbed:
+			e = gdOut.MakeOneHot(d.FT.From, d.FT.Name)
+		default:
+			var r *Raw
+			if r, e = gd.GetRaw(d.FT.Name); e == nil {
+				e = appendLike(gdOut, r, d.FT, false)
+			}
+		}
+
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return NewVecData("perturbed", gdOut), nil
+}
+
+// scoreNN returns the sum of the target columns of the output of the model in nnFile run on pipe
+func scoreNN(nnFile string, pipe Pipeline, target []int) ([]float64, error) {
+	// score all the rows in one batch without changing the batch size of pipe
+	all := NewVecData("score", pipe.GData(), WithBatchSize(0))
+
+	nn, e := PredictNN(nnFile, all, false)
+	if e != nil {
+		return nil, e
+	}
+
+	fit := nn.FitSlice()
+	outCols := nn.outCols
+	score := make([]float64, pipe.Rows())
+
+	for row := 0; row < len(score); row++ {
+		for _, col := range target {
+			score[row] += fit[row*outCols+col]
+		}
+	}
+
+	return score, nil
+}
+
+// mean returns the average of x
+func mean(x []float64) float64 {
+	m := 0.0
+	for _, xv := range x {
+		m += xv
+	}
+
+	return m / float64(len(x))
+}
+
+// ks2 returns the two-sample KS statistic of x and y
+func ks2(x, y []float64) float64 {
+	xs, ys := make([]float64, len(x)), make([]float64, len(y))
+	copy(xs, x)
+	copy(ys, y)
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+
+	ks := 0.0
+	indX, indY := 0, 0
+
+	for indX < len(xs) && indY < len(ys) {
+		v := math.Min(xs[indX], ys[indY])
+
+		for indX < len(xs) && xs[indX] == v {
+			indX++
+		}
+
+		for indY < len(ys) && ys[indY] == v {
+			indY++
+		}
+
+		ks = math.Max(ks, math.Abs(float64(indX)/float64(len(xs))-float64(indY)/float64(len(ys))))
+	}
+
+	return ks
+}
@@ -0,0 +1,58 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkewTest(t *testing.T) {
+	Verbose = false
+	mPipe := chPipe(100, "test1.csv")
+	vPipe := chPipe(1000, "testVal.csv")
+
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	nn, e := NewNNModel(mod, mPipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	ft := NewFit(nn, 20, mPipe)
+	assert.Nil(t, ft.Do())
+
+	defer func() {
+		_ = os.Remove(ft.OutFile() + "P.nn")
+		_ = os.Remove(ft.OutFile() + "S.nn")
+	}()
+
+	report, e := SkewTest(ft.OutFile(), vPipe, []int{0},
+		Perturbation{Name: "none", Field: "x1"},
+		Perturbation{Name: "shift x1", Field: "x1", Shift: 2},
+		Perturbation{Name: "missing x2", Field: "x2", MissRate: 0.5})
+	assert.Nil(t, e)
+	assert.Equal(t, 3, len(report))
+
+	assert.Equal(t, 0.0, report[0].KS)
+	assert.Equal(t, 0.0, report[0].MeanAbsDelta)
+	assert.Equal(t, report[0].BaseMean, report[0].Mean)
+
+	assert.Greater(t, report[1].KS, 0.0)
+	assert.Greater(t, report[1].MeanAbsDelta, 0.0)
+	assert.Greater(t, report[2].MeanAbsDelta, 0.0)
+
+	// the pipeline is unchanged
+	assert.Equal(t, 1000, vPipe.BatchSize())
+
+	_, e = SkewTest(ft.OutFile(), vPipe, []int{0}, Perturbation{Name: "bad", Field: "notThere"})
+	assert.NotNil(t, e)
+}
+
+func TestKs2(t *testing.T) {
+	assert.Equal(t, 0.0, ks2([]float64{1, 2, 3}, []float64{3, 2, 1}))
+	assert.Equal(t, 1.0, ks2([]float64{1, 2}, []float64{3, 4}))
+	assert.InDelta(t, 0.5, ks2([]float64{1, 2, 3, 4}, []float64{3, 4, 5, 6}), 1e-10)
+}
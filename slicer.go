@@ -1,13 +1,21 @@
 package seafan
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/invertedv/utilities"
+)
 
 // Slicer is an optional function that returns true if the row is to be used in calculations. This is used to
 // subset the diagnostics to specific values.
 type Slicer func(row int) bool
 
 // Slice implements generating Slicer functions for a feature.  These are used to slice through the values
-// of a discrete feature. For continuous features, it slices by quartile.
+// of a discrete feature. For continuous features, it slices by quartile (see SliceOpts for other bins).
 type Slice struct {
 	feat     string   // feature to slice
 	minCnt   int      // a level of a feature must have at least minCnt obs to be used
@@ -18,6 +26,49 @@ type Slice struct {
 	data     *GDatum  // feat data
 	restrict []any
 	q        []float64
+	cuts     bool         // true if q are the cut points of WithCuts rather than quantiles
+	top      map[any]bool // the levels of a discrete feature to use (see WithTopK), all if nil
+}
+
+// SliceOpts sets an option of NewSlice
+type SliceOpts func(so *sliceOpts)
+
+// sliceOpts holds the options of NewSlice
+type sliceOpts struct {
+	bins int       // # of quantile bins of a continuous feature
+	cuts []float64 // cut points of a continuous feature in raw units
+	topK int       // # of most frequent levels of a discrete feature
+}
+
+// WithQuantileBins slices a continuous feature into n bins of (roughly) equal counts rather than at the quartiles.
+// With NewSliceWeighted, the bins have equal weight.
+func WithQuantileBins(n int) SliceOpts {
+	return func(so *sliceOpts) {
+		so.bins = n
+	}
+}
+
+// WithCuts slices a continuous feature at the cut points cuts, which are in the units of the raw data.  The first
+// and last slices start and end at the min and max of the feature.  Cut points outside the range of the feature are
+// ignored.
+func WithCuts(cuts ...float64) SliceOpts {
+	return func(so *sliceOpts) {
+		so.cuts = cuts
+	}
+}
+
+// WithTopK restricts the slices of a discrete feature to its k most frequent levels.
+func WithTopK(k int) SliceOpts {
+	return func(so *sliceOpts) {
+		so.topK = k
+	}
+}
+
+// Bin is a slice of a feature
+type Bin struct {
+	Lo, Hi float64 // Lo and Hi are the range of a continuous feature in raw units. Hi is in the last bin only.
+	Level  any     // Level is the level of a discrete feature
+	Label  string  // Label describes the bin, for instance in a plot legend
 }
 
 func deDupe(xIn []float64) (xOut []float64) {
@@ -34,7 +85,8 @@ func deDupe(xIn []float64) (xOut []float64) {
 // NewSlice makes a new Slice based on feat in Pipeline pipe.
 // minCnt is the minimum # of obs a slice must have to be used.
 // Restrict is a slice of values to restrict Iter to.
-func NewSlice(feat string, minCnt int, pipe Pipeline, restrict []any) (*Slice, error) {
+// opts change the bins (see WithQuantileBins, WithCuts, WithTopK).
+func NewSlice(feat string, minCnt int, pipe Pipeline, restrict []any, opts ...SliceOpts) (*Slice, error) {
 	d := pipe.Get(feat)
 
 	if d == nil {
@@ -47,10 +99,198 @@ func NewSlice(feat string, minCnt int, pipe Pipeline, restrict []any) (*Slice, e
 
 	s := &Slice{feat: feat, minCnt: minCnt, pipe: pipe, index: -1, val: nil, data: d, restrict: restrict}
 
+	so := &sliceOpts{}
+	for _, o := range opts {
+		o(so)
+	}
+
+	if so.bins < 0 || so.topK < 0 {
+		return nil, Wrapper(ErrDiags, "NewSlice: bins and top k cannot be negative")
+	}
+
+	if so.bins > 0 && so.cuts != nil {
+		return nil, Wrapper(ErrDiags, "NewSlice: cannot have both quantile bins and cuts")
+	}
+
 	if s.data.Summary.DistrC != nil {
 		s.q = deDupe(s.data.Summary.DistrC.Q)
 	}
 
+	switch d.FT.Role {
+	case FRCts:
+		if so.bins > 0 {
+			desc, e := NewDesc(binQuantiles(so.bins), feat)
+			if e != nil {
+				return nil, Wrapper(e, "NewSlice")
+			}
+
+			desc.Populate(d.Floats(), true, nil)
+			s.q = deDupe(desc.Q)
+		}
+
+		if so.cuts != nil {
+			s.q, s.cuts = s.cutEdges(so.cuts), true
+		}
+	case FRCat:
+		if so.topK > 0 && d.Summary.DistrD != nil {
+			keys, _ := d.Summary.DistrD.Sort(false, false)
+			s.top = make(map[any]bool)
+
+			for _, k := range keys[:utilities.MinInt(so.topK, len(keys))] {
+				s.top[k] = true
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// binQuantiles returns the quantiles 0, 1/n, ..., 1
+func binQuantiles(n int) []float64 {
+	u := make([]float64, n+1)
+	for ind := range u {
+		u[ind] = float64(ind) / float64(n)
+	}
+
+	return u
+}
+
+// cutEdges returns the edges of the slices at cuts (raw units) in the units of the data.
+func (s *Slice) cutEdges(cuts []float64) []float64 {
+	lo, hi := math.MaxFloat64, -math.MaxFloat64
+	x := s.data.Floats()
+
+	for row := 0; row < s.data.Summary.NRows; row++ {
+		lo, hi = math.Min(lo, x[row]), math.Max(hi, x[row])
+	}
+
+	q := []float64{lo}
+	for _, c := range cuts {
+		if s.data.FT.Normalized {
+			c = (c - s.data.FT.FP.Location) / s.data.FT.FP.Scale
+		}
+
+		if c > lo && c < hi {
+			q = append(q, c)
+		}
+	}
+
+	q = append(q, hi)
+	sort.Float64s(q)
+
+	return deDupe(q)
+}
+
+// rawEdges returns the edges of the slices of a continuous feature in the units of the raw data
+func (s *Slice) rawEdges() []float64 {
+	qLab := make([]float64, len(s.q))
+	copy(qLab, s.q)
+
+	// if the feature is normalized, return it to original units for display
+	if s.data.FT.Normalized {
+		for ind := 0; ind < len(qLab); ind++ {
+			qLab[ind] = qLab[ind]*s.data.FT.FP.Scale + s.data.FT.FP.Location
+		}
+	}
+
+	return qLab
+}
+
+// Label describes the current slice: the range of a continuous feature in raw units or the level of a discrete
+// feature.
+func (s *Slice) Label() string {
+	if s.index < 0 {
+		return ""
+	}
+
+	if s.data.FT.Role != FRCts {
+		return fmt.Sprintf("%v", s.val)
+	}
+
+	qLab := s.rawEdges()
+	closer := ")"
+	if int(s.index)+2 == len(qLab) {
+		closer = "]"
+	}
+
+	return fmt.Sprintf("[%0.4g, %0.4g%s", qLab[s.index], qLab[s.index+1], closer)
+}
+
+// Bins returns the slices Iter produces.
+func (s *Slice) Bins() []Bin {
+	// iterate over a copy so the state of s is not changed
+	sc := *s
+	sc.index = -1
+	bins := make([]Bin, 0)
+
+	for sc.Iter() {
+		b := Bin{Label: sc.Label()}
+
+		switch sc.data.FT.Role {
+		case FRCts:
+			qLab := sc.rawEdges()
+			b.Lo, b.Hi = qLab[sc.index], qLab[sc.index+1]
+		default:
+			b.Level = sc.val
+		}
+
+		bins = append(bins, b)
+	}
+
+	return bins
+}
+
+// NewSliceWeighted makes a new Slice based on feat in Pipeline pipe. Unlike NewSlice, the quantiles of
+// continuous features are weighted by the field weight.  For instance, if weight is the loan balance, each slice has
+// roughly the same total balance rather than the same number of loans.  With WithCuts, there is no weighting.
+func NewSliceWeighted(feat, weight string, minCnt int, pipe Pipeline, restrict []any, opts ...SliceOpts) (*Slice, error) {
+	s, e := NewSlice(feat, minCnt, pipe, restrict, opts...)
+	if e != nil {
+		return nil, e
+	}
+
+	if s.data.FT.Role != FRCts || s.cuts {
+		return s, nil
+	}
+
+	so := &sliceOpts{}
+	for _, o := range opts {
+		o(so)
+	}
+
+	w := pipe.Get(weight)
+	if w == nil {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSliceWeighted: %s not found in pipeline", weight))
+	}
+
+	if w.FT.Role != FRCts {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSliceWeighted: weight %s must be FRCts", weight))
+	}
+
+	var u []float64
+	if s.data.Summary.DistrC != nil {
+		u = s.data.Summary.DistrC.U
+	}
+
+	if so.bins > 0 {
+		u = binQuantiles(so.bins)
+	}
+
+	desc, e := NewDesc(u, feat)
+	if e != nil {
+		return nil, e
+	}
+
+	// UnNormalize works in place
+	wts := make([]float64, w.Summary.NRows)
+	copy(wts, w.Floats())
+
+	if e := desc.PopulateWeighted(s.data.Floats(), UnNormalize(wts, w.FT), nil); e != nil {
+		return nil, Wrapper(e, "NewSliceWeighted")
+	}
+
+	s.q = deDupe(desc.Q)
+
 	return s, nil
 }
 
@@ -69,6 +309,97 @@ func (s *Slice) Index() int32 {
 	return s.index
 }
 
+// NewSlicerExpr creates a Slicer from the expression expr evaluated on pipe.  The Slicer returns true for rows
+// where expr evaluates to a positive value. For instance:
+//
+//	sl, e := NewSlicerExpr("fico>700 && state=='CA'", pipe)
+//	sl, e := NewSlicerExpr("in(state,'CA','TX','NY') && !in(fico,700,720)", pipe)
+//
+// The expression is evaluated once, when the Slicer is created.
+func NewSlicerExpr(expr string, pipe Pipeline) (Slicer, error) {
+	root := &OpNode{Expression: expr}
+	if e := Expr2Tree(root); e != nil {
+		return nil, Wrapper(e, "NewSlicerExpr")
+	}
+
+	if e := Evaluate(root, pipe); e != nil {
+		return nil, Wrapper(e, "NewSlicerExpr")
+	}
+
+	n := root.Raw.Len()
+	if n != 1 && n != pipe.Rows() {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSlicerExpr: expected %d values, got %d", pipe.Rows(), n))
+	}
+
+	keep := make([]bool, n)
+	for ind := 0; ind < n; ind++ {
+		x, e := utilities.Any2Float64(root.Raw.Data[ind])
+		if e != nil {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSlicerExpr: %s does not evaluate to a number", expr))
+		}
+
+		keep[ind] = *x > 0.0
+	}
+
+	fx := func(row int) bool {
+		if n == 1 {
+			return keep[0]
+		}
+
+		return keep[row]
+	}
+
+	return fx, nil
+}
+
+// NewSlicerIn creates a Slicer that returns true for rows where field is one of values.  It is the same as
+// NewSlicerExpr with in(field, values...).  Values are strings, numbers or dates.
+func NewSlicerIn(field string, pipe Pipeline, values ...any) (Slicer, error) {
+	if len(values) == 0 {
+		return nil, Wrapper(ErrDiags, "NewSlicerIn: no values")
+	}
+
+	args := []string{field}
+
+	for _, val := range values {
+		switch v := val.(type) {
+		case string:
+			if strings.Contains(v, "'") {
+				return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSlicerIn: value %s cannot contain '", v))
+			}
+
+			args = append(args, "'"+v+"'")
+		case time.Time:
+			args = append(args, "'"+v.Format("2006-01-02")+"'")
+		case float64, float32, int, int32, int64:
+			args = append(args, fmt.Sprintf("%v", v))
+		default:
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSlicerIn: unsupported value type %T", val))
+		}
+	}
+
+	return NewSlicerExpr(fmt.Sprintf("in(%s)", strings.Join(args, ",")), pipe)
+}
+
+// NewSlicerDates creates a Slicer that returns true for rows where the FRDate field is between from and to,
+// inclusive.
+func NewSlicerDates(field string, pipe Pipeline, from, to time.Time) (Slicer, error) {
+	d := pipe.Get(field)
+	if d == nil {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSlicerDates: field %s not found", field))
+	}
+
+	if d.FT.Role != FRDate {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("NewSlicerDates: field %s is not FRDate", field))
+	}
+
+	days, lo, hi := d.Data.([]int64), dateDay(from), dateDay(to)
+
+	return func(row int) bool {
+		return days[row] >= lo && days[row] <= hi
+	}, nil
+}
+
 // SlicerAnd creates a Slicer that is s1 && s2
 func SlicerAnd(s1, s2 Slicer) Slicer {
 	return func(row int) bool {
@@ -93,12 +424,13 @@ func (s *Slice) MakeSlicer() Slicer {
 
 		case FRCts:
 			//q := deDupe(s.data.Summary.DistrC.Q)
-			test := s.data.Data.([]float64)[row] >= s.q[s.index]
+			x := s.data.floatAt(row)
+			test := x >= s.q[s.index]
 			switch s.index+1 == int32(len(s.q)-1) {
 			case false:
-				test = test && s.data.Data.([]float64)[row] < s.q[s.index+1]
+				test = test && x < s.q[s.index+1]
 			case true:
-				test = test && s.data.Data.([]float64)[row] <= s.q[s.index+1]
+				test = test && x <= s.q[s.index+1]
 			}
 			return test
 		}
@@ -121,20 +453,12 @@ func (s *Slice) Iter() bool {
 		}
 
 		// make title
-		//		q := s.data.Summary.DistrC.Q
-		qLab := make([]float64, len(s.q))
-		copy(qLab, s.q)
-		// if the feature is normalized, return it to original units for display
-		if s.data.FT.Normalized {
-			m := s.data.FT.FP.Location
-			std := s.data.FT.FP.Scale
-
-			for ind := 0; ind < len(qLab); ind++ {
-				qLab[ind] = qLab[ind]*std + m
-			}
-		}
+		qLab := s.rawEdges()
 
 		s.title = fmt.Sprintf("%s between quantiles %v and %v", s.feat, qLab[s.index], qLab[s.index+1])
+		if s.cuts {
+			s.title = fmt.Sprintf("%s between %v and %v", s.feat, qLab[s.index], qLab[s.index+1])
+		}
 		s.val = qLab[s.index+1]
 
 		return true
@@ -167,6 +491,13 @@ func (s *Slice) Iter() bool {
 						continue
 					}
 
+					// and is one of the top k levels
+					if s.top != nil && !s.top[s.val] {
+						s.index++
+
+						continue
+					}
+
 					if s.restrict == nil {
 						return true
 					}
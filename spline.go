@@ -0,0 +1,127 @@
+package seafan
+
+// spline.go implements piecewise-linear (hinge) basis expansions of continuous fields
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// splineName returns the name of the basis field of field for the knot number ind (starting at 1)
+func splineName(field string, ind int) string {
+	return fmt.Sprintf("%sS%d", field, ind)
+}
+
+// hinge returns the hinge function max(0, x-knot)
+func hinge(x, knot float64) float64 {
+	return math.Max(0.0, x-knot)
+}
+
+// Splines adds a piecewise-linear basis for the FRCts field to pipe.  For each knot k, the field max(0, field - k)
+// is added.  The knots are in the units of the raw data (if field is normalized, it is un-normalized first).  Together
+// with field, the basis fields allow a model to fit a continuous piecewise-linear function of field with the slope
+// changing at each knot.
+//
+// The basis fields are named <field>S1, <field>S2, ... in order of increasing knot.  Their FTypes have From = field
+// and FP.Knot set to the knot, so the expansion can be repeated on new data by ApplySplines.  The names of the new
+// fields are returned.
+func Splines(pipe Pipeline, field string, knots []float64) ([]string, error) {
+	d := pipe.Get(field)
+	if d == nil {
+		return nil, Wrapper(ErrPipe, fmt.Sprintf("Splines: field %s not found", field))
+	}
+
+	if d.FT.Role != FRCts {
+		return nil, Wrapper(ErrPipe, fmt.Sprintf("Splines: field %s is not FRCts", field))
+	}
+
+	if len(knots) == 0 {
+		return nil, Wrapper(ErrPipe, "Splines: no knots")
+	}
+
+	ks := make([]float64, len(knots))
+	copy(ks, knots)
+	sort.Float64s(ks)
+
+	// check all the knots before any field is added, so an error leaves pipe unchanged
+	for ind, k := range ks {
+		if math.IsNaN(k) || math.IsInf(k, 0) {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("Splines: invalid knot %v", k))
+		}
+
+		if ind > 0 && k == ks[ind-1] {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("Splines: duplicate knot %v", k))
+		}
+
+		if pipe.Get(splineName(field, ind+1)) != nil {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("Splines: field %s already in pipe", splineName(field, ind+1)))
+		}
+	}
+
+	names := make([]string, 0)
+
+	for ind, k := range ks {
+		name := splineName(field, ind+1)
+		if e := addSpline(pipe, name, field, k); e != nil {
+			return nil, Wrapper(e, "Splines")
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// ApplySplines adds the spline basis fields in fts (fields with FP.Knot set) that are not already in pipe.  Use it to
+// expand scoring data identically to the model build data.  The names of the new fields are returned.
+func ApplySplines(pipe Pipeline, fts FTypes) ([]string, error) {
+	names := make([]string, 0)
+
+	for _, ft := range fts {
+		if ft.FP == nil || ft.FP.Knot == nil || pipe.GetFType(ft.Name) != nil {
+			continue
+		}
+
+		if e := addSpline(pipe, ft.Name, ft.From, *ft.FP.Knot); e != nil {
+			return nil, Wrapper(e, "ApplySplines")
+		}
+
+		names = append(names, ft.Name)
+	}
+
+	return names, nil
+}
+
+// addSpline adds the hinge basis field name of field from with knot to pipe
+func addSpline(pipe Pipeline, name, from string, knot float64) error {
+	d := pipe.Get(from)
+	if d == nil {
+		return Wrapper(ErrPipe, fmt.Sprintf("field %s not found", from))
+	}
+
+	if d.FT.Role != FRCts {
+		return Wrapper(ErrPipe, fmt.Sprintf("field %s is not FRCts", from))
+	}
+
+	x := d.Floats()
+	vals := make([]float64, len(x))
+
+	for ind, xv := range x {
+		if d.FT.Normalized {
+			xv = xv*d.FT.FP.Scale + d.FT.FP.Location
+		}
+
+		vals[ind] = hinge(xv, knot)
+	}
+
+	if e := pipe.GData().AppendC(NewRawCast(vals, nil), name, false, nil, pipe.GetKeepRaw()); e != nil {
+		return e
+	}
+
+	ft := pipe.GetFType(name)
+	ft.From = from
+	ft.FP.Knot = &knot
+
+	return nil
+}
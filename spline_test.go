@@ -0,0 +1,51 @@
+package seafan
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplines(t *testing.T) {
+	pipe := NewVecData("spline", getData(t))
+
+	names, e := Splines(pipe, "x1", []float64{5, 2})
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"x1S1", "x1S2"}, names)
+	// x1 is 1, 2, 3, 4, 8, 9, 10
+	assert.Equal(t, []float64{0, 0, 1, 2, 6, 7, 8}, pipe.Get("x1S1").Data)
+	assert.Equal(t, []float64{0, 0, 0, 0, 3, 4, 5}, pipe.Get("x1S2").Data)
+	assert.Equal(t, "x1", pipe.GetFType("x1S2").From)
+	assert.Equal(t, 5.0, *pipe.GetFType("x1S2").FP.Knot)
+
+	// the knots survive a save
+	ftFile := os.TempDir() + "/splineFts.json"
+	assert.Nil(t, pipe.GetFTypes().Save(ftFile))
+	fts, e := LoadFTypes(ftFile)
+	assert.Nil(t, e)
+	_ = os.Remove(ftFile)
+
+	// new data is expanded with the same knots
+	newPipe := NewVecData("new", getData(t))
+	names, e = ApplySplines(newPipe, fts)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"x1S1", "x1S2"}, names)
+	assert.Equal(t, pipe.Get("x1S2").Data, newPipe.Get("x1S2").Data)
+
+	_, e = Splines(pipe, "x2", []float64{1})
+	assert.NotNil(t, e)
+
+	// the fields already exist
+	_, e = Splines(pipe, "x1", []float64{1})
+	assert.NotNil(t, e)
+
+	// a bad knot leaves the pipeline unchanged
+	fresh := NewVecData("fresh", getData(t))
+	for _, knots := range [][]float64{{1, 3, 3}, {1, math.NaN()}} {
+		_, e = Splines(fresh, "x1", knots)
+		assert.NotNil(t, e)
+		assert.Nil(t, fresh.Get("x1S1"))
+	}
+}
@@ -0,0 +1,201 @@
+package seafan
+
+// sql.go translates expression trees built by Expr2Tree into SQL
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/invertedv/utilities"
+)
+
+// chGroupRef finds references to capture groups ($1) in the replacement of strReplaceRe
+var chGroupRef = regexp.MustCompile(`\$(\d)`)
+
+// chFunctions maps parser functions to ClickHouse functions that take the same arguments in the same order
+var chFunctions = map[string]string{
+	"log":               "log",
+	"exp":               "exp",
+	"abs":               "abs",
+	"pow":               "pow",
+	"if":                "if",
+	"sum":               "sum",
+	"mean":              "avg",
+	"max":               "max",
+	"min":               "min",
+	"std":               "stddevSamp",
+	"median":            "median",
+	"count":             "count",
+	"dateAdd":           "addMonths",
+	"toLastDayOfMonth":  "toLastDayOfMonth",
+	"toFirstDayOfMonth": "toStartOfMonth",
+	"day":               "toDayOfMonth",
+	"month":             "toMonth",
+	"year":              "toYear",
+	"toDate":            "toDate",
+	"nowDate":           "today",
+	"toString":          "toString",
+	"toFloatDP":         "toFloat64",
+	"toFloatSP":         "toFloat32",
+	"toInt":             "toInt32",
+	"cat":               "toInt32",
+	"maxE":              "greatest",
+	"minE":              "least",
+	"substr":            "substring",
+	"strCount":          "countSubstrings",
+	"strLen":            "length",
+	"strMatch":          "match",
+	"rand":              "randCanonical",
+	"randn":             "randNormal",
+}
+
+// ToSQL translates the expression tree rooted at node into SQL. The tree must be built by Expr2Tree. The only
+// dialect supported is "clickhouse".
+//
+// Functions that depend on the order of the rows (e.g. lag, cumeAfter), the FTypes of the Pipeline (e.g. nLevels)
+// or that produce output (e.g. print, plotXY) have no SQL equivalent and return an error.
+//
+// Strings that are valid dates (CCYYMMDD or MM/DD/CCYY) are converted to ClickHouse dates.  Logical operators and
+// the condition of if() treat positive values as true, as Evaluate does.
+func (node *OpNode) ToSQL(dialect string) (string, error) {
+	if strings.ToLower(dialect) != "clickhouse" {
+		return "", fmt.Errorf("ToSQL: unsupported dialect %s", dialect)
+	}
+
+	return node.toCH()
+}
+
+// toCH translates node to ClickHouse SQL
+func (node *OpNode) toCH() (string, error) {
+	sql, e := node.toCHNoNeg()
+	if e != nil {
+		return "", e
+	}
+
+	if node.Neg {
+		return "-" + sql, nil
+	}
+
+	return sql, nil
+}
+
+// toCHNoNeg translates node to ClickHouse SQL ignoring node.Neg
+func (node *OpNode) toCHNoNeg() (string, error) {
+	if node.Func == nil {
+		return leafCH(node.Expression), nil
+	}
+
+	args := make([]string, len(node.Inputs))
+
+	for ind, inp := range node.Inputs {
+		var e error
+		if args[ind], e = inp.toCH(); e != nil {
+			return "", e
+		}
+	}
+
+	name := node.Func.Name
+
+	if utilities.Has(name, delim, operations) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("ToSQL: operations require two operands")
+		}
+
+		switch name {
+		case "^":
+			return fmt.Sprintf("pow(%s, %s)", args[0], args[1]), nil
+		case "&&":
+			return fmt.Sprintf("(%s AND %s)", truthCH(node.Inputs[0], args[0]), truthCH(node.Inputs[1], args[1])), nil
+		case "||":
+			return fmt.Sprintf("(%s OR %s)", truthCH(node.Inputs[0], args[0]), truthCH(node.Inputs[1], args[1])), nil
+		case "==":
+			return fmt.Sprintf("(%s = %s)", args[0], args[1]), nil
+		case "+":
+			// Expr2Tree converts a - b to a + -b
+			if node.Inputs[1].Neg {
+				return fmt.Sprintf("(%s - %s)", args[0], args[1][1:]), nil
+			}
+		}
+
+		return fmt.Sprintf("(%s %s %s)", args[0], name, args[1]), nil
+	}
+
+	switch name {
+	case "not":
+		return fmt.Sprintf("(NOT %s)", truthCH(node.Inputs[0], args[0])), nil
+	case "in":
+		return fmt.Sprintf("(%s IN (%s))", args[0], strings.Join(args[1:], ", ")), nil
+	case "if":
+		if len(args) != 3 {
+			return "", fmt.Errorf("ToSQL: if requires three arguments")
+		}
+
+		args[0] = truthCH(node.Inputs[0], args[0])
+	case "strPos":
+		// strPos returns -1 if the target is not found, position returns 0
+		if len(args) != 2 {
+			return "", fmt.Errorf("ToSQL: strPos requires two arguments")
+		}
+
+		pos := fmt.Sprintf("position(%s, %s)", args[0], args[1])
+
+		return fmt.Sprintf("if(%s = 0, -1, %s)", pos, pos), nil
+	case "randInt":
+		if len(args) != 2 {
+			return "", fmt.Errorf("ToSQL: randInt requires two arguments")
+		}
+
+		return fmt.Sprintf("(%s + (rand() %% (%s - %s + 1)))", args[0], args[1], args[0]), nil
+	case "strExtract":
+		// wrapping the pattern in a group makes group 0 the entire match, as in Evaluate
+		return fmt.Sprintf("extractGroups(%s, concat('(', %s, ')'))[%s + 1]", args[0], args[1], args[2]), nil
+	case "strReplaceRe":
+		// ClickHouse refers to capture groups as \1 rather than $1
+		return fmt.Sprintf("replaceRegexpAll(%s, %s, %s)", args[0], args[1],
+			chGroupRef.ReplaceAllString(args[2], `\\${1}`)), nil
+	case "dateDiff":
+		if len(args) != 3 {
+			return "", fmt.Errorf("ToSQL: dateDiff requires three arguments")
+		}
+
+		return fmt.Sprintf("dateDiff(%s, %s, %s)", args[2], args[1], args[0]), nil
+	}
+
+	chName, ok := chFunctions[name]
+	if !ok {
+		return "", fmt.Errorf("ToSQL: function %s has no ClickHouse equivalent", name)
+	}
+
+	return fmt.Sprintf("%s(%s)", chName, strings.Join(args, ", ")), nil
+}
+
+// leafCH translates a leaf (constant or field) to ClickHouse SQL
+func leafCH(expr string) string {
+	if _, e := strconv.ParseFloat(expr, 64); e == nil {
+		return expr
+	}
+
+	if !strings.Contains(expr, "'") {
+		return expr
+	}
+
+	str := strings.ReplaceAll(expr, "'", "")
+
+	if dt, e := any2Date(str); e == nil {
+		return fmt.Sprintf("toDate('%s')", dt.Format("2006-01-02"))
+	}
+
+	return fmt.Sprintf("'%s'", str)
+}
+
+// truthCH returns the SQL for the test sql > 0 unless node is already a comparison or logical operation
+func truthCH(node *OpNode, sql string) string {
+	if node.Func != nil && !node.Neg && (node.Func.Name == "not" || node.Func.Name == "in" ||
+		utilities.Has(node.Func.Name, delim, logicals+delim+comparisons)) {
+		return sql
+	}
+
+	return fmt.Sprintf("(%s > 0)", sql)
+}
@@ -0,0 +1,171 @@
+package seafan
+
+// target.go builds modeling targets from event panels
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/invertedv/utilities"
+)
+
+// TargetRule declares how to construct a 0/1 target from a panel of events.  The panel has one row per
+// entity (e.g. loan) and date.  The target is 1 for an entity if Event is true for any row in the window of
+// Months months starting at the entity's Start date.  For example, "default = 1 if ever 90+ DPD within 24 months
+// of origination" is:
+//
+//	TargetRule{Name: "default", Group: "loan", Date: "month", Start: "origDate", Months: 24, Event: "dpd >= 90"}
+type TargetRule struct {
+	Name   string // Name of the target field
+	Group  string // Group is the field that identifies the entity
+	Date   string // Date is the field with the date of each row of the panel
+	Start  string // Start is the field with the start of the window. If "", the first Date of the entity is used.
+	Months int    // Months is the length of the window. If Months <= 0, the window has no end.
+	Event  string // Event is an expression that evaluates to a positive value when the event occurs
+}
+
+// MakeTargets applies rules to the panel pipe. The output has one row for each value of the Group field (in order
+// of first appearance) and a field for each rule. All the rules must have the same Group field.
+func MakeTargets(pipe Pipeline, rules ...TargetRule) (Pipeline, error) {
+	if len(rules) == 0 {
+		return nil, Wrapper(ErrPipe, "MakeTargets: no rules")
+	}
+
+	group := rules[0].Group
+	grpDatum := pipe.Get(group)
+
+	if grpDatum == nil {
+		return nil, Wrapper(ErrPipe, fmt.Sprintf("MakeTargets: group field %s not found", group))
+	}
+
+	grpRaw, e := pipe.GData().GetRaw(group)
+	if e != nil {
+		return nil, Wrapper(e, "MakeTargets")
+	}
+
+	groups := Unique(grpRaw.Data)
+	grpInd := make(map[any]int)
+
+	for ind, g := range groups {
+		grpInd[g] = ind
+	}
+
+	gdOut := NewGData()
+	if e := appendLike(gdOut, NewRaw(groups, nil), grpDatum.FT, pipe.GetKeepRaw()); e != nil {
+		return nil, Wrapper(e, "MakeTargets")
+	}
+
+	for _, rule := range rules {
+		if rule.Group != group {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("MakeTargets: rule %s has group %s, expected %s", rule.Name, rule.Group, group))
+		}
+
+		target, e := rule.apply(pipe, grpRaw, grpInd)
+		if e != nil {
+			return nil, Wrapper(e, fmt.Sprintf("MakeTargets: rule %s", rule.Name))
+		}
+
+		if e := gdOut.AppendC(NewRaw(target, nil), rule.Name, false, nil, pipe.GetKeepRaw()); e != nil {
+			return nil, Wrapper(e, "MakeTargets")
+		}
+	}
+
+	pipeOut := NewVecData("targets", gdOut)
+	WithKeepRaw(pipe.GetKeepRaw())(pipeOut)
+
+	return pipeOut, nil
+}
+
+// apply calculates the target of rule for each group
+func (rule *TargetRule) apply(pipe Pipeline, grpRaw *Raw, grpInd map[any]int) ([]any, error) {
+	dates, e := panelDates(pipe, rule.Date)
+	if e != nil {
+		return nil, e
+	}
+
+	// the window start for each row
+	starts := make([]time.Time, len(dates))
+
+	switch rule.Start {
+	case "":
+		first := make(map[any]time.Time)
+
+		for row, dt := range dates {
+			g := grpRaw.Data[row]
+			if f, ok := first[g]; !ok || dt.Before(f) {
+				first[g] = dt
+			}
+		}
+
+		for row := range dates {
+			starts[row] = first[grpRaw.Data[row]]
+		}
+	default:
+		if starts, e = panelDates(pipe, rule.Start); e != nil {
+			return nil, e
+		}
+	}
+
+	root := &OpNode{Expression: rule.Event}
+	if e := Expr2Tree(root); e != nil {
+		return nil, e
+	}
+
+	if e := Evaluate(root, pipe); e != nil {
+		return nil, e
+	}
+
+	events, e := utilities.AnySlice2Float64(root.Raw.Data)
+	if e != nil {
+		return nil, fmt.Errorf("event %s is not numeric", rule.Event)
+	}
+
+	if len(events) != 1 && len(events) != len(dates) {
+		return nil, fmt.Errorf("event %s has %d values, expected %d", rule.Event, len(events), len(dates))
+	}
+
+	target := make([]any, len(grpInd))
+	for ind := 0; ind < len(target); ind++ {
+		target[ind] = 0.0
+	}
+
+	for row, dt := range dates {
+		event := events[0]
+		if len(events) > 1 {
+			event = events[row]
+		}
+
+		if event <= 0.0 || dt.Before(starts[row]) {
+			continue
+		}
+
+		if rule.Months > 0 && !dt.Before(starts[row].AddDate(0, rule.Months, 0)) {
+			continue
+		}
+
+		target[grpInd[grpRaw.Data[row]]] = 1.0
+	}
+
+	return target, nil
+}
+
+// panelDates returns the values of the date field in pipe
+func panelDates(pipe Pipeline, field string) ([]time.Time, error) {
+	raw, e := pipe.GData().GetRaw(field)
+	if e != nil {
+		return nil, e
+	}
+
+	dates := make([]time.Time, raw.Len())
+
+	for ind, d := range raw.Data {
+		dt, ok := d.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("field %s is not a date", field)
+		}
+
+		dates[ind] = dt
+	}
+
+	return dates, nil
+}
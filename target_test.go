@@ -0,0 +1,42 @@
+package seafan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeTargets(t *testing.T) {
+	dt := func(y, m int) time.Time { return time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC) }
+
+	loan := []any{"l1", "l1", "l1", "l2", "l2", "l3"}
+	month := []any{dt(2020, 1), dt(2021, 1), dt(2022, 6), dt(2020, 3), dt(2020, 9), dt(2021, 1)}
+	orig := []any{dt(2020, 1), dt(2020, 1), dt(2020, 1), dt(2019, 1), dt(2019, 1), dt(2021, 1)}
+	dpd := []any{0.0, 30.0, 120.0, 90.0, 0.0, 60.0}
+
+	pipe, e := VecFromAny([][]any{loan, month, orig, dpd}, []string{"loan", "month", "orig", "dpd"}, nil)
+	assert.Nil(t, e)
+
+	rules := []TargetRule{
+		{Name: "default", Group: "loan", Date: "month", Start: "orig", Months: 24, Event: "dpd >= 90"},
+		{Name: "ever30", Group: "loan", Date: "month", Event: "dpd >= 30"},
+		{Name: "early90", Group: "loan", Date: "month", Months: 6, Event: "dpd >= 90"},
+	}
+
+	targets, e := MakeTargets(pipe, rules...)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, targets.Rows())
+
+	loans, e := targets.GData().GetRaw("loan")
+	assert.Nil(t, e)
+	assert.Equal(t, []any{"l1", "l2", "l3"}, loans.Data)
+
+	// l1 goes 120 DPD 29 months after origination
+	assert.Equal(t, []float64{0, 1, 0}, targets.Get("default").Data)
+	assert.Equal(t, []float64{1, 1, 1}, targets.Get("ever30").Data)
+	assert.Equal(t, []float64{0, 1, 0}, targets.Get("early90").Data)
+
+	_, e = MakeTargets(pipe, TargetRule{Name: "bad", Group: "loan", Date: "dpd", Event: "dpd > 0"})
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,514 @@
+package seafan
+
+// validate.go checks a ModSpec against a Pipeline before the model graph is built
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/invertedv/utilities"
+)
+
+// SpecError is a problem with a ModSpec found by Validate
+type SpecError struct {
+	Layer  int    // index of the layer in the ModSpec
+	Offset int    // offset in the layer string of the start of the problem
+	Msg    string // description of the problem
+}
+
+func (se *SpecError) Error() string {
+	return fmt.Sprintf("layer %d, offset %d: %s", se.Layer, se.Offset, se.Msg)
+}
+
+// Unwrap returns ErrModSpec
+func (se *SpecError) Unwrap() error {
+	return ErrModSpec
+}
+
+// SpecErrors are the problems with a ModSpec found by Validate
+type SpecErrors []*SpecError
+
+// Error lists the problems, one per line
+func (ses SpecErrors) Error() string {
+	msgs := make([]string, len(ses))
+	for ind, se := range ses {
+		msgs[ind] = se.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the problems
+func (ses SpecErrors) Unwrap() []error {
+	errs := make([]error, len(ses))
+	for ind, se := range ses {
+		errs[ind] = se
+	}
+
+	return errs
+}
+
+// specArg is an argument of a layer and its offset in the layer string
+type specArg struct {
+	text   string
+	offset int
+}
+
+// specArgs splits the layer string into its name and arguments.  Arguments are separated by sep outside of
+// parentheses and brackets.  The offset of the problem is returned if the layer is malformed.
+func specArgs(layer string, sep rune) (name string, args []specArg, bad *SpecError) {
+	open := strings.Index(layer, "(")
+	if open <= 0 {
+		return "", nil, &SpecError{Offset: utilities.MaxInt(open, 0), Msg: "expected name(arguments)"}
+	}
+
+	end := strings.LastIndex(layer, ")")
+	if end < open || strings.TrimSpace(layer[end+1:]) != "" {
+		return "", nil, &SpecError{Offset: len(layer), Msg: "missing closing )"}
+	}
+
+	name = strings.TrimSpace(layer[:open])
+	depth, start := 0, open+1
+
+	addArg := func(stop int) {
+		text := layer[start:stop]
+		lead := len(text) - len(strings.TrimLeft(text, " \n\t"))
+		args = append(args, specArg{text: strings.TrimSpace(text), offset: start + lead})
+	}
+
+	for ind := open + 1; ind < end; ind++ {
+		switch ch := rune(layer[ind]); {
+		case ch == '(' || ch == '[':
+			depth++
+		case ch == ')' || ch == ']':
+			if depth--; depth < 0 {
+				return "", nil, &SpecError{Offset: ind, Msg: fmt.Sprintf("unexpected %c", ch)}
+			}
+		case ch == sep && depth == 0:
+			addArg(ind)
+			start = ind + 1
+		}
+	}
+
+	if depth != 0 {
+		return "", nil, &SpecError{Offset: end, Msg: "unbalanced parentheses or brackets"}
+	}
+
+	addArg(end)
+
+	return name, args, nil
+}
+
+// layerType returns the Layer named name or nil if there is no such Layer.  Unlike LType, it doesn't require
+// the whole ModSpec to be valid.
+func layerType(name string) *Layer {
+	for ind := range _Layer_index[:len(_Layer_index)-1] {
+		if lay := Layer(ind); strings.EqualFold(name, lay.String()) {
+			return &lay
+		}
+	}
+
+	return nil
+}
+
+// layerType returns the Layer of layer i or nil if it can't be determined
+func (m ModSpec) layerType(i int) *Layer {
+	name, _, bad := specArgs(m[i], ',')
+	if bad != nil {
+		return nil
+	}
+
+	return layerType(name)
+}
+
+// Validate checks the ModSpec for building a model with pipe.  Unlike NewNNModel, it doesn't stop at the first
+// problem.  It checks:
+//   - the layer names and the syntax of their arguments;
+//   - the values of the arguments: sizes are positive, activations are known, probabilities are in (0, 1);
+//   - the Input fields are in pipe and have legal roles;
+//   - the Target, Offset and Output fields are in pipe and have legal roles;
+//   - the layer order: Input is first, sequence layers follow Input, InputDropout and GaussianNoise precede the FC
+//     layers, Target is last and Output layers are at the end;
+//   - the output of the model matches the target, including softmax activations.
+//
+// The return is nil or SpecErrors, which gives the layer and character offset of each problem.
+func (m ModSpec) Validate(pipe Pipeline) error {
+	var errs SpecErrors
+
+	add := func(layer, offset int, format string, a ...any) {
+		errs = append(errs, &SpecError{Layer: layer, Offset: offset, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	if len(m) == 0 {
+		add(0, 0, "empty ModSpec")
+		return errs
+	}
+
+	// the target columns implied by the last FC layer
+	lastFC, lastAct := -1, Linear
+	hasOutput, hasTarget := false, false
+	hidden := false // true once an FC or Output layer is reached
+
+	for ind, layer := range m {
+		name, args, bad := specArgs(layer, ',')
+		if bad != nil {
+			add(ind, bad.Offset, bad.Msg)
+			continue
+		}
+
+		lt := layerType(name)
+		if lt == nil {
+			add(ind, 0, "unknown layer %s", name)
+			continue
+		}
+
+		argsAt := strings.Index(layer, "(") + 1
+
+		if (ind == 0) != (*lt == Input) {
+			add(ind, 0, "the Input layer must be the first layer and only the first layer")
+		}
+
+		if hasOutput && *lt != Output && *lt != Offset {
+			add(ind, 0, "%s layer follows an Output layer", lt)
+		}
+
+		switch *lt {
+		case Input:
+			m.validateInput(ind, pipe, add)
+		case FC:
+			fc := validateFC(ind, args, add)
+			if fc != nil {
+				lastFC, lastAct = fc.Size, fc.Act
+			}
+		case DropOut, InputDropout:
+			p, e := strconv.ParseFloat(args[0].text, 64)
+			if len(args) != 1 || e != nil || p <= 0.0 || p >= 1.0 {
+				add(ind, args[0].offset, "%s probability must be a number in (0, 1)", lt)
+			}
+		case GaussianNoise:
+			sigma, e := strconv.ParseFloat(args[0].text, 64)
+			if len(args) != 1 || e != nil || sigma <= 0.0 {
+				add(ind, args[0].offset, "GaussianNoise standard deviation must be a positive number")
+			}
+		case Target:
+			hasTarget = true
+
+			if ind != len(m)-1 {
+				add(ind, 0, "the Target layer must be the last layer")
+			}
+
+			if len(args) > 2 {
+				add(ind, args[2].offset, "Target takes a target and an optional exposure field")
+			}
+
+			ft := validateField(ind, args[0], pipe, add, FRCts, FROneHot)
+			if ft != nil {
+				validateTargetCols(ind, ft, lastFC, lastAct, add)
+			}
+
+			if len(args) > 1 {
+				if exp := validateField(ind, args[1], pipe, add, FRCts); exp != nil && exp.Normalized {
+					add(ind, args[1].offset, "exposure %s must not be normalized", exp.Name)
+				}
+			}
+		case Offset:
+			if len(args) != 1 {
+				add(ind, argsAt, "Offset takes one field")
+				continue
+			}
+
+			if off := validateField(ind, args[0], pipe, add, FRCts); off != nil && off.Normalized {
+				add(ind, args[0].offset, "offset %s must not be normalized", off.Name)
+			}
+		case Output:
+			hasOutput = true
+
+			out, e := OutputParse(layer)
+			if e != nil {
+				add(ind, argsAt, "%v", e)
+				continue
+			}
+
+			ft := pipe.GetFType(out.Target)
+			if ft == nil {
+				add(ind, argsAt+strings.Index(layer[argsAt:], out.Target), "target %s not found", out.Target)
+				continue
+			}
+
+			switch {
+			case ft.Role != FRCts && ft.Role != FROneHot:
+				add(ind, argsAt, "target %s must be FRCts or FROneHot", ft.Name)
+			case out.Quantiles != nil && ft.Role != FRCts:
+				add(ind, argsAt, "quantile target %s must be FRCts", ft.Name)
+			case out.Quantiles == nil:
+				validateTargetCols(ind, ft, out.Size, out.Act, add)
+			}
+		case GRU, LSTM:
+			if _, e := RNNParse(layer); e != nil {
+				add(ind, argsAt, "%v", e)
+			}
+		case Conv1D:
+			if _, e := ConvParse(layer); e != nil {
+				add(ind, argsAt, "%v", e)
+			}
+		case MaxPool, AvgPool:
+			if _, e := PoolParse(layer); e != nil {
+				add(ind, argsAt, "%v", e)
+			}
+		case Attention:
+			if _, e := AttnParse(layer); e != nil {
+				add(ind, argsAt, "%v", e)
+			}
+		}
+
+		switch *lt {
+		case FC, Output:
+			hidden = true
+		case InputDropout, GaussianNoise:
+			if hidden {
+				add(ind, 0, "%s layer must precede the FC and Output layers", lt)
+			}
+		}
+
+		if isSeqLayer(*lt) {
+			for prev := 1; prev < ind; prev++ {
+				if l := m.layerType(prev); l != nil && !isSeqLayer(*l) {
+					add(ind, 0, "sequence layer %s must follow the Input layer or another sequence layer", lt)
+					break
+				}
+			}
+		}
+	}
+
+	if !hasOutput && !hasTarget {
+		add(len(m)-1, 0, "the last layer must be Target or Output")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// validateInput checks the fields of the Input layer ind
+func (m ModSpec) validateInput(ind int, pipe Pipeline, add func(layer, offset int, format string, a ...any)) {
+	feats, grouped := m.inputArgs(ind, add)
+	hasSeq := false
+	embWidths := make(map[int]bool) // columns of the embedded inputs
+
+	for _, feat := range feats {
+		field, embCols := strings.ReplaceAll(feat.text, " ", ""), 0
+
+		if isEmbed(field) {
+			var e error
+			if field, embCols, _, e = parseEmbed(field); e != nil {
+				add(ind, feat.offset, "bad embedding %s: %v", feat.text, e)
+				continue
+			}
+		}
+
+		ft := pipe.GetFType(field)
+
+		if ft == nil {
+			factors, ok := parseTerm(field)
+			if !ok {
+				add(ind, feat.offset, "feature %s not found", field)
+				continue
+			}
+
+			// terms are added by NewNNModel
+			for _, f := range factors {
+				if fft := pipe.GetFType(f.field); fft == nil || fft.Role != FRCts {
+					add(ind, feat.offset, "term %s: %s is not an FRCts feature", field, f.field)
+				}
+			}
+
+			continue
+		}
+
+		switch {
+		case ft.Role == FRCat && embCols == 0:
+			add(ind, feat.offset, "feature %s is categorical--must convert to one-hot or embed", field)
+		case embCols > 0 && ft.Role != FROneHot && ft.Role != FREmbed && ft.Role != FRCat:
+			add(ind, feat.offset, "embedded feature %s must be one-hot or categorical", field)
+		case ft.Role == FROrdinal && embCols == 0:
+			add(ind, feat.offset, "feature %s is ordinal--must convert to one-hot or embed", field)
+		case ft.Role == FRDate:
+			add(ind, feat.offset, "feature %s is a date--cannot be a model input", field)
+		case ft.Role == FRSeq:
+			hasSeq = true
+		}
+
+		if ft.Role == FREmbed && embCols == 0 {
+			embCols = ft.EmbCols
+		}
+
+		if embCols > 0 {
+			embWidths[embCols] = true
+		}
+	}
+
+	nSeq := 0
+	for l := 1; l < len(m); l++ {
+		if lt := m.layerType(l); lt != nil && isSeqLayer(*lt) {
+			nSeq++
+		}
+	}
+
+	if hasSeq && grouped {
+		add(ind, 0, "sequence inputs cannot be grouped")
+	}
+
+	if hasSeq && nSeq == 0 {
+		add(ind, 0, "sequence inputs require a sequence layer")
+	}
+
+	// without sequence inputs, the embedded inputs are the steps of the sequence
+	switch {
+	case !hasSeq && nSeq > 0 && len(embWidths) == 0:
+		add(ind, 0, "sequence layers require a sequence or embedded input")
+	case !hasSeq && nSeq > 0 && grouped:
+		add(ind, 0, "embedded inputs of sequence layers cannot be grouped")
+	case !hasSeq && nSeq > 0 && len(embWidths) > 1:
+		add(ind, 0, "embedded inputs of sequence layers have differing columns")
+	}
+}
+
+// inputArgs returns the features of the Input layer ind.  grouped is true if the inputs are grouped
+// (see InputGroups).
+func (m ModSpec) inputArgs(ind int, add func(layer, offset int, format string, a ...any)) (feats []specArg, grouped bool) {
+	_, groups, _ := specArgs(m[ind], ',')
+	seen := make(map[string]bool)
+
+	for _, group := range groups {
+		name, _, ok := parseGroup(strings.ReplaceAll(group.text, " ", ""))
+		if !ok {
+			if len(groups) > 1 {
+				add(ind, group.offset, "expected group:(features), got %s", group.text)
+			}
+
+			continue
+		}
+
+		if seen[name] {
+			add(ind, group.offset, "input group %s is repeated", name)
+		}
+
+		seen[name] = true
+
+		_, gFeats, _ := specArgs(group.text, '+')
+		for _, feat := range gFeats {
+			feat.offset += group.offset
+			feats = append(feats, feat)
+		}
+	}
+
+	if len(seen) > 0 {
+		return feats, true
+	}
+
+	_, feats, _ = specArgs(m[ind], '+')
+
+	return feats, false
+}
+
+// validateFC checks the arguments of the FC layer ind
+func validateFC(ind int, args []specArg, add func(layer, offset int, format string, a ...any)) *FCLayer {
+	fc := &FCLayer{Act: Linear, Bias: true}
+	ok := true
+
+	for _, arg := range args {
+		key, val, found := strings.Cut(arg.text, ":")
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+
+		if !found {
+			add(ind, arg.offset, "expected key:value, got %s", arg.text)
+			ok = false
+
+			continue
+		}
+
+		switch key {
+		case "size":
+			size, e := strconv.Atoi(val)
+			if e != nil || size < 1 {
+				add(ind, arg.offset, "size must be a positive integer")
+				ok = false
+			}
+
+			fc.Size = size
+		case "activation":
+			act, parm := StrAct(val)
+			if act == nil {
+				add(ind, arg.offset, "unknown activation %s", val)
+				ok = false
+
+				continue
+			}
+
+			fc.Act, fc.ActParm = *act, parm
+		case "bias":
+			b, e := strconv.ParseBool(val)
+			if e != nil {
+				add(ind, arg.offset, "bias must be true or false")
+				ok = false
+			}
+
+			fc.Bias = b
+		default:
+			add(ind, arg.offset, "unknown argument %s", key)
+			ok = false
+		}
+	}
+
+	if fc.Size == 0 && ok {
+		add(ind, 0, "FC layer has no size")
+		ok = false
+	}
+
+	if !ok {
+		return nil
+	}
+
+	return fc
+}
+
+// validateField checks that the field arg is in pipe and has one of roles
+func validateField(ind int, arg specArg, pipe Pipeline, add func(layer, offset int, format string, a ...any),
+	roles ...FRole) *FType {
+	ft := pipe.GetFType(arg.text)
+	if ft == nil {
+		add(ind, arg.offset, "field %s not found", arg.text)
+		return nil
+	}
+
+	for _, r := range roles {
+		if ft.Role == r {
+			return ft
+		}
+	}
+
+	add(ind, arg.offset, "field %s has role %v", arg.text, ft.Role)
+
+	return nil
+}
+
+// validateTargetCols checks that an output of size columns with activation act matches the target ft.  size < 0
+// means the output is not known.
+func validateTargetCols(ind int, ft *FType, size int, act Activation, add func(layer, offset int, format string, a ...any)) {
+	if act == SoftMax && ft.Role != FROneHot {
+		add(ind, 0, "softmax activation requires a one-hot target, %s is %v", ft.Name, ft.Role)
+		return
+	}
+
+	cols := 1
+	if ft.Role == FROneHot {
+		cols = ft.Cats
+	}
+
+	if size >= 0 && size != cols {
+		add(ind, 0, "the output has %d columns, target %s has %d", size, ft.Name, cols)
+	}
+}
@@ -0,0 +1,64 @@
+package seafan
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModSpec_Validate(t *testing.T) {
+	Verbose = false
+	pipe := chPipe(100, "test1.csv")
+
+	good := []ModSpec{
+		{"Input(x1+x2+x3)", "FC(size:3, activation:leakyrelu(0.1))", "Dropout(.1)", "FC(size:1)", "Target(ycts)"},
+		{"Input(x1+x2+E(y1oh,2))", "FC(size:2, activation:softmax)", "Target(yoh)"},
+		{"Input(x1+x1*x2)", "FC(size:1, activation:sigmoid)", "Target(ycts)"},
+		{"Input(x1+x2)", "FC(size:4, activation:relu)",
+			"Output(target:ycts, size:1)", "Output(target:yoh, size:2, activation:softmax)"},
+	}
+
+	for _, mod := range good {
+		assert.Nil(t, mod.Validate(pipe), mod)
+	}
+
+	// expected layer and offset of each problem
+	type loc struct{ layer, offset int }
+
+	bad := []struct {
+		mod ModSpec
+		exp []loc
+	}{
+		{ModSpec{"Input(x1+x9)", "FC(size:1)", "Target(ycts)"}, []loc{{0, 9}}},
+		{ModSpec{"Input(x1+y)", "FC(size:1)", "Target(ycts)"}, []loc{{0, 9}}},
+		{ModSpec{"Input(x1)", "FC(size:0)", "Target(ycts)"}, []loc{{1, 3}}},
+		{ModSpec{"Input(x1)", "FC(size:1, activation:tanhh)", "Target(ycts)"}, []loc{{1, 11}}},
+		{ModSpec{"Input(x1)", "FC(size:1, colour:red)", "Target(ycts)"}, []loc{{1, 11}}},
+		{ModSpec{"Input(x1)", "FC(size:1", "Target(ycts)"}, []loc{{1, 9}}},
+		{ModSpec{"Input(x1)", "Dropout(1.5)", "FC(size:1)", "Target(ycts)"}, []loc{{1, 8}}},
+		{ModSpec{"Input(x1)", "FCC(size:1)", "Target(ycts)"}, []loc{{1, 0}}},
+		{ModSpec{"Input(x1)", "FC(size:1, activation:softmax)", "Target(ycts)"}, []loc{{2, 0}}},
+		{ModSpec{"Input(x1)", "FC(size:3, activation:softmax)", "Target(yoh)"}, []loc{{2, 0}}},
+		{ModSpec{"Input(x1)", "FC(size:1)", "Target(yy)"}, []loc{{2, 7}}},
+		{ModSpec{"Input(x1)", "GRU(size:2)", "FC(size:1)", "Target(ycts)"}, []loc{{0, 0}}},
+		{ModSpec{"Input(x1)", "FC(size:1)"}, []loc{{1, 0}}},
+		{ModSpec{"Input(x1+x9)", "FC(size:-1)", "Target(yy)"}, []loc{{0, 9}, {1, 3}, {2, 7}}},
+	}
+
+	for _, b := range bad {
+		err := b.mod.Validate(pipe)
+		assert.NotNil(t, err, b.mod)
+
+		var ses SpecErrors
+		assert.True(t, errors.As(err, &ses))
+		assert.True(t, errors.Is(err, ErrModSpec))
+
+		got := make([]loc, len(ses))
+		for ind, se := range ses {
+			got[ind] = loc{se.Layer, se.Offset}
+		}
+
+		assert.Equal(t, b.exp, got, err.Error())
+	}
+}
@@ -2,22 +2,25 @@ package seafan
 
 import (
 	"fmt"
+	"math/rand"
 	"reflect"
 
 	G "gorgonia.org/gorgonia"
-	"gorgonia.org/tensor"
 )
 
 type VecData struct {
-	bs         int    // batch size
-	cbRow      int    // current batch starting row
-	nRow       int    // # rows in dataset
-	data       *GData // processed data
-	epochCount int    // current epoch
-	ftypes     FTypes // user input selections
-	callback   Opts   // user callbacks executed at the start of Init()
-	keepRaw    bool   // if true, *Raw data is retained
-	name       string // pipeline name
+	bs         int           // batch size
+	cbRow      int           // current batch starting row
+	nRow       int           // # rows in dataset
+	data       *GData        // processed data
+	epochCount int           // current epoch
+	ftypes     FTypes        // user input selections
+	callback   Opts          // user callbacks executed at the start of Init()
+	keepRaw    bool          // if true, *Raw data is retained
+	name       string        // pipeline name
+	rnd        *rand.Rand    // source for Shuffle (package source if nil)
+	buffers    batchBuffers  // tensors Batch copies into, nil if batches use the data directly
+	derived    DerivedFields // fields calculated on Init and after each callback
 }
 
 func NewVecData(name string, data *GData, opts ...Opts) *VecData {
@@ -93,6 +96,18 @@ func (vec *VecData) Init() error {
 		vec.bs = vec.Rows()
 	}
 
+	for _, ft := range vec.ftypes {
+		if e := vec.data.keepFloat32(ft); e != nil {
+			return Wrapper(e, "(*VecData).Init")
+		}
+	}
+
+	if vec.derived != nil {
+		if e := vec.derived.Apply(vec.data, vec.ftypes, vec.keepRaw); e != nil {
+			return Wrapper(e, "(*VecData).Init")
+		}
+	}
+
 	return nil
 }
 
@@ -103,6 +118,13 @@ func (vec *VecData) Batch(inputs G.Nodes) bool {
 		// user callbacks
 		if vec.callback != nil {
 			vec.callback(vec)
+
+			// the callback may have changed the data the derived fields use
+			if vec.derived != nil {
+				if e := vec.derived.Apply(vec.data, vec.ftypes, vec.keepRaw); e != nil {
+					panic(e)
+				}
+			}
 		}
 		return false
 	}
@@ -111,26 +133,13 @@ func (vec *VecData) Batch(inputs G.Nodes) bool {
 	endRow := startRow + vec.bs
 
 	for _, nd := range inputs {
-		var t tensor.Tensor
-
 		d := vec.data.Get(nd.Name())
 
 		if d == nil {
 			panic(Wrapper(ErrVecData, fmt.Sprintf("feature %s not in dataset", nd.Name())))
 		}
 
-		switch d.FT.Role {
-		case FRCts:
-			t = tensor.New(tensor.WithBacking(d.Data.([]float64)[startRow:endRow]), tensor.WithShape(vec.bs, 1))
-		case FRCat:
-			t = tensor.New(tensor.WithBacking(d.Data.([]int32)[startRow:endRow]), tensor.WithShape(vec.bs, 1))
-		case FROneHot, FREmbed:
-			sr := startRow * d.FT.Cats
-			er := endRow * d.FT.Cats
-			t = tensor.New(tensor.WithBacking(d.Data.([]float64)[sr:er]), tensor.WithShape(vec.bs, d.FT.Cats))
-		}
-
-		if e := G.Let(nd, t); e != nil {
+		if e := letBatch(nd, d, startRow, endRow, vec.buffers); e != nil {
 			panic(e)
 		}
 	}
@@ -209,9 +218,9 @@ func (vec *VecData) Cols(field string) int {
 	switch d.FT.Role {
 	case FRCts:
 		return 1
-	case FRCat:
+	case FRCat, FRDate, FROrdinal:
 		return 1
-	case FROneHot, FREmbed:
+	case FROneHot, FREmbed, FRSeq:
 		return d.FT.Cats
 	}
 
@@ -282,7 +291,7 @@ func (vec *VecData) String() string {
 
 // Shuffle shuffles the data.
 func (vec *VecData) Shuffle() {
-	vec.data.Shuffle()
+	vec.data.shuffle(vec.rnd)
 }
 
 // Sort sorts the data on "field".
@@ -351,6 +360,69 @@ func (vec *VecData) Where(field string, equalTo []any) (newPipe Pipeline, err er
 	return newPipe, nil
 }
 
+// WhereExpr creates a new pipeline with rows where the expression expr evaluates to a positive value.
+func (vec *VecData) WhereExpr(expr string) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = vec.GData().WhereExpr(expr); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(vec.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// SampleN creates a new pipeline with a simple random sample of n rows. See GData.SampleN.
+func (vec *VecData) SampleN(n int, seed int64) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = vec.GData().SampleN(n, seed); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(vec.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// SampleFrac creates a new pipeline with a simple random sample of the fraction f of the rows.
+func (vec *VecData) SampleFrac(f float64, seed int64) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = vec.GData().SampleFrac(f, seed); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(vec.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// StratifiedSample creates a new pipeline with a random sample of perLevel rows for each level of field. The sample is
+// reproducible for a given seed.
+func (vec *VecData) StratifiedSample(field string, perLevel int, seed int64) (newPipe Pipeline, err error) {
+	var gdNew *GData
+
+	if gdNew, err = vec.GData().StratifiedSample(field, perLevel, seed); err != nil {
+		return nil, err
+	}
+
+	newPipe = NewVecData("new pipe", gdNew)
+	WithKeepRaw(vec.keepRaw)(newPipe)
+
+	return newPipe, nil
+}
+
+// Compact rebuilds the data of the pipeline to use only the memory it needs. The *Raw data is dropped unless
+// the pipeline keeps it. Returns an estimate of the bytes reclaimed.
+func (vec *VecData) Compact() int {
+	return vec.GData().Compact(vec.keepRaw)
+}
+
 // Keep keeps only the listed fields in the pipeline
 func (vec *VecData) Keep(fields []string) error {
 	return vec.GData().Keep(fields)
@@ -362,8 +434,8 @@ func (vec *VecData) Drop(field string) error {
 }
 
 // AppendRows appends rows to the existing GData and then re-initializes each GDatum, using the fTypes, if provided.
-func (vec *VecData) AppendRows(gd *GData, fTypes FTypes) (pipeOut Pipeline, err error) {
-	gdOut, e := vec.GData().AppendRows(gd, fTypes)
+func (vec *VecData) AppendRows(gd *GData, fTypes FTypes, opts ...AppendOpts) (pipeOut Pipeline, err error) {
+	gdOut, e := vec.GData().AppendRows(gd, fTypes, opts...)
 	if e != nil {
 		return nil, e
 	}
@@ -1,6 +1,7 @@
 package seafan
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,6 +55,140 @@ func TestVecData_Batch(t *testing.T) {
 	}
 }
 
+func TestVecData_BatchBuffers(t *testing.T) {
+	vecData := NewVecData("test", getData(t), WithBatchSize(2), WithBatchBuffers(true))
+	g := G.NewGraph()
+	nd := G.NewTensor(g, G.Float64, 2, G.WithName("x1"), G.WithShape(2, 1))
+	ndOh := G.NewTensor(g, G.Float64, 2, G.WithName("x2Oh"), G.WithShape(2, 3))
+	nds := G.Nodes{nd, ndOh}
+	assert.Nil(t, vecData.Init())
+
+	var first any
+
+	act, actOh := make([]float64, 0), make([]float64, 0)
+	for vecData.Batch(nds) {
+		// the same tensor holds each batch
+		if first == nil {
+			first = nd.Value()
+		}
+
+		assert.True(t, first == nd.Value())
+
+		act = append(act, nd.Value().Data().([]float64)...)
+		actOh = append(actOh, ndOh.Value().Data().([]float64)...)
+	}
+
+	x1 := vecData.Get("x1").Data.([]float64)
+	assert.Equal(t, x1[:6], act)
+
+	oh := vecData.Get("x2Oh").Data.([]float64)
+	assert.Equal(t, oh[:18], actOh)
+
+	// the data are copied, not shared
+	nd.Value().Data().([]float64)[0] = -1
+	assert.Equal(t, 1.0, x1[0])
+}
+
+func TestNewCursor(t *testing.T) {
+	pipe := NewVecData("test", getData(t), WithBatchSize(2))
+	x1 := pipe.Get("x1").Data.([]float64)
+
+	acts := make([][]float64, 4)
+
+	var wg sync.WaitGroup
+	for ind := range acts {
+		cur, e := NewCursor(pipe)
+		assert.Nil(t, e)
+		assert.Equal(t, 2, cur.BatchSize())
+
+		wg.Add(1)
+
+		go func(ind int) {
+			defer wg.Done()
+
+			g := G.NewGraph()
+			nd := G.NewTensor(g, G.Float64, 2, G.WithName("x1"), G.WithShape(2, 1))
+
+			for cur.Batch(G.Nodes{nd}) {
+				acts[ind] = append(acts[ind], nd.Value().Data().([]float64)...)
+			}
+		}(ind)
+	}
+
+	wg.Wait()
+
+	for _, act := range acts {
+		assert.Equal(t, x1[:6], act)
+	}
+}
+
+// TestNewCursor_Freeze creates cursors and readers from several goroutines at once, while the *Raw data are still
+// to be filled in.  Run with -race.
+func TestNewCursor_Freeze(t *testing.T) {
+	x1 := []float64{1, 2, 3, 4, 8, 9, 10}
+	x2 := []string{"a", "b", "c", "a", "a", "a", "a"}
+
+	gd := NewGData()
+	assert.Nil(t, gd.AppendC(NewRawCast(x1, nil), "x1", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRawCast(x2, nil), "x2", nil, false))
+
+	pipe := NewVecData("test", gd, WithBatchSize(2))
+
+	const n = 8
+
+	errs := make([]error, n)
+	rows := make([]int, n)
+
+	var wg sync.WaitGroup
+	for ind := 0; ind < n; ind++ {
+		wg.Add(1)
+
+		go func(ind int) {
+			defer wg.Done()
+
+			if ind%2 == 1 {
+				rdr, e := pipe.GData().NewReader()
+				if errs[ind] = e; e != nil {
+					return
+				}
+
+				data, _, _ := rdr.Read(100, false)
+				rows[ind] = len(data)
+
+				return
+			}
+
+			cur, e := NewCursor(pipe)
+			if errs[ind] = e; e != nil {
+				return
+			}
+
+			g := G.NewGraph()
+			nd := G.NewTensor(g, G.Float64, 2, G.WithName("x1"), G.WithShape(2, 1))
+
+			for cur.Batch(G.Nodes{nd}) {
+				rows[ind] += 2
+			}
+		}(ind)
+	}
+
+	wg.Wait()
+
+	for ind := 0; ind < n; ind++ {
+		assert.Nil(t, errs[ind])
+
+		if ind%2 == 1 {
+			assert.Equal(t, len(x1), rows[ind])
+			continue
+		}
+
+		assert.Equal(t, 6, rows[ind])
+	}
+
+	assert.NotNil(t, gd.Get("x2").Raw)
+	assert.Equal(t, "b", gd.Get("x2").Raw.Data[1])
+}
+
 func TestVecData_Row(t *testing.T) {
 	gData := getData(t)
 	vecData := NewVecData("test", gData)
@@ -80,6 +215,83 @@ func TestVecData_Where(t *testing.T) {
 	assert.ElementsMatch(t, x2.Data, equalTo)
 }
 
+func TestVecData_WhereExpr(t *testing.T) {
+	gData := getData(t)
+	vecData := NewVecData("test", gData)
+
+	newPipe, e := vecData.WhereExpr("x1 >= 3 && x2 == 'a'")
+	assert.Nil(t, e)
+
+	x1, e := newPipe.GData().GetRaw("x1")
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, x1.Data, []any{4.0, 8.0, 9.0, 10.0})
+
+	_, e = vecData.WhereExpr("x1 > 100")
+	assert.NotNil(t, e)
+}
+
+func TestVecData_Sample(t *testing.T) {
+	vecData := NewVecData("test", getData(t))
+
+	s1, e := vecData.SampleN(3, 42)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, s1.Rows())
+
+	// same seed, same sample
+	s2, e := vecData.SampleN(3, 42)
+	assert.Nil(t, e)
+	assert.Equal(t, s1.Get("x1").Data, s2.Get("x1").Data)
+
+	// rows stay in order
+	x1 := s1.Get("x1").Data.([]float64)
+	assert.True(t, x1[0] < x1[1] && x1[1] < x1[2])
+
+	s3, e := vecData.SampleN(100, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, 7, s3.Rows())
+
+	s4, e := vecData.SampleFrac(0.5, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, 4, s4.Rows())
+
+	_, e = vecData.SampleFrac(1.5, 1)
+	assert.NotNil(t, e)
+
+	s5, e := vecData.StratifiedSample("x2", 2, 1)
+	assert.Nil(t, e)
+
+	x2, e := s5.GData().GetRaw("x2")
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, []any{"a", "a", "b", "c"}, x2.Data)
+
+	// the sample does not depend on the package random number generator
+	SetSeed(99)
+	s6, e := vecData.StratifiedSample("x2", 2, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, s5.Get("x1").Data, s6.Get("x1").Data)
+}
+
+func TestWithSeed(t *testing.T) {
+	x1 := make([]float64, 100)
+	for ind := range x1 {
+		x1[ind] = float64(ind)
+	}
+
+	shuffled := func() []float64 {
+		gd := NewGData()
+		assert.Nil(t, gd.AppendC(NewRawCast(x1, nil), "x1", false, nil, false))
+
+		pipe := NewVecData("test", gd, WithSeed(3))
+		pipe.Shuffle()
+
+		return pipe.Get("x1").Data.([]float64)
+	}
+
+	s1 := shuffled()
+	assert.Equal(t, s1, shuffled())
+	assert.NotEqual(t, x1, s1)
+}
+
 func TestSliceVecData(t *testing.T) {
 	vecData := NewVecData("test", getData(t))
 	slice, e := NewSlice("x2", 0, vecData, nil)
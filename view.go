@@ -0,0 +1,127 @@
+package seafan
+
+// view.go implements read-only views of GData that subset rows without copying the data
+
+import "fmt"
+
+// GDataView is a read-only subset of the rows of a GData. The data is not copied--the view holds the indices of
+// the rows of the underlying GData. Views are useful for diagnostics and slicing, where many subsets of a large
+// GData are needed.
+//
+// The view reflects the GData at the time it was created.  Operations that change the order or number of rows of
+// the GData (e.g. Sort, Shuffle, AppendRows) invalidate the view.
+type GDataView struct {
+	gd   *GData // underlying data
+	rows []int  // rows of gd in the view
+	in   []bool // in[row] is true if row of gd is in the view
+}
+
+// View returns a view of gd restricted to rows.
+func (gd *GData) View(rows []int) (*GDataView, error) {
+	in := make([]bool, gd.Rows())
+	keep := make([]int, len(rows))
+
+	for ind, row := range rows {
+		if row < 0 || row >= gd.Rows() {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("View: index out of range: %d to GData of %d rows", row, gd.Rows()))
+		}
+
+		keep[ind] = row
+		in[row] = true
+	}
+
+	return &GDataView{gd: gd, rows: keep, in: in}, nil
+}
+
+// ViewSlice returns a view of gd restricted to the rows for which sl is true.
+func (gd *GData) ViewSlice(sl Slicer) *GDataView {
+	in := make([]bool, gd.Rows())
+	rows := make([]int, 0)
+
+	for row := 0; row < gd.Rows(); row++ {
+		if sl == nil || sl(row) {
+			rows = append(rows, row)
+			in[row] = true
+		}
+	}
+
+	return &GDataView{gd: gd, rows: rows, in: in}
+}
+
+// GData returns the underlying *GData
+func (v *GDataView) GData() *GData {
+	return v.gd
+}
+
+// Rows returns the number of rows in the view
+func (v *GDataView) Rows() int {
+	return len(v.rows)
+}
+
+// FieldList returns the names of the fields
+func (v *GDataView) FieldList() []string {
+	return v.gd.FieldList()
+}
+
+// Row returns the row of the underlying GData that is row ind of the view
+func (v *GDataView) Row(ind int) int {
+	return v.rows[ind]
+}
+
+// Value returns the value of field at row ind of the view. The value is a float64 for FRCts fields and an int32 for
+// FRCat fields.
+func (v *GDataView) Value(field string, ind int) (any, error) {
+	d := v.gd.Get(field)
+	if d == nil {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("View: field %s not found", field))
+	}
+
+	if ind < 0 || ind >= v.Rows() {
+		return nil, Wrapper(ErrGData, fmt.Sprintf("View: index out of range: %d to view of %d rows", ind, v.Rows()))
+	}
+
+	switch x := d.Data.(type) {
+	case []float64:
+		if d.FT.Role == FRCts {
+			return x[v.rows[ind]], nil
+		}
+	case []float32:
+		return float64(x[v.rows[ind]]), nil
+	case []int32:
+		return x[v.rows[ind]], nil
+	}
+
+	return nil, Wrapper(ErrGData, fmt.Sprintf("View: field %s is not FRCts or FRCat", field))
+}
+
+// Slicer returns a Slicer on the underlying GData which is true for rows in the view. This can be passed to
+// the diagnostic functions (e.g. KS, SegPlot) to restrict them to the view.
+func (v *GDataView) Slicer() Slicer {
+	return func(row int) bool {
+		return row >= 0 && row < len(v.in) && v.in[row]
+	}
+}
+
+// View returns a view restricted to rows of v.
+func (v *GDataView) View(rows []int) (*GDataView, error) {
+	gdRows := make([]int, len(rows))
+
+	for ind, row := range rows {
+		if row < 0 || row >= v.Rows() {
+			return nil, Wrapper(ErrGData, fmt.Sprintf("View: index out of range: %d to view of %d rows", row, v.Rows()))
+		}
+
+		gdRows[ind] = v.rows[row]
+	}
+
+	return v.gd.View(gdRows)
+}
+
+// Materialize copies the rows of the view into a new *GData.
+func (v *GDataView) Materialize() (*GData, error) {
+	if v.Rows() == 0 {
+		return nil, Wrapper(ErrGData, "Materialize: view has no rows")
+	}
+
+	return v.gd.Subset(v.rows)
+}
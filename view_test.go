@@ -0,0 +1,55 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGData_View(t *testing.T) {
+	gd := getData(t)
+
+	v, e := gd.View([]int{1, 3, 5})
+	assert.Nil(t, e)
+	assert.Equal(t, 3, v.Rows())
+	assert.Equal(t, 3, v.Row(1))
+
+	val, e := v.Value("x1", 2)
+	assert.Nil(t, e)
+	assert.Equal(t, 9.0, val)
+
+	_, e = v.Value("x2Oh", 0)
+	assert.NotNil(t, e)
+
+	sl := v.Slicer()
+	assert.True(t, sl(3))
+	assert.False(t, sl(0))
+
+	v2, e := v.View([]int{0, 2})
+	assert.Nil(t, e)
+	assert.Equal(t, 5, v2.Row(1))
+
+	gdNew, e := v2.Materialize()
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, []float64{2, 9}, gdNew.Get("x1").Data)
+
+	_, e = gd.View([]int{100})
+	assert.NotNil(t, e)
+}
+
+func TestGData_ViewSlice(t *testing.T) {
+	gd := getData(t)
+	pipe := NewVecData("test", gd)
+
+	sl, e := NewSlicerExpr("x2 == 'a'", pipe)
+	assert.Nil(t, e)
+
+	v := gd.ViewSlice(sl)
+	assert.Equal(t, 5, v.Rows())
+
+	for ind := 0; ind < v.Rows(); ind++ {
+		val, e := v.Value("x2", ind)
+		assert.Nil(t, e)
+		assert.Equal(t, gd.GetFType("x2").FP.Lvl["a"], val)
+	}
+}
@@ -0,0 +1,278 @@
+package seafan
+
+// viz.go renders the architecture of an NNModel as a diagram
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// Visualize renders the architecture of the model to path.  The diagram shows the inputs, embeddings and layers with
+// their output shapes and parameter counts.  The format is determined by the extension of path:
+//   - .dot  Graphviz DOT source
+//   - .svg  SVG.  This requires the Graphviz program dot to be on the PATH.
+func (m *NNModel) Visualize(path string) error {
+	if m.construct == nil {
+		return Wrapper(ErrNNModel, "Visualize: no model")
+	}
+
+	src := m.dot()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot":
+		return os.WriteFile(path, []byte(src), 0644)
+	case ".svg":
+		cmd := exec.Command("dot", "-Tsvg", "-o", path)
+		cmd.Stdin = strings.NewReader(src)
+
+		var stdErr bytes.Buffer
+		cmd.Stderr = &stdErr
+
+		if e := cmd.Run(); e != nil {
+			return Wrapper(ErrNNModel, fmt.Sprintf("Visualize: dot failed: %v %s", e, stdErr.String()))
+		}
+
+		return nil
+	default:
+		return Wrapper(ErrNNModel, fmt.Sprintf("Visualize: unsupported file type %s", filepath.Ext(path)))
+	}
+}
+
+// dot returns the Graphviz DOT source for the model
+func (m *NNModel) dot() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %q {\n", m.Name())
+	b.WriteString("  rankdir=TB;\n  node [shape=record, fontname=\"Helvetica\"];\n")
+
+	bSize := 0
+	switch {
+	case len(m.inputsC) > 0:
+		bSize = m.inputsC[0].Shape()[0]
+	case len(m.inputsE) > 0:
+		bSize = m.inputsE[0].Shape()[0]
+	case len(m.inputsS) > 0:
+		bSize = m.inputsS[0].Shape()[0]
+	}
+
+	inCols := 0
+
+	for _, x := range m.inputsC {
+		fmt.Fprintf(&b, "  %q [label=\"{%s|input|%v}\"];\n", "in_"+x.Name(), x.Name(), x.Shape())
+		fmt.Fprintf(&b, "  %q -> \"concat\";\n", "in_"+x.Name())
+		inCols += x.Shape()[1]
+	}
+
+	for ind, x := range m.inputsE {
+		emb := m.paramsEmb[m.embOf[ind]]
+		fmt.Fprintf(&b, "  %q [label=\"{%s|input|%v}\"];\n", "in_"+x.Name(), x.Name(), x.Shape())
+		fmt.Fprintf(&b, "  %q [label=\"{%s|embedding|(%d, %d)|%d parameters}\"];\n",
+			emb.Name(), emb.Name(), bSize, emb.Shape()[1], emb.Shape().TotalSize())
+		fmt.Fprintf(&b, "  %q -> %q;\n", "in_"+x.Name(), emb.Name())
+
+		// the embeddings of a model without sequence inputs feed the sequence layers (see dotSeq)
+		if !m.embSeq {
+			fmt.Fprintf(&b, "  %q -> \"concat\";\n", emb.Name())
+			inCols += emb.Shape()[1]
+		}
+	}
+
+	inCols += m.dotSeq(&b, bSize)
+
+	fmt.Fprintf(&b, "  \"concat\" [label=\"{Input|(%d, %d)}\"];\n", bSize, inCols)
+
+	last := "concat"
+
+	for ind := 1; ind < len(m.construct); ind++ {
+		ltype, e := m.construct.LType(ind)
+		if e != nil {
+			continue
+		}
+
+		name := "layer" + strconv.Itoa(ind)
+
+		var label string
+
+		switch *ltype {
+		case FC:
+			fc := m.construct.FC(ind)
+			if fc == nil {
+				continue
+			}
+
+			nPar := 0
+			cols := 0
+
+			if w := GetNode(m.paramsW, "lWeights"+strconv.Itoa(ind)); w != nil {
+				nPar += w.Shape().TotalSize()
+				cols = w.Shape()[1]
+			}
+
+			if bias := GetNode(m.paramsB, "lBias"+strconv.Itoa(ind)); bias != nil {
+				nPar += bias.Shape().TotalSize()
+			}
+
+			// softmax adds a column to the output
+			if fc.Act == SoftMax {
+				cols++
+			}
+
+			label = fmt.Sprintf("{FC %d|%s|(%d, %d)|%d parameters}", ind, fc.Act, bSize, cols, nPar)
+		case DropOut:
+			do := m.construct.DropOut(ind)
+			if do == nil {
+				continue
+			}
+
+			label = fmt.Sprintf("{DropOut %d|p = %v}", ind, do.DropProb)
+		case InputDropout:
+			do := m.construct.InputDropout(ind)
+			if do == nil {
+				continue
+			}
+
+			label = fmt.Sprintf("{InputDropout %d|p = %v}", ind, do.DropProb)
+		case GaussianNoise:
+			gn := m.construct.GaussianNoise(ind)
+			if gn == nil {
+				continue
+			}
+
+			label = fmt.Sprintf("{GaussianNoise %d|sigma = %v}", ind, gn.Sigma)
+		case Output:
+			// the heads hang off the last hidden layer
+			out := m.construct.Output(ind)
+			if out == nil {
+				continue
+			}
+
+			nPar := 0
+			if w := GetNode(m.paramsW, "lWeights"+strconv.Itoa(ind)); w != nil {
+				nPar += w.Shape().TotalSize()
+			}
+
+			if bias := GetNode(m.paramsB, "lBias"+strconv.Itoa(ind)); bias != nil {
+				nPar += bias.Shape().TotalSize()
+			}
+
+			fmt.Fprintf(&b, "  %q [label=\"{Output %d|%s|(%d, %d)|%d parameters|weight %v}\"];\n  %q -> %q;\n",
+				name, ind, out.Act, bSize, out.Size, nPar, out.Weight, last, name)
+			fmt.Fprintf(&b, "  %q [shape=ellipse, label=\"%s\"];\n  %q -> %q;\n", "target_"+out.Target, out.Target,
+				name, "target_"+out.Target)
+
+			continue
+		default:
+			continue
+		}
+
+		fmt.Fprintf(&b, "  %q [label=\"%s\"];\n  %q -> %q;\n", name, label, last, name)
+		last = name
+	}
+
+	if m.offset != nil && m.heads == nil {
+		fmt.Fprintf(&b, "  \"offset\" [shape=ellipse, label=\"offset %s\"];\n  \"offset\" -> %q;\n", m.offset.Name(), last)
+	}
+
+	if m.targetFT != nil {
+		fmt.Fprintf(&b, "  \"target\" [shape=ellipse, label=\"%s\"];\n  %q -> \"target\";\n", m.targetFT.Name, last)
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// dotSeq writes the sequence inputs and the sequence layers, which feed the concat node, to b.  If there are no
+// sequence inputs, the embeddings feed the sequence layers.  It returns the columns of the output of the last
+// sequence layer.
+func (m *NNModel) dotSeq(b *strings.Builder, bSize int) int {
+	if len(m.inputsS) == 0 && !m.embSeq {
+		return 0
+	}
+
+	nSeq, e := m.construct.SeqLayers()
+	if e != nil {
+		return 0
+	}
+
+	prev := make([]string, 0)
+	chans, steps := 0, 0
+
+	for _, x := range m.inputsS {
+		fmt.Fprintf(b, "  %q [label=\"{%s|sequence input|%v}\"];\n", "in_"+x.Name(), x.Name(), x.Shape())
+		prev = append(prev, "in_"+x.Name())
+		steps, chans = x.Shape()[1], chans+x.Shape()[2]
+	}
+
+	if m.embSeq {
+		for ind := range m.inputsE {
+			emb := m.paramsEmb[m.embOf[ind]]
+			prev = append(prev, emb.Name())
+			steps, chans = steps+1, emb.Shape()[1]
+		}
+	}
+
+	recurrent := false
+
+	for ind := 1; ind <= nSeq; ind++ {
+		loc := strconv.Itoa(ind)
+		name := "layer" + loc
+
+		nPar := 0
+		for _, n := range []*G.Node{GetNode(m.paramsW, "lWeights"+loc), GetNode(m.paramsW, "rWeights"+loc),
+			GetNode(m.paramsW, "oWeights"+loc), GetNode(m.paramsB, "lBias"+loc)} {
+			if n != nil {
+				nPar += n.Shape().TotalSize()
+			}
+		}
+
+		var label string
+
+		recurrent = false
+
+		switch {
+		case m.construct.RNN(ind) != nil:
+			rnn := m.construct.RNN(ind)
+			chans, recurrent = rnn.Size, true
+			label = fmt.Sprintf("{%s %d|(%d, %d)|%d parameters}", rnn.Cell, ind, bSize, chans, nPar)
+		case m.construct.Conv(ind) != nil:
+			conv := m.construct.Conv(ind)
+			steps, chans = steps-conv.Kernel+1, conv.Filters
+			label = fmt.Sprintf("{Conv1D %d|%s|(%d, %d, %d)|%d parameters}", ind, conv.Act, bSize, steps, chans, nPar)
+		case m.construct.Attn(ind) != nil:
+			attn := m.construct.Attn(ind)
+			label = fmt.Sprintf("{Attention %d|%d heads|(%d, %d, %d)|%d parameters}", ind, attn.Heads, bSize, steps, chans, nPar)
+		case m.construct.Pool(ind) != nil:
+			pool := m.construct.Pool(ind)
+			steps /= pool.Size
+			label = fmt.Sprintf("{%s %d|(%d, %d, %d)}", pool.Pool, ind, bSize, steps, chans)
+		default:
+			continue
+		}
+
+		fmt.Fprintf(b, "  %q [label=\"%s\"];\n", name, label)
+
+		for _, p := range prev {
+			fmt.Fprintf(b, "  %q -> %q;\n", p, name)
+		}
+
+		prev = []string{name}
+	}
+
+	for _, p := range prev {
+		fmt.Fprintf(b, "  %q -> \"concat\";\n", p)
+	}
+
+	if recurrent {
+		return chans
+	}
+
+	return steps * chans
+}
@@ -0,0 +1,62 @@
+package seafan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNNModel_Visualize(t *testing.T) {
+	pipe := chPipe(100, "test1.csv")
+	mod := ModSpec{
+		"Input(x1+x2+x3+x4)",
+		"FC(size:3, activation:relu)",
+		"DropOut(.1)",
+		"FC(size:1)",
+		"Target(ycts)",
+	}
+
+	nn, e := NewNNModel(mod, pipe, true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	dot := nn.dot()
+	assert.Contains(t, dot, "FC 1|Relu|(100, 3)|15 parameters")
+	assert.Contains(t, dot, "DropOut 2|p = 0.1")
+	assert.Contains(t, dot, "FC 3|Linear|(100, 1)|4 parameters")
+	assert.Contains(t, dot, "\"layer3\" -> \"target\"")
+
+	file := os.TempDir() + "/nnViz.dot"
+	assert.Nil(t, nn.Visualize(file))
+	_ = os.Remove(file)
+
+	assert.NotNil(t, nn.Visualize(os.TempDir()+"/nnViz.png"))
+}
+
+func TestNNModel_VisualizeSeq(t *testing.T) {
+	gd := NewGData()
+	id, month, x := make([]any, 10), make([]any, 10), make([]any, 10)
+
+	for ind := range id {
+		id[ind], month[ind], x[ind] = ind%2, float64(ind/2), float64(ind)
+	}
+
+	assert.Nil(t, gd.AppendD(NewRaw(id, nil), "id", nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(month, nil), "month", false, nil, false))
+	assert.Nil(t, gd.AppendC(NewRaw(x, nil), "x", false, nil, false))
+	assert.Nil(t, gd.MakeSequence("seq", "id", "month", 4, "x"))
+
+	pipe := NewVecData("seq", gd, WithBatchSize(10))
+	nn, e := NewNNModel(ModSpec{"Input(x+seq)", "Conv1D(2, 2)", "MaxPool(3)", "FC(size:1)", "Target(month)"}, pipe,
+		true, WithCostFn(RMS))
+	assert.Nil(t, e)
+
+	dot := nn.dot()
+	assert.Contains(t, dot, "seq|sequence input|(10, 4, 1)")
+	assert.Contains(t, dot, "Conv1D 1|Linear|(10, 3, 2)|6 parameters")
+	assert.Contains(t, dot, "MaxPool 2|(10, 1, 2)")
+	assert.Contains(t, dot, "\"in_seq\" -> \"layer1\"")
+	assert.Contains(t, dot, "\"layer2\" -> \"concat\"")
+	assert.Contains(t, dot, "Input|(10, 3)")
+	assert.Contains(t, dot, "FC 3|Linear|(10, 1)|4 parameters")
+}
@@ -0,0 +1,254 @@
+package seafan
+
+// whatif.go searches for the smallest change to a row that moves the model output to a goal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/invertedv/utilities"
+)
+
+// Mutable is a field that WhatIf may change.  The field is a field of a row, in raw units: an FRCts field or the
+// FRCat field a one-hot or embedded input is made from.
+type Mutable struct {
+	Field  string  // Field is the name of the field
+	Lo, Hi float64 // Lo and Hi are the range searched for an FRCts field
+	Levels []any   // Levels are the levels searched for an FRCat field.  If nil, all the levels of its FType are.
+}
+
+// WhatIfResult is the result of WhatIf
+type WhatIfResult struct {
+	Found    bool           // Found is true if the goal is met
+	Fit0     float64        // Fit0 is the model output for the original row
+	Fit      float64        // Fit is the model output for the changed row
+	Distance float64        // Distance is the size of the change (see WhatIf)
+	Changes  map[string]any // Changes are the new values of the changed fields
+	Row0     map[string]any // Row0 is the original row
+	Row      map[string]any // Row is the changed row
+}
+
+// String produces a summary of the result
+func (wr *WhatIfResult) String() string {
+	str := fmt.Sprintf("Goal met: %v\nFit: %0.6f -> %0.6f\nDistance: %0.4f\n", wr.Found, wr.Fit0, wr.Fit, wr.Distance)
+
+	fields := make([]string, 0, len(wr.Changes))
+	for field := range wr.Changes {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		str = fmt.Sprintf("%s%s: %v -> %v\n", str, field, wr.Row0[field], wr.Changes[field])
+	}
+
+	return str
+}
+
+// WhatIf searches for the smallest change to row that moves the model output to goal.  The keys of row are field
+// names and the values are in the units of the raw data (see ScoreRow).  The output is the sum of the target columns.
+// If the output of row is below goal, the goal is met when the output is at least goal, otherwise it's met when the
+// output is at most goal.
+//
+// Only the fields in mutable are changed.  The distance of a change is the sum over the fields of |change|/(Hi-Lo)
+// for FRCts fields and 1 for each FRCat field changed.  An FRCts field is searched over steps equally spaced values
+// from Lo to Hi.
+//
+// The search is greedy.  Each round tries changing each field of the current row.  If any change meets the goal, the
+// one with the smallest distance is returned.  Otherwise, the change that moves the output furthest toward the goal
+// per unit of distance is kept and the next round starts from there.  If no round meets the goal, the row closest to
+// the goal is returned with Found false.
+func (sc *Scorer) WhatIf(row map[string]any, target []int, goal float64, mutable []*Mutable, steps int) (*WhatIfResult, error) {
+	if steps < 2 {
+		return nil, Wrapper(ErrNNModel, "WhatIf: steps must be at least 2")
+	}
+
+	if len(target) == 0 {
+		return nil, Wrapper(ErrNNModel, "WhatIf: no target columns")
+	}
+
+	for _, col := range target {
+		if col < 0 || col >= sc.outCols {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: target column %d out of range", col))
+		}
+	}
+
+	// candidate values of each field
+	cands := make([][]any, len(mutable))
+	x0 := make([]*float64, len(mutable)) // original value of FRCts fields
+
+	for ind, mut := range mutable {
+		ft := sc.pp.FTypes().Get(mut.Field)
+		if ft == nil {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: field %s not in FTypes", mut.Field))
+		}
+
+		if _, ok := row[mut.Field]; !ok {
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: field %s not in row", mut.Field))
+		}
+
+		switch ft.Role {
+		case FRCts:
+			if mut.Hi <= mut.Lo {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: range of %s is empty", mut.Field))
+			}
+
+			var e error
+			if x0[ind], e = utilities.Any2Float64(row[mut.Field]); e != nil {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: field %s: %v", mut.Field, e))
+			}
+
+			for step := 0; step < steps; step++ {
+				cands[ind] = append(cands[ind], mut.Lo+(mut.Hi-mut.Lo)*float64(step)/float64(steps-1))
+			}
+		case FRCat:
+			if ft.FP == nil || ft.FP.Lvl == nil {
+				return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: field %s has no levels", mut.Field))
+			}
+
+			if cands[ind] = mut.Levels; cands[ind] == nil {
+				cands[ind], _ = ft.FP.Lvl.Sort(true, true)
+			}
+
+			// levels mapped to the Default don't count
+			for _, lvl := range cands[ind] {
+				if _, ok := lookupLevel(&FParam{Lvl: ft.FP.Lvl}, lvl); !ok {
+					return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: %v is not a level of %s", lvl, mut.Field))
+				}
+			}
+		default:
+			return nil, Wrapper(ErrNNModel, fmt.Sprintf("WhatIf: field %s must be FRCts or FRCat", mut.Field))
+		}
+	}
+
+	score := func(r map[string]any) (float64, error) {
+		out, e := sc.ScoreRow(r)
+		if e != nil {
+			return 0, e
+		}
+
+		f := 0.0
+		for _, col := range target {
+			f += out[col]
+		}
+
+		return f, nil
+	}
+
+	fit0, e := score(row)
+	if e != nil {
+		return nil, Wrapper(e, "WhatIf")
+	}
+
+	up := fit0 < goal
+	met := func(f float64) bool {
+		return (up && f >= goal) || (!up && f <= goal)
+	}
+
+	// distance of value from the original value of field ind
+	dist := func(ind int, val any) float64 {
+		mut := mutable[ind]
+		if x0[ind] != nil {
+			return math.Abs(val.(float64)-*x0[ind]) / (mut.Hi - mut.Lo)
+		}
+
+		if fmt.Sprintf("%v", val) == fmt.Sprintf("%v", row[mut.Field]) {
+			return 0.0
+		}
+
+		return 1.0
+	}
+
+	res := &WhatIfResult{Fit0: fit0, Fit: fit0, Changes: make(map[string]any), Row0: row, Row: copyRow(row)}
+	if met(fit0) {
+		res.Found = true
+		return res, nil
+	}
+
+	cur := copyRow(row)
+	curDist := make([]float64, len(mutable))
+
+	for round := 0; round < len(mutable); round++ {
+		bestInd, bestGain, bestVal, bestFit := -1, 0.0, any(nil), res.Fit
+		foundInd, foundDist, foundVal, foundFit := -1, math.Inf(1), any(nil), 0.0
+
+		for ind, mut := range mutable {
+			old := cur[mut.Field]
+
+			for _, val := range cands[ind] {
+				cur[mut.Field] = val
+
+				f, e := score(cur)
+				if e != nil {
+					return nil, Wrapper(e, "WhatIf")
+				}
+
+				d := dist(ind, val) - curDist[ind]
+
+				if met(f) {
+					if total := res.Distance + d; total < foundDist {
+						foundInd, foundDist, foundVal, foundFit = ind, total, val, f
+					}
+
+					continue
+				}
+
+				// progress toward the goal per unit of distance
+				gain := f - res.Fit
+				if !up {
+					gain = -gain
+				}
+
+				if d > 0 {
+					gain /= d
+				}
+
+				if gain > bestGain {
+					bestInd, bestGain, bestVal, bestFit = ind, gain, val, f
+				}
+			}
+
+			cur[mut.Field] = old
+		}
+
+		if foundInd >= 0 {
+			bestInd, bestVal, bestFit = foundInd, foundVal, foundFit
+		}
+
+		if bestInd < 0 {
+			break
+		}
+
+		field := mutable[bestInd].Field
+		cur[field] = bestVal
+		res.Distance += dist(bestInd, bestVal) - curDist[bestInd]
+		curDist[bestInd] = dist(bestInd, bestVal)
+		res.Fit = bestFit
+
+		res.Changes[field] = bestVal
+		if curDist[bestInd] == 0.0 {
+			delete(res.Changes, field)
+		}
+
+		if foundInd >= 0 {
+			res.Found = true
+			break
+		}
+	}
+
+	res.Row = cur
+
+	return res, nil
+}
+
+// copyRow returns a shallow copy of row
+func copyRow(row map[string]any) map[string]any {
+	out := make(map[string]any, len(row))
+	for k, v := range row {
+		out[k] = v
+	}
+
+	return out
+}
@@ -0,0 +1,53 @@
+package seafan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScorer_WhatIf(t *testing.T) {
+	Verbose = false
+	pipe, root := scorerModel(t, 17)
+
+	sc, e := NewScorer(root, pipe.GetFTypes())
+	assert.Nil(t, e)
+
+	row := map[string]any{"x1": 0.47009787882515, "x2": 0.0336295029174111, "x3": 0.219553838861159, "x4": 0}
+
+	// a goal that moving x1 to 0.9 reaches
+	moved := copyRow(row)
+	moved["x1"] = 0.9
+	out, e := sc.ScoreRow(moved)
+	assert.Nil(t, e)
+
+	mutable := []*Mutable{{Field: "x1", Lo: 0, Hi: 1}, {Field: "x4"}}
+	res, e := sc.WhatIf(row, []int{1}, out[1], mutable, 11)
+	assert.Nil(t, e)
+	assert.True(t, res.Found)
+	assert.LessOrEqual(t, res.Distance, 0.43)
+	assert.Equal(t, 0.47009787882515, res.Row0["x1"])
+
+	fit, e := sc.ScoreRow(res.Row)
+	assert.Nil(t, e)
+	assert.InDelta(t, res.Fit, fit[1], 1e-10)
+	assert.Contains(t, res.String(), "Goal met: true")
+
+	// the row already meets the goal
+	res, e = sc.WhatIf(row, []int{1}, res.Fit0, mutable, 11)
+	assert.Nil(t, e)
+	assert.True(t, res.Found)
+	assert.Equal(t, 0, len(res.Changes))
+
+	_, e = sc.WhatIf(row, []int{1}, 0.5, []*Mutable{{Field: "x1", Lo: 1, Hi: 0}}, 11)
+	assert.NotNil(t, e)
+
+	_, e = sc.WhatIf(row, []int{1}, 0.5, []*Mutable{{Field: "x4", Levels: []any{"zz"}}}, 11)
+	assert.NotNil(t, e)
+
+	_, e = sc.WhatIf(row, []int{1}, 0.5, []*Mutable{{Field: "nope", Lo: 0, Hi: 1}}, 11)
+	assert.NotNil(t, e)
+
+	_, e = sc.WhatIf(row, nil, 0.5, mutable, 11)
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,204 @@
+package seafan
+
+// woe.go implements weight-of-evidence and information value screening of categorical fields
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/invertedv/utilities"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// woeSmooth is added to the event and non-event counts of each level to avoid infinite WoE values
+const woeSmooth = 0.5
+
+// WoE is the weight-of-evidence of the levels of an FRCat field against a binary target. The WoE of a level is
+// log(share of non-events / share of events), so levels with a high event rate have negative WoE.
+type WoE struct {
+	Field  string          // Field is the FRCat field
+	WoE    map[any]float64 // WoE of each level of Field
+	IV     float64         // IV is the information value of Field
+	ChiSq  float64         // ChiSq is the chi-square statistic of the independence of Field and the target
+	PValue float64         // PValue is the p-value of ChiSq
+	Levels int             // Levels is the number of levels of Field
+}
+
+// IVReport is the result of InformationValue, sorted by descending IV
+type IVReport []*WoE
+
+// String produces a table of the report
+func (ivr IVReport) String() string {
+	const pad = 3
+
+	table := [][]string{{"Field", "Levels", "IV", "Chi-Square", "p-value"}}
+
+	for _, w := range ivr {
+		table = append(table, []string{w.Field, fmt.Sprintf("%d", w.Levels), fmt.Sprintf("%0.4f", w.IV),
+			fmt.Sprintf("%0.2f", w.ChiSq), fmt.Sprintf("%0.4f", w.PValue)})
+	}
+
+	return utilities.Pad(table, pad)
+}
+
+// Get returns the WoE of field.  It returns nil if field is not in the report.
+func (ivr IVReport) Get(field string) *WoE {
+	for _, w := range ivr {
+		if w.Field == field {
+			return w
+		}
+	}
+
+	return nil
+}
+
+// InformationValue calculates the WoE and IV of the FRCat fields against the binary target.  target must be an FRCts
+// field; values greater than 0 are events.  If no fields are given, all the FRCat fields of pipe are used.
+func InformationValue(pipe Pipeline, target string, fields ...string) (IVReport, error) {
+	trg := pipe.Get(target)
+	if trg == nil {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("InformationValue: target %s not found", target))
+	}
+
+	if trg.FT.Role != FRCts {
+		return nil, Wrapper(ErrDiags, fmt.Sprintf("InformationValue: target %s must be FRCts", target))
+	}
+
+	if len(fields) == 0 {
+		for _, ft := range pipe.GetFTypes() {
+			if ft.Role == FRCat {
+				fields = append(fields, ft.Name)
+			}
+		}
+	}
+
+	// events
+	y := UnNormalize(append([]float64{}, trg.Floats()...), trg.FT)
+	event := make([]bool, len(y))
+	nEvent := 0
+
+	for ind, yv := range y {
+		if event[ind] = yv > 0.0; event[ind] {
+			nEvent++
+		}
+	}
+
+	if nEvent == 0 || nEvent == len(y) {
+		return nil, Wrapper(ErrDiags, "InformationValue: target has only one value")
+	}
+
+	report := make(IVReport, 0)
+
+	for _, field := range fields {
+		d := pipe.Get(field)
+		if d == nil {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("InformationValue: field %s not found", field))
+		}
+
+		if d.FT.Role != FRCat {
+			return nil, Wrapper(ErrDiags, fmt.Sprintf("InformationValue: field %s must be FRCat", field))
+		}
+
+		report = append(report, woe(d, event, nEvent))
+	}
+
+	sort.SliceStable(report, func(i, j int) bool { return report[i].IV > report[j].IV })
+
+	return report, nil
+}
+
+// woe calculates the WoE of the FRCat field d
+func woe(d *GDatum, event []bool, nEvent int) *WoE {
+	// map category codes back to levels
+	levels := make(map[int32]any)
+	for k, v := range d.FT.FP.Lvl {
+		levels[v] = k
+	}
+
+	evCnt, nonCnt := make(map[int32]float64), make(map[int32]float64)
+
+	for ind, code := range d.Data.([]int32) {
+		switch event[ind] {
+		case true:
+			evCnt[code]++
+		case false:
+			nonCnt[code]++
+		}
+	}
+
+	codes := make(map[int32]bool)
+	for c := range evCnt {
+		codes[c] = true
+	}
+
+	for c := range nonCnt {
+		codes[c] = true
+	}
+
+	n, nEv := float64(len(event)), float64(nEvent)
+	nNon := n - nEv
+	smooth := woeSmooth * float64(len(codes))
+	w := &WoE{Field: d.FT.Name, WoE: make(map[any]float64), Levels: len(codes)}
+
+	for code := range codes {
+		ev, non := evCnt[code], nonCnt[code]
+		pEv, pNon := (ev+woeSmooth)/(nEv+smooth), (non+woeSmooth)/(nNon+smooth)
+		wv := math.Log(pNon / pEv)
+
+		w.WoE[levels[code]] = wv
+		w.IV += (pNon - pEv) * wv
+
+		// chi-square of the 2 x levels table
+		tot := ev + non
+		for _, cell := range [][2]float64{{ev, tot * nEv / n}, {non, tot * nNon / n}} {
+			w.ChiSq += (cell[0] - cell[1]) * (cell[0] - cell[1]) / cell[1]
+		}
+	}
+
+	if w.Levels > 1 {
+		w.PValue = 1.0 - distuv.ChiSquared{K: float64(w.Levels - 1)}.CDF(w.ChiSq)
+	} else {
+		w.PValue = 1.0
+	}
+
+	return w
+}
+
+// AddWoE adds the WoE encoding of each field in report to pipe. The new fields are named <field>WoE and are FRCts.
+// Levels not in the report get a WoE of 0.  The names of the new fields are returned.
+func AddWoE(pipe Pipeline, report IVReport) ([]string, error) {
+	names := make([]string, 0)
+
+	for _, w := range report {
+		d := pipe.Get(w.Field)
+		if d == nil {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("AddWoE: field %s not found", w.Field))
+		}
+
+		if d.FT.Role != FRCat {
+			return nil, Wrapper(ErrPipe, fmt.Sprintf("AddWoE: field %s must be FRCat", w.Field))
+		}
+
+		woeByCode := make(map[int32]float64)
+		for k, v := range d.FT.FP.Lvl {
+			woeByCode[v] = w.WoE[k]
+		}
+
+		codes := d.Data.([]int32)
+		vals := make([]float64, len(codes))
+
+		for ind, code := range codes {
+			vals[ind] = woeByCode[code]
+		}
+
+		name := w.Field + "WoE"
+		if e := pipe.GData().AppendC(NewRawCast(vals, nil), name, false, nil, pipe.GetKeepRaw()); e != nil {
+			return nil, Wrapper(e, "AddWoE")
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,39 @@
+package seafan
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInformationValue(t *testing.T) {
+	gd := NewGData()
+	y := []float64{1, 1, 1, 0, 0, 0, 1, 0, 1, 0}
+	// x1 predicts y perfectly, x2 is unrelated
+	x1 := []string{"a", "a", "a", "b", "b", "b", "a", "b", "a", "b"}
+	x2 := []string{"c", "d", "c", "d", "c", "d", "c", "d", "d", "c"}
+	assert.Nil(t, gd.AppendC(NewRawCast(y, nil), "y", false, nil, false))
+	assert.Nil(t, gd.AppendD(NewRawCast(x1, nil), "x1", nil, false))
+	assert.Nil(t, gd.AppendD(NewRawCast(x2, nil), "x2", nil, false))
+	pipe := NewVecData("woe", gd)
+
+	report, e := InformationValue(pipe, "y")
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(report))
+	assert.Equal(t, "x1", report[0].Field)
+	assert.Greater(t, report[0].IV, report[1].IV)
+	assert.InDelta(t, 10.0, report[0].ChiSq, 1e-10)
+	assert.Less(t, report[0].PValue, 0.01)
+
+	// a has 5 events out of 5: WoE = log((0.5/6)/(5.5/6))
+	assert.InDelta(t, math.Log(0.5/5.5), report.Get("x1").WoE["a"], 1e-10)
+
+	names, e := AddWoE(pipe, report[:1])
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"x1WoE"}, names)
+	assert.InDelta(t, math.Log(5.5/0.5), pipe.Get("x1WoE").Data.([]float64)[3], 1e-10)
+
+	_, e = InformationValue(pipe, "x1")
+	assert.NotNil(t, e)
+}